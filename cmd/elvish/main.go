@@ -9,6 +9,7 @@ import (
 
 	"src.elv.sh/pkg/buildinfo"
 	"src.elv.sh/pkg/daemon"
+	"src.elv.sh/pkg/elvfmt"
 	"src.elv.sh/pkg/lsp"
 	"src.elv.sh/pkg/prog"
 	"src.elv.sh/pkg/shell"
@@ -18,6 +19,9 @@ func main() {
 	os.Exit(prog.Run(
 		[3]*os.File{os.Stdin, os.Stdout, os.Stderr}, os.Args,
 		prog.Composite(
-			&buildinfo.Program{}, &daemon.Program{}, &lsp.Program{},
+			&buildinfo.Program{}, &daemon.Program{},
+			&daemon.CtlProgram{SpawnConfig: shell.DaemonSpawnConfig},
+			&daemon.MigrateProgram{SpawnConfig: shell.DaemonSpawnConfig},
+			&lsp.Program{}, &elvfmt.Program{},
 			&shell.Program{ActivateDaemon: daemon.Activate})))
 }
@@ -7,6 +7,7 @@ import (
 
 	"src.elv.sh/pkg/buildinfo"
 	"src.elv.sh/pkg/daemon"
+	"src.elv.sh/pkg/elvfmt"
 	"src.elv.sh/pkg/lsp"
 	"src.elv.sh/pkg/pprof"
 	"src.elv.sh/pkg/prog"
@@ -17,6 +18,9 @@ func main() {
 	os.Exit(prog.Run(
 		[3]*os.File{os.Stdin, os.Stdout, os.Stderr}, os.Args,
 		prog.Composite(
-			&pprof.Program{}, &buildinfo.Program{}, &daemon.Program{}, &lsp.Program{},
+			&pprof.Program{}, &buildinfo.Program{}, &daemon.Program{},
+			&daemon.CtlProgram{SpawnConfig: shell.DaemonSpawnConfig},
+			&daemon.MigrateProgram{SpawnConfig: shell.DaemonSpawnConfig},
+			&lsp.Program{}, &elvfmt.Program{},
 			&shell.Program{ActivateDaemon: daemon.Activate})))
 }
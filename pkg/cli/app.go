@@ -5,9 +5,12 @@ import (
 	"io"
 	"os"
 	"sort"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
+	"src.elv.sh/pkg/cli/clock"
 	"src.elv.sh/pkg/cli/term"
 	"src.elv.sh/pkg/cli/tk"
 	"src.elv.sh/pkg/sys"
@@ -58,6 +61,15 @@ type App interface {
 	RedrawFull()
 	// Notify adds a note and requests a redraw.
 	Notify(note ui.Text)
+	// Bell rings the bell according to the bell style, to give feedback about
+	// an error. It never blocks.
+	Bell()
+	// Schedule arranges for f to be called from the event loop, between the
+	// handling of events, so that it can change the state of the App, like the
+	// addon stack, from another goroutine without racing with them. If ReadCode
+	// is not running, f is called when it next runs. It may block if the
+	// internal event buffer is full.
+	Schedule(f func())
 }
 
 type app struct {
@@ -67,8 +79,15 @@ type app struct {
 	TTY               TTY
 	MaxHeight         func() int
 	RPromptPersistent func() bool
+	BellStyle         func() BellStyle
+	Clock             clock.Clock
+	SemanticPrompt    func() bool
+	CommandTitle      func() bool
+	MouseTracking     func() bool
 	BeforeReadline    []func()
 	AfterReadline     []func(string)
+	AfterRedraw       []func()
+	RewriteCode       func(string) string
 	Highlighter       Highlighter
 	Prompt            Prompt
 	RPrompt           Prompt
@@ -77,15 +96,62 @@ type app struct {
 	StateMutex sync.RWMutex
 	State      State
 
+	bellMutex   sync.Mutex
+	bellPending bool
+	flashUntil  time.Time
+
 	codeArea tk.CodeArea
+
+	// Whether the start of a command output has been marked, and the end of it
+	// should be marked before the next prompt. Only accessed in ReadCode and
+	// the event loop.
+	outputMarked bool
+	// Whether the title has been set to a command, and should be set back
+	// before the next prompt. Only accessed in ReadCode and the event loop.
+	titleSet bool
+
+	// Restores the terminal set up in ReadCode. Only accessed in ReadCode and
+	// the event loop.
+	restoreTTY func()
+
+	// Whether mouse tracking is on. Only accessed in ReadCode and the event
+	// loop.
+	mouseTracking bool
+	// A mouse event waiting for the cursor position to be reported, which is
+	// needed to find where the event happened in the UI. Only accessed in the
+	// event loop.
+	pendingMouse *term.MouseEvent
+	// The widgets shown in the last redraw and where they are. Only accessed
+	// in the event loop.
+	layout []widgetRegion
+	// Whether the alternate screen is used. Only accessed in the event loop.
+	altScreen bool
+}
+
+// Where a widget is shown in the buffer.
+type widgetRegion struct {
+	widget        tk.Widget
+	top           int
+	width, height int
 }
 
+// Can be overridden in tests.
+var (
+	canSuspend     = sys.CanSuspend
+	suspendProcess = sys.Suspend
+)
+
 // State represents mutable state of an App.
 type State struct {
 	// Notes that have been added since the last redraw.
 	Notes []ui.Text
 	// The addon stack. All widgets are shown under the codearea widget. The
 	// last widget handles terminal events.
+	//
+	// If any addon implements interface{ AltScreen() bool } and returns true
+	// when .AltScreen() is called, the UI is shown in the alternate screen of
+	// the terminal, using its full height, and notes are held until the
+	// alternate screen is left.
 	Addons []tk.Widget
 }
 
@@ -97,8 +163,15 @@ func NewApp(spec AppSpec) App {
 		TTY:               spec.TTY,
 		MaxHeight:         spec.MaxHeight,
 		RPromptPersistent: spec.RPromptPersistent,
+		BellStyle:         spec.BellStyle,
+		Clock:             spec.Clock,
+		SemanticPrompt:    spec.SemanticPrompt,
+		CommandTitle:      spec.CommandTitle,
+		MouseTracking:     spec.MouseTracking,
 		BeforeReadline:    spec.BeforeReadline,
 		AfterReadline:     spec.AfterReadline,
+		AfterRedraw:       spec.AfterRedraw,
+		RewriteCode:       spec.RewriteCode,
 		Highlighter:       spec.Highlighter,
 		Prompt:            spec.Prompt,
 		RPrompt:           spec.RPrompt,
@@ -114,6 +187,21 @@ func NewApp(spec AppSpec) App {
 	if a.RPromptPersistent == nil {
 		a.RPromptPersistent = func() bool { return false }
 	}
+	if a.BellStyle == nil {
+		a.BellStyle = func() BellStyle { return SilentBell }
+	}
+	if a.Clock == nil {
+		a.Clock = clock.Real
+	}
+	if a.SemanticPrompt == nil {
+		a.SemanticPrompt = func() bool { return false }
+	}
+	if a.CommandTitle == nil {
+		a.CommandTitle = func() bool { return false }
+	}
+	if a.MouseTracking == nil {
+		a.MouseTracking = func() bool { return false }
+	}
 	if a.Highlighter == nil {
 		a.Highlighter = dummyHighlighter{}
 	}
@@ -130,13 +218,18 @@ func NewApp(spec AppSpec) App {
 	lp.RedrawCb(a.redraw)
 
 	a.codeArea = tk.NewCodeArea(tk.CodeAreaSpec{
-		Bindings:    spec.CodeAreaBindings,
-		Highlighter: a.Highlighter.Get,
-		Prompt:      a.Prompt.Get,
-		RPrompt:     a.RPrompt.Get,
-		QuotePaste:  spec.QuotePaste,
-		OnSubmit:    a.CommitCode,
-		State:       spec.CodeAreaState,
+		Bindings:      spec.CodeAreaBindings,
+		Highlighter:   a.Highlighter.Get,
+		Prompt:        a.Prompt.Get,
+		RPrompt:       a.RPrompt.Get,
+		QuotePaste:    spec.QuotePaste,
+		Reindent:      spec.Reindent,
+		MaxCodeHeight: spec.MaxCodeHeight,
+		TabWidth:      spec.TabWidth,
+		OnSubmit:      a.CommitCode,
+		State:         spec.CodeAreaState,
+
+		ContinuationPrompt: spec.ContinuationPrompt,
 
 		SimpleAbbreviations:    spec.SimpleAbbreviations,
 		CommandAbbreviations:   spec.CommandAbbreviations,
@@ -205,7 +298,9 @@ func (a *app) FocusedWidget() tk.Widget {
 }
 
 func (a *app) resetAllStates() {
-	a.MutateState(func(s *State) { *s = State{} })
+	// Notes that have not been shown, like those added by AfterReadline hooks,
+	// are kept for the next redraw.
+	a.MutateState(func(s *State) { *s = State{Notes: s.Notes} })
 	a.codeArea.MutateState(
 		func(s *tk.CodeAreaState) { *s = tk.CodeAreaState{} })
 }
@@ -219,14 +314,22 @@ func (a *app) handle(e event) {
 		case syscall.SIGINT:
 			a.resetAllStates()
 			a.triggerPrompts(true)
-		case sys.SIGWINCH:
+		case sys.SIGWINCH, sys.SIGCONT:
 			a.RedrawFull()
+		case sys.SIGTSTP:
+			if a.codeArea.CopyState().Buffer.Content == "" && len(a.CopyState().Addons) == 0 {
+				a.suspend()
+			}
 		}
+	case scheduledFunc:
+		e()
 	case term.Event:
-		target := a.ActiveWidget()
-		handled := target.Handle(e)
-		if !handled {
-			a.GlobalBindings.Handle(target, e)
+		if !a.handleMouse(e) {
+			target := a.ActiveWidget()
+			handled := target.Handle(e)
+			if !handled {
+				a.GlobalBindings.Handle(target, e)
+			}
 		}
 		if !a.loop.HasReturned() {
 			a.triggerPrompts(false)
@@ -235,35 +338,133 @@ func (a *app) handle(e event) {
 	}
 }
 
+// Handles mouse events, and the reports of the cursor position requested for
+// them. Returns whether the event has been handled.
+func (a *app) handleMouse(e term.Event) bool {
+	if !a.mouseTracking {
+		return false
+	}
+	switch e := e.(type) {
+	case term.MouseEvent:
+		// Mouse events carry the position on the screen, but where the UI is
+		// on the screen is only known from the position of the cursor.
+		a.pendingMouse = &e
+		a.TTY.RequestCursorPosition()
+		return true
+	case term.CursorPosition:
+		if a.pendingMouse == nil {
+			return false
+		}
+		e2 := *a.pendingMouse
+		a.pendingMouse = nil
+		// The cursor is at the dot of the buffer. Both positions are 1-based.
+		dot := a.TTY.Buffer().Dot
+		e2.Pos = term.Pos{Line: dot.Line + e2.Line - e.Line, Col: e2.Col - 1}
+		for _, r := range a.layout {
+			if r.top <= e2.Line && e2.Line < r.top+r.height {
+				if h, ok := r.widget.(tk.MouseHandler); ok {
+					e2.Line -= r.top
+					h.HandleMouse(e2, r.width, r.height)
+				}
+				break
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Suspends the process, like what happens to programs that don't handle
+// SIGTSTP, restoring the terminal while the process is stopped.
+func (a *app) suspend() {
+	// Check before touching the terminal, so that nothing changes when
+	// suspending is refused.
+	if err := canSuspend(); err != nil {
+		a.Notify(ui.T(err.Error()))
+		return
+	}
+	// Leave the cursor below the command line, like after committing the code.
+	a.redraw(finalRedraw)
+	if a.mouseTracking {
+		a.TTY.SetMouseTracking(false)
+	}
+	a.restoreTTY()
+	err := suspendProcess()
+	restore, errSetup := a.TTY.Setup()
+	a.restoreTTY = restore
+	if a.mouseTracking {
+		a.TTY.SetMouseTracking(true)
+	}
+	if err != nil {
+		a.Notify(ui.T("failed to suspend: " + err.Error()))
+	}
+	if errSetup != nil {
+		a.Notify(ui.T("failed to set up terminal: " + errSetup.Error()))
+	}
+	a.RedrawFull()
+}
+
 func (a *app) triggerPrompts(force bool) {
 	a.Prompt.Trigger(force)
 	a.RPrompt.Trigger(force)
 }
 
+// A Prompt that can cancel its ongoing computation, like *prompt.Prompt.
+type canceler interface {
+	Cancel()
+}
+
+func (a *app) cancelPrompts() {
+	for _, p := range []Prompt{a.Prompt, a.RPrompt} {
+		if c, ok := p.(canceler); ok {
+			c.Cancel()
+		}
+	}
+}
+
 func (a *app) redraw(flag redrawFlag) {
+	isFinalRedraw := flag&finalRedraw != 0
+	var addons []tk.Widget
+	a.MutateState(func(s *State) {
+		addons = append([]tk.Widget(nil), s.Addons...)
+	})
+	// The final redraw is always on the main screen, so that the code stays
+	// above the output.
+	altScreen := !isFinalRedraw && useAltScreen(addons)
+	if altScreen != a.altScreen {
+		a.TTY.SetAltScreen(altScreen)
+		a.altScreen = altScreen
+	}
+
 	// Get the dimensions available.
 	height, width := a.TTY.Size()
-	if maxHeight := a.MaxHeight(); maxHeight > 0 && maxHeight < height {
+	if maxHeight := a.MaxHeight(); !altScreen && maxHeight > 0 && maxHeight < height {
 		height = maxHeight
 	}
 
 	var notes []ui.Text
-	var addons []tk.Widget
-	a.MutateState(func(s *State) {
-		notes = s.Notes
-		s.Notes = nil
-		addons = append([]tk.Widget(nil), s.Addons...)
-	})
+	if !altScreen {
+		// Notes would scroll off the alternate screen, which has no
+		// scrollback, so they are held until it is left.
+		a.MutateState(func(s *State) {
+			notes = s.Notes
+			s.Notes = nil
+		})
+	}
 
 	bufNotes := renderNotes(notes, width)
-	isFinalRedraw := flag&finalRedraw != 0
 	if isFinalRedraw {
 		hideRPrompt := !a.RPromptPersistent()
 		a.codeArea.MutateState(func(s *tk.CodeAreaState) {
 			s.HideTips = true
 			s.HideRPrompt = hideRPrompt
 		})
-		bufMain := renderApp([]tk.Widget{a.codeArea /* no addon */}, width, height)
+		bufMain, _ := renderApp([]tk.Widget{a.codeArea /* no addon */}, width, height)
+		semanticPrompt := a.SemanticPrompt()
+		var promptEnd term.Pos
+		if semanticPrompt {
+			promptEnd = a.promptEnd(width, height)
+		}
 		a.codeArea.MutateState(func(s *tk.CodeAreaState) {
 			s.HideTips = false
 			s.HideRPrompt = false
@@ -272,13 +473,51 @@ func (a *app) redraw(flag redrawFlag) {
 		bufMain.Extend(term.NewBuffer(width), true)
 
 		a.TTY.UpdateBuffer(bufNotes, bufMain, flag&fullRedraw != 0)
+		if semanticPrompt {
+			a.TTY.WriteMark(term.PromptStartMark, term.Pos{})
+			a.TTY.WriteMark(term.CommandStartMark, promptEnd)
+			a.TTY.WriteMark(term.OutputStartMark, bufMain.Dot)
+			a.outputMarked = true
+		}
+		if a.CommandTitle() {
+			if title := commandTitle(a.codeArea.CopyState().Buffer.Content); title != "" {
+				a.TTY.SetTitle(title)
+				a.titleSet = true
+			}
+		}
 		a.TTY.ResetBuffer()
 	} else {
-		bufMain := renderApp(append([]tk.Widget{a.codeArea}, addons...), width, height)
+		ring, flash := a.extractBell()
+		if ring {
+			a.TTY.Bell()
+		}
+		bufMain, layout := renderApp(append([]tk.Widget{a.codeArea}, addons...), width, height)
+		a.layout = layout
+		if flash {
+			if len(addons) > 0 {
+				// The mode line is the first line of the last addon.
+				flashLine(bufMain, layout[len(layout)-1].top)
+			} else {
+				flashLine(bufMain, bufMain.Dot.Line)
+			}
+		}
 		a.TTY.UpdateBuffer(bufNotes, bufMain, flag&fullRedraw != 0)
+		for _, f := range a.AfterRedraw {
+			f()
+		}
 	}
 }
 
+// Returns whether any of the addons wants to be shown in the alternate screen.
+func useAltScreen(addons []tk.Widget) bool {
+	for _, w := range addons {
+		if a, ok := w.(interface{ AltScreen() bool }); ok && a.AltScreen() {
+			return true
+		}
+	}
+	return false
+}
+
 // Renders notes. This does not respect height so that overflow notes end up in
 // the scrollback buffer.
 func renderNotes(notes []ui.Text, width int) *term.Buffer {
@@ -295,22 +534,70 @@ func renderNotes(notes []ui.Text, width int) *term.Buffer {
 	return bb.Buffer()
 }
 
-// Renders the codearea, and uses the rest of the height for the listing.
-func renderApp(widgets []tk.Widget, width, height int) *term.Buffer {
+// Returns the position where the prompt ends and the code starts, by rendering
+// the code area without any code. Must be called with HideTips and HideRPrompt
+// set.
+func (a *app) promptEnd(width, height int) term.Pos {
+	state := a.codeArea.CopyState()
+	a.codeArea.MutateState(func(s *tk.CodeAreaState) {
+		s.Buffer, s.Pending = tk.CodeBuffer{}, tk.PendingCode{}
+	})
+	buf, _ := renderApp([]tk.Widget{a.codeArea}, width, height)
+	a.codeArea.MutateState(func(s *tk.CodeAreaState) {
+		s.Buffer, s.Pending = state.Buffer, state.Pending
+	})
+	return buf.Dot
+}
+
+// Returns the title to show while running the code: its first non-empty line,
+// with surrounding whitespace removed.
+func commandTitle(code string) string {
+	for _, line := range strings.Split(code, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// Renders the codearea, and uses the rest of the height for the listing. Also
+// returns where each widget rendered is.
+func renderApp(widgets []tk.Widget, width, height int) (*term.Buffer, []widgetRegion) {
 	heights, focus := distributeHeight(widgets, width, height)
 	var buf *term.Buffer
+	var regions []widgetRegion
 	for i, w := range widgets {
 		if heights[i] == 0 {
 			continue
 		}
 		buf2 := w.Render(width, heights[i])
+		top := 0
 		if buf == nil {
 			buf = buf2
 		} else {
+			top = len(buf.Lines)
 			buf.Extend(buf2, i == focus)
 		}
+		regions = append(regions, widgetRegion{w, top, width, len(buf2.Lines)})
+	}
+	return buf, regions
+}
+
+// Shows a line of the buffer in reverse video, for the visual bell.
+func flashLine(buf *term.Buffer, i int) {
+	if i < 0 || i >= len(buf.Lines) {
+		return
+	}
+	line := make([]term.Cell, len(buf.Lines[i]))
+	for j, cell := range buf.Lines[i] {
+		if cell.Style == "" {
+			cell.Style = "7"
+		} else {
+			cell.Style += ";7"
+		}
+		line[j] = cell
 	}
-	return buf
+	buf.Lines[i] = line
 }
 
 // Distributes the height among all the widgets. Returns the height for each
@@ -392,12 +679,19 @@ func hasFocus(w any) bool {
 	return true
 }
 
-func (a *app) ReadCode() (string, error) {
+func (a *app) ReadCode() (code string, err error) {
 	for _, f := range a.BeforeReadline {
 		f()
 	}
 	defer func() {
+		// The prompts won't be shown until the next call to ReadCode, which
+		// triggers them again anyway.
+		a.cancelPrompts()
 		content := a.codeArea.CopyState().Buffer.Content
+		if err == nil && a.RewriteCode != nil {
+			code = a.RewriteCode(code)
+			content = code
+		}
 		for _, f := range a.AfterReadline {
 			f(content)
 		}
@@ -408,7 +702,26 @@ func (a *app) ReadCode() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	defer restore()
+	a.restoreTTY = restore
+	if a.outputMarked {
+		a.TTY.WriteMark(term.CommandEndMark, term.Pos{})
+		a.outputMarked = false
+	}
+	if a.titleSet {
+		a.TTY.SetTitle("elvish")
+		a.titleSet = false
+	}
+	// Suspending replaces restoreTTY.
+	defer func() { a.restoreTTY() }()
+	a.mouseTracking = a.MouseTracking()
+	if a.mouseTracking {
+		a.TTY.SetMouseTracking(true)
+		defer func() {
+			a.TTY.SetMouseTracking(false)
+			a.mouseTracking = false
+			a.pendingMouse = nil
+		}()
+	}
 
 	var wg sync.WaitGroup
 	defer wg.Wait()
@@ -495,6 +808,13 @@ func (a *app) CommitCode() {
 	a.loop.Return(code, nil)
 }
 
+// An event for a function passed to Schedule.
+type scheduledFunc func()
+
+func (a *app) Schedule(f func()) {
+	a.loop.Input(scheduledFunc(f))
+}
+
 func (a *app) Notify(note ui.Text) {
 	a.MutateState(func(s *State) { s.Notes = append(s.Notes, note) })
 	a.Redraw()
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"src.elv.sh/pkg/cli/clock"
 	"src.elv.sh/pkg/cli/tk"
 	"src.elv.sh/pkg/ui"
 )
@@ -9,17 +10,45 @@ import (
 type AppSpec struct {
 	TTY               TTY
 	MaxHeight         func() int
+	MaxCodeHeight     func() int
+	TabWidth          func() int
 	RPromptPersistent func() bool
-	BeforeReadline    []func()
-	AfterReadline     []func(string)
+	BellStyle         func() BellStyle
+	// The clock used for timing the visual bell. Default is clock.Real.
+	Clock clock.Clock
+	// Whether to write semantic marks (OSC 133) around the prompt, the
+	// command and its output. Default is false.
+	SemanticPrompt func() bool
+	// Whether to set the title of the terminal, or the pane or window in tmux
+	// or screen, to the first line of the command while it is running, and
+	// back to "elvish" when the next prompt is shown. Default is false.
+	CommandTitle func() bool
+	// Whether to turn on mouse tracking while reading code, so that clicking
+	// moves the cursor or selects an item, and scrolling moves through
+	// listings. Mouse tracking takes over clicks from the terminal, so text
+	// can no longer be selected as usual. This is read when ReadCode starts.
+	// Default is false.
+	MouseTracking  func() bool
+	BeforeReadline []func()
+	AfterReadline  []func(string)
+	// Called after each redraw while reading code, except the final one. They
+	// are called from the goroutine that redraws, so they must not block.
+	AfterRedraw []func()
+	// If not nil, called with the code that has been read, and the code it
+	// returns is passed to AfterReadline and returned from ReadCode instead.
+	RewriteCode func(string) string
 
 	Highlighter Highlighter
 	Prompt      Prompt
 	RPrompt     Prompt
+	// Passed to the code area as CodeAreaSpec.ContinuationPrompt.
+	ContinuationPrompt func(lineno int) ui.Text
 
 	GlobalBindings   tk.Bindings
 	CodeAreaBindings tk.Bindings
 	QuotePaste       func() bool
+	// Passed to the code area as CodeAreaSpec.Reindent.
+	Reindent func(buf tk.CodeBuffer, r rune) tk.CodeBuffer
 
 	SimpleAbbreviations    func(f func(abbr, full string))
 	CommandAbbreviations   func(f func(abbr, full string))
@@ -3,6 +3,7 @@ package cli_test
 import (
 	"errors"
 	"io"
+	"reflect"
 	"strings"
 	"syscall"
 	"testing"
@@ -57,6 +58,20 @@ func TestReadCode_ResetsStateBeforeReturning(t *testing.T) {
 	}
 }
 
+func TestReadCode_KeepsNotesAddedByAfterReadline(t *testing.T) {
+	var app App
+	f := Setup(WithSpec(func(spec *AppSpec) {
+		spec.AfterReadline = []func(string){func(string) { app.Notify(ui.T("note")) }}
+	}))
+	app = f.App
+
+	f.Stop()
+
+	if notes := f.App.CopyState().Notes; len(notes) != 1 {
+		t.Errorf("got notes %v, want 1 note", notes)
+	}
+}
+
 func TestReadCode_CallsBeforeReadline(t *testing.T) {
 	callCh := make(chan bool, 1)
 	f := Setup(WithSpec(func(spec *AppSpec) {
@@ -106,6 +121,71 @@ func TestReadCode_CallsAfterReadline(t *testing.T) {
 	}
 }
 
+func TestReadCode_CallsAfterRedraw(t *testing.T) {
+	callCh := make(chan bool, 1)
+	f := Setup(WithSpec(func(spec *AppSpec) {
+		spec.AfterRedraw = []func(){func() {
+			select {
+			case callCh <- true:
+			default:
+			}
+		}}
+	}))
+	defer f.Stop()
+
+	select {
+	case <-callCh:
+		if f.TTY.LastBuffer() == nil {
+			t.Errorf("AfterRedraw called before the buffer is updated")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("AfterRedraw not called")
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	called := make(chan struct{})
+	f.App.Schedule(func() {
+		f.App.PushAddon(tk.Empty{})
+		close(called)
+	})
+	select {
+	case <-called:
+		if addons := f.App.CopyState().Addons; len(addons) != 1 {
+			t.Errorf("got %d addons, want 1", len(addons))
+		}
+	case <-time.After(time.Second):
+		t.Errorf("scheduled function not called")
+	}
+}
+
+func TestReadCode_RewriteCode(t *testing.T) {
+	callCh := make(chan string, 1)
+	f := Setup(WithSpec(func(spec *AppSpec) {
+		spec.RewriteCode = func(s string) string { return s + "!" }
+		spec.AfterReadline = []func(string){func(s string) { callCh <- s }}
+	}))
+
+	feedInput(f.TTY, "abc\n")
+	code, err := f.Wait()
+
+	if code != "abc!" || err != nil {
+		t.Errorf("ReadCode -> (%q, %v), want (%q, nil)", code, err, "abc!")
+	}
+	select {
+	case calledWith := <-callCh:
+		if calledWith != "abc!" {
+			t.Errorf("AfterReadline hook called with %v, want %v",
+				calledWith, "abc!")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("AfterReadline not called")
+	}
+}
+
 func TestReadCode_FinalRedraw(t *testing.T) {
 	f := Setup(WithSpec(func(spec *AppSpec) {
 		spec.CodeAreaState.Buffer.Content = "code"
@@ -166,12 +246,96 @@ func TestReadCode_RedrawsOnSIGWINCH(t *testing.T) {
 	f.TTY.TestBuffer(t, bb().Write("1234567890").SetDotHere().Buffer())
 
 	// Emulate a window size change.
-	f.TTY.SetSize(24, 4)
-	f.TTY.InjectSignal(sys.SIGWINCH)
+	f.Resize(4, 24)
 
 	// Test that the editor has redrawn using the new width.
-	f.TTY.TestBuffer(t, term.NewBufferBuilder(4).
-		Write("1234567890").SetDotHere().Buffer())
+	f.TestTTY(t, "1234567890", term.DotHere)
+	f.TestTTYAt(t, 2, 0, "90")
+}
+
+func TestReadCode_SuspendsOnSIGTSTPWithEmptyBuffer(t *testing.T) {
+	testutil.Set(t, CanSuspend, func() error { return nil })
+	suspended := make(chan struct{}, 1)
+	testutil.Set(t, SuspendProcess, func() error {
+		suspended <- struct{}{}
+		return nil
+	})
+	restoreCalled := 0
+	f := Setup(WithTTY(func(tty TTYCtrl) {
+		tty.SetSetup(func() { restoreCalled++ }, nil)
+	}))
+	f.TTY.TestBuffer(t, bb().SetDotHere().Buffer())
+
+	f.TTY.InjectSignal(sys.SIGTSTP)
+
+	select {
+	case <-suspended:
+	case <-time.After(testutil.Scaled(time.Second)):
+		t.Fatal("process not suspended")
+	}
+	// The editor moves the cursor below the command line before suspending,
+	// and redraws after resuming.
+	f.TTY.TestBuffer(t, bb().Newline().SetDotHere().Buffer())
+	f.TTY.TestBuffer(t, bb().SetDotHere().Buffer())
+	f.Stop()
+	// The terminal is restored before suspending, and again when ReadCode
+	// returns.
+	if restoreCalled != 2 {
+		t.Errorf("restore called %v times, want 2", restoreCalled)
+	}
+}
+
+func TestReadCode_DoesNotSuspendWhenRefused(t *testing.T) {
+	testutil.Set(t, CanSuspend, func() error {
+		return errors.New("cannot suspend a login shell")
+	})
+	testutil.Set(t, SuspendProcess, func() error {
+		t.Errorf("process suspended")
+		return nil
+	})
+	restoreCalled := 0
+	f := Setup(WithTTY(func(tty TTYCtrl) {
+		tty.SetSetup(func() { restoreCalled++ }, nil)
+	}))
+	f.TTY.TestBuffer(t, bb().SetDotHere().Buffer())
+
+	f.TTY.InjectSignal(sys.SIGTSTP)
+
+	f.TestTTYNotes(t, "cannot suspend a login shell")
+	f.Stop()
+	// The terminal is only restored when ReadCode returns.
+	if restoreCalled != 1 {
+		t.Errorf("restore called %v times, want 1", restoreCalled)
+	}
+}
+
+func TestReadCode_DoesNotSuspendOnSIGTSTPWithNonEmptyBuffer(t *testing.T) {
+	testutil.Set(t, SuspendProcess, func() error {
+		t.Errorf("process suspended")
+		return nil
+	})
+	f := Setup()
+	defer f.Stop()
+	feedInput(f.TTY, "code")
+	f.TTY.TestBuffer(t, bb().Write("code").SetDotHere().Buffer())
+
+	f.TTY.InjectSignal(sys.SIGTSTP)
+	// Make sure that the signal has been handled.
+	f.TTY.InjectSignal(sys.SIGWINCH)
+	f.TTY.TestBuffer(t, bb().Write("code").SetDotHere().Buffer())
+}
+
+func TestReadCode_RedrawsOnSIGCONT(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+	feedInput(f.TTY, "code")
+	f.TTY.TestBuffer(t, bb().Write("code").SetDotHere().Buffer())
+
+	f.TTY.SetSize(24, 3)
+	f.TTY.InjectSignal(sys.SIGCONT)
+
+	f.TTY.TestBuffer(t, term.NewBufferBuilder(3).
+		Write("code").SetDotHere().Buffer())
 }
 
 // Code area.
@@ -184,6 +348,20 @@ func TestReadCode_LetsCodeAreaHandleEvents(t *testing.T) {
 	f.TTY.TestBuffer(t, bb().Write("code").SetDotHere().Buffer())
 }
 
+func TestReadCode_InsertsPastedTextLiterally(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	// A newline in pasted text doesn't submit the code.
+	f.TTY.Inject(term.PasteEvent("echo a\necho b"))
+	f.TestTTY(t, "echo a\n", "echo b", term.DotHere)
+
+	f.TTY.Inject(term.K('\n'))
+	if code, err := f.Wait(); code != "echo a\necho b" || err != nil {
+		t.Errorf("got (%q, %v), want (%q, nil)", code, err, "echo a\necho b")
+	}
+}
+
 func TestReadCode_ShowsHighlightedCode(t *testing.T) {
 	f := Setup(withHighlighter(
 		testHighlighter{
@@ -269,6 +447,21 @@ func TestReadCode_CallsPromptTrigger(t *testing.T) {
 	}
 }
 
+func TestReadCode_CancelsPromptsWhenFinished(t *testing.T) {
+	var cancelled []string
+	f := Setup(WithSpec(func(spec *AppSpec) {
+		spec.Prompt = cancelablePrompt{func() { cancelled = append(cancelled, "prompt") }}
+		spec.RPrompt = cancelablePrompt{func() { cancelled = append(cancelled, "rprompt") }}
+	}))
+
+	f.TTY.Inject(term.K('\n'))
+	f.Wait()
+
+	if want := []string{"prompt", "rprompt"}; !reflect.DeepEqual(cancelled, want) {
+		t.Errorf("got cancelled %v, want %v", cancelled, want)
+	}
+}
+
 func TestReadCode_RedrawsOnLateUpdateFromPrompt(t *testing.T) {
 	promptContent := "old"
 	prompt := testPrompt{
@@ -335,6 +528,171 @@ func TestReadCode_HidesRPromptInFinalRedrawIfNotPersistent(t *testing.T) {
 	f.TTY.TestBuffer(t, wantBuf)
 }
 
+func TestReadCode_WritesSemanticMarks(t *testing.T) {
+	f := Setup(WithSpec(func(spec *AppSpec) {
+		spec.CodeAreaState.Buffer.Content = "code"
+		spec.Prompt = NewConstPrompt(ui.T("> "))
+		spec.SemanticPrompt = func() bool { return true }
+	}))
+
+	f.TTY.Inject(term.K('\n'))
+	f.Wait()
+
+	wantMarks := []Mark{
+		{Mark: term.PromptStartMark, Pos: term.Pos{Line: 0, Col: 0}},
+		{Mark: term.CommandStartMark, Pos: term.Pos{Line: 0, Col: 2}},
+		{Mark: term.OutputStartMark, Pos: term.Pos{Line: 1, Col: 0}},
+	}
+	if marks := f.TTY.Marks(); !reflect.DeepEqual(marks, wantMarks) {
+		t.Errorf("got marks %v, want %v", marks, wantMarks)
+	}
+}
+
+func TestReadCode_NoSemanticMarksByDefault(t *testing.T) {
+	f := Setup()
+
+	f.TTY.Inject(term.K('\n'))
+	f.Wait()
+
+	if marks := f.TTY.Marks(); len(marks) != 0 {
+		t.Errorf("got marks %v, want none", marks)
+	}
+}
+
+func TestReadCode_SetsCommandTitle(t *testing.T) {
+	f := Setup(WithSpec(func(spec *AppSpec) {
+		spec.CodeAreaState.Buffer.Content = "\n  echo foo\necho bar"
+		spec.CommandTitle = func() bool { return true }
+	}))
+
+	f.TTY.Inject(term.K('\n'))
+	f.Wait()
+	if titles := f.TTY.Titles(); !reflect.DeepEqual(titles, []string{"echo foo"}) {
+		t.Errorf("got titles %q, want %q", titles, []string{"echo foo"})
+	}
+}
+
+func TestReadCode_MouseTracking(t *testing.T) {
+	f := Setup(WithSpec(func(spec *AppSpec) {
+		spec.MouseTracking = func() bool { return true }
+	}))
+	f.TestTTY(t, term.DotHere)
+	if !f.TTY.MouseTracking() {
+		t.Errorf("mouse tracking not turned on")
+	}
+	f.Stop()
+	if f.TTY.MouseTracking() {
+		t.Errorf("mouse tracking not turned off")
+	}
+}
+
+func TestReadCode_NoMouseTrackingByDefault(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+	f.TestTTY(t, term.DotHere)
+	if f.TTY.MouseTracking() {
+		t.Errorf("mouse tracking turned on")
+	}
+	// Mouse events are passed to the widget like other events.
+	f.TTY.Inject(term.MouseEvent{Pos: term.Pos{Line: 1, Col: 1}, Down: true})
+	f.TTY.Inject(term.K('a'))
+	f.TestTTY(t, "a", term.DotHere)
+	if n := f.TTY.CursorPositionRequests(); n != 0 {
+		t.Errorf("got %d cursor position requests, want 0", n)
+	}
+}
+
+// Sets up a fixture with mouse tracking.
+func setupMouse(fns ...func(*AppSpec, TTYCtrl)) *Fixture {
+	return Setup(append([]func(*AppSpec, TTYCtrl){WithSpec(func(spec *AppSpec) {
+		spec.MouseTracking = func() bool { return true }
+	})}, fns...)...)
+}
+
+// Clicks with the left button at the given position of the UI, which starts at
+// line 10 of the screen. The cursor is at the dot of the last buffer.
+func click(f *Fixture, pos term.Pos) {
+	dot := f.TTY.LastBuffer().Dot
+	f.TTY.Inject(
+		term.MouseEvent{Pos: term.Pos{Line: 10 + pos.Line, Col: pos.Col + 1},
+			Down: true, Button: term.LeftButton},
+		term.CursorPosition{Line: 10 + dot.Line, Col: dot.Col + 1})
+}
+
+func TestReadCode_ClickMovesDot(t *testing.T) {
+	f := setupMouse(WithSpec(func(spec *AppSpec) {
+		spec.Prompt = NewConstPrompt(ui.T("> "))
+		spec.CodeAreaState.Buffer = tk.CodeBuffer{Content: "echo foo\necho bar", Dot: 17}
+	}))
+	defer f.Stop()
+	f.TestTTY(t, "> echo foo\n", "  echo bar", term.DotHere)
+
+	click(f, term.Pos{Line: 0, Col: 4})
+	f.TestTTY(t, "> ec", term.DotHere, "ho foo\n", "  echo bar")
+	if n := f.TTY.CursorPositionRequests(); n != 1 {
+		t.Errorf("got %d cursor position requests, want 1", n)
+	}
+
+	// Clicking after the end of a line moves the dot to the end of it.
+	click(f, term.Pos{Line: 1, Col: 30})
+	f.TestTTY(t, "> echo foo\n", "  echo bar", term.DotHere)
+
+	// Clicking on the prompt moves the dot to the start of the code.
+	click(f, term.Pos{Line: 0, Col: 0})
+	f.TestTTY(t, "> ", term.DotHere, "echo foo\n", "  echo bar")
+}
+
+func TestReadCode_ClickSelectsAndAcceptsItem(t *testing.T) {
+	accepted := make(chan int, 1)
+	f := setupMouse()
+	defer f.Stop()
+	f.App.PushAddon(tk.NewListBox(tk.ListBoxSpec{
+		State:    tk.ListBoxState{Items: tk.TestItems{NItems: 3}},
+		OnAccept: func(_ tk.Items, i int) { accepted <- i },
+	}))
+	f.TestTTYAt(t, 1, 0, "item 0", Styles, "++++++")
+
+	click(f, term.Pos{Line: 2, Col: 3})
+	f.TestTTYAt(t, 1, 0, "item 0\n", "item 1", Styles, "++++++")
+
+	click(f, term.Pos{Line: 2, Col: 3})
+	select {
+	case i := <-accepted:
+		if i != 1 {
+			t.Errorf("got item %d accepted, want 1", i)
+		}
+	case <-time.After(testutil.Scaled(time.Second)):
+		t.Errorf("no item accepted")
+	}
+}
+
+func TestReadCode_WheelMovesSelection(t *testing.T) {
+	f := setupMouse()
+	defer f.Stop()
+	f.App.PushAddon(tk.NewListBox(tk.ListBoxSpec{
+		State: tk.ListBoxState{Items: tk.TestItems{NItems: 3}}}))
+	f.TestTTYAt(t, 1, 0, "item 0", Styles, "++++++")
+
+	f.TTY.Inject(
+		term.MouseEvent{Pos: term.Pos{Line: 11, Col: 1}, Down: true,
+			Button: term.WheelDownButton},
+		term.CursorPosition{Line: 11, Col: 1})
+	f.TestTTYAt(t, 2, 0, "item 1", Styles, "++++++")
+}
+
+func TestReadCode_NoCommandTitleByDefault(t *testing.T) {
+	f := Setup(WithSpec(func(spec *AppSpec) {
+		spec.CodeAreaState.Buffer.Content = "code"
+	}))
+
+	f.TTY.Inject(term.K('\n'))
+	f.Wait()
+
+	if titles := f.TTY.Titles(); len(titles) != 0 {
+		t.Errorf("got titles %q, want none", titles)
+	}
+}
+
 // Addon.
 
 func TestReadCode_LetsLastWidgetHandleEvents(t *testing.T) {
@@ -424,6 +782,49 @@ func TestReadCode_HidesAddonsWhenNotEnoughSpace(t *testing.T) {
 		term.DotHere, "addon2> ")
 }
 
+func TestReadCode_UsesAltScreenForAddons(t *testing.T) {
+	f := Setup(WithSpec(func(spec *AppSpec) {
+		spec.MaxHeight = func() int { return 1 }
+	}))
+	defer f.Stop()
+
+	f.TestTTY(t /* nothing */)
+
+	f.App.PushAddon(altScreenAddon{tk.Label{Content: ui.T("addon> ")}})
+	f.App.Notify(ui.T("note"))
+	// MaxHeight doesn't apply in the alternate screen.
+	f.TestTTY(t, "\n",
+		term.DotHere, "addon> ")
+	if !f.TTY.AltScreen() {
+		t.Errorf("alternate screen not used")
+	}
+	// Notes are held until the alternate screen is left.
+	if n := len(f.App.CopyState().Notes); n != 1 {
+		t.Errorf("State.Notes has %d elements, want 1", n)
+	}
+
+	f.App.PopAddon()
+	f.App.Redraw()
+	f.TTY.TestNotesBuffer(t, bb().Write("note").Buffer())
+	if f.TTY.AltScreen() {
+		t.Errorf("alternate screen still used after popping addon")
+	}
+}
+
+func TestReadCode_LeavesAltScreenForFinalRedraw(t *testing.T) {
+	f := Setup(WithSpec(func(spec *AppSpec) {
+		spec.State.Addons = []tk.Widget{
+			altScreenAddon{tk.Label{Content: ui.T("addon> ")}}}
+	}))
+
+	f.TestTTY(t, "\n",
+		term.DotHere, "addon> ")
+	f.Stop()
+	if f.TTY.AltScreen() {
+		t.Errorf("alternate screen still used after ReadCode returns")
+	}
+}
+
 type testAddon struct {
 	tk.Label
 	focus bool
@@ -431,6 +832,10 @@ type testAddon struct {
 
 func (a testAddon) Focus() bool { return a.focus }
 
+type altScreenAddon struct{ tk.Label }
+
+func (altScreenAddon) AltScreen() bool { return true }
+
 // Misc features.
 
 func TestReadCode_UsesGlobalBindingsWithCodeAreaTarget(t *testing.T) {
@@ -530,6 +935,62 @@ func TestReadCode_ShowNotes(t *testing.T) {
 	}
 }
 
+func TestBell_Silent(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	f.App.Bell()
+	f.App.Notify(ui.T("note"))
+	f.TestTTYNotes(t, "note")
+	if n := f.TTY.Bells(); n != 0 {
+		t.Errorf("bell rung %d times, want 0", n)
+	}
+}
+
+func TestBell_Audible(t *testing.T) {
+	f := Setup(WithSpec(func(spec *AppSpec) {
+		spec.BellStyle = func() BellStyle { return AudibleBell }
+	}))
+	defer f.Stop()
+
+	f.App.Bell()
+	f.App.Notify(ui.T("note"))
+	f.TestTTYNotes(t, "note")
+	if n := f.TTY.Bells(); n != 1 {
+		t.Errorf("bell rung %d times, want 1", n)
+	}
+}
+
+func TestBell_Visual(t *testing.T) {
+	clock := NewFakeClock()
+	f := Setup(WithSpec(func(spec *AppSpec) {
+		spec.BellStyle = func() BellStyle { return VisualBell }
+		spec.Clock = clock
+		spec.CodeAreaState.Buffer = tk.CodeBuffer{Content: "code", Dot: 4}
+	}))
+	defer f.Stop()
+	f.TestTTY(t, "code", term.DotHere)
+
+	// Without addons, the line of the cursor is flashed.
+	f.App.Bell()
+	f.TestTTY(t, "code", Styles,
+		"++++", term.DotHere)
+	clock.Advance(*VisualBellDuration)
+	f.TestTTY(t, "code", term.DotHere)
+
+	// With addons, the first line of the last addon is flashed.
+	f.App.PushAddon(tk.Label{Content: ui.T("mode line\nlisting")})
+	f.App.Bell()
+	f.TestTTY(t, "code\n", term.DotHere,
+		"mode line", Styles,
+		"+++++++++", "\n",
+		"listing")
+	clock.Advance(*VisualBellDuration)
+	f.TestTTY(t, "code\n", term.DotHere,
+		"mode line\n",
+		"listing")
+}
+
 func TestReadCode_DoesNotCrashWithNilTTY(t *testing.T) {
 	f := Setup(WithSpec(func(spec *AppSpec) { spec.TTY = nil }))
 	defer f.Stop()
@@ -619,3 +1080,12 @@ func (p testPrompt) Get() ui.Text {
 func (p testPrompt) LateUpdates() <-chan struct{} {
 	return p.lateUpdates
 }
+
+type cancelablePrompt struct {
+	cancel func()
+}
+
+func (p cancelablePrompt) Trigger(bool)                 {}
+func (p cancelablePrompt) Get() ui.Text                 { return nil }
+func (p cancelablePrompt) LateUpdates() <-chan struct{} { return nil }
+func (p cancelablePrompt) Cancel()                      { p.cancel() }
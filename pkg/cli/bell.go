@@ -0,0 +1,51 @@
+package cli
+
+import "time"
+
+// BellStyle specifies how an App rings the bell, which it does to give
+// feedback about errors like having no completion candidates.
+type BellStyle int
+
+// Possible values of BellStyle.
+const (
+	// Don't give any feedback beyond the notification of the error, if any.
+	SilentBell BellStyle = iota
+	// Ring the terminal bell.
+	AudibleBell
+	// Flash the mode line in reverse video, or the line of the cursor if no
+	// mode is active.
+	VisualBell
+)
+
+// How long the visual bell lasts.
+var visualBellDuration = 100 * time.Millisecond
+
+func (a *app) Bell() {
+	switch a.BellStyle() {
+	case AudibleBell:
+		a.bellMutex.Lock()
+		a.bellPending = true
+		a.bellMutex.Unlock()
+		a.Redraw()
+	case VisualBell:
+		a.bellMutex.Lock()
+		a.flashUntil = a.Clock.Now().Add(visualBellDuration)
+		a.bellMutex.Unlock()
+		a.Redraw()
+		timer := a.Clock.After(visualBellDuration)
+		go func() {
+			<-timer
+			a.Redraw()
+		}()
+	}
+}
+
+// Returns whether the terminal bell should be rung, and whether the visual
+// bell is active.
+func (a *app) extractBell() (ring, flash bool) {
+	a.bellMutex.Lock()
+	defer a.bellMutex.Unlock()
+	ring = a.bellPending
+	a.bellPending = false
+	return ring, a.Clock.Now().Before(a.flashUntil)
+}
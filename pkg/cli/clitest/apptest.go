@@ -6,7 +6,9 @@ import (
 
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/sys"
 	"src.elv.sh/pkg/ui"
+	"src.elv.sh/pkg/wcwidth"
 )
 
 // Styles defines a common stylesheet for unit tests.
@@ -92,6 +94,73 @@ func (f *Fixture) TestTTYNotes(t *testing.T, args ...any) {
 	f.TTY.TestNotesBuffer(t, f.MakeBuffer(args...))
 }
 
+// Resize changes the size of the fake terminal and injects a SIGWINCH, like
+// what happens when the user resizes the terminal. Buffers built with
+// MakeBuffer afterwards use the new width.
+func (f *Fixture) Resize(width, height int) {
+	f.TTY.SetSize(height, width)
+	f.width = width
+	f.TTY.InjectSignal(sys.SIGWINCH)
+}
+
+// TestTTYAt verifies that the terminal will show the cells built from args
+// with term.NewBufferBuilder(...).MarkLines(args...), starting from the given
+// line and column, within 100ms, and aborts the test if it doesn't. Each line
+// built from args is compared with the same number of cells starting from the
+// column; cells outside the region and the position of the dot are not
+// compared.
+//
+// Unlike TestTTY, the buffer currently shown is also checked, so that several
+// regions of the same buffer can be checked with consecutive calls.
+func (f *Fixture) TestTTYAt(t *testing.T, line, col int, args ...any) {
+	t.Helper()
+	want := term.NewBufferBuilder(f.width - col).MarkLines(args...).Buffer()
+	match := func(buf *term.Buffer) bool {
+		return regionMatches(buf, line, col, want.Lines)
+	}
+	if !match(f.TTY.LastBuffer()) && !waitBuffer(f.TTY.bufCh, match) {
+		t.Logf("wanted cells at line %d, column %d not shown:\n%s",
+			line, col, want.TTYString())
+		t.Logf("last buffer:\n%s", f.TTY.LastBuffer().TTYString())
+		t.FailNow()
+	}
+}
+
+// Returns whether the cells of buf starting from the given line and column
+// match the given lines.
+func regionMatches(buf *term.Buffer, line, col int, want [][]term.Cell) bool {
+	if buf == nil || line+len(want) > len(buf.Lines) {
+		return false
+	}
+	for i, wantLine := range want {
+		cells, ok := cellsFromColumn(buf.Lines[line+i], col)
+		if !ok || len(cells) < len(wantLine) {
+			return false
+		}
+		for j, cell := range wantLine {
+			if cells[j] != cell {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Returns the cells of a line starting from the given column, and whether the
+// column is at the start of a cell.
+func cellsFromColumn(cells []term.Cell, col int) ([]term.Cell, bool) {
+	w := 0
+	for i, cell := range cells {
+		if w == col {
+			return cells[i:], true
+		} else if w > col {
+			return nil, false
+		}
+		w += wcwidth.Of(cell.Text)
+	}
+	return nil, w == col
+}
+
 // StartReadCode starts the readCode function asynchronously, and returns two
 // channels that deliver its return values. The two channels are closed after
 // return values are delivered, so that subsequent reads will return zero values
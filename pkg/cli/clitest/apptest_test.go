@@ -41,3 +41,57 @@ func TestFixture(t *testing.T) {
 		t.Errorf("Wait returned %q, %v", code, err)
 	}
 }
+
+func TestFixture_Resize(t *testing.T) {
+	f := Setup(WithSpec(func(spec *cli.AppSpec) {
+		spec.CodeAreaState.Buffer = tk.CodeBuffer{Content: "1234567890", Dot: 10}
+	}))
+	defer f.Stop()
+	f.TestTTY(t, "1234567890", term.DotHere)
+
+	f.Resize(4, 24)
+	if h, w := f.TTY.Size(); h != 24 || w != 4 {
+		t.Errorf("got size (%d, %d), want (24, 4)", h, w)
+	}
+	if w := f.MakeBuffer().Width; w != 4 {
+		t.Errorf("got width %d from MakeBuffer, want 4", w)
+	}
+	f.TestTTY(t, "1234567890", term.DotHere)
+}
+
+func TestFixture_TestTTYAt(t *testing.T) {
+	f := Setup(WithSpec(func(spec *cli.AppSpec) {
+		spec.CodeAreaState.Buffer = tk.CodeBuffer{Content: "foo 好 bar\nlorem 好 ipsum", Dot: 0}
+	}))
+	defer f.Stop()
+
+	f.TestTTYAt(t, 0, 4, "好 b")
+	f.TestTTYAt(t, 1, 6, "好 ipsum")
+}
+
+var regionMatchesTests = []struct {
+	name      string
+	line, col int
+	want      string
+	matches   bool
+}{
+	{"start", 0, 0, "foo", true},
+	{"after wide character", 0, 7, "bar", true},
+	{"wide character", 0, 4, "好", true},
+	{"inside wide character", 0, 5, " bar", false},
+	{"mismatch", 0, 0, "fox", false},
+	{"past end of line", 0, 7, "barbaz", false},
+	{"past last line", 1, 0, "a", false},
+}
+
+func TestRegionMatches(t *testing.T) {
+	buf := term.NewBufferBuilder(20).Write("foo 好 bar").Buffer()
+	for _, test := range regionMatchesTests {
+		t.Run(test.name, func(t *testing.T) {
+			want := term.NewBufferBuilder(20).Write(test.want).Buffer().Lines
+			if got := regionMatches(buf, test.line, test.col, want); got != test.matches {
+				t.Errorf("got %v, want %v", got, test.matches)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package clitest
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/cli/clock"
+	"src.elv.sh/pkg/testutil"
+)
+
+// FakeClock is a clock.Clock whose time only changes when Advance is called.
+type FakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+var _ clock.Clock = (*FakeClock)(nil)
+
+// NewFakeClock returns a new FakeClock with an arbitrary fixed time.
+func NewFakeClock() *FakeClock {
+	return &FakeClock{now: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+// Now returns the current time of the fake clock.
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// After returns a channel that delivers the time once Advance has moved the
+// time forward by d. If d is not positive, it delivers the time immediately.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// Buffered, so that Advance never blocks.
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+	} else {
+		c.timers = append(c.timers, fakeTimer{c.now.Add(d), ch})
+	}
+	return ch
+}
+
+// Advance moves the time forward by d, firing the timers whose deadlines have
+// been reached in the order of their deadlines.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+	i := 0
+	for ; i < len(c.timers) && !c.timers[i].deadline.After(c.now); i++ {
+		c.timers[i].ch <- c.now
+	}
+	c.timers = c.timers[i:]
+}
+
+// Timers returns the number of timers that haven't fired yet.
+func (c *FakeClock) Timers() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.timers)
+}
+
+// WaitTimers waits until there are at least n timers that haven't fired yet,
+// and aborts the test if that doesn't happen within 1s. This is useful for
+// making sure that code running in another goroutine has started waiting
+// before calling Advance.
+func (c *FakeClock) WaitTimers(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(testutil.Scaled(time.Second))
+	for c.Timers() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d timers after 1s, want %d", c.Timers(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,63 @@
+package clitest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	c := NewFakeClock()
+	start := c.Now()
+
+	ch1 := c.After(2 * time.Second)
+	ch2 := c.After(time.Second)
+	if n := c.Timers(); n != 2 {
+		t.Errorf("got %d timers, want 2", n)
+	}
+
+	c.Advance(time.Second)
+	if got, want := c.Now(), start.Add(time.Second); !got.Equal(want) {
+		t.Errorf("got time %v, want %v", got, want)
+	}
+	select {
+	case <-ch1:
+		t.Errorf("timer of 2s fired after 1s")
+	default:
+	}
+	select {
+	case fired := <-ch2:
+		if !fired.Equal(start.Add(time.Second)) {
+			t.Errorf("timer fired with time %v, want %v", fired, start.Add(time.Second))
+		}
+	default:
+		t.Errorf("timer of 1s didn't fire after 1s")
+	}
+
+	c.Advance(time.Second)
+	select {
+	case <-ch1:
+	default:
+		t.Errorf("timer of 2s didn't fire after 2s")
+	}
+	if n := c.Timers(); n != 0 {
+		t.Errorf("got %d timers, want 0", n)
+	}
+}
+
+func TestFakeClock_AfterNonPositive(t *testing.T) {
+	c := NewFakeClock()
+	select {
+	case <-c.After(0):
+	default:
+		t.Errorf("timer of 0s didn't fire immediately")
+	}
+}
+
+func TestFakeClock_WaitTimers(t *testing.T) {
+	c := NewFakeClock()
+	go func() {
+		time.Sleep(time.Millisecond)
+		c.After(time.Second)
+	}()
+	c.WaitTimers(t, 1)
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -41,8 +42,25 @@ type fakeTTY struct {
 	// Argument that SetRawInput got.
 	raw int
 	// Number of times the TTY screen has been cleared, incremented in
-	// ClearScreen.
+	// ClearScreen and ClearScrollback.
 	cleared int
+	// Number of times the scrollback has been cleared, incremented in
+	// ClearScrollback.
+	scrollbackCleared int
+	// Number of times the bell has been rung, incremented in Bell.
+	bells int32
+	// Semantic marks written with WriteMark, guarded by bufMutex.
+	marks []Mark
+	// Titles set with SetTitle, guarded by bufMutex.
+	titles []string
+	// Whether mouse tracking is on, guarded by bufMutex.
+	mouseTracking bool
+	// Whether the alternate screen is used, guarded by bufMutex.
+	altScreen bool
+	// Texts copied with SetClipboard, guarded by bufMutex.
+	clipboard []string
+	// Number of times the cursor position has been requested.
+	cprRequests int32
 
 	sizeMutex sync.RWMutex
 	// Predefined sizes.
@@ -136,6 +154,51 @@ func (t *fakeTTY) ClearScreen() {
 	t.cleared++
 }
 
+func (t *fakeTTY) ClearScrollback() {
+	t.cleared++
+	t.scrollbackCleared++
+}
+
+func (t *fakeTTY) Bell() {
+	atomic.AddInt32(&t.bells, 1)
+}
+
+func (t *fakeTTY) WriteMark(mark term.SemanticMark, pos term.Pos) {
+	t.bufMutex.Lock()
+	defer t.bufMutex.Unlock()
+	t.marks = append(t.marks, Mark{mark, pos})
+}
+
+func (t *fakeTTY) SetTitle(title string) {
+	t.bufMutex.Lock()
+	defer t.bufMutex.Unlock()
+	t.titles = append(t.titles, title)
+}
+
+func (t *fakeTTY) SetMouseTracking(on bool) {
+	t.bufMutex.Lock()
+	defer t.bufMutex.Unlock()
+	t.mouseTracking = on
+}
+
+func (t *fakeTTY) SetAltScreen(on bool) {
+	t.bufMutex.Lock()
+	defer t.bufMutex.Unlock()
+	t.altScreen = on
+}
+
+func (t *fakeTTY) SetClipboard(text string) {
+	t.bufMutex.Lock()
+	defer t.bufMutex.Unlock()
+	t.clipboard = append(t.clipboard, text)
+}
+
+// Records the request. Use the Inject method of TTYCtrl to deliver a
+// term.CursorPosition event in response.
+func (t *fakeTTY) RequestCursorPosition() {
+	atomic.AddInt32(&t.cprRequests, 1)
+}
+
 func (t *fakeTTY) NotifySignals() <-chan os.Signal { return t.sigCh }
 
 func (t *fakeTTY) StopSignals() { close(t.sigCh) }
@@ -207,12 +270,70 @@ func (t TTYCtrl) RawInput() int {
 	return t.raw
 }
 
-// ScreenCleared returns the number of times ClearScreen has been called on the
-// TTY.
+// ScreenCleared returns the number of times ClearScreen or ClearScrollback has
+// been called on the TTY.
 func (t TTYCtrl) ScreenCleared() int {
 	return t.cleared
 }
 
+// ScrollbackCleared returns the number of times ClearScrollback has been called
+// on the TTY.
+func (t TTYCtrl) ScrollbackCleared() int {
+	return t.scrollbackCleared
+}
+
+// Bells returns the number of times the bell has been rung.
+func (t TTYCtrl) Bells() int {
+	return int(atomic.LoadInt32(&t.bells))
+}
+
+// Mark records a semantic mark written to a fake TTY.
+type Mark struct {
+	Mark term.SemanticMark
+	Pos  term.Pos
+}
+
+// Marks returns the semantic marks that have been written to the TTY.
+func (t TTYCtrl) Marks() []Mark {
+	t.bufMutex.RLock()
+	defer t.bufMutex.RUnlock()
+	return append([]Mark(nil), t.marks...)
+}
+
+// Titles returns the titles that have been set on the TTY.
+func (t TTYCtrl) Titles() []string {
+	t.bufMutex.RLock()
+	defer t.bufMutex.RUnlock()
+	return append([]string(nil), t.titles...)
+}
+
+// MouseTracking returns whether mouse tracking is on.
+func (t TTYCtrl) MouseTracking() bool {
+	t.bufMutex.RLock()
+	defer t.bufMutex.RUnlock()
+	return t.mouseTracking
+}
+
+// Clipboard returns the texts that have been copied with SetClipboard.
+func (t TTYCtrl) Clipboard() []string {
+	t.bufMutex.RLock()
+	defer t.bufMutex.RUnlock()
+	return append([]string(nil), t.clipboard...)
+}
+
+// AltScreen returns whether the alternate screen is used.
+func (t TTYCtrl) AltScreen() bool {
+	t.bufMutex.RLock()
+	defer t.bufMutex.RUnlock()
+	return t.altScreen
+}
+
+// CursorPositionRequests returns the number of times the cursor position has
+// been requested.
+func (t TTYCtrl) CursorPositionRequests() int {
+	return int(atomic.LoadInt32(&t.cprRequests))
+}
+
 // TestBuffer verifies that a buffer will appear within 100ms, and aborts the
 // test if it doesn't.
 func (t TTYCtrl) TestBuffer(tt *testing.T, b *term.Buffer) {
@@ -294,11 +415,18 @@ func (t TTYCtrl) LastNotesBuffer() *term.Buffer {
 
 // Tests that an buffer appears on the channel within 100ms.
 func testBuffer(want *term.Buffer, ch <-chan *term.Buffer) bool {
+	return waitBuffer(ch, func(buf *term.Buffer) bool {
+		return reflect.DeepEqual(buf, want)
+	})
+}
+
+// Waits for a buffer that satisfies match to appear on ch within 100ms.
+func waitBuffer(ch <-chan *term.Buffer, match func(*term.Buffer) bool) bool {
 	timeout := time.After(testutil.Scaled(100 * time.Millisecond))
 	for {
 		select {
 		case buf := <-ch:
-			if reflect.DeepEqual(buf, want) {
+			if match(buf) {
 				return true
 			}
 		case <-timeout:
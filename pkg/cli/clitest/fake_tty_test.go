@@ -129,6 +129,17 @@ func TestFakeTTY_ClearScreen(t *testing.T) {
 	}
 }
 
+func TestFakeTTY_ClearScrollback(t *testing.T) {
+	fakeTTY, ttyCtrl := NewFakeTTY()
+	fakeTTY.ClearScrollback()
+	if cleared := ttyCtrl.ScreenCleared(); cleared != 1 {
+		t.Errorf("ScreenCleared -> %v, want 1", cleared)
+	}
+	if cleared := ttyCtrl.ScrollbackCleared(); cleared != 1 {
+		t.Errorf("ScrollbackCleared -> %v, want 1", cleared)
+	}
+}
+
 func TestGetTTYCtrl_FakeTTY(t *testing.T) {
 	fakeTTY, ttyCtrl := NewFakeTTY()
 	if got, ok := GetTTYCtrl(fakeTTY); got != ttyCtrl || !ok {
@@ -0,0 +1,135 @@
+package clitest
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/testutil"
+	"src.elv.sh/pkg/wcwidth"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// TestTTYGolden verifies that a buffer matching the golden file
+// testdata/<name>.golden will appear within 100ms, and aborts the test if it
+// doesn't. The golden file contains the buffer in the format of FormatBuffer.
+//
+// When the test is run with the -update flag, it instead waits for the buffer
+// to stop changing for 100ms and writes it to the golden file.
+func (f *Fixture) TestTTYGolden(t *testing.T, name string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		buf := f.TTY.settledBuffer()
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err == nil {
+			err = os.WriteFile(path, []byte(FormatBuffer(buf)), 0o644)
+		}
+		if err != nil {
+			t.Fatalf("cannot update golden file: %v", err)
+		}
+		return
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read golden file: %v (run the test with -update to create it)", err)
+	}
+	// Git may convert the line endings on checkout.
+	want := strings.ReplaceAll(string(content), "\r\n", "\n")
+	ok := waitBuffer(f.TTY.bufCh, func(buf *term.Buffer) bool {
+		return FormatBuffer(buf) == want
+	})
+	if !ok {
+		t.Logf("wanted buffer not shown:\n%s", want)
+		t.Logf("last buffer:\n%s", FormatBuffer(f.TTY.LastBuffer()))
+		t.Log("run the test with -update to update the golden file")
+		t.FailNow()
+	}
+}
+
+// Waits for the buffers to stop changing, and returns the last one.
+func (t TTYCtrl) settledBuffer() *term.Buffer {
+	for {
+		select {
+		case <-t.bufCh:
+		case <-time.After(testutil.Scaled(100 * time.Millisecond)):
+			return t.LastBuffer()
+		}
+	}
+}
+
+// Characters used to mark styles in the output of FormatBuffer, in the order
+// they are assigned.
+const styleMarkers = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// FormatBuffer formats a buffer in a stable text format suitable for golden
+// files. For example, a buffer of width 20 with the text "~> echo" on the
+// first line, where "echo" is green, and the dot on the second line is
+// formatted as:
+//
+//	width: 20
+//	dot: 1, 0
+//	text |~> echo|
+//	style|   aaaa
+//	text ||
+//	styles:
+//	a: 32
+//
+// Each line of the buffer is followed by a line marking the style of each
+// column, if any column is styled. The markers are assigned to styles in the
+// order they first appear, and explained at the end; if the buffer uses more
+// styles than there are markers, the rest are marked with "?".
+func FormatBuffer(b *term.Buffer) string {
+	if b == nil {
+		return "nil\n"
+	}
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "width: %d\n", b.Width)
+	fmt.Fprintf(sb, "dot: %d, %d\n", b.Dot.Line, b.Dot.Col)
+	markers := make(map[string]byte)
+	var styles []string
+	for _, line := range b.Lines {
+		var text, style strings.Builder
+		styled := false
+		for _, cell := range line {
+			text.WriteString(cell.Text)
+			marker := byte(' ')
+			if cell.Style != "" {
+				styled = true
+				var ok bool
+				if marker, ok = markers[cell.Style]; !ok {
+					marker = '?'
+					if len(styles) < len(styleMarkers) {
+						marker = styleMarkers[len(styles)]
+					}
+					markers[cell.Style] = marker
+					styles = append(styles, cell.Style)
+				}
+			}
+			// Repeat the marker for wide characters, so that the markers line
+			// up with the text.
+			w := wcwidth.Of(cell.Text)
+			if w < 1 {
+				w = 1
+			}
+			style.WriteString(strings.Repeat(string(marker), w))
+		}
+		fmt.Fprintf(sb, "text |%s|\n", text.String())
+		if styled {
+			fmt.Fprintf(sb, "style|%s\n", strings.TrimRight(style.String(), " "))
+		}
+	}
+	if len(styles) > 0 {
+		sb.WriteString("styles:\n")
+		for _, s := range styles {
+			fmt.Fprintf(sb, "%c: %s\n", markers[s], s)
+		}
+	}
+	return sb.String()
+}
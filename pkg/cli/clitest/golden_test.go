@@ -0,0 +1,67 @@
+package clitest
+
+import (
+	"os"
+	"testing"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/testutil"
+	"src.elv.sh/pkg/ui"
+)
+
+func TestFormatBuffer(t *testing.T) {
+	buf := term.NewBufferBuilder(20).
+		Write("~> ").WriteStringSGR("echo", "32").Write(" ").
+		WriteStringSGR("你好", "1;31").Newline().
+		WriteStringSGR("x", "32").SetDotHere().Buffer()
+	want := "width: 20\n" +
+		"dot: 1, 1\n" +
+		"text |~> echo 你好|\n" +
+		"style|   aaaa bbbb\n" +
+		"text |x|\n" +
+		"style|a\n" +
+		"styles:\n" +
+		"a: 32\n" +
+		"b: 1;31\n"
+	if got := FormatBuffer(buf); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormatBuffer_Nil(t *testing.T) {
+	if got := FormatBuffer(nil); got != "nil\n" {
+		t.Errorf("got %q, want %q", got, "nil\n")
+	}
+}
+
+func setupGolden(t *testing.T) *Fixture {
+	f := Setup(WithSpec(func(spec *cli.AppSpec) {
+		spec.Prompt = cli.NewConstPrompt(ui.T("~> ", ui.FgGreen))
+		spec.CodeAreaState.Buffer = tk.CodeBuffer{Content: "echo", Dot: 4}
+	}))
+	t.Cleanup(f.Stop)
+	return f
+}
+
+func TestTTYGolden(t *testing.T) {
+	f := setupGolden(t)
+	f.TestTTYGolden(t, "prompt")
+}
+
+func TestTTYGolden_Update(t *testing.T) {
+	testutil.InTempDir(t)
+	testutil.Set(t, update, true)
+	f := setupGolden(t)
+
+	f.TestTTYGolden(t, "prompt")
+
+	content, err := os.ReadFile("testdata/prompt.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := FormatBuffer(f.TTY.LastBuffer()); string(content) != want {
+		t.Errorf("got golden file:\n%s\nwant:\n%s", content, want)
+	}
+}
@@ -0,0 +1,128 @@
+package clitest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/testutil"
+	"src.elv.sh/pkg/ui"
+)
+
+// Play injects the events described by a script into the TTY, and aborts the
+// test if the script is invalid.
+//
+// The script is a sequence of the following items, separated by whitespace:
+//
+//   - A double-quoted string, like "echo ". Each character in the string is
+//     injected as a key event. The string is unquoted like a Go string
+//     literal, so "\n" is the Enter key.
+//
+//   - A key in angle brackets, like <Tab>, <Down> or <Ctrl-A>, in the syntax
+//     accepted by ui.ParseKey.
+//
+//   - A delay in angle brackets, like <sleep 100ms>, which waits for the given
+//     duration (scaled by testutil.Scaled) before injecting the rest of the
+//     events.
+//
+// For example, the following types "echo ", presses Tab, waits for the
+// completion listing to appear and then accepts the second candidate:
+//
+//	f.Play(t, `"echo " <Tab> <sleep 50ms> <Down> <Enter>`)
+func (f *Fixture) Play(t *testing.T, script string) {
+	t.Helper()
+	steps, err := parseScript(script)
+	if err != nil {
+		t.Fatalf("invalid script: %v", err)
+	}
+	for _, step := range steps {
+		if step.event == nil {
+			time.Sleep(testutil.Scaled(step.delay))
+		} else {
+			f.TTY.Inject(step.event)
+		}
+	}
+}
+
+// A step in a script, either an event to inject or, if event is nil, a delay.
+type scriptStep struct {
+	event term.Event
+	delay time.Duration
+}
+
+func parseScript(script string) ([]scriptStep, error) {
+	var steps []scriptStep
+	rest := strings.TrimLeft(script, " \t\n")
+	for rest != "" {
+		var item string
+		switch rest[0] {
+		case '"':
+			end := stringLiteralEnd(rest)
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated string: %s", rest)
+			}
+			item, rest = rest[:end], rest[end:]
+			s, err := strconv.Unquote(item)
+			if err != nil {
+				return nil, fmt.Errorf("bad string %s: %w", item, err)
+			}
+			for _, r := range s {
+				steps = append(steps, scriptStep{event: term.K(r)})
+			}
+		case '<':
+			end := strings.IndexByte(rest, '>')
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated key: %s", rest)
+			}
+			// Allow <>> for the > key.
+			if end == 1 && strings.HasPrefix(rest, "<>>") {
+				end = 2
+			}
+			item, rest = rest[:end+1], rest[end+1:]
+			step, err := parseBracketed(item[1 : len(item)-1])
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+		default:
+			return nil, fmt.Errorf("unexpected text: %s", rest)
+		}
+		if rest != "" && !strings.ContainsAny(rest[:1], " \t\n") {
+			return nil, fmt.Errorf("missing space after %s", item)
+		}
+		rest = strings.TrimLeft(rest, " \t\n")
+	}
+	return steps, nil
+}
+
+// Returns the index just after the closing quote of the double-quoted string
+// at the start of s, or -1 if it is not terminated.
+func stringLiteralEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func parseBracketed(s string) (scriptStep, error) {
+	if strings.HasPrefix(s, "sleep ") {
+		delay, err := time.ParseDuration(strings.TrimSpace(s[len("sleep "):]))
+		if err != nil {
+			return scriptStep{}, fmt.Errorf("bad delay <%s>: %w", s, err)
+		}
+		return scriptStep{delay: delay}, nil
+	}
+	k, err := ui.ParseKey(s)
+	if err != nil {
+		return scriptStep{}, fmt.Errorf("bad key <%s>: %w", s, err)
+	}
+	return scriptStep{event: term.KeyEvent(k)}, nil
+}
@@ -0,0 +1,60 @@
+package clitest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/ui"
+)
+
+var parseScriptTests = []struct {
+	script  string
+	want    []scriptStep
+	wantErr string
+}{
+	{script: "", want: nil},
+	{
+		script: `"ab" <Tab>`,
+		want: []scriptStep{
+			{event: term.K('a')}, {event: term.K('b')}, {event: term.K(ui.Tab)}},
+	},
+	{
+		script: "  \"a\\\"\\n\"\n<Ctrl-A>\t<sleep 10ms> <>> ",
+		want: []scriptStep{
+			{event: term.K('a')}, {event: term.K('"')}, {event: term.K('\n')},
+			{event: term.K('A', ui.Ctrl)}, {delay: 10 * time.Millisecond},
+			{event: term.K('>')}},
+	},
+	{script: `"a`, wantErr: `unterminated string: "a`},
+	{script: `<Tab`, wantErr: `unterminated key: <Tab`},
+	{script: `<Foo-X>`, wantErr: `bad key <Foo-X>: bad modifier: Foo`},
+	{script: `<sleep x>`, wantErr: `bad delay <sleep x>: time: invalid duration "x"`},
+	{script: `a`, wantErr: `unexpected text: a`},
+	{script: `"a"<Tab>`, wantErr: `missing space after "a"`},
+}
+
+func TestParseScript(t *testing.T) {
+	for _, tc := range parseScriptTests {
+		steps, err := parseScript(tc.script)
+		if tc.wantErr != "" {
+			if err == nil || err.Error() != tc.wantErr {
+				t.Errorf("parseScript(%q) returns error %v, want %q", tc.script, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(steps, tc.want) {
+			t.Errorf("parseScript(%q) returns (%v, %v), want (%v, nil)",
+				tc.script, steps, err, tc.want)
+		}
+	}
+}
+
+func TestPlay(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	f.Play(t, `"echo" <sleep 1ms> " x"`)
+	f.TestTTY(t, "echo x", term.DotHere)
+}
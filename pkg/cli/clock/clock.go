@@ -0,0 +1,22 @@
+// Package clock provides an abstraction of the passing of time, so that code
+// that depends on it can be tested deterministically.
+package clock
+
+import "time"
+
+// Clock is a source of the current time and timers.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After returns a channel that delivers the current time once the
+	// duration has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// Real is the Clock backed by the time package.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
@@ -0,0 +1,84 @@
+// Command remote demonstrates running the frontend of the line editor in a
+// different process.
+//
+// Run "remote -listen sock" in one terminal and "remote -connect sock" in
+// another. The line editor shows up in the second terminal, and the code read
+// is printed in the first.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/remote"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/ui"
+)
+
+var (
+	listen  = flag.String("listen", "", "read code using a frontend connecting to this socket")
+	connect = flag.String("connect", "", "run the frontend, connecting to this socket")
+)
+
+func main() {
+	flag.Parse()
+	var err error
+	switch {
+	case *listen != "":
+		err = runApp(*listen)
+	case *connect != "":
+		err = runFrontend(*connect)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func runApp(sock string) error {
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	conn, err := l.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var app cli.App
+	app = cli.NewApp(cli.AppSpec{
+		TTY:    remote.NewTTY(conn),
+		Prompt: cli.NewConstPrompt(ui.T("remote> ")),
+		CodeAreaBindings: tk.MapBindings{
+			term.K('D', ui.Ctrl): func(tk.Widget) { app.CommitEOF() },
+		},
+	})
+	for {
+		code, err := app.ReadCode()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		fmt.Printf("got %q\n", code)
+	}
+}
+
+func runFrontend(sock string) error {
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return remote.Serve(conn, cli.NewTTY(os.Stdin, os.Stderr))
+}
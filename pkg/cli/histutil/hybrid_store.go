@@ -78,3 +78,15 @@ func (c *hybridStoreCursor) Get() (storedefs.Cmd, error) {
 	}
 	return c.session.Get()
 }
+
+// NewHybridStoreWith returns a store that provides a view of the commands in
+// shared, followed by the given session commands. If shared is nil, the store
+// only has the session commands. The store never modifies the elements of
+// session; commands added to it are appended to a copy.
+func NewHybridStoreWith(shared Store, session []storedefs.Cmd) Store {
+	sessionStore := &memStore{session[:len(session):len(session)]}
+	if shared == nil {
+		return sessionStore
+	}
+	return hybridStore{shared, sessionStore}
+}
@@ -151,6 +151,27 @@ func TestHybridStore_Cursor_SharedAndSession(t *testing.T) {
 		{Text: "+ session 1", Seq: 4}})
 }
 
+func TestNewHybridStoreWith(t *testing.T) {
+	db := NewFaultyInMemoryDB("+ shared 1")
+	shared, _ := NewDBStore(db)
+	session := make([]storedefs.Cmd, 1, 2)
+	session[0] = storedefs.Cmd{Text: "+ session 1", Seq: 1}
+
+	f := NewHybridStoreWith(shared, session)
+	testCursorIteration(t, f.Cursor("+"), []storedefs.Cmd{
+		{Text: "+ shared 1", Seq: 0},
+		{Text: "+ session 1", Seq: 1}})
+
+	// Adding commands doesn't touch the underlying array of session.
+	f.AddCmd(storedefs.Cmd{Text: "+ session 2"})
+	if extra := session[:2][1]; extra != (storedefs.Cmd{}) {
+		t.Errorf("AddCmd modified session: %v", extra)
+	}
+
+	sessionOnly := NewHybridStoreWith(nil, session)
+	testCursorIteration(t, sessionOnly.Cursor("+"), session)
+}
+
 func testCursorIteration(t *testing.T, cursor Cursor, wantCmds []storedefs.Cmd) {
 	expectEndOfHistory := func() {
 		t.Helper()
@@ -1,11 +1,26 @@
 package cli
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 // Buffer size of the input channel. The value is chosen for no particular
 // reason.
 const inputChSize = 128
 
+const (
+	// How long to wait for another input event before redrawing. Input events
+	// are usually supplied one at a time, with the next event only read after
+	// the current one is handled, so waiting briefly allows pending input (like
+	// pasted text, or key repeat arriving while a slow redraw was running) to
+	// be handled in one batch.
+	defaultBatchWait = time.Millisecond
+	// Maximum time to spend handling a batch of input events before redrawing,
+	// so that the UI still gets updated while input keeps arriving.
+	defaultMaxBatchTime = 30 * time.Millisecond
+)
+
 // A generic main loop manager.
 type loop struct {
 	inputCh  chan event
@@ -18,6 +33,9 @@ type loop struct {
 	redrawMutex *sync.Mutex
 
 	returnCh chan loopReturn
+
+	batchWait    time.Duration
+	maxBatchTime time.Duration
 }
 
 type loopReturn struct {
@@ -62,6 +80,9 @@ func newLoop() *loop {
 		redrawMutex: new(sync.Mutex),
 
 		returnCh: make(chan loopReturn, 1),
+
+		batchWait:    defaultBatchWait,
+		maxBatchTime: defaultMaxBatchTime,
 	}
 }
 
@@ -123,8 +144,9 @@ func (lp *loop) Run() (buffer string, err error) {
 		lp.redrawCb(flag)
 		select {
 		case event := <-lp.inputCh:
-			// Consume all events in the channel to minimize redraws.
-		consumeAllEvents:
+			// Consume all pending events to minimize redraws, up to the
+			// maximum batch time.
+			deadline := time.Now().Add(lp.maxBatchTime)
 			for {
 				lp.handleCb(event)
 				select {
@@ -133,11 +155,10 @@ func (lp *loop) Run() (buffer string, err error) {
 					return ret.buffer, ret.err
 				default:
 				}
-				select {
-				case event = <-lp.inputCh:
-					// Continue the loop of consuming all events.
-				default:
-					break consumeAllEvents
+				var more bool
+				event, more = lp.nextEventInBatch(deadline)
+				if !more {
+					break
 				}
 			}
 		case ret := <-lp.returnCh:
@@ -148,6 +169,31 @@ func (lp *loop) Run() (buffer string, err error) {
 	}
 }
 
+// Returns the next input event if one arrives within the batch wait time and
+// before the deadline.
+func (lp *loop) nextEventInBatch(deadline time.Time) (event, bool) {
+	wait := time.Until(deadline)
+	if wait <= 0 {
+		return nil, false
+	}
+	select {
+	case event := <-lp.inputCh:
+		return event, true
+	default:
+	}
+	if wait > lp.batchWait {
+		wait = lp.batchWait
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case event := <-lp.inputCh:
+		return event, true
+	case <-timer.C:
+		return nil, false
+	}
+}
+
 func (lp *loop) extractRedrawFull() bool {
 	lp.redrawMutex.Lock()
 	defer lp.redrawMutex.Unlock()
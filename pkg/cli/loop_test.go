@@ -5,6 +5,7 @@ import (
 	"io"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestRead_PassesInputEventsToHandler(t *testing.T) {
@@ -110,6 +111,55 @@ func testReadCallsDrawWhenRedrawRequestedAfterFirstDraw(t *testing.T, full bool,
 	}
 }
 
+func TestLoop_BatchesEventsSuppliedAfterHandling(t *testing.T) {
+	// Simulate the app, which only reads the next event after the current one
+	// has been handled.
+	const n = 100
+	lp := newLoop()
+	lp.batchWait = 100 * time.Millisecond
+	lp.maxBatchTime = time.Hour
+	handled := 0
+	lp.HandleCb(func(event) {
+		handled++
+		if handled == n {
+			lp.Return("", nil)
+		} else {
+			go lp.Input(handled)
+		}
+	})
+	redraws := 0
+	lp.RedrawCb(func(flag redrawFlag) {
+		if flag&finalRedraw == 0 {
+			redraws++
+		}
+	})
+	supplyInputs(lp, 0)
+	lp.Run()
+	if redraws != 1 {
+		t.Errorf("got %d non-final redraws, want 1", redraws)
+	}
+}
+
+func TestLoop_RedrawsWhenBatchTakesTooLong(t *testing.T) {
+	lp := newLoop()
+	lp.batchWait = time.Hour
+	lp.maxBatchTime = time.Millisecond
+	lp.HandleCb(func(event) {
+		time.Sleep(time.Millisecond)
+		go lp.Input("x")
+	})
+	redraws := 0
+	lp.RedrawCb(func(flag redrawFlag) {
+		redraws++
+		if redraws == 3 {
+			lp.Return("", nil)
+		}
+	})
+	supplyInputs(lp, "x")
+	// Returns only if redraws happen while input keeps arriving.
+	lp.Run()
+}
+
 // Helpers.
 
 func supplyInputs(lp *loop, events ...event) {
@@ -3,6 +3,7 @@ package modes
 import (
 	"errors"
 	"strings"
+	"sync/atomic"
 
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/tk"
@@ -14,6 +15,10 @@ import (
 // candidates. It is based on the ComboBox widget.
 type Completion interface {
 	tk.ComboBox
+	// SetItems replaces the completion items, for example when more
+	// candidates have been generated. The filter is reapplied, and the
+	// selected item stays selected if it is still shown.
+	SetItems(items []CompletionItem)
 }
 
 // CompletionSpec specifies the configuration for the completion mode.
@@ -36,6 +41,8 @@ type CompletionItem struct {
 type completion struct {
 	tk.ComboBox
 	attached tk.CodeArea
+	filter   FilterSpec
+	items    *atomic.Value // Always holds a []CompletionItem
 }
 
 var errNoCandidates = errors.New("no candidates")
@@ -49,6 +56,8 @@ func NewCompletion(app cli.App, cfg CompletionSpec) (Completion, error) {
 	if len(cfg.Items) == 0 {
 		return nil, errNoCandidates
 	}
+	items := new(atomic.Value)
+	items.Store(cfg.Items)
 	w := tk.NewComboBox(tk.ComboBoxSpec{
 		CodeArea: tk.CodeAreaSpec{
 			Prompt:      modePrompt(" COMPLETING "+cfg.Name+" ", true),
@@ -71,13 +80,33 @@ func NewCompletion(app cli.App, cfg CompletionSpec) (Completion, error) {
 			ExtendStyle: true,
 		},
 		OnFilter: func(w tk.ComboBox, p string) {
-			w.ListBox().Reset(filterCompletionItems(cfg.Items, cfg.Filter.makePredicate(p)), 0)
+			all := items.Load().([]CompletionItem)
+			w.ListBox().Reset(filterCompletionItems(all, cfg.Filter.makePredicate(p)), 0)
 		},
 	})
-	return completion{w, codeArea}, nil
+	return &completion{w, codeArea, cfg.Filter, items}, nil
 }
 
-func (w completion) Dismiss() {
+func (w *completion) SetItems(items []CompletionItem) {
+	w.items.Store(items)
+	state := w.ListBox().CopyState()
+	var selected string
+	if 0 <= state.Selected && state.Selected < state.Items.Len() {
+		selected = state.Items.(completionItems)[state.Selected].ToInsert
+	}
+	p := w.filter.makePredicate(w.CodeArea().CopyState().Buffer.Content)
+	filtered := filterCompletionItems(items, p)
+	i := 0
+	for j, item := range filtered {
+		if item.ToInsert == selected {
+			i = j
+			break
+		}
+	}
+	w.ListBox().Reset(filtered, i)
+}
+
+func (w *completion) Dismiss() {
 	w.attached.MutateState(func(s *tk.CodeAreaState) { s.Pending = tk.PendingCode{} })
 }
 
@@ -6,6 +6,7 @@ import (
 	"src.elv.sh/pkg/cli"
 	. "src.elv.sh/pkg/cli/clitest"
 	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
 	"src.elv.sh/pkg/diag"
 	"src.elv.sh/pkg/ui"
 )
@@ -42,6 +43,52 @@ func TestCompletion_Dismiss(t *testing.T) {
 	f.TestTTY(t /* nothing */)
 }
 
+func TestCompletion_SetItems(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+	w, _ := NewCompletion(f.App, CompletionSpec{Items: []CompletionItem{
+		{ToShow: ui.T("foo"), ToInsert: "foo"},
+		{ToShow: ui.T("foobar"), ToInsert: "foobar"},
+	}})
+	f.App.PushAddon(w)
+	f.TTY.Inject(term.K('f'))
+	f.TestTTY(t,
+		"foo\n", Styles,
+		"___",
+		" COMPLETING   f", Styles,
+		"*************  ", term.DotHere, "\n",
+		"foo  foobar", Styles,
+		"+++",
+	)
+	w.ListBox().Select(tk.Next)
+	f.App.Redraw()
+	f.TestTTY(t,
+		"foobar\n", Styles,
+		"______",
+		" COMPLETING   f", Styles,
+		"*************  ", term.DotHere, "\n",
+		"foo  foobar", Styles,
+		"     ++++++",
+	)
+
+	// The filter is applied to the new items, and the selected item is kept.
+	w.SetItems([]CompletionItem{
+		{ToShow: ui.T("bar"), ToInsert: "bar"},
+		{ToShow: ui.T("foo"), ToInsert: "foo"},
+		{ToShow: ui.T("foo2"), ToInsert: "foo2"},
+		{ToShow: ui.T("foobar"), ToInsert: "foobar"},
+	})
+	f.App.Redraw()
+	f.TestTTY(t,
+		"foobar\n", Styles,
+		"______",
+		" COMPLETING   f", Styles,
+		"*************  ", term.DotHere, "\n",
+		"foo  foo2  foobar", Styles,
+		"           ++++++",
+	)
+}
+
 func TestNewCompletion_NoItems(t *testing.T) {
 	f := Setup()
 	defer f.Stop()
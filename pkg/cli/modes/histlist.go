@@ -28,6 +28,9 @@ type HistlistSpec struct {
 	Filter FilterSpec
 	// RPrompt of the code area (first row of the widget).
 	CodeAreaRPrompt func() ui.Text
+	// AltScreen is called to determine whether the mode should be shown in
+	// the alternate screen. Defaults to false if unset.
+	AltScreen func() bool
 }
 
 // NewHistlist creates a new histlist mode.
@@ -42,6 +45,9 @@ func NewHistlist(app cli.App, spec HistlistSpec) (Histlist, error) {
 	if spec.Dedup == nil {
 		spec.Dedup = func() bool { return true }
 	}
+	if spec.AltScreen == nil {
+		spec.AltScreen = func() bool { return false }
+	}
 
 	cmds, err := spec.AllCmds()
 	if err != nil {
@@ -85,7 +91,7 @@ func NewHistlist(app cli.App, spec HistlistSpec) (Histlist, error) {
 			w.ListBox().Reset(it, it.Len()-1)
 		},
 	})
-	return w, nil
+	return &altScreenComboBox{w, spec.AltScreen}, nil
 }
 
 type histlistItems struct {
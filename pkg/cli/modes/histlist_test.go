@@ -120,6 +120,22 @@ func TestHistlist_Dedup(t *testing.T) {
 		"++++++++++++++++++++++++++++++++++++++++++++++++++")
 }
 
+func TestHistlist_AltScreen(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	st := histutil.NewMemStore("foo")
+	w, _ := NewHistlist(f.App, HistlistSpec{AllCmds: st.AllCmds})
+	if wantsAltScreen(w) {
+		t.Errorf("histlist wants alternate screen by default")
+	}
+	w, _ = NewHistlist(f.App,
+		HistlistSpec{AllCmds: st.AllCmds, AltScreen: func() bool { return true }})
+	if !wantsAltScreen(w) {
+		t.Errorf("histlist doesn't want alternate screen when AltScreen returns true")
+	}
+}
+
 func TestHistlist_CustomFilter(t *testing.T) {
 	f := Setup()
 	defer f.Stop()
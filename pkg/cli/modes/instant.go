@@ -6,7 +6,6 @@ import (
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/term"
 	"src.elv.sh/pkg/cli/tk"
-	"src.elv.sh/pkg/ui"
 )
 
 // Instant is a mode that executes code whenever it changes and shows the
@@ -45,7 +44,7 @@ func (w *instant) render(width, height int) *term.Buffer {
 	bb := term.NewBufferBuilder(width).
 		WriteStyled(modeLine(" INSTANT ", false)).SetDotHere()
 	if w.lastErr != nil {
-		bb.Newline().Write(w.lastErr.Error(), ui.FgRed)
+		bb.Newline().Write(w.lastErr.Error(), stylingForError)
 	}
 	buf := bb.Buffer()
 	if len(buf.Lines) < height {
@@ -37,6 +37,9 @@ type LocationSpec struct {
 	IterateWorkspaces LocationWSIterator
 	// Configuration for the filter.
 	Filter FilterSpec
+	// AltScreen is called to determine whether the mode should be shown in
+	// the alternate screen. Defaults to false if unset.
+	AltScreen func() bool
 }
 
 // LocationStore defines the interface for interacting with the directory history.
@@ -56,6 +59,9 @@ func NewLocation(app cli.App, cfg LocationSpec) (Location, error) {
 	if cfg.Store == nil {
 		return nil, errNoDirectoryHistoryStore
 	}
+	if cfg.AltScreen == nil {
+		cfg.AltScreen = func() bool { return false }
+	}
 
 	dirs := []storedefs.Dir{}
 	blacklist := map[string]struct{}{}
@@ -114,7 +120,7 @@ func NewLocation(app cli.App, cfg LocationSpec) (Location, error) {
 			w.ListBox().Reset(l.filter(cfg.Filter.makePredicate(p)), 0)
 		},
 	})
-	return w, nil
+	return &altScreenComboBox{w, cfg.AltScreen}, nil
 }
 
 func hasPathPrefix(path, prefix string) bool {
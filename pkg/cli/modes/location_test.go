@@ -120,6 +120,21 @@ func TestLocation_FullWorkflow(t *testing.T) {
 	}
 }
 
+func TestLocation_AltScreen(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	w, _ := NewLocation(f.App, LocationSpec{Store: locationStore{}})
+	if wantsAltScreen(w) {
+		t.Errorf("location wants alternate screen by default")
+	}
+	w, _ = NewLocation(f.App,
+		LocationSpec{Store: locationStore{}, AltScreen: func() bool { return true }})
+	if !wantsAltScreen(w) {
+		t.Errorf("location doesn't want alternate screen when AltScreen returns true")
+	}
+}
+
 func TestLocation_Hidden(t *testing.T) {
 	f := Setup()
 	defer f.Stop()
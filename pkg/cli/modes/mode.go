@@ -23,9 +23,23 @@ func FocusedCodeArea(a cli.App) (tk.CodeArea, error) {
 	return nil, ErrFocusedWidgetNotCodeArea
 }
 
+// A mode based on the ComboBox widget that can be shown in the alternate
+// screen.
+type altScreenComboBox struct {
+	tk.ComboBox
+	altScreen func() bool
+}
+
+func (w *altScreenComboBox) AltScreen() bool { return w.altScreen() }
+
+var (
+	stylingForModeLine = ui.DefineRole("mode-line", ui.Stylings(ui.Bold, ui.FgWhite, ui.BgMagenta))
+	stylingForError    = ui.DefineRole("error", ui.FgRed)
+)
+
 // Returns text styled as a modeline.
 func modeLine(content string, space bool) ui.Text {
-	t := ui.T(content, ui.Bold, ui.FgWhite, ui.BgMagenta)
+	t := ui.T(content, stylingForModeLine)
 	if space {
 		t = ui.Concat(t, ui.T(" "))
 	}
@@ -43,5 +57,5 @@ var Prompt = modePrompt
 
 // ErrorText returns a red "error:" followed by unstyled space and err.Error().
 func ErrorText(err error) ui.Text {
-	return ui.Concat(ui.T("error:", ui.FgRed), ui.T(" "), ui.T(err.Error()))
+	return ui.Concat(ui.T("error:", stylingForError), ui.T(" "), ui.T(err.Error()))
 }
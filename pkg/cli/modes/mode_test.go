@@ -13,6 +13,12 @@ import (
 
 var Args = tt.Args
 
+// Returns whether a mode wants to be shown in the alternate screen.
+func wantsAltScreen(w tk.Widget) bool {
+	a, ok := w.(interface{ AltScreen() bool })
+	return ok && a.AltScreen()
+}
+
 func TestModeLine(t *testing.T) {
 	testModeLine(t, tt.Fn("Line", modeLine))
 }
@@ -47,6 +47,9 @@ type NavigationSpec struct {
 	Filter FilterSpec
 	// RPrompt of the code area (first row of the widget).
 	CodeAreaRPrompt func() ui.Text
+	// AltScreen is called to determine whether the mode should be shown in
+	// the alternate screen. Defaults to false if unset.
+	AltScreen func() bool
 }
 
 type navigationState struct {
@@ -110,6 +113,10 @@ func (w *navigation) Focus() bool {
 	return w.CopyState().Filtering
 }
 
+func (w *navigation) AltScreen() bool {
+	return w.NavigationSpec.AltScreen()
+}
+
 func (w *navigation) ascend() {
 	// Remember the name of the current directory before ascending.
 	currentName := ""
@@ -167,6 +174,9 @@ func NewNavigation(app cli.App, spec NavigationSpec) (Navigation, error) {
 	if spec.WidthRatio == nil {
 		spec.WidthRatio = func() [3]int { return [3]int{1, 3, 4} }
 	}
+	if spec.AltScreen == nil {
+		spec.AltScreen = func() bool { return false }
+	}
 
 	var w *navigation
 	w = &navigation{
@@ -327,7 +337,7 @@ func makeColInner(f NavigationFile, filter func(string) bool, showHidden bool, o
 }
 
 func makeErrCol(err error) tk.Widget {
-	return tk.Label{Content: ui.T(err.Error(), ui.FgRed)}
+	return tk.Label{Content: ui.T(err.Error(), stylingForError)}
 }
 
 type fileItems []NavigationFile
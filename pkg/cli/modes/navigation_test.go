@@ -140,6 +140,21 @@ func TestWidthRatio(t *testing.T) {
 	)
 }
 
+func TestNavigation_AltScreen(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	w, _ := NewNavigation(f.App, NavigationSpec{Cursor: getTestCursor()})
+	if wantsAltScreen(w) {
+		t.Errorf("navigation wants alternate screen by default")
+	}
+	w, _ = NewNavigation(f.App, NavigationSpec{
+		Cursor: getTestCursor(), AltScreen: func() bool { return true }})
+	if !wantsAltScreen(w) {
+		t.Errorf("navigation doesn't want alternate screen when AltScreen returns true")
+	}
+}
+
 func TestNavigation_SelectedName(t *testing.T) {
 	f := Setup()
 	defer f.Stop()
@@ -0,0 +1,63 @@
+package modes
+
+import (
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+)
+
+// Selection is a mode for selecting text in the code area. The text between
+// the position of the dot when the mode starts and the dot is selected. Like
+// the stub mode, it shows a modeline and keeps the focus on the code area, so
+// the selection is changed by moving the dot.
+type Selection interface {
+	tk.Widget
+	// SelectedText returns the selected text.
+	SelectedText() string
+}
+
+// SelectionSpec specifies the configuration for the selection mode.
+type SelectionSpec struct {
+	// Key bindings.
+	Bindings tk.Bindings
+	// Name to show in the modeline. Default is " SELECTION ".
+	Name string
+}
+
+type selection struct {
+	stub
+	codeArea tk.CodeArea
+}
+
+// NewSelection creates a new selection mode.
+func NewSelection(app cli.App, spec SelectionSpec) (Selection, error) {
+	codeArea, err := FocusedCodeArea(app)
+	if err != nil {
+		return nil, err
+	}
+	if spec.Bindings == nil {
+		spec.Bindings = tk.DummyBindings{}
+	}
+	if spec.Name == "" {
+		spec.Name = " SELECTION "
+	}
+	codeArea.MutateState(func(s *tk.CodeAreaState) {
+		s.Selection = tk.Selection{Active: true, Anchor: s.Buffer.Dot}
+	})
+	return &selection{stub{StubSpec{spec.Bindings, spec.Name}}, codeArea}, nil
+}
+
+func (w *selection) Handle(event term.Event) bool {
+	return w.Bindings.Handle(w, event)
+}
+
+func (w *selection) SelectedText() string {
+	s := w.codeArea.CopyState()
+	from, to := s.SelectedRange()
+	return s.Buffer.Content[from:to]
+}
+
+// Dismiss removes the selection when the mode is closed.
+func (w *selection) Dismiss() {
+	w.codeArea.MutateState(func(s *tk.CodeAreaState) { s.Selection = tk.Selection{} })
+}
@@ -0,0 +1,62 @@
+package modes
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/cli"
+	. "src.elv.sh/pkg/cli/clitest"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/ui"
+)
+
+func TestSelection(t *testing.T) {
+	f := Setup(WithSpec(func(spec *cli.AppSpec) {
+		spec.CodeAreaState.Buffer = tk.CodeBuffer{Content: "echo foo", Dot: 8}
+	}))
+	defer f.Stop()
+
+	moveDotLeft := func(tk.Widget) {
+		codeArea, _ := FocusedCodeArea(f.App)
+		codeArea.MutateState(func(s *tk.CodeAreaState) { s.Buffer.Dot-- })
+	}
+	var selected string
+	w, err := NewSelection(f.App, SelectionSpec{Bindings: tk.MapBindings{
+		term.K(ui.Left): moveDotLeft,
+		term.K('y'): func(w tk.Widget) {
+			selected = w.(Selection).SelectedText()
+			f.App.PopAddon()
+		},
+	}})
+	if err != nil {
+		t.Fatalf("NewSelection -> error %v", err)
+	}
+	f.App.PushAddon(w)
+	f.App.Redraw()
+	f.TestTTY(t,
+		"echo foo", term.DotHere, "\n",
+		" SELECTION ", Styles,
+		"***********",
+	)
+
+	f.TTY.Inject(term.K(ui.Left), term.K(ui.Left), term.K(ui.Left))
+	f.TestTTY(t,
+		"echo ", term.DotHere, "foo\n", Styles,
+		"+++",
+		" SELECTION ", Styles,
+		"***********",
+	)
+
+	f.TTY.Inject(term.K('y'))
+	f.TestTTY(t, "echo ", term.DotHere, "foo")
+	if selected != "foo" {
+		t.Errorf("got selected text %q, want %q", selected, "foo")
+	}
+}
+
+func TestSelection_FocusedWidgetNotCodeArea(t *testing.T) {
+	testFocusedWidgetNotCodeArea(t, func(app cli.App) error {
+		_, err := NewSelection(app, SelectionSpec{})
+		return err
+	})
+}
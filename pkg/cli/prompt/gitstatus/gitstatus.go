@@ -0,0 +1,295 @@
+// Package gitstatus provides the status of Git repositories for use in
+// prompts.
+//
+// The status is obtained by running "git status --porcelain=v2 --branch",
+// which can be slow in large repositories. A Provider runs it at most once at a
+// time and at most once per debounce interval for each repository, and caches
+// the results per repository root. Its Segment method returns a
+// prompt.Segment, so that the status can be combined with other parts of the
+// prompt and shown stale while it is being computed.
+package gitstatus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"src.elv.sh/pkg/cli/prompt"
+	"src.elv.sh/pkg/ui"
+)
+
+// Status is the status of a Git repository.
+type Status struct {
+	// Name of the current branch, or empty if HEAD is detached.
+	Branch string
+	// Commit ID of HEAD, or "(initial)" if there are no commits yet.
+	Oid string
+	// Name of the upstream branch, or empty if there is none.
+	Upstream string
+	// Number of commits ahead of and behind the upstream branch.
+	Ahead, Behind int
+	// Number of files with changes in the index and in the work tree.
+	Staged, Unstaged int
+	// Number of untracked files.
+	Untracked int
+	// Number of files with merge conflicts.
+	Conflicted int
+}
+
+// Dirty returns whether there are any changes that haven't been committed,
+// including untracked files.
+func (s Status) Dirty() bool {
+	return s.Staged+s.Unstaged+s.Untracked+s.Conflicted > 0
+}
+
+// ErrNotRepo is returned by Provider.Status when the directory is not in a Git
+// repository.
+var ErrNotRepo = errors.New("not in a Git repository")
+
+// Parse parses the output of "git status --porcelain=v2 --branch".
+func Parse(output []byte) (Status, error) {
+	var s Status
+	for _, line := range strings.Split(string(output), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "#":
+			if len(fields) < 3 {
+				continue
+			}
+			switch fields[1] {
+			case "branch.oid":
+				s.Oid = fields[2]
+			case "branch.head":
+				if fields[2] != "(detached)" {
+					s.Branch = fields[2]
+				}
+			case "branch.upstream":
+				s.Upstream = fields[2]
+			case "branch.ab":
+				if len(fields) < 4 {
+					return Status{}, fmt.Errorf("bad branch.ab line: %q", line)
+				}
+				ahead, err1 := strconv.Atoi(strings.TrimPrefix(fields[2], "+"))
+				behind, err2 := strconv.Atoi(strings.TrimPrefix(fields[3], "-"))
+				if err1 != nil || err2 != nil {
+					return Status{}, fmt.Errorf("bad branch.ab line: %q", line)
+				}
+				s.Ahead, s.Behind = ahead, behind
+			}
+		case "1", "2":
+			if len(fields) < 2 || len(fields[1]) != 2 {
+				return Status{}, fmt.Errorf("bad change line: %q", line)
+			}
+			if fields[1][0] != '.' {
+				s.Staged++
+			}
+			if fields[1][1] != '.' {
+				s.Unstaged++
+			}
+		case "u":
+			s.Conflicted++
+		case "?":
+			s.Untracked++
+		case "!":
+			// Ignored files are only listed with --ignored; don't count them.
+		default:
+			return Status{}, fmt.Errorf("bad line: %q", line)
+		}
+	}
+	return s, nil
+}
+
+// FindRoot returns the root of the Git repository that contains dir, which is
+// the nearest ancestor of dir (including dir itself) that contains a .git
+// directory or file.
+func FindRoot(dir string) (string, bool) {
+	dir = filepath.Clean(dir)
+	for {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// Config keeps configurations for a Provider.
+type Config struct {
+	// The minimum time between two runs of git status for the same repository;
+	// the status is reused within this interval. Default is 1 second.
+	Debounce time.Duration
+	// The function that runs "git status --porcelain=v2 --branch" in the root
+	// of a repository and returns its output. Default runs the git command.
+	Run func(root string) ([]byte, error)
+}
+
+const defaultDebounce = time.Second
+
+func runGit(root string) ([]byte, error) {
+	cmd := exec.Command("git", "status", "--porcelain=v2", "--branch")
+	cmd.Dir = root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// Provider provides the status of Git repositories, caching it per repository
+// root. It is safe for concurrent use.
+type Provider struct {
+	cfg Config
+
+	mutex sync.Mutex
+	repos map[string]*repoState
+}
+
+type repoState struct {
+	// Closed when the current run finishes; nil if there is no run going on.
+	done chan struct{}
+	// The result of the last run, and when it finished. Only meaningful if
+	// updated is not zero.
+	status  Status
+	err     error
+	updated time.Time
+}
+
+// New makes a new Provider.
+func New(cfg Config) *Provider {
+	if cfg.Debounce == 0 {
+		cfg.Debounce = defaultDebounce
+	}
+	if cfg.Run == nil {
+		cfg.Run = runGit
+	}
+	return &Provider{cfg: cfg, repos: map[string]*repoState{}}
+}
+
+// Status returns the status of the repository that contains dir, returning
+// ErrNotRepo if there is no such repository.
+//
+// If the status of the repository has been obtained within the debounce
+// interval, it is returned without running git again. If git is already
+// running for the repository, Status waits for it instead of starting another
+// run.
+func (p *Provider) Status(dir string) (Status, error) {
+	root, ok := FindRoot(dir)
+	if !ok {
+		return Status{}, ErrNotRepo
+	}
+
+	p.mutex.Lock()
+	st, ok := p.repos[root]
+	if !ok {
+		st = &repoState{}
+		p.repos[root] = st
+	}
+	if st.done == nil {
+		if !st.updated.IsZero() && time.Since(st.updated) < p.cfg.Debounce {
+			defer p.mutex.Unlock()
+			return st.status, st.err
+		}
+		st.done = make(chan struct{})
+		go p.run(root, st, st.done)
+	}
+	done := st.done
+	p.mutex.Unlock()
+
+	<-done
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return st.status, st.err
+}
+
+func (p *Provider) run(root string, st *repoState, done chan struct{}) {
+	var status Status
+	output, err := p.cfg.Run(root)
+	if err == nil {
+		status, err = Parse(output)
+	}
+	p.mutex.Lock()
+	st.status, st.err, st.updated = status, err, time.Now()
+	st.done = nil
+	p.mutex.Unlock()
+	close(done)
+}
+
+// Segment returns a prompt segment that shows the status of the repository
+// containing the working directory, formatted with format, or Format if it is
+// nil. The segment is empty outside of Git repositories and when git fails.
+//
+// The cache key of the segment is the repository root, so when the timeout of
+// the returned segment is set, the last status of the same repository is
+// shown stale while git is running.
+func (p *Provider) Segment(format func(Status) ui.Text) prompt.Segment {
+	if format == nil {
+		format = Format
+	}
+	return prompt.Segment{
+		Compute: func() ui.Text {
+			wd, err := os.Getwd()
+			if err != nil {
+				return nil
+			}
+			status, err := p.Status(wd)
+			if err != nil {
+				return nil
+			}
+			return format(status)
+		},
+		Key: func() string {
+			wd, err := os.Getwd()
+			if err != nil {
+				return ""
+			}
+			root, _ := FindRoot(wd)
+			return root
+		},
+	}
+}
+
+// Format formats the status like "main ↑1 ↓2 +3 !4 ?5", showing the branch
+// name (or the abbreviated commit ID if HEAD is detached), followed by the
+// number of commits ahead of and behind the upstream, staged files, unstaged
+// files, untracked files and conflicted files, each only when not zero.
+func Format(s Status) ui.Text {
+	var t ui.Text
+	switch {
+	case s.Branch != "":
+		t = ui.T(s.Branch, ui.FgMagenta)
+	case len(s.Oid) >= 7:
+		t = ui.T(s.Oid[:7], ui.FgMagenta)
+	default:
+		t = ui.T(s.Oid, ui.FgMagenta)
+	}
+	add := func(n int, symbol string, styling ui.Styling) {
+		if n > 0 {
+			t = ui.Concat(t, ui.T(" "), ui.T(symbol+strconv.Itoa(n), styling))
+		}
+	}
+	add(s.Ahead, "↑", ui.FgCyan)
+	add(s.Behind, "↓", ui.FgCyan)
+	add(s.Staged, "+", ui.FgGreen)
+	add(s.Unstaged, "!", ui.FgYellow)
+	add(s.Untracked, "?", ui.FgBlue)
+	add(s.Conflicted, "x", ui.FgRed)
+	return t
+}
@@ -0,0 +1,266 @@
+package gitstatus
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/testutil"
+	"src.elv.sh/pkg/ui"
+)
+
+var parseTests = []struct {
+	name    string
+	output  string
+	want    Status
+	wantErr bool
+}{
+	{name: "empty", output: "", want: Status{}},
+	{
+		name: "clean branch with upstream",
+		output: "# branch.oid 0123456789abcdef\n" +
+			"# branch.head main\n" +
+			"# branch.upstream origin/main\n" +
+			"# branch.ab +1 -2\n",
+		want: Status{Branch: "main", Oid: "0123456789abcdef",
+			Upstream: "origin/main", Ahead: 1, Behind: 2},
+	},
+	{
+		name: "detached HEAD",
+		output: "# branch.oid 0123456789abcdef\n" +
+			"# branch.head (detached)\n",
+		want: Status{Oid: "0123456789abcdef"},
+	},
+	{
+		name: "changes",
+		output: "# branch.oid (initial)\n" +
+			"# branch.head main\n" +
+			"1 M. N... 100644 100644 100644 a b staged.txt\n" +
+			"1 .M N... 100644 100644 100644 a b unstaged.txt\n" +
+			"1 MM N... 100644 100644 100644 a b both.txt\n" +
+			"2 R. N... 100644 100644 100644 a b R100 new.txt\told.txt\n" +
+			"u UU N... 100644 100644 100644 100644 a b c conflict.txt\n" +
+			"? untracked.txt\n" +
+			"? untracked2.txt\n",
+		want: Status{Branch: "main", Oid: "(initial)",
+			Staged: 3, Unstaged: 2, Untracked: 2, Conflicted: 1},
+	},
+	{name: "bad ahead-behind", output: "# branch.ab x y\n", wantErr: true},
+	{name: "bad change", output: "1 M\n", wantErr: true},
+	{name: "unknown line", output: "bad\n", wantErr: true},
+}
+
+func TestParse(t *testing.T) {
+	for _, test := range parseTests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Parse([]byte(test.output))
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("got nil error, want non-nil")
+				}
+				return
+			}
+			if got != test.want || err != nil {
+				t.Errorf("got (%v, %v), want (%v, nil)", got, err, test.want)
+			}
+		})
+	}
+}
+
+func TestFindRoot(t *testing.T) {
+	tmp := testutil.TempDir(t)
+	testutil.ApplyDirIn(testutil.Dir{
+		"repo": testutil.Dir{
+			".git": testutil.Dir{},
+			"a":    testutil.Dir{"b": testutil.Dir{}},
+		},
+		// Worktrees and submodules have a .git file instead of a directory.
+		"worktree": testutil.Dir{".git": "gitdir: elsewhere"},
+		"norepo":   testutil.Dir{},
+	}, tmp)
+
+	tests := []struct {
+		dir      string
+		wantRoot string
+		wantOK   bool
+	}{
+		{"repo", "repo", true},
+		{"repo/a/b", "repo", true},
+		{"worktree", "worktree", true},
+		{"norepo", "", false},
+	}
+	for _, test := range tests {
+		root, ok := FindRoot(filepath.Join(tmp, test.dir))
+		wantRoot := ""
+		if test.wantOK {
+			wantRoot = filepath.Join(tmp, test.wantRoot)
+		}
+		if root != wantRoot || ok != test.wantOK {
+			t.Errorf("FindRoot(%q) -> (%q, %v), want (%q, %v)",
+				test.dir, root, ok, wantRoot, test.wantOK)
+		}
+	}
+}
+
+func TestProvider_Debounce(t *testing.T) {
+	repo := tempRepo(t)
+	var runs int32
+	p := New(Config{
+		Debounce: testutil.Scaled(50 * time.Millisecond),
+		Run: func(string) ([]byte, error) {
+			atomic.AddInt32(&runs, 1)
+			return []byte("# branch.head main\n"), nil
+		},
+	})
+
+	for i := 0; i < 3; i++ {
+		status, err := p.Status(repo)
+		if want := (Status{Branch: "main"}); status != want || err != nil {
+			t.Errorf("got (%v, %v), want (%v, nil)", status, err, want)
+		}
+	}
+	if n := atomic.LoadInt32(&runs); n != 1 {
+		t.Errorf("git run %d times within debounce interval, want 1", n)
+	}
+
+	time.Sleep(testutil.Scaled(60 * time.Millisecond))
+	p.Status(repo)
+	if n := atomic.LoadInt32(&runs); n != 2 {
+		t.Errorf("git run %d times after debounce interval, want 2", n)
+	}
+}
+
+func TestProvider_SharesRun(t *testing.T) {
+	repo := tempRepo(t)
+	var runs int32
+	unblock := make(chan struct{})
+	p := New(Config{
+		Run: func(string) ([]byte, error) {
+			atomic.AddInt32(&runs, 1)
+			<-unblock
+			return nil, nil
+		},
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			p.Status(filepath.Join(repo, "a"))
+			wg.Done()
+		}()
+	}
+	// Give both calls a chance to start before unblocking git.
+	time.Sleep(testutil.Scaled(10 * time.Millisecond))
+	close(unblock)
+	wg.Wait()
+	if n := atomic.LoadInt32(&runs); n != 1 {
+		t.Errorf("git run %d times, want 1", n)
+	}
+}
+
+func TestProvider_CachesPerRoot(t *testing.T) {
+	repo1, repo2 := tempRepo(t), tempRepo(t)
+	p := New(Config{
+		Run: func(root string) ([]byte, error) {
+			return []byte("# branch.head " + filepath.Base(root) + "\n"), nil
+		},
+	})
+
+	for _, repo := range []string{repo1, repo2, repo1} {
+		status, _ := p.Status(repo)
+		if status.Branch != filepath.Base(repo) {
+			t.Errorf("got branch %q for %s", status.Branch, repo)
+		}
+	}
+}
+
+func TestProvider_Errors(t *testing.T) {
+	errRun := errors.New("cannot run git")
+	p := New(Config{Run: func(string) ([]byte, error) { return nil, errRun }})
+
+	if _, err := p.Status(tempRepo(t)); err != errRun {
+		t.Errorf("got error %v, want %v", err, errRun)
+	}
+	if _, err := p.Status(testutil.TempDir(t)); err != ErrNotRepo {
+		t.Errorf("got error %v, want %v", err, ErrNotRepo)
+	}
+}
+
+func TestSegment(t *testing.T) {
+	repo := tempRepo(t)
+	testutil.Chdir(t, repo)
+	p := New(Config{
+		Run: func(string) ([]byte, error) {
+			return []byte("# branch.head main\n? foo\n"), nil
+		},
+	})
+	seg := p.Segment(nil)
+
+	want := ui.Concat(ui.T("main", ui.FgMagenta), ui.T(" "), ui.T("?1", ui.FgBlue))
+	if got := seg.Compute(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if key := seg.Key(); key != repo {
+		t.Errorf("got key %q, want %q", key, repo)
+	}
+
+	testutil.Chdir(t, testutil.TempDir(t))
+	if got := seg.Compute(); got != nil {
+		t.Errorf("got %v outside repository, want nil", got)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		status Status
+		want   ui.Text
+	}{
+		{Status{Branch: "main"}, ui.T("main", ui.FgMagenta)},
+		{Status{Oid: "0123456789abcdef"}, ui.T("0123456", ui.FgMagenta)},
+		{Status{Branch: "main", Ahead: 1, Behind: 2, Staged: 3, Unstaged: 4,
+			Untracked: 5, Conflicted: 6},
+			ui.Concat(ui.T("main", ui.FgMagenta),
+				ui.T(" "), ui.T("↑1", ui.FgCyan), ui.T(" "), ui.T("↓2", ui.FgCyan),
+				ui.T(" "), ui.T("+3", ui.FgGreen), ui.T(" "), ui.T("!4", ui.FgYellow),
+				ui.T(" "), ui.T("?5", ui.FgBlue), ui.T(" "), ui.T("x6", ui.FgRed))},
+	}
+	for _, test := range tests {
+		if got := Format(test.status); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Format(%v) -> %v, want %v", test.status, got, test.want)
+		}
+	}
+}
+
+func TestProvider_RealGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	repo := testutil.TempDir(t)
+	cmd := exec.Command("git", "init", "-q", "-b", "main")
+	cmd.Dir = repo
+	if err := cmd.Run(); err != nil {
+		t.Skip("cannot run git init:", err)
+	}
+	must.WriteFile(filepath.Join(repo, "foo"), "foo")
+
+	status, err := New(Config{}).Status(repo)
+	want := Status{Branch: "main", Oid: "(initial)", Untracked: 1}
+	if status != want || err != nil {
+		t.Errorf("got (%v, %v), want (%v, nil)", status, err, want)
+	}
+}
+
+// Creates a directory that looks like the root of a Git repository, with a
+// subdirectory named "a".
+func tempRepo(t *testing.T) string {
+	dir := testutil.TempDir(t)
+	testutil.ApplyDirIn(testutil.Dir{".git": testutil.Dir{}, "a": testutil.Dir{}}, dir)
+	return dir
+}
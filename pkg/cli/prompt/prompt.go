@@ -1,20 +1,28 @@
-// Package prompt provides an implementation of the cli.Prompt interface.
+// Package prompt provides an implementation of the cli.Prompt interface, and
+// a way to compose a prompt from segments that are computed independently.
 package prompt
 
 import (
+	"context"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"src.elv.sh/pkg/cli/clock"
 	"src.elv.sh/pkg/ui"
 )
 
 // Prompt implements a prompt that is executed asynchronously.
 type Prompt struct {
 	config Config
+	// Whether computations can be cancelled, which is the case when
+	// Config.ComputeCtx is set.
+	cancellable bool
 
-	// Working directory when prompt was last updated.
-	lastWd string
+	// Whether the working directory has changed since the prompt was last
+	// updated; accessed atomically.
+	dirChanged int32
 	// Channel for update requests.
 	updateReq chan struct{}
 	// Channel on which prompt contents are delivered.
@@ -23,19 +31,47 @@ type Prompt struct {
 	last ui.Text
 	// Mutex for guarding access to the last field.
 	lastMutex sync.RWMutex
+	// Cancels the context of the last computation.
+	cancel context.CancelFunc
+	// Mutex for guarding access to the cancel field.
+	cancelMutex sync.Mutex
+	// Prompt contents computed before, indexed by the cache key. Only accessed
+	// from the loop goroutine.
+	cache map[string]ui.Text
 }
 
 // Config keeps configurations for the prompt.
 type Config struct {
 	// The function that computes the prompt.
 	Compute func() ui.Text
+	// Like Compute, but takes a context that is cancelled when the computation
+	// has become stale and a newer update is requested, or when Cancel is
+	// called. The result of a cancelled computation is discarded. If set,
+	// Compute is not used.
+	ComputeCtx func(context.Context) ui.Text
+	// The content shown before the prompt is computed for the first time.
+	// Default is "???> ". A prompt shown on the right usually wants an empty
+	// placeholder instead.
+	Placeholder func() ui.Text
 	// Function to transform stale prompts.
 	StaleTransform func(ui.Text) ui.Text
 	// Threshold for a prompt to be considered as stale.
 	StaleThreshold func() time.Duration
 	// How eager the prompt should be updated. When >= 5, updated when directory
-	// is changed. When >= 10, always update. Default is 5.
+	// is changed, as signaled by DirChanged. When >= 10, always update. Default
+	// is 5.
 	Eagerness func() int
+	// If not nil, prompt contents are cached, keyed by the working directory
+	// together with the key returned by this function. When there is a cached
+	// content for the current key, an update shows it immediately without
+	// calling the compute function. The function should return quickly, and
+	// the key should capture everything else the prompt depends on, like the
+	// HEAD of the Git repository. If it returns false, the cache is not used
+	// for the update.
+	CacheKey func() (string, bool)
+	// The clock used for deciding when the prompt becomes stale. Default is
+	// clock.Real.
+	Clock clock.Clock
 }
 
 func defaultStaleTransform(t ui.Text) ui.Text {
@@ -46,6 +82,9 @@ const defaultStaleThreshold = 200 * time.Millisecond
 
 const defaultEagerness = 5
 
+// The maximum number of prompt contents to cache.
+const maxCacheEntries = 64
+
 var unknownContent = ui.T("???> ")
 
 // New makes a new prompt.
@@ -53,6 +92,14 @@ func New(cfg Config) *Prompt {
 	if cfg.Compute == nil {
 		cfg.Compute = func() ui.Text { return unknownContent }
 	}
+	cancellable := cfg.ComputeCtx != nil
+	if !cancellable {
+		compute := cfg.Compute
+		cfg.ComputeCtx = func(context.Context) ui.Text { return compute() }
+	}
+	if cfg.Placeholder == nil {
+		cfg.Placeholder = func() ui.Text { return unknownContent }
+	}
 	if cfg.StaleTransform == nil {
 		cfg.StaleTransform = defaultStaleTransform
 	}
@@ -62,29 +109,69 @@ func New(cfg Config) *Prompt {
 	if cfg.Eagerness == nil {
 		cfg.Eagerness = func() int { return defaultEagerness }
 	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Real
+	}
 	p := &Prompt{
-		cfg,
-		"", make(chan struct{}, 1), make(chan struct{}, 1),
-		unknownContent, sync.RWMutex{}}
+		config: cfg, cancellable: cancellable, dirChanged: 1,
+		updateReq: make(chan struct{}, 1), ch: make(chan struct{}, 1),
+		last: cfg.Placeholder(), cancel: func() {}, cache: map[string]ui.Text{}}
 	// TODO: Don't keep a goroutine running.
 	go p.loop()
 	return p
 }
 
 func (p *Prompt) loop() {
-	content := unknownContent
-	ch := make(chan ui.Text)
+	content := p.config.Placeholder()
 	for range p.updateReq {
+		key, useCache := p.cacheKey()
+		if useCache {
+			if cached, ok := p.cache[key]; ok {
+				content = cached
+				p.update(content)
+				continue
+			}
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		p.cancelMutex.Lock()
+		p.cancel = cancel
+		p.cancelMutex.Unlock()
+		// Buffered, so that a computation that is no longer waited for can
+		// still finish.
+		ch := make(chan ui.Text, 1)
 		go func() {
-			ch <- p.config.Compute()
+			ch <- p.config.ComputeCtx(ctx)
 		}()
+		// Whether the computation has been cancelled, so that its result
+		// should be discarded.
+		cancelled := func() bool { return p.cancellable && ctx.Err() != nil }
 
 		select {
-		case <-time.After(p.config.StaleThreshold()):
+		case <-p.config.Clock.After(p.config.StaleThreshold()):
 			// The prompt callback did not finish within the threshold. Send the
 			// previous content, marked as stale.
 			p.update(p.config.StaleTransform(content))
-			content = <-ch
+			var newContent ui.Text
+			if p.cancellable {
+				select {
+				case newContent = <-ch:
+				case <-p.updateReq:
+					// Superseded by a newer update request; abandon this
+					// computation and start another one.
+					cancel()
+					p.queueUpdate()
+					continue
+				}
+			} else {
+				newContent = <-ch
+			}
+			if cancelled() {
+				break
+			}
+			content = newContent
+			if useCache {
+				p.addToCache(key, content)
+			}
 
 			select {
 			case <-p.updateReq:
@@ -95,10 +182,45 @@ func (p *Prompt) loop() {
 			default:
 				p.update(content)
 			}
-		case content = <-ch:
-			p.update(content)
+		case newContent := <-ch:
+			if !cancelled() {
+				content = newContent
+				if useCache {
+					p.addToCache(key, content)
+				}
+				p.update(content)
+			}
+		}
+		cancel()
+	}
+}
+
+// Returns the key to look up and store the prompt content in the cache, and
+// whether the cache should be used at all.
+func (p *Prompt) cacheKey() (string, bool) {
+	if p.config.CacheKey == nil {
+		return "", false
+	}
+	key, ok := p.config.CacheKey()
+	if !ok {
+		return "", false
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", false
+	}
+	return wd + "\x00" + key, true
+}
+
+func (p *Prompt) addToCache(key string, content ui.Text) {
+	if _, ok := p.cache[key]; !ok && len(p.cache) >= maxCacheEntries {
+		// Evict an arbitrary entry.
+		for k := range p.cache {
+			delete(p.cache, k)
+			break
 		}
 	}
+	p.cache[key] = content
 }
 
 // Trigger triggers an update to the prompt.
@@ -115,6 +237,15 @@ func (p *Prompt) Get() ui.Text {
 	return p.last
 }
 
+// Cancel cancels the ongoing computation of the prompt, if it was started with
+// Config.ComputeCtx. It is called when the prompt is no longer needed, like when
+// the editor has finished reading code.
+func (p *Prompt) Cancel() {
+	p.cancelMutex.Lock()
+	defer p.cancelMutex.Unlock()
+	p.cancel()
+}
+
 // LateUpdates returns a channel on which late updates are made available.
 func (p *Prompt) LateUpdates() <-chan struct{} {
 	return p.ch
@@ -127,6 +258,12 @@ func (p *Prompt) queueUpdate() {
 	}
 }
 
+// DirChanged signals that the working directory has changed, so that the next
+// call to Trigger updates the prompt when the eagerness is >= 5.
+func (p *Prompt) DirChanged() {
+	atomic.StoreInt32(&p.dirChanged, 1)
+}
+
 func (p *Prompt) update(content ui.Text) {
 	p.lastMutex.Lock()
 	p.last = content
@@ -140,13 +277,7 @@ func (p *Prompt) shouldUpdate() bool {
 		return true
 	}
 	if eagerness >= 5 {
-		wd, err := os.Getwd()
-		if err != nil {
-			wd = "error"
-		}
-		oldWd := p.lastWd
-		p.lastWd = wd
-		return wd != oldWd
+		return atomic.SwapInt32(&p.dirChanged, 0) != 0
 	}
 	return false
 }
@@ -1,11 +1,14 @@
 package prompt
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"testing"
 	"time"
 
+	"src.elv.sh/pkg/cli/clitest"
+	"src.elv.sh/pkg/must"
 	"src.elv.sh/pkg/testutil"
 	"src.elv.sh/pkg/ui"
 )
@@ -25,18 +28,30 @@ func TestPrompt_ShowsComputedPrompt(t *testing.T) {
 	testUpdate(t, prompt, ui.T(">>> "))
 }
 
+// The stale threshold used with a fake clock. The value doesn't matter, since
+// the fake clock only moves when advanced.
+const staleThreshold = 10 * time.Millisecond
+
 func TestPrompt_StalePrompt(t *testing.T) {
 	compute, unblock := blockedAutoIncPrompt()
+	clock := clitest.NewFakeClock()
 	prompt := New(Config{
-		Compute: compute,
-		StaleThreshold: func() time.Duration {
-			return testutil.Scaled(10 * time.Millisecond)
-		},
+		Compute:        compute,
+		StaleThreshold: func() time.Duration { return staleThreshold },
+		Clock:          clock,
 	})
+	// Lets the stale threshold pass once the prompt has started waiting for
+	// the compute function.
+	passThreshold := func() {
+		t.Helper()
+		clock.WaitTimers(t, 1)
+		clock.Advance(staleThreshold)
+	}
 
 	prompt.Trigger(true)
 	// The compute function is blocked, so a stale version of the initial
 	// "unknown" prompt will be shown.
+	passThreshold()
 	testUpdate(t, prompt, ui.T("???> ", ui.Inverse))
 
 	// The compute function will now return.
@@ -48,6 +63,7 @@ func TestPrompt_StalePrompt(t *testing.T) {
 	prompt.Trigger(true)
 	// The compute function will now be blocked again, so after a while a stale
 	// version of the previous prompt will be shown.
+	passThreshold()
 	testUpdate(t, prompt, ui.T("1> ", ui.Inverse))
 
 	// Unblock the compute function.
@@ -58,12 +74,14 @@ func TestPrompt_StalePrompt(t *testing.T) {
 	// Force a refresh.
 	prompt.Trigger(true)
 	// Make sure that the compute function is run and stuck.
+	passThreshold()
 	testUpdate(t, prompt, ui.T("2> ", ui.Inverse))
 	// Queue another two refreshes before the compute function can return.
 	prompt.Trigger(true)
 	prompt.Trigger(true)
 	unblock()
-	// Now the new prompt should be marked stale immediately.
+	// Now the new prompt should be marked stale once the threshold passes.
+	passThreshold()
 	testUpdate(t, prompt, ui.T("3> ", ui.Inverse))
 	unblock()
 	// However, the two refreshes we requested early only trigger one
@@ -72,6 +90,87 @@ func TestPrompt_StalePrompt(t *testing.T) {
 	testUpdate(t, prompt, ui.T("4> "))
 }
 
+func TestPrompt_Placeholder(t *testing.T) {
+	compute, unblock := blockedAutoIncPrompt()
+	prompt := New(Config{
+		Compute:     compute,
+		Placeholder: func() ui.Text { return ui.T("R") },
+		StaleThreshold: func() time.Duration {
+			return testutil.Scaled(10 * time.Millisecond)
+		},
+	})
+
+	if got, want := prompt.Get(), ui.T("R"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got initial content %v, want %v", got, want)
+	}
+	prompt.Trigger(true)
+	testUpdate(t, prompt, ui.T("R", ui.Inverse))
+	unblock()
+	testUpdate(t, prompt, ui.T("1> "))
+}
+
+func TestPrompt_ComputeCtx_CancelledWhenSuperseded(t *testing.T) {
+	started := make(chan int, 2)
+	i := 0
+	prompt := New(Config{
+		ComputeCtx: func(ctx context.Context) ui.Text {
+			i++
+			started <- i
+			if i == 1 {
+				// The first computation only finishes when it is cancelled.
+				<-ctx.Done()
+				return ui.T("cancelled> ")
+			}
+			return ui.T(fmt.Sprintf("%d> ", i))
+		},
+		StaleThreshold: func() time.Duration {
+			return testutil.Scaled(10 * time.Millisecond)
+		},
+	})
+
+	prompt.Trigger(true)
+	<-started
+	testUpdate(t, prompt, ui.T("???> ", ui.Inverse))
+	// A new update request cancels the stale computation, whose result is
+	// discarded.
+	prompt.Trigger(true)
+	testUpdate(t, prompt, ui.T("2> "))
+}
+
+func TestPrompt_Cancel(t *testing.T) {
+	started := make(chan struct{})
+	prompt := New(Config{
+		ComputeCtx: func(ctx context.Context) ui.Text {
+			close(started)
+			<-ctx.Done()
+			return ui.T("cancelled> ")
+		},
+		StaleThreshold: func() time.Duration { return time.Hour },
+	})
+
+	prompt.Trigger(true)
+	<-started
+	prompt.Cancel()
+	// The cancelled computation doesn't update the prompt.
+	testNoUpdate(t, prompt)
+	if got, want := prompt.Get(), ui.T("???> "); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPrompt_Cancel_NoEffectWithoutComputeCtx(t *testing.T) {
+	compute, unblock := blockedAutoIncPrompt()
+	prompt := New(Config{
+		Compute:        compute,
+		StaleThreshold: func() time.Duration { return time.Hour },
+	})
+
+	prompt.Trigger(true)
+	prompt.Cancel()
+	unblock()
+	testUpdate(t, prompt, ui.T("1> "))
+}
+
 func TestPrompt_Eagerness0(t *testing.T) {
 	prompt := New(Config{
 		Compute:   autoIncPrompt(),
@@ -111,7 +210,7 @@ func TestPrompt_Eagerness5(t *testing.T) {
 	testNoUpdate(t, prompt)
 
 	// Update because the pwd has changed.
-	testutil.InTempDir(t)
+	prompt.DirChanged()
 	prompt.Trigger(false)
 	testUpdate(t, prompt, ui.T("2> "))
 }
@@ -135,6 +234,78 @@ func TestPrompt_Eagerness10(t *testing.T) {
 	testUpdate(t, prompt, ui.T("4> "))
 }
 
+func TestPrompt_CacheKey(t *testing.T) {
+	key := "a"
+	prompt := New(Config{
+		Compute:  autoIncPrompt(),
+		CacheKey: func() (string, bool) { return key, true },
+	})
+
+	prompt.Trigger(true)
+	testUpdate(t, prompt, ui.T("1> "))
+	// The content for the same key is reused without calling Compute.
+	prompt.Trigger(true)
+	testUpdate(t, prompt, ui.T("1> "))
+
+	key = "b"
+	prompt.Trigger(true)
+	testUpdate(t, prompt, ui.T("2> "))
+
+	// Contents for keys used before are still cached.
+	key = "a"
+	prompt.Trigger(true)
+	testUpdate(t, prompt, ui.T("1> "))
+}
+
+func TestPrompt_CacheKey_IncludesWorkingDirectory(t *testing.T) {
+	dir := testutil.InTempDir(t)
+	must.MkdirAll("d")
+	prompt := New(Config{
+		Compute:  autoIncPrompt(),
+		CacheKey: func() (string, bool) { return "", true },
+	})
+
+	prompt.Trigger(true)
+	testUpdate(t, prompt, ui.T("1> "))
+
+	testutil.Chdir(t, "d")
+	prompt.Trigger(true)
+	testUpdate(t, prompt, ui.T("2> "))
+
+	testutil.Chdir(t, dir)
+	prompt.Trigger(true)
+	testUpdate(t, prompt, ui.T("1> "))
+}
+
+func TestPrompt_NoCacheWithoutCacheKey(t *testing.T) {
+	prompt := New(Config{Compute: autoIncPrompt()})
+
+	prompt.Trigger(true)
+	testUpdate(t, prompt, ui.T("1> "))
+	prompt.Trigger(true)
+	testUpdate(t, prompt, ui.T("2> "))
+}
+
+func TestPrompt_CacheKey_NotOK(t *testing.T) {
+	ok := true
+	prompt := New(Config{
+		Compute:  autoIncPrompt(),
+		CacheKey: func() (string, bool) { return "a", ok },
+	})
+
+	prompt.Trigger(true)
+	testUpdate(t, prompt, ui.T("1> "))
+
+	// The cache is neither used nor updated.
+	ok = false
+	prompt.Trigger(true)
+	testUpdate(t, prompt, ui.T("2> "))
+
+	ok = true
+	prompt.Trigger(true)
+	testUpdate(t, prompt, ui.T("1> "))
+}
+
 func blockedAutoIncPrompt() (func() ui.Text, func()) {
 	unblockChan := make(chan struct{})
 	i := 0
@@ -0,0 +1,131 @@
+package prompt
+
+import (
+	"sync"
+	"time"
+
+	"src.elv.sh/pkg/ui"
+)
+
+// Segment is a part of a prompt that is computed independently of the other
+// parts. Segments are combined into the Compute function of a Prompt with
+// Combine.
+type Segment struct {
+	// The function that computes the segment.
+	Compute func() ui.Text
+	// The function that returns the cache key of the segment, identifying the
+	// context the content of the segment depends on, like the working
+	// directory. When the segment is not computed in time, the last content
+	// computed with the same key is shown instead. If nil, the key is always
+	// the same.
+	Key func() string
+	// How long to wait for the segment to be computed. If 0, it is always
+	// waited for.
+	Timeout time.Duration
+	// Function to transform the last content when the segment is not
+	// computed in time. Default is the same as Config.StaleTransform.
+	StaleTransform func(ui.Text) ui.Text
+}
+
+// Combine returns a function that computes all the segments concurrently and
+// concatenates their contents, suitable for Config.Compute.
+//
+// A segment that is not computed within its timeout is shown with its last
+// content with the same cache key, transformed by its StaleTransform, or empty
+// if there is no such content. Such a segment continues to be computed in the
+// background, and lateUpdate, if not nil, is called when it finishes, so that
+// the prompt can be recomputed with the new content. A segment is never
+// computed more than once at the same time; if it is still being computed
+// when the combined function is called again, the same computation is waited
+// for.
+func Combine(lateUpdate func(), segments ...Segment) func() ui.Text {
+	states := make([]*segmentState, len(segments))
+	for i, seg := range segments {
+		if seg.Compute == nil {
+			seg.Compute = func() ui.Text { return nil }
+		}
+		if seg.Key == nil {
+			seg.Key = func() string { return "" }
+		}
+		if seg.StaleTransform == nil {
+			seg.StaleTransform = defaultStaleTransform
+		}
+		states[i] = &segmentState{seg: seg, lateUpdate: lateUpdate}
+	}
+	return func() ui.Text {
+		contents := make([]ui.Text, len(states))
+		var wg sync.WaitGroup
+		wg.Add(len(states))
+		for i, st := range states {
+			i, st := i, st
+			go func() {
+				contents[i] = st.get()
+				wg.Done()
+			}()
+		}
+		wg.Wait()
+		return ui.Concat(contents...)
+	}
+}
+
+type segmentState struct {
+	seg        Segment
+	lateUpdate func()
+
+	// Protects the fields below.
+	mutex sync.Mutex
+	// Closed when the current computation finishes; nil if there is no
+	// computation going on.
+	done chan struct{}
+	// Whether the current computation has taken longer than the timeout.
+	late bool
+	// The result of the last computation. Only meaningful if computed is true.
+	key      string
+	content  ui.Text
+	computed bool
+}
+
+func (st *segmentState) get() ui.Text {
+	key := st.seg.Key()
+	st.mutex.Lock()
+	if st.done == nil {
+		st.done = make(chan struct{})
+		go st.compute(key, st.done)
+	}
+	done := st.done
+	st.mutex.Unlock()
+
+	var timeout <-chan time.Time
+	if st.seg.Timeout > 0 {
+		timer := time.NewTimer(st.seg.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-done:
+		st.mutex.Lock()
+		defer st.mutex.Unlock()
+		return st.content
+	case <-timeout:
+		st.mutex.Lock()
+		defer st.mutex.Unlock()
+		st.late = true
+		if st.computed && st.key == key {
+			return st.seg.StaleTransform(st.content)
+		}
+		return nil
+	}
+}
+
+func (st *segmentState) compute(key string, done chan struct{}) {
+	content := st.seg.Compute()
+	st.mutex.Lock()
+	st.key, st.content, st.computed = key, content, true
+	late := st.late
+	st.done, st.late = nil, false
+	st.mutex.Unlock()
+	close(done)
+	if late && st.lateUpdate != nil {
+		st.lateUpdate()
+	}
+}
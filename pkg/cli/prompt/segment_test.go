@@ -0,0 +1,99 @@
+package prompt
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/testutil"
+	"src.elv.sh/pkg/ui"
+)
+
+func TestCombine(t *testing.T) {
+	compute := Combine(nil,
+		Segment{Compute: func() ui.Text { return ui.T("foo", ui.FgRed) }},
+		Segment{},
+		Segment{Compute: func() ui.Text { return ui.T("> ") }})
+
+	testCombined(t, compute, ui.Concat(ui.T("foo", ui.FgRed), ui.T("> ")))
+}
+
+func TestCombine_ComputesSegmentsConcurrently(t *testing.T) {
+	// Each segment waits for the other one to start, so computing them one
+	// after another would deadlock.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	waitForOther := func(s string) func() ui.Text {
+		return func() ui.Text {
+			wg.Done()
+			wg.Wait()
+			return ui.T(s)
+		}
+	}
+	compute := Combine(nil,
+		Segment{Compute: waitForOther("a")}, Segment{Compute: waitForOther("b")})
+
+	done := make(chan ui.Text)
+	go func() { done <- compute() }()
+	select {
+	case got := <-done:
+		if want := ui.T("ab"); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("segments not computed concurrently")
+	}
+}
+
+func TestCombine_Timeout(t *testing.T) {
+	slow, unblock := blockedAutoIncPrompt()
+	key := "dir1"
+	lateUpdates := make(chan struct{}, 1)
+	compute := Combine(func() { lateUpdates <- struct{}{} },
+		Segment{Compute: func() ui.Text { return ui.T("fast ") }},
+		Segment{
+			Compute: slow,
+			Key:     func() string { return key },
+			Timeout: testutil.Scaled(10 * time.Millisecond),
+		})
+
+	// The slow segment has never been computed, so it is shown empty.
+	testCombined(t, compute, ui.T("fast "))
+	unblock()
+	testLateUpdate(t, lateUpdates)
+
+	// The last content of the slow segment is shown as stale. Calling the
+	// combined function again while the segment is still being computed
+	// doesn't start another computation.
+	stale1 := ui.Concat(ui.T("fast "), ui.T("1> ", ui.Inverse))
+	testCombined(t, compute, stale1)
+	testCombined(t, compute, stale1)
+	unblock()
+	testLateUpdate(t, lateUpdates)
+
+	// The cached content has a different key, so it is not shown.
+	key = "dir2"
+	testCombined(t, compute, ui.T("fast "))
+	unblock()
+	testLateUpdate(t, lateUpdates)
+	testCombined(t, compute, ui.Concat(ui.T("fast "), ui.T("3> ", ui.Inverse)))
+	unblock()
+	testLateUpdate(t, lateUpdates)
+}
+
+func testCombined(t *testing.T, compute func() ui.Text, want ui.Text) {
+	t.Helper()
+	if got := compute(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func testLateUpdate(t *testing.T, ch <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Errorf("no late update after 1 second")
+	}
+}
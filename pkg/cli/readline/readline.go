@@ -0,0 +1,242 @@
+// Package readline provides a minimal line reader built on Elvish's line
+// editor, for Go programs that want to use it as a readline replacement.
+//
+// Unlike the full editor in src.elv.sh/pkg/edit, this package doesn't depend
+// on the Elvish interpreter, the daemon or the persistent history store; it
+// only uses the src.elv.sh/pkg/cli package and its subpackages. It supports
+// basic Emacs-style key bindings, walking through an in-memory or custom
+// history, and completion through a pluggable completer.
+//
+// A typical use looks like:
+//
+//	r := readline.New(readline.Config{
+//		Prompt: func() ui.Text { return ui.T("> ") },
+//	})
+//	for {
+//		line, err := r.ReadLine()
+//		if err != nil {
+//			break
+//		}
+//		// Handle line
+//	}
+package readline
+
+import (
+	"strings"
+	"unicode"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/histutil"
+	"src.elv.sh/pkg/cli/modes"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/strutil"
+	"src.elv.sh/pkg/ui"
+	"src.elv.sh/pkg/wcwidth"
+)
+
+// Config specifies the configuration for a Reader. All fields are optional.
+type Config struct {
+	// A function that returns the prompt. If nil, no prompt is shown.
+	Prompt func() ui.Text
+	// A function that returns the right-hand prompt. If nil, no right-hand
+	// prompt is shown.
+	RPrompt func() ui.Text
+	// A function that highlights the code. If nil, the code is not
+	// highlighted.
+	Highlighter func(code string) ui.Text
+	// A function for completing the code when Tab is pressed. If nil, Tab
+	// does nothing.
+	Completer Completer
+	// History store. If nil, an in-memory store that is initially empty is
+	// used.
+	History histutil.Store
+	// The terminal to use. If nil, the standard input and standard error are
+	// used.
+	TTY cli.TTY
+}
+
+// Completer is called with the current code and the position of the dot. It
+// returns the start of the text to replace, which ends at the dot, and the
+// candidates to replace it with. If there is exactly one candidate, it is
+// inserted directly; otherwise the candidates are shown in a list.
+type Completer func(code string, dot int) (from int, candidates []string)
+
+// Reader reads lines from the terminal.
+type Reader struct {
+	app     cli.App
+	history histutil.Store
+}
+
+// New creates a new Reader.
+func New(cfg Config) *Reader {
+	r := &Reader{history: cfg.History}
+	if r.history == nil {
+		r.history = histutil.NewMemStore()
+	}
+	spec := cli.AppSpec{TTY: cfg.TTY}
+	if cfg.Prompt != nil {
+		spec.Prompt = funcPrompt(cfg.Prompt)
+	}
+	if cfg.RPrompt != nil {
+		spec.RPrompt = funcPrompt(cfg.RPrompt)
+	}
+	if cfg.Highlighter != nil {
+		spec.Highlighter = funcHighlighter(cfg.Highlighter)
+	}
+	spec.CodeAreaBindings = r.codeAreaBindings(cfg.Completer)
+	spec.GlobalBindings = tk.MapBindings{
+		term.K('[', ui.Ctrl): func(tk.Widget) { r.app.PopAddon() },
+	}
+	r.app = cli.NewApp(spec)
+	return r
+}
+
+// ReadLine reads a line, and adds it to the history if it is not empty. It
+// returns io.EOF when the user presses Ctrl-D while the buffer is empty.
+func (r *Reader) ReadLine() (string, error) {
+	line, err := r.app.ReadCode()
+	if err != nil {
+		return line, err
+	}
+	if line != "" {
+		_, err = r.history.AddCmd(storedefs.Cmd{Text: line})
+	}
+	return line, err
+}
+
+type funcPrompt func() ui.Text
+
+func (funcPrompt) Trigger(force bool)           {}
+func (p funcPrompt) Get() ui.Text               { return p() }
+func (funcPrompt) LateUpdates() <-chan struct{} { return nil }
+
+type funcHighlighter func(code string) ui.Text
+
+func (h funcHighlighter) Get(code string) (ui.Text, []ui.Text) { return h(code), nil }
+func (funcHighlighter) LateUpdates() <-chan struct{}           { return nil }
+
+func (r *Reader) codeAreaBindings(completer Completer) tk.Bindings {
+	mutate := func(f func(*tk.CodeBuffer)) func(tk.Widget) {
+		return func(w tk.Widget) {
+			w.(tk.CodeArea).MutateState(func(s *tk.CodeAreaState) { f(&s.Buffer) })
+		}
+	}
+	moveLeft := mutate(func(b *tk.CodeBuffer) { b.Dot = dotLeft(b.Content, b.Dot) })
+	moveRight := mutate(func(b *tk.CodeBuffer) { b.Dot = dotRight(b.Content, b.Dot) })
+	moveSOL := mutate(func(b *tk.CodeBuffer) { b.Dot = strutil.FindLastSOL(b.Content[:b.Dot]) })
+	moveEOL := mutate(func(b *tk.CodeBuffer) {
+		b.Dot += strutil.FindFirstEOL(b.Content[b.Dot:])
+	})
+	killRight := mutate(func(b *tk.CodeBuffer) { kill(b, b.Dot, dotRight(b.Content, b.Dot)) })
+	killSOL := mutate(func(b *tk.CodeBuffer) {
+		kill(b, strutil.FindLastSOL(b.Content[:b.Dot]), b.Dot)
+	})
+	killEOL := mutate(func(b *tk.CodeBuffer) {
+		kill(b, b.Dot, b.Dot+strutil.FindFirstEOL(b.Content[b.Dot:]))
+	})
+	killWordLeft := mutate(func(b *tk.CodeBuffer) { kill(b, wordLeft(b.Content, b.Dot), b.Dot) })
+	return tk.MapBindings{
+		term.K(ui.Left):      moveLeft,
+		term.K('B', ui.Ctrl): moveLeft,
+		term.K(ui.Right):     moveRight,
+		term.K('F', ui.Ctrl): moveRight,
+		term.K(ui.Home):      moveSOL,
+		term.K('A', ui.Ctrl): moveSOL,
+		term.K(ui.End):       moveEOL,
+		term.K('E', ui.Ctrl): moveEOL,
+		term.K(ui.Delete):    killRight,
+		term.K('U', ui.Ctrl): killSOL,
+		term.K('K', ui.Ctrl): killEOL,
+		term.K('W', ui.Ctrl): killWordLeft,
+		term.K('C', ui.Ctrl): mutate(func(b *tk.CodeBuffer) { *b = tk.CodeBuffer{} }),
+		term.K('D', ui.Ctrl): func(w tk.Widget) {
+			if w.(tk.CodeArea).CopyState().Buffer.Content == "" {
+				r.app.CommitEOF()
+			} else {
+				killRight(w)
+			}
+		},
+		term.K(ui.Up):  func(w tk.Widget) { r.startHistwalk(w.(tk.CodeArea)) },
+		term.K(ui.Tab): func(w tk.Widget) { r.complete(w.(tk.CodeArea), completer) },
+	}
+}
+
+func (r *Reader) startHistwalk(codeArea tk.CodeArea) {
+	buf := codeArea.CopyState().Buffer
+	w, err := modes.NewHistwalk(r.app, modes.HistwalkSpec{
+		Bindings: tk.MapBindings{
+			term.K(ui.Up): func(w tk.Widget) { w.(modes.Histwalk).Prev() },
+			term.K(ui.Down): func(w tk.Widget) {
+				if w.(modes.Histwalk).Next() == histutil.ErrEndOfHistory {
+					r.app.PopAddon()
+				}
+			},
+		},
+		Store: r.history, Prefix: buf.Content[:buf.Dot]})
+	if err == nil {
+		r.app.PushAddon(w)
+	}
+}
+
+func (r *Reader) complete(codeArea tk.CodeArea, completer Completer) {
+	if completer == nil {
+		return
+	}
+	buf := codeArea.CopyState().Buffer
+	from, candidates := completer(buf.Content, buf.Dot)
+	if from < 0 || from > buf.Dot {
+		return
+	}
+	if len(candidates) == 1 {
+		codeArea.MutateState(func(s *tk.CodeAreaState) {
+			s.Buffer = tk.CodeBuffer{
+				Content: buf.Content[:from] + candidates[0] + buf.Content[buf.Dot:],
+				Dot:     from + len(candidates[0])}
+		})
+		return
+	}
+	items := make([]modes.CompletionItem, len(candidates))
+	for i, c := range candidates {
+		items[i] = modes.CompletionItem{ToShow: ui.T(c), ToInsert: c}
+	}
+	w, err := modes.NewCompletion(r.app, modes.CompletionSpec{
+		Bindings: tk.MapBindings{
+			term.K(ui.Tab):           func(w tk.Widget) { w.(tk.ListBox).Select(tk.Next) },
+			term.K(ui.Tab, ui.Shift): func(w tk.Widget) { w.(tk.ListBox).Select(tk.Prev) },
+		},
+		Replace: diag.Ranging{From: from, To: buf.Dot},
+		Items:   items,
+	})
+	if err == nil {
+		r.app.PushAddon(w)
+	}
+}
+
+func kill(b *tk.CodeBuffer, from, to int) {
+	b.Content = b.Content[:from] + b.Content[to:]
+	b.Dot = from
+}
+
+func dotLeft(s string, dot int) int {
+	if sol := strutil.FindLastSOL(s[:dot]); sol < dot {
+		return dot - wcwidth.LastClusterLen(s[sol:dot])
+	}
+	if dot > 0 {
+		// Move over the newline.
+		return dot - 1
+	}
+	return dot
+}
+
+func dotRight(s string, dot int) int {
+	return dot + wcwidth.ClusterLen(s[dot:])
+}
+
+// Returns the start of the whitespace-delimited word to the left of the dot.
+func wordLeft(s string, dot int) int {
+	i := strings.LastIndexFunc(s[:dot], func(r rune) bool { return !unicode.IsSpace(r) })
+	return strings.LastIndexFunc(s[:i+1], unicode.IsSpace) + 1
+}
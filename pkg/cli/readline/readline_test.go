@@ -0,0 +1,180 @@
+package readline
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/cli/clitest"
+	"src.elv.sh/pkg/cli/histutil"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/tt"
+	"src.elv.sh/pkg/ui"
+)
+
+type fixture struct {
+	r      *Reader
+	tty    clitest.TTYCtrl
+	lineCh <-chan string
+	errCh  <-chan error
+	done   bool
+}
+
+func setup(t *testing.T, cfg Config) *fixture {
+	tty, ttyCtrl := clitest.NewFakeTTY()
+	cfg.TTY = tty
+	r := New(cfg)
+	lineCh, errCh := clitest.StartReadCode(r.ReadLine)
+	f := &fixture{r: r, tty: ttyCtrl, lineCh: lineCh, errCh: errCh}
+	t.Cleanup(func() {
+		if !f.done {
+			r.app.CommitEOF()
+			f.wait()
+		}
+	})
+	return f
+}
+
+func (f *fixture) wait() (string, error) {
+	f.done = true
+	return <-f.lineCh, <-f.errCh
+}
+
+func (f *fixture) feed(keys ...any) {
+	for _, key := range keys {
+		switch key := key.(type) {
+		case string:
+			for _, r := range key {
+				f.tty.Inject(term.K(r))
+			}
+		case ui.Key:
+			f.tty.Inject(term.KeyEvent(key))
+		}
+	}
+}
+
+func (f *fixture) testTTY(t *testing.T, args ...any) {
+	t.Helper()
+	_, width := f.tty.Size()
+	f.tty.TestBuffer(t, term.NewBufferBuilder(width).MarkLines(args...).Buffer())
+}
+
+func TestReadLine(t *testing.T) {
+	f := setup(t, Config{Prompt: func() ui.Text { return ui.T("> ") }})
+	f.feed("echo foo", ui.K(ui.Left), ui.K(ui.Backspace), ui.K('A', ui.Ctrl), "x")
+	f.testTTY(t, "> x", term.DotHere, "echo fo")
+
+	f.feed("\n")
+	line, err := f.wait()
+	if line != "xecho fo" || err != nil {
+		t.Errorf("got (%q, %v), want (%q, nil)", line, err, "xecho fo")
+	}
+	cmds, _ := f.r.history.AllCmds()
+	if len(cmds) != 1 || cmds[0].Text != "xecho fo" {
+		t.Errorf("got history %v, want the line read", cmds)
+	}
+}
+
+func TestReadLine_EOF(t *testing.T) {
+	f := setup(t, Config{})
+	f.feed("ab", ui.K(ui.Left), ui.K('D', ui.Ctrl))
+	f.testTTY(t, "a", term.DotHere)
+	f.feed(ui.K('A', ui.Ctrl), ui.K('D', ui.Ctrl), ui.K('D', ui.Ctrl))
+	line, err := f.wait()
+	if line != "" || err != io.EOF {
+		t.Errorf("got (%q, %v), want (\"\", io.EOF)", line, err)
+	}
+}
+
+func TestReadLine_Killing(t *testing.T) {
+	f := setup(t, Config{})
+	f.feed("foo bar  ", ui.K('W', ui.Ctrl))
+	f.testTTY(t, "foo ", term.DotHere)
+	f.feed("baz", ui.K(ui.Home), ui.K(ui.Right), ui.K('K', ui.Ctrl))
+	f.testTTY(t, "f", term.DotHere)
+	f.feed("oo", ui.K(ui.Left), ui.K('U', ui.Ctrl))
+	f.testTTY(t, "", term.DotHere, "o")
+	f.feed(ui.K('C', ui.Ctrl))
+	f.testTTY(t, "", term.DotHere)
+}
+
+func TestReadLine_History(t *testing.T) {
+	f := setup(t, Config{History: histutil.NewMemStore("echo old", "ls")})
+
+	f.feed(ui.K(ui.Up))
+	f.testTTY(t,
+		"ls", clitest.Styles,
+		"__", term.DotHere, "\n",
+		" HISTORY #1 ", clitest.Styles,
+		"************")
+	f.feed(ui.K(ui.Up))
+	f.testTTY(t,
+		"echo old", clitest.Styles,
+		"________", term.DotHere, "\n",
+		" HISTORY #0 ", clitest.Styles,
+		"************")
+	f.feed(ui.K(ui.Down), ui.K(ui.Down))
+	f.testTTY(t, "", term.DotHere)
+	f.feed(ui.K(ui.Up), "\n")
+	line, _ := f.wait()
+	if line != "ls" {
+		t.Errorf("got %q, want %q", line, "ls")
+	}
+}
+
+func TestReadLine_Completion(t *testing.T) {
+	completer := func(code string, dot int) (int, []string) {
+		from := strings.LastIndexByte(code[:dot], ' ') + 1
+		var candidates []string
+		for _, s := range []string{"foo", "foobar", "lorem"} {
+			if strings.HasPrefix(s, code[from:dot]) {
+				candidates = append(candidates, s)
+			}
+		}
+		return from, candidates
+	}
+	f := setup(t, Config{Completer: completer})
+
+	// A single candidate is inserted directly.
+	f.feed("echo l", ui.K(ui.Tab))
+	f.testTTY(t, "echo lorem", term.DotHere)
+
+	// Multiple candidates are shown in a list.
+	f.feed(" f", ui.K(ui.Tab), ui.K(ui.Tab))
+	f.testTTY(t,
+		"echo lorem foobar", clitest.Styles,
+		"           ______", "\n",
+		" COMPLETING   ", clitest.Styles,
+		"************* ", term.DotHere, "\n",
+		"foo  foobar", clitest.Styles,
+		"     ++++++")
+	f.feed("\n\n")
+	line, _ := f.wait()
+	if line != "echo lorem foobar" {
+		t.Errorf("got %q, want %q", line, "echo lorem foobar")
+	}
+}
+
+func TestDotLeftRight(t *testing.T) {
+	tt.Test(t, tt.Fn("dotLeft", dotLeft), tt.Table{
+		tt.Args("ab", 0).Rets(0),
+		tt.Args("ab", 2).Rets(1),
+		tt.Args("a\nb", 2).Rets(1),
+		tt.Args("é", 3).Rets(0),
+	})
+	tt.Test(t, tt.Fn("dotRight", dotRight), tt.Table{
+		tt.Args("ab", 0).Rets(1),
+		tt.Args("ab", 2).Rets(2),
+		tt.Args("é", 0).Rets(3),
+	})
+}
+
+func TestWordLeft(t *testing.T) {
+	tt.Test(t, tt.Fn("wordLeft", wordLeft), tt.Table{
+		tt.Args("", 0).Rets(0),
+		tt.Args("foo", 3).Rets(0),
+		tt.Args("foo bar", 7).Rets(4),
+		tt.Args("foo bar  ", 9).Rets(4),
+		tt.Args("foo bar", 5).Rets(4),
+	})
+}
@@ -0,0 +1,179 @@
+// Package remote runs the frontend of a cli.App, the part that reads events
+// from and writes the UI to the terminal, in a different process from the
+// App itself.
+//
+// The process running the App uses a TTY returned by NewTTY, which forwards
+// all operations over a connection to a process running Serve, which performs
+// them on its own terminal. This is the basis for things like attaching to an
+// interactive session from another terminal, and testing the UI against a
+// real Elvish process.
+//
+// # Protocol
+//
+// The two sides exchange JSON objects, one per line, each with a "type" field
+// and other fields depending on the type. The side running the App sends
+// requests that correspond to the methods of cli.TTY:
+//
+//   - "setup", "restore": set up or restore the terminal.
+//   - "read": read one event.
+//   - "raw-input" with "n": read the next n events raw.
+//   - "close-reader": stop reading events.
+//   - "update" with "notes", "buffer" and "full": update the UI.
+//   - "reset-buffer", "clear-screen", "clear-scrollback", "show-cursor",
+//     "hide-cursor", "bell": self-explanatory.
+//   - "mark" with "mark" and "pos": write a semantic mark.
+//   - "title" with "title": set the title.
+//   - "mouse-tracking" with "on": turn mouse tracking on or off.
+//   - "alt-screen" with "on": switch to or from the alternate screen.
+//   - "clipboard" with "text": copy text to the clipboard with OSC 52.
+//   - "request-cursor-position": ask the terminal to report the cursor
+//     position, which arrives as an "event".
+//   - "notify-signals", "stop-signals": start or stop relaying signals.
+//
+// The side running Serve sends:
+//
+//   - "size" with "height" and "width" when it starts.
+//   - "setup" with "error" in response to "setup".
+//   - "event" with "event" or "error", and "error-kind", in response to
+//     "read".
+//   - "signal" with "signal", "height" and "width" when a signal arrives
+//     between "notify-signals" and "stop-signals".
+//
+// Only SIGHUP, SIGINT, SIGWINCH and SIGCONT are relayed. In particular, SIGTSTP
+// is not relayed, since suspending the process running the App wouldn't
+// suspend the frontend.
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/sys"
+	"src.elv.sh/pkg/ui"
+)
+
+type message struct {
+	Type string `json:"type"`
+
+	// For "update".
+	Notes  *term.Buffer `json:"notes,omitempty"`
+	Buffer *term.Buffer `json:"buffer,omitempty"`
+	Full   bool         `json:"full,omitempty"`
+	// For "mark".
+	Mark string    `json:"mark,omitempty"`
+	Pos  *term.Pos `json:"pos,omitempty"`
+	// For "title".
+	Title string `json:"title,omitempty"`
+	// For "mouse-tracking" and "alt-screen".
+	On bool `json:"on,omitempty"`
+	// For "clipboard".
+	Text string `json:"text,omitempty"`
+	// For "raw-input".
+	N int `json:"n,omitempty"`
+
+	// For "event".
+	Event *event `json:"event,omitempty"`
+	// For "setup" and "event".
+	Error     string `json:"error,omitempty"`
+	ErrorKind string `json:"error-kind,omitempty"`
+	// For "signal".
+	Signal string `json:"signal,omitempty"`
+	// For "size" and "signal".
+	Height int `json:"height,omitempty"`
+	Width  int `json:"width,omitempty"`
+}
+
+// Values of message.ErrorKind.
+const (
+	// The reader was stopped, corresponding to term.ErrStopped.
+	errorStopped = "stopped"
+	// The reader can continue to read events after the error.
+	errorRecoverable = "recoverable"
+)
+
+// An event. At most one of the fields is set; an event with none set
+// corresponds to a nil term.Event.
+type event struct {
+	Key            *ui.Key          `json:"key,omitempty"`
+	Mouse          *term.MouseEvent `json:"mouse,omitempty"`
+	CursorPosition *term.Pos        `json:"cursor-position,omitempty"`
+	Paste          *string          `json:"paste,omitempty"`
+}
+
+func encodeEvent(e term.Event) *event {
+	switch e := e.(type) {
+	case term.KeyEvent:
+		k := ui.Key(e)
+		return &event{Key: &k}
+	case term.MouseEvent:
+		return &event{Mouse: &e}
+	case term.CursorPosition:
+		pos := term.Pos(e)
+		return &event{CursorPosition: &pos}
+	case term.PasteEvent:
+		paste := string(e)
+		return &event{Paste: &paste}
+	}
+	return &event{}
+}
+
+func decodeEvent(e *event) term.Event {
+	switch {
+	case e == nil:
+		return nil
+	case e.Key != nil:
+		return term.KeyEvent(*e.Key)
+	case e.Mouse != nil:
+		return *e.Mouse
+	case e.CursorPosition != nil:
+		return term.CursorPosition(*e.CursorPosition)
+	case e.Paste != nil:
+		return term.PasteEvent(*e.Paste)
+	}
+	return nil
+}
+
+func encodeReadError(err error) (string, string) {
+	switch {
+	case err == term.ErrStopped:
+		return err.Error(), errorStopped
+	case term.IsReadErrorRecoverable(err):
+		return err.Error(), errorRecoverable
+	default:
+		return err.Error(), ""
+	}
+}
+
+var signals = map[string]os.Signal{
+	"SIGHUP":   syscall.SIGHUP,
+	"SIGINT":   syscall.SIGINT,
+	"SIGWINCH": sys.SIGWINCH,
+	"SIGCONT":  sys.SIGCONT,
+}
+
+func signalName(sig os.Signal) (string, bool) {
+	for name, s := range signals {
+		if s == sig {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Wraps a json.Encoder to be safe for concurrent use.
+type encoder struct {
+	mutex sync.Mutex
+	enc   *json.Encoder
+}
+
+func (e *encoder) send(m message) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.enc.Encode(m)
+}
+
+var errClosed = errors.New("connection to frontend closed")
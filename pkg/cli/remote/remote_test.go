@@ -0,0 +1,251 @@
+package remote_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/cli"
+	. "src.elv.sh/pkg/cli/clitest"
+	. "src.elv.sh/pkg/cli/remote"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/sys"
+	"src.elv.sh/pkg/testutil"
+	"src.elv.sh/pkg/ui"
+)
+
+type fixture struct {
+	*Fixture
+	// The TTY of the frontend. Fixture.TTY is not used.
+	front TTYCtrl
+	// Closes the connection from the side of the App.
+	closeConn func()
+	// Delivers the return value of Serve.
+	serveErr <-chan error
+}
+
+// Sets up a fixture whose App uses a frontend connected via a pipe. The
+// functions are called with the fake TTY of the frontend.
+func setup(t *testing.T, fns ...func(*cli.AppSpec, TTYCtrl)) *fixture {
+	frontTTY, front := NewFakeTTY()
+	appConn, frontConn := net.Pipe()
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- Serve(frontConn, frontTTY)
+		frontConn.Close()
+	}()
+	f := Setup(func(spec *cli.AppSpec, _ TTYCtrl) {
+		spec.TTY = NewTTY(appConn)
+		spec.Prompt = cli.NewConstPrompt(ui.T("> "))
+		for _, fn := range fns {
+			fn(spec, front)
+		}
+	})
+	return &fixture{f, front, func() { appConn.Close() }, serveErr}
+}
+
+// Stops ReadCode, closes the connection and waits for Serve to return.
+func (f *fixture) stop(t *testing.T) {
+	t.Helper()
+	f.Stop()
+	f.closeConn()
+	select {
+	case err := <-f.serveErr:
+		if err != nil {
+			t.Errorf("Serve returned error %v", err)
+		}
+	case <-time.After(testutil.Scaled(time.Second)):
+		t.Errorf("Serve didn't return")
+	}
+}
+
+func TestRemote_UpdatesBufferAndReadsEvents(t *testing.T) {
+	f := setup(t)
+	defer f.stop(t)
+
+	f.front.Inject(term.K('a'), term.K('b'))
+	f.front.TestBuffer(t, f.MakeBuffer("> ab", term.DotHere))
+
+	f.App.CommitCode()
+	if code, err := f.Wait(); code != "ab" || err != nil {
+		t.Errorf("got (%q, %v), want (%q, nil)", code, err, "ab")
+	}
+}
+
+func TestRemote_RelaysPastes(t *testing.T) {
+	f := setup(t)
+	defer f.stop(t)
+
+	f.front.Inject(term.PasteEvent("a\nb"))
+	f.front.TestBuffer(t, f.MakeBuffer("> a\n", "  b", term.DotHere))
+
+	f.App.CommitCode()
+	if code, err := f.Wait(); code != "a\nb" || err != nil {
+		t.Errorf("got (%q, %v), want (%q, nil)", code, err, "a\nb")
+	}
+}
+
+func TestRemote_UpdatesNotes(t *testing.T) {
+	f := setup(t)
+	defer f.stop(t)
+
+	f.App.Notify(ui.T("a note"))
+	f.front.TestNotesBuffer(t, f.MakeBuffer("a note"))
+}
+
+func TestRemote_RelaysSizeWithSIGWINCH(t *testing.T) {
+	f := setup(t)
+	defer f.stop(t)
+	f.front.TestBuffer(t, f.MakeBuffer("> ", term.DotHere))
+
+	f.front.SetSize(20, 10)
+	f.front.InjectSignal(sys.SIGWINCH)
+	f.front.TestBuffer(t,
+		term.NewBufferBuilder(10).Write("> ").SetDotHere().Buffer())
+}
+
+func TestRemote_RelaysSIGHUP(t *testing.T) {
+	f := setup(t)
+	defer f.stop(t)
+
+	f.front.InjectSignal(syscall.SIGHUP)
+	if _, err := f.Wait(); err != io.EOF {
+		t.Errorf("got error %v, want io.EOF", err)
+	}
+}
+
+func TestRemote_ReturnsEOFWhenConnectionIsClosed(t *testing.T) {
+	f := setup(t)
+	defer f.stop(t)
+	f.front.TestBuffer(t, f.MakeBuffer("> ", term.DotHere))
+
+	f.closeConn()
+	if _, err := f.Wait(); err != io.EOF {
+		t.Errorf("got error %v, want io.EOF", err)
+	}
+}
+
+func TestRemote_RelaysSetupError(t *testing.T) {
+	f := setup(t, WithTTY(func(tty TTYCtrl) {
+		tty.SetSetup(func() {}, errors.New("fake error"))
+	}))
+	defer f.stop(t)
+
+	if _, err := f.Wait(); err == nil || err.Error() != "fake error" {
+		t.Errorf("got error %v, want fake error", err)
+	}
+}
+
+func TestRemote_RestoresTTY(t *testing.T) {
+	restored := 0
+	f := setup(t, WithTTY(func(tty TTYCtrl) {
+		tty.SetSetup(func() { restored++ }, nil)
+	}))
+
+	f.stop(t)
+	if restored != 1 {
+		t.Errorf("restore called %d times, want once", restored)
+	}
+}
+
+func TestRemote_WritesMarks(t *testing.T) {
+	f := setup(t, WithSpec(func(spec *cli.AppSpec) {
+		spec.SemanticPrompt = func() bool { return true }
+	}))
+
+	f.stop(t)
+	wantMarks := []Mark{
+		{Mark: term.PromptStartMark, Pos: term.Pos{}},
+		{Mark: term.CommandStartMark, Pos: term.Pos{Line: 0, Col: 2}},
+		{Mark: term.OutputStartMark, Pos: term.Pos{Line: 1, Col: 0}},
+	}
+	marks := f.front.Marks()
+	if len(marks) != len(wantMarks) {
+		t.Fatalf("got marks %v, want %v", marks, wantMarks)
+	}
+	for i, mark := range marks {
+		if mark != wantMarks[i] {
+			t.Errorf("got marks %v, want %v", marks, wantMarks)
+		}
+	}
+}
+
+func TestRemote_RingsBell(t *testing.T) {
+	f := setup(t, WithSpec(func(spec *cli.AppSpec) {
+		spec.BellStyle = func() cli.BellStyle { return cli.AudibleBell }
+	}))
+	f.front.TestBuffer(t, f.MakeBuffer("> ", term.DotHere))
+
+	f.App.Bell()
+	f.App.Redraw()
+	// Make sure that the redraw has happened.
+	f.front.Inject(term.K('a'))
+	f.front.TestBuffer(t, f.MakeBuffer("> a", term.DotHere))
+	f.stop(t)
+	if n := f.front.Bells(); n != 1 {
+		t.Errorf("got %d bells, want 1", n)
+	}
+}
+
+func TestRemote_SetsTitle(t *testing.T) {
+	f := setup(t, WithSpec(func(spec *cli.AppSpec) {
+		spec.CodeAreaState.Buffer.Content = "ls"
+		spec.CommandTitle = func() bool { return true }
+	}))
+
+	f.App.CommitCode()
+	f.Wait()
+	f.stop(t)
+	if titles := f.front.Titles(); len(titles) != 1 || titles[0] != "ls" {
+		t.Errorf("got titles %q, want [ls]", titles)
+	}
+}
+
+func TestRemote_RelaysAltScreen(t *testing.T) {
+	f := setup(t, WithSpec(func(spec *cli.AppSpec) {
+		spec.State.Addons = []tk.Widget{altScreenAddon{tk.Label{}}}
+	}))
+	f.front.TestBuffer(t, f.MakeBuffer("> \n", term.DotHere))
+
+	if !f.front.AltScreen() {
+		t.Errorf("alternate screen not used")
+	}
+	f.App.CommitCode()
+	f.Wait()
+	f.stop(t)
+	if f.front.AltScreen() {
+		t.Errorf("alternate screen still used")
+	}
+}
+
+type altScreenAddon struct{ tk.Label }
+
+func (altScreenAddon) AltScreen() bool { return true }
+
+func TestRemote_RelaysMouseTracking(t *testing.T) {
+	f := setup(t, WithSpec(func(spec *cli.AppSpec) {
+		spec.CodeAreaState.Buffer = tk.CodeBuffer{Content: "ab", Dot: 2}
+		spec.MouseTracking = func() bool { return true }
+	}))
+	f.front.TestBuffer(t, f.MakeBuffer("> ab", term.DotHere))
+
+	if !f.front.MouseTracking() {
+		t.Errorf("mouse tracking not turned on")
+	}
+	// Click before "ab", with the UI starting at the first line of the screen.
+	f.front.Inject(
+		term.MouseEvent{Pos: term.Pos{Line: 1, Col: 3}, Down: true, Button: term.LeftButton},
+		term.CursorPosition{Line: 1, Col: 5})
+	f.front.TestBuffer(t, f.MakeBuffer("> ", term.DotHere, "ab"))
+	if n := f.front.CursorPositionRequests(); n != 1 {
+		t.Errorf("got %d cursor position requests, want 1", n)
+	}
+	f.stop(t)
+	if f.front.MouseTracking() {
+		t.Errorf("mouse tracking not turned off")
+	}
+}
@@ -0,0 +1,178 @@
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/term"
+)
+
+// Serve runs the frontend of an App that uses the TTY returned by NewTTY on
+// the other end of conn, performing all the operations on tty. It returns nil
+// when conn reaches EOF, or any other error from reading from conn. Before
+// returning, it restores tty if it has been set up.
+func Serve(conn io.ReadWriter, tty cli.TTY) error {
+	s := &server{tty: tty, enc: &encoder{enc: json.NewEncoder(conn)}}
+	defer s.cleanup()
+
+	h, w := tty.Size()
+	if err := s.enc.send(message{Type: "size", Height: h, Width: w}); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(conn)
+	for {
+		var m message
+		err := dec.Decode(&m)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := s.handle(m); err != nil {
+			return err
+		}
+	}
+}
+
+type server struct {
+	tty cli.TTY
+	enc *encoder
+
+	restore func()
+	// Requests to read events, served by a goroutine started on the first
+	// request.
+	readCh chan struct{}
+	// Whether events have been read since the reader was last closed.
+	reading bool
+	// Whether signals are being relayed, and the goroutine relaying them.
+	relaying bool
+	relayWg  sync.WaitGroup
+}
+
+func (s *server) handle(m message) error {
+	switch m.Type {
+	case "setup":
+		restore, err := s.tty.Setup()
+		s.restore = restore
+		reply := message{Type: "setup"}
+		if err != nil {
+			reply.Error = err.Error()
+		}
+		s.enc.send(reply)
+	case "restore":
+		if s.restore != nil {
+			s.restore()
+			s.restore = nil
+		}
+	case "read":
+		if s.readCh == nil {
+			s.readCh = make(chan struct{}, 1)
+			go s.relayEvents()
+		}
+		s.reading = true
+		s.readCh <- struct{}{}
+	case "raw-input":
+		s.tty.SetRawInput(m.N)
+	case "close-reader":
+		s.closeReader()
+	case "update":
+		s.tty.UpdateBuffer(m.Notes, m.Buffer, m.Full)
+	case "reset-buffer":
+		s.tty.ResetBuffer()
+	case "clear-screen":
+		s.tty.ClearScreen()
+	case "clear-scrollback":
+		s.tty.ClearScrollback()
+	case "show-cursor":
+		s.tty.ShowCursor()
+	case "hide-cursor":
+		s.tty.HideCursor()
+	case "bell":
+		s.tty.Bell()
+	case "mark":
+		if len(m.Mark) != 1 || m.Pos == nil {
+			return fmt.Errorf("bad mark message: %v %v", m.Mark, m.Pos)
+		}
+		s.tty.WriteMark(term.SemanticMark(m.Mark[0]), *m.Pos)
+	case "title":
+		s.tty.SetTitle(m.Title)
+	case "mouse-tracking":
+		s.tty.SetMouseTracking(m.On)
+	case "alt-screen":
+		s.tty.SetAltScreen(m.On)
+	case "clipboard":
+		s.tty.SetClipboard(m.Text)
+	case "request-cursor-position":
+		s.tty.RequestCursorPosition()
+	case "notify-signals":
+		s.startRelayingSignals()
+	case "stop-signals":
+		s.stopRelayingSignals()
+	default:
+		return fmt.Errorf("unknown message type %q", m.Type)
+	}
+	return nil
+}
+
+func (s *server) closeReader() {
+	if s.reading {
+		s.reading = false
+		s.tty.CloseReader()
+	}
+}
+
+func (s *server) relayEvents() {
+	for range s.readCh {
+		event, err := s.tty.ReadEvent()
+		reply := message{Type: "event"}
+		if err == nil {
+			reply.Event = encodeEvent(event)
+		} else {
+			reply.Error, reply.ErrorKind = encodeReadError(err)
+		}
+		s.enc.send(reply)
+	}
+}
+
+func (s *server) startRelayingSignals() {
+	if s.relaying {
+		return
+	}
+	s.relaying = true
+	sigCh := s.tty.NotifySignals()
+	s.relayWg.Add(1)
+	go func() {
+		defer s.relayWg.Done()
+		for sig := range sigCh {
+			name, ok := signalName(sig)
+			if !ok {
+				continue
+			}
+			h, w := s.tty.Size()
+			s.enc.send(message{Type: "signal", Signal: name, Height: h, Width: w})
+		}
+	}()
+}
+
+func (s *server) stopRelayingSignals() {
+	if !s.relaying {
+		return
+	}
+	s.relaying = false
+	s.tty.StopSignals()
+	s.relayWg.Wait()
+}
+
+func (s *server) cleanup() {
+	s.stopRelayingSignals()
+	s.closeReader()
+	if s.readCh != nil {
+		close(s.readCh)
+	}
+	if s.restore != nil {
+		s.restore()
+	}
+}
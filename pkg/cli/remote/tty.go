@@ -0,0 +1,274 @@
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/logutil"
+)
+
+var logger = logutil.GetLogger("[cli/remote] ")
+
+// Size of the channel that NotifySignals returns. Signals that arrive when the
+// channel is full are dropped.
+const sigChSize = 32
+
+type tty struct {
+	enc *encoder
+
+	// Responses to "setup" and "read". Since the App waits for the response
+	// to each request before making the next one, there is at most one
+	// pending response of each type.
+	setupCh chan message
+	eventCh chan message
+	// Closed when the connection is closed.
+	done chan struct{}
+
+	sizeMutex     sync.RWMutex
+	height, width int
+
+	// Guards sending "read" and "close-reader", so that a read is never
+	// requested after the reader is closed. Otherwise the frontend would
+	// start reading again, and the App would be left waiting for a response
+	// that only comes when a key is pressed.
+	readMutex    sync.Mutex
+	readerClosed bool
+
+	sigMutex sync.Mutex
+	sigCh    chan os.Signal
+
+	bufMutex sync.Mutex
+	curBuf   *term.Buffer
+}
+
+// NewTTY returns a cli.TTY that forwards all operations to the frontend
+// connected via conn, which should run Serve.
+//
+// When the connection is closed, the TTY delivers a SIGHUP if it is relaying
+// signals, which makes the App return io.EOF.
+func NewTTY(conn io.ReadWriter) cli.TTY {
+	t := &tty{
+		enc:     &encoder{enc: json.NewEncoder(conn)},
+		setupCh: make(chan message, 1),
+		eventCh: make(chan message, 1),
+		done:    make(chan struct{}),
+		curBuf:  &term.Buffer{},
+	}
+	go t.receive(json.NewDecoder(conn))
+	return t
+}
+
+func (t *tty) receive(dec *json.Decoder) {
+	defer func() {
+		t.sigMutex.Lock()
+		defer t.sigMutex.Unlock()
+		close(t.done)
+		t.sendSignal(syscall.SIGHUP)
+	}()
+	for {
+		var m message
+		err := dec.Decode(&m)
+		if err != nil {
+			if err != io.EOF {
+				logger.Warnf("failed to decode message: %v", err)
+			}
+			return
+		}
+		switch m.Type {
+		case "size":
+			t.setSize(m.Height, m.Width)
+		case "setup":
+			t.setupCh <- m
+		case "event":
+			t.eventCh <- m
+		case "signal":
+			t.setSize(m.Height, m.Width)
+			if sig, ok := signals[m.Signal]; ok {
+				t.sigMutex.Lock()
+				t.sendSignal(sig)
+				t.sigMutex.Unlock()
+			}
+		default:
+			logger.Warnf("unknown message type %q", m.Type)
+		}
+	}
+}
+
+func (t *tty) setSize(h, w int) {
+	t.sizeMutex.Lock()
+	defer t.sizeMutex.Unlock()
+	t.height, t.width = h, w
+}
+
+// Must be called with sigMutex held.
+func (t *tty) sendSignal(sig os.Signal) {
+	if t.sigCh == nil {
+		return
+	}
+	select {
+	case t.sigCh <- sig:
+	default:
+	}
+}
+
+func (t *tty) send(m message) error {
+	select {
+	case <-t.done:
+		return errClosed
+	default:
+	}
+	return t.enc.send(m)
+}
+
+func (t *tty) Setup() (func(), error) {
+	t.readMutex.Lock()
+	t.readerClosed = false
+	t.readMutex.Unlock()
+
+	restore := func() { t.send(message{Type: "restore"}) }
+	if err := t.send(message{Type: "setup"}); err != nil {
+		return restore, err
+	}
+	select {
+	case m := <-t.setupCh:
+		if m.Error != "" {
+			return restore, errors.New(m.Error)
+		}
+		return restore, nil
+	case <-t.done:
+		return restore, errClosed
+	}
+}
+
+func (t *tty) ReadEvent() (term.Event, error) {
+	t.readMutex.Lock()
+	if t.readerClosed {
+		t.readMutex.Unlock()
+		return nil, term.ErrStopped
+	}
+	err := t.send(message{Type: "read"})
+	t.readMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case m := <-t.eventCh:
+		switch {
+		case m.Error == "":
+			return decodeEvent(m.Event), nil
+		case m.ErrorKind == errorStopped:
+			return nil, term.ErrStopped
+		case m.ErrorKind == errorRecoverable:
+			// The concrete type of the error is lost, so return the event
+			// the App would make of it.
+			return term.NonfatalErrorEvent{Err: errors.New(m.Error)}, nil
+		default:
+			return nil, errors.New(m.Error)
+		}
+	case <-t.done:
+		return nil, errClosed
+	}
+}
+
+func (t *tty) SetRawInput(n int) { t.send(message{Type: "raw-input", N: n}) }
+
+func (t *tty) CloseReader() {
+	t.readMutex.Lock()
+	defer t.readMutex.Unlock()
+	t.readerClosed = true
+	t.send(message{Type: "close-reader"})
+}
+
+func (t *tty) Buffer() *term.Buffer {
+	t.bufMutex.Lock()
+	defer t.bufMutex.Unlock()
+	return t.curBuf
+}
+
+func (t *tty) ResetBuffer() {
+	t.resetBuffer()
+	t.send(message{Type: "reset-buffer"})
+}
+
+func (t *tty) resetBuffer() {
+	t.bufMutex.Lock()
+	defer t.bufMutex.Unlock()
+	t.curBuf = &term.Buffer{}
+}
+
+func (t *tty) UpdateBuffer(bufNotes, buf *term.Buffer, full bool) error {
+	err := t.send(message{Type: "update", Notes: bufNotes, Buffer: buf, Full: full})
+	if err != nil {
+		return err
+	}
+	t.bufMutex.Lock()
+	defer t.bufMutex.Unlock()
+	t.curBuf = buf
+	return nil
+}
+
+func (t *tty) ClearScreen() {
+	t.resetBuffer()
+	t.send(message{Type: "clear-screen"})
+}
+
+func (t *tty) ClearScrollback() {
+	t.resetBuffer()
+	t.send(message{Type: "clear-scrollback"})
+}
+
+func (t *tty) ShowCursor() { t.send(message{Type: "show-cursor"}) }
+
+func (t *tty) HideCursor() { t.send(message{Type: "hide-cursor"}) }
+
+func (t *tty) Bell() { t.send(message{Type: "bell"}) }
+
+func (t *tty) WriteMark(mark term.SemanticMark, pos term.Pos) {
+	t.send(message{Type: "mark", Mark: string(rune(mark)), Pos: &pos})
+}
+
+func (t *tty) SetTitle(title string) { t.send(message{Type: "title", Title: title}) }
+
+func (t *tty) SetMouseTracking(on bool) { t.send(message{Type: "mouse-tracking", On: on}) }
+
+func (t *tty) SetAltScreen(on bool) { t.send(message{Type: "alt-screen", On: on}) }
+
+func (t *tty) SetClipboard(text string) { t.send(message{Type: "clipboard", Text: text}) }
+
+func (t *tty) RequestCursorPosition() { t.send(message{Type: "request-cursor-position"}) }
+
+func (t *tty) NotifySignals() <-chan os.Signal {
+	sigCh := make(chan os.Signal, sigChSize)
+	t.sigMutex.Lock()
+	t.sigCh = sigCh
+	select {
+	case <-t.done:
+		t.sendSignal(syscall.SIGHUP)
+	default:
+	}
+	t.sigMutex.Unlock()
+	// Sending can block until the frontend reads the message, so don't hold
+	// sigMutex while doing so; receive needs it.
+	t.send(message{Type: "notify-signals"})
+	return sigCh
+}
+
+func (t *tty) StopSignals() {
+	t.send(message{Type: "stop-signals"})
+	t.sigMutex.Lock()
+	defer t.sigMutex.Unlock()
+	close(t.sigCh)
+	t.sigCh = nil
+}
+
+func (t *tty) Size() (h, w int) {
+	t.sizeMutex.RLock()
+	defer t.sizeMutex.RUnlock()
+	return t.height, t.width
+}
@@ -12,6 +12,9 @@ import (
 type Cell struct {
 	Text  string
 	Style string
+	// If not empty, the URL that the cell links to, written as an OSC 8
+	// hyperlink when the terminal supports it.
+	Link string
 }
 
 // Pos is a line/column position.
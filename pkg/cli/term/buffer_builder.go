@@ -1,7 +1,9 @@
 package term
 
 import (
+	"fmt"
 	"strings"
+	"unicode/utf8"
 
 	"src.elv.sh/pkg/ui"
 	"src.elv.sh/pkg/wcwidth"
@@ -15,6 +17,10 @@ type BufferBuilder struct {
 	// unneessary line breaks, but is is useful when echoing the user input.
 	// will otherwise
 	EagerWrap bool
+	// TabWidth controls how tabs are written. If positive, tabs are expanded
+	// to spaces up to the next tab stop, which are TabWidth columns apart.
+	// Otherwise tabs are written like other control characters.
+	TabWidth int
 	// Lines the content of the buffer.
 	Lines Lines
 	// Dot is what the user perceives as the cursor.
@@ -45,6 +51,11 @@ func (bb *BufferBuilder) SetEagerWrap(v bool) *BufferBuilder {
 	return bb
 }
 
+func (bb *BufferBuilder) SetTabWidth(w int) *BufferBuilder {
+	bb.TabWidth = w
+	return bb
+}
+
 func (bb *BufferBuilder) setDot(dot Pos) *BufferBuilder {
 	bb.Dot = dot
 	return bb
@@ -79,23 +90,36 @@ func (bb *BufferBuilder) Newline() *BufferBuilder {
 }
 
 // WriteRuneSGR writes a single rune to a buffer with an SGR style, wrapping the
-// line when needed. If the rune is a control character, it will be written
-// using the caret notation (like ^X) and gets the additional style of
-// styleForControlChar.
+// line when needed. Tabs are expanded to spaces if TabWidth is positive.
+// Characters that can't be written to the terminal as is are written as
+// placeholders in reverse video: control characters use the caret notation
+// (like ^X), C1 control characters use their code points in hexadecimal (like
+// <9b>), and bytes that are not valid UTF-8 are written as <fffd>.
 func (bb *BufferBuilder) WriteRuneSGR(r rune, style string) *BufferBuilder {
-	if r == '\n' {
+	return bb.writeCluster(string(r), style, "")
+}
+
+// Writes a single grapheme cluster to a buffer with an SGR style and a link, as
+// a single cell. Control characters and invalid bytes always form a grapheme
+// cluster on their own, and are handled like in WriteRuneSGR.
+func (bb *BufferBuilder) writeCluster(s, style, link string) *BufferBuilder {
+	switch {
+	case s == "\n":
 		bb.Newline()
 		return bb
+	case s == "\t" && bb.TabWidth > 0:
+		bb.writeTab(style, link)
+		return bb
 	}
-	c := Cell{string(r), style}
-	if r < 0x20 || r == 0x7f {
-		// Always show control characters in reverse video.
+	c := Cell{s, style, link}
+	if p, ok := placeholder(s); ok {
+		// Always show placeholders in reverse video.
 		if style != "" {
 			style = style + ";7"
 		} else {
 			style = "7"
 		}
-		c = Cell{"^" + string(r^0x40), style}
+		c = Cell{p, style, link}
 	}
 
 	if bb.Col+wcwidth.Of(c.Text) > bb.Width {
@@ -103,13 +127,48 @@ func (bb *BufferBuilder) WriteRuneSGR(r rune, style string) *BufferBuilder {
 		bb.appendCell(c)
 	} else {
 		bb.appendCell(c)
-		if bb.Col == bb.Width && bb.EagerWrap {
-			bb.Newline()
-		}
+		bb.maybeEagerWrap()
 	}
 	return bb
 }
 
+func (bb *BufferBuilder) writeTab(style, link string) {
+	if bb.Col >= bb.Width {
+		bb.Newline()
+	}
+	n := bb.TabWidth - bb.Col%bb.TabWidth
+	if bb.Col+n > bb.Width {
+		// A tab never causes a line to wrap.
+		n = bb.Width - bb.Col
+	}
+	for i := 0; i < n; i++ {
+		bb.appendCell(Cell{" ", style, link})
+	}
+	bb.maybeEagerWrap()
+}
+
+func (bb *BufferBuilder) maybeEagerWrap() {
+	if bb.Col == bb.Width && bb.EagerWrap {
+		bb.Newline()
+	}
+}
+
+// Returns the placeholder for a grapheme cluster that can't be written to the
+// terminal as is.
+func placeholder(s string) (string, bool) {
+	r, size := utf8.DecodeRuneInString(s)
+	switch {
+	case r == utf8.RuneError && size == 1:
+		return "<fffd>", true
+	case r < 0x20 || r == 0x7f:
+		return "^" + string(r^0x40), true
+	case 0x80 <= r && r < 0xa0:
+		return fmt.Sprintf("<%x>", r), true
+	default:
+		return "", false
+	}
+}
+
 // Write is equivalent to calling WriteStyled with ui.T(text, style...).
 func (bb *BufferBuilder) Write(text string, ts ...ui.Styling) *BufferBuilder {
 	return bb.WriteStyled(ui.T(text, ts...))
@@ -135,18 +194,26 @@ func (bb *BufferBuilder) MarkLines(args ...any) *BufferBuilder {
 	return bb.WriteStyled(ui.MarkLines(args...))
 }
 
-// WriteStringSGR writes a string to a buffer with a SGR style.
+// WriteStringSGR writes a string to a buffer with a SGR style. Each grapheme
+// cluster is written as a single cell.
 func (bb *BufferBuilder) WriteStringSGR(text, style string) *BufferBuilder {
-	for _, r := range text {
-		bb.WriteRuneSGR(r, style)
+	return bb.writeString(text, style, "")
+}
+
+func (bb *BufferBuilder) writeString(text, style, link string) *BufferBuilder {
+	for len(text) > 0 {
+		n := wcwidth.ClusterLen(text)
+		bb.writeCluster(text[:n], style, link)
+		text = text[n:]
 	}
 	return bb
 }
 
-// WriteStyled writes a styled text.
+// WriteStyled writes a styled text. The cells written for a segment with a
+// link have that link.
 func (bb *BufferBuilder) WriteStyled(t ui.Text) *BufferBuilder {
 	for _, seg := range t {
-		bb.WriteStringSGR(seg.Text, seg.Style.SGR())
+		bb.writeString(seg.Text, seg.Style.SGR(), seg.Link)
 	}
 	return bb
 }
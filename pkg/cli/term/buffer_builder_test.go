@@ -17,41 +17,70 @@ var bufferBuilderWritesTests = []struct {
 	{NewBufferBuilder(10), "", "", &Buffer{Width: 10, Lines: Lines{Line{}}}},
 	// Writing a single rune.
 	{NewBufferBuilder(10), "a", "1",
-		&Buffer{Width: 10, Lines: Lines{Line{Cell{"a", "1"}}}}},
+		&Buffer{Width: 10, Lines: Lines{Line{Cell{Text: "a", Style: "1"}}}}},
 	// Writing control character.
 	{NewBufferBuilder(10), "\033", "",
-		&Buffer{Width: 10, Lines: Lines{Line{Cell{"^[", "7"}}}}},
+		&Buffer{Width: 10, Lines: Lines{Line{Cell{Text: "^[", Style: "7"}}}}},
 	// Writing styled control character.
 	{NewBufferBuilder(10), "a\033b", "1",
 		&Buffer{Width: 10, Lines: Lines{Line{
-			Cell{"a", "1"},
-			Cell{"^[", "1;7"},
-			Cell{"b", "1"}}}}},
+			Cell{Text: "a", Style: "1"},
+			Cell{Text: "^[", Style: "1;7"},
+			Cell{Text: "b", Style: "1"}}}}},
+	// Writing C1 control character and invalid UTF-8.
+	{NewBufferBuilder(12), "\u009b\xffa", "1",
+		&Buffer{Width: 12, Lines: Lines{Line{
+			Cell{Text: "<9b>", Style: "1;7"},
+			Cell{Text: "<fffd>", Style: "1;7"},
+			Cell{Text: "a", Style: "1"}}}}},
+	// Writing tab without a tab width.
+	{NewBufferBuilder(10), "\t", "",
+		&Buffer{Width: 10, Lines: Lines{Line{Cell{Text: "^I", Style: "7"}}}}},
+	// Writing tabs with a tab width.
+	{NewBufferBuilder(10).SetTabWidth(4), "a\tb\t", "1",
+		&Buffer{Width: 10, Lines: Lines{Line{
+			Cell{Text: "a", Style: "1"}, Cell{Text: " ", Style: "1"}, Cell{Text: " ", Style: "1"}, Cell{Text: " ", Style: "1"},
+			Cell{Text: "b", Style: "1"}, Cell{Text: " ", Style: "1"}, Cell{Text: " ", Style: "1"}, Cell{Text: " ", Style: "1"}}}}},
+	// Writing a tab that would go beyond the width.
+	{NewBufferBuilder(6).SetTabWidth(4), "abcde\tf", "",
+		&Buffer{Width: 6, Lines: Lines{
+			Line{Cell{Text: "a"}, Cell{Text: "b"}, Cell{Text: "c"}, Cell{Text: "d"},
+				Cell{Text: "e"}, Cell{Text: " "}},
+			Line{Cell{Text: "f"}}}}},
 	// Writing text containing a newline.
 	{NewBufferBuilder(10), "a\nb", "1",
 		&Buffer{Width: 10, Lines: Lines{
-			Line{Cell{"a", "1"}}, Line{Cell{"b", "1"}}}}},
+			Line{Cell{Text: "a", Style: "1"}}, Line{Cell{Text: "b", Style: "1"}}}}},
 	// Writing text containing a newline when there is indent.
 	{NewBufferBuilder(10).SetIndent(2), "a\nb", "1",
 		&Buffer{Width: 10, Lines: Lines{
-			Line{Cell{"a", "1"}},
-			Line{Cell{" ", ""}, Cell{" ", ""}, Cell{"b", "1"}},
+			Line{Cell{Text: "a", Style: "1"}},
+			Line{Cell{Text: " "}, Cell{Text: " "}, Cell{Text: "b", Style: "1"}},
 		}}},
 	// Writing long text that triggers wrapping.
 	{NewBufferBuilder(4), "aaaab", "1",
 		&Buffer{Width: 4, Lines: Lines{
-			Line{Cell{"a", "1"}, Cell{"a", "1"}, Cell{"a", "1"}, Cell{"a", "1"}},
-			Line{Cell{"b", "1"}}}}},
+			Line{Cell{Text: "a", Style: "1"}, Cell{Text: "a", Style: "1"}, Cell{Text: "a", Style: "1"}, Cell{Text: "a", Style: "1"}},
+			Line{Cell{Text: "b", Style: "1"}}}}},
 	// Writing long text that triggers wrapping when there is indent.
 	{NewBufferBuilder(4).SetIndent(2), "aaaab", "1",
 		&Buffer{Width: 4, Lines: Lines{
-			Line{Cell{"a", "1"}, Cell{"a", "1"}, Cell{"a", "1"}, Cell{"a", "1"}},
-			Line{Cell{" ", ""}, Cell{" ", ""}, Cell{"b", "1"}}}}},
+			Line{Cell{Text: "a", Style: "1"}, Cell{Text: "a", Style: "1"}, Cell{Text: "a", Style: "1"}, Cell{Text: "a", Style: "1"}},
+			Line{Cell{Text: " "}, Cell{Text: " "}, Cell{Text: "b", Style: "1"}}}}},
+	// Writing a grapheme cluster with a combining mark.
+	{NewBufferBuilder(10), "e\u0301x", "1",
+		&Buffer{Width: 10, Lines: Lines{
+			Line{Cell{Text: "e\u0301", Style: "1"}, Cell{Text: "x", Style: "1"}}}}},
+	// Writing an emoji ZWJ sequence, which is wrapped as a whole.
+	{NewBufferBuilder(4), "aaa\U0001F469\u200D\U0001F4BB", "1",
+		&Buffer{Width: 4, Lines: Lines{
+			Line{Cell{Text: "a", Style: "1"}, Cell{Text: "a", Style: "1"}, Cell{Text: "a", Style: "1"}},
+			Line{Cell{Text: "\U0001F469\u200D\U0001F4BB", Style: "1"}}}}},
 	// Writing long text that triggers eager wrapping.
 	{NewBufferBuilder(4).SetIndent(2).SetEagerWrap(true), "aaaa", "1",
 		&Buffer{Width: 4, Lines: Lines{
-			Line{Cell{"a", "1"}, Cell{"a", "1"}, Cell{"a", "1"}, Cell{"a", "1"}},
-			Line{Cell{" ", ""}, Cell{" ", ""}}}}},
+			Line{Cell{Text: "a", Style: "1"}, Cell{Text: "a", Style: "1"}, Cell{Text: "a", Style: "1"}, Cell{Text: "a", Style: "1"}},
+			Line{Cell{Text: " "}, Cell{Text: " "}}}}},
 }
 
 // TestBufferWrites tests BufferBuilder.Writes by calling Writes on a
@@ -86,8 +115,8 @@ var bufferBuilderTests = []struct {
 			"bar",
 		),
 		&Buffer{Width: 10, Dot: Pos{0, 4}, Lines: Lines{
-			Line{Cell{"f", "4"}, Cell{"o", "4"}, Cell{"o", ""}, Cell{" ", ""}},
-			Line{Cell{"b", ""}, Cell{"a", ""}, Cell{"r", ""}},
+			Line{Cell{Text: "f", Style: "4"}, Cell{Text: "o", Style: "4"}, Cell{Text: "o"}, Cell{Text: " "}},
+			Line{Cell{Text: "b"}, Cell{Text: "a"}, Cell{Text: "r"}},
 		}},
 	},
 }
@@ -106,5 +135,5 @@ func TestBufferBuilder(t *testing.T) {
 func cloneBufferBuilder(bb *BufferBuilder) *BufferBuilder {
 	return &BufferBuilder{
 		bb.Width, bb.Col, bb.Indent,
-		bb.EagerWrap, cloneLines(bb.Lines), bb.Dot}
+		bb.EagerWrap, bb.TabWidth, cloneLines(bb.Lines), bb.Dot}
 }
@@ -10,7 +10,7 @@ var cellsWidthTests = []struct {
 	wantWidth int
 }{
 	{[]Cell{}, 0},
-	{[]Cell{{"a", ""}, {"好", ""}}, 3},
+	{[]Cell{{Text: "a"}, {Text: "好"}}, 3},
 }
 
 func TestCellsWidth(t *testing.T) {
@@ -27,8 +27,8 @@ var makeSpacingTests = []struct {
 	want []Cell
 }{
 	{0, []Cell{}},
-	{1, []Cell{{" ", ""}}},
-	{4, []Cell{{" ", ""}, {" ", ""}, {" ", ""}, {" ", ""}}},
+	{1, []Cell{{Text: " "}}},
+	{4, []Cell{{Text: " "}, {Text: " "}, {Text: " "}, {Text: " "}}},
 }
 
 func TestMakeSpacing(t *testing.T) {
@@ -46,15 +46,15 @@ var compareCellsTests = []struct {
 	wantIndex int
 }{
 	{[]Cell{}, []Cell{}, true, 0},
-	{[]Cell{}, []Cell{{"a", ""}}, false, 0},
+	{[]Cell{}, []Cell{{Text: "a"}}, false, 0},
 	{
-		[]Cell{{"a", ""}, {"好", ""}, {"b", ""}},
-		[]Cell{{"a", ""}, {"好", ""}, {"c", ""}},
+		[]Cell{{Text: "a"}, {Text: "好"}, {Text: "b"}},
+		[]Cell{{Text: "a"}, {Text: "好"}, {Text: "c"}},
 		false, 2,
 	},
 	{
-		[]Cell{{"a", ""}, {"好", ""}, {"b", ""}},
-		[]Cell{{"a", ""}, {"好", "1"}, {"c", ""}},
+		[]Cell{{Text: "a"}, {Text: "好"}, {Text: "b"}},
+		[]Cell{{Text: "a"}, {Text: "好", Style: "1"}, {Text: "c"}},
 		false, 1,
 	},
 }
@@ -79,7 +79,7 @@ var bufferCursorTests = []struct {
 		Pos{0, 0},
 	},
 	{
-		&Buffer{Width: 10, Lines: Lines{Line{Cell{"a", ""}}, Line{Cell{"好", ""}}}},
+		&Buffer{Width: 10, Lines: Lines{Line{Cell{Text: "a"}}, Line{Cell{Text: "好"}}}},
 		Pos{1, 2},
 	},
 }
@@ -125,42 +125,42 @@ var bufferTrimToLinesTests = []struct {
 }{
 	{
 		&Buffer{Width: 10, Lines: Lines{
-			Line{Cell{"a", ""}}, Line{Cell{"b", ""}}, Line{Cell{"c", ""}}, Line{Cell{"d", ""}},
+			Line{Cell{Text: "a"}}, Line{Cell{Text: "b"}}, Line{Cell{Text: "c"}}, Line{Cell{Text: "d"}},
 		}},
 		0, 2,
 		&Buffer{Width: 10, Lines: Lines{
-			Line{Cell{"a", ""}}, Line{Cell{"b", ""}},
+			Line{Cell{Text: "a"}}, Line{Cell{Text: "b"}},
 		}},
 	},
 	// Negative low is treated as 0.
 
 	{
 		&Buffer{Width: 10, Lines: Lines{
-			Line{Cell{"a", ""}}, Line{Cell{"b", ""}}, Line{Cell{"c", ""}}, Line{Cell{"d", ""}},
+			Line{Cell{Text: "a"}}, Line{Cell{Text: "b"}}, Line{Cell{Text: "c"}}, Line{Cell{Text: "d"}},
 		}},
 		-1, 2,
 		&Buffer{Width: 10, Lines: Lines{
-			Line{Cell{"a", ""}}, Line{Cell{"b", ""}},
+			Line{Cell{Text: "a"}}, Line{Cell{Text: "b"}},
 		}},
 	},
 	// With dot.
 	{
 		&Buffer{Width: 10, Lines: Lines{
-			Line{Cell{"a", ""}}, Line{Cell{"b", ""}}, Line{Cell{"c", ""}}, Line{Cell{"d", ""}},
+			Line{Cell{Text: "a"}}, Line{Cell{Text: "b"}}, Line{Cell{Text: "c"}}, Line{Cell{Text: "d"}},
 		}, Dot: Pos{1, 1}},
 		1, 3,
 		&Buffer{Width: 10, Lines: Lines{
-			Line{Cell{"b", ""}}, Line{Cell{"c", ""}},
+			Line{Cell{Text: "b"}}, Line{Cell{Text: "c"}},
 		}, Dot: Pos{0, 1}},
 	},
 	// With dot that is going to be trimmed away.
 	{
 		&Buffer{Width: 10, Lines: Lines{
-			Line{Cell{"a", ""}}, Line{Cell{"b", ""}}, Line{Cell{"c", ""}}, Line{Cell{"d", ""}},
+			Line{Cell{Text: "a"}}, Line{Cell{Text: "b"}}, Line{Cell{Text: "c"}}, Line{Cell{Text: "d"}},
 		}, Dot: Pos{0, 1}},
 		1, 3,
 		&Buffer{Width: 10, Lines: Lines{
-			Line{Cell{"b", ""}}, Line{Cell{"c", ""}},
+			Line{Cell{Text: "b"}}, Line{Cell{Text: "c"}},
 		}, Dot: Pos{0, 1}},
 	},
 }
@@ -184,29 +184,29 @@ var bufferExtendTests = []struct {
 }{
 	{
 		&Buffer{Width: 10, Lines: Lines{
-			Line{Cell{"a", ""}}, Line{Cell{"b", ""}}}},
+			Line{Cell{Text: "a"}}, Line{Cell{Text: "b"}}}},
 		&Buffer{Width: 11, Lines: Lines{
-			Line{Cell{"c", ""}}, Line{Cell{"d", ""}}}},
+			Line{Cell{Text: "c"}}, Line{Cell{Text: "d"}}}},
 		false,
 		&Buffer{Width: 10, Lines: Lines{
-			Line{Cell{"a", ""}}, Line{Cell{"b", ""}},
-			Line{Cell{"c", ""}}, Line{Cell{"d", ""}}}},
+			Line{Cell{Text: "a"}}, Line{Cell{Text: "b"}},
+			Line{Cell{Text: "c"}}, Line{Cell{Text: "d"}}}},
 	},
 	// Moving dot.
 	{
 		&Buffer{Width: 10, Lines: Lines{
-			Line{Cell{"a", ""}}, Line{Cell{"b", ""}}}},
+			Line{Cell{Text: "a"}}, Line{Cell{Text: "b"}}}},
 		&Buffer{
 			Width: 11,
-			Lines: Lines{Line{Cell{"c", ""}}, Line{Cell{"d", ""}}},
+			Lines: Lines{Line{Cell{Text: "c"}}, Line{Cell{Text: "d"}}},
 			Dot:   Pos{1, 1},
 		},
 		true,
 		&Buffer{
 			Width: 10,
 			Lines: Lines{
-				Line{Cell{"a", ""}}, Line{Cell{"b", ""}},
-				Line{Cell{"c", ""}}, Line{Cell{"d", ""}}},
+				Line{Cell{Text: "a"}}, Line{Cell{Text: "b"}},
+				Line{Cell{Text: "c"}}, Line{Cell{Text: "d"}}},
 			Dot: Pos{3, 1},
 		},
 	},
@@ -230,42 +230,42 @@ var bufferExtendRightTests = []struct {
 }{
 	// No padding, equal height.
 	{
-		&Buffer{Width: 1, Lines: Lines{Line{Cell{"a", ""}}, Line{Cell{"b", ""}}}},
-		&Buffer{Width: 1, Lines: Lines{Line{Cell{"c", ""}}, Line{Cell{"d", ""}}}},
+		&Buffer{Width: 1, Lines: Lines{Line{Cell{Text: "a"}}, Line{Cell{Text: "b"}}}},
+		&Buffer{Width: 1, Lines: Lines{Line{Cell{Text: "c"}}, Line{Cell{Text: "d"}}}},
 		&Buffer{Width: 2, Lines: Lines{
-			Line{Cell{"a", ""}, Cell{"c", ""}},
-			Line{Cell{"b", ""}, Cell{"d", ""}}}},
+			Line{Cell{Text: "a"}, Cell{Text: "c"}},
+			Line{Cell{Text: "b"}, Cell{Text: "d"}}}},
 	},
 	// With padding, equal height.
 	{
-		&Buffer{Width: 2, Lines: Lines{Line{Cell{"a", ""}}, Line{Cell{"b", ""}}}},
-		&Buffer{Width: 1, Lines: Lines{Line{Cell{"c", ""}}, Line{Cell{"d", ""}}}},
+		&Buffer{Width: 2, Lines: Lines{Line{Cell{Text: "a"}}, Line{Cell{Text: "b"}}}},
+		&Buffer{Width: 1, Lines: Lines{Line{Cell{Text: "c"}}, Line{Cell{Text: "d"}}}},
 		&Buffer{Width: 3, Lines: Lines{
-			Line{Cell{"a", ""}, Cell{" ", ""}, Cell{"c", ""}},
-			Line{Cell{"b", ""}, Cell{" ", ""}, Cell{"d", ""}}}},
+			Line{Cell{Text: "a"}, Cell{Text: " "}, Cell{Text: "c"}},
+			Line{Cell{Text: "b"}, Cell{Text: " "}, Cell{Text: "d"}}}},
 	},
 	// buf is higher.
 	{
 		&Buffer{Width: 1, Lines: Lines{
-			Line{Cell{"a", ""}}, Line{Cell{"b", ""}}, Line{Cell{"x", ""}}}},
+			Line{Cell{Text: "a"}}, Line{Cell{Text: "b"}}, Line{Cell{Text: "x"}}}},
 		&Buffer{Width: 1, Lines: Lines{
-			Line{Cell{"c", ""}}, Line{Cell{"d", ""}},
+			Line{Cell{Text: "c"}}, Line{Cell{Text: "d"}},
 		}},
 		&Buffer{Width: 2, Lines: Lines{
-			Line{Cell{"a", ""}, Cell{"c", ""}},
-			Line{Cell{"b", ""}, Cell{"d", ""}},
-			Line{Cell{"x", ""}}}},
+			Line{Cell{Text: "a"}, Cell{Text: "c"}},
+			Line{Cell{Text: "b"}, Cell{Text: "d"}},
+			Line{Cell{Text: "x"}}}},
 	},
 	// buf2 is higher.
 	{
-		&Buffer{Width: 1, Lines: Lines{Line{Cell{"a", ""}}, Line{Cell{"b", ""}}}},
+		&Buffer{Width: 1, Lines: Lines{Line{Cell{Text: "a"}}, Line{Cell{Text: "b"}}}},
 		&Buffer{Width: 1, Lines: Lines{
-			Line{Cell{"c", ""}}, Line{Cell{"d", ""}}, Line{Cell{"e", ""}},
+			Line{Cell{Text: "c"}}, Line{Cell{Text: "d"}}, Line{Cell{Text: "e"}},
 		}},
 		&Buffer{Width: 2, Lines: Lines{
-			Line{Cell{"a", ""}, Cell{"c", ""}},
-			Line{Cell{"b", ""}, Cell{"d", ""}},
-			Line{Cell{" ", ""}, Cell{"e", ""}}}},
+			Line{Cell{Text: "a"}, Cell{Text: "c"}},
+			Line{Cell{Text: "b"}, Cell{Text: "d"}},
+			Line{Cell{Text: " "}, Cell{Text: "e"}}}},
 	},
 }
 
@@ -0,0 +1,348 @@
+package term
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Capabilities records which optional features the terminal supports.
+type Capabilities struct {
+	// Whether the capabilities were detected by probing the terminal, rather
+	// than being the defaults.
+	Probed bool
+	// Support for 24-bit colors.
+	TrueColor bool
+	// The size of the palette that 24-bit colors are replaced with when they
+	// are not supported: 256, 16 or 8, or 0 if unknown, in which case the
+	// 256-color palette is used.
+	Colors int
+	// Support for bracketed paste mode.
+	BracketedPaste bool
+	// Support for SGR-style mouse tracking.
+	Mouse bool
+	// Support for synchronized output, which makes the terminal show the
+	// result of a screen update at once.
+	SynchronizedOutput bool
+	// Support for OSC 8 hyperlinks. Cells with links are written as plain
+	// text when this is false.
+	Hyperlinks bool
+	// The terminal multiplexer Elvish is running in, detected from the
+	// environment.
+	Multiplexer Multiplexer
+}
+
+// Multiplexer is a terminal multiplexer. Multiplexers swallow escape sequences
+// they don't understand, so some sequences need to be wrapped to reach the
+// outer terminal.
+type Multiplexer int
+
+// Possible values of Multiplexer.
+const (
+	NoMultiplexer Multiplexer = iota
+	Tmux
+	Screen
+)
+
+// DefaultCapabilities returns the capabilities assumed when the terminal has
+// not been probed, or did not respond to the probe. Features that are known
+// to be harmless on terminals that don't support them are assumed to be
+// supported. Support for colors is decided from $COLORTERM and the terminfo
+// entry for $TERM, and the multiplexer from $TMUX and $STY.
+//
+// Support for hyperlinks can't be probed. Most terminals ignore OSC sequences
+// they don't understand, so hyperlinks are assumed to be supported, except on
+// the Linux console, which shows them as text.
+func DefaultCapabilities(getenv func(string) string) Capabilities {
+	colors := terminfoColors(getenv)
+	return Capabilities{
+		TrueColor: colortermHasTrueColor(getenv("COLORTERM")) ||
+			colors >= directColors,
+		Colors:         paletteSize(colors),
+		BracketedPaste: true,
+		Hyperlinks:     getenv("TERM") != "linux",
+		Multiplexer:    detectMultiplexer(getenv),
+	}
+}
+
+// Returns the size of the palette to use on a terminal with the given number of
+// colors.
+func paletteSize(colors int) int {
+	switch {
+	case colors >= 256:
+		return 256
+	case colors >= 16:
+		return 16
+	case colors >= 8:
+		return 8
+	default:
+		return 0
+	}
+}
+
+func detectMultiplexer(getenv func(string) string) Multiplexer {
+	switch {
+	case getenv("TMUX") != "":
+		return Tmux
+	case getenv("STY") != "":
+		return Screen
+	default:
+		return NoMultiplexer
+	}
+}
+
+func colortermHasTrueColor(colorterm string) bool {
+	return colorterm == "truecolor" || colorterm == "24bit"
+}
+
+var (
+	capsMutex sync.Mutex
+	caps      *Capabilities
+)
+
+// GetCapabilities returns the capabilities that the Writer and Setup use.
+// Before SetCapabilities is called, they are DefaultCapabilities for the
+// current environment.
+func GetCapabilities() Capabilities {
+	capsMutex.Lock()
+	defer capsMutex.Unlock()
+	if caps == nil {
+		c := DefaultCapabilities(os.Getenv)
+		caps = &c
+	}
+	return *caps
+}
+
+// SetCapabilities sets the capabilities that the Writer and Setup use,
+// usually to the result of Probe.
+func SetCapabilities(c Capabilities) {
+	capsMutex.Lock()
+	defer capsMutex.Unlock()
+	caps = &c
+}
+
+// Private modes queried with DECRQM.
+const (
+	modeMouse              = 1006
+	modeBracketedPaste     = 2004
+	modeSynchronizedOutput = 2026
+)
+
+// The probe, written to the terminal. It consists of:
+//
+//  1. Setting the background to a 24-bit color and querying the current SGR
+//     with DECRQSS, which reports the color back only if it is supported;
+//     followed by resetting the SGR.
+//
+//  2. Querying the private modes with DECRQM.
+//
+//  3. Querying the primary device attributes (DA1). Virtually all terminals
+//     respond to it, so its response marks the end of all the responses.
+var probeQuery = "\033[48:2:1:2:3m\033P$qm\033\\\033[m" +
+	"\033[?" + strconv.Itoa(modeMouse) + "$p" +
+	"\033[?" + strconv.Itoa(modeBracketedPaste) + "$p" +
+	"\033[?" + strconv.Itoa(modeSynchronizedOutput) + "$p" +
+	"\033[c"
+
+var (
+	da1Pattern    = regexp.MustCompile("\033\\[\\?[0-9;]*c")
+	decrqssReply  = regexp.MustCompile("\033P([01])\\$r([^\033]*)\033\\\\")
+	decrqmReply   = regexp.MustCompile("\033\\[\\?([0-9]+);([0-9])\\$y")
+	probeResponse = regexp.MustCompile(strings.Join([]string{
+		da1Pattern.String(), decrqssReply.String(), decrqmReply.String()}, "|"))
+)
+
+// Returns whether the data ends the responses to the probe.
+func probeDone(data []byte) bool { return da1Pattern.Match(data) }
+
+// Parses the responses to the probe into capabilities, and returns them along
+// with any data that is not part of the responses, like keys typed while the
+// probe was in progress.
+func parseProbeResponse(data []byte, getenv func(string) string) (Capabilities, []byte) {
+	c := DefaultCapabilities(getenv)
+	if !probeDone(data) {
+		// The terminal doesn't understand the probe, or is too slow to
+		// respond; don't trust any partial response.
+		return c, removeProbeResponses(data)
+	}
+	c.Probed = true
+	for _, m := range decrqssReply.FindAllSubmatch(data, -1) {
+		if string(m[1]) == "1" && strings.Contains(
+			strings.ReplaceAll(string(m[2]), ";", ":"), "1:2:3") {
+			c.TrueColor = true
+		}
+	}
+	for _, m := range decrqmReply.FindAllSubmatch(data, -1) {
+		mode, _ := strconv.Atoi(string(m[1]))
+		// 1 and 2 mean that the mode is set and reset respectively, and 3
+		// means that it is permanently set; 0 means that the mode is not
+		// recognized, and 4 means that it is permanently reset. Terminals that
+		// don't support DECRQM won't respond at all, in which case the
+		// defaults are kept.
+		supported := m[2][0] == '1' || m[2][0] == '2' || m[2][0] == '3'
+		switch mode {
+		case modeMouse:
+			c.Mouse = supported
+		case modeBracketedPaste:
+			c.BracketedPaste = supported
+		case modeSynchronizedOutput:
+			c.SynchronizedOutput = supported
+		}
+	}
+	return c, removeProbeResponses(data)
+}
+
+func removeProbeResponses(data []byte) []byte {
+	rest := probeResponse.ReplaceAll(data, nil)
+	if len(rest) == 0 {
+		return nil
+	}
+	return rest
+}
+
+var sgrTrueColor = regexp.MustCompile(`(^|;)([34]8);2;([0-9]+);([0-9]+);([0-9]+)`)
+
+// Replaces the colors in an SGR sequence that the terminal doesn't support with
+// the closest colors that it does.
+func downgradeColors(sgr string, c Capabilities) string {
+	switch {
+	case c.TrueColor:
+		return sgr
+	case c.Colors == 0 || c.Colors == 256:
+		return downgradeTrueColor(sgr)
+	default:
+		return downgradeToBasicColors(sgr, c.Colors)
+	}
+}
+
+// Replaces 24-bit colors in an SGR sequence with the closest color in the
+// 256-color palette.
+func downgradeTrueColor(sgr string) string {
+	if !strings.Contains(sgr, "8;2;") {
+		return sgr
+	}
+	return sgrTrueColor.ReplaceAllStringFunc(sgr, func(s string) string {
+		m := sgrTrueColor.FindStringSubmatch(s)
+		r, _ := strconv.Atoi(m[3])
+		g, _ := strconv.Atoi(m[4])
+		b, _ := strconv.Atoi(m[5])
+		return m[1] + m[2] + ";5;" + strconv.Itoa(closest256Color(r, g, b))
+	})
+}
+
+// Levels of each component in the 6x6x6 color cube of the 256-color palette.
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// Returns the index of the color in the 6x6x6 color cube or the grayscale
+// ramp of the 256-color palette that is closest to the given color.
+func closest256Color(r, g, b int) int {
+	toCube := func(v int) int {
+		if v < 48 {
+			return 0
+		} else if v < 115 {
+			return 1
+		}
+		return (v - 35) / 40
+	}
+	cr, cg, cb := toCube(r), toCube(g), toCube(b)
+	cubeIndex := 16 + 36*cr + 6*cg + cb
+	cubeDist := sqDist(r, g, b, cubeLevels[cr], cubeLevels[cg], cubeLevels[cb])
+
+	// The grayscale ramp has 24 levels from 8 to 238.
+	avg := (r + g + b) / 3
+	grayLevel := 23
+	if avg < 238 {
+		grayLevel = (avg - 3) / 10
+		if grayLevel < 0 {
+			grayLevel = 0
+		}
+	}
+	gray := 8 + 10*grayLevel
+	if sqDist(r, g, b, gray, gray, gray) < cubeDist {
+		return 232 + grayLevel
+	}
+	return cubeIndex
+}
+
+var sgrExtendedColor = regexp.MustCompile(
+	`(^|;)([34])8;(?:2;([0-9]+);([0-9]+);([0-9]+)|5;([0-9]+))`)
+
+// Replaces 24-bit colors and colors from the 256-color palette in an SGR
+// sequence with the closest of the first n basic colors, where n is 8 or 16.
+func downgradeToBasicColors(sgr string, n int) string {
+	if !strings.Contains(sgr, "8;") {
+		return sgr
+	}
+	return sgrExtendedColor.ReplaceAllStringFunc(sgr, func(s string) string {
+		m := sgrExtendedColor.FindStringSubmatch(s)
+		var r, g, b int
+		if m[6] != "" {
+			i, _ := strconv.Atoi(m[6])
+			if i < n {
+				return m[1] + basicColorSGR(m[2], i)
+			}
+			r, g, b = xterm256RGB(i)
+		} else {
+			r, _ = strconv.Atoi(m[3])
+			g, _ = strconv.Atoi(m[4])
+			b, _ = strconv.Atoi(m[5])
+		}
+		return m[1] + basicColorSGR(m[2], closestBasicColor(r, g, b, n))
+	})
+}
+
+// Returns the SGR code for the i-th basic color, where kind is "3" for the
+// foreground and "4" for the background.
+func basicColorSGR(kind string, i int) string {
+	if i < 8 {
+		return kind + strconv.Itoa(i)
+	}
+	// Bright colors are 90-97 for the foreground and 100-107 for the
+	// background.
+	if kind == "3" {
+		return "9" + strconv.Itoa(i-8)
+	}
+	return "10" + strconv.Itoa(i-8)
+}
+
+// The 16 basic colors as shown by xterm by default. Other terminals use
+// slightly different colors, but the differences don't matter for finding the
+// closest color.
+var basicColors = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// Returns the index of the closest color among the first n basic colors.
+func closestBasicColor(r, g, b, n int) int {
+	best, bestDist := 0, -1
+	for i, c := range basicColors[:n] {
+		if d := sqDist(r, g, b, c[0], c[1], c[2]); bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// Returns the components of the i-th color in the 256-color palette.
+func xterm256RGB(i int) (r, g, b int) {
+	switch {
+	case i < 16:
+		c := basicColors[i]
+		return c[0], c[1], c[2]
+	case i < 232:
+		i -= 16
+		return cubeLevels[i/36], cubeLevels[i/6%6], cubeLevels[i%6]
+	default:
+		gray := 8 + 10*(i-232)
+		return gray, gray, gray
+	}
+}
+
+func sqDist(r1, g1, b1, r2, g2, b2 int) int {
+	return (r1-r2)*(r1-r2) + (g1-g2)*(g1-g2) + (b1-b2)*(b1-b2)
+}
@@ -0,0 +1,148 @@
+package term
+
+import (
+	"reflect"
+	"testing"
+)
+
+func getenvFrom(m map[string]string) func(string) string {
+	return func(name string) string { return m[name] }
+}
+
+var parseProbeResponseTests = []struct {
+	name     string
+	data     string
+	env      map[string]string
+	wantCaps Capabilities
+	wantRest string
+}{
+	{
+		name:     "no response",
+		data:     "",
+		wantCaps: Capabilities{BracketedPaste: true, Hyperlinks: true},
+	},
+	{
+		name:     "no response, with COLORTERM",
+		data:     "",
+		env:      map[string]string{"COLORTERM": "truecolor"},
+		wantCaps: Capabilities{TrueColor: true, BracketedPaste: true, Hyperlinks: true},
+	},
+	{
+		name:     "no response, in tmux",
+		data:     "",
+		env:      map[string]string{"TMUX": "/tmp/tmux-1000/default,1,0"},
+		wantCaps: Capabilities{BracketedPaste: true, Hyperlinks: true, Multiplexer: Tmux},
+	},
+	{
+		name:     "no response, in screen",
+		data:     "",
+		env:      map[string]string{"STY": "1234.pts-0.host"},
+		wantCaps: Capabilities{BracketedPaste: true, Hyperlinks: true, Multiplexer: Screen},
+	},
+	{
+		name:     "only DA1",
+		data:     "\033[?62;22c",
+		wantCaps: Capabilities{Probed: true, BracketedPaste: true, Hyperlinks: true},
+	},
+	{
+		name: "all supported",
+		data: "\033P1$r0;48:2:1:2:3m\033\\" +
+			"\033[?1006;2$y\033[?2004;2$y\033[?2026;2$y\033[?62;22c",
+		wantCaps: Capabilities{Probed: true, TrueColor: true,
+			BracketedPaste: true, Mouse: true, SynchronizedOutput: true,
+			Hyperlinks: true},
+	},
+	{
+		name: "true color with semicolons",
+		data: "\033P1$r48;2;1;2;3m\033\\\033[?62c",
+		wantCaps: Capabilities{Probed: true, TrueColor: true,
+			BracketedPaste: true, Hyperlinks: true},
+	},
+	{
+		name: "none supported",
+		data: "\033P0$r\033\\" +
+			"\033[?1006;0$y\033[?2004;4$y\033[?2026;0$y\033[?1;2c",
+		wantCaps: Capabilities{Probed: true, Hyperlinks: true},
+	},
+	{
+		name:     "SGR reported without true color",
+		data:     "\033P1$r0m\033\\\033[?1;2c",
+		wantCaps: Capabilities{Probed: true, BracketedPaste: true, Hyperlinks: true},
+	},
+	{
+		name: "input mixed with responses",
+		data: "a\033[?2026;1$yb\033[?62cc",
+		wantCaps: Capabilities{Probed: true, BracketedPaste: true,
+			SynchronizedOutput: true, Hyperlinks: true},
+		wantRest: "abc",
+	},
+	{
+		name:     "partial response",
+		data:     "\033[?2026;1$yx",
+		wantCaps: Capabilities{BracketedPaste: true, Hyperlinks: true},
+		wantRest: "x",
+	},
+}
+
+func TestParseProbeResponse(t *testing.T) {
+	for _, tc := range parseProbeResponseTests {
+		t.Run(tc.name, func(t *testing.T) {
+			caps, rest := parseProbeResponse([]byte(tc.data), getenvFrom(tc.env))
+			if !reflect.DeepEqual(caps, tc.wantCaps) {
+				t.Errorf("got caps %+v, want %+v", caps, tc.wantCaps)
+			}
+			if string(rest) != tc.wantRest {
+				t.Errorf("got rest %q, want %q", rest, tc.wantRest)
+			}
+		})
+	}
+}
+
+var downgradeTrueColorTests = []struct {
+	sgr  string
+	want string
+}{
+	{"1;31", "1;31"},
+	{"38;2;255;0;0", "38;5;196"},
+	{"1;48;2;0;0;0", "1;48;5;16"},
+	{"38;2;128;128;128;48;2;255;255;255", "38;5;244;48;5;231"},
+	{"38;5;100", "38;5;100"},
+}
+
+func TestDowngradeTrueColor(t *testing.T) {
+	for _, tc := range downgradeTrueColorTests {
+		if got := downgradeTrueColor(tc.sgr); got != tc.want {
+			t.Errorf("downgradeTrueColor(%q) -> %q, want %q", tc.sgr, got, tc.want)
+		}
+	}
+}
+
+var downgradeColorsTests = []struct {
+	sgr  string
+	caps Capabilities
+	want string
+}{
+	{"38;2;255;0;0", Capabilities{TrueColor: true, Colors: 8}, "38;2;255;0;0"},
+	{"38;2;255;0;0", Capabilities{}, "38;5;196"},
+	{"38;2;255;0;0", Capabilities{Colors: 256}, "38;5;196"},
+	{"38;2;255;0;0", Capabilities{Colors: 16}, "91"},
+	{"38;2;255;0;0", Capabilities{Colors: 8}, "31"},
+	{"1;48;2;250;250;250", Capabilities{Colors: 16}, "1;107"},
+	{"48;2;250;250;250", Capabilities{Colors: 8}, "47"},
+	// Colors from the 256-color palette.
+	{"38;5;100", Capabilities{}, "38;5;100"},
+	{"38;5;3;48;5;12", Capabilities{Colors: 16}, "33;104"},
+	{"48;5;12", Capabilities{Colors: 8}, "44"},
+	{"38;5;196", Capabilities{Colors: 16}, "91"},
+	{"38;5;232", Capabilities{Colors: 16}, "30"},
+	// Basic colors are kept.
+	{"1;31;103", Capabilities{Colors: 8}, "1;31;103"},
+}
+
+func TestDowngradeColors(t *testing.T) {
+	for _, tc := range downgradeColorsTests {
+		if got := downgradeColors(tc.sgr, tc.caps); got != tc.want {
+			t.Errorf("downgradeColors(%q, %+v) -> %q, want %q", tc.sgr, tc.caps, got, tc.want)
+		}
+	}
+}
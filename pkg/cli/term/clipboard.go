@@ -0,0 +1,79 @@
+package term
+
+import (
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// ClipboardConfig keeps the configuration of the clipboard used by
+// CopyToClipboard and PasteFromClipboard.
+type ClipboardConfig struct {
+	// An external command that copies its input to the clipboard, like pbcopy
+	// or "xclip -selection clipboard", used for terminals that don't support
+	// OSC 52. If empty, text is copied with OSC 52.
+	CopyCommand []string
+	// An external command that writes the content of the clipboard to its
+	// output, like pbpaste or "xclip -selection clipboard -o". If empty, the
+	// text last copied with CopyToClipboard is pasted.
+	PasteCommand []string
+}
+
+var (
+	clipboardMutex  sync.RWMutex
+	clipboardConfig ClipboardConfig
+	// The text last copied with CopyToClipboard.
+	lastCopied string
+)
+
+// GetClipboardConfig returns the configuration of the clipboard.
+func GetClipboardConfig() ClipboardConfig {
+	clipboardMutex.RLock()
+	defer clipboardMutex.RUnlock()
+	return clipboardConfig
+}
+
+// SetClipboardConfig sets the configuration of the clipboard.
+func SetClipboardConfig(c ClipboardConfig) {
+	clipboardMutex.Lock()
+	defer clipboardMutex.Unlock()
+	clipboardConfig = c
+}
+
+// CopyToClipboard copies text to the system clipboard. It runs the copy
+// command with text as its input if one is configured, and otherwise asks the
+// terminal to copy text with OSC 52 by writing to w.
+func CopyToClipboard(w Writer, text string) error {
+	clipboardMutex.Lock()
+	lastCopied = text
+	command := clipboardConfig.CopyCommand
+	clipboardMutex.Unlock()
+	if len(command) == 0 {
+		w.SetClipboard(text)
+		return nil
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	// The output is not captured, since commands like xclip keep running in
+	// the background to serve the clipboard, and would keep the pipes open.
+	return cmd.Run()
+}
+
+// PasteFromClipboard returns the content of the system clipboard, as written
+// by the paste command. If no paste command is configured, it returns the text
+// last copied with CopyToClipboard instead, since most terminals don't allow
+// reading the clipboard with OSC 52.
+func PasteFromClipboard() (string, error) {
+	clipboardMutex.RLock()
+	text := lastCopied
+	command := clipboardConfig.PasteCommand
+	clipboardMutex.RUnlock()
+	if len(command) == 0 {
+		return text, nil
+	}
+	out, err := exec.Command(command[0], command[1:]...).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
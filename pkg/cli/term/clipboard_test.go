@@ -0,0 +1,28 @@
+package term
+
+import (
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestClipboard_OSC52(t *testing.T) {
+	testutil.Set(t, &clipboardConfig, ClipboardConfig{})
+	testutil.Set(t, &lastCopied, "")
+	testutil.Set(t, &caps, &Capabilities{})
+	sb := &strings.Builder{}
+
+	err := CopyToClipboard(NewWriter(sb), "echo foo")
+	if err != nil {
+		t.Errorf("CopyToClipboard -> %v, want nil", err)
+	}
+	if want := "\033]52;c;ZWNobyBmb28=\007"; sb.String() != want {
+		t.Errorf("got %q written, want %q", sb.String(), want)
+	}
+	// Without a paste command, the text last copied is pasted.
+	text, err := PasteFromClipboard()
+	if text != "echo foo" || err != nil {
+		t.Errorf("PasteFromClipboard -> (%q, %v), want (%q, nil)", text, err, "echo foo")
+	}
+}
@@ -0,0 +1,45 @@
+//go:build !windows && !plan9 && !js
+
+package term
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestClipboard_Commands(t *testing.T) {
+	testutil.InTempDir(t)
+	testutil.Set(t, &clipboardConfig, ClipboardConfig{
+		CopyCommand:  []string{"sh", "-c", "cat > clipboard"},
+		PasteCommand: []string{"cat", "clipboard"},
+	})
+	testutil.Set(t, &lastCopied, "")
+	sb := &strings.Builder{}
+
+	err := CopyToClipboard(NewWriter(sb), "echo foo")
+	if err != nil {
+		t.Errorf("CopyToClipboard -> %v, want nil", err)
+	}
+	if sb.String() != "" {
+		t.Errorf("got %q written, want nothing", sb.String())
+	}
+	if content := must.ReadFileString("clipboard"); content != "echo foo" {
+		t.Errorf("copy command got %q, want %q", content, "echo foo")
+	}
+
+	must.WriteFile("clipboard", "echo bar")
+	text, err := PasteFromClipboard()
+	if text != "echo bar" || err != nil {
+		t.Errorf("PasteFromClipboard -> (%q, %v), want (%q, nil)", text, err, "echo bar")
+	}
+
+	os.Remove("clipboard")
+	_, err = PasteFromClipboard()
+	if err == nil {
+		t.Errorf("PasteFromClipboard -> nil error, want error when the command fails")
+	}
+}
@@ -26,12 +26,23 @@ type MouseEvent struct {
 	Mod    ui.Mod
 }
 
+// Values of MouseEvent.Button.
+const (
+	LeftButton = iota
+	MiddleButton
+	RightButton
+	// Scrolling the wheel up or down is reported as pressing these buttons.
+	WheelUpButton
+	WheelDownButton
+)
+
 // CursorPosition represents a report of the current cursor position from the
 // terminal driver, usually as a response from a cursor position request.
 type CursorPosition Pos
 
-// PasteSetting indicates the start or finish of pasted text.
-type PasteSetting bool
+// PasteEvent carries text pasted into the terminal, as delivered by bracketed
+// paste. The whole text is delivered at once, with newlines converted to "\n".
+type PasteEvent string
 
 // FatalErrorEvent represents an error that affects the Reader's ability to
 // continue reading events. After sending a FatalError, the Reader makes no more
@@ -48,7 +59,7 @@ func (KeyEvent) isEvent()   {}
 func (MouseEvent) isEvent() {}
 
 func (CursorPosition) isEvent() {}
-func (PasteSetting) isEvent()   {}
+func (PasteEvent) isEvent()     {}
 
 func (FatalErrorEvent) isEvent()    {}
 func (NonfatalErrorEvent) isEvent() {}
@@ -41,6 +41,30 @@ type bReader struct {
 func (r *bReader) ReadByteWithTimeout(timeout time.Duration) (byte, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	// Return input read by Probe first.
+	if b, ok := takePendingInput(); ok {
+		return b, nil
+	}
+	for {
+		b, err := r.readByte(timeout)
+		if err != nil || b != '\033' || !lateProbeResponsePossible() {
+			return b, err
+		}
+		seq, err := readEscapeSequence(r.readByte, b)
+		if err == ErrStopped {
+			addPendingInput(seq)
+			return 0, err
+		}
+		if !dropLateProbeResponse(seq) {
+			addPendingInput(seq[1:])
+			return b, nil
+		}
+	}
+}
+
+// Reads a byte from the file, blocking until it is available, the timeout is
+// reached or the reader is stopped.
+func (r *bReader) readByte(timeout time.Duration) (byte, error) {
 	for {
 		ready, err := eunix.WaitForRead(timeout, r.file, r.rStop)
 		if err != nil {
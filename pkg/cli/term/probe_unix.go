@@ -0,0 +1,185 @@
+//go:build !windows && !plan9
+
+package term
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"src.elv.sh/pkg/sys/eunix"
+)
+
+// Probe queries the terminal for its capabilities, waiting at most timeout for
+// the responses. If the terminal doesn't respond in time, it returns
+// DefaultCapabilities; responses that arrive later are removed from the input
+// by the Reader, and update the capabilities once they are complete.
+//
+// The terminal is put in raw mode while probing. Input that is not part of the
+// responses, like keys typed during the probe, is kept and returned by the
+// Reader later.
+func Probe(in, out *os.File, timeout time.Duration) Capabilities {
+	fd := int(in.Fd())
+	term, err := eunix.TermiosForFd(fd)
+	if err != nil {
+		return DefaultCapabilities(os.Getenv)
+	}
+	savedTermios := term.Copy()
+	term.SetICanon(false)
+	term.SetEcho(false)
+	term.SetVMin(1)
+	term.SetVTime(0)
+	if err := term.ApplyToFd(fd); err != nil {
+		return DefaultCapabilities(os.Getenv)
+	}
+	defer savedTermios.ApplyToFd(fd)
+
+	fr, err := newFileReader(in)
+	if err != nil {
+		return DefaultCapabilities(os.Getenv)
+	}
+	defer fr.Close()
+	if _, err := out.WriteString(probeQuery); err != nil {
+		return DefaultCapabilities(os.Getenv)
+	}
+	data := readProbeResponse(fr, timeout)
+	c, rest := parseProbeResponse(data, os.Getenv)
+	addPendingInput(rest)
+	if !c.Probed {
+		expectLateProbeResponse(data)
+	}
+	return c
+}
+
+// Reads the responses to the probe, until the end of the responses is seen or
+// the timeout is reached.
+func readProbeResponse(rd byteReaderWithTimeout, timeout time.Duration) []byte {
+	deadline := time.Now().Add(timeout)
+	var data []byte
+	for !probeDone(data) {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+		b, err := rd.ReadByteWithTimeout(remaining)
+		if err != nil {
+			break
+		}
+		data = append(data, b)
+	}
+	return data
+}
+
+var (
+	pendingInputMutex sync.Mutex
+	pendingInput      []byte
+)
+
+func addPendingInput(data []byte) {
+	pendingInputMutex.Lock()
+	defer pendingInputMutex.Unlock()
+	pendingInput = append(pendingInput, data...)
+}
+
+func takePendingInput() (byte, bool) {
+	pendingInputMutex.Lock()
+	defer pendingInputMutex.Unlock()
+	if len(pendingInput) == 0 {
+		return 0, false
+	}
+	b := pendingInput[0]
+	pendingInput = pendingInput[1:]
+	return b, true
+}
+
+// How long responses to the probe are still expected after Probe has given up
+// waiting for them.
+const lateProbeWindow = 10 * time.Second
+
+// How long to wait for each byte of an escape sequence that may be a late
+// response to the probe. Terminals write each response at once, so this only
+// matters for a lone ESC typed by the user.
+const lateProbeByteTimeout = 50 * time.Millisecond
+
+var (
+	lateProbeMutex     sync.Mutex
+	lateProbeDeadline  time.Time
+	lateProbeResponses []byte
+
+	wholeProbeResponse = regexp.MustCompile("^(?:" + probeResponse.String() + ")$")
+)
+
+// Records that responses to the probe may still arrive, along with the
+// responses that already have.
+func expectLateProbeResponse(data []byte) {
+	lateProbeMutex.Lock()
+	defer lateProbeMutex.Unlock()
+	lateProbeDeadline = time.Now().Add(lateProbeWindow)
+	lateProbeResponses = bytes.Join(probeResponse.FindAll(data, -1), nil)
+}
+
+func lateProbeResponsePossible() bool {
+	lateProbeMutex.Lock()
+	defer lateProbeMutex.Unlock()
+	return time.Now().Before(lateProbeDeadline)
+}
+
+// Drops the escape sequence if it is a late response to the probe, and returns
+// whether it did. When the response to DA1 is seen, the capabilities are
+// updated from all the responses and no more responses are expected.
+func dropLateProbeResponse(seq []byte) bool {
+	lateProbeMutex.Lock()
+	defer lateProbeMutex.Unlock()
+	if !time.Now().Before(lateProbeDeadline) || !wholeProbeResponse.Match(seq) {
+		return false
+	}
+	lateProbeResponses = append(lateProbeResponses, seq...)
+	if probeDone(lateProbeResponses) {
+		c, _ := parseProbeResponse(lateProbeResponses, os.Getenv)
+		SetCapabilities(c)
+		lateProbeDeadline = time.Time{}
+		lateProbeResponses = nil
+	}
+	return true
+}
+
+// Reads the rest of the CSI or DCS sequence started by esc, giving up when the
+// next byte doesn't arrive in time. It returns all the bytes read, including
+// esc. The error is non-nil only if the reader has been stopped.
+func readEscapeSequence(read func(time.Duration) (byte, error), esc byte) ([]byte, error) {
+	seq := []byte{esc}
+	var stopErr error
+	next := func() bool {
+		b, err := read(lateProbeByteTimeout)
+		if err != nil {
+			if err == ErrStopped {
+				stopErr = err
+			}
+			return false
+		}
+		seq = append(seq, b)
+		return true
+	}
+	if !next() {
+		return seq, stopErr
+	}
+	switch seq[1] {
+	case '[':
+		// CSI sequences end with a byte in the range 0x40 to 0x7e.
+		for next() {
+			if b := seq[len(seq)-1]; 0x40 <= b && b <= 0x7e {
+				break
+			}
+		}
+	case 'P':
+		// DCS sequences end with ST.
+		for next() {
+			if bytes.HasSuffix(seq, []byte("\033\\")) {
+				break
+			}
+		}
+	}
+	return seq, stopErr
+}
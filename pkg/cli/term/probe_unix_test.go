@@ -0,0 +1,110 @@
+//go:build !windows && !plan9
+
+package term
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/creack/pty"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestProbe(t *testing.T) {
+	testutil.Setenv(t, "COLORTERM", "")
+	testutil.Setenv(t, "TMUX", "")
+	testutil.Setenv(t, "STY", "")
+	testutil.Setenv(t, "TERM", "")
+	pty, tty, err := pty.Open()
+	if err != nil {
+		t.Skip("cannot open pty for testing Probe")
+	}
+	defer pty.Close()
+	defer tty.Close()
+
+	// Act as the terminal: respond to the probe once it has been received,
+	// with a key typed before the response.
+	go func() {
+		var query []byte
+		buf := make([]byte, 64)
+		for !bytes.HasSuffix(query, []byte("\033[c")) {
+			n, err := pty.Read(buf)
+			if err != nil {
+				return
+			}
+			query = append(query, buf[:n]...)
+		}
+		pty.WriteString("x\033[?2026;2$y\033[?1;2c")
+	}()
+
+	caps := Probe(tty, tty, time.Second)
+	wantCaps := Capabilities{Probed: true, BracketedPaste: true,
+		SynchronizedOutput: true, Hyperlinks: true}
+	if !reflect.DeepEqual(caps, wantCaps) {
+		t.Errorf("got %+v, want %+v", caps, wantCaps)
+	}
+
+	// The key typed during the probe is kept for the reader.
+	rd := NewReader(tty)
+	defer rd.Close()
+	event, err := rd.ReadEvent()
+	if event != K('x') || err != nil {
+		t.Errorf("got (%v, %v), want (%v, nil)", event, err, K('x'))
+	}
+}
+
+func TestProbe_Timeout(t *testing.T) {
+	testutil.Set(t, &lateProbeDeadline, time.Time{})
+	pty, tty, err := pty.Open()
+	if err != nil {
+		t.Skip("cannot open pty for testing Probe")
+	}
+	defer pty.Close()
+	defer tty.Close()
+
+	caps := Probe(tty, tty, 10*time.Millisecond)
+	if caps.Probed {
+		t.Errorf("got Probed = true, want false")
+	}
+}
+
+func TestProbe_LateResponse(t *testing.T) {
+	testutil.Setenv(t, "COLORTERM", "")
+	testutil.Setenv(t, "TMUX", "")
+	testutil.Setenv(t, "STY", "")
+	testutil.Setenv(t, "TERM", "")
+	testutil.Set(t, &caps, nil)
+	testutil.Set(t, &lateProbeDeadline, time.Time{})
+	pty, tty, err := pty.Open()
+	if err != nil {
+		t.Skip("cannot open pty for testing Probe")
+	}
+	defer pty.Close()
+	defer tty.Close()
+
+	c := Probe(tty, tty, 10*time.Millisecond)
+	if c.Probed {
+		t.Errorf("got Probed = true, want false")
+	}
+	SetCapabilities(c)
+
+	restore, err := Setup(tty, tty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+	// The terminal responds after the probe has given up; the responses are
+	// not read as keys, but update the capabilities.
+	pty.WriteString("\033[?2026;2$y\033[?1;2cx")
+	rd := NewReader(tty)
+	defer rd.Close()
+	event, err := rd.ReadEvent()
+	if event != K('x') || err != nil {
+		t.Errorf("got (%v, %v), want (%v, nil)", event, err, K('x'))
+	}
+	if !GetCapabilities().SynchronizedOutput {
+		t.Errorf("capabilities not updated from late response")
+	}
+}
@@ -0,0 +1,12 @@
+package term
+
+import (
+	"os"
+	"time"
+)
+
+// Probe returns the capabilities currently in use, since the Windows console
+// can't be probed with escape sequences.
+func Probe(in, out *os.File, timeout time.Duration) Capabilities {
+	return GetCapabilities()
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sync"
+	"time"
 )
 
 // Reader reads events from the terminal.
@@ -21,6 +23,46 @@ type Reader interface {
 	Close()
 }
 
+// ReaderConfig keeps the configuration of how Reader decodes escape sequences.
+// It only applies to terminals that send escape sequences, so it has no effect
+// on Windows.
+type ReaderConfig struct {
+	// How long to wait for each byte following an ESC in an escape sequence.
+	// An ESC that is not followed by anything within the timeout is taken as
+	// the Escape key.
+	KeySeqTimeout time.Duration
+	// Whether to treat an ESC that is not followed by anything within
+	// KeySeqTimeout as a prefix meaning Alt. If true, the Reader waits for
+	// the next key and reports it with the Alt modifier, and the Escape key
+	// itself can only be sent by pressing it twice.
+	ESCAsMeta bool
+}
+
+// DefaultReaderConfig is the initial configuration of Reader. Modern terminal
+// emulators send escape sequences very fast, so 10ms is more than sufficient
+// for the timeout, but SSH connections on a slow link may need more.
+var DefaultReaderConfig = ReaderConfig{KeySeqTimeout: 10 * time.Millisecond}
+
+var (
+	readerConfigMutex sync.RWMutex
+	readerConfig      = DefaultReaderConfig
+)
+
+// GetReaderConfig returns the configuration that Reader uses.
+func GetReaderConfig() ReaderConfig {
+	readerConfigMutex.RLock()
+	defer readerConfigMutex.RUnlock()
+	return readerConfig
+}
+
+// SetReaderConfig sets the configuration that Reader uses. It takes effect
+// from the next call to ReadEvent.
+func SetReaderConfig(c ReaderConfig) {
+	readerConfigMutex.Lock()
+	defer readerConfigMutex.Unlock()
+	readerConfig = c
+}
+
 // ErrStopped is returned by Reader when Close is called during a ReadEvent or
 // ReadRawEvent method.
 var ErrStopped = errors.New("stopped")
@@ -3,8 +3,11 @@
 package term
 
 import (
+	"bytes"
 	"os"
-	"time"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"src.elv.sh/pkg/ui"
 )
@@ -40,25 +43,23 @@ func (rd *reader) Close() {
 // Used by readRune in readOne to signal end of current sequence.
 const runeEndOfSeq rune = -1
 
-// Timeout for bytes in escape sequences. Modern terminal emulators send escape
-// sequences very fast, so 10ms is more than sufficient. SSH connections on a
-// slow link might be problematic though.
-var keySeqTimeout = 10 * time.Millisecond
-
 func readEvent(rd byteReaderWithTimeout) (event Event, err error) {
 	var r rune
 	r, err = readRune(rd, -1)
 	if err != nil {
 		return
 	}
+	cfg := GetReaderConfig()
+	// Used with ESCAsMeta to wait for the key after a lone ESC.
+	waitRune := func() (rune, error) { return readRune(rd, -1) }
 
 	currentSeq := string(r)
-	// Attempts to read a rune within a timeout of keySeqTimeout. It returns
+	// Attempts to read a rune within a timeout of cfg.KeySeqTimeout. It returns
 	// runeEndOfSeq if there is any error; the caller should terminate the
 	// current sequence when it sees that value.
 	readRune :=
 		func() rune {
-			r, e := readRune(rd, keySeqTimeout)
+			r, e := readRune(rd, cfg.KeySeqTimeout)
 			if e != nil {
 				return runeEndOfSeq
 			}
@@ -84,6 +85,23 @@ func readEvent(rd byteReaderWithTimeout) (event Event, err error) {
 			r2 = readRune()
 		}
 		if r2 == runeEndOfSeq {
+			if cfg.ESCAsMeta && !hasTwoLeadingESC {
+				// Nothing follows yet. Wait for the next key and take it as
+				// Alt-modified; it can't start an escape sequence. A second
+				// ESC is taken as the Escape key.
+				r2, err = waitRune()
+				if err != nil {
+					return
+				}
+				if r2 == 0x1b {
+					event = KeyEvent{'[', ui.Ctrl}
+					break
+				}
+				k := ctrlModify(r2)
+				k.Mod |= ui.Alt
+				event = KeyEvent(k)
+				break
+			}
 			// TODO(xiaq): Error is swallowed.
 			// Nothing follows. Taken as a lone Escape.
 			event = KeyEvent{'[', ui.Ctrl}
@@ -125,7 +143,9 @@ func readEvent(rd byteReaderWithTimeout) (event Event, err error) {
 				}
 				down := true
 				button := int(cb & 3)
-				if button == 3 {
+				if cb&64 != 0 {
+					button += WheelUpButton
+				} else if button == 3 {
 					down = false
 					button = -1
 				}
@@ -170,11 +190,19 @@ func readEvent(rd byteReaderWithTimeout) (event Event, err error) {
 				}
 				down := r == 'M'
 				button := nums[0] & 3
+				if nums[0]&64 != 0 {
+					button += WheelUpButton
+				}
 				mod := mouseModify(nums[0])
 				event = MouseEvent{Pos{nums[2], nums[1]}, down, button, mod}
-			} else if r == '~' && len(nums) == 1 && (nums[0] == 200 || nums[0] == 201) {
-				b := nums[0] == 200
-				event = PasteSetting(b)
+			} else if r == '~' && len(nums) == 1 && nums[0] == 200 {
+				var text string
+				text, err = readPaste(rd)
+				if err == nil {
+					event = PasteEvent(text)
+				}
+			} else if r == '~' && len(nums) == 1 && nums[0] == 201 {
+				badSeq("end of paste without start")
 			} else {
 				k := parseCSI(nums, r, currentSeq)
 				if k == (ui.Key{}) {
@@ -194,7 +222,18 @@ func readEvent(rd byteReaderWithTimeout) (event Event, err error) {
 				event = KeyEvent{'O', ui.Alt}
 				return
 			}
+			// Some terminals insert the modifier as a number before the
+			// rune, like \eO2P for Shift-F1.
+			mod := 0
+			for '0' <= r && r <= '9' {
+				mod = mod*10 + int(r-'0')
+				r = readRune()
+			}
 			k, ok := g3Seq[r]
+			if ok && mod > 0 {
+				k = xtermModify(k, mod, currentSeq)
+				ok = k != (ui.Key{})
+			}
 			if ok {
 				if hasTwoLeadingESC {
 					k.Mod |= ui.Alt
@@ -216,6 +255,27 @@ func readEvent(rd byteReaderWithTimeout) (event Event, err error) {
 	return
 }
 
+// Sequence that ends a bracketed paste.
+const pasteEnd = "\033[201~"
+
+// Reads the text of a bracketed paste after the sequence starting it. The
+// text is read as is, without decoding any escape sequences in it, until the
+// sequence ending it; no timeout applies, since pasting a long text can take a
+// while.
+func readPaste(rd byteReaderWithTimeout) (string, error) {
+	var buf []byte
+	for !bytes.HasSuffix(buf, []byte(pasteEnd)) {
+		b, err := rd.ReadByteWithTimeout(-1)
+		if err != nil {
+			return "", err
+		}
+		buf = append(buf, b)
+	}
+	// Terminals send pasted newlines like the Enter key, as \r.
+	text := strings.ReplaceAll(string(buf[:len(buf)-len(pasteEnd)]), "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n"), nil
+}
+
 // Determines whether a rune corresponds to a Ctrl-modified key and returns the
 // ui.Key the rune represents.
 func ctrlModify(r rune) ui.Key {
@@ -247,8 +307,10 @@ func ctrlModify(r rune) ui.Key {
 // G3-style key sequences: \eO followed by exactly one character. For instance,
 // \eOP is F1. These are pretty limited in that they cannot be extended to
 // support modifier keys, other than a leading \e for Alt (e.g. \e\eOP is
-// Alt-F1). Terminals that send G3-style key sequences typically switch to
-// sending a CSI-style key sequence when a non-Alt modifier key is pressed.
+// Alt-F1), or a modifier number inserted by some older terminals (e.g. \eO2P
+// is Shift-F1; see xtermModify). Terminals that send G3-style key sequences
+// typically switch to sending a CSI-style key sequence when a non-Alt modifier
+// key is pressed.
 var g3Seq = map[rune]ui.Key{
 	// xterm, tmux -- only in Vim, depends on termios setting?
 	// NOTE(xiaq): According to urxvt's manpage, \eO[ABCD] sequences are used for
@@ -256,7 +318,9 @@ var g3Seq = map[rune]ui.Key{
 	// urxvt 9.22 packaged by Debian; those keys simply send the same sequence
 	// as Ctrl-modified keys (\eO[abcd]).
 	'A': ui.K(ui.Up), 'B': ui.K(ui.Down), 'C': ui.K(ui.Right), 'D': ui.K(ui.Left),
-	'H': ui.K(ui.Home), 'F': ui.K(ui.End), 'M': ui.K(ui.Insert),
+	'H': ui.K(ui.Home), 'F': ui.K(ui.End),
+	// xterm, urxvt -- the keypad Enter key in application keypad mode
+	'M': ui.K(ui.KeypadEnter),
 	// urxvt
 	'a': ui.K(ui.Up, ui.Ctrl), 'b': ui.K(ui.Down, ui.Ctrl),
 	'c': ui.K(ui.Right, ui.Ctrl), 'd': ui.K(ui.Left, ui.Ctrl),
@@ -288,6 +352,9 @@ var csiSeqByLast = map[rune]ui.Key{
 	'c': ui.K(ui.Right, ui.Shift), 'd': ui.K(ui.Left, ui.Shift),
 	// xterm (Terminal.app only sends those in alternate screen)
 	'H': ui.K(ui.Home), 'F': ui.K(ui.End),
+	// xterm, libvte and kitty, when F1 to F4 are modified (e.g. \e[1;2P for
+	// Shift-F1); unmodified, they are sent as G3-style sequences.
+	'P': ui.K(ui.F1), 'Q': ui.K(ui.F2), 'R': ui.K(ui.F3), 'S': ui.K(ui.F4),
 	// xterm, urxvt, tmux
 	'Z': ui.K(ui.Tab, ui.Shift),
 }
@@ -319,26 +386,76 @@ var csiSeqTilde = map[int]rune{
 	// NOTE: 16 and 22 are unused
 	15: ui.F5, 17: ui.F6, 18: ui.F7, 19: ui.F8,
 	20: ui.F9, 21: ui.F10, 23: ui.F11, 24: ui.F12,
+	// urxvt, Linux console (which send them for Shift-F3 to Shift-F10)
+	// NOTE: 27 and 30 are unused
+	25: ui.F13, 26: ui.F14, 28: ui.F15, 29: ui.F16,
+	31: ui.F17, 32: ui.F18, 33: ui.F19, 34: ui.F20,
 }
 
 // CSI-style key sequences ending with '~', with the first argument always 27,
-// the second argument identifying the modifier, and the third argument
-// identifying the key. For instance, \e[27;5;9~ is Ctrl-Tab.
+// the second argument identifying the modifier, and the third argument being
+// the codepoint of the key, decoded by codepointKey. For instance, \e[27;5;9~
+// is Ctrl-Tab. They are sent by xterm when the modifyOtherKeys resource is
+// enabled.
 //
-// NOTE(xiaq): The list is taken blindly from xterm-keys.c in the tmux source
-// tree. I do not have a keyboard-terminal combination that generate such
-// sequences, but assumably they are generated by some terminals for numpad
-// inputs.
-var csiSeqTilde27 = map[int]rune{
-	9: '\t', 13: '\r',
-	33: '!', 35: '#', 39: '\'', 40: '(', 41: ')', 43: '+', 44: ',', 45: '-',
-	46: '.',
-	48: '0', 49: '1', 50: '2', 51: '3', 52: '4', 53: '5', 54: '6', 55: '7',
-	56: '8', 57: '9',
-	58: ':', 59: ';', 60: '<', 61: '=', 62: '>', 63: ';',
+// A more compact form of the same information is \e[9;5u, with the codepoint
+// as the first argument and the optional modifier as the second argument. It
+// is sent by xterm when the formatOtherKeys resource is also enabled, and by
+// kitty, foot and other terminals implementing the "fixterms" proposal or the
+// kitty keyboard protocol.
+
+// Codepoints that kitty uses for keys without a Unicode representation. See
+// https://sw.kovidgoyal.net/kitty/keyboard-protocol/#functional-key-definitions.
+var csiFunctionalKeys = map[int]rune{
+	57376: ui.F13, 57377: ui.F14, 57378: ui.F15, 57379: ui.F16,
+	57380: ui.F17, 57381: ui.F18, 57382: ui.F19, 57383: ui.F20,
+	57384: ui.F21, 57385: ui.F22, 57386: ui.F23, 57387: ui.F24,
+	57414: ui.KeypadEnter,
+	57417: ui.KeypadLeft, 57418: ui.KeypadRight,
+	57419: ui.KeypadUp, 57420: ui.KeypadDown,
 }
 
-// parseCSI parses a CSI-style key sequence. See comments above for all the 3
+// Decodes a key identified by its codepoint and an xterm-style modifier,
+// normalizing it like ui.ParseKey would, so that it matches the binding for
+// the key's name.
+func codepointKey(code, mod int, seq string) ui.Key {
+	var k ui.Key
+	switch {
+	case csiFunctionalKeys[code] != 0:
+		k = ui.K(csiFunctionalKeys[code])
+	case code == '\r':
+		k = ui.K(ui.Enter)
+	case code == 0x1b:
+		k = ui.K('[', ui.Ctrl)
+	case code == '\t' || code == ui.Backspace:
+		k = ui.K(rune(code))
+	case code >= 0x20 && utf8.ValidRune(rune(code)) && !unicode.In(rune(code), unicode.Co):
+		// Other codepoints in the private use area are keys that Elvish
+		// doesn't know about.
+		k = ui.K(rune(code))
+	default:
+		return ui.Key{}
+	}
+	k = xtermModify(k, mod, seq)
+	if k.Mod&ui.Shift != 0 && 'a' <= k.Rune && k.Rune <= 'z' {
+		// Shift-a is just A.
+		k.Rune += 'A' - 'a'
+		k.Mod &^= ui.Shift
+	}
+	if k.Mod&ui.Ctrl != 0 {
+		if 'a' <= k.Rune && k.Rune <= 'z' {
+			k.Rune += 'A' - 'a'
+		}
+		if k.Rune == 'I' {
+			k = ui.K(ui.Tab, k.Mod&^ui.Ctrl)
+		} else if k.Rune == 'J' {
+			k = ui.K(ui.Enter, k.Mod&^ui.Ctrl)
+		}
+	}
+	return k
+}
+
+// parseCSI parses a CSI-style key sequence. See comments above for all the
 // variants this function handles.
 func parseCSI(nums []int, last rune, seq string) ui.Key {
 	if k, ok := csiSeqByLast[last]; ok {
@@ -366,10 +483,16 @@ func parseCSI(nums []int, last rune, seq string) ui.Key {
 				return xtermModify(k, nums[1], seq)
 			}
 		} else if len(nums) == 3 && nums[0] == 27 {
-			if r, ok := csiSeqTilde27[nums[2]]; ok {
-				k := ui.K(r)
-				return xtermModify(k, nums[1], seq)
-			}
+			// Modified: \e[27;5;9~ (Ctrl-Tab)
+			return codepointKey(nums[2], nums[1], seq)
+		}
+	case 'u':
+		if len(nums) == 1 {
+			// Unmodified: \e[57376u (F13)
+			return codepointKey(nums[0], 0, seq)
+		} else if len(nums) == 2 {
+			// Modified: \e[13;2u (Shift-Enter)
+			return codepointKey(nums[0], nums[1], seq)
 		}
 	case '$', '^', '@':
 		// Modified by urxvt; see comment above csiSeqTilde.
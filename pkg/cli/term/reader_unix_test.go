@@ -6,8 +6,10 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/testutil"
 	"src.elv.sh/pkg/ui"
 )
 
@@ -88,14 +90,50 @@ var readEventTests = []struct {
 	// CSI-sequence key with three arguments and ending in '~'. The first
 	// argument is always 27, the second identifies the modifier and the last
 	// identifies the key.
-	{"\033[27;4;63~", K(';', ui.Shift, ui.Alt)},
+	{"\033[27;4;63~", K('?', ui.Shift, ui.Alt)},
+	{"\033[27;5;9~", K(ui.Tab, ui.Ctrl)},
+	{"\033[27;2;13~", K(ui.Enter, ui.Shift)},
+	// Keys are normalized like in key names.
+	{"\033[27;5;97~", K('A', ui.Ctrl)},
+	{"\033[27;2;97~", K('A')},
+	{"\033[27;5;105~", K(ui.Tab)},
+
+	// CSI-sequence key with the codepoint as the first argument and the
+	// optional modifier as the second, ending in 'u'.
+	{"\033[97;5u", K('A', ui.Ctrl)},
+	{"\033[13;2u", K(ui.Enter, ui.Shift)},
+	{"\033[127;5u", K(ui.Backspace, ui.Ctrl)},
+	{"\033[27u", K('[', ui.Ctrl)},
+	{"\033[228;3u", K('ä', ui.Alt)},
+	// Codepoints for keys without a Unicode representation.
+	{"\033[57376u", K(ui.F13)},
+	{"\033[57387;5u", K(ui.F24, ui.Ctrl)},
+	{"\033[57414u", K(ui.KeypadEnter)},
+	{"\033[57419;2u", K(ui.KeypadUp, ui.Shift)},
+	{"\033[57417u", K(ui.KeypadLeft)},
+
+	// Modified F1 to F4.
+	{"\033[1;2P", K(ui.F1, ui.Shift)},
+	{"\033[1;5S", K(ui.F4, ui.Ctrl)},
+	// F13 to F20.
+	{"\033[25~", K(ui.F13)},
+	{"\033[34;5~", K(ui.F20, ui.Ctrl)},
+
+	// G3-style key with a modifier number.
+	{"\033O2P", K(ui.F1, ui.Shift)},
+	{"\033O5A", K(ui.Up, ui.Ctrl)},
+	// Keypad Enter in application keypad mode.
+	{"\033OM", K(ui.KeypadEnter)},
 
 	// Cursor Position Report.
 	{"\033[3;4R", CursorPosition{3, 4}},
 
-	// Paste setting.
-	{"\033[200~", PasteSetting(true)},
-	{"\033[201~", PasteSetting(false)},
+	// Bracketed paste.
+	{"\033[200~\033[201~", PasteEvent("")},
+	{"\033[200~foo\033[201~", PasteEvent("foo")},
+	// Newlines are converted, and escape sequences are kept as is.
+	{"\033[200~a\rb\r\nc\033[Ad\033[201~", PasteEvent("a\nb\nc\033[Ad")},
+	{"\033[200~你好\033[201~", PasteEvent("你好")},
 
 	// Mouse event.
 	{"\033[M\x00\x23\x24", MouseEvent{Pos{4, 3}, true, 0, 0}},
@@ -108,6 +146,9 @@ var readEventTests = []struct {
 	{"\033[M\x08\x23\x24", MouseEvent{Pos{4, 3}, true, 0, ui.Alt}},
 	{"\033[M\x10\x23\x24", MouseEvent{Pos{4, 3}, true, 0, ui.Ctrl}},
 	{"\033[M\x14\x23\x24", MouseEvent{Pos{4, 3}, true, 0, ui.Shift | ui.Ctrl}},
+	// Wheel events.
+	{"\033[M\x60\x23\x24", MouseEvent{Pos{4, 3}, true, WheelUpButton, 0}},
+	{"\033[M\x61\x23\x24", MouseEvent{Pos{4, 3}, true, WheelDownButton, 0}},
 
 	// SGR-style mouse event.
 	{"\033[<0;3;4M", MouseEvent{Pos{4, 3}, true, 0, 0}},
@@ -118,6 +159,9 @@ var readEventTests = []struct {
 	// Modified.
 	{"\033[<4;3;4M", MouseEvent{Pos{4, 3}, true, 0, ui.Shift}},
 	{"\033[<16;3;4M", MouseEvent{Pos{4, 3}, true, 0, ui.Ctrl}},
+	// SGR-style wheel events.
+	{"\033[<64;3;4M", MouseEvent{Pos{4, 3}, true, WheelUpButton, 0}},
+	{"\033[<65;3;4M", MouseEvent{Pos{4, 3}, true, WheelDownButton, 0}},
 }
 
 func TestReader_ReadEvent(t *testing.T) {
@@ -151,6 +195,9 @@ var readEventBadSeqTests = []struct {
 	{"\033[;", "incomplete CSI"},
 	{"\033[1;", "incomplete CSI"},
 
+	// End of paste should follow a start of paste
+	{"\033[201~", "end of paste without start"},
+
 	// CPR should have exactly 2 parameters
 	{"\033[1R", "bad CPR"},
 	{"\033[1;2;3R", "bad CPR"},
@@ -166,9 +213,14 @@ var readEventBadSeqTests = []struct {
 	{"\033[1;17A", "bad CSI"},
 	// unknown CSI terminator
 	{"\033[x", "bad CSI"},
+	// codepoints in the private use area that are not known keys
+	{"\033[57344u", "bad CSI"},
+	// codepoints of control characters other than Tab, Enter and Escape
+	{"\033[1;5u", "bad CSI"},
 
 	// G3 allows a small list of allowed bytes after \033O
 	{"\033Ox", "bad G3"},
+	{"\033O2x", "bad G3"},
 }
 
 func TestReader_ReadEvent_BadSeq(t *testing.T) {
@@ -210,6 +262,74 @@ func TestReader_ReadRawEvent(t *testing.T) {
 	}
 }
 
+func TestReader_ReadEvent_KeySeqTimeout(t *testing.T) {
+	r, w := setupReader(t)
+	setReaderConfig(t, ReaderConfig{KeySeqTimeout: testutil.Scaled(time.Second)})
+
+	// With a long timeout, a slow escape sequence is still decoded as one key.
+	writeSlowly(w, "\033", "[A")
+	testReadEvent(t, r, K(ui.Up))
+
+	// With a short timeout, it is split.
+	setReaderConfig(t, ReaderConfig{KeySeqTimeout: time.Millisecond})
+	writeSlowly(w, "\033", "a")
+	testReadEvent(t, r, K('[', ui.Ctrl))
+	testReadEvent(t, r, K('a'))
+}
+
+func TestReader_ReadEvent_SlowPaste(t *testing.T) {
+	r, w := setupReader(t)
+	setReaderConfig(t, ReaderConfig{KeySeqTimeout: time.Millisecond})
+
+	// The timeout doesn't apply to pasted text.
+	writeSlowly(w, "\033[200~a\033", "b\033[201~")
+	testReadEvent(t, r, PasteEvent("a\033b"))
+}
+
+func TestReader_ReadEvent_ESCAsMeta(t *testing.T) {
+	r, w := setupReader(t)
+	setReaderConfig(t, ReaderConfig{KeySeqTimeout: time.Millisecond, ESCAsMeta: true})
+
+	writeSlowly(w, "\033", "a")
+	testReadEvent(t, r, K('a', ui.Alt))
+	// A key that would start an escape sequence is taken literally.
+	writeSlowly(w, "\033", "[")
+	testReadEvent(t, r, K('[', ui.Alt))
+	// Pressing ESC twice sends Escape, whether the second one is slow or not.
+	writeSlowly(w, "\033", "\033")
+	testReadEvent(t, r, K('[', ui.Ctrl))
+	w.WriteString("\033\033")
+	testReadEvent(t, r, K('[', ui.Ctrl))
+	// Escape sequences and Alt keys that arrive in time are not affected.
+	w.WriteString("\033[A")
+	testReadEvent(t, r, K(ui.Up))
+	w.WriteString("\033b")
+	testReadEvent(t, r, K('b', ui.Alt))
+}
+
+func setReaderConfig(t *testing.T, c ReaderConfig) {
+	SetReaderConfig(c)
+	t.Cleanup(func() { SetReaderConfig(DefaultReaderConfig) })
+}
+
+// Writes the first string, and the rest after a delay longer than the short
+// timeouts used in tests.
+func writeSlowly(w *os.File, first string, rest string) {
+	w.WriteString(first)
+	go func() {
+		time.Sleep(testutil.Scaled(50 * time.Millisecond))
+		w.WriteString(rest)
+	}()
+}
+
+func testReadEvent(t *testing.T, r Reader, want Event) {
+	t.Helper()
+	ev, err := r.ReadEvent()
+	if ev != want || err != nil {
+		t.Errorf("got (%v, %v), want (%v, nil)", ev, err, want)
+	}
+}
+
 func setupReader(t *testing.T) (Reader, *os.File) {
 	pr, pw := must.Pipe()
 	r := NewReader(pr)
@@ -108,7 +108,8 @@ var keyCodeToRune = map[uint16]rune{
 	/* 0x60 - 0x6f: numpads; currently ignored */
 	0x70: ui.F1, 0x71: ui.F2, 0x72: ui.F3, 0x73: ui.F4, 0x74: ui.F5, 0x75: ui.F6,
 	0x76: ui.F7, 0x77: ui.F8, 0x78: ui.F9, 0x79: ui.F10, 0x7a: ui.F11, 0x7b: ui.F12,
-	/* 0x7c - 0x87: F13 - F24; currently ignored */
+	0x7c: ui.F13, 0x7d: ui.F14, 0x7e: ui.F15, 0x7f: ui.F16, 0x80: ui.F17, 0x81: ui.F18,
+	0x82: ui.F19, 0x83: ui.F20, 0x84: ui.F21, 0x85: ui.F22, 0x86: ui.F23, 0x87: ui.F24,
 	0xba: ';', 0xbb: '=', 0xbc: ',', 0xbd: '-', 0xbe: '.', 0xbf: '/', 0xc0: '`',
 	0xdb: '[', 0xdc: '\\', 0xdd: ']', 0xde: '\'',
 }
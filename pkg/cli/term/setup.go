@@ -22,9 +22,8 @@ func SetupForEval(in, out *os.File) func() {
 }
 
 const (
-	lackEOLRune    = '\u23ce'
-	lackEOL        = "\033[7m" + string(lackEOLRune) + "\033[m"
-	enableSGRMouse = false
+	lackEOLRune = '\u23ce'
+	lackEOL     = "\033[7m" + string(lackEOLRune) + "\033[m"
 )
 
 // setupVT performs setup for VT-like terminals.
@@ -66,13 +65,10 @@ func setupVT(out *os.File) error {
 	*/
 	s += "\033[?7l"
 
-	// Turn on SGR-style mouse tracking.
-	if enableSGRMouse {
-		s += "\033[?1000;1006h"
-	}
-
 	// Enable bracketed paste.
-	s += "\033[?2004h"
+	if GetCapabilities().BracketedPaste {
+		s += "\033[?2004h"
+	}
 
 	_, err := out.WriteString(s)
 	return err
@@ -83,12 +79,10 @@ func restoreVT(out *os.File) error {
 	s := ""
 	// Turn on autowrap.
 	s += "\033[?7h"
-	// Turn off mouse tracking.
-	if enableSGRMouse {
-		s += "\033[?1000;1006l"
-	}
 	// Disable bracketed paste.
-	s += "\033[?2004l"
+	if GetCapabilities().BracketedPaste {
+		s += "\033[?2004l"
+	}
 	// Move the cursor to the first row, even if we haven't written anything
 	// visible. This is because the terminal driver might not be smart enough to
 	// recognize some escape sequences as invisible and wrongly assume that we
@@ -0,0 +1,100 @@
+package term
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Magic numbers of compiled terminfo entries, with 16-bit and 32-bit numeric
+// capabilities respectively.
+const (
+	terminfoMagic16 = 0o432
+	terminfoMagic32 = 0o1036
+)
+
+// Index of the "colors" capability among the numeric capabilities.
+const terminfoColorsIndex = 13
+
+// Number of colors of terminals that support 24-bit colors, like xterm-direct.
+const directColors = 1 << 24
+
+// Returns the number of colors the terminal supports, as recorded in the
+// terminfo entry for $TERM, or 0 if the entry can't be found or doesn't record
+// it. Directories are searched in the same order as ncurses.
+func terminfoColors(getenv func(string) string) int {
+	name := getenv("TERM")
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return 0
+	}
+	var dirs []string
+	if dir := getenv("TERMINFO"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	if home := getenv("HOME"); home != "" {
+		dirs = append(dirs, filepath.Join(home, ".terminfo"))
+	}
+	for _, dir := range strings.Split(getenv("TERMINFO_DIRS"), ":") {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	dirs = append(dirs,
+		"/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo", "/usr/lib/terminfo")
+	for _, dir := range dirs {
+		// Entries are kept in subdirectories named after the first character
+		// of the name, or its hexadecimal code on case-insensitive file
+		// systems like the default one on macOS.
+		for _, sub := range []string{name[:1], strconv.FormatInt(int64(name[0]), 16)} {
+			data, err := os.ReadFile(filepath.Join(dir, sub, name))
+			if err == nil {
+				return parseTerminfoColors(data)
+			}
+		}
+	}
+	return 0
+}
+
+// Parses the "colors" capability from a compiled terminfo entry. See term(5)
+// for the format.
+func parseTerminfoColors(data []byte) int {
+	if len(data) < 12 {
+		return 0
+	}
+	header := make([]int, 6)
+	for i := range header {
+		header[i] = int(binary.LittleEndian.Uint16(data[2*i:]))
+	}
+	numSize := 2
+	switch header[0] {
+	case terminfoMagic16:
+	case terminfoMagic32:
+		numSize = 4
+	default:
+		return 0
+	}
+	namesSize, boolCount, numCount := header[1], header[2], header[3]
+	if numCount <= terminfoColorsIndex {
+		return 0
+	}
+	// The numbers are aligned to an even offset.
+	offset := 12 + namesSize + boolCount
+	offset += offset % 2
+	offset += terminfoColorsIndex * numSize
+	if offset+numSize > len(data) {
+		return 0
+	}
+	var colors int
+	if numSize == 2 {
+		colors = int(int16(binary.LittleEndian.Uint16(data[offset:])))
+	} else {
+		colors = int(int32(binary.LittleEndian.Uint32(data[offset:])))
+	}
+	if colors < 0 {
+		// Absent (-1) or cancelled (-2).
+		return 0
+	}
+	return colors
+}
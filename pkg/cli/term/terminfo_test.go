@@ -0,0 +1,117 @@
+package term
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestDefaultCapabilities_Terminfo(t *testing.T) {
+	dir := testutil.TempDir(t)
+	writeTerminfo(t, filepath.Join(dir, "l", "linux"), terminfoMagic16, 8)
+	// Subdirectory named after the hexadecimal code of the first character.
+	writeTerminfo(t, filepath.Join(dir, "72", "rxvt"), terminfoMagic16, 88)
+	writeTerminfo(t, filepath.Join(dir, "x", "xterm-256color"), terminfoMagic16, 256)
+	writeTerminfo(t, filepath.Join(dir, "x", "xterm-direct"), terminfoMagic32, 1<<24)
+	writeTerminfo(t, filepath.Join(dir, "v", "vt100"), terminfoMagic16, -1)
+
+	tests := []struct {
+		term       string
+		wantColors int
+		wantTrue   bool
+	}{
+		{"linux", 8, false},
+		{"rxvt", 16, false},
+		{"xterm-256color", 256, false},
+		{"xterm-direct", 256, true},
+		{"vt100", 0, false},
+		{"nonexistent", 0, false},
+		{"", 0, false},
+	}
+	for _, tc := range tests {
+		caps := DefaultCapabilities(getenvFrom(map[string]string{
+			"TERM": tc.term, "TERMINFO": dir}))
+		if caps.Colors != tc.wantColors || caps.TrueColor != tc.wantTrue {
+			t.Errorf("with TERM=%q, got Colors = %v, TrueColor = %v, want %v, %v",
+				tc.term, caps.Colors, caps.TrueColor, tc.wantColors, tc.wantTrue)
+		}
+	}
+}
+
+func TestDefaultCapabilities_Hyperlinks(t *testing.T) {
+	for _, tc := range []struct {
+		term string
+		want bool
+	}{
+		{"xterm-256color", true},
+		{"", true},
+		{"linux", false},
+	} {
+		caps := DefaultCapabilities(getenvFrom(map[string]string{"TERM": tc.term}))
+		if caps.Hyperlinks != tc.want {
+			t.Errorf("with TERM=%q, got Hyperlinks = %v, want %v",
+				tc.term, caps.Hyperlinks, tc.want)
+		}
+	}
+}
+
+func TestParseTerminfoColors_BadData(t *testing.T) {
+	for _, data := range [][]byte{
+		nil,
+		[]byte("not terminfo"),
+		// Header claiming more numbers than there are.
+		makeTerminfo(terminfoMagic16, 8)[:14],
+	} {
+		if colors := parseTerminfoColors(data); colors != 0 {
+			t.Errorf("parseTerminfoColors(%q) -> %v, want 0", data, colors)
+		}
+	}
+}
+
+// Writes a terminfo entry to path, creating its directory.
+func writeTerminfo(t *testing.T, path string, magic, colors int) {
+	t.Helper()
+	err := os.MkdirAll(filepath.Dir(path), 0o700)
+	if err == nil {
+		err = os.WriteFile(path, makeTerminfo(magic, colors), 0o600)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Makes a minimal compiled terminfo entry with the given "colors" capability,
+// with an odd-sized names section to exercise the alignment of numbers.
+func makeTerminfo(magic, colors int) []byte {
+	names := "test|a test terminal\x00"
+	numSize := 2
+	if magic == terminfoMagic32 {
+		numSize = 4
+	}
+	data := make([]byte, 12)
+	for i, v := range []int{magic, len(names), 2, terminfoColorsIndex + 1, 0, 0} {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(v))
+	}
+	data = append(data, names...)
+	data = append(data, 1, 0)
+	if len(data)%2 == 1 {
+		data = append(data, 0)
+	}
+	for i := 0; i <= terminfoColorsIndex; i++ {
+		v := -1
+		if i == terminfoColorsIndex {
+			v = colors
+		}
+		num := make([]byte, numSize)
+		if numSize == 2 {
+			binary.LittleEndian.PutUint16(num, uint16(v))
+		} else {
+			binary.LittleEndian.PutUint32(num, uint32(v))
+		}
+		data = append(data, num...)
+	}
+	return data
+}
@@ -2,8 +2,13 @@ package term
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
 	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
 )
 
 var logWriterDetail = false
@@ -17,30 +22,135 @@ type Writer interface {
 	// UpdateBuffer updates the terminal display to reflect current buffer.
 	UpdateBuffer(bufNoti, buf *Buffer, fullRefresh bool) error
 	// ClearScreen clears the terminal screen and places the cursor at the top
-	// left corner.
+	// left corner. It also resets the current buffer, so that the next update
+	// draws the buffer from there.
 	ClearScreen()
+	// ClearScrollback is like ClearScreen, but also clears the scrollback
+	// buffer of the terminal, if the terminal supports that.
+	ClearScrollback()
 	// ShowCursor shows the cursor.
 	ShowCursor()
 	// HideCursor hides the cursor.
 	HideCursor()
+	// Bell rings the terminal bell.
+	Bell()
+	// WriteMark writes a semantic mark at the given position of the current
+	// buffer, leaving the cursor where it was.
+	WriteMark(mark SemanticMark, pos Pos)
+	// SetTitle sets the title of the terminal window, or of the pane or
+	// window when running inside tmux or screen. Control characters in the
+	// title are removed.
+	SetTitle(title string)
+	// SetMouseTracking turns SGR-style mouse tracking on or off. When it is
+	// on, the terminal reports clicks and wheel scrolls as MouseEvent's.
+	SetMouseTracking(on bool)
+	// SetAltScreen switches to or from the alternate screen buffer of the
+	// terminal. The current buffer is reset when switching to the alternate
+	// screen, and restored when switching back, since the terminal restores
+	// the original screen.
+	SetAltScreen(on bool)
+	// SetClipboard asks the terminal to copy text to the system clipboard,
+	// using the OSC 52 escape sequence, which also works over SSH. Terminals
+	// that don't support it ignore it. See also CopyToClipboard.
+	SetClipboard(text string)
+	// RequestCursorPosition asks the terminal to report the position of the
+	// cursor, which is delivered as a CursorPosition event.
+	RequestCursorPosition()
+}
+
+// SemanticMark is a mark that tells the terminal about the structure of the
+// session, using the OSC 133 escape sequence (also known as "semantic
+// prompts"). Terminals that support it use the marks to jump between prompts
+// or select the output of a command.
+type SemanticMark byte
+
+// Possible values of SemanticMark.
+const (
+	// Marks the start of the prompt.
+	PromptStartMark SemanticMark = 'A'
+	// Marks the end of the prompt and the start of the command.
+	CommandStartMark SemanticMark = 'B'
+	// Marks the end of the command and the start of its output.
+	OutputStartMark SemanticMark = 'C'
+	// Marks the end of the output of the command.
+	CommandEndMark SemanticMark = 'D'
+)
+
+// WriterConfig keeps the configuration of how Writer limits the rate of
+// updates. Limiting the rate is useful on slow links, where writing every
+// update can saturate the link and make the display lag behind the input.
+type WriterConfig struct {
+	// Maximum number of updates written per second, or 0 for no limit. An
+	// update that comes too soon after the previous one is delayed, and
+	// replaced by any update that comes while it is being delayed, so that
+	// only the latest one is written.
+	MaxRedrawRate float64
+	// Updates that take up to this many bytes to write are always written
+	// immediately, so that small changes like typing a character are not
+	// delayed.
+	RateLimitThreshold int
+}
+
+var (
+	writerConfigMutex sync.RWMutex
+	writerConfig      WriterConfig
+)
+
+// GetWriterConfig returns the configuration that Writer uses.
+func GetWriterConfig() WriterConfig {
+	writerConfigMutex.RLock()
+	defer writerConfigMutex.RUnlock()
+	return writerConfig
+}
+
+// SetWriterConfig sets the configuration that Writer uses. It takes effect
+// from the next call to UpdateBuffer.
+func SetWriterConfig(c WriterConfig) {
+	writerConfigMutex.Lock()
+	defer writerConfigMutex.Unlock()
+	writerConfig = c
 }
 
 // writer renders the editor UI.
 type writer struct {
+	// Protects all the fields, since delayed updates are written from another
+	// goroutine.
+	mutex  sync.Mutex
 	file   io.Writer
 	curBuf *Buffer
+
+	lastWrite time.Time
+	// The delayed update, if any.
+	pending *pendingUpdate
+	timer   *time.Timer
+	// Whether the screen has been cleared since the last update.
+	cleared bool
+	// The buffer on the main screen, saved while the alternate screen is
+	// used; nil otherwise.
+	mainBuf *Buffer
+}
+
+type pendingUpdate struct {
+	buf         *Buffer
+	fullRefresh bool
 }
 
 // NewWriter returns a Writer that writes VT100 sequences to the given io.Writer.
 func NewWriter(f io.Writer) Writer {
-	return &writer{f, &Buffer{}}
+	return &writer{file: f, curBuf: &Buffer{}}
 }
 
 func (w *writer) Buffer() *Buffer {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.flushPending()
 	return w.curBuf
 }
 
 func (w *writer) ResetBuffer() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.flushPending()
 	w.curBuf = &Buffer{}
 }
 
@@ -66,18 +176,97 @@ func deltaPos(from, to Pos) []byte {
 const (
 	hideCursor = "\033[?25l"
 	showCursor = "\033[?25h"
+
+	beginSynchronizedUpdate = "\033[?2026h"
+	endSynchronizedUpdate   = "\033[?2026l"
 )
 
-// UpdateBuffer updates the terminal display to reflect current buffer.
+// UpdateBuffer updates the terminal display to reflect current buffer. If the
+// rate of updates is limited, the update may be delayed, and errors from
+// writing a delayed update are only logged.
 func (w *writer) UpdateBuffer(bufNoti, buf *Buffer, fullRefresh bool) error {
-	if buf.Width != w.curBuf.Width && w.curBuf.Lines != nil {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.pending != nil {
+		// The pending update is superseded by this one, since updates are
+		// always rendered against what's on the terminal.
+		fullRefresh = fullRefresh || w.pending.fullRefresh
+		w.cancelPending()
+	}
+
+	cfg := GetWriterConfig()
+	data := w.render(bufNoti, buf, fullRefresh)
+	// Notifications are never delayed, since they are not part of the
+	// buffer and would get lost if the update were replaced. Neither are
+	// updates after the screen is cleared, which would leave it blank.
+	if bufNoti == nil && !w.cleared &&
+		cfg.MaxRedrawRate > 0 && len(data) > cfg.RateLimitThreshold {
+		interval := time.Duration(float64(time.Second) / cfg.MaxRedrawRate)
+		if wait := time.Until(w.lastWrite.Add(interval)); wait > 0 {
+			w.pending = &pendingUpdate{buf, fullRefresh}
+			w.timer = time.AfterFunc(wait, func() {
+				w.mutex.Lock()
+				defer w.mutex.Unlock()
+				w.flushPending()
+			})
+			return nil
+		}
+	}
+	return w.write(data, buf)
+}
+
+// Writes the pending update, if any. Must be called with the mutex held.
+func (w *writer) flushPending() {
+	if w.pending == nil {
+		return
+	}
+	p := w.pending
+	w.cancelPending()
+	err := w.write(w.render(nil, p.buf, p.fullRefresh), p.buf)
+	if err != nil {
+		logger.Warnf("failed to write delayed update: %v", err)
+	}
+}
+
+// Discards the pending update. Must be called with the mutex held.
+func (w *writer) cancelPending() {
+	w.timer.Stop()
+	w.pending = nil
+	w.timer = nil
+}
+
+func (w *writer) write(data []byte, buf *Buffer) error {
+	if logWriterDetail {
+		logger.Debugf("going to write %q", data)
+	}
+
+	_, err := w.file.Write(data)
+	if err != nil {
+		return err
+	}
+
+	w.curBuf = buf
+	w.lastWrite = time.Now()
+	w.cleared = false
+	return nil
+}
+
+// Returns the bytes to write to update the terminal from the current buffer to
+// buf.
+func (w *writer) render(bufNoti, buf *Buffer, fullRefresh bool) []byte {
+	oldLines := w.curBuf.Lines
+	if buf.Width != w.curBuf.Width && oldLines != nil {
 		// Width change, force full refresh
-		w.curBuf.Lines = nil
+		oldLines = nil
 		fullRefresh = true
 	}
 
+	caps := GetCapabilities()
 	bytesBuf := new(bytes.Buffer)
 
+	if caps.SynchronizedOutput {
+		bytesBuf.WriteString(beginSynchronizedUpdate)
+	}
 	bytesBuf.WriteString(hideCursor)
 
 	// Rewind cursor
@@ -98,42 +287,51 @@ func (w *writer) UpdateBuffer(bufNoti, buf *Buffer, fullRefresh bool) error {
 		bytesBuf.WriteString(" \033[J\r")
 	}
 
-	// style of last written cell.
-	style := ""
+	// style and link of last written cell.
+	style, link := "", ""
 
-	switchStyle := func(newstyle string) {
+	switchStyle := func(newstyle, newlink string) {
 		if newstyle != style {
-			fmt.Fprintf(bytesBuf, "\033[0;%sm", newstyle)
+			fmt.Fprintf(bytesBuf, "\033[0;%sm", downgradeColors(newstyle, caps))
 			style = newstyle
 		}
+		if !caps.Hyperlinks {
+			return
+		}
+		if newlink != link {
+			// Tmux 3.4 and later support OSC 8 themselves, so it is not
+			// passed through.
+			fmt.Fprintf(bytesBuf, "\033]8;;%s\007", removeControlChars(newlink))
+			link = newlink
+		}
 	}
 
 	writeCells := func(cs []Cell) {
 		for _, c := range cs {
-			switchStyle(c.Style)
+			switchStyle(c.Style, c.Link)
 			bytesBuf.WriteString(c.Text)
 		}
 	}
 
 	if bufNoti != nil {
 		if logWriterDetail {
-			logger.Printf("going to write %d lines of notifications", len(bufNoti.Lines))
+			logger.Debugf("going to write %d lines of notifications", len(bufNoti.Lines))
 		}
 
 		// Write notifications
 		for _, line := range bufNoti.Lines {
 			writeCells(line)
-			switchStyle("")
+			switchStyle("", "")
 			bytesBuf.WriteString("\033[K\n")
 		}
 		// TODO(xiaq): This is hacky; try to improve it.
-		if len(w.curBuf.Lines) > 0 {
-			w.curBuf.Lines = w.curBuf.Lines[1:]
+		if len(oldLines) > 0 {
+			oldLines = oldLines[1:]
 		}
 	}
 
 	if logWriterDetail {
-		logger.Printf("going to write %d lines, oldBuf had %d", len(buf.Lines), len(w.curBuf.Lines))
+		logger.Debugf("going to write %d lines, oldBuf had %d", len(buf.Lines), len(oldLines))
 	}
 
 	for i, line := range buf.Lines {
@@ -142,9 +340,9 @@ func (w *writer) UpdateBuffer(bufNoti, buf *Buffer, fullRefresh bool) error {
 		}
 		var j int // First column where buf and oldBuf differ
 		// No need to update current line
-		if !fullRefresh && i < len(w.curBuf.Lines) {
+		if !fullRefresh && i < len(oldLines) {
 			var eq bool
-			if eq, j = CompareCells(line, w.curBuf.Lines[i]); eq {
+			if eq, j = CompareCells(line, oldLines[i]); eq {
 				continue
 			}
 		}
@@ -154,51 +352,168 @@ func (w *writer) UpdateBuffer(bufNoti, buf *Buffer, fullRefresh bool) error {
 			fmt.Fprintf(bytesBuf, "\033[%dC", firstCol)
 		}
 		// Erase the rest of the line if necessary.
-		if !fullRefresh && i < len(w.curBuf.Lines) && j < len(w.curBuf.Lines[i]) {
-			switchStyle("")
+		if !fullRefresh && i < len(oldLines) && j < len(oldLines[i]) {
+			switchStyle("", "")
 			bytesBuf.WriteString("\033[K")
 		}
 		writeCells(line[j:])
 	}
-	if len(w.curBuf.Lines) > len(buf.Lines) && !fullRefresh {
+	if len(oldLines) > len(buf.Lines) && !fullRefresh {
 		// If the old buffer is higher, erase old content.
 		// Note that we cannot simply write \033[J, because if the cursor is
 		// just over the last column -- which is precisely the case if we have a
 		// rprompt, \033[J will also erase the last column.
-		switchStyle("")
+		switchStyle("", "")
 		bytesBuf.WriteString("\n\033[J\033[A")
 	}
-	switchStyle("")
+	switchStyle("", "")
 	cursor := buf.Cursor()
 	bytesBuf.Write(deltaPos(cursor, buf.Dot))
 
 	// Show cursor.
 	bytesBuf.WriteString(showCursor)
-
-	if logWriterDetail {
-		logger.Printf("going to write %q", bytesBuf.String())
-	}
-
-	_, err := w.file.Write(bytesBuf.Bytes())
-	if err != nil {
-		return err
+	if caps.SynchronizedOutput {
+		bytesBuf.WriteString(endSynchronizedUpdate)
 	}
+	return bytesBuf.Bytes()
+}
 
-	w.curBuf = buf
-	return nil
+// Locks the mutex and writes the pending update, if any, so that what the
+// caller writes comes after it. Returns the function to unlock the mutex.
+func (w *writer) lockAndFlush() func() {
+	w.mutex.Lock()
+	w.flushPending()
+	return w.mutex.Unlock
 }
 
 func (w *writer) HideCursor() {
+	defer w.lockAndFlush()()
 	fmt.Fprint(w.file, hideCursor)
 }
 
 func (w *writer) ShowCursor() {
+	defer w.lockAndFlush()()
 	fmt.Fprint(w.file, showCursor)
 }
 
 func (w *writer) ClearScreen() {
-	fmt.Fprint(w.file,
+	defer w.lockAndFlush()()
+	w.clear(
+		"\033[H",  // move cursor to the top left corner
+		"\033[2J", // clear entire buffer
+	)
+}
+
+func (w *writer) ClearScrollback() {
+	defer w.lockAndFlush()()
+	w.clear(
 		"\033[H",  // move cursor to the top left corner
 		"\033[2J", // clear entire buffer
+		"\033[3J", // clear scrollback (xterm extension)
 	)
 }
+
+// Writes the sequences that clear the screen. With synchronized output, they
+// start a synchronized update that the next update ends, so that the screen
+// doesn't flash blank before it is redrawn. Terminals end synchronized updates
+// by themselves after a timeout, in case no update follows.
+func (w *writer) clear(seqs ...any) {
+	if GetCapabilities().SynchronizedOutput {
+		fmt.Fprint(w.file, beginSynchronizedUpdate)
+	}
+	fmt.Fprint(w.file, seqs...)
+	w.curBuf = &Buffer{}
+	w.cleared = true
+}
+
+func (w *writer) Bell() {
+	defer w.lockAndFlush()()
+	fmt.Fprint(w.file, "\a")
+}
+
+func (w *writer) WriteMark(mark SemanticMark, pos Pos) {
+	defer w.lockAndFlush()()
+	dot := w.curBuf.Dot
+	bytesBuf := new(bytes.Buffer)
+	bytesBuf.Write(deltaPos(dot, pos))
+	bytesBuf.WriteString(passThrough(
+		fmt.Sprintf("\033]133;%c\007", mark), GetCapabilities().Multiplexer))
+	bytesBuf.Write(deltaPos(pos, dot))
+	w.file.Write(bytesBuf.Bytes())
+}
+
+func (w *writer) SetTitle(title string) {
+	defer w.lockAndFlush()()
+	title = removeControlChars(title)
+	if GetCapabilities().Multiplexer == Screen {
+		// Screen doesn't support OSC 2, and uses its own sequence for the
+		// window title.
+		fmt.Fprintf(w.file, "\033k%s\033\\", title)
+	} else {
+		// Tmux uses OSC 2 to set the pane title.
+		fmt.Fprintf(w.file, "\033]2;%s\007", title)
+	}
+}
+
+func (w *writer) SetMouseTracking(on bool) {
+	defer w.lockAndFlush()()
+	if on {
+		fmt.Fprint(w.file, "\033[?1000;1006h")
+	} else {
+		fmt.Fprint(w.file, "\033[?1000;1006l")
+	}
+}
+
+func (w *writer) SetAltScreen(on bool) {
+	defer w.lockAndFlush()()
+	if on == (w.mainBuf != nil) {
+		return
+	}
+	if on {
+		// Also move the cursor to the top left corner, which 1049 leaves
+		// where it was on the main screen.
+		fmt.Fprint(w.file, "\033[?1049h\033[H")
+		w.mainBuf, w.curBuf = w.curBuf, &Buffer{}
+	} else {
+		fmt.Fprint(w.file, "\033[?1049l")
+		w.curBuf, w.mainBuf = w.mainBuf, nil
+	}
+}
+
+func (w *writer) SetClipboard(text string) {
+	defer w.lockAndFlush()()
+	seq := "\033]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\007"
+	fmt.Fprint(w.file, passThrough(seq, GetCapabilities().Multiplexer))
+}
+
+func (w *writer) RequestCursorPosition() {
+	defer w.lockAndFlush()()
+	fmt.Fprint(w.file, "\033[6n")
+}
+
+// Wraps an escape sequence meant for the outer terminal, like an OSC sequence
+// that the multiplexer doesn't understand, in the pass-through sequence of the
+// multiplexer. The sequence must not contain ST (ESC \); OSC sequences
+// should be terminated by BEL instead.
+//
+// Tmux only passes the sequence through when the allow-passthrough option is
+// on.
+func passThrough(seq string, m Multiplexer) string {
+	switch m {
+	case Tmux:
+		return "\033Ptmux;" + strings.ReplaceAll(seq, "\033", "\033\033") + "\033\\"
+	case Screen:
+		return "\033P" + seq + "\033\\"
+	default:
+		return seq
+	}
+}
+
+func removeControlChars(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
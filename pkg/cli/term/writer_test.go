@@ -1,8 +1,12 @@
 package term
 
 import (
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"src.elv.sh/pkg/testutil"
 )
 
 func TestWriter(t *testing.T) {
@@ -21,4 +25,253 @@ func TestWriter(t *testing.T) {
 		NewBufferBuilder(10).Write("line 1").SetDotHere().Buffer(),
 		false)
 	testOutput(hideCursor + "\rnote 1\033[K\n" + "line 1\r\033[6C" + showCursor)
+
+	w.ClearScreen()
+	testOutput("\033[H\033[2J")
+	if buf := w.Buffer(); buf.Lines != nil {
+		t.Errorf("buffer not reset after ClearScreen: %v", buf)
+	}
+
+	w.ClearScrollback()
+	testOutput("\033[H\033[2J\033[3J")
+}
+
+func TestWriter_WriteMark(t *testing.T) {
+	sb := &strings.Builder{}
+	w := NewWriter(sb)
+	w.UpdateBuffer(nil,
+		NewBufferBuilder(10).Write("> ls").Newline().SetDotHere().Buffer(), false)
+	sb.Reset()
+
+	w.WriteMark(CommandStartMark, Pos{Line: 0, Col: 2})
+	want := "\033[1A\r\033[2C" + "\033]133;B\007" + "\033[1B\r"
+	if sb.String() != want {
+		t.Errorf("got %q, want %q", sb.String(), want)
+	}
+}
+
+func TestWriter_WriteMark_PassesThroughMultiplexers(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		mux  Multiplexer
+		want string
+	}{
+		{"tmux", Tmux, "\r\033Ptmux;\033\033]133;A\007\033\\\r"},
+		{"screen", Screen, "\r\033P\033]133;A\007\033\\\r"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testutil.Set(t, &caps, &Capabilities{Multiplexer: tc.mux})
+			sb := &strings.Builder{}
+			w := NewWriter(sb)
+			w.WriteMark(PromptStartMark, Pos{})
+			if sb.String() != tc.want {
+				t.Errorf("got %q, want %q", sb.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestWriter_SetTitle(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		mux  Multiplexer
+		want string
+	}{
+		{"no multiplexer", NoMultiplexer, "\033]2;vim foo\007"},
+		{"tmux", Tmux, "\033]2;vim foo\007"},
+		{"screen", Screen, "\033kvim foo\033\\"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testutil.Set(t, &caps, &Capabilities{Multiplexer: tc.mux})
+			sb := &strings.Builder{}
+			w := NewWriter(sb)
+			w.SetTitle("vim\033\a foo")
+			if sb.String() != tc.want {
+				t.Errorf("got %q, want %q", sb.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestWriter_SetClipboard(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		mux  Multiplexer
+		want string
+	}{
+		{"no multiplexer", NoMultiplexer, "\033]52;c;ZWNobyBmb28=\007"},
+		{"tmux", Tmux, "\033Ptmux;\033\033]52;c;ZWNobyBmb28=\007\033\\"},
+		{"screen", Screen, "\033P\033]52;c;ZWNobyBmb28=\007\033\\"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testutil.Set(t, &caps, &Capabilities{Multiplexer: tc.mux})
+			sb := &strings.Builder{}
+			w := NewWriter(sb)
+			w.SetClipboard("echo foo")
+			if sb.String() != tc.want {
+				t.Errorf("got %q, want %q", sb.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestWriter_MouseTrackingAndCursorPosition(t *testing.T) {
+	sb := &strings.Builder{}
+	w := NewWriter(sb)
+	w.SetMouseTracking(true)
+	w.RequestCursorPosition()
+	w.SetMouseTracking(false)
+	want := "\033[?1000;1006h\033[6n\033[?1000;1006l"
+	if sb.String() != want {
+		t.Errorf("got %q, want %q", sb.String(), want)
+	}
+}
+
+func TestWriter_AltScreen(t *testing.T) {
+	sb := &strings.Builder{}
+	w := NewWriter(sb)
+	mainBuf := NewBufferBuilder(10).Write("main").Buffer()
+	w.UpdateBuffer(nil, mainBuf, false)
+
+	sb.Reset()
+	w.SetAltScreen(true)
+	if got := sb.String(); got != "\033[?1049h\033[H" {
+		t.Errorf("got %q when switching to alternate screen", got)
+	}
+	if buf := w.Buffer(); buf.Lines != nil {
+		t.Errorf("got buffer %v in alternate screen, want empty", buf)
+	}
+	w.UpdateBuffer(nil, NewBufferBuilder(10).Write("alt").Buffer(), false)
+
+	sb.Reset()
+	w.SetAltScreen(false)
+	if got := sb.String(); got != "\033[?1049l" {
+		t.Errorf("got %q when switching back", got)
+	}
+	if buf := w.Buffer(); !reflect.DeepEqual(buf, mainBuf) {
+		t.Errorf("got buffer %v after switching back, want %v", buf, mainBuf)
+	}
+
+	// Switching to the screen already used does nothing.
+	sb.Reset()
+	w.SetAltScreen(false)
+	if got := sb.String(); got != "" {
+		t.Errorf("got %q when switching to main screen again, want empty", got)
+	}
+}
+
+func TestWriter_KeysOffCapabilities(t *testing.T) {
+	testutil.Set(t, &caps, &Capabilities{SynchronizedOutput: true})
+	sb := &strings.Builder{}
+	w := NewWriter(sb)
+	w.UpdateBuffer(nil,
+		NewBufferBuilder(10).WriteStringSGR("x", "38;2;255;0;0").Buffer(), false)
+	want := beginSynchronizedUpdate + hideCursor + "\r\033[0;38;5;196mx\033[0;m\r" +
+		showCursor + endSynchronizedUpdate
+	if sb.String() != want {
+		t.Errorf("got %q, want %q", sb.String(), want)
+	}
+}
+
+func TestWriter_Hyperlinks(t *testing.T) {
+	buf := &Buffer{Width: 10, Lines: Lines{{
+		{Text: "a"}, {Text: "b", Link: "https://elv.sh"}, {Text: "c"}}}}
+	for _, tc := range []struct {
+		name       string
+		hyperlinks bool
+		want       string
+	}{
+		{"supported", true,
+			"\ra\033]8;;https://elv.sh\007b\033]8;;\007c\r"},
+		{"not supported", false, "\rabc\r"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testutil.Set(t, &caps, &Capabilities{Hyperlinks: tc.hyperlinks})
+			sb := &strings.Builder{}
+			w := NewWriter(sb)
+			w.UpdateBuffer(nil, buf, false)
+			want := hideCursor + tc.want + showCursor
+			if sb.String() != want {
+				t.Errorf("got %q, want %q", sb.String(), want)
+			}
+		})
+	}
+}
+
+func TestWriter_ClearScreenWithSynchronizedOutput(t *testing.T) {
+	testutil.Set(t, &caps, &Capabilities{SynchronizedOutput: true})
+	sb := &strings.Builder{}
+	w := NewWriter(sb)
+	// The synchronized update started by ClearScreen is ended by the next
+	// update.
+	w.ClearScreen()
+	w.UpdateBuffer(nil, NewBufferBuilder(10).Write("x").Buffer(), true)
+	want := beginSynchronizedUpdate + "\033[H\033[2J" +
+		beginSynchronizedUpdate + hideCursor + "\r \033[J\rx\r" +
+		showCursor + endSynchronizedUpdate
+	if sb.String() != want {
+		t.Errorf("got %q, want %q", sb.String(), want)
+	}
+}
+
+func TestWriter_RateLimit(t *testing.T) {
+	testutil.Set(t, &writerConfig, WriterConfig{MaxRedrawRate: 1})
+	sb := &strings.Builder{}
+	w := NewWriter(sb)
+	testOutput := func(want string) {
+		t.Helper()
+		if sb.String() != want {
+			t.Errorf("got %q, want %q", sb.String(), want)
+		}
+		sb.Reset()
+	}
+	update := func(s string) {
+		w.UpdateBuffer(nil, NewBufferBuilder(10).Write(s).Buffer(), false)
+	}
+
+	update("a")
+	testOutput(hideCursor + "\ra\r" + showCursor)
+	// Updates that come too soon are delayed, and only the latest one is
+	// written.
+	update("ab")
+	update("abc")
+	testOutput("")
+	// Writing anything else writes the delayed update first.
+	w.Bell()
+	testOutput(hideCursor + "\r\033[1Cbc\r" + showCursor + "\a")
+
+	// Updates after the screen is cleared are written immediately.
+	w.ClearScreen()
+	update("abc")
+	testOutput("\033[H\033[2J" + hideCursor + "\rabc\r" + showCursor)
+
+	// Updates that are small enough are written immediately.
+	testutil.Set(t, &writerConfig, WriterConfig{MaxRedrawRate: 1, RateLimitThreshold: 100})
+	update("abcd")
+	testOutput(hideCursor + "\r\033[3Cd\r" + showCursor)
+}
+
+func TestWriter_RateLimit_WritesDelayedUpdate(t *testing.T) {
+	testutil.Set(t, &writerConfig, WriterConfig{MaxRedrawRate: 20})
+	writes := make(chan string, 10)
+	w := NewWriter(chanWriter(writes))
+
+	w.UpdateBuffer(nil, NewBufferBuilder(10).Write("a").Buffer(), false)
+	<-writes
+	w.UpdateBuffer(nil, NewBufferBuilder(10).Write("ab").Buffer(), false)
+	select {
+	case s := <-writes:
+		if want := hideCursor + "\r\033[1Cb\r" + showCursor; s != want {
+			t.Errorf("got %q, want %q", s, want)
+		}
+	case <-time.After(testutil.Scaled(time.Second)):
+		t.Errorf("delayed update not written")
+	}
+}
+
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
 }
@@ -0,0 +1,6 @@
+package cli
+
+// Pointers to variables that can be mutated for testing.
+var VisualBellDuration = &visualBellDuration
+var SuspendProcess = &suspendProcess
+var CanSuspend = &canSuspend
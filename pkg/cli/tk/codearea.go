@@ -1,8 +1,8 @@
 package tk
 
 import (
-	"bytes"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"unicode"
@@ -16,12 +16,17 @@ import (
 // CodeArea is a Widget for displaying and editing code.
 type CodeArea interface {
 	Widget
+	MouseHandler
 	// CopyState returns a copy of the state.
 	CopyState() CodeAreaState
 	// MutateState calls the given the function while locking StateMutex.
 	MutateState(f func(*CodeAreaState))
 	// Submit triggers the OnSubmit callback.
 	Submit()
+	// ScrollBy scrolls the view of the code by the given number of lines,
+	// without moving the dot. Positive values scroll down, and negative values
+	// scroll up. The scroll position is reset when the buffer changes.
+	ScrollBy(delta int)
 }
 
 // CodeAreaSpec specifies the configuration and initial state for CodeArea.
@@ -36,18 +41,42 @@ type CodeAreaSpec struct {
 	Prompt func() ui.Text
 	// Right-prompt callback.
 	RPrompt func() ui.Text
+	// A function that returns the prompt to show at the start of each line of
+	// the code after the first one, given its line number (2 for the second
+	// line). If this function is not given or returns nil, such lines are
+	// indented to align with the first line when the prompt is short enough.
+	ContinuationPrompt func(lineno int) ui.Text
 	// A function that calls the callback with string pairs for abbreviations
 	// and their expansions. If no function is provided the Widget does not
 	// expand any abbreviations of the specified type.
 	SimpleAbbreviations    func(f func(abbr, full string))
 	CommandAbbreviations   func(f func(abbr, full string))
 	SmallWordAbbreviations func(f func(abbr, full string))
+	// A function that is called after a rune has been typed and inserted into
+	// the buffer, and returns the buffer to use instead, such as the same
+	// buffer with the current line re-indented after a closing bracket. If
+	// this function is not given, the buffer is left as is.
+	Reindent func(buf CodeBuffer, r rune) CodeBuffer
 	// A function that returns whether pasted texts (from bracketed pastes)
 	// should be quoted. If this function is not given, the Widget defaults to
 	// not quoting pasted texts.
 	QuotePaste func() bool
 	// A function that is called on the submit event.
 	OnSubmit func()
+	// A function that returns the maximum number of lines the code area may
+	// occupy. When the code needs more lines, only part of it is shown, along
+	// with a scrollbar. Non-positive values mean no limit. If this function is
+	// not given, there is no limit.
+	MaxCodeHeight func() int
+	// A function that returns the width of tab stops. Tabs in the code are
+	// expanded to spaces up to the next tab stop. Non-positive values mean
+	// that tabs are shown like other control characters, as ^I. If this
+	// function is not given, tabs are shown as ^I.
+	TabWidth func() int
+	// Whether the code is a secret, like a password. If true, neither the code
+	// nor where the dot is within it is shown, the Highlighter is not called,
+	// and clicking on the code area does nothing.
+	Secret bool
 
 	// State. When used in New, this field specifies the initial state.
 	State CodeAreaState
@@ -59,6 +88,39 @@ type CodeAreaState struct {
 	Pending     PendingCode
 	HideRPrompt bool
 	HideTips    bool
+	// Number of lines the view is scrolled by, relative to the position that
+	// keeps the dot visible. Only takes effect when not all lines can be
+	// shown.
+	Scroll int
+	// Selected text, shown in reverse video.
+	Selection Selection
+}
+
+// Selection represents the text selected in the CodeArea widget, which is
+// between the anchor and the dot.
+type Selection struct {
+	// Whether any text is selected.
+	Active bool
+	// The end of the selection other than the dot, as a byte index into the
+	// content of the buffer.
+	Anchor int
+}
+
+// SelectedRange returns the start and end of the selected text, as byte
+// indices into Buffer.Content. They are equal if no text is selected.
+func (s *CodeAreaState) SelectedRange() (from, to int) {
+	if !s.Selection.Active {
+		return 0, 0
+	}
+	// The anchor may be out of range if the content has been changed.
+	anchor := s.Selection.Anchor
+	if anchor > len(s.Buffer.Content) {
+		anchor = len(s.Buffer.Content)
+	}
+	if anchor < s.Buffer.Dot {
+		return anchor, s.Buffer.Dot
+	}
+	return s.Buffer.Dot, anchor
 }
 
 // CodeBuffer represents the buffer of the CodeArea widget.
@@ -106,10 +168,9 @@ type codeArea struct {
 	// Value of State.CodeBuffer when handleKeyEvent was last called. Used for
 	// detecting whether insertion has been interrupted.
 	lastCodeBuffer CodeBuffer
-	// Whether the widget is in the middle of bracketed pasting.
-	pasting bool
-	// Buffer for keeping Pasted text during bracketed pasting.
-	pasteBuffer bytes.Buffer
+	// Value of State.Buffer when ScrollBy was last called. Used for resetting
+	// State.Scroll when the buffer changes.
+	scrollBuffer CodeBuffer
 }
 
 // NewCodeArea creates a new CodeArea from the given spec.
@@ -135,13 +196,22 @@ func NewCodeArea(spec CodeAreaSpec) CodeArea {
 	if spec.SmallWordAbbreviations == nil {
 		spec.SmallWordAbbreviations = func(func(a, f string)) {}
 	}
+	if spec.Reindent == nil {
+		spec.Reindent = func(buf CodeBuffer, _ rune) CodeBuffer { return buf }
+	}
 	if spec.QuotePaste == nil {
 		spec.QuotePaste = func() bool { return false }
 	}
 	if spec.OnSubmit == nil {
 		spec.OnSubmit = func() {}
 	}
-	return &codeArea{CodeAreaSpec: spec}
+	if spec.MaxCodeHeight == nil {
+		spec.MaxCodeHeight = func() int { return -1 }
+	}
+	if spec.TabWidth == nil {
+		spec.TabWidth = func() int { return -1 }
+	}
+	return &codeArea{CodeAreaSpec: spec, scrollBuffer: spec.State.Buffer}
 }
 
 // Submit emits a submit event with the current code content.
@@ -152,28 +222,156 @@ func (w *codeArea) Submit() {
 // Render renders the code area, including the prompt and rprompt, highlighted
 // code, the cursor, and compilation errors in the code content.
 func (w *codeArea) Render(width, height int) *term.Buffer {
-	b := w.render(width)
-	truncateToHeight(b, height)
+	b, win := w.window(width, height)
+	if win.high-win.low == len(b.Lines) {
+		return b
+	}
+	total := len(b.Lines)
+	truncateToLines(b, win.low, win.high)
+	if win.scrollbar {
+		b.ExtendRight(VScrollbar{Total: total, Low: win.low, High: win.high}.
+			Render(1, win.high-win.low))
+	}
 	return b
 }
 
+// The part of the fully rendered code area that is shown in a region.
+type codeWindow struct {
+	// The width the code area is rendered with, which excludes the scrollbar.
+	width int
+	// The range of lines shown.
+	low, high int
+	// Whether a scrollbar is shown.
+	scrollbar bool
+}
+
+// Fully renders the code area for a region of the given size, and returns the
+// part that is shown.
+func (w *codeArea) window(width, height int) (*term.Buffer, codeWindow) {
+	maxHeight := w.MaxCodeHeight()
+	if maxHeight > 0 && maxHeight < height {
+		height = maxHeight
+	}
+	b := w.render(width)
+	if len(b.Lines) <= height {
+		return b, codeWindow{width, 0, len(b.Lines), false}
+	}
+	// The scrollbar is only shown when a maximum code height is set, to
+	// preserve the traditional behavior of truncation otherwise.
+	showScrollbar := maxHeight > 0 && width > 1
+	if showScrollbar {
+		// Leave room for the scrollbar.
+		width--
+		b = w.render(width)
+	}
+	low := w.scrollWindow(b, height)
+	return b, codeWindow{width, low, low + height, showScrollbar}
+}
+
 func (w *codeArea) MaxHeight(width, height int) int {
-	return len(w.render(width).Lines)
+	n := len(w.render(width).Lines)
+	if maxHeight := w.MaxCodeHeight(); maxHeight > 0 && maxHeight < n {
+		return maxHeight
+	}
+	return n
+}
+
+// Returns the first line to show when only height lines of b can be shown,
+// taking the scroll position into account.
+func (w *codeArea) scrollWindow(b *term.Buffer, height int) int {
+	base := windowLow(b, height)
+	maxLow := len(b.Lines) - height
+	var low int
+	w.MutateState(func(s *CodeAreaState) {
+		if s.Buffer != w.scrollBuffer {
+			s.Scroll = 0
+		}
+		low = base + s.Scroll
+		if low < 0 {
+			low = 0
+		} else if low > maxLow {
+			low = maxLow
+		}
+		// Don't let the scroll position go beyond what can be shown.
+		s.Scroll = low - base
+	})
+	return low
+}
+
+func (w *codeArea) ScrollBy(delta int) {
+	w.MutateState(func(s *CodeAreaState) {
+		if s.Buffer != w.scrollBuffer {
+			s.Scroll = 0
+			w.scrollBuffer = s.Buffer
+		}
+		s.Scroll += delta
+	})
 }
 
 func (w *codeArea) render(width int) *term.Buffer {
-	view := getView(w)
-	bb := term.NewBufferBuilder(width)
-	renderView(view, bb)
+	return w.renderView(getView(w), width)
+}
+
+func (w *codeArea) renderView(v *view, width int) *term.Buffer {
+	bb := term.NewBufferBuilder(width).SetTabWidth(w.TabWidth())
+	renderView(v, bb)
 	return bb.Buffer()
 }
 
-// Handle handles KeyEvent's of non-function keys, as well as PasteSetting
-// events.
+// HandleMouse moves the dot to where the left button is clicked, and scrolls
+// the code with the wheel.
+func (w *codeArea) HandleMouse(event term.MouseEvent, width, height int) bool {
+	switch {
+	case event.Button == term.WheelUpButton:
+		w.ScrollBy(-1)
+		return true
+	case event.Button == term.WheelDownButton:
+		w.ScrollBy(1)
+		return true
+	case event.Button == term.LeftButton && event.Down:
+		if w.Secret || w.CopyState().Pending != (PendingCode{}) {
+			// The dot can't be moved within pending code.
+			return false
+		}
+		_, win := w.window(width, height)
+		dot := w.dotAt(win.width, term.Pos{Line: event.Line + win.low, Col: event.Col})
+		w.MutateState(func(s *CodeAreaState) { s.Buffer.Dot = dot })
+		return true
+	}
+	return false
+}
+
+// Returns the index of the code that the dot should be moved to when pos of
+// the code area rendered with the given width is clicked: the last index
+// whose dot is at or before pos, or 0 if there is none.
+func (w *codeArea) dotAt(width int, pos term.Pos) int {
+	v := getView(w)
+	// There is no pending code, so the view shows the content of the buffer.
+	code := w.CopyState().Buffer.Content
+	// Indices of the starts of all the codepoints, plus the end of the code.
+	var indices []int
+	for i := range code {
+		indices = append(indices, i)
+	}
+	indices = append(indices, len(code))
+	// The position of the dot never decreases as the dot moves forward, so
+	// search for the first index whose dot is after pos.
+	i := sort.Search(len(indices), func(i int) bool {
+		v.dot = indices[i]
+		dot := w.renderView(v, width).Dot
+		return dot.Line > pos.Line || (dot.Line == pos.Line && dot.Col > pos.Col)
+	})
+	if i == 0 {
+		return 0
+	}
+	return indices[i-1]
+}
+
+// Handle handles KeyEvent's of non-function keys, as well as PasteEvent's.
 func (w *codeArea) Handle(event term.Event) bool {
 	switch event := event.(type) {
-	case term.PasteSetting:
-		return w.handlePasteSetting(bool(event))
+	case term.PasteEvent:
+		return w.handlePaste(string(event))
 	case term.KeyEvent:
 		return w.handleKeyEvent(ui.Key(event))
 	}
@@ -197,20 +395,14 @@ func (w *codeArea) resetInserts() {
 	w.lastCodeBuffer = CodeBuffer{}
 }
 
-func (w *codeArea) handlePasteSetting(start bool) bool {
+// Inserts pasted text literally: key bindings don't apply to it, newlines in
+// it don't submit the code, and it doesn't trigger abbreviations.
+func (w *codeArea) handlePaste(text string) bool {
 	w.resetInserts()
-	if start {
-		w.pasting = true
-	} else {
-		text := w.pasteBuffer.String()
-		if w.QuotePaste() {
-			text = parse.Quote(text)
-		}
-		w.MutateState(func(s *CodeAreaState) { s.Buffer.InsertAtDot(text) })
-
-		w.pasting = false
-		w.pasteBuffer = bytes.Buffer{}
+	if w.QuotePaste() {
+		text = parse.Quote(text)
 	}
+	w.MutateState(func(s *CodeAreaState) { s.Buffer.InsertAtDot(text) })
 	return true
 }
 
@@ -334,16 +526,6 @@ func (w *codeArea) expandSmallWordAbbr(trigger rune, categorizer func(rune) int)
 
 func (w *codeArea) handleKeyEvent(key ui.Key) bool {
 	isFuncKey := key.Mod != 0 || key.Rune < 0
-	if w.pasting {
-		if isFuncKey {
-			// TODO: Notify the user of the error, or insert the original
-			// character as is.
-		} else {
-			w.pasteBuffer.WriteRune(key.Rune)
-		}
-		return true
-	}
-
 	if w.Bindings.Handle(w, term.KeyEvent(key)) {
 		return true
 	}
@@ -388,6 +570,10 @@ func (w *codeArea) handleKeyEvent(key ui.Key) bool {
 		}
 		w.expandSimpleAbbr()
 		w.expandSmallWordAbbr(key.Rune, CategorizeSmallWord)
+		if buf := w.Reindent(w.State.Buffer, key.Rune); buf != w.State.Buffer {
+			w.State.Buffer = buf
+			w.resetInserts()
+		}
 		return true
 	}
 }
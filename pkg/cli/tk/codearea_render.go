@@ -1,6 +1,8 @@
 package tk
 
 import (
+	"strings"
+
 	"src.elv.sh/pkg/cli/term"
 	"src.elv.sh/pkg/ui"
 	"src.elv.sh/pkg/wcwidth"
@@ -8,17 +10,29 @@ import (
 
 // View model, calculated from State and used for rendering.
 type view struct {
-	prompt  ui.Text
-	rprompt ui.Text
-	code    ui.Text
-	dot     int
-	tips    []ui.Text
+	prompt     ui.Text
+	rprompt    ui.Text
+	contPrompt func(lineno int) ui.Text
+	code       ui.Text
+	dot        int
+	tips       []ui.Text
 }
 
-var stylingForPending = ui.Underlined
+var (
+	stylingForPending   = ui.DefineRole("pending", ui.Underlined)
+	stylingForSelection = ui.DefineRole("selection", ui.Inverse)
+)
 
 func getView(w *codeArea) *view {
 	s := w.CopyState()
+	var rprompt ui.Text
+	if !s.HideRPrompt {
+		rprompt = w.RPrompt()
+	}
+	if w.Secret {
+		return &view{w.Prompt(), rprompt, w.ContinuationPrompt, nil, 0, nil}
+	}
+
 	code, pFrom, pTo := patchPending(s.Buffer, s.Pending)
 	styledCode, errors := w.Highlighter(code.Content)
 	if s.HideTips {
@@ -29,14 +43,13 @@ func getView(w *codeArea) *view {
 		parts := styledCode.Partition(pFrom, pTo)
 		pending := ui.StyleText(parts[1], stylingForPending)
 		styledCode = ui.Concat(parts[0], pending, parts[2])
+	} else if sFrom, sTo := s.SelectedRange(); sFrom < sTo {
+		parts := styledCode.Partition(sFrom, sTo)
+		selected := ui.StyleText(parts[1], stylingForSelection)
+		styledCode = ui.Concat(parts[0], selected, parts[2])
 	}
 
-	var rprompt ui.Text
-	if !s.HideRPrompt {
-		rprompt = w.RPrompt()
-	}
-
-	return &view{w.Prompt(), rprompt, styledCode, code.Dot, errors}
+	return &view{w.Prompt(), rprompt, w.ContinuationPrompt, styledCode, code.Dot, errors}
 }
 
 func patchPending(c CodeBuffer, p PendingCode) (CodeBuffer, int, int) {
@@ -68,15 +81,20 @@ func renderView(v *view, buf *term.BufferBuilder) {
 	buf.EagerWrap = true
 
 	buf.WriteStyled(v.prompt)
-	if len(buf.Lines) == 1 && buf.Col*2 < buf.Width {
-		buf.Indent = buf.Col
-	}
+	setIndentAfterPrompt(buf, 0)
 
 	parts := v.code.Partition(v.dot)
-	buf.
-		WriteStyled(parts[0]).
-		SetDotHere().
-		WriteStyled(parts[1])
+	if v.contPrompt == nil {
+		buf.
+			WriteStyled(parts[0]).
+			SetDotHere().
+			WriteStyled(parts[1])
+	} else {
+		w := contPromptWriter{buf, v.contPrompt, buf.Indent, 1}
+		w.write(parts[0])
+		buf.SetDotHere()
+		w.write(parts[1])
+	}
 
 	buf.EagerWrap = false
 	buf.Indent = 0
@@ -96,17 +114,76 @@ func renderView(v *view, buf *term.BufferBuilder) {
 	}
 }
 
-func truncateToHeight(b *term.Buffer, maxHeight int) {
+// Sets the indentation of lines wrapped from the current line, so that they
+// are aligned with the text after the prompt that has just been written
+// starting from the given line, if it fits in that line and is short enough.
+func setIndentAfterPrompt(buf *term.BufferBuilder, startLine int) {
+	if len(buf.Lines) == startLine+1 && buf.Col*2 < buf.Width {
+		buf.Indent = buf.Col
+	} else {
+		buf.Indent = 0
+	}
+}
+
+// Writes code, starting each line after a newline with the continuation
+// prompt for it.
+type contPromptWriter struct {
+	buf        *term.BufferBuilder
+	contPrompt func(lineno int) ui.Text
+	// Indentation set after the main prompt, used for lines whose continuation
+	// prompt is nil.
+	indent int
+	// Line number of the current line.
+	lineno int
+}
+
+func (w *contPromptWriter) write(code ui.Text) {
+	for _, seg := range code {
+		for i, line := range strings.Split(seg.Text, "\n") {
+			if i > 0 {
+				w.newline()
+			}
+			if line != "" {
+				w.buf.WriteStyled(ui.Text{&ui.Segment{Style: seg.Style, Text: line}})
+			}
+		}
+	}
+}
+
+func (w *contPromptWriter) newline() {
+	w.lineno++
+	prompt := w.contPrompt(w.lineno)
+	if prompt == nil {
+		w.buf.Indent = w.indent
+		w.buf.Newline()
+		return
+	}
+	w.buf.Indent = 0
+	w.buf.Newline()
+	startLine := len(w.buf.Lines) - 1
+	w.buf.WriteStyled(prompt)
+	setIndentAfterPrompt(w.buf, startLine)
+}
+
+// Returns the first line to show when only maxHeight lines of b can be shown.
+func windowLow(b *term.Buffer, maxHeight int) int {
 	switch {
-	case len(b.Lines) <= maxHeight:
-		// We can show all line; do nothing.
-	case b.Dot.Line < maxHeight:
+	case len(b.Lines) <= maxHeight, b.Dot.Line < maxHeight:
 		// We can show all lines before the cursor, and as many lines after the
 		// cursor as we can, adding up to maxHeight.
-		b.TrimToLines(0, maxHeight)
+		return 0
 	default:
 		// We can show maxHeight lines before and including the cursor line.
-		b.TrimToLines(b.Dot.Line-maxHeight+1, b.Dot.Line+1)
+		return b.Dot.Line - maxHeight + 1
+	}
+}
+
+// Trims b to the lines [low, high), keeping the dot within the remaining lines
+// even if it was outside them.
+func truncateToLines(b *term.Buffer, low, high int) {
+	b.TrimToLines(low, high)
+	if b.Dot.Line >= len(b.Lines) {
+		b.Dot = term.Pos{Line: len(b.Lines) - 1, Col: 0}
 	}
 }
 
@@ -2,6 +2,8 @@ package tk
 
 import (
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
 	"src.elv.sh/pkg/cli/term"
@@ -37,6 +39,22 @@ var codeAreaRenderTests = []renderTest{
 		Width: 10, Height: 24,
 		Want: bb(10).SetDotHere().Write("code"),
 	},
+	{
+		Name: "code with tab and TabWidth",
+		Given: NewCodeArea(CodeAreaSpec{
+			TabWidth: func() int { return 4 },
+			State: CodeAreaState{
+				Buffer: CodeBuffer{Content: "a\tb", Dot: 3}}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("a   b").SetDotHere(),
+	},
+	{
+		Name: "code with tab and no TabWidth",
+		Given: NewCodeArea(CodeAreaSpec{State: CodeAreaState{
+			Buffer: CodeBuffer{Content: "a\tb", Dot: 3}}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("a").WriteRuneSGR('\t', "").Write("b").SetDotHere(),
+	},
 	{
 		Name: "code only with dot at middle",
 		Given: NewCodeArea(CodeAreaSpec{State: CodeAreaState{
@@ -113,6 +131,62 @@ var codeAreaRenderTests = []renderTest{
 		Width: 10, Height: 24,
 		Want: bb(10).Write("> code").SetDotHere(),
 	},
+	{
+		Name: "secret",
+		Given: NewCodeArea(CodeAreaSpec{
+			Prompt:  p(ui.T("> ")),
+			RPrompt: p(ui.T("RP")),
+			Highlighter: func(code string) (ui.Text, []ui.Text) {
+				panic("highlighter called")
+			},
+			Secret: true,
+			State: CodeAreaState{
+				Buffer: CodeBuffer{Content: "secret\ncode", Dot: 3}}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("> ").SetDotHere().WriteSpaces(6).Write("RP"),
+	},
+	{
+		Name: "multi-line code without continuation prompt",
+		Given: NewCodeArea(CodeAreaSpec{
+			Prompt: p(ui.T("> ")),
+			State:  CodeAreaState{Buffer: CodeBuffer{Content: "a\nb", Dot: 3}}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("> a").Newline().Write("  b").SetDotHere(),
+	},
+	{
+		Name: "multi-line code with continuation prompt",
+		Given: NewCodeArea(CodeAreaSpec{
+			Prompt: p(ui.T("> ")),
+			ContinuationPrompt: func(lineno int) ui.Text {
+				return ui.T(strconv.Itoa(lineno)+" ", ui.FgRed)
+			},
+			State: CodeAreaState{Buffer: CodeBuffer{Content: "a\nb\nc", Dot: 3}}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("> a").
+			Newline().Write("2 ", ui.FgRed).Write("b").SetDotHere().
+			Newline().Write("3 ", ui.FgRed).Write("c"),
+	},
+	{
+		Name: "continuation prompt returning nil",
+		Given: NewCodeArea(CodeAreaSpec{
+			Prompt:             p(ui.T("> ")),
+			ContinuationPrompt: func(int) ui.Text { return nil },
+			State:              CodeAreaState{Buffer: CodeBuffer{Content: "a\nb", Dot: 3}}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("> a").Newline().Write("  b").SetDotHere(),
+	},
+	{
+		Name: "wrapped line after continuation prompt",
+		Given: NewCodeArea(CodeAreaSpec{
+			Prompt:             p(ui.T("> ")),
+			ContinuationPrompt: func(int) ui.Text { return ui.T(". ") },
+			State: CodeAreaState{
+				Buffer: CodeBuffer{Content: "a\n12345678901", Dot: 14}}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("> a").
+			Newline().Write(". 12345678").
+			Newline().Write("  901").SetDotHere(),
+	},
 	{
 		Name: "pending code inserting at the dot",
 		Given: NewCodeArea(CodeAreaSpec{State: CodeAreaState{
@@ -168,6 +242,24 @@ var codeAreaRenderTests = []renderTest{
 		Width: 10, Height: 24,
 		Want: bb(10).Write("code").SetDotHere(),
 	},
+	{
+		Name: "selection before the dot",
+		Given: NewCodeArea(CodeAreaSpec{State: CodeAreaState{
+			Buffer:    CodeBuffer{Content: "echo foo", Dot: 8},
+			Selection: Selection{Active: true, Anchor: 5},
+		}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("echo ").WriteStringSGR("foo", "7").SetDotHere(),
+	},
+	{
+		Name: "selection after the dot",
+		Given: NewCodeArea(CodeAreaSpec{State: CodeAreaState{
+			Buffer:    CodeBuffer{Content: "echo foo", Dot: 0},
+			Selection: Selection{Active: true, Anchor: 4},
+		}}),
+		Width: 10, Height: 24,
+		Want: bb(10).SetDotHere().WriteStringSGR("echo", "7").Write(" foo"),
+	},
 	{
 		Name: "prioritize lines before the cursor with small height",
 		Given: NewCodeArea(CodeAreaSpec{State: CodeAreaState{
@@ -199,6 +291,179 @@ func TestCodeArea_Render(t *testing.T) {
 	testRender(t, codeAreaRenderTests)
 }
 
+func maxCodeHeight(n int) func() int { return func() int { return n } }
+
+var codeAreaMaxCodeHeightRenderTests = []renderTest{
+	{
+		Name: "MaxCodeHeight not reached",
+		Given: NewCodeArea(CodeAreaSpec{
+			MaxCodeHeight: maxCodeHeight(2),
+			State: CodeAreaState{
+				Buffer: CodeBuffer{Content: "a\nb", Dot: 3}}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("a").Newline().Write("b").SetDotHere(),
+	},
+	{
+		Name: "MaxCodeHeight reached, showing scrollbar",
+		Given: NewCodeArea(CodeAreaSpec{
+			MaxCodeHeight: maxCodeHeight(2),
+			State: CodeAreaState{
+				Buffer: CodeBuffer{Content: "a\nb\nc\nd", Dot: 7}}}),
+		Width: 10, Height: 24,
+		Want: bb(10).
+			Write("c        ").Write("│", ui.FgMagenta).Newline().
+			Write("d").SetDotHere().Write("        ").
+			Write(" ", ui.FgMagenta, ui.Inverse),
+	},
+	{
+		Name: "scrolled up, with dot outside the view",
+		Given: NewCodeArea(CodeAreaSpec{
+			MaxCodeHeight: maxCodeHeight(2),
+			State: CodeAreaState{
+				Buffer: CodeBuffer{Content: "a\nb\nc\nd", Dot: 7},
+				Scroll: -1}}),
+		Width: 10, Height: 24,
+		Want: bb(10).
+			Write("b        ").Write("│", ui.FgMagenta).Newline().
+			SetDotHere().Write("c        ").
+			Write(" ", ui.FgMagenta, ui.Inverse),
+	},
+	{
+		Name: "scroll position clamped",
+		Given: NewCodeArea(CodeAreaSpec{
+			MaxCodeHeight: maxCodeHeight(2),
+			State: CodeAreaState{
+				Buffer: CodeBuffer{Content: "a\nb\nc\nd", Dot: 0},
+				Scroll: -5}}),
+		Width: 10, Height: 24,
+		Want: bb(10).
+			SetDotHere().Write("a        ").
+			Write(" ", ui.FgMagenta, ui.Inverse).Newline().
+			Write("b        ").Write("│", ui.FgMagenta),
+	},
+}
+
+func TestCodeArea_Render_MaxCodeHeight(t *testing.T) {
+	testRender(t, codeAreaMaxCodeHeightRenderTests)
+}
+
+func TestCodeArea_ScrollBy(t *testing.T) {
+	w := NewCodeArea(CodeAreaSpec{
+		MaxCodeHeight: maxCodeHeight(2),
+		State: CodeAreaState{
+			Buffer: CodeBuffer{Content: "a\nb\nc\nd", Dot: 7}}})
+
+	w.ScrollBy(-1)
+	if scroll := w.CopyState().Scroll; scroll != -1 {
+		t.Errorf("got scroll %v after ScrollBy(-1), want -1", scroll)
+	}
+	// Scrolling beyond the end is clamped upon rendering.
+	w.ScrollBy(5)
+	w.Render(10, 24)
+	if scroll := w.CopyState().Scroll; scroll != 0 {
+		t.Errorf("got scroll %v after ScrollBy(5), want 0", scroll)
+	}
+	// The scroll position is reset when the buffer changes.
+	w.ScrollBy(-1)
+	w.MutateState(func(s *CodeAreaState) { s.Buffer.InsertAtDot("e") })
+	w.Render(10, 24)
+	if scroll := w.CopyState().Scroll; scroll != 0 {
+		t.Errorf("got scroll %v after changing buffer, want 0", scroll)
+	}
+}
+
+func leftClick(line, col int) term.MouseEvent {
+	return term.MouseEvent{Pos: term.Pos{Line: line, Col: col}, Down: true, Button: term.LeftButton}
+}
+
+func TestCodeArea_HandleMouse(t *testing.T) {
+	w := NewCodeArea(CodeAreaSpec{
+		Prompt: p(ui.T("> ")),
+		State:  CodeAreaState{Buffer: CodeBuffer{Content: "echo 好\nls", Dot: 0}}})
+	dotAfterClick := func(line, col int) int {
+		t.Helper()
+		if !w.HandleMouse(leftClick(line, col), 10, 24) {
+			t.Fatalf("click at (%d, %d) not handled", line, col)
+		}
+		return w.CopyState().Buffer.Dot
+	}
+
+	if dot := dotAfterClick(0, 4); dot != 2 {
+		t.Errorf("got dot %d after clicking (0, 4), want 2", dot)
+	}
+	// Clicking on either half of a wide character moves the dot before it.
+	if dot := dotAfterClick(0, 8); dot != 5 {
+		t.Errorf("got dot %d after clicking (0, 8), want 5", dot)
+	}
+	// Clicking after the end of a line moves the dot to the end of it.
+	if dot := dotAfterClick(0, 9); dot != 8 {
+		t.Errorf("got dot %d after clicking (0, 9), want 8", dot)
+	}
+	if dot := dotAfterClick(1, 9); dot != 11 {
+		t.Errorf("got dot %d after clicking (1, 9), want 11", dot)
+	}
+	if dot := dotAfterClick(0, 0); dot != 0 {
+		t.Errorf("got dot %d after clicking (0, 0), want 0", dot)
+	}
+
+	// Other buttons are not handled.
+	if w.HandleMouse(term.MouseEvent{Down: true, Button: term.RightButton}, 10, 24) {
+		t.Errorf("right click handled")
+	}
+	// The wheel scrolls the code.
+	w.HandleMouse(term.MouseEvent{Down: true, Button: term.WheelUpButton}, 10, 24)
+	if scroll := w.CopyState().Scroll; scroll != -1 {
+		t.Errorf("got scroll %d after wheel up, want -1", scroll)
+	}
+}
+
+func TestCodeArea_HandleMouse_Scrolled(t *testing.T) {
+	w := NewCodeArea(CodeAreaSpec{
+		MaxCodeHeight: maxCodeHeight(2),
+		State: CodeAreaState{
+			Buffer: CodeBuffer{Content: "a\nb\nc\nd", Dot: 7}}})
+	// Lines c and d are shown.
+	w.HandleMouse(leftClick(0, 0), 10, 24)
+	if dot := w.CopyState().Buffer.Dot; dot != 4 {
+		t.Errorf("got dot %d, want 4", dot)
+	}
+}
+
+func TestCodeArea_HandleMouse_PendingCode(t *testing.T) {
+	w := NewCodeArea(CodeAreaSpec{
+		State: CodeAreaState{
+			Buffer:  CodeBuffer{Content: "echo", Dot: 4},
+			Pending: PendingCode{From: 4, To: 4, Content: " foo"}}})
+	if w.HandleMouse(leftClick(0, 0), 10, 24) {
+		t.Errorf("click handled with pending code")
+	}
+	if dot := w.CopyState().Buffer.Dot; dot != 4 {
+		t.Errorf("got dot %d, want 4", dot)
+	}
+}
+
+func TestCodeArea_HandleMouse_Secret(t *testing.T) {
+	w := NewCodeArea(CodeAreaSpec{
+		Secret: true,
+		State:  CodeAreaState{Buffer: CodeBuffer{Content: "secret", Dot: 6}}})
+	if w.HandleMouse(leftClick(0, 0), 10, 24) {
+		t.Errorf("click handled in secret code area")
+	}
+	if dot := w.CopyState().Buffer.Dot; dot != 6 {
+		t.Errorf("got dot %d, want 6", dot)
+	}
+}
+
+func TestCodeArea_MaxHeight_MaxCodeHeight(t *testing.T) {
+	w := NewCodeArea(CodeAreaSpec{
+		MaxCodeHeight: maxCodeHeight(2),
+		State: CodeAreaState{
+			Buffer: CodeBuffer{Content: "a\nb\nc\nd", Dot: 7}}})
+	if h := w.MaxHeight(10, 24); h != 2 {
+		t.Errorf("got MaxHeight %v, want 2", h)
+	}
+}
+
 var codeAreaHandleTests = []handleTest{
 	{
 		Name:         "simple inserts",
@@ -213,36 +478,22 @@ var codeAreaHandleTests = []handleTest{
 		WantNewState: CodeAreaState{Buffer: CodeBuffer{Content: "你好", Dot: 6}},
 	},
 	{
-		Name:         "unterminated paste",
+		Name:         "literal paste",
 		Given:        NewCodeArea(CodeAreaSpec{}),
-		Events:       []term.Event{term.PasteSetting(true), term.K('"'), term.K('x')},
-		WantNewState: CodeAreaState{},
-	},
-	{
-		Name:  "literal paste",
-		Given: NewCodeArea(CodeAreaSpec{}),
-		Events: []term.Event{
-			term.PasteSetting(true),
-			term.K('"'), term.K('x'),
-			term.PasteSetting(false)},
-		WantNewState: CodeAreaState{Buffer: CodeBuffer{Content: "\"x", Dot: 2}},
+		Events:       []term.Event{term.PasteEvent("\"x\n\ty")},
+		WantNewState: CodeAreaState{Buffer: CodeBuffer{Content: "\"x\n\ty", Dot: 5}},
 	},
 	{
-		Name:  "literal paste swallowing functional keys",
-		Given: NewCodeArea(CodeAreaSpec{}),
-		Events: []term.Event{
-			term.PasteSetting(true),
-			term.K('a'), term.K(ui.F1), term.K('b'),
-			term.PasteSetting(false)},
-		WantNewState: CodeAreaState{Buffer: CodeBuffer{Content: "ab", Dot: 2}},
+		Name: "paste inserted at dot",
+		Given: NewCodeArea(CodeAreaSpec{State: CodeAreaState{
+			Buffer: CodeBuffer{Content: "ac", Dot: 1}}}),
+		Events:       []term.Event{term.PasteEvent("b")},
+		WantNewState: CodeAreaState{Buffer: CodeBuffer{Content: "abc", Dot: 2}},
 	},
 	{
-		Name:  "quoted paste",
-		Given: NewCodeArea(CodeAreaSpec{QuotePaste: func() bool { return true }}),
-		Events: []term.Event{
-			term.PasteSetting(true),
-			term.K('"'), term.K('x'),
-			term.PasteSetting(false)},
+		Name:         "quoted paste",
+		Given:        NewCodeArea(CodeAreaSpec{QuotePaste: func() bool { return true }}),
+		Events:       []term.Event{term.PasteEvent("\"x")},
 		WantNewState: CodeAreaState{Buffer: CodeBuffer{Content: "'\"x'", Dot: 4}},
 	},
 	{
@@ -324,6 +575,16 @@ var codeAreaHandleTests = []handleTest{
 		Events:       []term.Event{term.K('d'), term.K('n')},
 		WantNewState: CodeAreaState{Buffer: CodeBuffer{Content: "/dev/null", Dot: 9}},
 	},
+	{
+		Name: "abbreviation not expanded in pasted text",
+		Given: NewCodeArea(CodeAreaSpec{
+			SimpleAbbreviations: func(f func(abbr, full string)) {
+				f("dn", "/dev/null")
+			},
+		}),
+		Events:       []term.Event{term.PasteEvent("dn"), term.K('d'), term.PasteEvent("n")},
+		WantNewState: CodeAreaState{Buffer: CodeBuffer{Content: "dndn", Dot: 4}},
+	},
 	{
 		Name: "abbreviation expansion interrupted by function key",
 		Given: NewCodeArea(CodeAreaSpec{
@@ -334,6 +595,21 @@ var codeAreaHandleTests = []handleTest{
 		Events:       []term.Event{term.K('d'), term.K(ui.F1), term.K('n')},
 		WantNewState: CodeAreaState{Buffer: CodeBuffer{Content: "dn", Dot: 2}},
 	},
+	{
+		Name: "reindent",
+		Given: NewCodeArea(CodeAreaSpec{
+			Reindent: func(buf CodeBuffer, r rune) CodeBuffer {
+				if r == '}' {
+					content := strings.TrimLeft(buf.Content, " ")
+					return CodeBuffer{Content: content, Dot: len(content)}
+				}
+				return buf
+			},
+			State: CodeAreaState{Buffer: CodeBuffer{Content: "  ", Dot: 2}},
+		}),
+		Events:       []term.Event{term.K('x'), term.K('}')},
+		WantNewState: CodeAreaState{Buffer: CodeBuffer{Content: "x}", Dot: 2}},
+	},
 	{
 		Name: "small word abbreviation expansion space trigger",
 		Given: NewCodeArea(CodeAreaSpec{
@@ -432,8 +708,7 @@ var codeAreaHandleTests = []handleTest{
 		Given: NewCodeArea(CodeAreaSpec{Bindings: MapBindings{
 			term.K('\n'): func(w Widget) {}},
 		}),
-		Events: []term.Event{
-			term.PasteSetting(true), term.K('\n'), term.PasteSetting(false)},
+		Events:       []term.Event{term.PasteEvent("\n")},
 		WantNewState: CodeAreaState{Buffer: CodeBuffer{Content: "\n", Dot: 1}},
 	},
 }
@@ -519,3 +794,19 @@ func TestCodeAreaState_ApplyPending(t *testing.T) {
 			Rets(CodeAreaState{Buffer: CodeBuffer{Content: "x", Dot: 1}, HideRPrompt: true}),
 	})
 }
+
+func TestCodeAreaState_SelectedRange(t *testing.T) {
+	selectedRange := func(s CodeAreaState) (int, int) { return s.SelectedRange() }
+	tt.Test(t, tt.Fn("selectedRange", selectedRange), tt.Table{
+		// Inactive selection.
+		Args(CodeAreaState{Buffer: CodeBuffer{"echo foo", 8}}).Rets(0, 0),
+		// Anchor before or after the dot.
+		Args(CodeAreaState{Buffer: CodeBuffer{"echo foo", 8},
+			Selection: Selection{Active: true, Anchor: 5}}).Rets(5, 8),
+		Args(CodeAreaState{Buffer: CodeBuffer{"echo foo", 2},
+			Selection: Selection{Active: true, Anchor: 5}}).Rets(2, 5),
+		// Anchor out of range.
+		Args(CodeAreaState{Buffer: CodeBuffer{"echo", 2},
+			Selection: Selection{Active: true, Anchor: 8}}).Rets(2, 4),
+	})
+}
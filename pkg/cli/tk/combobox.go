@@ -7,6 +7,7 @@ import (
 // ComboBox is a Widget that combines a ListBox and a CodeArea.
 type ComboBox interface {
 	Widget
+	MouseHandler
 	// Returns the embedded codearea widget.
 	CodeArea() CodeArea
 	// Returns the embedded listbox widget.
@@ -75,6 +76,20 @@ func (w *comboBox) Handle(event term.Event) bool {
 	return false
 }
 
+// HandleMouse lets the listbox handle wheel events, and other events on the
+// part where the widget is rendered.
+func (w *comboBox) HandleMouse(event term.MouseEvent, width, height int) bool {
+	if event.Button == term.WheelUpButton || event.Button == term.WheelDownButton {
+		return w.listBox.HandleMouse(event, width, height)
+	}
+	codeAreaHeight := len(w.codeArea.Render(width, height).Lines)
+	if event.Line < codeAreaHeight {
+		return w.codeArea.HandleMouse(event, width, height)
+	}
+	event.Line -= codeAreaHeight
+	return w.listBox.HandleMouse(event, width, height-codeAreaHeight)
+}
+
 func (w *comboBox) Refilter() {
 	w.OnFilter(w, w.codeArea.CopyState().Buffer.Content)
 }
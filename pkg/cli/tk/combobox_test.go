@@ -75,14 +75,18 @@ func TestComboBox_Handle(t *testing.T) {
 	}
 
 	onFilterCalled = false
-	handled = w.Handle(term.PasteSetting(true))
+	handled = w.Handle(term.PasteEvent(""))
 	if !handled {
-		t.Errorf("codearea did not handle PasteSetting")
+		t.Errorf("codearea did not handle PasteEvent")
 	}
 	if onFilterCalled {
 		t.Errorf("OnFilter called when codearea content did not change")
 	}
-	w.Handle(term.PasteSetting(false))
+
+	w.Handle(term.PasteEvent("b"))
+	if lastFilter != "ab" {
+		t.Errorf("OnFilter not called with pasted text")
+	}
 
 	handled = w.Handle(term.K('D', ui.Ctrl))
 	if handled {
@@ -90,6 +94,29 @@ func TestComboBox_Handle(t *testing.T) {
 	}
 }
 
+func TestComboBox_HandleMouse(t *testing.T) {
+	w := NewComboBox(ComboBoxSpec{
+		CodeArea: CodeAreaSpec{
+			State: CodeAreaState{Buffer: CodeBuffer{Content: "ab", Dot: 2}}},
+		ListBox: ListBoxSpec{
+			State: ListBoxState{Items: TestItems{NItems: 3}}}})
+	w.Render(10, 24)
+
+	w.HandleMouse(term.MouseEvent{Pos: term.Pos{Line: 0, Col: 1}, Down: true, Button: term.LeftButton}, 10, 24)
+	if dot := w.CodeArea().CopyState().Buffer.Dot; dot != 1 {
+		t.Errorf("got dot %d after clicking code area, want 1", dot)
+	}
+	w.HandleMouse(term.MouseEvent{Pos: term.Pos{Line: 3, Col: 1}, Down: true, Button: term.LeftButton}, 10, 24)
+	if selected := w.ListBox().CopyState().Selected; selected != 2 {
+		t.Errorf("got selected %d after clicking listbox, want 2", selected)
+	}
+	// Wheel events always go to the listbox.
+	w.HandleMouse(term.MouseEvent{Pos: term.Pos{Line: 0}, Down: true, Button: term.WheelUpButton}, 10, 24)
+	if selected := w.ListBox().CopyState().Selected; selected != 1 {
+		t.Errorf("got selected %d after wheel up, want 1", selected)
+	}
+}
+
 func TestRefilter(t *testing.T) {
 	onFilter := make(chan string, 100)
 	w := NewComboBox(ComboBoxSpec{
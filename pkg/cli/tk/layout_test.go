@@ -37,57 +37,57 @@ var layoutRenderTests = []struct {
 		"VScrollbar showing full thumb",
 		VScrollbar{4, 0, 3},
 		10, 2,
-		bb(1).WriteStyled(vscrollbarThumb).WriteStyled(vscrollbarThumb),
+		bb(1).Write(" ", stylingForScrollbarThumb).Write(" ", stylingForScrollbarThumb),
 	},
 	{
 		"VScrollbar showing thumb in first half",
 		VScrollbar{4, 0, 1},
 		10, 2,
-		bb(1).WriteStyled(vscrollbarThumb).WriteStyled(vscrollbarTrough),
+		bb(1).Write(" ", stylingForScrollbarThumb).Write("│", stylingForScrollbarTrough),
 	},
 	{
 		"VScrollbar showing a minimal 1-size thumb at beginning",
 		VScrollbar{4, 0, 0},
 		10, 2,
-		bb(1).WriteStyled(vscrollbarThumb).WriteStyled(vscrollbarTrough),
+		bb(1).Write(" ", stylingForScrollbarThumb).Write("│", stylingForScrollbarTrough),
 	},
 	{
 		"VScrollbar showing a minimal 1-size thumb at end",
 		VScrollbar{4, 3, 3},
 		10, 2,
-		bb(1).WriteStyled(vscrollbarTrough).WriteStyled(vscrollbarThumb),
+		bb(1).Write("│", stylingForScrollbarTrough).Write(" ", stylingForScrollbarThumb),
 	},
 	{
 		"VScrollbarContainer",
 		VScrollbarContainer{Label{ui.T("abcd1234")},
 			VScrollbar{4, 0, 1}},
 		5, 2,
-		bb(5).Write("abcd").WriteStyled(vscrollbarThumb).
-			Newline().Write("1234").WriteStyled(vscrollbarTrough),
+		bb(5).Write("abcd").Write(" ", stylingForScrollbarThumb).
+			Newline().Write("1234").Write("│", stylingForScrollbarTrough),
 	},
 	{
 		"HScrollbar showing full thumb",
 		HScrollbar{4, 0, 3},
 		2, 10,
-		bb(2).WriteStyled(hscrollbarThumb).WriteStyled(hscrollbarThumb),
+		bb(2).Write(" ", stylingForScrollbarThumb).Write(" ", stylingForScrollbarThumb),
 	},
 	{
 		"HScrollbar showing thumb in first half",
 		HScrollbar{4, 0, 1},
 		2, 10,
-		bb(2).WriteStyled(hscrollbarThumb).WriteStyled(hscrollbarTrough),
+		bb(2).Write(" ", stylingForScrollbarThumb).Write("━", stylingForScrollbarTrough),
 	},
 	{
 		"HScrollbar showing a minimal 1-size thumb at beginning",
 		HScrollbar{4, 0, 0},
 		2, 10,
-		bb(2).WriteStyled(hscrollbarThumb).WriteStyled(hscrollbarTrough),
+		bb(2).Write(" ", stylingForScrollbarThumb).Write("━", stylingForScrollbarTrough),
 	},
 	{
 		"HScrollbar showing a minimal 1-size thumb at end",
 		HScrollbar{4, 3, 3},
 		2, 10,
-		bb(2).WriteStyled(hscrollbarTrough).WriteStyled(hscrollbarThumb),
+		bb(2).Write("━", stylingForScrollbarTrough).Write(" ", stylingForScrollbarThumb),
 	},
 }
 
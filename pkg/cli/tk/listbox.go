@@ -11,6 +11,7 @@ import (
 // ListBox is a list for displaying and selecting from a list of items.
 type ListBox interface {
 	Widget
+	MouseHandler
 	// CopyState returns a copy of the state.
 	CopyState() ListBoxState
 	// Reset resets the state of the widget with the given items and index of
@@ -76,7 +77,7 @@ func NewListBox(spec ListBoxSpec) ListBox {
 	return &listBox{ListBoxSpec: spec}
 }
 
-var stylingForSelected = ui.Inverse
+var stylingForSelected = ui.DefineRole("selected", ui.Inverse)
 
 func (w *listBox) Render(width, height int) *term.Buffer {
 	if w.Horizontal {
@@ -286,6 +287,82 @@ func (w *listBox) Handle(event term.Event) bool {
 	return false
 }
 
+// HandleMouse selects the item that is clicked with the left button, or
+// accepts it if it is already selected, and moves the selection with the
+// wheel.
+func (w *listBox) HandleMouse(event term.MouseEvent, width, height int) bool {
+	switch {
+	case event.Button == term.WheelUpButton:
+		w.Select(Prev)
+		return true
+	case event.Button == term.WheelDownButton:
+		w.Select(Next)
+		return true
+	case event.Button == term.LeftButton && event.Down:
+		i := w.itemAt(event.Pos, width, height)
+		if i == -1 {
+			return false
+		}
+		if i == w.CopyState().Selected {
+			w.Accept()
+		} else {
+			w.Select(func(ListBoxState) int { return i })
+		}
+		return true
+	}
+	return false
+}
+
+// Returns the index of the item shown at the given position when the listbox
+// was last rendered with the given width and height, or -1 if there is none.
+func (w *listBox) itemAt(pos term.Pos, width, height int) int {
+	s := w.CopyState()
+	if s.Items == nil || s.Items.Len() == 0 {
+		return -1
+	}
+	n := s.Items.Len()
+	if w.Horizontal {
+		// The window has been determined when rendering.
+		if pos.Line >= s.Height {
+			return -1
+		}
+		col, remainedWidth := 0, width
+		for i := s.First; i < n; i += s.Height {
+			colWidth := maxWidth(s.Items, w.Padding, i, i+s.Height)
+			if colWidth > remainedWidth {
+				colWidth = remainedWidth
+			}
+			if pos.Col < col+colWidth {
+				if i+pos.Line < n {
+					return i + pos.Line
+				}
+				return -1
+			}
+			col += colWidth + listBoxColGap
+			remainedWidth -= colWidth
+			if pos.Col < col || remainedWidth <= listBoxColGap {
+				// In the gap after the column, or after the last column.
+				return -1
+			}
+			remainedWidth -= listBoxColGap
+		}
+		return -1
+	}
+	first, crop := getVerticalWindow(s, height)
+	line := 0
+	for i := first; i < n && line < height; i++ {
+		lines := s.Items.Show(i).CountLines()
+		if i == first {
+			lines -= crop
+		}
+		if pos.Line < line+lines {
+			return i
+		}
+		line += lines
+	}
+	return -1
+}
+
 func (w *listBox) CopyState() ListBoxState {
 	w.StateMutex.RLock()
 	defer w.StateMutex.RUnlock()
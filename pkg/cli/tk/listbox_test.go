@@ -348,6 +348,73 @@ func TestListBox_Handle_EnterEmitsAccept(t *testing.T) {
 	}
 }
 
+func TestListBox_HandleMouse(t *testing.T) {
+	var accepted []int
+	w := NewListBox(ListBoxSpec{
+		OnAccept: func(_ Items, i int) { accepted = append(accepted, i) },
+		State:    ListBoxState{Items: TestItems{NItems: 3}}})
+	click := term.MouseEvent{Pos: term.Pos{Line: 1}, Down: true, Button: term.LeftButton}
+
+	w.HandleMouse(click, 10, 4)
+	if selected := w.CopyState().Selected; selected != 1 {
+		t.Errorf("got selected %d after clicking, want 1", selected)
+	}
+	if len(accepted) != 0 {
+		t.Errorf("got accepted %v after clicking unselected item", accepted)
+	}
+	w.HandleMouse(click, 10, 4)
+	if len(accepted) != 1 || accepted[0] != 1 {
+		t.Errorf("got accepted %v after clicking selected item, want [1]", accepted)
+	}
+
+	w.HandleMouse(term.MouseEvent{Down: true, Button: term.WheelDownButton}, 10, 4)
+	if selected := w.CopyState().Selected; selected != 2 {
+		t.Errorf("got selected %d after wheel down, want 2", selected)
+	}
+	w.HandleMouse(term.MouseEvent{Down: true, Button: term.WheelUpButton}, 10, 4)
+	if selected := w.CopyState().Selected; selected != 1 {
+		t.Errorf("got selected %d after wheel up, want 1", selected)
+	}
+}
+
+var listBoxItemAtTests = []struct {
+	name       string
+	horizontal bool
+	state      ListBoxState
+	width      int
+	height     int
+	pos        term.Pos
+	want       int
+}{
+	{"vertical", false, ListBoxState{Items: TestItems{NItems: 3}}, 10, 4, term.Pos{Line: 2}, 2},
+	{"vertical below items", false, ListBoxState{Items: TestItems{NItems: 3}}, 10, 4, term.Pos{Line: 3}, -1},
+	{"vertical cropped", false,
+		ListBoxState{Items: TestItems{NItems: 2, Prefix: "x\n"}, Selected: 1}, 10, 3,
+		// Only the second line of item 0 is shown.
+		term.Pos{Line: 1}, 1},
+	{"vertical empty", false, ListBoxState{Items: TestItems{}}, 10, 4, term.Pos{}, -1},
+	// item 0  item 3  it
+	// item 1  item 4
+	// item 2
+	{"horizontal", true, ListBoxState{Items: TestItems{NItems: 5}}, 18, 4, term.Pos{Line: 1, Col: 9}, 4},
+	{"horizontal in gap", true, ListBoxState{Items: TestItems{NItems: 5}}, 18, 4, term.Pos{Line: 1, Col: 7}, -1},
+	{"horizontal after items", true, ListBoxState{Items: TestItems{NItems: 5}}, 18, 4, term.Pos{Line: 2, Col: 9}, -1},
+	{"horizontal scrollbar", true, ListBoxState{Items: TestItems{NItems: 5}}, 18, 4, term.Pos{Line: 3}, -1},
+}
+
+func TestListBox_ItemAt(t *testing.T) {
+	for _, test := range listBoxItemAtTests {
+		t.Run(test.name, func(t *testing.T) {
+			w := NewListBox(ListBoxSpec{Horizontal: test.horizontal, State: test.state})
+			w.Render(test.width, test.height)
+			got := w.(*listBox).itemAt(test.pos, test.width, test.height)
+			if got != test.want {
+				t.Errorf("got %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
 func TestListBox_Select_ChangeState(t *testing.T) {
 	// number of items = 10, height = 3
 	var tests = []struct {
@@ -5,6 +5,11 @@ import (
 	"src.elv.sh/pkg/ui"
 )
 
+var (
+	stylingForScrollbarThumb  = ui.DefineRole("scrollbar-thumb", ui.Stylings(ui.FgMagenta, ui.Inverse))
+	stylingForScrollbarTrough = ui.DefineRole("scrollbar-trough", ui.FgMagenta)
+)
+
 // VScrollbarContainer is a Renderer consisting of content and a vertical
 // scrollbar on the right.
 type VScrollbarContainer struct {
@@ -25,11 +30,6 @@ type VScrollbar struct {
 	High  int
 }
 
-var (
-	vscrollbarThumb  = ui.T(" ", ui.FgMagenta, ui.Inverse)
-	vscrollbarTrough = ui.T("│", ui.FgMagenta)
-)
-
 func (v VScrollbar) Render(width, height int) *term.Buffer {
 	posLow, posHigh := findScrollInterval(v.Total, v.Low, v.High, height)
 	bb := term.NewBufferBuilder(1)
@@ -38,9 +38,9 @@ func (v VScrollbar) Render(width, height int) *term.Buffer {
 			bb.Newline()
 		}
 		if posLow <= i && i < posHigh {
-			bb.WriteStyled(vscrollbarThumb)
+			bb.Write(" ", stylingForScrollbarThumb)
 		} else {
-			bb.WriteStyled(vscrollbarTrough)
+			bb.Write("│", stylingForScrollbarTrough)
 		}
 	}
 	return bb.Buffer()
@@ -53,19 +53,14 @@ type HScrollbar struct {
 	High  int
 }
 
-var (
-	hscrollbarThumb  = ui.T(" ", ui.FgMagenta, ui.Inverse)
-	hscrollbarTrough = ui.T("━", ui.FgMagenta)
-)
-
 func (h HScrollbar) Render(width, height int) *term.Buffer {
 	posLow, posHigh := findScrollInterval(h.Total, h.Low, h.High, width)
 	bb := term.NewBufferBuilder(width)
 	for i := 0; i < width; i++ {
 		if posLow <= i && i < posHigh {
-			bb.WriteStyled(hscrollbarThumb)
+			bb.Write(" ", stylingForScrollbarThumb)
 		} else {
-			bb.WriteStyled(hscrollbarTrough)
+			bb.Write("━", stylingForScrollbarTrough)
 		}
 	}
 	return bb.Buffer()
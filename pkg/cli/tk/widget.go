@@ -37,6 +37,15 @@ type Handler interface {
 	Handle(event term.Event) bool
 }
 
+// MouseHandler wraps the HandleMouse method.
+type MouseHandler interface {
+	// HandleMouse tries to handle a mouse event and returns whether it has
+	// been handled. The position of the event is relative to the top left
+	// corner of the widget, and the width and height are those of the region
+	// the widget was last rendered onto.
+	HandleMouse(event term.MouseEvent, width, height int) bool
+}
+
 // Bindings is the interface for key bindings.
 type Bindings interface {
 	Handle(Widget, term.Event) bool
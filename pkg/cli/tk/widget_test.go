@@ -35,7 +35,7 @@ func (w *testWidget) Handle(e term.Event) bool {
 func TestDummyBindings(t *testing.T) {
 	w := Empty{}
 	b := DummyBindings{}
-	for _, event := range []term.Event{term.K('a'), term.PasteSetting(true)} {
+	for _, event := range []term.Event{term.K('a'), term.PasteEvent("x")} {
 		if b.Handle(w, event) {
 			t.Errorf("should not handle")
 		}
@@ -109,11 +109,17 @@ func (t *aTTY) CloseReader() {
 
 func (t *aTTY) NotifySignals() <-chan os.Signal {
 	t.sigCh = sys.NotifySignals()
+	// SIGTSTP is ignored by sys.NotifySignals, but the editor decides itself
+	// whether to suspend.
+	signal.Notify(t.sigCh, sys.SIGTSTP)
 	return t.sigCh
 }
 
 func (t *aTTY) StopSignals() {
 	signal.Stop(t.sigCh)
+	// Keep ignoring SIGTSTP after the editor stops handling it; see
+	// sys.NotifySignals.
+	signal.Ignore(sys.SIGTSTP)
 	close(t.sigCh)
 	t.sigCh = nil
 }
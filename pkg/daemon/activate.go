@@ -6,11 +6,13 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"src.elv.sh/pkg/daemon/daemondefs"
 	"src.elv.sh/pkg/daemon/internal/api"
 	"src.elv.sh/pkg/fsutil"
+	"src.elv.sh/pkg/logutil"
 )
 
 var (
@@ -36,9 +38,23 @@ const connectionRefusedFmt = "Socket file %s exists but refuses requests. This i
 
 // Activate returns a daemon client, either by connecting to an existing daemon,
 // or spawning a new one. It always returns a non-nil client, even if there was an error.
+//
+// If the activation succeeds and the daemon goes away later, for example
+// because it was stopped with -daemon-ctl, the client spawns a new one when it
+// can't connect.
 func Activate(stderr io.Writer, spawnCfg *daemondefs.SpawnConfig) (daemondefs.Client, error) {
+	cl := &client{sockPath: spawnCfg.SockPath}
+	err := activate(stderr, spawnCfg, cl)
+	if err == nil {
+		cl.spawnCfg = spawnCfg
+	}
+	return cl, err
+}
+
+// Makes sure that a daemon is serving on spawnCfg.SockPath, using cl to talk
+// to it.
+func activate(stderr io.Writer, spawnCfg *daemondefs.SpawnConfig, cl daemondefs.Client) error {
 	sockpath := spawnCfg.SockPath
-	cl := NewClient(sockpath)
 	status, err := detectDaemon(sockpath, cl)
 	shouldSpawn := false
 
@@ -47,34 +63,34 @@ func Activate(stderr io.Writer, spawnCfg *daemondefs.SpawnConfig) (daemondefs.Cl
 	case sockfileMissing:
 		shouldSpawn = true
 	case sockfileOtherError:
-		return cl, fmt.Errorf("socket file %s inaccessible: %w", sockpath, err)
+		return fmt.Errorf("socket file %s inaccessible: %w", sockpath, err)
 	case connectionRefused:
 		fmt.Fprintf(stderr, connectionRefusedFmt, sockpath)
 		err := os.Remove(sockpath)
 		if err != nil {
-			return cl, fmt.Errorf("failed to remove socket file: %w", err)
+			return fmt.Errorf("failed to remove socket file: %w", err)
 		}
 		shouldSpawn = true
 	case connectionOtherError:
-		return cl, fmt.Errorf("unexpected RPC error on socket %s: %w", sockpath, err)
+		return fmt.Errorf("unexpected RPC error on socket %s: %w", sockpath, err)
 	case daemonOutdated:
 		fmt.Fprintln(stderr, "Daemon is outdated; going to kill old daemon and re-spawn")
 		err := killDaemon(sockpath, cl)
 		if err != nil {
-			return cl, fmt.Errorf("failed to kill old daemon: %w", err)
+			return fmt.Errorf("failed to kill old daemon: %w", err)
 		}
 		shouldSpawn = true
 	default:
-		return cl, fmt.Errorf("code bug: unknown daemon status %d", status)
+		return fmt.Errorf("code bug: unknown daemon status %d", status)
 	}
 
 	if !shouldSpawn {
-		return cl, nil
+		return nil
 	}
 
 	err = spawn(spawnCfg)
 	if err != nil {
-		return cl, fmt.Errorf("failed to spawn daemon: %w", err)
+		return fmt.Errorf("failed to spawn daemon: %w", err)
 	}
 
 	// Wait for daemon to come online
@@ -85,23 +101,23 @@ func Activate(stderr io.Writer, spawnCfg *daemondefs.SpawnConfig) (daemondefs.Cl
 
 		switch status {
 		case daemonOK:
-			return cl, nil
+			return nil
 		case sockfileMissing:
 			// Continue waiting
 		case sockfileOtherError:
-			return cl, fmt.Errorf("socket file %s inaccessible: %w", sockpath, err)
+			return fmt.Errorf("socket file %s inaccessible: %w", sockpath, err)
 		case connectionRefused:
 			// Continue waiting
 		case connectionOtherError:
-			return cl, fmt.Errorf("unexpected RPC error on socket %s: %w", sockpath, err)
+			return fmt.Errorf("unexpected RPC error on socket %s: %w", sockpath, err)
 		case daemonOutdated:
-			return cl, fmt.Errorf("code bug: newly spawned daemon is outdated")
+			return fmt.Errorf("code bug: newly spawned daemon is outdated")
 		default:
-			return cl, fmt.Errorf("code bug: unknown daemon status %d", status)
+			return fmt.Errorf("code bug: unknown daemon status %d", status)
 		}
 		time.Sleep(daemonSpawnWaitPerLoop)
 	}
-	return cl, fmt.Errorf("daemon did not come up within %v", daemonSpawnTimeout)
+	return fmt.Errorf("daemon did not come up within %v", daemonSpawnTimeout)
 }
 
 func detectDaemon(sockpath string, cl daemondefs.Client) (daemonStatus, error) {
@@ -141,6 +157,13 @@ func killDaemon(sockpath string, cl daemondefs.Client) error {
 	}
 	// Wait until the old daemon has removed the socket file, so that it doesn't
 	// inadvertently remove the socket file of the new daemon we will start.
+	if err := waitForSockRemoval(sockpath); err != nil {
+		return fmt.Errorf("kill daemon: %w", err)
+	}
+	return nil
+}
+
+func waitForSockRemoval(sockpath string) error {
 	start := time.Now()
 	for time.Since(start) < daemonKillTimeout {
 		_, err := os.Lstat(sockpath)
@@ -149,10 +172,10 @@ func killDaemon(sockpath string, cl daemondefs.Client) error {
 		} else if os.IsNotExist(err) {
 			return nil
 		} else {
-			return fmt.Errorf("kill daemon: %w", err)
+			return err
 		}
 	}
-	return fmt.Errorf("kill daemon: daemon did not remove socket within %v", daemonKillTimeout)
+	return fmt.Errorf("daemon did not remove socket within %v", daemonKillTimeout)
 }
 
 // Can be overridden in tests to avoid actual forking.
@@ -163,8 +186,9 @@ var startProcess = func(name string, argv []string, attr *os.ProcAttr) error {
 
 // Spawns a daemon process in the background by invoking BinPath, passing
 // BinPath, DbPath and SockPath as command-line arguments after resolving them
-// to absolute paths. The daemon log file is created in RunDir, and the stdout
-// and stderr of the daemon is redirected to the log file.
+// to absolute paths. The daemon log file is created in RunDir and passed with
+// -log, together with the current logging configuration, and the stdout and
+// stderr of the daemon is redirected to the log file.
 //
 // A suitable ProcAttr is chosen depending on the OS and makes sure that the
 // daemon is detached from the current terminal, so that it is not affected by
@@ -205,6 +229,19 @@ func spawn(cfg *daemondefs.SpawnConfig) error {
 		return err
 	}
 	defer out.Close()
+	// Also pass the log file with -log, so that the daemon can rotate it. The
+	// stdout and stderr still go to the file, so that crashes are recorded.
+	logPath, err := filepath.Abs(out.Name())
+	if err != nil {
+		return err
+	}
+	logConfig := logutil.GetConfig()
+	args = append(args,
+		"-log", logPath,
+		"-log-level", logConfig.Level.String(),
+		"-log-format", logConfig.Format.String(),
+		"-log-max-size", strconv.FormatInt(logConfig.MaxSize, 10),
+		"-log-max-backups", strconv.Itoa(logConfig.MaxBackups))
 
 	procattrs := procAttrForSpawn([]*os.File{in, out, out})
 
@@ -2,8 +2,10 @@ package daemon
 
 import (
 	"errors"
+	"io"
 	"net"
 	"sync"
+	"time"
 
 	"src.elv.sh/pkg/daemon/daemondefs"
 	"src.elv.sh/pkg/daemon/internal/api"
@@ -13,6 +15,12 @@ import (
 
 const retriesOnShutdown = 3
 
+// How long to wait after a failure to spawn the daemon before trying again.
+// Spawning can fail temporarily, for example when the database is locked.
+const respawnBackoff = 10 * time.Second
+
+var timeNow = time.Now // to allow mocking in tests
+
 var (
 	// ErrDaemonUnreachable is returned when the daemon cannot be reached after
 	// several retries.
@@ -24,12 +32,18 @@ type client struct {
 	sockPath  string
 	rpcClient *rpc.Client
 	waits     sync.WaitGroup
+	// If not nil, used to spawn a new daemon when the socket can't be
+	// connected to. After a failure to spawn, no more attempts are made for
+	// respawnBackoff.
+	spawnCfg        *daemondefs.SpawnConfig
+	respawnMutex    sync.Mutex
+	respawnFailedAt time.Time
 }
 
 // NewClient creates a new Client instance that talks to the socket. Connection
 // creation is deferred to the first request.
 func NewClient(sockPath string) daemondefs.Client {
-	return &client{sockPath, nil, sync.WaitGroup{}}
+	return &client{sockPath: sockPath}
 }
 
 // SockPath returns the socket path that the Client talks to. If the client is
@@ -63,6 +77,16 @@ func (c *client) call(f string, req, res any) error {
 	for attempt := 0; attempt < retriesOnShutdown; attempt++ {
 		if c.rpcClient == nil {
 			conn, err := net.Dial("unix", c.sockPath)
+			if err != nil && c.spawnCfg != nil {
+				var probe daemondefs.Client
+				conn, probe, err = c.respawn()
+				if probe != nil {
+					// Keep the connection used for detecting the new daemon
+					// until this call has been served, so that the daemon
+					// doesn't exit in between for having no clients.
+					defer probe.Close()
+				}
+			}
 			if err != nil {
 				return err
 			}
@@ -81,6 +105,26 @@ func (c *client) call(f string, req, res any) error {
 	return ErrDaemonUnreachable
 }
 
+// Spawns a new daemon and connects to it. It also returns the client used for
+// detecting the daemon, which the caller should close.
+func (c *client) respawn() (net.Conn, daemondefs.Client, error) {
+	c.respawnMutex.Lock()
+	defer c.respawnMutex.Unlock()
+	if !c.respawnFailedAt.IsZero() && timeNow().Sub(c.respawnFailedAt) < respawnBackoff {
+		return nil, nil, ErrDaemonUnreachable
+	}
+	logger.Infof("cannot connect to daemon, spawning a new one")
+	probe := NewClient(c.sockPath)
+	if err := activate(io.Discard, c.spawnCfg, probe); err != nil {
+		logger.Errorf("failed to spawn daemon: %v", err)
+		c.respawnFailedAt = timeNow()
+		probe.Close()
+		return nil, nil, err
+	}
+	conn, err := net.Dial("unix", c.sockPath)
+	return conn, probe, err
+}
+
 // Convenience methods for RPC methods. These are quite repetitive; when the
 // number of RPC calls grow above some threshold, a code generator should be
 // written to generate them.
@@ -99,6 +143,20 @@ func (c *client) Pid() (int, error) {
 	return res.Pid, err
 }
 
+func (c *client) Status() (daemondefs.Status, error) {
+	req := &api.StatusRequest{}
+	res := &api.StatusResponse{}
+	err := c.call("Status", req, res)
+	return res.Status, err
+}
+
+func (c *client) Compact() (before, after int64, err error) {
+	req := &api.CompactRequest{}
+	res := &api.CompactResponse{}
+	err = c.call("Compact", req, res)
+	return res.Before, res.After, err
+}
+
 func (c *client) NextCmdSeq() (int, error) {
 	req := &api.NextCmdRequest{}
 	res := &api.NextCmdSeqResponse{}
@@ -148,6 +206,20 @@ func (c *client) PrevCmd(upto int, prefix string) (storedefs.Cmd, error) {
 	return storedefs.Cmd{Text: res.Text, Seq: res.Seq}, err
 }
 
+func (c *client) SyncCmds() ([]storedefs.SyncCmd, error) {
+	req := &api.SyncCmdsRequest{}
+	res := &api.SyncCmdsResponse{}
+	err := c.call("SyncCmds", req, res)
+	return res.Cmds, err
+}
+
+func (c *client) MergeCmds(cmds []storedefs.SyncCmd) (int, error) {
+	req := &api.MergeCmdsRequest{Cmds: cmds}
+	res := &api.MergeCmdsResponse{}
+	err := c.call("MergeCmds", req, res)
+	return res.Added, err
+}
+
 func (c *client) AddDir(dir string, incFactor float64) error {
 	req := &api.AddDirRequest{Dir: dir, IncFactor: incFactor}
 	res := &api.AddDirResponse{}
@@ -168,3 +240,31 @@ func (c *client) Dirs(blacklist map[string]struct{}) ([]storedefs.Dir, error) {
 	err := c.call("Dirs", req, res)
 	return res.Dirs, err
 }
+
+func (c *client) Value(ns, key string) (storedefs.Value, error) {
+	req := &api.ValueRequest{NS: ns, Key: key}
+	res := &api.ValueResponse{}
+	err := c.call("Value", req, res)
+	return res.Value, err
+}
+
+func (c *client) SetValue(ns, key, data string, version int) error {
+	req := &api.SetValueRequest{NS: ns, Key: key, Data: data, Version: version}
+	res := &api.SetValueResponse{}
+	err := c.call("SetValue", req, res)
+	return err
+}
+
+func (c *client) DelValue(ns, key string, version int) error {
+	req := &api.DelValueRequest{NS: ns, Key: key, Version: version}
+	res := &api.DelValueResponse{}
+	err := c.call("DelValue", req, res)
+	return err
+}
+
+func (c *client) ValueKeys(ns string) ([]string, error) {
+	req := &api.ValueKeysRequest{NS: ns}
+	res := &api.ValueKeysResponse{}
+	err := c.call("ValueKeys", req, res)
+	return res.Keys, err
+}
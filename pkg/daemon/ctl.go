@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"src.elv.sh/pkg/daemon/daemondefs"
+	"src.elv.sh/pkg/daemon/internal/api"
+	"src.elv.sh/pkg/prog"
+	"src.elv.sh/pkg/rpc"
+)
+
+// CtlProgram is the subprogram for managing the daemon with -daemon-ctl.
+type CtlProgram struct {
+	// Returns the configuration for spawning the daemon, with the paths from
+	// the -db and -sock flags if they are set.
+	SpawnConfig func(*prog.DaemonPaths, io.Writer) (*daemondefs.SpawnConfig, error)
+
+	run   bool
+	paths *prog.DaemonPaths
+	json  *bool
+}
+
+func (p *CtlProgram) RegisterFlags(fs *prog.FlagSet) {
+	fs.BoolVar(&p.run, "daemon-ctl", false,
+		"Manage the storage daemon; the argument is one of status, stop, restart and\ncompact")
+	p.paths = fs.DaemonPaths()
+	p.json = fs.JSON()
+}
+
+func (p *CtlProgram) Run(fds [3]*os.File, args []string) error {
+	if !p.run {
+		return prog.NextProgram()
+	}
+	if len(args) != 1 {
+		return prog.BadUsage("-daemon-ctl requires one argument: status, stop, restart or compact")
+	}
+	action := args[0]
+	switch action {
+	case "status", "stop", "restart", "compact":
+	default:
+		return prog.BadUsage(fmt.Sprintf("unknown -daemon-ctl action %q", action))
+	}
+
+	cfg, err := p.SpawnConfig(p.paths, fds[2])
+	if err != nil {
+		return err
+	}
+	cl := NewClient(cfg.SockPath)
+	defer cl.Close()
+	status, err := detectDaemon(cfg.SockPath, cl)
+	running := status == daemonOK || status == daemonOutdated
+	switch status {
+	case sockfileOtherError:
+		return fmt.Errorf("socket file %s inaccessible: %w", cfg.SockPath, err)
+	case connectionOtherError:
+		return fmt.Errorf("unexpected RPC error on socket %s: %w", cfg.SockPath, err)
+	}
+
+	if action == "status" {
+		if !running {
+			fmt.Fprintln(fds[2], "daemon is not running")
+			return prog.Exit(1)
+		}
+		st, err := cl.Status()
+		if err != nil {
+			return err
+		}
+		writeStatus(fds[1], st, *p.json)
+		return nil
+	}
+
+	if action == "compact" {
+		// The database is compacted by the daemon, which is started if it
+		// is not running, so that no other process can open the database
+		// while it is being replaced. Outdated daemons are replaced by
+		// Activate.
+		newCl, err := Activate(fds[2], cfg)
+		defer newCl.Close()
+		if err != nil {
+			return err
+		}
+		before, after, err := newCl.Compact()
+		if err != nil {
+			return fmt.Errorf("compact %s: %w", cfg.DbPath, err)
+		}
+		fmt.Fprintf(fds[1], "compacted %s from %d to %d bytes\n", cfg.DbPath, before, after)
+		return nil
+	}
+
+	if running {
+		pid, err := cl.Pid()
+		if err != nil {
+			return err
+		}
+		if status == daemonOutdated {
+			// Daemons older than the Stop RPC have to be killed.
+			err = killDaemon(cfg.SockPath, cl)
+		} else {
+			err = stopDaemon(cfg.SockPath)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(fds[1], "stopped daemon (pid %d)\n", pid)
+	} else if action == "stop" {
+		fmt.Fprintln(fds[2], "daemon is not running")
+		return nil
+	}
+
+	if action == "restart" {
+		newCl, err := Activate(fds[2], cfg)
+		defer newCl.Close()
+		if err != nil {
+			return err
+		}
+		pid, err := newCl.Pid()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(fds[1], "started daemon (pid %d)\n", pid)
+	}
+	return nil
+}
+
+// Asks the daemon listening on sockpath to stop, and waits until it has
+// removed the socket file.
+func stopDaemon(sockpath string) error {
+	conn, err := net.Dial("unix", sockpath)
+	if err != nil {
+		return fmt.Errorf("stop daemon: %w", err)
+	}
+	rpcClient := rpc.NewClient(conn)
+	defer rpcClient.Close()
+	err = rpcClient.Call(api.ServiceName+".Stop", &api.StopRequest{}, &api.StopResponse{})
+	// The daemon may close the connection before the response arrives.
+	if err != nil && err != rpc.ErrShutdown && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("stop daemon: %w", err)
+	}
+	if err := waitForSockRemoval(sockpath); err != nil {
+		return fmt.Errorf("stop daemon: %w", err)
+	}
+	return nil
+}
+
+func writeStatus(w io.Writer, st daemondefs.Status, useJSON bool) {
+	if useJSON {
+		json.NewEncoder(w).Encode(st)
+		return
+	}
+	fmt.Fprintf(w, "pid: %d\n", st.Pid)
+	fmt.Fprintf(w, "version: %d\n", st.Version)
+	fmt.Fprintf(w, "uptime: %v\n", time.Since(st.StartTime).Round(time.Second))
+	fmt.Fprintf(w, "clients: %d\n", st.Clients)
+	fmt.Fprintf(w, "db: %s (%d bytes)\n", st.DBPath, st.DBSize)
+	if st.DBError != "" {
+		fmt.Fprintf(w, "db error: %s\n", st.DBError)
+	}
+}
@@ -0,0 +1,217 @@
+package daemon
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/daemon/daemondefs"
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/prog"
+	. "src.elv.sh/pkg/prog/progtest"
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestCtlProgram_NoDaemon(t *testing.T) {
+	setup(t)
+
+	Test(t, ctlProgram(),
+		ThatElvish("-daemon-ctl", "status").
+			ExitsWith(1).
+			WritesStderr("daemon is not running\n"),
+		ThatElvish("-daemon-ctl", "stop").
+			WritesStderr("daemon is not running\n"),
+	)
+}
+
+func TestCtlProgram_Status(t *testing.T) {
+	setup(t)
+	startServer(t, cli("sock", "db"))
+	startClient(t, "sock")
+
+	Test(t, ctlProgram(),
+		ThatElvish("-daemon-ctl", "status").
+			WritesStdoutContaining("clients: 2\ndb: db ("),
+		ThatElvish("-daemon-ctl", "-json", "status").
+			WritesStdoutContaining(`"DBPath":"db"`),
+	)
+}
+
+func TestCtlProgram_StopWithClients(t *testing.T) {
+	setup(t)
+	server := startServer(t, cli("sock", "db"))
+	client := startClient(t, "sock")
+
+	Test(t, ctlProgram(),
+		ThatElvish("-daemon-ctl", "stop").
+			WritesStdoutContaining("stopped daemon (pid "))
+
+	server.WaitQuit()
+	if _, err := os.Lstat("sock"); !os.IsNotExist(err) {
+		t.Errorf("socket file still exists after stopping")
+	}
+	if _, err := client.Version(); err == nil {
+		t.Errorf("client.Version() returns nil error, want non-nil")
+	}
+}
+
+func TestCtlProgram_Restart(t *testing.T) {
+	spawned := 0
+	setupForActivate(t, func(name string, argv []string, attr *os.ProcAttr) error {
+		startServer(t, argv)
+		spawned++
+		return nil
+	})
+	client, err := Activate(io.Discard,
+		&daemondefs.SpawnConfig{DbPath: "db", SockPath: "sock", RunDir: "."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	Test(t, ctlProgram(),
+		ThatElvish("-daemon-ctl", "restart").
+			WritesStdoutContaining("stopped daemon").
+			WritesStdoutContaining("started daemon"))
+
+	if spawned < 2 {
+		t.Errorf("spawned daemon %v times, want at least 2", spawned)
+	}
+	// The client connects to the new daemon, or spawns another one if the new
+	// daemon has exited after the -daemon-ctl process disconnected.
+	if _, err := client.Version(); err != nil {
+		t.Errorf("client.Version() -> error %v, want nil", err)
+	}
+}
+
+func TestCtlProgram_ClientsRespawnStoppedDaemon(t *testing.T) {
+	spawned := 0
+	setupForActivate(t, func(name string, argv []string, attr *os.ProcAttr) error {
+		startServer(t, argv)
+		spawned++
+		return nil
+	})
+	client, err := Activate(io.Discard,
+		&daemondefs.SpawnConfig{DbPath: "db", SockPath: "sock", RunDir: "."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	Test(t, ctlProgram(),
+		ThatElvish("-daemon-ctl", "stop").WritesStdoutContaining("stopped daemon"))
+	if _, err := client.AddCmd("echo"); err != nil {
+		t.Errorf("client.AddCmd() -> error %v, want nil", err)
+	}
+	if spawned != 2 {
+		t.Errorf("spawned daemon %v times, want 2", spawned)
+	}
+}
+
+func TestClient_RetriesRespawnAfterBackoff(t *testing.T) {
+	spawnErr := errors.New("cannot spawn")
+	setupForActivate(t, func(name string, argv []string, attr *os.ProcAttr) error {
+		if spawnErr != nil {
+			return spawnErr
+		}
+		startServer(t, argv)
+		return nil
+	})
+	now := time.Now()
+	testutil.Set(t, &timeNow, func() time.Time { return now })
+	cl := &client{sockPath: "sock",
+		spawnCfg: &daemondefs.SpawnConfig{DbPath: "db", SockPath: "sock", RunDir: "."}}
+	defer cl.Close()
+
+	if _, err := cl.Version(); err == nil {
+		t.Errorf("client.Version() -> nil error, want non-nil")
+	}
+	spawnErr = nil
+	if _, err := cl.Version(); err != ErrDaemonUnreachable {
+		t.Errorf("client.Version() -> error %v, want ErrDaemonUnreachable", err)
+	}
+	now = now.Add(respawnBackoff)
+	if _, err := cl.Version(); err != nil {
+		t.Errorf("client.Version() -> error %v, want nil", err)
+	}
+}
+
+func TestCtlProgram_Compact(t *testing.T) {
+	spawned := 0
+	setupForActivate(t, func(name string, argv []string, attr *os.ProcAttr) error {
+		startServer(t, argv)
+		spawned++
+		return nil
+	})
+	st, err := store.NewStore("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		st.AddCmd(strings.Repeat("x", 10000))
+		st.DelCmd(i + 1)
+	}
+	st.Close()
+
+	// Without a running daemon, one is started to compact the database.
+	Test(t, ctlProgram(),
+		ThatElvish("-daemon-ctl", "compact").
+			WritesStdoutContaining("compacted db from "))
+	if spawned != 1 {
+		t.Errorf("spawned daemon %v times, want 1", spawned)
+	}
+
+	// A running daemon compacts the database without being restarted, and
+	// its clients keep working.
+	client, err := Activate(io.Discard,
+		&daemondefs.SpawnConfig{DbPath: "db", SockPath: "sock", RunDir: "."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	Test(t, ctlProgram(),
+		ThatElvish("-daemon-ctl", "compact").
+			WritesStdoutContaining("compacted db from "))
+	if _, err := client.AddCmd("echo"); err != nil {
+		t.Errorf("client.AddCmd() -> error %v, want nil", err)
+	}
+}
+
+func TestCtlProgram_BadUsage(t *testing.T) {
+	setup(t)
+	must.CreateEmpty("not-dir")
+
+	Test(t, ctlProgram(),
+		ThatElvish("-daemon-ctl").
+			ExitsWith(2).
+			WritesStderrContaining("-daemon-ctl requires one argument"),
+		ThatElvish("-daemon-ctl", "bad").
+			ExitsWith(2).
+			WritesStderrContaining(`unknown -daemon-ctl action "bad"`),
+		ThatElvish("-daemon-ctl", "-sock", "not-dir/sock", "status").
+			ExitsWith(2).
+			WritesStderrContaining("socket file not-dir/sock inaccessible"),
+		ThatElvish().
+			ExitsWith(2).
+			WritesStderr("internal error: no suitable subprogram\n"),
+	)
+}
+
+// Returns a CtlProgram using the -db and -sock flags, with "db" and "sock" as
+// the defaults.
+func ctlProgram() *CtlProgram {
+	return &CtlProgram{SpawnConfig: func(p *prog.DaemonPaths, _ io.Writer) (*daemondefs.SpawnConfig, error) {
+		cfg := &daemondefs.SpawnConfig{DbPath: "db", SockPath: "sock", RunDir: "."}
+		if p.DB != "" {
+			cfg.DbPath = p.DB
+		}
+		if p.Sock != "" {
+			cfg.SockPath = p.Sock
+		}
+		return cfg, nil
+	}}
+}
@@ -6,6 +6,7 @@ package daemondefs
 
 import (
 	"io"
+	"time"
 
 	"src.elv.sh/pkg/store/storedefs"
 )
@@ -17,11 +18,43 @@ type Client interface {
 	ResetConn() error
 	Close() error
 
+	// Compact compacts the database of the daemon, and returns its sizes
+	// before and after.
+	Compact() (before, after int64, err error)
 	Pid() (int, error)
 	SockPath() string
+	Status() (Status, error)
 	Version() (int, error)
 }
 
+// Status contains information about a running daemon, used for diagnosing
+// problems with it.
+type Status struct {
+	Pid     int
+	Version int
+	// When the daemon started serving.
+	StartTime time.Time
+	// The number of clients currently connected.
+	Clients int
+	// The path and size of the database file.
+	DBPath string
+	DBSize int64
+	// The error opening the database, if any.
+	DBError string
+	// Statistics of RPC calls that access the database, keyed by their names
+	// like "AddCmd".
+	Calls map[string]CallStats
+}
+
+// CallStats contains statistics of an RPC call.
+type CallStats struct {
+	Count int
+	// The total and the maximum time spent serving the call.
+	Total, Max time.Duration
+	// The time spent serving the last call.
+	Last time.Duration
+}
+
 // ActivateFunc is a function that activates a daemon client, possibly by
 // spawning a new daemon and connecting to it.
 type ActivateFunc func(stderr io.Writer, spawnCfg *SpawnConfig) (Client, error)
@@ -3,11 +3,12 @@
 package api
 
 import (
+	"src.elv.sh/pkg/daemon/daemondefs"
 	"src.elv.sh/pkg/store/storedefs"
 )
 
 // Version is the API version. It should be bumped any time the API changes.
-const Version = -93
+const Version = -88
 
 // ServiceName is the name of the RPC service exposed by the daemon.
 const ServiceName = "Daemon"
@@ -26,6 +27,22 @@ type PidResponse struct {
 	Pid int
 }
 
+type StatusRequest struct{}
+
+type StatusResponse struct {
+	Status daemondefs.Status
+}
+
+type StopRequest struct{}
+
+type StopResponse struct{}
+
+type CompactRequest struct{}
+
+type CompactResponse struct {
+	Before, After int64
+}
+
 // Cmd requests.
 
 type NextCmdSeqRequest struct{}
@@ -95,6 +112,20 @@ type PrevCmdResponse struct {
 	Text string
 }
 
+type SyncCmdsRequest struct{}
+
+type SyncCmdsResponse struct {
+	Cmds []storedefs.SyncCmd
+}
+
+type MergeCmdsRequest struct {
+	Cmds []storedefs.SyncCmd
+}
+
+type MergeCmdsResponse struct {
+	Added int
+}
+
 // Dir requests.
 
 type AddDirRequest struct {
@@ -117,3 +148,39 @@ type DirsRequest struct {
 type DirsResponse struct {
 	Dirs []storedefs.Dir
 }
+
+// Key-value requests.
+
+type ValueRequest struct {
+	NS  string
+	Key string
+}
+
+type ValueResponse struct {
+	Value storedefs.Value
+}
+
+type SetValueRequest struct {
+	NS      string
+	Key     string
+	Data    string
+	Version int
+}
+
+type SetValueResponse struct{}
+
+type DelValueRequest struct {
+	NS      string
+	Key     string
+	Version int
+}
+
+type DelValueResponse struct{}
+
+type ValueKeysRequest struct {
+	NS string
+}
+
+type ValueKeysResponse struct {
+	Keys []string
+}
@@ -0,0 +1,72 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"src.elv.sh/pkg/daemon/daemondefs"
+	"src.elv.sh/pkg/prog"
+	"src.elv.sh/pkg/store"
+)
+
+// MigrateProgram is the subprogram for migrating the database to the latest
+// schema version with -db-migrate.
+//
+// The daemon also migrates the database when it starts, so this is only
+// needed for maintenance, like checking that a database can be migrated
+// before switching to a new version of Elvish.
+type MigrateProgram struct {
+	// Returns the configuration for spawning the daemon, with the paths from
+	// the -db and -sock flags if they are set.
+	SpawnConfig func(*prog.DaemonPaths, io.Writer) (*daemondefs.SpawnConfig, error)
+
+	run   bool
+	paths *prog.DaemonPaths
+}
+
+var errDaemonRunning = errors.New(
+	"the daemon is running and has the database open; stop it with -daemon-ctl stop first")
+
+func (p *MigrateProgram) RegisterFlags(fs *prog.FlagSet) {
+	fs.BoolVar(&p.run, "db-migrate", false,
+		"Migrate the database to the latest schema version while the storage daemon\nis not running")
+	p.paths = fs.DaemonPaths()
+}
+
+func (p *MigrateProgram) Run(fds [3]*os.File, args []string) error {
+	if !p.run {
+		return prog.NextProgram()
+	}
+	if len(args) > 0 {
+		return prog.BadUsage("arguments are not allowed with -db-migrate")
+	}
+
+	cfg, err := p.SpawnConfig(p.paths, fds[2])
+	if err != nil {
+		return err
+	}
+	cl := NewClient(cfg.SockPath)
+	defer cl.Close()
+	status, err := detectDaemon(cfg.SockPath, cl)
+	switch status {
+	case daemonOK, daemonOutdated:
+		return errDaemonRunning
+	case sockfileOtherError:
+		return fmt.Errorf("socket file %s inaccessible: %w", cfg.SockPath, err)
+	case connectionOtherError:
+		return fmt.Errorf("unexpected RPC error on socket %s: %w", cfg.SockPath, err)
+	}
+
+	from, to, err := store.Migrate(cfg.DbPath)
+	if err != nil {
+		return fmt.Errorf("migrate %s: %w", cfg.DbPath, err)
+	}
+	if from == to {
+		fmt.Fprintf(fds[1], "%s is already at schema version %d\n", cfg.DbPath, to)
+	} else {
+		fmt.Fprintf(fds[1], "migrated %s from schema version %d to %d\n", cfg.DbPath, from, to)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"src.elv.sh/pkg/daemon/daemondefs"
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/prog"
+	. "src.elv.sh/pkg/prog/progtest"
+	"src.elv.sh/pkg/store"
+)
+
+func TestMigrateProgram(t *testing.T) {
+	setup(t)
+	latest := store.SchemaVersion()
+
+	Test(t, migrateProgram(),
+		ThatElvish("-db-migrate").
+			WritesStdout(fmt.Sprintf("migrated db from schema version 0 to %d\n", latest)),
+		ThatElvish("-db-migrate").
+			WritesStdout(fmt.Sprintf("db is already at schema version %d\n", latest)),
+		ThatElvish("-db-migrate", "arg").
+			ExitsWith(2).
+			WritesStderrContaining("arguments are not allowed with -db-migrate"),
+	)
+}
+
+func TestMigrateProgram_BadDB(t *testing.T) {
+	setup(t)
+	must.WriteFile("db", "not a valid bolt database")
+
+	Test(t, migrateProgram(),
+		ThatElvish("-db-migrate").
+			ExitsWith(2).
+			WritesStderrContaining("migrate db: "),
+	)
+}
+
+func TestMigrateProgram_DaemonRunning(t *testing.T) {
+	setup(t)
+	startServer(t, cli("sock", "db"))
+
+	Test(t, migrateProgram(),
+		ThatElvish("-db-migrate").
+			ExitsWith(2).
+			WritesStderrContaining("the daemon is running"),
+	)
+}
+
+// Returns a MigrateProgram with "db" and "sock" as the paths.
+func migrateProgram() *MigrateProgram {
+	return &MigrateProgram{SpawnConfig: func(*prog.DaemonPaths, io.Writer) (*daemondefs.SpawnConfig, error) {
+		return &daemondefs.SpawnConfig{DbPath: "db", SockPath: "sock", RunDir: "."}, nil
+	}}
+}
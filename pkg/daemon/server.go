@@ -9,6 +9,7 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 
 	"src.elv.sh/pkg/daemon/internal/api"
@@ -42,9 +43,12 @@ func (p *Program) Run(fds [3]*os.File, args []string) error {
 		return prog.BadUsage("arguments are not allowed with -daemon")
 	}
 
-	// The stdout is redirected to a unique log file (see the spawn function),
-	// so just use it for logging.
-	logutil.SetOutput(fds[1])
+	// The spawn function passes the log file with -log, so that it can be
+	// rotated. Without -log, log to stdout, which is redirected to the log file
+	// by older versions of spawn.
+	if logutil.OutputFile() == "" {
+		logutil.SetOutput(fds[1])
+	}
 	setUmaskForDaemon()
 	exit := Serve(p.paths.Sock, p.paths.DB, p.serveOpts)
 	return prog.Exit(exit)
@@ -65,19 +69,19 @@ type ServeOpts struct {
 // and serving data from dbpath until all clients have exited. See doc for
 // ServeOpts for additional options.
 func Serve(sockpath, dbpath string, opts ServeOpts) int {
-	logger.Println("pid is", syscall.Getpid())
-	logger.Println("going to listen", sockpath)
+	logger.Infof("pid is %d", syscall.Getpid())
+	logger.Infof("going to listen %s", sockpath)
 	listener, err := net.Listen("unix", sockpath)
 	if err != nil {
-		logger.Printf("failed to listen on %s: %v", sockpath, err)
-		logger.Println("aborting")
+		logger.Errorf("failed to listen on %s: %v", sockpath, err)
+		logger.Errorf("aborting")
 		return 2
 	}
 
 	st, err := store.NewStore(dbpath)
 	if err != nil {
-		logger.Printf("failed to create storage: %v", err)
-		logger.Printf("serving anyway")
+		logger.Errorf("failed to create storage: %v", err)
+		logger.Warnf("serving anyway")
 	}
 
 	server := rpc.NewServer()
@@ -85,7 +89,8 @@ func Serve(sockpath, dbpath string, opts ServeOpts) int {
 	if opts.Version != nil {
 		version = *opts.Version
 	}
-	server.RegisterName(api.ServiceName, &service{version, st, err})
+	svc := newService(version, st, err, dbpath)
+	server.RegisterName(api.ServiceName, svc)
 
 	connCh := make(chan net.Conn, 10)
 	listenErrCh := make(chan error, 1)
@@ -113,9 +118,9 @@ func Serve(sockpath, dbpath string, opts ServeOpts) int {
 
 	interrupt := func() {
 		if len(conns) == 0 {
-			logger.Println("exiting since there are no clients")
+			logger.Infof("exiting since there are no clients")
 		}
-		logger.Printf("going to close %v active connections", len(conns))
+		logger.Infof("going to close %v active connections", len(conns))
 		for conn := range conns {
 			// Ignore the error - if we can't close the connection it's because
 			// the client has closed it. There is nothing we can do anyway.
@@ -131,26 +136,32 @@ loop:
 	for {
 		select {
 		case sig := <-sigCh:
-			logger.Printf("received signal %v", sig)
+			logger.Infof("received signal %v", sig)
+			interrupt()
+			break loop
+		case <-svc.stop:
+			logger.Infof("received stop request")
 			interrupt()
 			break loop
 		case err := <-listenErrCh:
-			logger.Println("could not listen:", err)
+			logger.Errorf("could not listen: %v", err)
 			if len(conns) == 0 {
-				logger.Println("exiting since there are no clients")
+				logger.Infof("exiting since there are no clients")
 				break loop
 			}
-			logger.Println("continuing to serve until all existing clients exit")
+			logger.Infof("continuing to serve until all existing clients exit")
 		case conn := <-connCh:
 			conns[conn] = struct{}{}
+			atomic.StoreInt32(&svc.clients, int32(len(conns)))
 			go func() {
 				server.ServeConn(conn)
 				connDoneCh <- conn
 			}()
 		case conn := <-connDoneCh:
 			delete(conns, conn)
+			atomic.StoreInt32(&svc.clients, int32(len(conns)))
 			if len(conns) == 0 {
-				logger.Println("all clients disconnected, exiting")
+				logger.Infof("all clients disconnected, exiting")
 				break loop
 			}
 		}
@@ -158,17 +169,17 @@ loop:
 
 	err = os.Remove(sockpath)
 	if err != nil {
-		logger.Printf("failed to remove socket %s: %v", sockpath, err)
+		logger.Warnf("failed to remove socket %s: %v", sockpath, err)
 	}
 	if st != nil {
 		err = st.Close()
 		if err != nil {
-			logger.Printf("failed to close storage: %v", err)
+			logger.Errorf("failed to close storage: %v", err)
 		}
 	}
 	err = listener.Close()
 	if err != nil {
-		logger.Printf("failed to close listener: %v", err)
+		logger.Warnf("failed to close listener: %v", err)
 	}
 	// Ensure that the listener goroutine has exited before returning
 	<-listenErrCh
@@ -44,7 +44,30 @@ func TestProgram_ServesClientRequests(t *testing.T) {
 
 	// Test store requests.
 	storetest.TestCmd(t, client)
+	storetest.TestCmdSync(t, client)
 	storetest.TestDir(t, client)
+	storetest.TestKV(t, client)
+
+	// Test the status request, which also covers the store requests above.
+	st, err := client.Status()
+	if err != nil {
+		t.Fatalf(".Status() -> error %v", err)
+	}
+	if st.Pid != wantPid || st.Version != api.Version || st.Clients != 1 ||
+		st.DBPath != "db" || st.DBSize == 0 || st.DBError != "" {
+		t.Errorf(".Status() -> %+v, want pid %v, version %v, 1 client, "+
+			"non-empty db and no db error", st, wantPid, api.Version)
+	}
+	if time.Since(st.StartTime) < 0 || time.Since(st.StartTime) > time.Hour {
+		t.Errorf(".Status().StartTime = %v, want a recent time", st.StartTime)
+	}
+	if c := st.Calls["AddCmd"]; c.Count == 0 || c.Total < c.Max || c.Max < c.Last {
+		t.Errorf(".Status().Calls[AddCmd] = %+v, want non-zero count, "+
+			"total >= max >= last", c)
+	}
+	if _, ok := st.Calls["Status"]; ok {
+		t.Errorf(".Status().Calls has Status, want only calls accessing the db")
+	}
 }
 
 func TestProgram_StillServesIfCannotOpenDB(t *testing.T) {
@@ -57,6 +80,10 @@ func TestProgram_StillServesIfCannotOpenDB(t *testing.T) {
 	if err == nil {
 		t.Errorf("got nil error, want non-nil")
 	}
+	st, err := client.Status()
+	if st.DBError == "" || err != nil {
+		t.Errorf(".Status() -> (%+v, %v), want non-empty DBError and nil error", st, err)
+	}
 }
 
 func TestProgram_QuitsOnSignalChannelWithNoClient(t *testing.T) {
@@ -1,8 +1,14 @@
 package daemon
 
 import (
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
+	"src.elv.sh/pkg/daemon/daemondefs"
 	"src.elv.sh/pkg/daemon/internal/api"
 	"src.elv.sh/pkg/store/storedefs"
 )
@@ -12,6 +18,41 @@ type service struct {
 	version int
 	store   storedefs.Store
 	err     error
+
+	dbPath    string
+	startTime time.Time
+	// The number of connected clients, updated by Serve and accessed
+	// atomically.
+	clients int32
+
+	callsMutex sync.Mutex
+	calls      map[string]daemondefs.CallStats
+
+	// Closed when a client requests the daemon to stop.
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newService(version int, st storedefs.Store, err error, dbPath string) *service {
+	return &service{version: version, store: st, err: err, dbPath: dbPath,
+		startTime: time.Now(), calls: make(map[string]daemondefs.CallStats),
+		stop: make(chan struct{})}
+}
+
+// Records the time spent serving an RPC call that started at start. Used with
+// defer.
+func (s *service) observe(name string, start time.Time) {
+	d := time.Since(start)
+	s.callsMutex.Lock()
+	defer s.callsMutex.Unlock()
+	st := s.calls[name]
+	st.Count++
+	st.Total += d
+	st.Last = d
+	if d > st.Max {
+		st.Max = d
+	}
+	s.calls[name] = st
 }
 
 // Implementations of RPC methods.
@@ -28,10 +69,60 @@ func (s *service) Pid(req *api.PidRequest, res *api.PidResponse) error {
 	return nil
 }
 
+// Status returns information about the daemon.
+func (s *service) Status(req *api.StatusRequest, res *api.StatusResponse) error {
+	st := daemondefs.Status{
+		Pid:       syscall.Getpid(),
+		Version:   s.version,
+		StartTime: s.startTime,
+		Clients:   int(atomic.LoadInt32(&s.clients)),
+		DBPath:    s.dbPath,
+		Calls:     make(map[string]daemondefs.CallStats),
+	}
+	if info, err := os.Stat(s.dbPath); err == nil {
+		st.DBSize = info.Size()
+	}
+	if s.err != nil {
+		st.DBError = s.err.Error()
+	}
+	s.callsMutex.Lock()
+	for name, stats := range s.calls {
+		st.Calls[name] = stats
+	}
+	s.callsMutex.Unlock()
+	res.Status = st
+	return nil
+}
+
+// Stop makes the daemon close all connections and exit.
+func (s *service) Stop(req *api.StopRequest, res *api.StopResponse) error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	return nil
+}
+
+// Compact compacts the database. Other calls accessing the database wait until
+// it finishes.
+func (s *service) Compact(req *api.CompactRequest, res *api.CompactResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	st, ok := s.store.(interface {
+		Compact() (before, after int64, err error)
+	})
+	if !ok {
+		return errors.New("store does not support compaction")
+	}
+	defer s.observe("Compact", time.Now())
+	var err error
+	res.Before, res.After, err = st.Compact()
+	return err
+}
+
 func (s *service) NextCmdSeq(req *api.NextCmdSeqRequest, res *api.NextCmdSeqResponse) error {
 	if s.err != nil {
 		return s.err
 	}
+	defer s.observe("NextCmdSeq", time.Now())
 	seq, err := s.store.NextCmdSeq()
 	res.Seq = seq
 	return err
@@ -41,6 +132,7 @@ func (s *service) AddCmd(req *api.AddCmdRequest, res *api.AddCmdResponse) error
 	if s.err != nil {
 		return s.err
 	}
+	defer s.observe("AddCmd", time.Now())
 	seq, err := s.store.AddCmd(req.Text)
 	res.Seq = seq
 	return err
@@ -50,6 +142,7 @@ func (s *service) DelCmd(req *api.DelCmdRequest, res *api.DelCmdResponse) error
 	if s.err != nil {
 		return s.err
 	}
+	defer s.observe("DelCmd", time.Now())
 	err := s.store.DelCmd(req.Seq)
 	return err
 }
@@ -58,6 +151,7 @@ func (s *service) Cmd(req *api.CmdRequest, res *api.CmdResponse) error {
 	if s.err != nil {
 		return s.err
 	}
+	defer s.observe("Cmd", time.Now())
 	text, err := s.store.Cmd(req.Seq)
 	res.Text = text
 	return err
@@ -67,6 +161,7 @@ func (s *service) CmdsWithSeq(req *api.CmdsWithSeqRequest, res *api.CmdsWithSeqR
 	if s.err != nil {
 		return s.err
 	}
+	defer s.observe("CmdsWithSeq", time.Now())
 	cmds, err := s.store.CmdsWithSeq(req.From, req.Upto)
 	res.Cmds = cmds
 	return err
@@ -76,6 +171,7 @@ func (s *service) NextCmd(req *api.NextCmdRequest, res *api.NextCmdResponse) err
 	if s.err != nil {
 		return s.err
 	}
+	defer s.observe("NextCmd", time.Now())
 	cmd, err := s.store.NextCmd(req.From, req.Prefix)
 	res.Seq, res.Text = cmd.Seq, cmd.Text
 	return err
@@ -85,15 +181,37 @@ func (s *service) PrevCmd(req *api.PrevCmdRequest, res *api.PrevCmdResponse) err
 	if s.err != nil {
 		return s.err
 	}
+	defer s.observe("PrevCmd", time.Now())
 	cmd, err := s.store.PrevCmd(req.Upto, req.Prefix)
 	res.Seq, res.Text = cmd.Seq, cmd.Text
 	return err
 }
 
+func (s *service) SyncCmds(req *api.SyncCmdsRequest, res *api.SyncCmdsResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	defer s.observe("SyncCmds", time.Now())
+	cmds, err := s.store.SyncCmds()
+	res.Cmds = cmds
+	return err
+}
+
+func (s *service) MergeCmds(req *api.MergeCmdsRequest, res *api.MergeCmdsResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	defer s.observe("MergeCmds", time.Now())
+	added, err := s.store.MergeCmds(req.Cmds)
+	res.Added = added
+	return err
+}
+
 func (s *service) AddDir(req *api.AddDirRequest, res *api.AddDirResponse) error {
 	if s.err != nil {
 		return s.err
 	}
+	defer s.observe("AddDir", time.Now())
 	return s.store.AddDir(req.Dir, req.IncFactor)
 }
 
@@ -101,6 +219,7 @@ func (s *service) DelDir(req *api.DelDirRequest, res *api.DelDirResponse) error
 	if s.err != nil {
 		return s.err
 	}
+	defer s.observe("DelDir", time.Now())
 	return s.store.DelDir(req.Dir)
 }
 
@@ -108,7 +227,44 @@ func (s *service) Dirs(req *api.DirsRequest, res *api.DirsResponse) error {
 	if s.err != nil {
 		return s.err
 	}
+	defer s.observe("Dirs", time.Now())
 	dirs, err := s.store.Dirs(req.Blacklist)
 	res.Dirs = dirs
 	return err
 }
+
+func (s *service) Value(req *api.ValueRequest, res *api.ValueResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	defer s.observe("Value", time.Now())
+	value, err := s.store.Value(req.NS, req.Key)
+	res.Value = value
+	return err
+}
+
+func (s *service) SetValue(req *api.SetValueRequest, res *api.SetValueResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	defer s.observe("SetValue", time.Now())
+	return s.store.SetValue(req.NS, req.Key, req.Data, req.Version)
+}
+
+func (s *service) DelValue(req *api.DelValueRequest, res *api.DelValueResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	defer s.observe("DelValue", time.Now())
+	return s.store.DelValue(req.NS, req.Key, req.Version)
+}
+
+func (s *service) ValueKeys(req *api.ValueKeysRequest, res *api.ValueKeysResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	defer s.observe("ValueKeys", time.Now())
+	keys, err := s.store.ValueKeys(req.NS)
+	res.Keys = keys
+	return err
+}
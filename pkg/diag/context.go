@@ -41,6 +41,12 @@ func (c *Context) ShowCompact(indent string) string {
 	return desc + c.culprit.Show(indent+descIndent)
 }
 
+// Position returns the name and the 1-based line and column numbers of the
+// start of the Context, like "a.elv:1:6".
+func (c *Context) Position() string {
+	return c.Name + ":" + c.culprit.describeStart()
+}
+
 // Information about the lines that contain the culprit.
 type culprit struct {
 	// The actual culprit text.
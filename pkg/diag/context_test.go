@@ -74,6 +74,13 @@ func TestContext(t *testing.T) {
 	}
 }
 
+func TestContext_Position(t *testing.T) {
+	c := contextInParen("[test]", "echo\necho (bad)")
+	if got := c.Position(); got != "[test]:2:6" {
+		t.Errorf("Position() -> %q, want %q", got, "[test]:2:6")
+	}
+}
+
 // Returns a Context with the given name and source, and a range for the part
 // between ( and ).
 func contextInParen(name, src string) *Context {
@@ -115,6 +115,9 @@ func bindingTip(text string, fnNames ...string) bindingTipEntry {
 	return bindingTipEntry{text, fnNames}
 }
 
+// Used for the keys in the text returned by bindingTips.
+var stylingForKey = ui.DefineRole("key", ui.Inverse)
+
 // Given a binding map and a list of function groups, returns a text describing
 // the keys that are bound to any function in each group.
 //
@@ -136,7 +139,7 @@ func bindingTips(ns *eval.Ns, binding string, entries ...bindingTipEntry) ui.Tex
 			t = ui.Concat(t, ui.T(" "))
 		}
 		for _, k := range keys {
-			t = ui.Concat(t, ui.T(k.String(), ui.Inverse), ui.T(" "))
+			t = ui.Concat(t, ui.T(k.String(), stylingForKey), ui.T(" "))
 		}
 		t = ui.Concat(t, ui.T(entry.text))
 	}
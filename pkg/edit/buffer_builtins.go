@@ -60,6 +60,16 @@ var bufferBuiltinsData = map[string]func(*tk.CodeBuffer){
 	"transpose-word":       makeTransform(transposeWord),
 	"transpose-small-word": makeTransform(transposeSmallWord),
 	"transpose-alnum-word": makeTransform(transposeAlnumWord),
+
+	"upcase-word":           makeTransform(makeCaseChange(categorizeWord, strings.ToUpper)),
+	"downcase-word":         makeTransform(makeCaseChange(categorizeWord, strings.ToLower)),
+	"capitalize-word":       makeTransform(makeCaseChange(categorizeWord, capitalize)),
+	"upcase-small-word":     makeTransform(makeCaseChange(tk.CategorizeSmallWord, strings.ToUpper)),
+	"downcase-small-word":   makeTransform(makeCaseChange(tk.CategorizeSmallWord, strings.ToLower)),
+	"capitalize-small-word": makeTransform(makeCaseChange(tk.CategorizeSmallWord, capitalize)),
+	"upcase-alnum-word":     makeTransform(makeCaseChange(categorizeAlnum, strings.ToUpper)),
+	"downcase-alnum-word":   makeTransform(makeCaseChange(categorizeAlnum, strings.ToLower)),
+	"capitalize-alnum-word": makeTransform(makeCaseChange(categorizeAlnum, capitalize)),
 }
 
 // A pure function that takes the current buffer and dot, and returns a new
@@ -100,14 +110,23 @@ func makeTransform(t pureTransformer) func(*tk.CodeBuffer) {
 
 // Implementation of pure movers.
 
+// Moving left and right is done by grapheme cluster, so that the dot never
+// lands in the middle of, for instance, an emoji ZWJ sequence.
+
 func moveDotLeft(buffer string, dot int) int {
-	_, w := utf8.DecodeLastRuneInString(buffer[:dot])
-	return dot - w
+	// Grapheme clusters never span multiple lines, so it suffices to scan from
+	// the start of the line.
+	sol := strutil.FindLastSOL(buffer[:dot])
+	if sol == dot {
+		// At the start of a line; move over the newline, if any.
+		_, w := utf8.DecodeLastRuneInString(buffer[:dot])
+		return dot - w
+	}
+	return dot - wcwidth.LastClusterLen(buffer[sol:dot])
 }
 
 func moveDotRight(buffer string, dot int) int {
-	_, w := utf8.DecodeRuneInString(buffer[dot:])
-	return dot + w
+	return dot + wcwidth.ClusterLen(buffer[dot:])
 }
 
 func moveDotSOL(buffer string, dot int) int {
@@ -366,6 +385,29 @@ func transposeGeneralWord(categorize categorizer, buffer string, dot int) (strin
 	return buffer[:leftStart] + buffer[rightStart:rightEnd] + buffer[leftEnd:rightStart] + buffer[leftStart:leftEnd] + buffer[rightEnd:], rightEnd
 }
 
+// Returns a transformer that changes the case of the text from the dot to the
+// end of the word the dot is in, or the next word if the dot is not in a word,
+// using the word flavor described by the categorizer. The dot is moved to the
+// end of the word, so that repeating the transformer changes the following
+// words.
+func makeCaseChange(categorize categorizer, change func(string) string) pureTransformer {
+	return func(buffer string, dot int) (string, int) {
+		start := skipWsRight(categorize, buffer, dot)
+		end := skipSameCatRight(categorize, buffer, start)
+		before := buffer[:start] + change(buffer[start:end])
+		return before + buffer[end:], len(before)
+	}
+}
+
+// Converts the first rune of s to title case and the rest to lower case.
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToTitle(r)) + strings.ToLower(s[size:])
+}
+
 // Skips all runes to the left of the dot that belongs to the same category.
 func skipSameCatLeft(categorize categorizer, buffer string, pos int) int {
 	if pos == 0 {
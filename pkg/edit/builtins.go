@@ -21,6 +21,7 @@ func closeMode(app cli.App) {
 
 func endOfHistory(app cli.App) {
 	app.Notify(ui.T("End of history"))
+	app.Bell()
 }
 
 type redrawOpts struct{ Full bool }
@@ -35,14 +36,27 @@ func redraw(app cli.App, opts redrawOpts) {
 	}
 }
 
-func clear(app cli.App, tty cli.TTY) {
+func scrollCode(app cli.App, delta int) {
+	codeArea, ok := focusedCodeArea(app)
+	if !ok {
+		return
+	}
+	codeArea.ScrollBy(delta)
+}
+
+func clear(app cli.App, tty cli.TTY, scrollback bool) {
 	tty.HideCursor()
-	tty.ClearScreen()
+	if scrollback {
+		tty.ClearScrollback()
+	} else {
+		tty.ClearScreen()
+	}
 	app.RedrawFull()
 	tty.ShowCursor()
 }
 
-func insertRaw(app cli.App, tty cli.TTY) {
+func insertRaw(ed *Editor, tty cli.TTY) {
+	app := ed.app
 	codeArea, ok := focusedCodeArea(app)
 	if !ok {
 		return
@@ -63,7 +77,7 @@ func insertRaw(app cli.App, tty cli.TTY) {
 		}),
 		Name: " RAW ",
 	})
-	app.PushAddon(w)
+	ed.pushMode("raw", w)
 }
 
 var errMustBeKeyOrString = errors.New("must be key or string")
@@ -104,7 +118,7 @@ func smartEnter(ed *Editor) {
 	codeArea.MutateState(func(s *tk.CodeAreaState) {
 		buf := &s.Buffer
 		if !isSyntaxComplete(buf.Content) {
-			buf.InsertAtDot("\n")
+			buf.InsertAtDot("\n" + autoIndent(buf.Content, buf.Dot))
 			insertedNewline = true
 		}
 	})
@@ -141,25 +155,29 @@ func wordify(fm *eval.Frame, code string) error {
 	return nil
 }
 
-func initTTYBuiltins(app cli.App, tty cli.TTY, nb eval.NsBuilder) {
+func initTTYBuiltins(ed *Editor, tty cli.TTY, nb eval.NsBuilder) {
+	app := ed.app
 	nb.AddGoFns(map[string]any{
-		"insert-raw": func() { insertRaw(app, tty) },
-		"clear":      func() { clear(app, tty) },
+		"insert-raw":       func() { insertRaw(ed, tty) },
+		"clear":            func() { clear(app, tty, false) },
+		"clear-scrollback": func() { clear(app, tty, true) },
 	})
 }
 
 func initMiscBuiltins(ed *Editor, nb eval.NsBuilder) {
 	nb.AddGoFns(map[string]any{
-		"binding-table":  makeBindingMap,
-		"close-mode":     func() { closeMode(ed.app) },
-		"end-of-history": func() { endOfHistory(ed.app) },
-		"key":            toKey,
-		"notify":         func(x any) error { return notify(ed.app, x) },
-		"redraw":         func(opts redrawOpts) { redraw(ed.app, opts) },
-		"return-line":    ed.app.CommitCode,
-		"return-eof":     ed.app.CommitEOF,
-		"smart-enter":    func() { smartEnter(ed) },
-		"wordify":        wordify,
+		"binding-table":    makeBindingMap,
+		"close-mode":       func() { closeMode(ed.app) },
+		"end-of-history":   func() { endOfHistory(ed.app) },
+		"key":              toKey,
+		"notify":           func(x any) error { return notify(ed.app, x) },
+		"redraw":           func(opts redrawOpts) { redraw(ed.app, opts) },
+		"return-line":      ed.app.CommitCode,
+		"return-eof":       ed.app.CommitEOF,
+		"scroll-code-up":   func() { scrollCode(ed.app, -1) },
+		"scroll-code-down": func() { scrollCode(ed.app, 1) },
+		"smart-enter":      func() { smartEnter(ed) },
+		"wordify":          wordify,
 	})
 }
 
@@ -98,6 +98,29 @@ func TestRedraw(t *testing.T) {
 		"   vvvv", term.DotHere)
 }
 
+func TestScrollCode(t *testing.T) {
+	f := setup(t, rc(`set edit:max-code-height = 2`))
+
+	evals(f.Evaler,
+		`set edit:current-command = "echo\necho\necho"`,
+		`edit:scroll-code-up`,
+		`edit:redraw`)
+	// The dot is below the visible part of the code.
+	f.TestTTY(t,
+		"~> echo                                           \n", Styles,
+		"   vvvv                                          X",
+		term.DotHere, "   echo                                          │", Styles,
+		"   vvvv                                          -")
+
+	evals(f.Evaler, `edit:scroll-code-down`, `edit:redraw`)
+	f.TestTTY(t,
+		"   echo                                          │\n", Styles,
+		"   vvvv                                          -",
+		"   echo", Styles,
+		"   vvvv", term.DotHere, "                                           ", Styles,
+		"                                          X")
+}
+
 func TestClear(t *testing.T) {
 	f := setup(t)
 
@@ -108,6 +131,21 @@ func TestClear(t *testing.T) {
 	if cleared := f.TTYCtrl.ScreenCleared(); cleared != 1 {
 		t.Errorf("screen cleared %v times, want 1", cleared)
 	}
+	if cleared := f.TTYCtrl.ScrollbackCleared(); cleared != 0 {
+		t.Errorf("scrollback cleared %v times, want 0", cleared)
+	}
+}
+
+func TestClearScrollback(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler, `set edit:current-command = echo`, `edit:clear-scrollback`)
+	f.TestTTY(t,
+		"~> echo", Styles,
+		"   vvvv", term.DotHere)
+	if cleared := f.TTYCtrl.ScrollbackCleared(); cleared != 1 {
+		t.Errorf("scrollback cleared %v times, want 1", cleared)
+	}
 }
 
 func TestNotify(t *testing.T) {
@@ -157,7 +195,7 @@ func TestSmartEnter_InsertsNewlineWhenIncomplete(t *testing.T) {
 
 	f.SetCodeBuffer(tk.CodeBuffer{Content: "put [", Dot: 5})
 	evals(f.Evaler, `edit:smart-enter`)
-	wantBuf := tk.CodeBuffer{Content: "put [\n", Dot: 6}
+	wantBuf := tk.CodeBuffer{Content: "put [\n  ", Dot: 8}
 	if buf := codeArea(f.Editor.app).CopyState().Buffer; buf != wantBuf {
 		t.Errorf("got code buffer %v, want %v", buf, wantBuf)
 	}
@@ -174,6 +212,49 @@ func TestSmartEnter_AcceptsCodeWhenWholeBufferIsComplete(t *testing.T) {
 	}
 }
 
+func TestSmartEnter_IndentsByDepth(t *testing.T) {
+	f := setup(t)
+
+	f.SetCodeBuffer(tk.CodeBuffer{Content: "if $true {\n  put [", Dot: 18})
+	evals(f.Evaler, `edit:smart-enter`)
+	testCodeBuffer(t, f.Editor,
+		tk.CodeBuffer{Content: "if $true {\n  put [\n    ", Dot: 23})
+}
+
+func TestSmartEnter_NoIndentationInString(t *testing.T) {
+	f := setup(t)
+
+	f.SetCodeBuffer(tk.CodeBuffer{Content: "  put 'foo", Dot: 10})
+	evals(f.Evaler, `edit:smart-enter`)
+	testCodeBuffer(t, f.Editor, tk.CodeBuffer{Content: "  put 'foo\n", Dot: 11})
+}
+
+func TestClosingBracket_Reindents(t *testing.T) {
+	f := setup(t)
+
+	f.SetCodeBuffer(tk.CodeBuffer{Content: "if $true {\n  put [\n    ", Dot: 23})
+	f.TTYCtrl.Inject(term.K(']'), term.K('\n'), term.K('}'))
+	f.TestTTY(t,
+		"~> if $true {\n", Styles,
+		"   vv ----- b\n",
+		"     put [\n", Styles,
+		"     vvv b\n",
+		"     ]\n", Styles,
+		"     b\n",
+		"   }", Styles,
+		"   b", term.DotHere)
+}
+
+func TestCtrlEnter_AcceptsIncompleteCode(t *testing.T) {
+	f := setup(t)
+
+	f.SetCodeBuffer(tk.CodeBuffer{Content: "put [", Dot: 5})
+	f.TTYCtrl.Inject(term.K(ui.Enter, ui.Ctrl))
+	if code, _ := f.Wait(); code != "put [" {
+		t.Errorf("got return code %q, want %q", code, "put [")
+	}
+}
+
 // TODO: Test that smart-enter applies autofix.
 
 func TestWordify(t *testing.T) {
@@ -287,6 +368,66 @@ var bufferBuiltinsTests = []struct {
 		tk.CodeBuffer{Content: "cd ~/downloads;", Dot: 4},
 		tk.CodeBuffer{Content: "downloads ~/cd;", Dot: 14},
 	},
+	{
+		"upcase-word with dot in the middle of a word",
+		tk.CodeBuffer{Content: "echo foo-bar baz", Dot: 6},
+		tk.CodeBuffer{Content: "echo fOO-BAR baz", Dot: 12},
+	},
+	{
+		"upcase-word with dot before a word",
+		tk.CodeBuffer{Content: "echo  foo", Dot: 4},
+		tk.CodeBuffer{Content: "echo  FOO", Dot: 9},
+	},
+	{
+		"upcase-word with no word after dot",
+		tk.CodeBuffer{Content: "echo  ", Dot: 5},
+		tk.CodeBuffer{Content: "echo  ", Dot: 6},
+	},
+	{
+		"downcase-word",
+		tk.CodeBuffer{Content: "ECHO FOO", Dot: 4},
+		tk.CodeBuffer{Content: "ECHO foo", Dot: 8},
+	},
+	{
+		"capitalize-word",
+		tk.CodeBuffer{Content: "echo fOO-BAR", Dot: 4},
+		tk.CodeBuffer{Content: "echo Foo-bar", Dot: 12},
+	},
+	{
+		"capitalize-word with non-ASCII runes",
+		tk.CodeBuffer{Content: "ǆemal ÉCOLE", Dot: 0},
+		tk.CodeBuffer{Content: "ǅemal ÉCOLE", Dot: 6},
+	},
+	{
+		"upcase-small-word",
+		tk.CodeBuffer{Content: "echo foo-bar", Dot: 4},
+		tk.CodeBuffer{Content: "echo FOO-bar", Dot: 8},
+	},
+	{
+		"downcase-small-word",
+		tk.CodeBuffer{Content: "ECHO FOO-BAR", Dot: 8},
+		tk.CodeBuffer{Content: "ECHO FOO-BAR", Dot: 9},
+	},
+	{
+		"capitalize-small-word",
+		tk.CodeBuffer{Content: "echo foo-bar", Dot: 9},
+		tk.CodeBuffer{Content: "echo foo-Bar", Dot: 12},
+	},
+	{
+		"upcase-alnum-word",
+		tk.CodeBuffer{Content: "cd ~/downloads;", Dot: 2},
+		tk.CodeBuffer{Content: "cd ~/DOWNLOADS;", Dot: 14},
+	},
+	{
+		"downcase-alnum-word",
+		tk.CodeBuffer{Content: "CD ~/DOWNLOADS;", Dot: 0},
+		tk.CodeBuffer{Content: "cd ~/DOWNLOADS;", Dot: 2},
+	},
+	{
+		"capitalize-alnum-word",
+		tk.CodeBuffer{Content: "cd ~/downloads;", Dot: 3},
+		tk.CodeBuffer{Content: "cd ~/Downloads;", Dot: 14},
+	},
 }
 
 func TestBufferBuiltins(t *testing.T) {
@@ -340,6 +481,10 @@ func TestMoveDotLeftRight(t *testing.T) {
 		Args("精灵", 0).Rets(0),
 		Args("精灵", 3).Rets(0),
 		Args("精灵", 6).Rets(3),
+		Args("a\nb", 2).Rets(1),
+		// Grapheme clusters are moved over as a whole.
+		Args("e\u0301x", 3).Rets(0),
+		Args("x\U0001F1EB\U0001F1F7", 9).Rets(1),
 	})
 	tt.Test(t, tt.Fn("moveDotRight", moveDotRight), tt.Table{
 		Args("foo", 0).Rets(1),
@@ -347,6 +492,9 @@ func TestMoveDotLeftRight(t *testing.T) {
 		Args("精灵", 0).Rets(3),
 		Args("精灵", 3).Rets(6),
 		Args("精灵", 6).Rets(6),
+		Args("a\nb", 1).Rets(2),
+		Args("e\u0301x", 0).Rets(3),
+		Args("\U0001F1EB\U0001F1F7x", 0).Rets(8),
 	})
 }
 
@@ -3,13 +3,14 @@ package edit
 // Implementation of the editor "command" mode.
 
 import (
+	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/modes"
 	"src.elv.sh/pkg/eval"
 )
 
-func initCommandAPI(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
+func initCommandAPI(ed *Editor, ev *eval.Evaler, tty cli.TTY, nb eval.NsBuilder) {
 	bindingVar := newBindingVar(emptyBindingsMap)
-	bindings := newMapBindings(ed, ev, bindingVar)
+	bindings := viBindings{ed, tty, newMapBindings(ed, ev, bindingVar)}
 	nb.AddNs("command",
 		eval.BuildNsNamed("edit:command").
 			AddVar("binding", bindingVar).
@@ -19,7 +20,26 @@ func initCommandAPI(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 						Bindings: bindings,
 						Name:     " COMMAND ",
 					})
-					ed.app.PushAddon(w)
+					ed.vi.reset()
+					ed.pushMode("command", commandMode{w, ed})
 				},
 			}))
 }
+
+// The command mode, whose modeline is hidden while the visual mode is started
+// from it, so that only one of them is shown.
+type commandMode struct {
+	modes.Stub
+	ed *Editor
+}
+
+func (w commandMode) MaxHeight(width, height int) int {
+	if w.ed.activeMode() == "visual" {
+		return 0
+	}
+	return w.Stub.MaxHeight(width, height)
+}
+
+// Focus returns false, like the stub mode does, to keep the focus on the code
+// area.
+func (w commandMode) Focus() bool { return false }
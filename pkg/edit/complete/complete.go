@@ -4,6 +4,8 @@ package complete
 import (
 	"errors"
 	"sort"
+	"strings"
+	"sync"
 
 	"src.elv.sh/pkg/cli/modes"
 	"src.elv.sh/pkg/diag"
@@ -24,6 +26,9 @@ type Config struct {
 	// Used to generate candidates for a command argument. Defaults to
 	// GenerateFileNames.
 	ArgGenerator ArgGenerator
+	// Like ArgGenerator, but generates candidates in batches. If non-nil, it
+	// is used instead of ArgGenerator.
+	ArgStreamer ArgStreamer
 }
 
 // Filterer is the type of functions that filter raw candidates.
@@ -34,11 +39,23 @@ type Filterer func(ctxName, seed string, rawItems []RawItem) []RawItem
 // argument to complete, and returns raw candidates or an error.
 type ArgGenerator func(args []string) ([]RawItem, error)
 
+// ArgStreamer is like ArgGenerator, but calls emit with each batch of raw
+// candidates as they are generated. It should stop generating candidates and
+// return nil as soon as emit returns false.
+type ArgStreamer func(args []string, emit func([]RawItem) bool) error
+
 // Result keeps the result of the completion algorithm.
 type Result struct {
 	Name    string
 	Replace diag.Ranging
 	Items   []modes.CompletionItem
+	// Only set by CompleteStream when not all the candidates have been
+	// generated yet. Each time a new batch is generated, the items generated
+	// so far are sent to Updates, which is closed when generation finishes.
+	Updates <-chan []modes.CompletionItem
+	// Stops generating candidates. It can be called multiple times, and must be
+	// called if the caller stops receiving from Updates before it is closed.
+	Cancel func()
 }
 
 // RawItem represents completion items before the quoting pass.
@@ -57,12 +74,30 @@ type CodeBuffer struct {
 // Complete runs the code completion algorithm in the given context, and returns
 // the completion type, items and any error encountered.
 func Complete(code CodeBuffer, ev *eval.Evaler, cfg Config) (*Result, error) {
+	result, err := CompleteStream(code, ev, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if result.Updates != nil {
+		for items := range result.Updates {
+			result.Items = items
+		}
+		result.Updates, result.Cancel = nil, nil
+	}
+	return result, nil
+}
+
+// CompleteStream is like Complete, but returns as soon as the first batch of
+// candidates has been generated. This matters for generators that generate
+// candidates incrementally, like the one for file names, which reads
+// directories in batches.
+//
+// The items in the result are always sorted and deduplicated. If generation
+// is not finished yet, the Updates and Cancel fields of the result are set.
+func CompleteStream(code CodeBuffer, ev *eval.Evaler, cfg Config) (*Result, error) {
 	if cfg.Filterer == nil {
 		cfg.Filterer = FilterPrefix
 	}
-	if cfg.ArgGenerator == nil {
-		cfg.ArgGenerator = GenerateFileNames
-	}
 
 	// Ignore the error; the function always returns a valid *ChunkNode.
 	tree, _ := parse.Parse(parse.Source{Name: "[interactive]", Code: code.Content}, parse.Config{})
@@ -72,30 +107,143 @@ func Complete(code CodeBuffer, ev *eval.Evaler, cfg Config) (*Result, error) {
 		return nil, errNoCompletion
 	}
 	for _, completer := range completers {
-		ctx, rawItems, err := completer(path, ev, cfg)
+		ctx, gen, err := completer(path, ev, cfg)
 		if err == errNoCompletion {
 			continue
 		}
-		rawItems = cfg.Filterer(ctx.name, ctx.seed, rawItems)
-		sort.Slice(rawItems, func(i, j int) bool {
-			return rawItems[i].String() < rawItems[j].String()
-		})
-		items := make([]modes.CompletionItem, len(rawItems))
-		for i, rawCand := range rawItems {
-			items[i] = rawCand.Cook(ctx.quote)
+		run := startGenerator(gen)
+		batch, more := <-run.batches
+		if !more && <-run.err == errNoCompletion {
+			continue
 		}
-		items = dedup(items)
-		return &Result{Name: ctx.name, Items: items, Replace: ctx.interval}, nil
+		acc := &accumulator{ctx: ctx, filterer: cfg.Filterer}
+		acc.add(batch)
+		result := &Result{Name: ctx.name, Items: acc.items, Replace: ctx.interval}
+		if more {
+			updates := make(chan []modes.CompletionItem)
+			go func() {
+				defer close(updates)
+				for batch := range run.batches {
+					acc.add(batch)
+					select {
+					case updates <- acc.items:
+					case <-run.stop:
+						return
+					}
+				}
+			}()
+			result.Updates, result.Cancel = updates, run.cancel
+		}
+		return result, nil
 	}
 	return nil, errNoCompletion
 }
 
-func dedup(items []modes.CompletionItem) []modes.CompletionItem {
-	var result []modes.CompletionItem
-	for i, item := range items {
-		if i == 0 || item.ToInsert != items[i-1].ToInsert {
-			result = append(result, item)
+// A generator running in its own goroutine.
+type runningGenerator struct {
+	// Batches generated; closed when the generator returns.
+	batches chan []RawItem
+	// Receives the error returned by the generator.
+	err chan error
+	// Closed when the generator should stop.
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func startGenerator(gen generator) *runningGenerator {
+	run := &runningGenerator{
+		batches: make(chan []RawItem), err: make(chan error, 1),
+		stop: make(chan struct{})}
+	go func() {
+		run.err <- gen(func(batch []RawItem) bool {
+			select {
+			case run.batches <- batch:
+				return true
+			case <-run.stop:
+				return false
+			}
+		})
+		close(run.batches)
+	}()
+	return run
+}
+
+func (run *runningGenerator) cancel() {
+	run.stopOnce.Do(func() { close(run.stop) })
+}
+
+// Accumulates batches of raw items, keeping the cooked items sorted and
+// deduplicated. The items slice is never modified in place, so it can be
+// passed to other goroutines.
+type accumulator struct {
+	ctx      *context
+	filterer Filterer
+	// Sort keys of the items, which are the strings of the raw items.
+	keys  []string
+	items []modes.CompletionItem
+}
+
+func (acc *accumulator) add(batch []RawItem) {
+	batch = filterFoldingCase(acc.filterer, acc.ctx.name, acc.ctx.seed, batch)
+	if len(batch) == 0 {
+		return
+	}
+	sort.Slice(batch, func(i, j int) bool {
+		return batch[i].String() < batch[j].String()
+	})
+	n := len(acc.items) + len(batch)
+	keys := make([]string, 0, n)
+	items := make([]modes.CompletionItem, 0, n)
+	appendItem := func(key string, item modes.CompletionItem) {
+		if len(items) > 0 && items[len(items)-1].ToInsert == item.ToInsert {
+			return
+		}
+		keys = append(keys, key)
+		items = append(items, item)
+	}
+	i := 0
+	for _, rawItem := range batch {
+		key := rawItem.String()
+		for i < len(acc.items) && acc.keys[i] <= key {
+			appendItem(acc.keys[i], acc.items[i])
+			i++
+		}
+		appendItem(key, rawItem.Cook(acc.ctx.quote))
+	}
+	for ; i < len(acc.items); i++ {
+		appendItem(acc.keys[i], acc.items[i])
+	}
+	acc.keys, acc.items = keys, items
+}
+
+// Filters raw items with the filterer. Items that are matched
+// case-insensitively are filtered separately, with their strings and the seed
+// converted to lower case.
+func filterFoldingCase(f Filterer, ctxName, seed string, items []RawItem) []RawItem {
+	var folded, others []RawItem
+	for _, item := range items {
+		if c, ok := item.(ComplexItem); ok && c.FoldCase {
+			folded = append(folded, lowerCaseItem{c})
+		} else {
+			others = append(others, item)
+		}
+	}
+	if len(folded) == 0 {
+		return f(ctxName, seed, items)
+	}
+	var filtered []RawItem
+	if len(others) > 0 {
+		filtered = f(ctxName, seed, others)
+	}
+	for _, item := range f(ctxName, strings.ToLower(seed), folded) {
+		if item, ok := item.(lowerCaseItem); ok {
+			filtered = append(filtered, item.ComplexItem)
 		}
 	}
-	return result
+	return filtered
 }
+
+// Wraps a ComplexItem to be matched in lower case.
+type lowerCaseItem struct{ ComplexItem }
+
+func (l lowerCaseItem) String() string { return strings.ToLower(l.Stem) }
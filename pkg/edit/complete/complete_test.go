@@ -3,18 +3,22 @@ package complete
 // Mocked builtin commands
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
 	"runtime"
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"src.elv.sh/pkg/cli/lscolors"
 	"src.elv.sh/pkg/cli/modes"
 	"src.elv.sh/pkg/diag"
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/fsutil"
 	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/testutil"
 	"src.elv.sh/pkg/tt"
@@ -425,6 +429,241 @@ func TestComplete(t *testing.T) {
 	}
 }
 
+func TestCompleteStream(t *testing.T) {
+	lscolors.SetTestLsColors(t)
+	testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{"d": "", "c": "", "b": "", "a": "", "ba": ""})
+	testutil.Set(t, &fileNameBatchSize, 2)
+	ev := eval.NewEvaler()
+
+	result, err := CompleteStream(cb("ls "), ev, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Updates == nil {
+		t.Fatalf("got no updates, want updates")
+	}
+	if len(result.Items) != 2 {
+		t.Errorf("got %d items in the first batch, want 2", len(result.Items))
+	}
+	var items []modes.CompletionItem
+	for items = range result.Updates {
+	}
+	wantItems := []modes.CompletionItem{
+		fci("a", " "), fci("b", " "), fci("ba", " "), fci("c", " "), fci("d", " ")}
+	if !reflect.DeepEqual(items, wantItems) {
+		t.Errorf("got items %v, want %v", items, wantItems)
+	}
+
+	// Candidates are filtered and deduplicated across batches.
+	dupCfg := Config{
+		ArgStreamer: func(args []string, emit func([]RawItem) bool) error {
+			emit([]RawItem{PlainItem("b"), PlainItem("x")})
+			emit([]RawItem{PlainItem("b"), PlainItem("a")})
+			return nil
+		},
+	}
+	result, _ = Complete(cb("ls b"), ev, dupCfg)
+	wantResult := &Result{Name: "argument", Replace: r(3, 4), Items: []modes.CompletionItem{ci("b")}}
+	if !reflect.DeepEqual(result, wantResult) {
+		t.Errorf("got %v, want %v", result, wantResult)
+	}
+}
+
+func TestComplete_CaseInsensitiveFileNames(t *testing.T) {
+	lscolors.SetTestLsColors(t)
+	dir := testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{"README.md": "", "d": testutil.Dir{"Main.go": ""}})
+	t.Cleanup(func() { fsutil.SetCaseInsensitivePaths(nil) })
+	ev := eval.NewEvaler()
+
+	fsutil.SetCaseInsensitivePaths(map[string]bool{dir: true})
+	// The inserted text has the case of the file.
+	result, _ := Complete(cb("ls rea"), ev, Config{})
+	if want := []modes.CompletionItem{fci("README.md", " ")}; !reflect.DeepEqual(result.Items, want) {
+		t.Errorf("got items %v, want %v", result.Items, want)
+	}
+	result, _ = Complete(cb("ls d/MA"), ev, Config{})
+	if want := []modes.CompletionItem{fci("d/Main.go", " ")}; !reflect.DeepEqual(result.Items, want) {
+		t.Errorf("got items %v, want %v", result.Items, want)
+	}
+
+	fsutil.SetCaseInsensitivePaths(map[string]bool{dir: false})
+	result, _ = Complete(cb("ls rea"), ev, Config{})
+	if len(result.Items) != 0 {
+		t.Errorf("got items %v, want none", result.Items)
+	}
+}
+
+func TestCompleteStream_Cancel(t *testing.T) {
+	stopped := make(chan struct{})
+	cfg := Config{
+		ArgStreamer: func(args []string, emit func([]RawItem) bool) error {
+			defer close(stopped)
+			for emit([]RawItem{PlainItem("a")}) {
+			}
+			return nil
+		},
+	}
+	result, err := CompleteStream(cb("ls "), eval.NewEvaler(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-result.Updates
+	result.Cancel()
+	result.Cancel()
+	select {
+	case <-stopped:
+	case <-time.After(testutil.Scaled(time.Second)):
+		t.Errorf("generator not stopped after Cancel")
+	}
+}
+
+var gnuManPage = `LS(1)                     User Commands                    LS(1)
+
+NAME
+       ls - list directory contents
+
+DESCRIPTION
+       List information about the FILEs (the current directory by
+       default).
+
+       Mandatory arguments to long options are mandatory for short
+       options too.
+
+       -a, --all
+              do not ignore entries starting with .
+
+       --color[=WHEN]
+              color the output WHEN; more info below
+
+       -w, --width=COLS
+              set output width to COLS.  0 means no limit
+
+       -1     list one file per line
+              --like this continuation line
+
+       Exit status:
+              0      if OK,
+`
+
+var bsdManPage = "NAME\n     ls \u2013 list directory contents\n\n" +
+	"OPTIONS\n" +
+	"     -\b-l\bl      List files in the long format, as described in the\n" +
+	"             FILES section below.\n" +
+	"     -\x1b[1mR\x1b[0m      Recursively list subdirectories encountered.\n" +
+	"GLOBAL OPTIONS\n" +
+	"     -h, --help\n" +
+	"\n" +
+	"             Show help.\n"
+
+func TestGenerateManOptions(t *testing.T) {
+	calls := 0
+	testutil.Set(t, &manPage, func(cmd string) (string, error) {
+		calls++
+		switch cmd {
+		case "gnu-ls":
+			return gnuManPage, nil
+		case "bsd-ls":
+			return bsdManPage, nil
+		default:
+			return "", fmt.Errorf("no manual entry for %s", cmd)
+		}
+	})
+	item := func(flag, desc string) RawItem {
+		return ComplexItem{Stem: flag, Display: ui.T(flag + " (" + desc + ")")}
+	}
+
+	tt.Test(t, tt.Fn("GenerateManOptions", GenerateManOptions), tt.Table{
+		Args([]string{"gnu-ls", "-"}).Rets([]RawItem{
+			item("-a", "do not ignore entries starting with ."),
+			item("--all", "do not ignore entries starting with ."),
+			item("--color", "color the output WHEN; more info below"),
+			item("-w", "set output width to COLS. 0 means no limit"),
+			item("--width", "set output width to COLS. 0 means no limit"),
+			item("-1", "list one file per line"),
+		}, nil),
+		// The command name is the base name of the command.
+		Args([]string{"/usr/bin/bsd-ls", "-"}).Rets([]RawItem{
+			item("-l", "List files in the long format, as described in the"),
+			item("-R", "Recursively list subdirectories encountered."),
+			item("-h", "Show help."),
+			item("--help", "Show help."),
+		}, nil),
+		// No candidates for arguments not starting with "-".
+		Args([]string{"gnu-ls", "a"}).Rets([]RawItem(nil), nil),
+		// No candidates when man fails.
+		Args([]string{"no-man", "-"}).Rets([]RawItem(nil), nil),
+	})
+
+	// Man pages are only read once.
+	GenerateManOptions([]string{"gnu-ls", "--"})
+	GenerateManOptions([]string{"no-man", "--"})
+	if calls != 3 {
+		t.Errorf("man page read %d times, want 3", calls)
+	}
+}
+
+func TestGenerateRemoteFileNames(t *testing.T) {
+	testutil.Set(t, &listRemoteDir, func(host, dir string) ([]string, error) {
+		switch host + ":" + dir {
+		case "host:", "me@host:":
+			return []string{"a", "d/", ".x"}, nil
+		case "host:d/":
+			return []string{"b"}, nil
+		case "host:/etc/":
+			return []string{"passwd"}, nil
+		default:
+			return nil, errors.New("no such directory")
+		}
+	})
+	item := func(stem, suffix string) RawItem {
+		return ComplexItem{Stem: stem, CodeSuffix: suffix, Display: ui.T(stem)}
+	}
+
+	tt.Test(t, tt.Fn("GenerateRemoteFileNames", GenerateRemoteFileNames), tt.Table{
+		Args([]string{"scp", "host:"}).Rets([]RawItem{
+			item("host:a", " "), item("host:d/", "")}, nil),
+		Args([]string{"scp", "me@host:"}).Rets([]RawItem{
+			item("me@host:a", " "), item("me@host:d/", "")}, nil),
+		// Dot files are only generated when the prefix starts with a dot.
+		Args([]string{"scp", "host:."}).Rets([]RawItem{item("host:.x", " ")}, nil),
+		Args([]string{"scp", "host:d/"}).Rets([]RawItem{item("host:d/b", " ")}, nil),
+		Args([]string{"scp", "host:/etc/p"}).Rets([]RawItem{
+			item("host:/etc/passwd", " ")}, nil),
+		// Paths starting with ~/ are listed relative to the home directory.
+		Args([]string{"scp", "host:~/d/"}).Rets([]RawItem{
+			item("host:~/d/b", " ")}, nil),
+		Args([]string{"scp", "host:nonexistent/"}).Rets([]RawItem(nil),
+			errors.New("cannot list directory host:nonexistent/: no such directory")),
+		// No candidates for local paths and options.
+		Args([]string{"scp", "a"}).Rets([]RawItem(nil), nil),
+		Args([]string{"scp", "./a:b"}).Rets([]RawItem(nil), nil),
+		Args([]string{"scp", "-o:"}).Rets([]RawItem(nil), nil),
+		// Nor for rsync daemon paths.
+		Args([]string{"rsync", "host::module"}).Rets([]RawItem(nil), nil),
+	})
+}
+
+func TestSSHArgs(t *testing.T) {
+	args := sshArgs("host", "it's")
+	if got, want := args[len(args)-1], `ls -1Ap -- 'it'\''s'`; got != want {
+		t.Errorf("got remote command %q, want %q", got, want)
+	}
+	if got := args[len(args)-2]; got != "host" {
+		t.Errorf("got host %q, want %q", got, "host")
+	}
+}
+
+func TestConfiguresControl(t *testing.T) {
+	tt.Test(t, tt.Fn("configuresControl", configuresControl), tt.Table{
+		tt.Args("user u\ncontrolmaster false\ncontrolpath none\n").Rets(false),
+		tt.Args("user u\n").Rets(false),
+		tt.Args("controlmaster auto\n").Rets(true),
+		tt.Args("controlmaster false\ncontrolpath ~/.ssh/%C\n").Rets(true),
+	})
+}
+
 func cb(s string) CodeBuffer { return CodeBuffer{s, len(s)} }
 
 func ci(s string) modes.CompletionItem { return modes.CompletionItem{ToShow: ui.T(s), ToInsert: s} }
@@ -6,7 +6,7 @@ import (
 	"src.elv.sh/pkg/parse"
 )
 
-var completers = []func(nodePath, *eval.Evaler, Config) (*context, []RawItem, error){
+var completers = []func(nodePath, *eval.Evaler, Config) (*context, generator, error){
 	completeCommand,
 	completeIndex,
 	completeRedir,
@@ -21,14 +21,13 @@ type context struct {
 	interval diag.Ranging
 }
 
-func completeArg(np nodePath, ev *eval.Evaler, cfg Config) (*context, []RawItem, error) {
+func completeArg(np nodePath, ev *eval.Evaler, cfg Config) (*context, generator, error) {
 	var form *parse.Form
 	if np.match(aSep, store(&form)) && form.Head != nil {
 		// Case 1: starting a new argument.
 		ctx := &context{"argument", "", parse.Bareword, range0(np[0].Range().To)}
 		args := purelyEvalForm(form, "", np[0].Range().To, ev)
-		items, err := generateArgs(args, ev, np, cfg)
-		return ctx, items, err
+		return ctx, generateArgs(args, ev, np, cfg), nil
 	}
 
 	expr := simpleExpr(ev)
@@ -36,18 +35,16 @@ func completeArg(np nodePath, ev *eval.Evaler, cfg Config) (*context, []RawItem,
 		// Case 2: in an incomplete argument.
 		ctx := &context{"argument", expr.s, expr.quote, expr.compound.Range()}
 		args := purelyEvalForm(form, expr.s, expr.compound.Range().From, ev)
-		items, err := generateArgs(args, ev, np, cfg)
-		return ctx, items, err
+		return ctx, generateArgs(args, ev, np, cfg), nil
 	}
 
 	return nil, nil, errNoCompletion
 }
 
-func completeCommand(np nodePath, ev *eval.Evaler, cfg Config) (*context, []RawItem, error) {
-	generateForEmpty := func(pos int) (*context, []RawItem, error) {
+func completeCommand(np nodePath, ev *eval.Evaler, cfg Config) (*context, generator, error) {
+	generateForEmpty := func(pos int) (*context, generator, error) {
 		ctx := &context{"command", "", parse.Bareword, range0(pos)}
-		items, err := generateCommands("", ev, np)
-		return ctx, items, err
+		return ctx, oneBatch(generateCommands("", ev, np)), nil
 	}
 
 	if np.match(aChunk) {
@@ -77,8 +74,7 @@ func completeCommand(np nodePath, ev *eval.Evaler, cfg Config) (*context, []RawI
 	if np.match(expr, store(&form)) && form.Head == expr.compound {
 		// Case 4: At an already started command.
 		ctx := &context{"command", expr.s, expr.quote, expr.compound.Range()}
-		items, err := generateCommands(expr.s, ev, np)
-		return ctx, items, err
+		return ctx, oneBatch(generateCommands(expr.s, ev, np)), nil
 	}
 
 	return nil, nil, errNoCompletion
@@ -86,10 +82,10 @@ func completeCommand(np nodePath, ev *eval.Evaler, cfg Config) (*context, []RawI
 
 // NOTE: This now only supports a single level of indexing; for instance,
 // $a[<Tab> is supported, but $a[x][<Tab> is not.
-func completeIndex(np nodePath, ev *eval.Evaler, cfg Config) (*context, []RawItem, error) {
-	generateForEmpty := func(v any, pos int) (*context, []RawItem, error) {
+func completeIndex(np nodePath, ev *eval.Evaler, cfg Config) (*context, generator, error) {
+	generateForEmpty := func(v any, pos int) (*context, generator, error) {
 		ctx := &context{"index", "", parse.Bareword, range0(pos)}
-		return ctx, generateIndices(v), nil
+		return ctx, oneBatch(generateIndices(v), nil), nil
 	}
 
 	var indexing *parse.Indexing
@@ -110,7 +106,7 @@ func completeIndex(np nodePath, ev *eval.Evaler, cfg Config) (*context, []RawIte
 			if indexee := ev.PurelyEvalPrimary(indexing.Head); indexee != nil {
 				ctx := &context{
 					"index", expr.s, expr.quote, expr.compound.Range()}
-				return ctx, generateIndices(indexee), nil
+				return ctx, oneBatch(generateIndices(indexee), nil), nil
 			}
 		}
 	}
@@ -118,26 +114,24 @@ func completeIndex(np nodePath, ev *eval.Evaler, cfg Config) (*context, []RawIte
 	return nil, nil, errNoCompletion
 }
 
-func completeRedir(np nodePath, ev *eval.Evaler, cfg Config) (*context, []RawItem, error) {
+func completeRedir(np nodePath, ev *eval.Evaler, cfg Config) (*context, generator, error) {
 	if np.match(aSep, aRedir) {
 		// Empty redirection target.
 		ctx := &context{"redir", "", parse.Bareword, range0(np[0].Range().To)}
-		items, err := generateFileNames("", false)
-		return ctx, items, err
+		return ctx, generateFileNames("", false), nil
 	}
 
 	expr := simpleExpr(ev)
 	if np.match(expr, aRedir) {
 		// Non-empty redirection target.
 		ctx := &context{"redir", expr.s, expr.quote, expr.compound.Range()}
-		items, err := generateFileNames(expr.s, false)
-		return ctx, items, err
+		return ctx, generateFileNames(expr.s, false), nil
 	}
 
 	return nil, nil, errNoCompletion
 }
 
-func completeVariable(np nodePath, ev *eval.Evaler, cfg Config) (*context, []RawItem, error) {
+func completeVariable(np nodePath, ev *eval.Evaler, cfg Config) (*context, generator, error) {
 	primary, ok := np[0].(*parse.Primary)
 	if !ok || primary.Type != parse.Variable {
 		return nil, nil, errNoCompletion
@@ -159,7 +153,7 @@ func completeVariable(np nodePath, ev *eval.Evaler, cfg Config) (*context, []Raw
 		items = append(items, noQuoteItem("e:"), noQuoteItem("E:"))
 	}
 
-	return ctx, items, nil
+	return ctx, oneBatch(items, nil), nil
 }
 
 func purelyEvalForm(form *parse.Form, seed string, upto int, ev *eval.Evaler) []string {
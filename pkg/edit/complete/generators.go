@@ -2,8 +2,10 @@ package complete
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"src.elv.sh/pkg/cli/lscolors"
@@ -18,10 +20,46 @@ const pathSeparator = string(filepath.Separator)
 
 var eachExternal = fsutil.EachExternal
 
+// The number of directory entries read at a time when generating file names.
+var fileNameBatchSize = 1024
+
+// A function that generates raw candidates in batches, calling emit with each
+// batch. It stops generating candidates when emit returns false.
+type generator func(emit func([]RawItem) bool) error
+
+// Returns a generator that generates items in one batch and returns err.
+func oneBatch(items []RawItem, err error) generator {
+	return func(emit func([]RawItem) bool) error {
+		if len(items) > 0 {
+			emit(items)
+		}
+		return err
+	}
+}
+
+// Runs gen to completion and returns all the items it generates.
+func collect(gen generator) ([]RawItem, error) {
+	var items []RawItem
+	err := gen(func(batch []RawItem) bool {
+		items = append(items, batch...)
+		return true
+	})
+	return items, err
+}
+
 // GenerateFileNames returns filename candidates that are suitable for completing
 // the last argument. It can be used in Config.ArgGenerator.
 func GenerateFileNames(args []string) ([]RawItem, error) {
-	return generateFileNames(args[len(args)-1], false)
+	items, err := collect(generateFileNames(args[len(args)-1], false))
+	sort.Slice(items, func(i, j int) bool { return items[i].String() < items[j].String() })
+	return items, err
+}
+
+// StreamFileNames is like GenerateFileNames, but reads the directory in
+// batches and generates candidates for each batch. It can be used in
+// Config.ArgStreamer.
+func StreamFileNames(args []string, emit func([]RawItem) bool) error {
+	return generateFileNames(args[len(args)-1], false)(emit)
 }
 
 // GenerateForSudo generates candidates for sudo.
@@ -33,18 +71,31 @@ func GenerateForSudo(args []string, ev *eval.Evaler, cfg Config) ([]RawItem, err
 		// Complete external commands.
 		return generateExternalCommands(args[1], ev)
 	default:
-		return cfg.ArgGenerator(args[1:])
+		return collect(cfg.generateArgs(args[1:]))
+	}
+}
+
+func (cfg Config) generateArgs(args []string) generator {
+	switch {
+	case cfg.ArgStreamer != nil:
+		return func(emit func([]RawItem) bool) error {
+			return cfg.ArgStreamer(args, emit)
+		}
+	case cfg.ArgGenerator != nil:
+		return oneBatch(cfg.ArgGenerator(args))
+	default:
+		return generateFileNames(args[len(args)-1], false)
 	}
 }
 
 // Internal generators, used from completers.
 
-func generateArgs(args []string, ev *eval.Evaler, np nodePath, cfg Config) ([]RawItem, error) {
+func generateArgs(args []string, ev *eval.Evaler, np nodePath, cfg Config) generator {
 	switch args[0] {
 	case "set", "tmp":
 		for _, arg := range args[1:] {
 			if arg == "=" {
-				return nil, nil
+				return oneBatch(nil, nil)
 			}
 		}
 		seed := args[len(args)-1]
@@ -54,17 +105,16 @@ func generateArgs(args []string, ev *eval.Evaler, np nodePath, cfg Config) ([]Ra
 		eachVariableInNs(ev, np, ns, func(varname string) {
 			items = append(items, noQuoteItem(sigil+parse.QuoteVariableName(ns+varname)))
 		})
-		return items, nil
+		return oneBatch(items, nil)
 	}
 
-	items, err := cfg.ArgGenerator(args)
-	return items, err
+	return cfg.generateArgs(args)
 }
 
 func generateExternalCommands(seed string, ev *eval.Evaler) ([]RawItem, error) {
 	if fsutil.DontSearch(seed) {
 		// Completing a local external command name.
-		return generateFileNames(seed, true)
+		return collect(generateFileNames(seed, true))
 	}
 	var items []RawItem
 	eachExternal(func(s string) { items = append(items, PlainItem(s)) })
@@ -74,7 +124,7 @@ func generateExternalCommands(seed string, ev *eval.Evaler) ([]RawItem, error) {
 func generateCommands(seed string, ev *eval.Evaler, np nodePath) ([]RawItem, error) {
 	if fsutil.DontSearch(seed) {
 		// Completing a local external command name.
-		return generateFileNames(seed, true)
+		return collect(generateFileNames(seed, true))
 	}
 
 	var cands []RawItem
@@ -113,65 +163,79 @@ func generateCommands(seed string, ev *eval.Evaler, np nodePath) ([]RawItem, err
 	return cands, nil
 }
 
-func generateFileNames(seed string, onlyExecutable bool) ([]RawItem, error) {
-	var items []RawItem
-
-	dir, fileprefix := filepath.Split(seed)
-	dirToRead := dir
-	if dirToRead == "" {
-		dirToRead = "."
-	}
-
-	files, err := os.ReadDir(dirToRead)
-	if err != nil {
-		return nil, fmt.Errorf("cannot list directory %s: %v", dirToRead, err)
-	}
-
-	lsColor := lscolors.GetColorist()
+func generateFileNames(seed string, onlyExecutable bool) generator {
+	return func(emit func([]RawItem) bool) error {
+		dir, fileprefix := filepath.Split(seed)
+		dirToRead := dir
+		if dirToRead == "" {
+			dirToRead = "."
+		}
 
-	// Make candidates out of elements that match the file component.
-	for _, file := range files {
-		name := file.Name()
-		stat, err := file.Info()
+		f, err := os.Open(dirToRead)
 		if err != nil {
-			continue
-		}
-		// Show dot files iff file part of pattern starts with dot, and vice
-		// versa.
-		if dotfile(fileprefix) != dotfile(name) {
-			continue
+			return fmt.Errorf("cannot list directory %s: %v", dirToRead, err)
 		}
-		// Only accept searchable directories and executable files if
-		// executableOnly is true.
-		if onlyExecutable && !fsutil.IsExecutable(stat) && !stat.IsDir() {
-			continue
-		}
-
-		// Full filename for source and getStyle.
-		full := dir + name
-
-		// Will be set to an empty space for non-directories
-		suffix := " "
-
-		if stat.IsDir() {
-			full += pathSeparator
-			suffix = ""
-		} else if stat.Mode()&os.ModeSymlink != 0 {
-			stat, err := os.Stat(full)
-			if err == nil && stat.IsDir() { // symlink to directory
-				full += pathSeparator
-				suffix = ""
+		defer f.Close()
+
+		lsColor := lscolors.GetColorist()
+		foldCase := fsutil.IsCaseInsensitive(dirToRead)
+
+		for {
+			files, err := f.ReadDir(fileNameBatchSize)
+			if len(files) == 0 {
+				if err == io.EOF {
+					return nil
+				}
+				return fmt.Errorf("cannot list directory %s: %v", dirToRead, err)
+			}
+			var items []RawItem
+			// Make candidates out of elements that match the file component.
+			for _, file := range files {
+				name := file.Name()
+				// Show dot files iff file part of pattern starts with dot, and
+				// vice versa.
+				if dotfile(fileprefix) != dotfile(name) {
+					continue
+				}
+				stat, err := file.Info()
+				if err != nil {
+					continue
+				}
+				// Only accept searchable directories and executable files if
+				// executableOnly is true.
+				if onlyExecutable && !fsutil.IsExecutable(stat) && !stat.IsDir() {
+					continue
+				}
+
+				// Full filename for source and getStyle.
+				full := dir + name
+
+				// Will be set to an empty space for non-directories
+				suffix := " "
+
+				if stat.IsDir() {
+					full += pathSeparator
+					suffix = ""
+				} else if stat.Mode()&os.ModeSymlink != 0 {
+					stat, err := os.Stat(full)
+					if err == nil && stat.IsDir() { // symlink to directory
+						full += pathSeparator
+						suffix = ""
+					}
+				}
+
+				items = append(items, ComplexItem{
+					Stem:       full,
+					CodeSuffix: suffix,
+					Display:    ui.T(full, ui.StylingFromSGR(lsColor.GetStyle(full))),
+					FoldCase:   foldCase,
+				})
+			}
+			if len(items) > 0 && !emit(items) {
+				return nil
 			}
 		}
-
-		items = append(items, ComplexItem{
-			Stem:       full,
-			CodeSuffix: suffix,
-			Display:    ui.T(full, ui.StylingFromSGR(lsColor.GetStyle(full))),
-		})
 	}
-
-	return items, nil
 }
 
 func generateIndices(v any) []RawItem {
@@ -0,0 +1,212 @@
+package complete
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"src.elv.sh/pkg/ui"
+)
+
+// How long to wait for man to format a page. Can be overridden in tests.
+var manTimeout = 2 * time.Second
+
+// Returns the formatted man page of a command. Can be overridden in tests.
+//
+// The output is read through a pipe that is closed on timeout, since the
+// processes that man starts to format the page can keep it open after man
+// itself has been killed.
+var manPage = func(cmd string) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	c := exec.Command("man", cmd)
+	c.Env = append(os.Environ(),
+		"MANPAGER=cat", "PAGER=cat", "MANWIDTH=80", "GROFF_NO_SGR=1")
+	c.Stdout = w
+	err = c.Start()
+	w.Close()
+	if err != nil {
+		return "", err
+	}
+	timer := time.AfterFunc(manTimeout, func() {
+		c.Process.Kill()
+		r.Close()
+	})
+	defer timer.Stop()
+	out, readErr := io.ReadAll(r)
+	err = c.Wait()
+	if err == nil {
+		err = readErr
+	}
+	return string(out), err
+}
+
+// Options parsed from man pages, keyed by command name. Failures are cached
+// as nil, so that man is run at most once for each command.
+var manOptionsCache sync.Map
+
+type manOption struct {
+	flag string
+	desc string
+}
+
+// GenerateManOptions generates options for the command in args[0], parsed from
+// the OPTIONS section of its man page, when the last argument starts with "-".
+// The DESCRIPTION section is used instead for man pages without an OPTIONS
+// section, which is common for GNU utilities. Man pages are parsed once for
+// each command and cached.
+//
+// It can be used in Config.ArgGenerator.
+func GenerateManOptions(args []string) ([]RawItem, error) {
+	if len(args) < 2 || !strings.HasPrefix(args[len(args)-1], "-") {
+		return nil, nil
+	}
+	var items []RawItem
+	for _, opt := range manOptions(filepath.Base(args[0])) {
+		display := opt.flag
+		if opt.desc != "" {
+			display += " (" + opt.desc + ")"
+		}
+		items = append(items, ComplexItem{Stem: opt.flag, Display: ui.T(display)})
+	}
+	return items, nil
+}
+
+func manOptions(cmd string) []manOption {
+	if opts, ok := manOptionsCache.Load(cmd); ok {
+		return opts.([]manOption)
+	}
+	var opts []manOption
+	if text, err := manPage(cmd); err == nil {
+		opts = parseManOptions(text)
+	}
+	manOptionsCache.Store(cmd, opts)
+	return opts
+}
+
+var (
+	overstrikePattern = regexp.MustCompile(".\b")
+	sgrPattern        = regexp.MustCompile("\033\\[[0-9;]*m")
+	manFlagPattern    = regexp.MustCompile(`^--?[[:alnum:]][[:alnum:]_-]*$`)
+	manColumnSep      = regexp.MustCompile(`\s{2,}`)
+)
+
+// Parses options from a formatted man page. Option lines are indented lines
+// that start with "-"; the description is either on the same line, separated
+// by at least two spaces, or on the next line with a deeper indentation.
+func parseManOptions(text string) []manOption {
+	text = overstrikePattern.ReplaceAllString(text, "")
+	text = sgrPattern.ReplaceAllString(text, "")
+	sections := manSections(strings.Split(text, "\n"))
+	lines := sections["OPTIONS"]
+	if lines == nil {
+		lines = sections["DESCRIPTION"]
+	}
+
+	var opts []manOption
+	seen := make(map[string]bool)
+	// The indentation of the last option line, or -1 if there is none yet.
+	tagIndent := -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if !strings.HasPrefix(trimmed, "-") || (tagIndent >= 0 && indent > tagIndent) {
+			if trimmed != "" && indent <= tagIndent {
+				// A paragraph that is not part of an option.
+				tagIndent = -1
+			}
+			continue
+		}
+		tag, desc, _ := cutColumn(trimmed)
+		if desc == "" {
+			desc = nextDeeperLine(lines[i+1:], indent)
+		}
+		flags := manFlags(tag)
+		if len(flags) == 0 {
+			continue
+		}
+		tagIndent = indent
+		for _, flag := range flags {
+			if !seen[flag] {
+				seen[flag] = true
+				opts = append(opts, manOption{flag, desc})
+			}
+		}
+	}
+	return opts
+}
+
+// Splits a man page into sections, keyed by headings. Headings are the lines
+// that are not indented. All sections whose headings end with "OPTIONS", like
+// "GLOBAL OPTIONS", are merged into the "OPTIONS" section.
+func manSections(lines []string) map[string][]string {
+	sections := make(map[string][]string)
+	heading := ""
+	for _, line := range lines {
+		switch {
+		case line == "":
+			if heading != "" {
+				sections[heading] = append(sections[heading], line)
+			}
+		case line[0] != ' ' && line[0] != '\t':
+			heading = strings.TrimSpace(line)
+			if strings.HasSuffix(heading, "OPTIONS") {
+				heading = "OPTIONS"
+			}
+		case heading != "":
+			sections[heading] = append(sections[heading], line)
+		}
+	}
+	return sections
+}
+
+// Splits an option line into the tag and the description that follows it on
+// the same line.
+func cutColumn(s string) (tag, desc string, found bool) {
+	loc := manColumnSep.FindStringIndex(s)
+	if loc == nil {
+		return s, "", false
+	}
+	return s[:loc[0]], normalizeSpace(s[loc[1]:]), true
+}
+
+// Returns the first non-empty line if it is indented deeper than indent.
+func nextDeeperLine(lines []string, indent int) string {
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if len(line)-len(strings.TrimLeft(line, " \t")) > indent {
+			return normalizeSpace(trimmed)
+		}
+		return ""
+	}
+	return ""
+}
+
+// Extracts the flags from an option tag like "-w, --width=COLS".
+func manFlags(tag string) []string {
+	var flags []string
+	for _, field := range strings.Fields(strings.ReplaceAll(tag, ",", " ")) {
+		if i := strings.IndexAny(field, "=[<"); i >= 0 {
+			field = field[:i]
+		}
+		if manFlagPattern.MatchString(field) {
+			flags = append(flags, field)
+		}
+	}
+	return flags
+}
+
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,28 @@
+//go:build !windows && !plan9
+
+package complete
+
+import (
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestManPage_TimesOutWhenChildrenKeepOutputOpen(t *testing.T) {
+	dir := testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{
+		"man": testutil.File{Perm: 0o755, Content: "#!/bin/sh\necho partial\nsleep 10\n"},
+	})
+	testutil.Setenv(t, "PATH", dir+":/bin:/usr/bin")
+	testutil.Set(t, &manTimeout, 10*time.Millisecond)
+
+	start := time.Now()
+	_, err := manPage("ls")
+	if err == nil {
+		t.Errorf("got nil error, want error")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("manPage took %v", d)
+	}
+}
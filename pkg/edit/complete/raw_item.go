@@ -33,6 +33,9 @@ type ComplexItem struct {
 	Stem       string  // Used in the code and the menu.
 	CodeSuffix string  // Appended to the code.
 	Display    ui.Text // How the item is displayed. If empty, defaults to ui.T(Stem).
+	// Whether the item is matched against the seed case-insensitively, like
+	// file names on case-insensitive file systems.
+	FoldCase bool
 }
 
 func (c ComplexItem) String() string { return c.Stem }
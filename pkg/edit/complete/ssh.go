@@ -0,0 +1,141 @@
+package complete
+
+import (
+	"fmt"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"src.elv.sh/pkg/ui"
+)
+
+// How long to wait for listing a remote directory, including the time needed
+// to establish the SSH connection.
+const sshTimeout = 3 * time.Second
+
+// How long the SSH master connection is kept after the last listing.
+const sshControlPersist = "5m"
+
+// Lists the entries in a directory on host, with a "/" appended to the names
+// of directories. An empty dir means the home directory. Can be overridden in
+// tests.
+var listRemoteDir = func(host, dir string) ([]string, error) {
+	var out strings.Builder
+	c := exec.Command("ssh", append(sshControlArgs(host), sshArgs(host, dir)...)...)
+	c.Stdout = &out
+	if err := c.Start(); err != nil {
+		return nil, err
+	}
+	timer := time.AfterFunc(sshTimeout, func() { c.Process.Kill() })
+	err := c.Wait()
+	if !timer.Stop() {
+		return nil, fmt.Errorf("timed out after %v", sshTimeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"), nil
+}
+
+// Returns the arguments to ssh for listing dir on host, apart from those
+// returned by sshControlArgs.
+//
+// BatchMode makes ssh fail instead of prompting for passwords or passphrases,
+// which would mess up the terminal.
+func sshArgs(host, dir string) []string {
+	args := []string{
+		"-o", "BatchMode=yes",
+		"-o", "ConnectTimeout=" + strconv.Itoa(int(sshTimeout/time.Second)),
+	}
+	cmd := "ls -1Ap"
+	if dir != "" {
+		cmd += " -- " + shQuote(dir)
+	}
+	return append(args, "--", host, cmd)
+}
+
+// Reports whether the output of ssh -G sets ControlMaster or ControlPath.
+func configuresControl(config string) bool {
+	for _, line := range strings.Split(config, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch strings.ToLower(key) {
+		case "controlmaster":
+			if value != "false" && value != "no" {
+				return true
+			}
+		case "controlpath":
+			if value != "none" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Quotes s for the POSIX shell that runs the remote command.
+func shQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// IsRemotePath returns whether arg has the form host:path or user@host:path
+// used for remote paths by commands like scp and rsync. Like those commands,
+// it considers arguments with a "/" before the first ":" to be local paths.
+func IsRemotePath(arg string) bool {
+	_, _, ok := splitRemotePath(arg)
+	return ok
+}
+
+func splitRemotePath(arg string) (host, p string, ok bool) {
+	i := strings.IndexByte(arg, ':')
+	if i <= 0 || strings.HasPrefix(arg, "-") || strings.Contains(arg[:i], "/") ||
+		strings.HasPrefix(arg[i+1:], ":") {
+		// rsync uses host::module for rsync daemons, not SSH.
+		return "", "", false
+	}
+	return arg[:i], arg[i+1:], true
+}
+
+// GenerateRemoteFileNames generates file names on a remote host when the last
+// argument has the form host:path, by listing the directory with ls over SSH.
+// It generates nothing for other arguments.
+//
+// SSH connections are shared across calls using a control socket, and listing
+// is given up after a few seconds, or immediately if SSH needs a password.
+//
+// It can be used in Config.ArgGenerator.
+func GenerateRemoteFileNames(args []string) ([]RawItem, error) {
+	host, p, ok := splitRemotePath(args[len(args)-1])
+	if !ok {
+		return nil, nil
+	}
+	dir, fileprefix := path.Split(p)
+	// Quoting disables tilde expansion on the remote host, so list paths
+	// relative to the home directory instead, which is the working directory
+	// of the remote command.
+	dirToList := dir
+	if dirToList == "~/" {
+		dirToList = ""
+	} else if strings.HasPrefix(dirToList, "~/") {
+		dirToList = dirToList[2:]
+	}
+
+	names, err := listRemoteDir(host, dirToList)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list directory %s:%s: %v", host, dir, err)
+	}
+	var items []RawItem
+	for _, name := range names {
+		if name == "" || dotfile(fileprefix) != dotfile(name) {
+			continue
+		}
+		full := host + ":" + dir + name
+		suffix := " "
+		if strings.HasSuffix(name, "/") {
+			suffix = ""
+		}
+		items = append(items, ComplexItem{Stem: full, CodeSuffix: suffix, Display: ui.T(full)})
+	}
+	return items, nil
+}
@@ -0,0 +1,79 @@
+//go:build !windows && !plan9
+
+package complete
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"src.elv.sh/pkg/env"
+)
+
+// Returns the options to ssh for sharing the connection to host with later
+// listings through a control socket, so that only the first listing pays for
+// establishing the connection.
+//
+// Nothing is returned when the SSH configuration already sets ControlMaster or
+// ControlPath for host, so that it takes effect instead. Otherwise, the socket
+// is put in a directory that only the current user can access, since anyone
+// who can connect to it can use the connection; nothing is returned if there
+// is no such directory.
+func sshControlArgs(host string) []string {
+	if sshConfiguresControl(host) {
+		return nil
+	}
+	dir, err := sshControlDir()
+	if err != nil {
+		return nil
+	}
+	return []string{
+		"-o", "ControlMaster=auto",
+		"-o", "ControlPath=" + filepath.Join(dir, "ssh-%C"),
+		"-o", "ControlPersist=" + sshControlPersist,
+	}
+}
+
+// Reports whether the SSH configuration for host sets ControlMaster or
+// ControlPath, or if that can't be determined. Can be overridden in tests.
+var sshConfiguresControl = func(host string) bool {
+	var out strings.Builder
+	c := exec.Command("ssh", "-G", "--", host)
+	c.Stdout = &out
+	if err := c.Start(); err != nil {
+		return true
+	}
+	timer := time.AfterFunc(sshTimeout, func() { c.Process.Kill() })
+	defer timer.Stop()
+	if err := c.Wait(); err != nil {
+		return true
+	}
+	return configuresControl(out.String())
+}
+
+// Returns the directory for control sockets, creating it if needed. Like the
+// run directory of the daemon, it is $XDG_RUNTIME_DIR/elvish if XDG_RUNTIME_DIR
+// is set, or elvish-$uid in the system temporary directory otherwise.
+func sshControlDir() (string, error) {
+	dir := filepath.Join(os.TempDir(), "elvish-"+strconv.Itoa(os.Getuid()))
+	if xdg := os.Getenv(env.XDG_RUNTIME_DIR); xdg != "" {
+		dir = filepath.Join(xdg, "elvish")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	stat := info.Sys().(*syscall.Stat_t)
+	if !info.IsDir() || int(stat.Uid) != os.Getuid() || stat.Mode&077 != 0 {
+		return "", fmt.Errorf("%s is accessible to other users", dir)
+	}
+	return dir, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"sync"
@@ -58,51 +59,143 @@ func completionStart(ed *Editor, bindings tk.Bindings, ev *eval.Evaler, cfg comp
 		ed.applyAutofix()
 	}
 	buf := codeArea.CopyState().Buffer
-	result, err := complete.Complete(
+	result, err := complete.CompleteStream(
 		complete.CodeBuffer{Content: buf.Content, Dot: buf.Dot}, ev, cfg)
 	if err != nil {
 		ed.app.Notify(modes.ErrorText(err))
+		ed.app.Bell()
 		return
 	}
-	if smart {
-		prefix := ""
-		for i, item := range result.Items {
-			if i == 0 {
-				prefix = item.ToInsert
-				continue
+	if result.Updates == nil {
+		if smart && insertCommonPrefix(codeArea, result) {
+			return
+		}
+		pushCompletion(ed, bindings, result)
+		return
+	}
+
+	// More candidates are still being generated. Show the ones generated so
+	// far, and update the completion mode as more are generated. The updates
+	// are made from the event loop, so that they don't race with the handling
+	// of events, which also changes the completion mode. Variables below are
+	// only accessed from the event loop.
+	var w modes.Completion
+	if len(result.Items) > 0 {
+		w = pushCompletion(ed, bindings, result)
+	}
+	stopped := false
+	stopCh := make(chan struct{})
+	stop := func() {
+		stopped = true
+		close(stopCh)
+	}
+	update := func(items []modes.CompletionItem) {
+		if stopped {
+			return
+		}
+		result.Items = items
+		if w == nil {
+			if codeArea.CopyState().Buffer != buf {
+				// The user has kept typing before any candidate was generated.
+				stop()
+			} else if len(items) > 0 {
+				w = pushCompletion(ed, bindings, result)
+			}
+			return
+		}
+		if ed.app.ActiveWidget() != tk.Widget(w) {
+			// The completion mode has been accepted or dismissed.
+			stop()
+			return
+		}
+		w.SetItems(items)
+	}
+	finish := func() {
+		if stopped {
+			return
+		}
+		switch {
+		case w == nil:
+			if codeArea.CopyState().Buffer == buf {
+				// Report that there are no candidates.
+				pushCompletion(ed, bindings, result)
 			}
-			prefix = commonPrefix(prefix, item.ToInsert)
-			if prefix == "" {
-				break
+		case smart && ed.app.ActiveWidget() == tk.Widget(w) &&
+			w.CodeArea().CopyState().Buffer.Content == "":
+			// With all the candidates known, do what smart-start would have
+			// done if it had them from the beginning, unless the user has
+			// started filtering.
+			code := codeArea.CopyState().Buffer.Content
+			if _, ok := commonPrefixToInsert(code, result); ok {
+				ed.app.PopAddon()
+				insertCommonPrefix(codeArea, result)
 			}
 		}
-		if prefix != "" {
-			insertedPrefix := false
-			codeArea.MutateState(func(s *tk.CodeAreaState) {
-				rep := s.Buffer.Content[result.Replace.From:result.Replace.To]
-				if len(prefix) > len(rep) && strings.HasPrefix(prefix, rep) {
-					s.Pending = tk.PendingCode{
-						Content: prefix,
-						From:    result.Replace.From, To: result.Replace.To}
-					s.ApplyPending()
-					insertedPrefix = true
+	}
+	go func() {
+		defer result.Cancel()
+		for {
+			select {
+			case items, ok := <-result.Updates:
+				if !ok {
+					ed.app.Schedule(finish)
+					return
 				}
-			})
-			if insertedPrefix {
+				ed.app.Schedule(func() { update(items) })
+			case <-stopCh:
 				return
 			}
 		}
+	}()
+}
+
+// Inserts the longest common prefix of all the candidates if it is longer
+// than the text to replace, and reports whether it has done so.
+func insertCommonPrefix(codeArea tk.CodeArea, result *complete.Result) bool {
+	insertedPrefix := false
+	codeArea.MutateState(func(s *tk.CodeAreaState) {
+		if prefix, ok := commonPrefixToInsert(s.Buffer.Content, result); ok {
+			s.Pending = tk.PendingCode{
+				Content: prefix,
+				From:    result.Replace.From, To: result.Replace.To}
+			s.ApplyPending()
+			insertedPrefix = true
+		}
+	})
+	return insertedPrefix
+}
+
+func commonPrefixToInsert(code string, result *complete.Result) (string, bool) {
+	prefix := ""
+	for i, item := range result.Items {
+		if i == 0 {
+			prefix = item.ToInsert
+			continue
+		}
+		prefix = commonPrefix(prefix, item.ToInsert)
+		if prefix == "" {
+			break
+		}
 	}
+	rep := code[result.Replace.From:result.Replace.To]
+	return prefix, len(prefix) > len(rep) && strings.HasPrefix(prefix, rep)
+}
+
+// Starts the completion mode with the candidates in the result, and returns
+// the widget for it, or nil if it cannot be started.
+func pushCompletion(ed *Editor, bindings tk.Bindings, result *complete.Result) modes.Completion {
 	w, err := modes.NewCompletion(ed.app, modes.CompletionSpec{
 		Name: result.Name, Replace: result.Replace, Items: result.Items,
 		Filter: filterSpec, Bindings: bindings,
 	})
 	if w != nil {
-		ed.app.PushAddon(w)
+		ed.pushMode("completion", w)
 	}
 	if err != nil {
 		ed.app.Notify(modes.ErrorText(err))
+		ed.app.Bell()
 	}
+	return w
 }
 
 func initCompletion(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
@@ -114,7 +207,7 @@ func initCompletion(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 		return complete.Config{
 			Filterer: adaptMatcherMap(
 				ed, ev, matcherMapVar.Get().(vals.Map)),
-			ArgGenerator: adaptArgGeneratorMap(
+			ArgStreamer: adaptArgGeneratorMap(
 				ev, argGeneratorMapVar.Get().(vals.Map)),
 		}
 	}
@@ -122,13 +215,15 @@ func initCompletion(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 		return complete.GenerateForSudo(args, ev, cfg())
 	}
 	nb.AddGoFns(map[string]any{
-		"complete-filename": wrapArgGenerator(complete.GenerateFileNames),
-		"complete-getopt":   completeGetopt,
-		"complete-sudo":     wrapArgGenerator(generateForSudo),
-		"complex-candidate": complexCandidate,
-		"match-prefix":      wrapMatcher(strings.HasPrefix),
-		"match-subseq":      wrapMatcher(strutil.HasSubseq),
-		"match-substr":      wrapMatcher(strings.Contains),
+		"complete-filename":        wrapArgGenerator(complete.GenerateFileNames),
+		"complete-getopt":          completeGetopt,
+		"complete-man-options":     wrapArgGenerator(complete.GenerateManOptions),
+		"complete-remote-filename": wrapArgGenerator(complete.GenerateRemoteFileNames),
+		"complete-sudo":            wrapArgGenerator(generateForSudo),
+		"complex-candidate":        complexCandidate,
+		"match-prefix":             wrapMatcher(strings.HasPrefix),
+		"match-subseq":             wrapMatcher(strutil.HasSubseq),
+		"match-substr":             wrapMatcher(strings.Contains),
 	})
 	app := ed.app
 	nb.AddNs("completion",
@@ -342,14 +437,41 @@ func adaptMatcherMap(nt notifier, ev *eval.Evaler, m vals.Map) complete.Filterer
 	}
 }
 
-func adaptArgGeneratorMap(ev *eval.Evaler, m vals.Map) complete.ArgGenerator {
-	return func(args []string) ([]complete.RawItem, error) {
+// Commands whose arguments of the form host:path are remote paths accessed
+// over SSH.
+var remotePathCommands = map[string]bool{
+	"rsync": true, "scp": true, "sftp": true, "sshfs": true,
+}
+
+// Adapts $edit:completion:arg-completer into an ArgStreamer. Candidates from
+// the builtin file name completer are generated in batches, while those from
+// an Elvish arg completer are emitted in one batch.
+//
+// For commands without an arg completer, options parsed from the man page are
+// used when completing an argument starting with "-", falling back to file
+// names if the man page has no options. Arguments of commands that take remote
+// paths, like scp, are completed with file names on the remote host when they
+// have the form host:path.
+func adaptArgGeneratorMap(ev *eval.Evaler, m vals.Map) complete.ArgStreamer {
+	return func(args []string, emit func([]complete.RawItem) bool) error {
 		gen, ok := lookupFn(m, args[0])
 		if !ok {
-			return nil, fmt.Errorf("arg completer for %s not a function", args[0])
+			return fmt.Errorf("arg completer for %s not a function", args[0])
 		}
 		if gen == nil {
-			return complete.GenerateFileNames(args)
+			if items, _ := complete.GenerateManOptions(args); len(items) > 0 {
+				emit(items)
+				return nil
+			}
+			if remotePathCommands[filepath.Base(args[0])] &&
+				complete.IsRemotePath(args[len(args)-1]) {
+				items, err := complete.GenerateRemoteFileNames(args)
+				if len(items) > 0 {
+					emit(items)
+				}
+				return err
+			}
+			return complete.StreamFileNames(args, emit)
 		}
 		argValues := make([]any, len(args))
 		for i, arg := range args {
@@ -397,7 +519,10 @@ func adaptArgGeneratorMap(ev *eval.Evaler, m vals.Map) complete.ArgGenerator {
 				nil, port1, {File: os.Stderr}}})
 		done()
 
-		return output, err
+		if len(output) > 0 {
+			emit(output)
+		}
+		return err
 	}
 }
 
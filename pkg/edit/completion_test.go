@@ -1,6 +1,7 @@
 package edit
 
 import (
+	"fmt"
 	"testing"
 
 	"src.elv.sh/pkg/cli/term"
@@ -50,6 +51,45 @@ func TestCompletionAddon_CompletesLongestCommonPrefix(t *testing.T) {
 	)
 }
 
+func TestCompletionAddon_ManyCandidates(t *testing.T) {
+	f := setup(t)
+
+	files := testutil.Dir{"zz": ""}
+	for i := 0; i < 1100; i++ {
+		files[fmt.Sprintf("a%04d", i)] = ""
+	}
+	testutil.ApplyDir(files)
+
+	// The filter applies to candidates generated after the completion mode
+	// has started.
+	feedInput(f.TTYCtrl, "echo \tzz")
+	f.TestTTY(t,
+		"~> echo zz \n", Styles,
+		"   vvvv ___",
+		" COMPLETING argument  zz", Styles,
+		"*********************   ", term.DotHere, "\n",
+		"zz", Styles,
+		"++",
+	)
+}
+
+func TestCompletionAddon_ManyCandidatesWithCommonPrefix(t *testing.T) {
+	f := setup(t)
+
+	files := testutil.Dir{}
+	for i := 0; i < 1100; i++ {
+		files[fmt.Sprintf("foo%04d", i)] = ""
+	}
+	testutil.ApplyDir(files)
+
+	// The longest common prefix is inserted once all candidates are known.
+	feedInput(f.TTYCtrl, "echo \t")
+	f.TestTTY(t,
+		"~> echo foo", Styles,
+		"   vvvv", term.DotHere,
+	)
+}
+
 func TestCompletionAddon_AppliesAutofix(t *testing.T) {
 	f := setup(t)
 	fooNs := eval.BuildNs().AddGoFn("a", func() {}).AddGoFn("b", func() {}).Ns()
@@ -96,9 +136,9 @@ func TestComplexCandidate(t *testing.T) {
 		That("cc a/b").Puts(complexItem{Stem: "a/b"}),
 		That("cc a/b &code-suffix=' '").Puts(complexItem{Stem: "a/b", CodeSuffix: " "}),
 		That("cc a/b &code-suffix=' ' &display=A/B").Puts(
-			complexItem{"a/b", " ", ui.T("A/B")}),
+			complexItem{Stem: "a/b", CodeSuffix: " ", Display: ui.T("A/B")}),
 		That("cc a/b &code-suffix=' ' &display=(styled A/B red)").Puts(
-			complexItem{"a/b", " ", ui.T("A/B", ui.FgRed)}),
+			complexItem{Stem: "a/b", CodeSuffix: " ", Display: ui.T("A/B", ui.FgRed)}),
 		That("cc a/b &code-suffix=' ' &display=[]").Throws(
 			errs.BadValue{What: "&display", Valid: "string or styled", Actual: "[]"}),
 
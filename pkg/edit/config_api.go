@@ -2,27 +2,359 @@ package edit
 
 import (
 	"fmt"
+	"math"
 	"os"
+	"os/user"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/histutil"
+	"src.elv.sh/pkg/cli/term"
 	"src.elv.sh/pkg/diag"
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/eval/vars"
 	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/ui"
 )
 
 func initMaxHeight(appSpec *cli.AppSpec, nb eval.NsBuilder) {
 	maxHeight := newIntVar(-1)
 	appSpec.MaxHeight = func() int { return maxHeight.GetRaw().(int) }
 	nb.AddVar("max-height", maxHeight)
+
+	maxCodeHeight := newIntVar(-1)
+	appSpec.MaxCodeHeight = func() int { return maxCodeHeight.GetRaw().(int) }
+	nb.AddVar("max-code-height", maxCodeHeight)
+}
+
+func initTabWidth(appSpec *cli.AppSpec, nb eval.NsBuilder) {
+	tabWidth := newIntVar(-1)
+	appSpec.TabWidth = func() int { return tabWidth.GetRaw().(int) }
+	nb.AddVar("tab-width", tabWidth)
+}
+
+var bellStyles = map[string]cli.BellStyle{
+	"silent":  cli.SilentBell,
+	"audible": cli.AudibleBell,
+	"visual":  cli.VisualBell,
+}
+
+func initBellStyle(appSpec *cli.AppSpec, nb eval.NsBuilder) {
+	var mutex sync.RWMutex
+	style := "silent"
+	appSpec.BellStyle = func() cli.BellStyle {
+		mutex.RLock()
+		defer mutex.RUnlock()
+		return bellStyles[style]
+	}
+	nb.AddVar("bell-style", vars.FromSetGet(
+		func(v any) error {
+			s, ok := v.(string)
+			if _, valid := bellStyles[s]; !ok || !valid {
+				return errs.BadValue{What: "bell style",
+					Valid: "silent, audible or visual", Actual: vals.ReprPlain(v)}
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			style = s
+			return nil
+		},
+		func() any {
+			mutex.RLock()
+			defer mutex.RUnlock()
+			return style
+		}))
+}
+
+func initMouseTracking(appSpec *cli.AppSpec, nb eval.NsBuilder) {
+	mouseTracking := newBoolVar(false)
+	appSpec.MouseTracking = func() bool { return mouseTracking.Get().(bool) }
+	nb.AddVar("mouse-tracking", mouseTracking)
+}
+
+func initReaderConfig(nb eval.NsBuilder) {
+	// The reader configuration is global, since all terminal readers in the
+	// process share the same terminal.
+	setConfig := func(f func(*term.ReaderConfig)) {
+		cfg := term.GetReaderConfig()
+		f(&cfg)
+		term.SetReaderConfig(cfg)
+	}
+	nb.AddVar("esc-timeout", vars.FromSetGet(
+		func(v any) error {
+			var seconds float64
+			err := vals.ScanToGo(v, &seconds)
+			if err != nil || !(seconds >= 0) || math.IsInf(seconds, 1) {
+				return errs.BadValue{What: "escape timeout",
+					Valid: "non-negative number", Actual: vals.ReprPlain(v)}
+			}
+			setConfig(func(cfg *term.ReaderConfig) {
+				cfg.KeySeqTimeout = time.Duration(seconds * float64(time.Second))
+			})
+			return nil
+		},
+		func() any { return term.GetReaderConfig().KeySeqTimeout.Seconds() }))
+	nb.AddVar("esc-as-meta", vars.FromSetGet(
+		func(v any) error {
+			b, ok := v.(bool)
+			if !ok {
+				return errs.BadValue{What: "esc-as-meta",
+					Valid: "bool", Actual: vals.Kind(v)}
+			}
+			setConfig(func(cfg *term.ReaderConfig) { cfg.ESCAsMeta = b })
+			return nil
+		},
+		func() any { return term.GetReaderConfig().ESCAsMeta }))
+}
+
+func initWriterConfig(nb eval.NsBuilder) {
+	// Like the reader configuration, the writer configuration is global.
+	setConfig := func(f func(*term.WriterConfig)) {
+		cfg := term.GetWriterConfig()
+		f(&cfg)
+		term.SetWriterConfig(cfg)
+	}
+	nb.AddVar("max-redraw-rate", vars.FromSetGet(
+		func(v any) error {
+			var rate float64
+			err := vals.ScanToGo(v, &rate)
+			if err != nil || !(rate >= 0) || math.IsInf(rate, 1) {
+				return errs.BadValue{What: "max redraw rate",
+					Valid: "non-negative number", Actual: vals.ReprPlain(v)}
+			}
+			setConfig(func(cfg *term.WriterConfig) { cfg.MaxRedrawRate = rate })
+			return nil
+		},
+		func() any { return term.GetWriterConfig().MaxRedrawRate }))
+	nb.AddVar("redraw-rate-threshold", vars.FromSetGet(
+		func(v any) error {
+			var threshold int
+			err := vals.ScanToGo(v, &threshold)
+			if err != nil || threshold < 0 {
+				return errs.BadValue{What: "redraw rate threshold",
+					Valid: "non-negative integer", Actual: vals.ReprPlain(v)}
+			}
+			setConfig(func(cfg *term.WriterConfig) { cfg.RateLimitThreshold = threshold })
+			return nil
+		},
+		func() any { return term.GetWriterConfig().RateLimitThreshold }))
+}
+
+func initClipboardConfig(nb eval.NsBuilder) {
+	// Like the writer configuration, the clipboard configuration is global.
+	commandVar := func(what string, field func(*term.ClipboardConfig) *[]string) vars.Var {
+		return vars.FromSetGet(
+			func(v any) error {
+				var command []string
+				l, ok := v.(vals.List)
+				if !ok || vals.ScanListToGo(l, &command) != nil {
+					return errs.BadValue{What: what,
+						Valid: "list of strings", Actual: vals.ReprPlain(v)}
+				}
+				cfg := term.GetClipboardConfig()
+				*field(&cfg) = command
+				term.SetClipboardConfig(cfg)
+				return nil
+			},
+			func() any {
+				cfg := term.GetClipboardConfig()
+				l := vals.EmptyList
+				for _, arg := range *field(&cfg) {
+					l = l.Conj(arg)
+				}
+				return l
+			})
+	}
+	nb.AddVar("clipboard-copy-command", commandVar("clipboard copy command",
+		func(cfg *term.ClipboardConfig) *[]string { return &cfg.CopyCommand }))
+	nb.AddVar("clipboard-paste-command", commandVar("clipboard paste command",
+		func(cfg *term.ClipboardConfig) *[]string { return &cfg.PasteCommand }))
+}
+
+func initTheme(nb eval.NsBuilder) {
+	// Like the clipboard configuration, the theme is global.
+	nb.AddVar("theme", vars.FromSetGet(setTheme, getTheme))
+}
+
+func getTheme() any {
+	m := vals.EmptyMap
+	for role, styling := range ui.Theme() {
+		m = m.Assoc(role, styling)
+	}
+	return m
+}
+
+func setTheme(v any) error {
+	m, ok := v.(vals.Map)
+	if !ok {
+		return errs.BadValue{What: "$edit:theme",
+			Valid: "map", Actual: vals.Kind(v)}
+	}
+	theme := make(map[string]string, m.Len())
+	for it := m.Iterator(); it.HasElem(); it.Next() {
+		k, v := it.Elem()
+		role, ok := k.(string)
+		if _, known := ui.LookupRole(role); !ok || !known {
+			return errs.BadValue{What: "key of $edit:theme",
+				Valid: "name of role", Actual: vals.ReprPlain(k)}
+		}
+		styling, ok := v.(string)
+		if !ok || (styling != "" && ui.ParseStyling(styling) == nil) {
+			return errs.BadValue{What: "value of $edit:theme",
+				Valid: "styling string", Actual: vals.ReprPlain(v)}
+		}
+		theme[role] = styling
+	}
+	return ui.SetTheme(theme)
+}
+
+// Facts about the current machine that edit:when tests. Can be overridden in
+// tests.
+var (
+	whenHostname = os.Hostname
+	whenUsername = func() (string, error) {
+		u, err := user.Current()
+		if err != nil {
+			return "", err
+		}
+		return u.Username, nil
+	}
+	whenOS   = runtime.GOOS
+	whenArch = runtime.GOARCH
+)
+
+type whenOpts struct {
+	Name string
+	Host any
+	User any
+	OS   any
+	Arch any
+}
+
+func (*whenOpts) SetDefaultOptions() {}
+
+func initConfigLayers(nb eval.NsBuilder) {
+	var mutex sync.RWMutex
+	layers := vals.EmptyList
+	nb.AddVar("config-layers", vars.FromGet(func() any {
+		mutex.RLock()
+		defer mutex.RUnlock()
+		return layers
+	}))
+	nb.AddGoFn("when", func(fm *eval.Frame, opts whenOpts, body eval.Callable) error {
+		name, match, err := matchWhen(opts)
+		if err != nil || !match {
+			return err
+		}
+		mutex.Lock()
+		layers = layers.Conj(name)
+		mutex.Unlock()
+		return body.Call(fm.Fork("edit:when"), eval.NoArgs, eval.NoOpts)
+	})
+}
+
+// Tests the conditions of edit:when, and returns the name of the layer and
+// whether all the conditions are met.
+func matchWhen(opts whenOpts) (string, bool, error) {
+	hostnames := func() []string {
+		host, err := whenHostname()
+		if err != nil {
+			return nil
+		}
+		// Also match the host name without the domain.
+		short, _, _ := strings.Cut(host, ".")
+		return []string{host, short}
+	}
+	usernames := func() []string {
+		name, err := whenUsername()
+		if err != nil {
+			return nil
+		}
+		// On Windows, also match the user name without the domain.
+		_, short, _ := strings.Cut(name, `\`)
+		return []string{name, short}
+	}
+	var desc []string
+	match := true
+	for _, cond := range []struct {
+		what  string
+		opt   any
+		facts func() []string
+	}{
+		{"host", opts.Host, hostnames},
+		{"user", opts.User, usernames},
+		{"os", opts.OS, func() []string { return []string{whenOS} }},
+		{"arch", opts.Arch, func() []string { return []string{whenArch} }},
+	} {
+		if cond.opt == nil || cond.opt == "" {
+			continue
+		}
+		wants, err := whenValues(cond.what, cond.opt)
+		if err != nil {
+			return "", false, err
+		}
+		desc = append(desc, cond.what+"="+strings.Join(wants, ","))
+		if match && !anyEqual(wants, cond.facts()) {
+			match = false
+		}
+	}
+	name := opts.Name
+	switch {
+	case name != "":
+	case len(desc) == 0:
+		name = "always"
+	default:
+		name = strings.Join(desc, " ")
+	}
+	return name, match, nil
+}
+
+// Converts the value of a condition of edit:when, which can be a string or a
+// list of strings.
+func whenValues(what string, v any) ([]string, error) {
+	if s, ok := v.(string); ok {
+		return []string{s}, nil
+	}
+	bad := errs.BadValue{What: "&" + what + " option",
+		Valid: "string or list of strings", Actual: vals.ReprPlain(v)}
+	if vals.Kind(v) != "list" {
+		return nil, bad
+	}
+	var values []string
+	err := vals.Iterate(v, func(elem any) bool {
+		s, ok := elem.(string)
+		if ok {
+			values = append(values, s)
+		}
+		return ok
+	})
+	if err != nil || len(values) != vals.Len(v) {
+		return nil, bad
+	}
+	return values, nil
+}
+
+func anyEqual(wants, facts []string) bool {
+	for _, want := range wants {
+		for _, fact := range facts {
+			if fact != "" && want == fact {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func initReadlineHooks(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder) {
+	initAfterChdir(appSpec, ev, nb)
 	initBeforeReadline(appSpec, ev, nb)
 	initAfterReadline(appSpec, ev, nb)
+	initLineRewriters(appSpec, ev, nb)
 }
 
 func initBeforeReadline(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder) {
@@ -33,6 +365,41 @@ func initBeforeReadline(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder
 	})
 }
 
+// Runs the hooks in $edit:after-chdir before a readline cycle if the working
+// directory has changed since the previous one. Unlike $after-chdir, this
+// only happens once per command line, and not for directory changes that have
+// been reverted by the time the command line finishes.
+func initAfterChdir(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder) {
+	hook := newListVar(vals.EmptyList)
+	nb.AddVar("after-chdir", hook)
+	var mutex sync.Mutex
+	// The working directory after the last chdir event, and when the last
+	// readline cycle started.
+	var wd, readlineWd string
+	first := true
+	ev.AfterChdir = append(ev.AfterChdir, func(e eval.ChdirEvent) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		wd = e.Dir
+	})
+	appSpec.BeforeReadline = append(appSpec.BeforeReadline, func() {
+		mutex.Lock()
+		if first {
+			first = false
+			readlineWd, _ = os.Getwd()
+		}
+		changed := wd != "" && wd != readlineWd
+		if changed {
+			readlineWd = wd
+		}
+		newWd := readlineWd
+		mutex.Unlock()
+		if changed {
+			callHooks(ev, "$<edit>:after-chdir", hook.Get().(vals.List), newWd)
+		}
+	})
+}
+
 func initAfterReadline(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder) {
 	hook := newListVar(vals.EmptyList)
 	nb.AddVar("after-readline", hook)
@@ -41,6 +408,14 @@ func initAfterReadline(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder)
 	})
 }
 
+func initLineRewriters(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder) {
+	rewriters := newListVar(vals.EmptyList)
+	nb.AddVar("line-rewriters", rewriters)
+	appSpec.RewriteCode = func(code string) string {
+		return callRewriters(ev, "$<edit>:line-rewriters", rewriters.Get().(vals.List), code)
+	}
+}
+
 func initAddCmdFilters(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder, s histutil.Store) {
 	ignoreLeadingSpace := eval.NewGoFn("<ignore-cmd-with-leading-space>",
 		func(s string) bool { return !strings.HasPrefix(s, " ") })
@@ -57,6 +432,17 @@ func initAddCmdFilters(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder,
 	})
 }
 
+func initIncognito(ed *Editor, nb eval.NsBuilder) {
+	ed.incognito = newBoolVar(false)
+	nb.AddVar("incognito", ed.incognito)
+}
+
+// Returns whether the current session is private, in which case neither
+// commands nor directories are saved to the store.
+func (ed *Editor) isIncognito() bool {
+	return ed.incognito.Get().(bool)
+}
+
 func initGlobalBindings(appSpec *cli.AppSpec, nt notifier, ev *eval.Evaler, nb eval.NsBuilder) {
 	bindingVar := newBindingVar(emptyBindingsMap)
 	appSpec.GlobalBindings = newMapBindings(nt, ev, bindingVar)
@@ -138,6 +524,46 @@ func callFilters(ev *eval.Evaler, name string, filters vals.List, args ...any) b
 	return true
 }
 
+// Calls each rewriter with the code returned by the previous one, and returns
+// the final code. Rewriters that output nothing or fail leave the code
+// unchanged.
+func callRewriters(ev *eval.Evaler, name string, rewriters vals.List, code string) string {
+	i := -1
+	for it := rewriters.Iterator(); it.HasElem(); it.Next() {
+		i++
+		name := fmt.Sprintf("%s[%d]", name, i)
+		fn, ok := it.Elem().(eval.Callable)
+		if !ok {
+			diag.Complainf(os.Stderr, "%s not function", name)
+			continue
+		}
+
+		port1, collect, err := eval.ValueCapturePort()
+		if err != nil {
+			diag.Complainf(os.Stderr, "cannot create pipe to run rewriter")
+			return code
+		}
+		err = ev.Call(fn, eval.CallCfg{Args: []any{code}, From: name},
+			eval.EvalCfg{Ports: []*eval.Port{nil, port1, {File: os.Stderr}}})
+		out := collect()
+
+		if err != nil {
+			diag.ShowError(os.Stderr, err)
+			continue
+		}
+		if len(out) == 0 {
+			continue
+		}
+		s, ok := out[0].(string)
+		if len(out) > 1 || !ok {
+			diag.Complainf(os.Stderr, "rewriter %s should output at most one string", name)
+			continue
+		}
+		code = s
+	}
+	return code
+}
+
 func newIntVar(i int) vars.PtrVar             { return vars.FromPtr(&i) }
 func newFloatVar(f float64) vars.PtrVar       { return vars.FromPtr(&f) }
 func newBoolVar(b bool) vars.PtrVar           { return vars.FromPtr(&b) }
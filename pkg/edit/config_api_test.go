@@ -1,10 +1,21 @@
 package edit
 
 import (
+	"os"
+	"reflect"
 	"testing"
+	"time"
 
+	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/testutil"
 	"src.elv.sh/pkg/ui"
 )
 
@@ -44,6 +55,197 @@ func TestAfterReadline(t *testing.T) {
 	})
 }
 
+func TestBeforeReadline_UpdatesState(t *testing.T) {
+	f := setup(t, rc(
+		`set edit:before-readline = [ { edit:update-state {|s| put [&buffer=echo] } } ]`))
+
+	f.TestTTY(t,
+		"~> echo", Styles,
+		"   vvvv", term.DotHere)
+}
+
+func TestLineRewriters(t *testing.T) {
+	f := setup(t, rc(
+		`var called-with = ''`,
+		`set edit:after-readline = [ {|code| set called-with = $code } ]`,
+		`set edit:line-rewriters = [
+			{|line| if (eq $line gs) { put 'git status' } }
+			{|line| }
+			{|line| put $line' -s' }
+		]`))
+
+	feedInput(f.TTYCtrl, "gs\n")
+	code, err := f.Wait()
+
+	if code != "git status -s" || err != nil {
+		t.Errorf("ReadCode -> (%q, %v), want (%q, nil)", code, err, "git status -s")
+	}
+	testCommands(t, f.Store, storedefs.Cmd{Text: "git status -s", Seq: 1})
+	testGlobal(t, f.Evaler, "called-with", "git status -s")
+}
+
+func TestBellStyle(t *testing.T) {
+	f := setup(t, rc(`set edit:bell-style = audible`))
+
+	// Reaching the end of history.
+	f.TTYCtrl.Inject(term.K(ui.Up))
+	f.TestTTYNotes(t,
+		"error: end of history", Styles,
+		"!!!!!!")
+	if n := f.TTYCtrl.Bells(); n != 1 {
+		t.Errorf("got %d bells, want 1", n)
+	}
+
+	// An exception thrown from a binding.
+	evals(f.Evaler, `set edit:insert:binding[Ctrl-X] = { fail bad }`)
+	f.TTYCtrl.Inject(term.K('X', ui.Ctrl))
+	f.TestTTYNotes(t,
+		"[binding error] bad\n",
+		`see stack trace with "show $edit:exceptions[0]"`)
+	if n := f.TTYCtrl.Bells(); n != 2 {
+		t.Errorf("got %d bells, want 2", n)
+	}
+
+	evals(f.Evaler, `var ok = ?(set edit:bell-style = loud)`,
+		`var ok = (bool $ok)`, `var style = $edit:bell-style`)
+	testGlobals(t, f.Evaler, map[string]any{"ok": false, "style": "audible"})
+}
+
+func TestMouseTracking(t *testing.T) {
+	f := setup(t, rc(`set edit:mouse-tracking = $true`))
+	f.TTYCtrl.Inject(term.K('a'), term.K('b'))
+	f.TestTTY(t,
+		"~> ab", Styles,
+		"   !!", term.DotHere)
+	if !f.TTYCtrl.MouseTracking() {
+		t.Errorf("mouse tracking not turned on")
+	}
+
+	// Click before "ab", with the editor starting at the first line of the
+	// screen.
+	f.TTYCtrl.Inject(
+		term.MouseEvent{Pos: term.Pos{Line: 1, Col: 4}, Down: true, Button: term.LeftButton},
+		term.CursorPosition{Line: 1, Col: 6})
+	f.TestTTY(t,
+		"~> ", term.DotHere, "ab", Styles,
+		"!!")
+}
+
+func TestReaderConfig(t *testing.T) {
+	t.Cleanup(func() { term.SetReaderConfig(term.DefaultReaderConfig) })
+	f := setup(t)
+
+	evals(f.Evaler, `var timeout = $edit:esc-timeout`, `var meta = $edit:esc-as-meta`)
+	testGlobals(t, f.Evaler, map[string]any{"timeout": 0.01, "meta": false})
+
+	evals(f.Evaler, `set edit:esc-timeout = 0.5`, `set edit:esc-as-meta = $true`,
+		`var timeout = $edit:esc-timeout`, `var meta = $edit:esc-as-meta`)
+	testGlobals(t, f.Evaler, map[string]any{"timeout": 0.5, "meta": true})
+	want := term.ReaderConfig{KeySeqTimeout: 500 * time.Millisecond, ESCAsMeta: true}
+	if cfg := term.GetReaderConfig(); cfg != want {
+		t.Errorf("got reader config %v, want %v", cfg, want)
+	}
+
+	evals(f.Evaler,
+		`var ok-timeout = ?(set edit:esc-timeout = -1)`,
+		`var ok-meta = ?(set edit:esc-as-meta = yes)`,
+		`var ok-timeout ok-meta = (bool $ok-timeout) (bool $ok-meta)`)
+	testGlobals(t, f.Evaler, map[string]any{"ok-timeout": false, "ok-meta": false})
+	if cfg := term.GetReaderConfig(); cfg != want {
+		t.Errorf("got reader config %v, want %v", cfg, want)
+	}
+}
+
+func TestWriterConfig(t *testing.T) {
+	t.Cleanup(func() { term.SetWriterConfig(term.WriterConfig{}) })
+	f := setup(t)
+
+	evals(f.Evaler, `var rate = $edit:max-redraw-rate`,
+		`var threshold = $edit:redraw-rate-threshold`)
+	testGlobals(t, f.Evaler, map[string]any{"rate": 0.0, "threshold": 0})
+
+	evals(f.Evaler, `set edit:max-redraw-rate = 10`,
+		`set edit:redraw-rate-threshold = 64`,
+		`var rate = $edit:max-redraw-rate`,
+		`var threshold = $edit:redraw-rate-threshold`)
+	testGlobals(t, f.Evaler, map[string]any{"rate": 10.0, "threshold": 64})
+	want := term.WriterConfig{MaxRedrawRate: 10, RateLimitThreshold: 64}
+	if cfg := term.GetWriterConfig(); cfg != want {
+		t.Errorf("got writer config %v, want %v", cfg, want)
+	}
+
+	evals(f.Evaler,
+		`var ok-rate = ?(set edit:max-redraw-rate = -1)`,
+		`var ok-threshold = ?(set edit:redraw-rate-threshold = 1.5)`,
+		`var ok-rate ok-threshold = (bool $ok-rate) (bool $ok-threshold)`)
+	testGlobals(t, f.Evaler, map[string]any{"ok-rate": false, "ok-threshold": false})
+	if cfg := term.GetWriterConfig(); cfg != want {
+		t.Errorf("got writer config %v, want %v", cfg, want)
+	}
+}
+
+func TestClipboardConfig(t *testing.T) {
+	t.Cleanup(func() { term.SetClipboardConfig(term.ClipboardConfig{}) })
+	f := setup(t)
+
+	evals(f.Evaler, `var copy = $edit:clipboard-copy-command`,
+		`var paste = $edit:clipboard-paste-command`)
+	testGlobals(t, f.Evaler, map[string]any{"copy": vals.EmptyList, "paste": vals.EmptyList})
+
+	evals(f.Evaler, `set edit:clipboard-copy-command = [xclip -selection clipboard]`,
+		`set edit:clipboard-paste-command = [xclip -selection clipboard -o]`,
+		`var copy = $edit:clipboard-copy-command`,
+		`var paste = $edit:clipboard-paste-command`)
+	testGlobals(t, f.Evaler, map[string]any{
+		"copy":  vals.MakeList("xclip", "-selection", "clipboard"),
+		"paste": vals.MakeList("xclip", "-selection", "clipboard", "-o"),
+	})
+	want := term.ClipboardConfig{
+		CopyCommand:  []string{"xclip", "-selection", "clipboard"},
+		PasteCommand: []string{"xclip", "-selection", "clipboard", "-o"},
+	}
+	if cfg := term.GetClipboardConfig(); !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got clipboard config %v, want %v", cfg, want)
+	}
+
+	evals(f.Evaler,
+		`var ok-copy = ?(set edit:clipboard-copy-command = xclip)`,
+		`var ok-paste = ?(set edit:clipboard-paste-command = [(num 1)])`,
+		`var ok-copy ok-paste = (bool $ok-copy) (bool $ok-paste)`)
+	testGlobals(t, f.Evaler, map[string]any{"ok-copy": false, "ok-paste": false})
+	if cfg := term.GetClipboardConfig(); !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got clipboard config %v, want %v", cfg, want)
+	}
+}
+
+func TestTheme(t *testing.T) {
+	t.Cleanup(func() { ui.SetTheme(nil) })
+	f := setup(t, rc(`set edit:theme[syntax-variable] = 'fg-blue'`))
+
+	feedInput(f.TTYCtrl, "put $true")
+	f.TestTTY(t,
+		"~> put $true", Styles,
+		"   vvv /////", term.DotHere,
+	)
+
+	evals(f.Evaler, `set edit:theme[syntax-command] = ''`, `var theme = $edit:theme`)
+	testGlobals(t, f.Evaler, map[string]any{
+		"theme": vals.MakeMap("syntax-variable", "fg-blue", "syntax-command", ""),
+	})
+	want := map[string]string{"syntax-variable": "fg-blue", "syntax-command": ""}
+	if theme := ui.Theme(); !reflect.DeepEqual(theme, want) {
+		t.Errorf("got theme %v, want %v", theme, want)
+	}
+
+	evals(f.Evaler,
+		`var ok-map = ?(set edit:theme = foo)`,
+		`var ok-role = ?(set edit:theme[bad-role] = red)`,
+		`var ok-styling = ?(set edit:theme[selected] = bad-styling)`,
+		`var ok-map ok-role ok-styling = (bool $ok-map) (bool $ok-role) (bool $ok-styling)`)
+	testGlobals(t, f.Evaler, map[string]any{
+		"ok-map": false, "ok-role": false, "ok-styling": false})
+}
+
 func TestAddCmdFilters(t *testing.T) {
 	cases := []struct {
 		name        string
@@ -133,6 +335,17 @@ func TestAddCmdFilters_SkipsRemainingOnFalse(t *testing.T) {
 	testGlobal(t, f.Evaler, "called", false)
 }
 
+func TestIncognito(t *testing.T) {
+	f := setup(t, rc(`set edit:incognito = $true`))
+
+	feedInput(f.TTYCtrl, "echo\n")
+	f.Wait()
+
+	testCommands(t, f.Store)
+	evals(f.Evaler, `var cmds = [(edit:command-history)]`)
+	testGlobal(t, f.Evaler, "cmds", vals.MakeList(vals.MakeMap("id", 0, "cmd", "echo")))
+}
+
 func TestGlobalBindings(t *testing.T) {
 	f := setup(t, rc(
 		`var called = $false`,
@@ -145,3 +358,72 @@ func TestGlobalBindings(t *testing.T) {
 
 	testGlobal(t, f.Evaler, "called", true)
 }
+
+func TestAfterChdir(t *testing.T) {
+	dir := testutil.TempDir(t)
+	testutil.InTempDir(t)
+	ev := eval.NewEvaler()
+	var spec cli.AppSpec
+	nb := eval.BuildNs()
+	initAfterChdir(&spec, ev, nb)
+	ev.ExtendGlobal(eval.BuildNs().AddNs("edit", nb.Ns()))
+	evals(ev, `var dirs = []`,
+		`set edit:after-chdir = [{|dir| set dirs = [$@dirs $dir] }]`)
+	beforeReadline := spec.BeforeReadline[0]
+
+	// Not called for the first readline cycle.
+	beforeReadline()
+	testGlobal(t, ev, "dirs", vals.EmptyList)
+
+	// Not called if the directory is unchanged, or changed temporarily.
+	evals(ev, `with-cd `+parse.Quote(dir)+` { }`)
+	beforeReadline()
+	testGlobal(t, ev, "dirs", vals.EmptyList)
+
+	// Called once after the directory has changed.
+	evals(ev, `cd `+parse.Quote(dir))
+	beforeReadline()
+	beforeReadline()
+	testGlobal(t, ev, "dirs", vals.MakeList(must.OK1(os.Getwd())))
+}
+
+func TestWhen(t *testing.T) {
+	testutil.Set(t, &whenHostname,
+		func() (string, error) { return "box.example.com", nil })
+	testutil.Set(t, &whenUsername, func() (string, error) { return "elf", nil })
+	testutil.Set(t, &whenOS, "linux")
+	testutil.Set(t, &whenArch, "amd64")
+
+	TestWithSetup(t, func(ev *eval.Evaler) {
+		nb := eval.BuildNs()
+		initConfigLayers(nb)
+		ev.ExtendGlobal(eval.BuildNs().AddNs("edit", nb))
+	},
+		That("edit:when &os=linux { put linux }").Puts("linux"),
+		That("edit:when &os=darwin { put darwin }").DoesNothing(),
+		// Host names match with or without the domain.
+		That("edit:when &host=box { put a }; edit:when &host=box.example.com { put b }").
+			Puts("a", "b"),
+		// All conditions must match.
+		That("edit:when &user=elf &arch=arm64 { put x }").DoesNothing(),
+		// A list matches if any element matches.
+		That("edit:when &user=[root elf] &os=[darwin linux] { put x }").Puts("x"),
+		// Applied layers are recorded.
+		That(
+			"edit:when &os=linux &host=[box other] { }",
+			"edit:when &os=darwin { }",
+			"edit:when &name=work &user=elf { }",
+			"edit:when { }",
+			"put $edit:config-layers",
+		).Puts(vals.MakeList("host=box,other os=linux", "work", "always")),
+		// Exceptions from the body are propagated.
+		That("edit:when { fail foo }").Throws(eval.FailError{Content: "foo"}),
+		// Bad conditions.
+		That("edit:when &os=[linux []] { }").Throws(errs.BadValue{
+			What: "&os option", Valid: "string or list of strings",
+			Actual: "[linux []]"}),
+		That("edit:when &host=(num 1) { }").Throws(errs.BadValue{
+			What: "&host option", Valid: "string or list of strings",
+			Actual: "(num 1)"}),
+	)
+}
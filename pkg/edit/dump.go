@@ -0,0 +1,98 @@
+package edit
+
+import (
+	"sort"
+	"strings"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/ui"
+)
+
+// ConfigVar describes the value of a variable in the edit: namespace. It is
+// used to dump the configuration of the editor.
+type ConfigVar struct {
+	// The qualified name of the variable, like "edit:max-height".
+	Name string
+	// The value of the variable as a string, or a map from key names to
+	// functions for binding tables, with functions described by
+	// DescribeFn.
+	Value any
+	// Whether the variable still has the value it had when the editor was
+	// created.
+	IsDefault bool
+}
+
+// ConfigVars returns all the variables in the edit: namespace and its
+// sub-namespaces, sorted by name. Variables whose names start with "-" and
+// function variables are not included.
+func (ed *Editor) ConfigVars() []ConfigVar {
+	var cvs []ConfigVar
+	for name, v := range nsValues(ed.ns, "edit:") {
+		cvs = append(cvs, ConfigVar{
+			Name:      name,
+			Value:     describeValue(v),
+			IsDefault: vals.Equal(v, ed.defaults[name]),
+		})
+	}
+	sort.Slice(cvs, func(i, j int) bool { return cvs[i].Name < cvs[j].Name })
+	return cvs
+}
+
+// BindingTables returns all the binding tables in the edit: namespace, keyed
+// by the names of the variables holding them, like "edit:insert:binding". Each
+// binding table is a map from key names to functions described by DescribeFn.
+func (ed *Editor) BindingTables() map[string]map[string]string {
+	tables := make(map[string]map[string]string)
+	for name, v := range nsValues(ed.ns, "edit:") {
+		if b, ok := v.(bindingsMap); ok {
+			tables[name] = describeBindings(b)
+		}
+	}
+	return tables
+}
+
+// DescribeFn returns the source code of closures, and the representation of
+// other values.
+func DescribeFn(v any) string {
+	if c, ok := v.(*eval.Closure); ok {
+		return c.SrcMeta.Code[c.DefRange.From:c.DefRange.To]
+	}
+	return vals.ReprPlain(v)
+}
+
+// Returns the values of all the variables in ns and its sub-namespaces, keyed
+// by their qualified names.
+func nsValues(ns *eval.Ns, prefix string) map[string]any {
+	values := make(map[string]any)
+	ns.IterateKeysString(func(name string) {
+		if strings.HasPrefix(name, "-") || strings.HasSuffix(name, eval.FnSuffix) {
+			return
+		}
+		v := ns.IndexString(name).Get()
+		if sub, ok := v.(*eval.Ns); ok && strings.HasSuffix(name, eval.NsSuffix) {
+			for subName, v := range nsValues(sub, prefix+name) {
+				values[subName] = v
+			}
+			return
+		}
+		values[prefix+name] = v
+	})
+	return values
+}
+
+func describeValue(v any) any {
+	if b, ok := v.(bindingsMap); ok {
+		return describeBindings(b)
+	}
+	return vals.ReprPlain(v)
+}
+
+func describeBindings(b bindingsMap) map[string]string {
+	m := make(map[string]string)
+	for it := b.Map.Iterator(); it.HasElem(); it.Next() {
+		k, fn := it.Elem()
+		m[k.(ui.Key).String()] = DescribeFn(fn)
+	}
+	return m
+}
@@ -34,9 +34,34 @@ type Editor struct {
 	// set in initHighlighter.
 	applyAutofix func()
 
+	// Values of the variables in the namespace when the editor was created,
+	// used by ConfigVars.
+	defaults map[string]any
+
+	// The $edit:incognito variable.
+	incognito vars.PtrVar
+
+	// Names of the modes on the addon stack and the last key that triggered a
+	// binding, used by edit:state.
+	stateMutex sync.Mutex
+	modeNames  []string
+	lastKey    any
+
+	// State of the vi layer of the command and visual modes.
+	vi viState
+
+	// The $edit:last-output-max-lines variable, and the output of the last
+	// command recorded with RecordOutput.
+	lastOutputMaxLines vars.PtrVar
+	lastOutputMutex    sync.Mutex
+	lastOutput         []string
+
 	// Maybe move this to another type that represents the REPL cycle as a whole, not just the
 	// read/edit portion represented by the Editor type.
 	AfterCommand []func(src parse.Source, duration float64, err error)
+	// Called after each redraw while reading code, except the final one, from
+	// the goroutine that redraws. Must be set before ReadCode is called.
+	AfterRedraw []func()
 }
 
 // DElvFiles embeds all the .d.elv files for this module.
@@ -44,12 +69,13 @@ type Editor struct {
 //go:embed *.d.elv
 var DElvFiles embed.FS
 
-// An interface that wraps notifyf and notifyError. It is only implemented by
+// An interface that wraps notifyf, notifyError and bell. It is only implemented by
 // the *Editor type; functions may take a notifier instead of *Editor argument
 // to make it clear that they do not depend on other parts of *Editor.
 type notifier interface {
 	notifyf(format string, args ...any)
 	notifyError(ctx string, e error)
+	bell()
 }
 
 // NewEditor creates a new editor. The TTY is used for input and output. The
@@ -63,39 +89,54 @@ func NewEditor(tty cli.TTY, ev *eval.Evaler, st storedefs.Store) *Editor {
 	nb := eval.BuildNsNamed("edit")
 	appSpec := cli.AppSpec{TTY: tty}
 
-	hs, err := newHistStore(st)
-	if err != nil {
-		_ = err // TODO(xiaq): Report the error.
-	}
+	initIncognito(ed, nb)
+	hs := newHistStore(st, ed.isIncognito)
 
 	initMaxHeight(&appSpec, nb)
+	initTabWidth(&appSpec, nb)
+	initBellStyle(&appSpec, nb)
+	initMouseTracking(&appSpec, nb)
+	initReaderConfig(nb)
+	initWriterConfig(nb)
+	initClipboardConfig(nb)
+	initTheme(nb)
 	initReadlineHooks(&appSpec, ev, nb)
 	initAddCmdFilters(&appSpec, ev, nb, hs)
 	initGlobalBindings(&appSpec, ed, ev, nb)
 	initInsertAPI(&appSpec, ed, ev, nb)
 	initHighlighter(&appSpec, ed, ev, nb)
-	initPrompts(&appSpec, ed, ev, nb)
+	initPrompts(&appSpec, ed, ed.isIncognito, ev, nb)
+	initModeIndicator(&appSpec, ed, nb)
+	appSpec.AfterRedraw = []func(){func() {
+		for _, f := range ed.AfterRedraw {
+			f()
+		}
+	}}
 	ed.app = cli.NewApp(appSpec)
 
 	initExceptionsAPI(ed, nb)
 	initVarsAPI(ed, nb)
-	initCommandAPI(ed, ev, nb)
+	initCommandAPI(ed, ev, tty, nb)
 	initListings(ed, ev, st, hs, nb)
 	initNavigation(ed, ev, nb)
 	initCompletion(ed, ev, nb)
 	initHistWalk(ed, ev, hs, nb)
 	initInstant(ed, ev, nb)
 	initMinibuf(ed, ev, nb)
+	initSelection(ed, ev, tty, nb)
+	initVi(ed, ev, tty, nb)
 
 	initRepl(ed, ev, nb)
 	initBufferBuiltins(ed.app, nb)
-	initTTYBuiltins(ed.app, tty, nb)
+	initTTYBuiltins(ed, tty, nb)
 	initMiscBuiltins(ed, nb)
-	initStateAPI(ed.app, nb)
+	initConfigLayers(nb)
+	initStateAPI(ed, nb)
 	initStoreAPI(ed.app, nb, hs)
 
 	ed.ns = nb.Ns()
 	initElvishState(ev, ed.ns)
+	ed.defaults = nsValues(ed.ns, "edit:")
 
 	return ed
 }
@@ -121,6 +162,12 @@ func (ed *Editor) ReadCode() (string, error) {
 	return ed.app.ReadCode()
 }
 
+// SetIncognito sets $edit:incognito, which controls whether commands and
+// directories are saved to the store.
+func (ed *Editor) SetIncognito(incognito bool) {
+	ed.incognito.Set(incognito)
+}
+
 // Notify adds a note to the notification buffer.
 func (ed *Editor) Notify(note ui.Text) {
 	ed.app.Notify(note)
@@ -144,6 +191,10 @@ func (ed *Editor) notifyf(format string, args ...any) {
 	ed.app.Notify(ui.T(fmt.Sprintf(format, args...)))
 }
 
+func (ed *Editor) bell() {
+	ed.app.Bell()
+}
+
 func (ed *Editor) notifyError(ctx string, e error) {
 	if exc, ok := e.(eval.Exception); ok {
 		ed.excMutex.Lock()
@@ -4,6 +4,7 @@ package highlight
 import (
 	"time"
 
+	"src.elv.sh/pkg/cli/clock"
 	"src.elv.sh/pkg/diag"
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/parse"
@@ -15,6 +16,16 @@ type Config struct {
 	Check      func(n parse.Tree) (string, error)
 	HasCommand func(name string) bool
 	AutofixTip func(autofix string) ui.Text
+	// The clock used for timing how long to wait for the late results of
+	// HasCommand. Default is clock.Real.
+	Clock clock.Clock
+}
+
+func (cfg Config) clock() clock.Clock {
+	if cfg.Clock == nil {
+		return clock.Real
+	}
+	return cfg.Clock
 }
 
 // Information collected about a command region, used for asynchronous
@@ -118,7 +129,7 @@ func highlight(code string, cfg Config, lateCb func(ui.Text)) (ui.Text, []ui.Tex
 		select {
 		case late := <-lateCh:
 			return late, tips
-		case <-time.After(maxBlockForLate):
+		case <-cfg.clock().After(maxBlockForLate):
 			go func() {
 				lateCb(<-lateCh)
 			}()
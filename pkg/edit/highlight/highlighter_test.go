@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"src.elv.sh/pkg/cli/clitest"
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/testutil"
@@ -123,6 +124,9 @@ type c struct {
 	wantInitial ui.Text
 	wantLate    ui.Text
 	mustLate    bool
+	// If not nil, a value is sent on it after the initial Get, to let the
+	// HasCommand callback return.
+	release chan<- struct{}
 }
 
 var lateTimeout = testutil.Scaled(100 * time.Millisecond)
@@ -132,6 +136,9 @@ func testThat(t *testing.T, hl *Highlighter, c c) {
 	if !reflect.DeepEqual(c.wantInitial, initial) {
 		t.Errorf("want %v from initial Get, got %v", c.wantInitial, initial)
 	}
+	if c.release != nil {
+		c.release <- struct{}{}
+	}
 	if c.wantLate == nil {
 		return
 	}
@@ -150,23 +157,32 @@ func testThat(t *testing.T, hl *Highlighter, c c) {
 func TestHighlighter_HasCommand_LateResult_Async(t *testing.T) {
 	// When the HasCommand callback takes longer than maxBlockForLate, late
 	// results are delivered asynchronously.
-	testutil.Set(t, &maxBlockForLate, testutil.Scaled(time.Millisecond))
+	clock := clitest.NewFakeClock()
+	release := make(chan struct{})
 	hl := NewHighlighter(Config{
-		// HasCommand is slow and only recognizes "ls".
+		// HasCommand only returns after the highlighter has stopped waiting
+		// for it, and only recognizes "ls".
 		HasCommand: func(cmd string) bool {
-			time.Sleep(testutil.Scaled(10 * time.Millisecond))
+			for clock.Timers() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			clock.Advance(maxBlockForLate)
+			<-release
 			return cmd == "ls"
-		}})
+		},
+		Clock: clock})
 
 	testThat(t, hl, c{
 		given:       "ls",
 		wantInitial: ui.T("ls"),
 		wantLate:    ui.T("ls", ui.FgGreen),
+		release:     release,
 	})
 	testThat(t, hl, c{
 		given:       "echo",
 		wantInitial: ui.T("echo"),
 		wantLate:    ui.T("echo", ui.FgRed),
+		release:     release,
 	})
 }
 
@@ -138,6 +138,8 @@ func emitRegionsInForm(n *parse.Form, f func(parse.Node, regionKind, string)) {
 		emitRegionsInFor(n, f)
 	case "try":
 		emitRegionsInTry(n, f)
+	case "match":
+		emitRegionsInMatch(n, f)
 	}
 	if isBarewordCompound(n.Head) {
 		f(n.Head, semanticRegion, commandRegion)
@@ -159,7 +161,21 @@ func emitVariableRegion(n *parse.Compound, f func(parse.Node, regionKind, string
 	// Only handle valid LHS here. Invalid LHS will result in a compile error
 	// and highlighted as an error accordingly.
 	if n != nil && len(n.Indexings) == 1 && n.Indexings[0].Head != nil {
-		f(n.Indexings[0].Head, semanticRegion, variableRegion)
+		head := n.Indexings[0].Head
+		switch head.Type {
+		case parse.List:
+			// A list pattern.
+			for _, elem := range head.Elements {
+				emitVariableRegion(elem, f)
+			}
+		case parse.Map:
+			// A map pattern; only the values are variables.
+			for _, pair := range head.MapPairs {
+				emitVariableRegion(pair.Value, f)
+			}
+		default:
+			f(head, semanticRegion, variableRegion)
+		}
 	}
 }
 
@@ -213,6 +229,28 @@ func emitRegionsInTry(n *parse.Form, f func(parse.Node, regionKind, string)) {
 	matchKW("finally")
 }
 
+func emitRegionsInMatch(n *parse.Form, f func(parse.Node, regionKind, string)) {
+	// Highlight the keyword of each arm, and the variables after "list" and
+	// "map".
+	for i := 1; i < len(n.Args); i++ {
+		kw := sourceText(n.Args[i])
+		switch kw {
+		case "is", "kind", "list", "map", "else":
+			f(n.Args[i], semanticRegion, keywordRegion)
+		default:
+			return
+		}
+		for i++; i < len(n.Args); i++ {
+			if _, ok := cmpd.Lambda(n.Args[i]); ok {
+				break
+			}
+			if kw == "list" || kw == "map" {
+				emitVariableRegion(n.Args[i], f)
+			}
+		}
+	}
+}
+
 func isStringLiteral(n *parse.Compound) bool {
 	_, ok := cmpd.StringLiteral(n)
 	return ok
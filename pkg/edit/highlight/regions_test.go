@@ -68,6 +68,19 @@ func TestGetRegions(t *testing.T) {
 			{8, 11, lexicalRegion, barewordRegion}, // foo
 		}),
 
+		Args("var [x @y] [&k=z]").Rets([]region{
+			{0, 3, semanticRegion, commandRegion}, // var
+			{4, 5, lexicalRegion, "["},
+			{5, 6, semanticRegion, variableRegion}, // x
+			{7, 9, semanticRegion, variableRegion}, // @y
+			{9, 10, lexicalRegion, "]"},
+			{11, 12, lexicalRegion, "["},
+			{12, 13, lexicalRegion, "&"},
+			{13, 14, lexicalRegion, barewordRegion}, // k
+			{14, 15, lexicalRegion, "="},
+			{15, 16, semanticRegion, variableRegion}, // z
+			{16, 17, lexicalRegion, "]"},
+		}),
 		// The "set" special command
 		Args("set x = foo").Rets([]region{
 			{0, 3, semanticRegion, commandRegion},  // var
@@ -159,6 +172,25 @@ func TestGetRegions(t *testing.T) {
 			{28, 29, lexicalRegion, "}"},
 		}),
 
+		// The "match" special command.
+
+		Args("match $x is a { } list y @z { } else { }").Rets([]region{
+			{0, 5, semanticRegion, commandRegion},   // match
+			{6, 8, lexicalRegion, variableRegion},   // $x
+			{9, 11, semanticRegion, keywordRegion},  // is
+			{12, 13, lexicalRegion, barewordRegion}, // a
+			{14, 15, lexicalRegion, "{"},
+			{16, 17, lexicalRegion, "}"},
+			{18, 22, semanticRegion, keywordRegion},  // list
+			{23, 24, semanticRegion, variableRegion}, // y
+			{25, 27, semanticRegion, variableRegion}, // @z
+			{28, 29, lexicalRegion, "{"},
+			{30, 31, lexicalRegion, "}"},
+			{32, 36, semanticRegion, keywordRegion}, // else
+			{37, 38, lexicalRegion, "{"},
+			{39, 40, lexicalRegion, "}"},
+		}),
+
 		// Regression test for b.elv.sh/1358.
 		Args("try { } except { }").Rets([]region{
 			{0, 3, semanticRegion, commandRegion}, // try
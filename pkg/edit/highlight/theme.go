@@ -4,36 +4,44 @@ import (
 	"src.elv.sh/pkg/ui"
 )
 
+var (
+	stylingForString      = ui.DefineRole("syntax-string", ui.FgYellow)
+	stylingForVariable    = ui.DefineRole("syntax-variable", ui.FgMagenta)
+	stylingForComment     = ui.DefineRole("syntax-comment", ui.FgCyan)
+	stylingForOperator    = ui.DefineRole("syntax-operator", ui.FgGreen)
+	stylingForPunctuation = ui.DefineRole("syntax-punctuation", ui.Bold)
+	stylingForKeyword     = ui.DefineRole("syntax-keyword", ui.FgYellow)
+	stylingForError       = ui.DefineRole("syntax-error", ui.Stylings(ui.FgBrightWhite, ui.BgRed))
+
+	stylingForGoodCommand = ui.DefineRole("syntax-command", ui.FgGreen)
+	stylingForBadCommand  = ui.DefineRole("syntax-bad-command", ui.FgRed)
+)
+
 var stylingFor = map[string]ui.Styling{
 	barewordRegion:     nil,
-	singleQuotedRegion: ui.FgYellow,
-	doubleQuotedRegion: ui.FgYellow,
-	variableRegion:     ui.FgMagenta,
+	singleQuotedRegion: stylingForString,
+	doubleQuotedRegion: stylingForString,
+	variableRegion:     stylingForVariable,
 	wildcardRegion:     nil,
 	tildeRegion:        nil,
 
-	commentRegion: ui.FgCyan,
+	commentRegion: stylingForComment,
 
-	">":  ui.FgGreen,
-	">>": ui.FgGreen,
-	"<":  ui.FgGreen,
-	"?>": ui.FgGreen,
-	"|":  ui.FgGreen,
-	"?(": ui.Bold,
-	"(":  ui.Bold,
-	")":  ui.Bold,
-	"[":  ui.Bold,
-	"]":  ui.Bold,
-	"{":  ui.Bold,
-	"}":  ui.Bold,
-	"&":  ui.Bold,
+	">":  stylingForOperator,
+	">>": stylingForOperator,
+	"<":  stylingForOperator,
+	"?>": stylingForOperator,
+	"|":  stylingForOperator,
+	"?(": stylingForPunctuation,
+	"(":  stylingForPunctuation,
+	")":  stylingForPunctuation,
+	"[":  stylingForPunctuation,
+	"]":  stylingForPunctuation,
+	"{":  stylingForPunctuation,
+	"}":  stylingForPunctuation,
+	"&":  stylingForPunctuation,
 
-	commandRegion: ui.FgGreen,
-	keywordRegion: ui.FgYellow,
-	errorRegion:   ui.Stylings(ui.FgBrightWhite, ui.BgRed),
+	commandRegion: stylingForGoodCommand,
+	keywordRegion: stylingForKeyword,
+	errorRegion:   stylingForError,
 }
-
-var (
-	stylingForGoodCommand = ui.FgGreen
-	stylingForBadCommand  = ui.FgRed
-)
@@ -2,6 +2,7 @@ package edit
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"src.elv.sh/pkg/cli/histutil"
 	"src.elv.sh/pkg/store/storedefs"
@@ -9,62 +10,115 @@ import (
 
 // A wrapper of histutil.Store that is concurrency-safe and supports an
 // additional FastForward method.
+//
+// Reads are served from an immutable snapshot, which writes replace
+// atomically. As a result, AllCmds, Cursor and the cursors it returns never
+// wait for AddCmd or FastForward, which are serialized among themselves. Each
+// cursor has a mutex of its own, since it can be used from multiple
+// goroutines.
+//
+// The database is first accessed when the history is first used, so that
+// creating the store doesn't wait for the daemon.
 type histStore struct {
-	m  sync.Mutex
+	m  sync.Mutex // Serializes writes
 	db storedefs.Store
-	hs histutil.Store
+	// If not nil and returns true, AddCmd only adds commands to the session.
+	incognito func() bool
+	snap      atomic.Value // Holds a *histSnapshot once initialized
+	initOnce  sync.Once
 }
 
-func newHistStore(db storedefs.Store) (*histStore, error) {
-	hs, err := histutil.NewHybridStore(db)
-	return &histStore{db: db, hs: hs}, err
+// An immutable view of the history.
+type histSnapshot struct {
+	// A view of the commands in the database when the store was created or
+	// last fast-forwarded; nil if there is no database or it could not be
+	// read.
+	shared histutil.Store
+	// Commands added in this session. Writes only ever append to it, so
+	// elements visible to a snapshot are never modified.
+	session []storedefs.Cmd
+}
+
+func newHistStore(db storedefs.Store, incognito func() bool) *histStore {
+	return &histStore{db: db, incognito: incognito}
 }
 
 func (s *histStore) AddCmd(cmd storedefs.Cmd) (int, error) {
+	s.init()
 	s.m.Lock()
 	defer s.m.Unlock()
-	return s.hs.AddCmd(cmd)
+	snap := s.snap.Load().(*histSnapshot)
+	seq, err := cmd.Seq, error(nil)
+	switch {
+	case s.incognito != nil && s.incognito():
+		// The command is not saved and doesn't get a sequence number.
+		seq = 0
+	case snap.shared != nil:
+		seq, err = snap.shared.AddCmd(cmd)
+	case seq < 0:
+		seq = len(snap.session) + 1
+	}
+	session := append(snap.session, storedefs.Cmd{Text: cmd.Text, Seq: seq})
+	s.snap.Store(&histSnapshot{snap.shared, session})
+	return seq, err
 }
 
 // AllCmds returns a slice of all interactive commands in oldest to newest order.
 func (s *histStore) AllCmds() ([]storedefs.Cmd, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
-	return s.hs.AllCmds()
+	return s.snapshot().AllCmds()
 }
 
 func (s *histStore) Cursor(prefix string) histutil.Cursor {
-	s.m.Lock()
-	defer s.m.Unlock()
-	return cursor{&s.m, histutil.NewDedupCursor(s.hs.Cursor(prefix))}
+	return &cursor{c: histutil.NewDedupCursor(s.snapshot().Cursor(prefix))}
 }
 
 func (s *histStore) FastForward() error {
 	s.m.Lock()
 	defer s.m.Unlock()
-	hs, err := histutil.NewHybridStore(s.db)
-	s.hs = hs
+	var shared histutil.Store
+	var err error
+	if s.db != nil {
+		shared, err = histutil.NewDBStore(s.db)
+	}
+	s.snap.Store(&histSnapshot{shared: shared})
 	return err
 }
 
+// Initializes the snapshot if neither AddCmd, snapshot nor FastForward has
+// been called yet.
+func (s *histStore) init() {
+	s.initOnce.Do(func() {
+		if s.snap.Load() == nil {
+			// TODO: Report the error.
+			_ = s.FastForward()
+		}
+	})
+}
+
+func (s *histStore) snapshot() histutil.Store {
+	s.init()
+	snap := s.snap.Load().(*histSnapshot)
+	return histutil.NewHybridStoreWith(snap.shared, snap.session)
+}
+
 type cursor struct {
-	m *sync.Mutex
+	m sync.Mutex
 	c histutil.Cursor
 }
 
-func (c cursor) Prev() {
+func (c *cursor) Prev() {
 	c.m.Lock()
 	defer c.m.Unlock()
 	c.c.Prev()
 }
 
-func (c cursor) Next() {
+func (c *cursor) Next() {
 	c.m.Lock()
 	defer c.m.Unlock()
 	c.c.Next()
 }
 
-func (c cursor) Get() (storedefs.Cmd, error) {
+func (c *cursor) Get() (storedefs.Cmd, error) {
 	c.m.Lock()
 	defer c.m.Unlock()
 	return c.c.Get()
@@ -0,0 +1,66 @@
+package edit
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+func TestHistStore_SnapshotIsolation(t *testing.T) {
+	st := store.MustTempStore(t)
+	st.AddCmd("echo shared")
+	hs := newHistStore(st, nil)
+	hs.AddCmd(storedefs.Cmd{Text: "echo session 1"})
+
+	// A cursor keeps walking the history as it was when it was created.
+	c := hs.Cursor("echo")
+	hs.AddCmd(storedefs.Cmd{Text: "echo session 2"})
+	c.Prev()
+	if cmd, _ := c.Get(); cmd.Text != "echo session 1" {
+		t.Errorf("got %q, want %q", cmd.Text, "echo session 1")
+	}
+
+	wantCmds := []storedefs.Cmd{
+		{Text: "echo shared", Seq: 1},
+		{Text: "echo session 1", Seq: 2},
+		{Text: "echo session 2", Seq: 3}}
+	if cmds, _ := hs.AllCmds(); !reflect.DeepEqual(cmds, wantCmds) {
+		t.Errorf("got %v, want %v", cmds, wantCmds)
+	}
+
+	// FastForward picks up commands added to the database by other sessions.
+	st.AddCmd("echo other session")
+	hs.FastForward()
+	wantCmds = append(wantCmds, storedefs.Cmd{Text: "echo other session", Seq: 4})
+	if cmds, _ := hs.AllCmds(); !reflect.DeepEqual(cmds, wantCmds) {
+		t.Errorf("got %v, want %v", cmds, wantCmds)
+	}
+}
+
+func TestHistStore_ConcurrentAccess(t *testing.T) {
+	hs := newHistStore(nil, nil)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			hs.AddCmd(storedefs.Cmd{Text: "echo", Seq: -1})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			hs.AllCmds()
+			c := hs.Cursor("")
+			c.Prev()
+			c.Get()
+		}
+	}()
+	wg.Wait()
+	if cmds, _ := hs.AllCmds(); len(cmds) != 100 {
+		t.Errorf("got %d commands, want 100", len(cmds))
+	}
+}
@@ -18,7 +18,7 @@ func initHistWalk(ed *Editor, ev *eval.Evaler, hs *histStore, nb eval.NsBuilder)
 		eval.BuildNsNamed("edit:history").
 			AddVar("binding", bindingVar).
 			AddGoFns(map[string]any{
-				"start": func() { notifyError(app, histwalkStart(app, hs, bindings)) },
+				"start": func() { notifyError(app, histwalkStart(ed, hs, bindings)) },
 				"up":    func() { notifyError(app, histwalkDo(app, modes.Histwalk.Prev)) },
 
 				"down": func() { notifyError(app, histwalkDo(app, modes.Histwalk.Next)) },
@@ -35,7 +35,8 @@ func initHistWalk(ed *Editor, ev *eval.Evaler, hs *histStore, nb eval.NsBuilder)
 			}))
 }
 
-func histwalkStart(app cli.App, hs *histStore, bindings tk.Bindings) error {
+func histwalkStart(ed *Editor, hs *histStore, bindings tk.Bindings) error {
+	app := ed.app
 	codeArea, ok := focusedCodeArea(app)
 	if !ok {
 		return nil
@@ -44,7 +45,7 @@ func histwalkStart(app cli.App, hs *histStore, bindings tk.Bindings) error {
 	w, err := modes.NewHistwalk(app, modes.HistwalkSpec{
 		Bindings: bindings, Store: hs, Prefix: buf.Content[:buf.Dot]})
 	if w != nil {
-		app.PushAddon(w)
+		ed.pushMode("histwalk", w)
 	}
 	return err
 }
@@ -62,5 +63,6 @@ func histwalkDo(app cli.App, f func(modes.Histwalk) error) error {
 func notifyError(app cli.App, err error) {
 	if err != nil {
 		app.Notify(modes.ErrorText(err))
+		app.Bell()
 	}
 }
@@ -0,0 +1,106 @@
+package edit
+
+import (
+	"strings"
+
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/parse/parseutil"
+)
+
+// The unit of indentation for each level of nesting.
+const indentUnit = "  "
+
+// Returns the indentation to write after a newline inserted at dot, based on
+// the parse tree of code:
+//
+//   - After a pipe, it is one level deeper than the line the pipeline starts
+//     on.
+//
+//   - Inside a bracketed construct like a lambda or a list, it is one level
+//     deeper than the line of the innermost unclosed opening bracket.
+//
+//   - Inside a string literal, it is empty, since any whitespace would become
+//     part of the string.
+//
+//   - Otherwise it is empty.
+func autoIndent(code string, dot int) string {
+	tree, err := parse.Parse(parse.Source{Name: "[indent]", Code: code}, parse.Config{})
+	if inStringLiteral(tree.Root, unterminatedString(err), dot) {
+		return ""
+	}
+	if before := strings.TrimRight(code[:dot], " \t"); strings.HasSuffix(before, "|") {
+		if pn := parseutil.PipelineAt(tree.Root, len(before)-1); pn != nil {
+			return lineIndent(code, pn.Range().From) + indentUnit
+		}
+	}
+	if pn := parseutil.OpenBracketAt(tree.Root, dot); pn != nil {
+		return lineIndent(code, pn.Range().From) + indentUnit
+	}
+	return ""
+}
+
+// Re-indents the line of the dot after r has been typed just before the dot,
+// if r is a closing bracket preceded only by whitespace on its line. Such a
+// line is indented to the same level as the line of the matching opening
+// bracket.
+func reindentCloser(buf tk.CodeBuffer, r rune) tk.CodeBuffer {
+	if r != ')' && r != ']' && r != '}' {
+		return buf
+	}
+	closer := buf.Dot - 1
+	if closer < 0 || buf.Content[closer] != byte(r) {
+		return buf
+	}
+	lineStart := strings.LastIndexByte(buf.Content[:closer], '\n') + 1
+	if strings.Trim(buf.Content[lineStart:closer], " \t") != "" {
+		return buf
+	}
+	tree, _ := parse.Parse(parse.Source{Name: "[indent]", Code: buf.Content}, parse.Config{})
+	pn := parseutil.BracketClosedAt(tree.Root, closer)
+	if pn == nil {
+		return buf
+	}
+	indent := lineIndent(buf.Content, pn.Range().From)
+	return tk.CodeBuffer{
+		Content: buf.Content[:lineStart] + indent + buf.Content[closer:],
+		Dot:     lineStart + len(indent) + 1,
+	}
+}
+
+// Returns the leading whitespace of the line that contains pos.
+func lineIndent(code string, pos int) string {
+	lineStart := strings.LastIndexByte(code[:pos], '\n') + 1
+	line := code[lineStart:]
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// Returns whether the parse error contains an unterminated string.
+func unterminatedString(err error) bool {
+	for _, e := range parse.UnpackErrors(err) {
+		if e.Message == "string not terminated" {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether dot is between the quotes of a string literal in the tree
+// rooted at n. An unterminated string always extends to the end of the code.
+func inStringLiteral(n parse.Node, unterminated bool, dot int) bool {
+descend:
+	for {
+		if pn, ok := n.(*parse.Primary); ok &&
+			(pn.Type == parse.SingleQuoted || pn.Type == parse.DoubleQuoted) {
+			rg := pn.Range()
+			return rg.From < dot && (dot < rg.To || unterminated)
+		}
+		for _, ch := range parse.Children(n) {
+			if rg := ch.Range(); rg.From <= dot && dot <= rg.To {
+				n = ch
+				continue descend
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,88 @@
+package edit
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/cli/tk"
+)
+
+var autoIndentTests = []struct {
+	name string
+	code string
+	dot  int
+	want string
+}{
+	{"top level", "echo foo", 8, ""},
+	{"list", "echo [", 6, "  "},
+	{"lambda", "fn f {", 6, "  "},
+	{"output capture", "echo (", 6, "  "},
+	{"closed bracket", "echo [a]", 8, ""},
+	{"in closed bracket", "echo [a]", 7, "  "},
+	{"nested", "fn f {\n  echo [", 15, "    "},
+	{"bracket line indentation", "  fn f {", 8, "    "},
+	{"tab", "fn f {\n\techo [", 14, "\t  "},
+	{"innermost bracket", "echo [{", 7, "  "},
+	{"after closed inner bracket", "fn f {\n  echo [a]", 17, "  "},
+	{"pipe", "echo foo |", 10, "  "},
+	{"pipe with trailing space", "echo foo | ", 11, "  "},
+	{"pipe in lambda", "fn f {\n  echo foo |", 19, "    "},
+	{"continued pipe", "echo foo |\n  str:to-upper |", 27, "  "},
+	{"unterminated single-quoted string", "  echo 'foo", 11, ""},
+	{"unterminated double-quoted string", "  echo \"foo", 11, ""},
+	{"inside terminated string", "echo ['foo' [", 8, ""},
+	{"after terminated string", "echo ['foo' [", 13, "  "},
+}
+
+func TestAutoIndent(t *testing.T) {
+	for _, test := range autoIndentTests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := autoIndent(test.code, test.dot); got != test.want {
+				t.Errorf("autoIndent(%q, %d) -> %q, want %q",
+					test.code, test.dot, got, test.want)
+			}
+		})
+	}
+}
+
+var reindentCloserTests = []struct {
+	name string
+	buf  tk.CodeBuffer
+	r    rune
+	want tk.CodeBuffer
+}{
+	{"lambda",
+		tk.CodeBuffer{Content: "fn f {\n  }", Dot: 10}, '}',
+		tk.CodeBuffer{Content: "fn f {\n}", Dot: 8}},
+	{"nested list",
+		tk.CodeBuffer{Content: "fn f {\n  put [\n    ]", Dot: 20}, ']',
+		tk.CodeBuffer{Content: "fn f {\n  put [\n  ]", Dot: 18}},
+	{"indents to opener line",
+		tk.CodeBuffer{Content: "  fn f {\n}", Dot: 10}, '}',
+		tk.CodeBuffer{Content: "  fn f {\n  }", Dot: 12}},
+	{"code after dot",
+		tk.CodeBuffer{Content: "echo (\n    ) foo", Dot: 12}, ')',
+		tk.CodeBuffer{Content: "echo (\n) foo", Dot: 8}},
+	{"not a closing bracket",
+		tk.CodeBuffer{Content: "fn f {\n  x", Dot: 10}, 'x',
+		tk.CodeBuffer{Content: "fn f {\n  x", Dot: 10}},
+	{"not at start of line",
+		tk.CodeBuffer{Content: "fn f {\n  put [a]", Dot: 16}, ']',
+		tk.CodeBuffer{Content: "fn f {\n  put [a]", Dot: 16}},
+	{"unmatched",
+		tk.CodeBuffer{Content: "  }", Dot: 3}, '}',
+		tk.CodeBuffer{Content: "  }", Dot: 3}},
+	{"in string",
+		tk.CodeBuffer{Content: "echo 'foo\n  ]", Dot: 13}, ']',
+		tk.CodeBuffer{Content: "echo 'foo\n  ]", Dot: 13}},
+}
+
+func TestReindentCloser(t *testing.T) {
+	for _, test := range reindentCloserTests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := reindentCloser(test.buf, test.r); got != test.want {
+				t.Errorf("reindentCloser(%v, %q) -> %v, want %v",
+					test.buf, test.r, got, test.want)
+			}
+		})
+	}
+}
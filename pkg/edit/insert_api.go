@@ -26,6 +26,8 @@ func initInsertAPI(appSpec *cli.AppSpec, nt notifier, ev *eval.Evaler, nb eval.N
 	quotePaste := newBoolVar(false)
 	appSpec.QuotePaste = func() bool { return quotePaste.GetRaw().(bool) }
 
+	appSpec.Reindent = reindentCloser
+
 	toggleQuotePaste := func() {
 		quotePaste.Set(!quotePaste.Get().(bool))
 	}
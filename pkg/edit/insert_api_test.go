@@ -39,11 +39,7 @@ func TestInsert_QuotePaste(t *testing.T) {
 
 	evals(f.Evaler, `set edit:insert:quote-paste = $true`)
 
-	f.TTYCtrl.Inject(
-		term.PasteSetting(true),
-		term.K('>'),
-		term.PasteSetting(false),
-		term.K('\n'))
+	f.TTYCtrl.Inject(term.PasteEvent(">"), term.K('\n'))
 
 	wantCode := `'>'`
 	if code := <-f.codeCh; code != wantCode {
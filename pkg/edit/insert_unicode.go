@@ -0,0 +1,168 @@
+package edit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"src.elv.sh/pkg/cli/modes"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/ui"
+	"src.elv.sh/pkg/unicodenames"
+)
+
+func initInsertUnicode(ed *Editor, ev *eval.Evaler, commonBindingVar vars.PtrVar, nb eval.NsBuilder) {
+	bindingVar := newBindingVar(emptyBindingsMap)
+	bindings := newMapBindings(ed, ev, bindingVar, commonBindingVar)
+	nb.AddNs("insert-unicode",
+		eval.BuildNsNamed("edit:insert-unicode").
+			AddVar("binding", bindingVar).
+			AddGoFn("start", func() { insertUnicodeStart(ed, bindings) }))
+}
+
+func insertUnicodeStart(ed *Editor, bindings tk.Bindings) {
+	codeArea, err := modes.FocusedCodeArea(ed.app)
+	if err != nil {
+		ed.startMode("insert-unicode", nil, err)
+		return
+	}
+	w, err := modes.NewListing(ed.app, modes.ListingSpec{
+		Bindings: bindings,
+		Caption:  " UNICODE ",
+		GetItems: func(q string) ([]modes.ListingItem, int) {
+			return unicodeItems(q), 0
+		},
+		Accept: func(s string) {
+			codeArea.MutateState(func(s2 *tk.CodeAreaState) {
+				s2.Buffer.InsertAtDot(s)
+			})
+		},
+	})
+	ed.startMode("insert-unicode", w, err)
+}
+
+// Returns the listing items for the characters matching the query: the one
+// with the code point if the query is a hex number, optionally prefixed with
+// "U+", the one of the digraph if the query is one, and those with matching
+// names.
+func unicodeItems(q string) []modes.ListingItem {
+	var rs []rune
+	seen := make(map[rune]bool)
+	add := func(r rune) {
+		if !seen[r] {
+			seen[r] = true
+			rs = append(rs, r)
+		}
+	}
+	if r, ok := parseCodePoint(q); ok {
+		add(r)
+	}
+	if r, ok := digraph(q); ok {
+		add(r)
+	}
+	for _, r := range unicodenames.Search(q) {
+		add(r)
+	}
+
+	items := make([]modes.ListingItem, len(rs))
+	for i, r := range rs {
+		items[i] = modes.ListingItem{
+			ToAccept: string(r),
+			ToShow: ui.T(fmt.Sprintf("U+%04X %s %s",
+				r, showRune(r), unicodenames.Name(r)))}
+	}
+	return items
+}
+
+func parseCodePoint(s string) (rune, bool) {
+	if len(s) > 2 && (s[:2] == "U+" || s[:2] == "u+") {
+		s = s[2:]
+	}
+	if s == "" || len(s) > 6 {
+		return 0, false
+	}
+	i, err := strconv.ParseUint(s, 16, 32)
+	r := rune(i)
+	if err != nil || !utf8.ValidRune(r) || !unicode.In(r, assignedCategories...) {
+		return 0, false
+	}
+	return r, true
+}
+
+var assignedCategories = []*unicode.RangeTable{
+	unicode.L, unicode.M, unicode.N, unicode.P, unicode.S, unicode.Z,
+	unicode.Cc, unicode.Cf, unicode.Co}
+
+// Returns how a character is shown in the listing. Combining marks are shown
+// on a dotted circle, and characters that are not graphic as a space.
+func showRune(r rune) string {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return "◌" + string(r)
+	case unicode.IsGraphic(r) && !unicode.IsSpace(r):
+		return string(r)
+	default:
+		return " "
+	}
+}
+
+// Digraphs in the style of RFC 1345, as used by Vim: a Latin letter followed
+// by a mark for a letter with a diacritic, a Latin letter followed by "*" for
+// a Greek letter, and some symbols.
+var (
+	digraphMarks = map[byte]string{
+		'\'': "ACUTE", '!': "GRAVE", '>': "CIRCUMFLEX", ':': "DIAERESIS",
+		'?': "TILDE", ',': "CEDILLA", '<': "CARON", '0': "RING ABOVE",
+		'-': "MACRON", '(': "BREVE", '.': "DOT ABOVE", ';': "OGONEK",
+		'/': "STROKE",
+	}
+	greekDigraphs = map[byte]string{
+		'A': "ALPHA", 'B': "BETA", 'G': "GAMMA", 'D': "DELTA", 'E': "EPSILON",
+		'Z': "ZETA", 'Y': "ETA", 'H': "THETA", 'I': "IOTA", 'K': "KAPPA",
+		'L': "LAMDA", 'M': "MU", 'N': "NU", 'C': "XI", 'O': "OMICRON",
+		'P': "PI", 'R': "RHO", 'S': "SIGMA", 'T': "TAU", 'U': "UPSILON",
+		'F': "PHI", 'X': "CHI", 'Q': "PSI", 'W': "OMEGA",
+	}
+	symbolDigraphs = map[string]rune{
+		"NS": '\u00a0', "!I": '¡', "?I": '¿', "Ct": '¢', "Pd": '£', "Eu": '€',
+		"Ye": '¥', "SE": '§', "PI": '¶', "Co": '©', "Rg": '®', "TM": '™',
+		"DG": '°', "+-": '±', "*X": '×', "-:": '÷', "<<": '«', ">>": '»',
+		"ss": 'ß', "ae": 'æ', "AE": 'Æ', "oe": 'œ', "OE": 'Œ',
+		"-N": '–', "-M": '—', "'6": '‘', "'9": '’', "\"6": '“', "\"9": '”',
+		",.": '…', "<-": '←', "->": '→', "-!": '↑', "-v": '↓', "=>": '⇒',
+		"!=": '≠', "=<": '≤', ">=": '≥', "00": '∞', "OK": '✓', "XX": '✗',
+	}
+)
+
+func digraph(s string) (rune, bool) {
+	if r, ok := symbolDigraphs[s]; ok {
+		return r, true
+	}
+	if len(s) != 2 {
+		return 0, false
+	}
+	letter, mark := s[0], s[1]
+	var letterCase string
+	switch {
+	case 'a' <= letter && letter <= 'z':
+		letterCase = "SMALL"
+	case 'A' <= letter && letter <= 'Z':
+		letterCase = "CAPITAL"
+	default:
+		return 0, false
+	}
+	upper := strings.ToUpper(s[:1])
+	if mark == '*' {
+		if greek, ok := greekDigraphs[upper[0]]; ok {
+			return unicodenames.Lookup("GREEK " + letterCase + " LETTER " + greek)
+		}
+	} else if diacritic, ok := digraphMarks[mark]; ok {
+		return unicodenames.Lookup(
+			"LATIN " + letterCase + " LETTER " + upper + " WITH " + diacritic)
+	}
+	return 0, false
+}
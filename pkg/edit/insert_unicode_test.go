@@ -0,0 +1,56 @@
+package edit
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/tt"
+)
+
+func TestParseCodePoint(t *testing.T) {
+	tt.Test(t, tt.Fn("parseCodePoint", parseCodePoint), tt.Table{
+		Args("e9").Rets('é', true),
+		Args("U+00E9").Rets('é', true),
+		Args("u+1f600").Rets('😀', true),
+		Args("U+").Rets(rune(0), false),
+		Args("xyz").Rets(rune(0), false),
+		// Surrogates, unassigned code points and those beyond U+10FFFF.
+		Args("d800").Rets(rune(0), false),
+		Args("378").Rets(rune(0), false),
+		Args("110000").Rets(rune(0), false),
+	})
+}
+
+func TestDigraph(t *testing.T) {
+	tt.Test(t, tt.Fn("digraph", digraph), tt.Table{
+		Args("e'").Rets('é', true),
+		Args("A!").Rets('À', true),
+		Args("n?").Rets('ñ', true),
+		Args("c,").Rets('ç', true),
+		Args("o/").Rets('ø', true),
+		Args("w*").Rets('ω', true),
+		Args("L*").Rets('Λ', true),
+		Args("Eu").Rets('€', true),
+		Args("->").Rets('→', true),
+		// No such letters.
+		Args("q'").Rets(rune(0), false),
+		Args("J*").Rets(rune(0), false),
+		Args("1'").Rets(rune(0), false),
+		Args("e").Rets(rune(0), false),
+	})
+}
+
+func TestUnicodeItems(t *testing.T) {
+	// The character with the code point comes first, and the same character
+	// isn't listed again.
+	items := unicodeItems("b5")
+	if len(items) != 1 || items[0].ToAccept != "µ" {
+		t.Errorf("got %v, want µ only", items)
+	}
+	items = unicodeItems("acute combining")
+	if len(items) == 0 || plainText(items[0].ToShow) != "U+0301 ◌́ COMBINING ACUTE ACCENT" {
+		t.Errorf("got %v, want combining acute accent first", items)
+	}
+	if items := unicodeItems(""); len(items) != 0 {
+		t.Errorf("got %v, want none", items)
+	}
+}
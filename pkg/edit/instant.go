@@ -1,7 +1,6 @@
 package edit
 
 import (
-	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/modes"
 	"src.elv.sh/pkg/cli/tk"
 	"src.elv.sh/pkg/eval"
@@ -15,11 +14,12 @@ func initInstant(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 		eval.BuildNsNamed("edit:-instant").
 			AddVar("binding", bindingVar).
 			AddGoFns(map[string]any{
-				"start": func() { instantStart(ed.app, ev, bindings) },
+				"start": func() { instantStart(ed, ev, bindings) },
 			}))
 }
 
-func instantStart(app cli.App, ev *eval.Evaler, bindings tk.Bindings) {
+func instantStart(ed *Editor, ev *eval.Evaler, bindings tk.Bindings) {
+	app := ed.app
 	execute := func(code string) ([]string, error) {
 		outPort, collect, err := eval.StringCapturePort()
 		if err != nil {
@@ -35,7 +35,7 @@ func instantStart(app cli.App, ev *eval.Evaler, bindings tk.Bindings) {
 	w, err := modes.NewInstant(app,
 		modes.InstantSpec{Bindings: bindings, Execute: execute})
 	if w != nil {
-		app.PushAddon(w)
+		ed.pushMode("instant", w)
 		app.Redraw()
 	}
 	if err != nil {
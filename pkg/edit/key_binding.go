@@ -21,6 +21,12 @@ type mapBindings struct {
 	mapVars []vars.PtrVar
 }
 
+// An interface for recording the key that triggered a binding. It is
+// implemented by *Editor.
+type keyRecorder interface {
+	setLastKey(k ui.Key)
+}
+
 func newMapBindings(nt notifier, ev *eval.Evaler, mapVars ...vars.PtrVar) tk.Bindings {
 	return mapBindings{nt, ev, mapVars}
 }
@@ -38,6 +44,9 @@ func (b mapBindings) Handle(w tk.Widget, e term.Event) bool {
 	if f == nil {
 		return false
 	}
+	if r, ok := b.nt.(keyRecorder); ok {
+		r.setLastKey(ui.Key(k))
+	}
 	callWithNotifyPorts(b.nt, b.ev, f)
 	return true
 }
@@ -69,6 +78,7 @@ func callWithNotifyPorts(nt notifier, ev *eval.Evaler, f eval.Callable, args ...
 		eval.EvalCfg{Ports: []*eval.Port{nil, notifyPort, notifyPort}})
 	if err != nil {
 		nt.notifyError("binding", err)
+		nt.bell()
 	}
 }
 
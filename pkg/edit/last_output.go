@@ -0,0 +1,155 @@
+package edit
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+
+	"src.elv.sh/pkg/cli/modes"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/strutil"
+	"src.elv.sh/pkg/ui"
+)
+
+func initLastOutput(ed *Editor, ev *eval.Evaler, commonBindingVar vars.PtrVar, nb eval.NsBuilder) {
+	ed.lastOutputMaxLines = newIntVar(0)
+	nb.AddVar("last-output-max-lines", ed.lastOutputMaxLines)
+
+	bindingVar := newBindingVar(emptyBindingsMap)
+	bindings := newMapBindings(ed, ev, bindingVar, commonBindingVar)
+	nb.AddGoFn("last-output", func(fm *eval.Frame) error {
+		out := fm.ByteOutput()
+		for _, line := range ed.getLastOutput() {
+			if _, err := out.WriteString(line + "\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	nb.AddNs("last-output",
+		eval.BuildNsNamed("edit:last-output").
+			AddVar("binding", bindingVar).
+			AddGoFn("start", func() { lastOutputStart(ed, bindings) }))
+}
+
+func lastOutputStart(ed *Editor, bindings tk.Bindings) {
+	codeArea, err := modes.FocusedCodeArea(ed.app)
+	if err != nil {
+		ed.startMode("last-output", nil, err)
+		return
+	}
+	lines := ed.getLastOutput()
+	items := make([]modes.ListingItem, len(lines))
+	for i, line := range lines {
+		text := ui.ParseSGREscapedText(line)
+		items[i] = modes.ListingItem{ToAccept: plainText(text), ToShow: text}
+	}
+	w, err := modes.NewListing(ed.app, modes.ListingSpec{
+		Bindings: bindings,
+		Caption:  " LAST OUTPUT ",
+		GetItems: func(q string) ([]modes.ListingItem, int) {
+			var filtered []modes.ListingItem
+			for _, item := range items {
+				if strings.Contains(item.ToAccept, q) {
+					filtered = append(filtered, item)
+				}
+			}
+			// The end of the output is usually the most interesting part.
+			return filtered, len(filtered) - 1
+		},
+		Accept: func(s string) {
+			codeArea.MutateState(func(s2 *tk.CodeAreaState) {
+				s2.Buffer.InsertAtDot(s)
+			})
+		},
+	})
+	ed.startMode("last-output", w, err)
+}
+
+func (ed *Editor) getLastOutput() []string {
+	ed.lastOutputMutex.Lock()
+	defer ed.lastOutputMutex.Unlock()
+	return ed.lastOutput
+}
+
+func plainText(t ui.Text) string {
+	var sb strings.Builder
+	for _, seg := range t {
+		sb.WriteString(seg.Text)
+	}
+	return sb.String()
+}
+
+// RecordOutput returns a writer that records the output of a command, so that
+// it can be browsed with edit:last-output:start after the command finishes.
+// Only the last $edit:last-output-max-lines lines are kept; if that is 0 (the
+// default), RecordOutput returns nil.
+//
+// The recorded output replaces the one of the previous command when the
+// writer is closed. Writes after that are ignored.
+func (ed *Editor) RecordOutput() io.WriteCloser {
+	maxLines := ed.lastOutputMaxLines.Get().(int)
+	if maxLines <= 0 {
+		return nil
+	}
+	return &outputRecorder{ed: ed, maxLines: maxLines}
+}
+
+type outputRecorder struct {
+	ed       *Editor
+	maxLines int
+
+	mutex   sync.Mutex
+	closed  bool
+	lines   []string
+	partial []byte
+}
+
+func (r *outputRecorder) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return len(p), nil
+	}
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i == -1 {
+			r.partial = append(r.partial, p...)
+			break
+		}
+		line := string(append(r.partial, p[:i+1]...))
+		r.lines = append(r.lines, strutil.ChopLineEnding(line))
+		r.partial = r.partial[:0]
+		p = p[i+1:]
+	}
+	// Trim the lines occasionally rather than on each write, so that the cost
+	// of copying is amortized.
+	if len(r.lines) > 2*r.maxLines {
+		r.lines = append([]string(nil), r.lines[len(r.lines)-r.maxLines:]...)
+	}
+	return n, nil
+}
+
+func (r *outputRecorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	lines := r.lines
+	if len(r.partial) > 0 {
+		lines = append(lines, string(r.partial))
+	}
+	if len(lines) > r.maxLines {
+		lines = lines[len(lines)-r.maxLines:]
+	}
+	r.ed.lastOutputMutex.Lock()
+	r.ed.lastOutput = lines
+	r.ed.lastOutputMutex.Unlock()
+	return nil
+}
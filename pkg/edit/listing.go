@@ -2,6 +2,7 @@ package edit
 
 import (
 	"os"
+	"path/filepath"
 
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/histutil"
@@ -37,6 +38,8 @@ func initListings(ed *Editor, ev *eval.Evaler, st storedefs.Store, histStore his
 	initHistlist(ed, ev, histStore, bindingVar, nb)
 	initLastcmd(ed, ev, histStore, bindingVar, nb)
 	initLocation(ed, ev, st, bindingVar, nb)
+	initLastOutput(ed, ev, bindingVar, nb)
+	initInsertUnicode(ed, ev, bindingVar, nb)
 }
 
 var filterSpec = modes.FilterSpec{
@@ -54,8 +57,10 @@ func initHistlist(ed *Editor, ev *eval.Evaler, histStore histutil.Store, commonB
 	bindingVar := newBindingVar(emptyBindingsMap)
 	bindings := newMapBindings(ed, ev, bindingVar, commonBindingVar)
 	dedup := newBoolVar(true)
+	altScreenVar := newBoolVar(false)
 	ns := eval.BuildNsNamed("edit:histlist").
 		AddVar("binding", bindingVar).
+		AddVar("alt-screen", altScreenVar).
 		AddGoFns(map[string]any{
 			"start": func() {
 				w, err := modes.NewHistlist(ed.app, modes.HistlistSpec{
@@ -69,8 +74,9 @@ func initHistlist(ed *Editor, ev *eval.Evaler, histStore histutil.Store, commonB
 						return bindingTips(ed.ns, "histlist:binding",
 							bindingTip("dedup", "histlist:toggle-dedup"))
 					},
+					AltScreen: func() bool { return altScreenVar.Get().(bool) },
 				})
-				startMode(ed.app, w, err)
+				ed.startMode("histlist", w, err)
 			},
 			"toggle-dedup": func() {
 				dedup.Set(!dedup.Get().(bool))
@@ -91,7 +97,7 @@ func initLastcmd(ed *Editor, ev *eval.Evaler, histStore histutil.Store, commonBi
 				// TODO: Specify wordifier
 				w, err := modes.NewLastcmd(ed.app, modes.LastcmdSpec{
 					Bindings: bindings, Store: histStore})
-				startMode(ed.app, w, err)
+				ed.startMode("lastcmd", w, err)
 			}))
 }
 
@@ -100,6 +106,9 @@ func initLocation(ed *Editor, ev *eval.Evaler, st storedefs.Store, commonBinding
 	pinnedVar := newListVar(vals.EmptyList)
 	hiddenVar := newListVar(vals.EmptyList)
 	workspacesVar := newMapVar(vals.EmptyMap)
+	saveHistoryVar := newBoolVar(true)
+	excludeHistoryVar := newListVar(vals.EmptyList)
+	altScreenVar := newBoolVar(false)
 
 	bindings := newMapBindings(ed, ev, bindingVar, commonBindingVar)
 	workspaceIterator := modes.LocationWSIterator(
@@ -108,10 +117,13 @@ func initLocation(ed *Editor, ev *eval.Evaler, st storedefs.Store, commonBinding
 	nb.AddNs("location",
 		eval.BuildNsNamed("edit:location").
 			AddVars(map[string]vars.Var{
-				"binding":    bindingVar,
-				"hidden":     hiddenVar,
-				"pinned":     pinnedVar,
-				"workspaces": workspacesVar,
+				"binding":         bindingVar,
+				"hidden":          hiddenVar,
+				"pinned":          pinnedVar,
+				"workspaces":      workspacesVar,
+				"save-history":    saveHistoryVar,
+				"exclude-history": excludeHistoryVar,
+				"alt-screen":      altScreenVar,
 			}).
 			AddGoFn("start", func() {
 				w, err := modes.NewLocation(ed.app, modes.LocationSpec{
@@ -120,23 +132,41 @@ func initLocation(ed *Editor, ev *eval.Evaler, st storedefs.Store, commonBinding
 					IterateHidden:     adaptToIterateString(hiddenVar),
 					IterateWorkspaces: workspaceIterator,
 					Filter:            filterSpec,
+					AltScreen:         func() bool { return altScreenVar.Get().(bool) },
 				})
-				startMode(ed.app, w, err)
+				ed.startMode("location", w, err)
 			}))
-	ev.AfterChdir = append(ev.AfterChdir, func(string) {
-		wd, err := os.Getwd()
-		if err != nil {
-			// TODO(xiaq): Surface the error.
+	ev.AfterChdir = append(ev.AfterChdir, func(e eval.ChdirEvent) {
+		if st != nil && !ed.isIncognito() && saveHistoryVar.Get().(bool) &&
+			!dirExcluded(e.Dir, adaptToIterateString(excludeHistoryVar)) {
+			st.AddDir(e.Dir, 1)
+			kind, root := workspaceIterator.Parse(e.Dir)
+			if kind != "" {
+				st.AddDir(kind+e.Dir[len(root):], 1)
+			}
+		}
+	})
+}
+
+// Returns whether dir or one of its parent directories matches one of the
+// patterns, using the syntax of filepath.Match.
+func dirExcluded(dir string, iteratePatterns func(func(string))) bool {
+	excluded := false
+	iteratePatterns(func(pattern string) {
+		if excluded || pattern == "" {
 			return
 		}
-		if st != nil {
-			st.AddDir(wd, 1)
-			kind, root := workspaceIterator.Parse(wd)
-			if kind != "" {
-				st.AddDir(kind+wd[len(root):], 1)
+		for d := dir; ; d = filepath.Dir(d) {
+			if ok, _ := filepath.Match(pattern, d); ok {
+				excluded = true
+				return
+			}
+			if parent := filepath.Dir(d); parent == d {
+				return
 			}
 		}
 	})
+	return excluded
 }
 
 func listingAccept(app cli.App) {
@@ -222,13 +252,13 @@ func (d dirStore) Getwd() (string, error) {
 	return os.Getwd()
 }
 
-func startMode(app cli.App, w tk.Widget, err error) {
+func (ed *Editor) startMode(name string, w tk.Widget, err error) {
 	if w != nil {
-		app.PushAddon(w)
-		app.Redraw()
+		ed.pushMode(name, w)
+		ed.app.Redraw()
 	}
 	if err != nil {
-		app.Notify(modes.ErrorText(err))
+		ed.app.Notify(modes.ErrorText(err))
 	}
 }
 
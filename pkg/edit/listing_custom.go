@@ -100,7 +100,7 @@ func listingStartCustom(ed *Editor, fm *eval.Frame, opts customListingOpts, item
 		},
 		AutoAccept: opts.AutoAccept,
 	})
-	startMode(ed.app, w, err)
+	ed.startMode("listing", w, err)
 }
 
 func getToFilter(v any) (string, bool) {
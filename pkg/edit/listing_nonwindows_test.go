@@ -112,3 +112,43 @@ func TestLocation_AddDir(t *testing.T) {
 		t.Errorf("got dirs %v, want %v", dirs, wantDirs)
 	}
 }
+
+func TestLocation_AddDir_SkipsExcludedAndPrivate(t *testing.T) {
+	f := setup(t)
+
+	testutil.ApplyDir(
+		testutil.Dir{
+			"bin":    testutil.Dir{},
+			"secret": testutil.Dir{"a": testutil.Dir{"b": testutil.Dir{}}},
+			"tmp1":   testutil.Dir{},
+			"tmp2":   testutil.Dir{},
+			"quiet":  testutil.Dir{},
+		})
+	evals(f.Evaler,
+		`set edit:location:exclude-history = [$E:HOME/secret $E:HOME/tmp?]`)
+
+	chdir := func(path string) {
+		t.Helper()
+		err := f.Evaler.Chdir(path)
+		if err != nil {
+			t.Skip("chdir:", err)
+		}
+	}
+	chdir(filepath.Join(f.Home, "bin"))
+	chdir(filepath.Join(f.Home, "secret", "a", "b"))
+	chdir(filepath.Join(f.Home, "tmp1"))
+	evals(f.Evaler, `set edit:incognito = $true`)
+	chdir(filepath.Join(f.Home, "quiet"))
+	evals(f.Evaler, `set edit:incognito = $false`, `set edit:location:save-history = $false`)
+	chdir(filepath.Join(f.Home, "tmp2"))
+	chdir(filepath.Join(f.Home, "quiet"))
+
+	entries, err := f.Store.Dirs(map[string]struct{}{})
+	if err != nil {
+		t.Error("unable to list dir history:", err)
+	}
+	wantEntries := []storedefs.Dir{{Path: filepath.Join(f.Home, "bin"), Score: 10}}
+	if !reflect.DeepEqual(entries, wantEntries) {
+		t.Errorf("got dirs %v, want %v", entries, wantEntries)
+	}
+}
@@ -1,6 +1,7 @@
 package edit
 
 import (
+	"fmt"
 	"testing"
 
 	"src.elv.sh/pkg/cli/term"
@@ -153,6 +154,52 @@ func TestLastCmdAddon(t *testing.T) {
 	)
 }
 
+func TestLastOutput(t *testing.T) {
+	f := setup(t, rc(`set edit:last-output-max-lines = 3`))
+
+	w := f.Editor.RecordOutput()
+	fmt.Fprint(w, "echo 1\n\033[31mred\033[m\n")
+	fmt.Fprint(w, "foo\nbar")
+	w.Close()
+	// Writes after closing are ignored.
+	fmt.Fprint(w, "ignored\n")
+
+	evals(f.Evaler, `edit:last-output:start`)
+	f.TestTTY(t,
+		"~> \n",
+		" LAST OUTPUT  ", Styles,
+		"************* ", term.DotHere, "\n",
+		"red                                               \n", Styles,
+		"!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!",
+		"foo                                               \n",
+		"bar                                               ", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++",
+	)
+
+	f.TTYCtrl.Inject(term.K(ui.Up), term.K(ui.Enter))
+	f.TestTTY(t, "~> foo", Styles,
+		"   !!!", term.DotHere)
+}
+
+func TestLastOutput_Fn(t *testing.T) {
+	f := setup(t, rc(`set edit:last-output-max-lines = 2`))
+
+	w := f.Editor.RecordOutput()
+	fmt.Fprint(w, "foo\nbar\r\nlorem\n")
+	w.Close()
+
+	evals(f.Evaler, `var out = (edit:last-output | slurp)`)
+	testGlobal(t, f.Evaler, "out", "bar\nlorem\n")
+}
+
+func TestLastOutput_DisabledByDefault(t *testing.T) {
+	f := setup(t)
+
+	if w := f.Editor.RecordOutput(); w != nil {
+		t.Errorf("RecordOutput returns %v, want nil", w)
+	}
+}
+
 func TestCustomListing_PassingList(t *testing.T) {
 	f := setup(t)
 
@@ -222,3 +269,23 @@ func TestCustomListing_PassingBytesCallback(t *testing.T) {
 		"~> # x", Styles,
 		"   ccc", term.DotHere)
 }
+
+func TestInsertUnicode(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler, `edit:insert-unicode:start`)
+	feedInput(f.TTYCtrl, "snowman")
+	f.TestTTY(t,
+		"~> \n",
+		" UNICODE  snowman", Styles,
+		"*********        ", term.DotHere, "\n",
+		"U+2603 ☃ SNOWMAN                                  \n", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++",
+		"U+26C4 ⛄ SNOWMAN WITHOUT SNOW                     \n",
+		"U+26C7 ⛇ BLACK SNOWMAN                            ",
+	)
+
+	f.TTYCtrl.Inject(term.K(ui.Down), term.K(ui.Enter))
+	f.TestTTY(t, "~> ⛄", Styles,
+		"   !", term.DotHere)
+}
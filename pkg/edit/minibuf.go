@@ -2,9 +2,11 @@ package edit
 
 import (
 	"src.elv.sh/pkg/cli/modes"
+	"src.elv.sh/pkg/cli/term"
 	"src.elv.sh/pkg/cli/tk"
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/ui"
 )
 
 func initMinibuf(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
@@ -15,6 +17,9 @@ func initMinibuf(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 			AddVar("binding", bindingVar).
 			AddGoFns(map[string]any{
 				"start": func() { minibufStart(ed, ev, bindings) },
+				"start-secret": func(opts secretMinibufOpts, callback eval.Callable) {
+					minibufStartSecret(ed, ev, opts, callback)
+				},
 			}))
 }
 
@@ -26,7 +31,7 @@ func minibufStart(ed *Editor, ev *eval.Evaler, bindings tk.Bindings) {
 		// TODO: Add Highlighter. Right now the async highlighter is not
 		// directly usable.
 	})
-	ed.app.PushAddon(w)
+	ed.pushMode("minibuf", w)
 	ed.app.Redraw()
 }
 
@@ -47,3 +52,36 @@ func minibufSubmit(ed *Editor, ev *eval.Evaler) {
 		app.Notify(modes.ErrorText(err))
 	}
 }
+
+type secretMinibufOpts struct{ Prompt string }
+
+func (opts *secretMinibufOpts) SetDefaultOptions() { opts.Prompt = " SECRET " }
+
+// Starts a variant of the minibuffer for entering secrets like passwords. The
+// secret is passed to callback when submitted. Since it is neither code nor
+// history, the user-defined bindings, which could show or store it, are not
+// used.
+func minibufStartSecret(ed *Editor, ev *eval.Evaler, opts secretMinibufOpts, callback eval.Callable) {
+	var w tk.CodeArea
+	w = tk.NewCodeArea(tk.CodeAreaSpec{
+		Prompt: modes.Prompt(opts.Prompt, true),
+		Bindings: tk.MapBindings{
+			term.K('U', ui.Ctrl): func(tk.Widget) {
+				w.MutateState(func(s *tk.CodeAreaState) { s.Buffer = tk.CodeBuffer{} })
+			},
+		},
+		Secret: true,
+		OnSubmit: func() {
+			ed.app.PopAddon()
+			var secret string
+			w.MutateState(func(s *tk.CodeAreaState) {
+				secret = s.Buffer.Content
+				// Don't keep the secret around for longer than needed.
+				s.Buffer = tk.CodeBuffer{}
+			})
+			callWithNotifyPorts(ed, ev, callback, secret)
+		},
+	})
+	ed.pushMode("minibuf", w)
+	ed.app.Redraw()
+}
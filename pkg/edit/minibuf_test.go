@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/ui"
 )
 
 func TestMinibuf(t *testing.T) {
@@ -21,3 +22,35 @@ func TestMinibuf(t *testing.T) {
 		"   vvv", term.DotHere,
 	)
 }
+
+func TestMinibuf_Secret(t *testing.T) {
+	f := setup(t, rc(
+		// The user-defined bindings of the minibuffer are not used.
+		`set edit:minibuf:binding[Ctrl-R] = { edit:insert-at-dot bound }`))
+
+	evals(f.Evaler, `edit:minibuf:start-secret {|s| edit:insert-at-dot $s }`)
+	feedInput(f.TTYCtrl, "hunter")
+	f.TTYCtrl.Inject(term.K('R', ui.Ctrl))
+	// Neither the secret nor its length is shown.
+	f.TestTTY(t,
+		"~> \n",
+		" SECRET  ", Styles,
+		"******** ", term.DotHere,
+	)
+	f.TTYCtrl.Inject(term.K('U', ui.Ctrl))
+	feedInput(f.TTYCtrl, "hunter2\n")
+	f.TestTTY(t,
+		"~> hunter2", Styles,
+		"   !!!!!!!", term.DotHere)
+}
+
+func TestMinibuf_Secret_Prompt(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler, `edit:minibuf:start-secret &prompt=' PASSWORD ' {|s| }`)
+	f.TestTTY(t,
+		"~> \n",
+		" PASSWORD  ", Styles,
+		"********** ", term.DotHere,
+	)
+}
@@ -74,6 +74,7 @@ func initNavigation(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 	bindingVar := newBindingVar(emptyBindingsMap)
 	bindings := newMapBindings(ed, ev, bindingVar)
 	widthRatioVar := newListVar(vals.MakeList(1.0, 3.0, 4.0))
+	altScreenVar := newBoolVar(false)
 
 	selectedFileVar := vars.FromGet(func() any {
 		if w, ok := activeNavigation(ed.app); ok {
@@ -89,6 +90,7 @@ func initNavigation(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 		AddVars(map[string]vars.Var{
 			"binding":     bindingVar,
 			"width-ratio": widthRatioVar,
+			"alt-screen":  altScreenVar,
 		}).
 		AddGoFns(map[string]any{
 			"start": func() {
@@ -104,11 +106,12 @@ func initNavigation(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 							bindingTip("hidden", "navigation:trigger-shown-hidden"),
 							bindingTip("filter", "navigation:trigger-filter"))
 					},
+					AltScreen: func() bool { return altScreenVar.Get().(bool) },
 				})
 				if err != nil {
 					app.Notify(modes.ErrorText(err))
 				} else {
-					startMode(app, w, nil)
+					ed.startMode("navigation", w, nil)
 				}
 			},
 			"left":  actOnNavigation(app, modes.Navigation.Ascend),
@@ -6,6 +6,7 @@ import (
 
 	"src.elv.sh/pkg/cli/lscolors"
 	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/must"
 	"src.elv.sh/pkg/testutil"
 	"src.elv.sh/pkg/ui"
@@ -74,6 +75,31 @@ func TestNavigation_WidthRatio(t *testing.T) {
 	)
 }
 
+func TestNavigation_AltScreen(t *testing.T) {
+	f := setupNav(t)
+
+	evals(f.Evaler, `set edit:navigation:alt-screen = $true`)
+	f.TTYCtrl.Inject(term.K('N', ui.Ctrl))
+	f.TestTTY(t,
+		filepath.Join("~", "d"), "> ", term.DotHere, "\n",
+		" NAVIGATING            Ctrl-H hidden Ctrl-F filter\n", Styles,
+		"************           ++++++        ++++++       ",
+		" d      a                 \n", Styles,
+		"###### ++++++++++++++++++ ",
+		"        e                ", Styles,
+		"       //////////////////",
+	)
+	if !f.TTYCtrl.AltScreen() {
+		t.Errorf("alternate screen not used in navigation mode")
+	}
+
+	f.TTYCtrl.Inject(term.K('[', ui.Ctrl))
+	f.TestTTY(t, filepath.Join("~", "d"), "> ", term.DotHere)
+	if f.TTYCtrl.AltScreen() {
+		t.Errorf("alternate screen still used after leaving navigation mode")
+	}
+}
+
 // Test corner case: Inserting a selection when the CLI cursor is not at the
 // start of the edit buffer, but the preceding char is a space, does not
 // insert another space.
@@ -106,7 +132,8 @@ func TestNavigation_EnterDoesNotAddSpaceAtStartOfBuffer(t *testing.T) {
 }
 
 // Test corner case: Inserting a selection when the CLI cursor is at the start
-// of a line buffer omits the space char prefix.
+// of a line buffer (after the indentation inserted by Enter) omits the space
+// char prefix.
 func TestNavigation_EnterDoesNotAddSpaceAtStartOfLine(t *testing.T) {
 	f := setupNav(t)
 
@@ -117,7 +144,7 @@ func TestNavigation_EnterDoesNotAddSpaceAtStartOfLine(t *testing.T) {
 		filepath.Join("~", "d"), "> ",
 		"put [", Styles,
 		"vvv b", "\n",
-		"     a", term.DotHere,
+		"       a", term.DotHere,
 	)
 }
 
@@ -148,7 +175,7 @@ func TestNavigation_EnterDoesNothingInEmptyDir(t *testing.T) {
 func TestNavigation_UsesEvalerChdir(t *testing.T) {
 	f := setupNav(t)
 	afterChdirCalled := false
-	f.Evaler.AfterChdir = append(f.Evaler.AfterChdir, func(dir string) {
+	f.Evaler.AfterChdir = append(f.Evaler.AfterChdir, func(eval.ChdirEvent) {
 		afterChdirCalled = true
 	})
 
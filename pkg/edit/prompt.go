@@ -1,6 +1,7 @@
 package edit
 
 import (
+	"context"
 	"io"
 	"os"
 	"os/user"
@@ -10,23 +11,38 @@ import (
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/prompt"
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/eval/vars"
 	"src.elv.sh/pkg/fsutil"
 	"src.elv.sh/pkg/ui"
 )
 
-func initPrompts(appSpec *cli.AppSpec, nt notifier, ev *eval.Evaler, nb eval.NsBuilder) {
-	promptVal, rpromptVal := getDefaultPromptVals()
-	initPrompt(&appSpec.Prompt, "prompt", promptVal, nt, ev, nb)
-	initPrompt(&appSpec.RPrompt, "rprompt", rpromptVal, nt, ev, nb)
+func initPrompts(appSpec *cli.AppSpec, nt notifier, incognito func() bool, ev *eval.Evaler, nb eval.NsBuilder) {
+	promptVal, rpromptVal := getDefaultPromptVals(incognito)
+	initPrompt(&appSpec.Prompt, "prompt", promptVal, nil, nt, ev, nb)
+	// The rprompt is usually more expensive to compute, so it is shown empty
+	// rather than with a placeholder until it is ready. It never holds back
+	// the prompt since each of them is computed by its own prompt.Prompt.
+	initPrompt(&appSpec.RPrompt, "rprompt", rpromptVal,
+		func() ui.Text { return nil }, nt, ev, nb)
 
 	rpromptPersistentVar := newBoolVar(false)
 	appSpec.RPromptPersistent = func() bool { return rpromptPersistentVar.Get().(bool) }
 	nb.AddVar("rprompt-persistent", rpromptPersistentVar)
+
+	initContinuationPrompt(appSpec, nt, ev, nb)
+
+	semanticPromptVar := newBoolVar(false)
+	appSpec.SemanticPrompt = func() bool { return semanticPromptVar.Get().(bool) }
+	nb.AddVar("semantic-prompt", semanticPromptVar)
+
+	commandTitleVar := newBoolVar(false)
+	appSpec.CommandTitle = func() bool { return commandTitleVar.Get().(bool) }
+	nb.AddVar("command-title", commandTitleVar)
 }
 
-func initPrompt(p *cli.Prompt, name string, val eval.Callable, nt notifier, ev *eval.Evaler, nb eval.NsBuilder) {
+func initPrompt(p *cli.Prompt, name string, val eval.Callable, placeholder func() ui.Text, nt notifier, ev *eval.Evaler, nb eval.NsBuilder) {
 	computeVar := vars.FromPtr(&val)
 	nb.AddVar(name, computeVar)
 	eagernessVar := newIntVar(5)
@@ -36,23 +52,133 @@ func initPrompt(p *cli.Prompt, name string, val eval.Callable, nt notifier, ev *
 	staleTransformVar := newFnVar(
 		eval.NewGoFn("<default stale transform>", defaultStaleTransform))
 	nb.AddVar(name+"-stale-transform", staleTransformVar)
+	cacheKeyVar := newFnVar(nil)
+	nb.AddVar(name+"-cache-key", cacheKeyVar)
 
-	*p = prompt.New(prompt.Config{
-		Compute: func() ui.Text {
-			return callForStyledText(nt, ev, name, computeVar.Get().(eval.Callable))
+	pr := prompt.New(prompt.Config{
+		ComputeCtx: func(ctx context.Context) ui.Text {
+			return callForStyledText(ctx, nt, ev, name, computeVar.Get().(eval.Callable))
 		},
-		Eagerness: func() int { return eagernessVar.GetRaw().(int) },
+		Placeholder: placeholder,
+		Eagerness:   func() int { return eagernessVar.GetRaw().(int) },
 		StaleThreshold: func() time.Duration {
 			seconds := staleThresholdVar.GetRaw().(float64)
 			return time.Duration(seconds * float64(time.Second))
 		},
 		StaleTransform: func(original ui.Text) ui.Text {
-			return callForStyledText(nt, ev, name+" stale transform", staleTransformVar.Get().(eval.Callable), original)
+			return callForStyledText(context.Background(), nt, ev, name+" stale transform", staleTransformVar.Get().(eval.Callable), original)
+		},
+		CacheKey: func() (string, bool) {
+			fn, _ := cacheKeyVar.Get().(eval.Callable)
+			if fn == nil {
+				return "", false
+			}
+			return callForStyledText(context.Background(), nt, ev, name+" cache key", fn).String(), true
 		},
 	})
+	ev.AfterChdir = append(ev.AfterChdir, func(eval.ChdirEvent) { pr.DirChanged() })
+	*p = pr
 }
 
-func getDefaultPromptVals() (prompt, rprompt eval.Callable) {
+func initContinuationPrompt(appSpec *cli.AppSpec, nt notifier, ev *eval.Evaler, nb eval.NsBuilder) {
+	fnVar := newFnVar(nil)
+	nb.AddVar("continuation-prompt", fnVar)
+	// The continuation prompt of each line is only computed once while reading
+	// a command, so that the function is not called on each redraw, and errors
+	// from it are only reported once.
+	var (
+		cacheMutex sync.Mutex
+		cache      map[int]ui.Text
+	)
+	appSpec.BeforeReadline = append(appSpec.BeforeReadline, func() {
+		cacheMutex.Lock()
+		defer cacheMutex.Unlock()
+		cache = nil
+	})
+	appSpec.ContinuationPrompt = func(lineno int) ui.Text {
+		fn, _ := fnVar.Get().(eval.Callable)
+		if fn == nil {
+			return nil
+		}
+		cacheMutex.Lock()
+		defer cacheMutex.Unlock()
+		if content, ok := cache[lineno]; ok {
+			return content
+		}
+		content := callForStyledText(context.Background(), nt, ev, "continuation prompt", fn, lineno)
+		if cache == nil {
+			cache = make(map[int]ui.Text)
+		}
+		cache[lineno] = content
+		return content
+	}
+}
+
+// Shows an indicator of the active mode at the start of the prompt, taken from
+// $edit:mode-indicator. Useful for the command and visual modes, whose
+// modelines are hidden once they are closed.
+func initModeIndicator(appSpec *cli.AppSpec, ed *Editor, nb eval.NsBuilder) {
+	var (
+		mutex      sync.RWMutex
+		indicators = vals.EmptyMap
+	)
+	nb.AddVar("mode-indicator", vars.FromSetGet(
+		func(v any) error {
+			m, ok := v.(vals.Map)
+			if !ok {
+				return errs.BadValue{What: "$edit:mode-indicator",
+					Valid: "map", Actual: vals.Kind(v)}
+			}
+			for it := m.Iterator(); it.HasElem(); it.Next() {
+				k, v := it.Elem()
+				if _, ok := k.(string); !ok {
+					return errs.BadValue{What: "key of $edit:mode-indicator",
+						Valid: "string", Actual: vals.ReprPlain(k)}
+				}
+				if _, err := ui.Text(nil).Concat(v); err != nil {
+					return errs.BadValue{What: "value of $edit:mode-indicator",
+						Valid: "string or styled", Actual: vals.ReprPlain(v)}
+				}
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			indicators = m
+			return nil
+		},
+		func() any {
+			mutex.RLock()
+			defer mutex.RUnlock()
+			return indicators
+		}))
+	appSpec.Prompt = modeIndicatorPrompt{appSpec.Prompt, func() ui.Text {
+		mutex.RLock()
+		v, ok := indicators.Index(ed.activeMode())
+		mutex.RUnlock()
+		if !ok {
+			return nil
+		}
+		t, _ := ui.Text(nil).Concat(v)
+		return t.(ui.Text)
+	}}
+}
+
+type modeIndicatorPrompt struct {
+	cli.Prompt
+	indicator func() ui.Text
+}
+
+func (p modeIndicatorPrompt) Get() ui.Text {
+	return ui.Concat(p.indicator(), p.Prompt.Get())
+}
+
+// Cancel cancels the computation of the wrapped prompt, if it supports that.
+func (p modeIndicatorPrompt) Cancel() {
+	if c, ok := p.Prompt.(interface{ Cancel() }); ok {
+		c.Cancel()
+	}
+}
+
+func getDefaultPromptVals(incognito func() bool) (prompt, rprompt eval.Callable) {
 	user, userErr := user.Current()
 	isRoot := userErr == nil && user.Uid == "0"
 
@@ -65,33 +191,50 @@ func getDefaultPromptVals() (prompt, rprompt eval.Callable) {
 		hostname = "???"
 	}
 
-	return getDefaultPrompt(isRoot), getDefaultRPrompt(username, hostname)
+	return getDefaultPrompt(isRoot, incognito), getDefaultRPrompt(username, hostname)
 }
 
-func getDefaultPrompt(isRoot bool) eval.Callable {
-	p := ui.T("> ")
-	if isRoot {
-		p = ui.T("# ", ui.FgRed)
-	}
+var (
+	// Used for the indicator shown at the start of the default prompt when
+	// $edit:incognito is true.
+	stylingForIncognito  = ui.DefineRole("incognito", ui.FgMagenta)
+	stylingForRootPrompt = ui.DefineRole("prompt-root", ui.FgRed)
+	stylingForRPrompt    = ui.DefineRole("rprompt", ui.Inverse)
+	stylingForStale      = ui.DefineRole("prompt-stale", ui.Inverse)
+)
+
+// The default prompts are styled when they are called rather than when they
+// are created, so that they follow changes to $edit:theme.
+
+func getDefaultPrompt(isRoot bool, incognito func() bool) eval.Callable {
 	return eval.NewGoFn("default prompt", func() ui.Text {
+		p := ui.T("> ")
+		if isRoot {
+			p = ui.T("# ", stylingForRootPrompt)
+		}
+		if incognito() {
+			return ui.Concat(ui.T("[private]", stylingForIncognito), ui.T(" "),
+				ui.T(fsutil.Getwd()), p)
+		}
 		return ui.Concat(ui.T(fsutil.Getwd()), p)
 	})
 }
 
 func getDefaultRPrompt(username, hostname string) eval.Callable {
-	rp := ui.T(username+"@"+hostname, ui.Inverse)
 	return eval.NewGoFn("default rprompt", func() ui.Text {
-		return rp
+		return ui.T(username+"@"+hostname, stylingForRPrompt)
 	})
 }
 
 func defaultStaleTransform(original ui.Text) ui.Text {
-	return ui.StyleText(original, ui.Inverse)
+	return ui.StyleText(original, stylingForStale)
 }
 
 // Calls a function with the given arguments and closed input, and concatenates
 // its outputs to a styled text. Used to call prompts and stale transformers.
-func callForStyledText(nt notifier, ev *eval.Evaler, ctx string, fn eval.Callable, args ...any) ui.Text {
+// The function is interrupted when cancelCtx is cancelled, in which case the
+// resulting error is not reported.
+func callForStyledText(cancelCtx context.Context, nt notifier, ev *eval.Evaler, ctx string, fn eval.Callable, args ...any) ui.Text {
 	var (
 		result      ui.Text
 		resultMutex sync.Mutex
@@ -133,11 +276,16 @@ func callForStyledText(nt notifier, ev *eval.Evaler, ctx string, fn eval.Callabl
 
 	err = ev.Call(fn,
 		eval.CallCfg{Args: args, From: "[" + ctx + "]"},
-		eval.EvalCfg{Ports: []*eval.Port{nil, port1, port2}})
+		eval.EvalCfg{
+			Ports: []*eval.Port{nil, port1, port2},
+			Interrupt: func() (<-chan struct{}, func()) {
+				return cancelCtx.Done(), func() {}
+			},
+		})
 	done1()
 	done2()
 
-	if err != nil {
+	if err != nil && cancelCtx.Err() == nil {
 		nt.notifyError(ctx, err)
 	}
 	return result
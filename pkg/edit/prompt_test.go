@@ -2,12 +2,14 @@ package edit
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"src.elv.sh/pkg/cli/clitest"
 	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
 	"src.elv.sh/pkg/testutil"
 	"src.elv.sh/pkg/ui"
 )
@@ -51,6 +53,28 @@ func TestPrompt_NotifiesException(t *testing.T) {
 	testGlobal(t, f.Evaler, "excs", 1)
 }
 
+func TestModeIndicator(t *testing.T) {
+	f := setup(t, rc(
+		`set edit:mode-indicator = [&command=(styled '[N]' inverse) &insert='[I]']`,
+		`set edit:insert:binding[Ctrl-'['] = $edit:command:start~`))
+
+	f.TestTTY(t, "[I]~> ", term.DotHere)
+
+	f.TTYCtrl.Inject(term.K('[', ui.Ctrl))
+	f.TestTTY(t,
+		"[N]~> ", Styles,
+		"+++", term.DotHere, "\n",
+		" COMMAND ", Styles,
+		"*********",
+	)
+
+	evals(f.Evaler,
+		`var ok-map = ?(set edit:mode-indicator = foo)`,
+		`var ok-value = ?(set edit:mode-indicator = [&insert=[]])`,
+		`var ok-map ok-value = (bool $ok-map) (bool $ok-value)`)
+	testGlobals(t, f.Evaler, map[string]any{"ok-map": false, "ok-value": false})
+}
+
 func TestRPrompt(t *testing.T) {
 	f := setup(t, rc(`set edit:rprompt = { put 'RRR' }`))
 
@@ -71,6 +95,45 @@ func TestPromptEagerness(t *testing.T) {
 	f.TestTTY(t, "2> ", term.DotHere)
 }
 
+func TestPromptEagerness_UpdatedAfterChdir(t *testing.T) {
+	f := setup(t, rc(
+		`var i = 0`,
+		`set edit:prompt = { set i = (+ $i 1); put $i'> ' }`))
+
+	f.TestTTY(t, "1> ", term.DotHere)
+	// With the default eagerness, key presses only cause the prompt to be
+	// recomputed after the directory has changed.
+	evals(f.Evaler, `cd /`)
+	f.TTYCtrl.Inject(term.K('a'))
+	f.TestTTY(t,
+		"2> a", Styles,
+		"   !", term.DotHere)
+	f.TTYCtrl.Inject(term.K('b'))
+	f.TestTTY(t,
+		"2> ab", Styles,
+		"   !!", term.DotHere)
+}
+
+func TestPromptCacheKey(t *testing.T) {
+	f := setup(t, rc(
+		`var i = 0`,
+		`var key = a`,
+		`set edit:prompt = { set i = (+ $i 1); put $i'> ' }`,
+		`set edit:prompt-cache-key = { put $key }`,
+		`set edit:-prompt-eagerness = 10`))
+
+	f.TestTTY(t, "1> ", term.DotHere)
+	// The key is the same, so the cached prompt is used.
+	f.TTYCtrl.Inject(term.K(ui.Backspace))
+	f.TestTTY(t, "1> ", term.DotHere)
+	evals(f.Evaler, `set key = b`)
+	f.TTYCtrl.Inject(term.K(ui.Backspace))
+	f.TestTTY(t, "2> ", term.DotHere)
+	evals(f.Evaler, `set key = a`)
+	f.TTYCtrl.Inject(term.K(ui.Backspace))
+	f.TestTTY(t, "1> ", term.DotHere)
+}
+
 func TestPromptStaleThreshold(t *testing.T) {
 	f := setup(t, rc(
 		`var pipe = (file:pipe)`,
@@ -112,6 +175,80 @@ func TestPromptStaleTransform_Exception(t *testing.T) {
 	testGlobal(t, f.Evaler, "excs", 1)
 }
 
+func TestRPromptStaleThreshold(t *testing.T) {
+	f := setup(t, rc(
+		`var pipe = (file:pipe)`,
+		`set edit:rprompt = { nop (slurp < $pipe); put 'RRR' }`,
+		`set edit:rprompt-stale-threshold = `+scaledMsAsSec(50)))
+
+	// A slow rprompt is shown empty, and doesn't hold back the prompt.
+	f.TestTTY(t, "~> ", term.DotHere)
+
+	evals(f.Evaler, `file:close $pipe[w]`)
+	f.TestTTY(t, "~> ", term.DotHere,
+		strings.Repeat(" ", clitest.FakeTTYWidth-6)+"RRR")
+	evals(f.Evaler, `file:close $pipe[r]`)
+}
+
+func TestContinuationPrompt(t *testing.T) {
+	f := setup(t, rc(
+		`var calls = 0`,
+		`set edit:continuation-prompt = {|n| set calls = (+ $calls 1); styled $n'| ' red }`))
+
+	f.SetCodeBuffer(tk.CodeBuffer{Content: "a\nb\nc", Dot: 5})
+	f.TTYCtrl.Inject(term.K('d'))
+	f.TestTTY(t,
+		"~> a\n", Styles,
+		"   !",
+		"2| b\n", Styles,
+		"!!!!",
+		"3| cd", Styles,
+		"!!!vv", term.DotHere)
+	// The continuation prompt of each line is computed only once.
+	testGlobal(t, f.Evaler, "calls", 2)
+}
+
+func TestContinuationPrompt_Default(t *testing.T) {
+	f := setup(t)
+
+	f.SetCodeBuffer(tk.CodeBuffer{Content: "a\nb", Dot: 3})
+	f.TTYCtrl.Inject(term.K(ui.Right))
+	f.TestTTY(t,
+		"~> a\n", Styles,
+		"   !",
+		"   b", Styles,
+		"   !", term.DotHere)
+}
+
+func TestSemanticPrompt(t *testing.T) {
+	f := setup(t, rc(`set edit:semantic-prompt = $true`))
+	f.TestTTY(t, "~> ", term.DotHere)
+
+	f.TTYCtrl.Inject(term.K('x'), term.K('\n'))
+	f.Wait()
+
+	wantMarks := []clitest.Mark{
+		{Mark: term.PromptStartMark, Pos: term.Pos{Line: 0, Col: 0}},
+		{Mark: term.CommandStartMark, Pos: term.Pos{Line: 0, Col: 3}},
+		{Mark: term.OutputStartMark, Pos: term.Pos{Line: 1, Col: 0}},
+	}
+	if marks := f.TTYCtrl.Marks(); !reflect.DeepEqual(marks, wantMarks) {
+		t.Errorf("got marks %v, want %v", marks, wantMarks)
+	}
+}
+
+func TestCommandTitle(t *testing.T) {
+	f := setup(t, rc(`set edit:command-title = $true`))
+	f.TestTTY(t, "~> ", term.DotHere)
+
+	f.TTYCtrl.Inject(term.K('x'), term.K('\n'))
+	f.Wait()
+
+	if titles := f.TTYCtrl.Titles(); !reflect.DeepEqual(titles, []string{"x"}) {
+		t.Errorf("got titles %q, want %q", titles, []string{"x"})
+	}
+}
+
 func TestRPromptPersistent_True(t *testing.T) {
 	testRPromptPersistent(t, `set edit:rprompt-persistent = $true`,
 		"~> "+strings.Repeat(" ", clitest.FakeTTYWidth-6)+"RRR",
@@ -140,19 +277,29 @@ func testRPromptPersistent(t *testing.T, code string, finalBuf ...any) {
 }
 
 func TestDefaultPromptForNonRoot(t *testing.T) {
-	f := setup(t, assign("edit:prompt", getDefaultPrompt(false)))
+	f := setup(t, assign("edit:prompt", getDefaultPrompt(false, notIncognito)))
 
 	f.TestTTY(t, "~> ", term.DotHere)
 }
 
 func TestDefaultPromptForRoot(t *testing.T) {
-	f := setup(t, assign("edit:prompt", getDefaultPrompt(true)))
+	f := setup(t, assign("edit:prompt", getDefaultPrompt(true, notIncognito)))
 
 	f.TestTTY(t,
 		"~# ", Styles,
 		" !!", term.DotHere)
 }
 
+func TestDefaultPromptForIncognito(t *testing.T) {
+	f := setup(t, assign("edit:prompt", getDefaultPrompt(false, func() bool { return true })))
+
+	f.TestTTY(t,
+		"[private] ~> ", Styles,
+		"---------", term.DotHere)
+}
+
+func notIncognito() bool { return false }
+
 func TestDefaultRPrompt(t *testing.T) {
 	f := setup(t, assign("edit:rprompt", getDefaultRPrompt("elf", "host")))
 
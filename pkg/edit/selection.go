@@ -0,0 +1,73 @@
+package edit
+
+// Implementation of the selection mode and the clipboard builtins.
+
+import (
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/modes"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/eval"
+)
+
+func initSelection(ed *Editor, ev *eval.Evaler, tty cli.TTY, nb eval.NsBuilder) {
+	bindingVar := newBindingVar(emptyBindingsMap)
+	bindings := newMapBindings(ed, ev, bindingVar)
+	nb.AddNs("selection",
+		eval.BuildNsNamed("edit:selection").
+			AddVar("binding", bindingVar).
+			AddGoFns(map[string]any{
+				"start": func() {
+					w, err := modes.NewSelection(ed.app, modes.SelectionSpec{Bindings: bindings})
+					ed.startMode("selection", w, err)
+				},
+			}))
+	nb.AddGoFns(map[string]any{
+		"copy-selection":  func() error { return copySelection(ed.app, tty) },
+		"paste-clipboard": func() error { return pasteClipboard(ed.app) },
+	})
+}
+
+// Copies the selected text to the clipboard, or all the code if no text is
+// selected, and closes the selection mode.
+func copySelection(app cli.App, tty cli.TTY) error {
+	codeArea, ok := focusedCodeArea(app)
+	if !ok {
+		return nil
+	}
+	s := codeArea.CopyState()
+	text := s.Buffer.Content
+	if from, to := s.SelectedRange(); from < to {
+		text = text[from:to]
+	}
+	closeSelection(app)
+	return term.CopyToClipboard(tty, text)
+}
+
+// Inserts the content of the clipboard at the dot, replacing the selected text
+// if there is any, and closes the selection mode.
+func pasteClipboard(app cli.App) error {
+	text, err := term.PasteFromClipboard()
+	if err != nil {
+		return err
+	}
+	codeArea, ok := focusedCodeArea(app)
+	if !ok {
+		return nil
+	}
+	codeArea.MutateState(func(s *tk.CodeAreaState) {
+		if from, to := s.SelectedRange(); from < to {
+			s.Buffer.Content = s.Buffer.Content[:from] + s.Buffer.Content[to:]
+			s.Buffer.Dot = from
+		}
+		s.Buffer.InsertAtDot(text)
+	})
+	closeSelection(app)
+	return nil
+}
+
+func closeSelection(app cli.App) {
+	if _, ok := app.ActiveWidget().(modes.Selection); ok {
+		app.PopAddon()
+	}
+}
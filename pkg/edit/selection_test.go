@@ -0,0 +1,53 @@
+package edit
+
+import (
+	"reflect"
+	"testing"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/ui"
+)
+
+func TestSelection_CopyAndPaste(t *testing.T) {
+	f := setup(t)
+
+	feedInput(f.TTYCtrl, "echo foo")
+	f.TTYCtrl.Inject(term.K('`', ui.Ctrl),
+		term.K(ui.Left), term.K(ui.Left), term.K(ui.Left))
+	f.TestTTY(t,
+		"~> echo ", Styles,
+		"   vvvv ", term.DotHere, "foo\n", Styles,
+		"+++",
+		" SELECTION ", Styles,
+		"***********",
+	)
+
+	f.TTYCtrl.Inject(term.K('y'))
+	f.TestTTY(t,
+		"~> echo ", Styles,
+		"   vvvv ", term.DotHere, "foo")
+	if clipboard := f.TTYCtrl.Clipboard(); !reflect.DeepEqual(clipboard, []string{"foo"}) {
+		t.Errorf("got clipboard %q, want %q", clipboard, []string{"foo"})
+	}
+
+	// Replace "echo " with the text just copied.
+	f.TTYCtrl.Inject(term.K('`', ui.Ctrl), term.K(ui.Home), term.K('p'))
+	f.TestTTY(t,
+		"~> foo", Styles,
+		"   !!!", term.DotHere, "foo", Styles,
+		"!!!")
+}
+
+func TestCopySelection_CopiesAllCodeOutsideSelectionMode(t *testing.T) {
+	f := setup(t)
+
+	f.SetCodeBuffer(tk.CodeBuffer{Content: "echo foo", Dot: 4})
+	evals(f.Evaler, "edit:copy-selection")
+	if clipboard := f.TTYCtrl.Clipboard(); !reflect.DeepEqual(clipboard, []string{"echo foo"}) {
+		t.Errorf("got clipboard %q, want %q", clipboard, []string{"echo foo"})
+	}
+
+	evals(f.Evaler, "edit:paste-clipboard")
+	testCodeBuffer(t, f.Editor, tk.CodeBuffer{Content: "echoecho foo foo", Dot: 12})
+}
@@ -1,11 +1,16 @@
 package edit
 
 import (
+	"errors"
+	"unicode/utf8"
+
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/tk"
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/ui"
 )
 
 func insertAtDot(app cli.App, text string) {
@@ -28,13 +33,148 @@ func replaceInput(app cli.App, text string) {
 	})
 }
 
-func initStateAPI(app cli.App, nb eval.NsBuilder) {
+// A snapshot of the state of the editor, output by edit:state.
+type editorState struct {
+	Buffer  string
+	Dot     int
+	Mode    string
+	LastKey any
+}
+
+func (editorState) IsStructMap() {}
+
+var errStateChanged = errors.New("buffer changed while the update function was running")
+
+// Pushes a mode widget to the addon stack, remembering its name for
+// edit:state.
+func (ed *Editor) pushMode(name string, w tk.Widget) {
+	ed.stateMutex.Lock()
+	depth := len(ed.app.CopyState().Addons)
+	if depth > len(ed.modeNames) {
+		// Could happen if an addon is pushed without pushMode.
+		depth = len(ed.modeNames)
+	}
+	ed.modeNames = append(ed.modeNames[:depth], name)
+	ed.stateMutex.Unlock()
+	ed.app.PushAddon(w)
+}
+
+// Returns the name of the active mode, like "insert" or "completion".
+func (ed *Editor) activeMode() string {
+	ed.stateMutex.Lock()
+	defer ed.stateMutex.Unlock()
+	depth := len(ed.app.CopyState().Addons)
+	if depth == 0 {
+		return "insert"
+	} else if depth > len(ed.modeNames) {
+		return ""
+	}
+	return ed.modeNames[depth-1]
+}
+
+func (ed *Editor) setLastKey(k ui.Key) {
+	ed.stateMutex.Lock()
+	defer ed.stateMutex.Unlock()
+	ed.lastKey = k
+}
+
+// Returns the state of the editor, and the code area that its buffer and dot
+// come from: the focused code area, the filter of a listing mode, or the root
+// code area otherwise.
+func (ed *Editor) state(root tk.CodeArea) (editorState, tk.CodeArea) {
+	codeArea := root
+	switch w := ed.app.FocusedWidget().(type) {
+	case tk.CodeArea:
+		codeArea = w
+	case tk.ComboBox:
+		codeArea = w.CodeArea()
+	}
+	buf := codeArea.CopyState().Buffer
+	mode := ed.activeMode()
+	ed.stateMutex.Lock()
+	defer ed.stateMutex.Unlock()
+	return editorState{buf.Content, buf.Dot, mode, ed.lastKey}, codeArea
+}
+
+func updateState(ed *Editor, root tk.CodeArea, fm *eval.Frame, f eval.Callable) error {
+	st, codeArea := ed.state(root)
+	outs, err := fm.CaptureOutput(func(fm *eval.Frame) error {
+		return f.Call(fm, []any{st}, eval.NoOpts)
+	})
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(outs) == 0:
+		return nil
+	case len(outs) > 1:
+		return errs.ArityMismatch{What: "update function outputs",
+			ValidLow: 0, ValidHigh: 1, Actual: len(outs)}
+	}
+	newBuf, err := scanBuffer(outs[0], tk.CodeBuffer{Content: st.Buffer, Dot: st.Dot})
+	if err != nil {
+		return err
+	}
+	codeArea.MutateState(func(s *tk.CodeAreaState) {
+		if s.Buffer.Content != st.Buffer || s.Buffer.Dot != st.Dot {
+			err = errStateChanged
+			return
+		}
+		s.Buffer = newBuf
+	})
+	return err
+}
+
+// Scans the buffer and dot from the output of the function passed to
+// edit:update-state. Fields that are missing keep their values in old.
+func scanBuffer(v any, old tk.CodeBuffer) (tk.CodeBuffer, error) {
+	if vals.Kind(v) != "map" {
+		return tk.CodeBuffer{}, errs.BadValue{What: "output of update function",
+			Valid: "map", Actual: vals.Kind(v)}
+	}
+	buf := old
+	if vals.HasKey(v, "buffer") {
+		content, _ := vals.Index(v, "buffer")
+		s, ok := content.(string)
+		if !ok {
+			return tk.CodeBuffer{}, errs.BadValue{What: "buffer",
+				Valid: "string", Actual: vals.Kind(content)}
+		}
+		buf.Content = s
+		if !vals.HasKey(v, "dot") {
+			buf.Dot = len(s)
+		}
+	}
+	if vals.HasKey(v, "dot") {
+		dotVal, _ := vals.Index(v, "dot")
+		var dot int
+		err := vals.ScanToGo(dotVal, &dot)
+		if err != nil || dot < 0 || dot > len(buf.Content) ||
+			(dot < len(buf.Content) && !utf8.RuneStart(buf.Content[dot])) {
+			return tk.CodeBuffer{}, errs.BadValue{What: "dot",
+				Valid:  "index of a codepoint boundary in the buffer",
+				Actual: vals.ReprPlain(dotVal)}
+		}
+		buf.Dot = dot
+	}
+	return buf, nil
+}
+
+func initStateAPI(ed *Editor, nb eval.NsBuilder) {
+	app := ed.app
 	// State API always operates on the root CodeArea widget
 	codeArea := app.ActiveWidget().(tk.CodeArea)
 
 	nb.AddGoFns(map[string]any{
 		"insert-at-dot": func(s string) { insertAtDot(app, s) },
 		"replace-input": func(s string) { replaceInput(app, s) },
+		"state": func(fm *eval.Frame) error {
+			st, _ := ed.state(codeArea)
+			return fm.ValueOutput().Put(st)
+		},
+		"update-state": func(fm *eval.Frame, f eval.Callable) error {
+			return updateState(ed, codeArea, fm, f)
+		},
 	})
 
 	setDot := func(v any) error {
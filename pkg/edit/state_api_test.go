@@ -3,7 +3,9 @@ package edit
 import (
 	"testing"
 
+	"src.elv.sh/pkg/cli/term"
 	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/ui"
 )
 
 func TestInsertAtDot(t *testing.T) {
@@ -41,6 +43,61 @@ func TestCurrentCommand(t *testing.T) {
 	testCodeBuffer(t, f.Editor, tk.CodeBuffer{Content: "code", Dot: 4})
 }
 
+func TestState(t *testing.T) {
+	f := setup(t, rc(
+		`var s = $nil`,
+		`set edit:insert:binding[Ctrl-X] = { set s = (edit:state); edit:notify done }`))
+
+	evals(f.Evaler, `var key = (edit:state)[last-key]`)
+	testGlobal(t, f.Evaler, "key", nil)
+
+	f.SetCodeBuffer(tk.CodeBuffer{Content: "ab", Dot: 1})
+	f.TTYCtrl.Inject(term.K('X', ui.Ctrl))
+	f.TestTTYNotes(t, "done")
+	evals(f.Evaler,
+		`var buffer dot mode key = $s[buffer] $s[dot] $s[mode] $s[last-key]`)
+	testGlobals(t, f.Evaler, map[string]any{
+		"buffer": "ab", "dot": 1, "mode": "insert", "key": ui.K('X', ui.Ctrl)})
+
+	evals(f.Evaler, `edit:location:start`, `var mode = (edit:state)[mode]`)
+	testGlobal(t, f.Evaler, "mode", "location")
+	evals(f.Evaler, `edit:close-mode`, `var mode = (edit:state)[mode]`)
+	testGlobal(t, f.Evaler, "mode", "insert")
+}
+
+func TestUpdateState(t *testing.T) {
+	f := setup(t)
+
+	f.SetCodeBuffer(tk.CodeBuffer{Content: "ab", Dot: 1})
+	evals(f.Evaler, `edit:update-state {|s|
+		put [&buffer=$s[buffer]c &dot=(+ $s[dot] 1)] }`)
+	testCodeBuffer(t, f.Editor, tk.CodeBuffer{Content: "abc", Dot: 2})
+
+	// Only updating the buffer moves the dot to the end.
+	evals(f.Evaler, `edit:update-state {|s| put [&buffer=xyz] }`)
+	testCodeBuffer(t, f.Editor, tk.CodeBuffer{Content: "xyz", Dot: 3})
+
+	// Outputting nothing doesn't change anything.
+	evals(f.Evaler, `edit:update-state {|s| }`)
+	testCodeBuffer(t, f.Editor, tk.CodeBuffer{Content: "xyz", Dot: 3})
+
+	// The state is not changed if the function fails, outputs a bad value, or
+	// if the buffer changes while it is running.
+	for _, code := range []string{
+		`edit:update-state {|s| put [&buffer=abc]; fail bad }`,
+		`edit:update-state {|s| put [&buffer=abc] [&buffer=def] }`,
+		`edit:update-state {|s| put abc }`,
+		`edit:update-state {|s| put [&buffer=(num 1)] }`,
+		`edit:update-state {|s| put [&dot=10] }`,
+		`edit:update-state {|s| put [&buffer=你 &dot=1] }`,
+		`edit:update-state {|s| edit:insert-at-dot w; put [&dot=0] }`,
+	} {
+		evals(f.Evaler, `var ok = ?(`+code+`)`, `var ok = (bool $ok)`)
+		testGlobal(t, f.Evaler, "ok", false)
+	}
+	testCodeBuffer(t, f.Editor, tk.CodeBuffer{Content: "xyzw", Dot: 4})
+}
+
 func testCodeBuffer(t *testing.T, ed *Editor, wantBuf tk.CodeBuffer) {
 	t.Helper()
 	if buf := codeArea(ed.app).CopyState().Buffer; buf != wantBuf {
@@ -2,6 +2,9 @@ package edit
 
 import (
 	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/histutil"
@@ -93,11 +96,130 @@ func insertLastWord(app cli.App, histStore histutil.Store) error {
 	return nil
 }
 
+// State of edit:complete-history-word, kept between calls so that repeated
+// calls cycle through the candidates.
+type historyWordCompleter struct {
+	// The buffer after the last call; a call with any other buffer starts a
+	// new completion.
+	buf tk.CodeBuffer
+	// The start of the word being completed, and the part of it before the dot
+	// when the completion started.
+	start  int
+	prefix string
+	// Candidates found so far, and the index of the inserted one, or -1 if the
+	// prefix itself is inserted.
+	cands []string
+	seen  map[string]bool
+	i     int
+	// The cursor to find more candidates in history with, or nil if history
+	// has been exhausted.
+	cursor histutil.Cursor
+}
+
+func (c *historyWordCompleter) complete(app cli.App, histStore histutil.Store) {
+	codeArea, ok := focusedCodeArea(app)
+	if !ok {
+		return
+	}
+	buf := codeArea.CopyState().Buffer
+	if c.cands == nil || buf != c.buf {
+		c.start = wordStart(buf.Content, buf.Dot)
+		c.prefix = buf.Content[c.start:buf.Dot]
+		if c.prefix == "" {
+			c.cands = nil
+			return
+		}
+		c.cands, c.seen, c.i = []string{}, map[string]bool{}, -1
+		c.add(reversed(historyWords(buf.Content[:c.start])))
+		after := buf.Content[buf.Dot:]
+		c.add(historyWords(after[wordEnd(after):]))
+		c.cursor = histStore.Cursor("")
+	}
+	c.i++
+	for c.i >= len(c.cands) && c.cursor != nil {
+		c.cursor.Prev()
+		cmd, err := c.cursor.Get()
+		if err != nil {
+			c.cursor = nil
+			break
+		}
+		c.add(reversed(historyWords(cmd.Text)))
+	}
+	if len(c.cands) == 0 {
+		c.cands = nil
+		return
+	}
+	word := c.prefix
+	if c.i < len(c.cands) {
+		word = c.cands[c.i]
+	} else {
+		// Restore the prefix after the last candidate, and start over next time.
+		c.i = -1
+	}
+	codeArea.MutateState(func(s *tk.CodeAreaState) {
+		s.Buffer = tk.CodeBuffer{
+			Content: s.Buffer.Content[:c.start] + word + s.Buffer.Content[s.Buffer.Dot:],
+			Dot:     c.start + len(word)}
+		c.buf = s.Buffer
+	})
+}
+
+func (c *historyWordCompleter) add(words []string) {
+	for _, word := range words {
+		if word != c.prefix && strings.HasPrefix(word, c.prefix) && !c.seen[word] {
+			c.seen[word] = true
+			c.cands = append(c.cands, word)
+		}
+	}
+}
+
+// Returns whether r separates the words considered by
+// edit:complete-history-word. Characters commonly found in paths and
+// identifiers, like "/", "." and "-", are not separators.
+func isHistoryWordSep(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("()[]{}<>|;&'\"$=", r)
+}
+
+func historyWords(s string) []string {
+	return strings.FieldsFunc(s, isHistoryWordSep)
+}
+
+// Returns the start of the word that ends at dot.
+func wordStart(s string, dot int) int {
+	for dot > 0 {
+		r, size := utf8.DecodeLastRuneInString(s[:dot])
+		if isHistoryWordSep(r) {
+			break
+		}
+		dot -= size
+	}
+	return dot
+}
+
+// Returns the end of the word that starts at the beginning of s.
+func wordEnd(s string) int {
+	if i := strings.IndexFunc(s, isHistoryWordSep); i != -1 {
+		return i
+	}
+	return len(s)
+}
+
+func reversed(words []string) []string {
+	for i, j := 0, len(words)-1; i < j; i, j = i+1, j-1 {
+		words[i], words[j] = words[j], words[i]
+	}
+	return words
+}
+
 func initStoreAPI(app cli.App, nb eval.NsBuilder, fuser histutil.Store) {
+	var historyWord historyWordCompleter
 	nb.AddGoFns(map[string]any{
 		"command-history": func(fm *eval.Frame, opts cmdhistOpt) error {
 			return commandHistory(opts, fuser, fm.ValueOutput())
 		},
 		"insert-last-word": func() { insertLastWord(app, fuser) },
+		"complete-history-word": func() {
+			historyWord.complete(app, fuser)
+		},
 	})
 }
@@ -96,3 +96,43 @@ func TestInsertLastWord(t *testing.T) {
 		t.Errorf("buf = %v, want %v", buf, wantBuf)
 	}
 }
+
+func TestCompleteHistoryWord(t *testing.T) {
+	f := setup(t, storeOp(func(s storedefs.Store) {
+		s.AddCmd("cd /some/long/path")
+		s.AddCmd("echo $long-name")
+		s.AddCmd("echo long-name lo | lookup")
+	}))
+
+	f.SetCodeBuffer(tk.CodeBuffer{Content: "put local x lo", Dot: 14})
+	// Words in the buffer come first, followed by words in history, newest
+	// first; each word is only tried once, and the original word is restored
+	// after the last candidate.
+	for _, want := range []string{
+		"put local x local", "put local x lookup", "put local x long-name",
+		"put local x lo", "put local x local",
+	} {
+		evals(f.Evaler, "edit:complete-history-word")
+		wantBuf := tk.CodeBuffer{Content: want, Dot: len(want)}
+		if buf := codeArea(f.Editor.app).CopyState().Buffer; buf != wantBuf {
+			t.Errorf("buf = %v, want %v", buf, wantBuf)
+		}
+	}
+
+	// Changing the buffer starts a new completion.
+	f.SetCodeBuffer(tk.CodeBuffer{Content: "ls /so", Dot: 6})
+	evals(f.Evaler, "edit:complete-history-word")
+	wantBuf := tk.CodeBuffer{Content: "ls /some/long/path", Dot: 18}
+	if buf := codeArea(f.Editor.app).CopyState().Buffer; buf != wantBuf {
+		t.Errorf("buf = %v, want %v", buf, wantBuf)
+	}
+
+	// Nothing happens without a prefix or candidates.
+	for _, buf := range []tk.CodeBuffer{{Content: "ls ", Dot: 3}, {Content: "xyz", Dot: 3}} {
+		f.SetCodeBuffer(buf)
+		evals(f.Evaler, "edit:complete-history-word")
+		if got := codeArea(f.Editor.app).CopyState().Buffer; got != buf {
+			t.Errorf("buf = %v, want %v", got, buf)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package edit
+
+// Implementation of the vi layer of the command and visual modes: counts,
+// operators, and the motions that work with them.
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/modes"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/strutil"
+	"src.elv.sh/pkg/wcwidth"
+)
+
+func initVi(ed *Editor, ev *eval.Evaler, tty cli.TTY, nb eval.NsBuilder) {
+	bindingVar := newBindingVar(emptyBindingsMap)
+	bindings := viBindings{ed, tty, newMapBindings(ed, ev, bindingVar)}
+	nb.AddNs("visual",
+		eval.BuildNsNamed("edit:visual").
+			AddVar("binding", bindingVar).
+			AddGoFns(map[string]any{
+				"start": func() {
+					w, err := modes.NewSelection(ed.app,
+						modes.SelectionSpec{Bindings: bindings, Name: " VISUAL "})
+					ed.vi.reset()
+					ed.startMode("visual", w, err)
+				},
+			}))
+
+	motion := func(m viMover) func() error {
+		return func() error {
+			n, op := ed.vi.takeCount()
+			return ed.viMove(tty, n, op, m)
+		}
+	}
+	nb.AddNs("vi",
+		eval.BuildNsNamed("edit:vi").
+			AddGoFns(map[string]any{
+				"delete": func() error { return ed.viOperate(tty, viDelete) },
+				"change": func() error { return ed.viOperate(tty, viChange) },
+				"yank":   func() error { return ed.viOperate(tty, viYank) },
+
+				"word-right":     motion(viWordRight(tk.CategorizeSmallWord)),
+				"word-left":      motion(repeatMover(moveDotLeftSmallWord)),
+				"big-word-right": motion(viWordRight(categorizeWord)),
+				"big-word-left":  motion(repeatMover(moveDotLeftWord)),
+
+				"find-right": func() { ed.viFind(tty, viFind{forward: true}) },
+				"find-left":  func() { ed.viFind(tty, viFind{}) },
+				"till-right": func() { ed.viFind(tty, viFind{forward: true, till: true}) },
+				"till-left":  func() { ed.viFind(tty, viFind{till: true}) },
+				"repeat-find": func() error {
+					return ed.viRepeatFind(tty, false)
+				},
+				"repeat-find-reverse": func() error {
+					return ed.viRepeatFind(tty, true)
+				},
+
+				"cancel": ed.vi.reset,
+			}))
+}
+
+type viOperator int
+
+const (
+	viNoOperator viOperator = iota
+	viDelete
+	viChange
+	viYank
+)
+
+// A motion of the find family, like f or T.
+type viFind struct {
+	r       rune
+	forward bool
+	// Whether to stop before the character, like t and T.
+	till bool
+}
+
+// State of the vi layer, shared by the command and visual modes.
+type viState struct {
+	mutex sync.Mutex
+	// The count typed before the current command, or 0 if there is none.
+	count int
+	// Whether the current command has used the count. Bindings that don't use
+	// it are repeated instead.
+	counted bool
+	// The pending operator, and the count typed before it.
+	op      viOperator
+	opCount int
+	// If not nil, the next key is read as a character and passed to this
+	// function, for motions like f.
+	readChar func(rune)
+	// The last motion of the find family, repeated by edit:vi:repeat-find.
+	lastFind viFind
+}
+
+func (s *viState) reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.count, s.counted = 0, false
+	s.op, s.opCount = viNoOperator, 0
+	s.readChar = nil
+}
+
+// Returns the count of the current command, which multiplies the counts typed
+// before the operator and the motion, and the pending operator, which is no
+// longer pending afterwards.
+func (s *viState) takeCount() (int, viOperator) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n := max1(s.count) * max1(s.opCount)
+	op := s.op
+	s.count, s.counted = 0, true
+	s.op, s.opCount = viNoOperator, 0
+	return n, op
+}
+
+func max1(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// Bindings of the command and visual modes. Counts and the characters read by
+// motions like f are handled here; other keys are handled by the inner
+// bindings.
+type viBindings struct {
+	ed    *Editor
+	tty   cli.TTY
+	inner tk.Bindings
+}
+
+func (b viBindings) Handle(w tk.Widget, e term.Event) bool {
+	k, ok := e.(term.KeyEvent)
+	if !ok {
+		return b.inner.Handle(w, e)
+	}
+	s := &b.ed.vi
+
+	s.mutex.Lock()
+	readChar := s.readChar
+	s.readChar = nil
+	if readChar != nil {
+		s.mutex.Unlock()
+		if k.Mod == 0 && unicode.IsPrint(k.Rune) {
+			readChar(k.Rune)
+		} else {
+			// Other keys, like Escape, cancel the motion.
+			s.reset()
+		}
+		return true
+	}
+	if k.Mod == 0 && ('1' <= k.Rune && k.Rune <= '9' || k.Rune == '0' && s.count > 0) {
+		s.count = s.count*10 + int(k.Rune-'0')
+		s.mutex.Unlock()
+		return true
+	}
+	n := max1(s.count) * max1(s.opCount)
+	op := s.op
+	s.counted = false
+	s.mutex.Unlock()
+
+	codeArea, err := modes.FocusedCodeArea(b.ed.app)
+	if err != nil {
+		s.reset()
+		return b.inner.Handle(w, e)
+	}
+	mode := b.ed.activeMode()
+	before := codeArea.CopyState().Buffer
+	if !b.inner.Handle(w, e) {
+		s.reset()
+		return false
+	}
+
+	s.mutex.Lock()
+	counted := s.counted
+	s.count = 0
+	s.mutex.Unlock()
+	if counted {
+		return true
+	}
+	// The binding is not part of the vi layer, like edit:move-dot-left. Repeat
+	// it by the count, and apply the pending operator to the text that the dot
+	// has moved over.
+	for i := 1; i < n && b.ed.activeMode() == mode; i++ {
+		b.inner.Handle(w, e)
+	}
+	if op != viNoOperator {
+		_, pending := s.takeCount()
+		after := codeArea.CopyState().Buffer
+		if pending == op && b.ed.activeMode() == mode &&
+			after.Content == before.Content && after.Dot != before.Dot {
+			from, to := before.Dot, after.Dot
+			if from > to {
+				from, to = to, from
+			}
+			err := b.ed.viApply(b.tty, op, codeArea, from, to)
+			if err != nil {
+				b.ed.notifyError("binding", err)
+			}
+		}
+	}
+	return true
+}
+
+// Implements the operators, which work on the selection in the visual mode.
+// Otherwise, they are applied to the text moved over by the following motion,
+// or to whole lines when repeated, like dd.
+func (ed *Editor) viOperate(tty cli.TTY, op viOperator) error {
+	codeArea, ok := focusedCodeArea(ed.app)
+	if !ok {
+		return nil
+	}
+	if ed.activeMode() == "visual" {
+		ed.vi.takeCount()
+		state := codeArea.CopyState()
+		from, to := state.SelectedRange()
+		ed.app.PopAddon()
+		return ed.viApply(tty, op, codeArea, from, to)
+	}
+
+	s := &ed.vi
+	s.mutex.Lock()
+	switch s.op {
+	case viNoOperator:
+		s.op, s.opCount = op, s.count
+		s.count, s.counted = 0, true
+		s.mutex.Unlock()
+		return nil
+	case op:
+		s.mutex.Unlock()
+		n, _ := s.takeCount()
+		buf := codeArea.CopyState().Buffer
+		from, to := viLines(buf.Content, buf.Dot, n, op == viDelete)
+		return ed.viApply(tty, op, codeArea, from, to)
+	default:
+		// Different operators, like dy, cancel each other.
+		s.mutex.Unlock()
+		s.takeCount()
+		return nil
+	}
+}
+
+// Returns the range of n lines starting from the line of the dot. If
+// withNewline is true, the range includes the newline after the last line, or
+// the one before the first line if the last line is the last in the buffer.
+func viLines(buffer string, dot, n int, withNewline bool) (from, to int) {
+	from = strutil.FindLastSOL(buffer[:dot])
+	to = from
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			if to == len(buffer) {
+				break
+			}
+			to++
+		}
+		to += strutil.FindFirstEOL(buffer[to:])
+	}
+	if withNewline {
+		if to < len(buffer) {
+			to++
+		} else if from > 0 {
+			from--
+		}
+	}
+	return from, to
+}
+
+// Applies the operator to the text between from and to.
+func (ed *Editor) viApply(tty cli.TTY, op viOperator, codeArea tk.CodeArea, from, to int) error {
+	var text string
+	codeArea.MutateState(func(s *tk.CodeAreaState) {
+		text = s.Buffer.Content[from:to]
+		if op != viYank {
+			s.Buffer.Content = s.Buffer.Content[:from] + s.Buffer.Content[to:]
+		}
+		s.Buffer.Dot = from
+	})
+	switch op {
+	case viYank:
+		return term.CopyToClipboard(tty, text)
+	case viChange:
+		// Return to the insert mode.
+		for m := ed.activeMode(); m == "command" || m == "visual"; m = ed.activeMode() {
+			ed.app.PopAddon()
+		}
+	}
+	return nil
+}
+
+// A function that finds where a motion repeated n times moves the dot to.
+// The text under the target is part of the motion when inclusive is true, and
+// the motion fails if ok is false.
+type viMover func(buffer string, dot, n int, op viOperator) (target int, inclusive, ok bool)
+
+// Moves the dot, or applies the pending operator to the text moved over.
+func (ed *Editor) viMove(tty cli.TTY, n int, op viOperator, m viMover) error {
+	codeArea, ok := focusedCodeArea(ed.app)
+	if !ok {
+		return nil
+	}
+	buf := codeArea.CopyState().Buffer
+	target, inclusive, ok := m(buf.Content, buf.Dot, n, op)
+	if !ok {
+		ed.app.Bell()
+		return nil
+	}
+	if op == viNoOperator {
+		codeArea.MutateState(func(s *tk.CodeAreaState) { s.Buffer.Dot = target })
+		return nil
+	}
+	from, to := buf.Dot, target
+	if from > to {
+		from, to = to, from
+	} else if inclusive {
+		to += wcwidth.ClusterLen(buf.Content[to:])
+	}
+	return ed.viApply(tty, op, codeArea, from, to)
+}
+
+func repeatMover(m pureMover) viMover {
+	return func(buffer string, dot, n int, _ viOperator) (int, bool, bool) {
+		for i := 0; i < n; i++ {
+			dot = m(buffer, dot)
+		}
+		return dot, false, true
+	}
+}
+
+// Returns the mover of w or W. Like in vi, cw changes to the end of the word
+// instead of the start of the next one when the dot is in a word.
+func viWordRight(categorize categorizer) viMover {
+	return func(buffer string, dot, n int, op viOperator) (int, bool, bool) {
+		r, _ := utf8.DecodeRuneInString(buffer[dot:])
+		if op == viChange && dot < len(buffer) && categorize(r) != 0 {
+			pos := dot
+			for i := 0; i < n; i++ {
+				pos = skipWsRight(categorize, buffer, pos)
+				pos = skipSameCatRight(categorize, buffer, pos)
+			}
+			return pos, false, true
+		}
+		for i := 0; i < n; i++ {
+			dot = moveDotRightGeneralWord(categorize, buffer, dot)
+		}
+		return dot, false, true
+	}
+}
+
+// Reads a character and does the motion of the find family with it.
+func (ed *Editor) viFind(tty cli.TTY, f viFind) {
+	n, op := ed.vi.takeCount()
+	ed.vi.mutex.Lock()
+	defer ed.vi.mutex.Unlock()
+	ed.vi.readChar = func(r rune) {
+		f.r = r
+		ed.vi.mutex.Lock()
+		ed.vi.lastFind = f
+		ed.vi.mutex.Unlock()
+		if err := ed.viMove(tty, n, op, findMover(f)); err != nil {
+			ed.notifyError("binding", err)
+		}
+	}
+}
+
+// Repeats the last motion of the find family, in the reverse direction if
+// reverse is true.
+func (ed *Editor) viRepeatFind(tty cli.TTY, reverse bool) error {
+	n, op := ed.vi.takeCount()
+	ed.vi.mutex.Lock()
+	f := ed.vi.lastFind
+	ed.vi.mutex.Unlock()
+	if f.r == 0 {
+		ed.app.Bell()
+		return nil
+	}
+	if reverse {
+		f.forward = !f.forward
+	}
+	return ed.viMove(tty, n, op, findMover(f))
+}
+
+// Returns the mover of a motion of the find family. Like in vi, the character
+// is only looked for in the line of the dot.
+func findMover(f viFind) viMover {
+	return func(buffer string, dot, n int, _ viOperator) (int, bool, bool) {
+		pos := dot
+		if f.forward {
+			eol := dot + strutil.FindFirstEOL(buffer[dot:])
+			for i := 0; i < n; i++ {
+				// Skip the character under the dot, and when repeating t, the
+				// character found last time.
+				start, skip := pos, 1
+				if f.till && i > 0 {
+					skip = 2
+				}
+				for j := 0; j < skip && start < eol; j++ {
+					_, w := utf8.DecodeRuneInString(buffer[start:eol])
+					start += w
+				}
+				k := strings.IndexRune(buffer[start:eol], f.r)
+				if k < 0 {
+					return dot, false, false
+				}
+				pos = start + k
+				if f.till {
+					_, w := utf8.DecodeLastRuneInString(buffer[:pos])
+					pos -= w
+				}
+			}
+			return pos, true, true
+		}
+		sol := strutil.FindLastSOL(buffer[:dot])
+		for i := 0; i < n; i++ {
+			end := pos
+			if f.till && i > 0 {
+				// Skip the character found last time.
+				_, w := utf8.DecodeLastRuneInString(buffer[sol:end])
+				end -= w
+			}
+			k := strings.LastIndex(buffer[sol:end], string(f.r))
+			if k < 0 {
+				return dot, false, false
+			}
+			pos = sol + k
+			if f.till {
+				pos += utf8.RuneLen(f.r)
+			}
+		}
+		return pos, false, true
+	}
+}
@@ -0,0 +1,269 @@
+package edit
+
+import (
+	"reflect"
+	"testing"
+
+	"src.elv.sh/pkg/cli/modes"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/ui"
+)
+
+var viOperatorTests = []struct {
+	name      string
+	buf       tk.CodeBuffer
+	code      string
+	want      tk.CodeBuffer
+	wantMode  string
+	clipboard []string
+}{
+	{
+		name: "dw",
+		buf:  tk.CodeBuffer{Content: "echo foo bar", Dot: 5},
+		code: "edit:vi:delete; edit:vi:word-right",
+		want: tk.CodeBuffer{Content: "echo bar", Dot: 5},
+	},
+	{
+		name:     "cw changes to the end of the word",
+		buf:      tk.CodeBuffer{Content: "echo foo bar", Dot: 5},
+		code:     "edit:vi:change; edit:vi:word-right",
+		want:     tk.CodeBuffer{Content: "echo  bar", Dot: 5},
+		wantMode: "insert",
+	},
+	{
+		name: "db",
+		buf:  tk.CodeBuffer{Content: "echo foo bar", Dot: 8},
+		code: "edit:vi:delete; edit:vi:word-left",
+		want: tk.CodeBuffer{Content: "echo  bar", Dot: 5},
+	},
+	{
+		name: "dW",
+		buf:  tk.CodeBuffer{Content: "echo a.b c", Dot: 5},
+		code: "edit:vi:delete; edit:vi:big-word-right",
+		want: tk.CodeBuffer{Content: "echo c", Dot: 5},
+	},
+	{
+		name: "dd",
+		buf:  tk.CodeBuffer{Content: "echo a\necho b\necho c", Dot: 9},
+		code: "edit:vi:delete; edit:vi:delete",
+		want: tk.CodeBuffer{Content: "echo a\necho c", Dot: 7},
+	},
+	{
+		name: "dd on the last line",
+		buf:  tk.CodeBuffer{Content: "echo a\necho b", Dot: 9},
+		code: "edit:vi:delete; edit:vi:delete",
+		want: tk.CodeBuffer{Content: "echo a", Dot: 6},
+	},
+	{
+		name:      "yy",
+		buf:       tk.CodeBuffer{Content: "echo a\necho b", Dot: 9},
+		code:      "edit:vi:yank; edit:vi:yank",
+		want:      tk.CodeBuffer{Content: "echo a\necho b", Dot: 7},
+		clipboard: []string{"echo b"},
+	},
+	{
+		name: "different operators cancel each other",
+		buf:  tk.CodeBuffer{Content: "echo foo", Dot: 0},
+		code: "edit:vi:delete; edit:vi:yank; edit:vi:word-right",
+		want: tk.CodeBuffer{Content: "echo foo", Dot: 5},
+	},
+	{
+		name: "cancel",
+		buf:  tk.CodeBuffer{Content: "echo foo", Dot: 0},
+		code: "edit:vi:delete; edit:vi:cancel; edit:vi:word-right",
+		want: tk.CodeBuffer{Content: "echo foo", Dot: 5},
+	},
+}
+
+func TestVi_OperatorsAndMotions(t *testing.T) {
+	for _, test := range viOperatorTests {
+		t.Run(test.name, func(t *testing.T) {
+			f := setup(t)
+			f.SetCodeBuffer(test.buf)
+			evals(f.Evaler, "edit:command:start", test.code)
+
+			codeArea, err := modes.FocusedCodeArea(f.Editor.app)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf := codeArea.CopyState().Buffer; buf != test.want {
+				t.Errorf("got buffer %v, want %v", buf, test.want)
+			}
+			wantMode := test.wantMode
+			if wantMode == "" {
+				wantMode = "command"
+			}
+			if mode := f.Editor.activeMode(); mode != wantMode {
+				t.Errorf("got mode %q, want %q", mode, wantMode)
+			}
+			if clipboard := f.TTYCtrl.Clipboard(); !reflect.DeepEqual(clipboard, test.clipboard) {
+				t.Errorf("got clipboard %q, want %q", clipboard, test.clipboard)
+			}
+		})
+	}
+}
+
+func startViCommandMode(t *testing.T, code string) *fixture {
+	f := setup(t)
+	evals(f.Evaler, `set edit:insert:binding[Ctrl-'['] = $edit:command:start~`)
+	feedInput(f.TTYCtrl, code)
+	f.TTYCtrl.Inject(term.K('[', ui.Ctrl), term.K('0'))
+	return f
+}
+
+func TestVi_Counts(t *testing.T) {
+	f := startViCommandMode(t, "echo a b c d")
+
+	feedInput(f.TTYCtrl, "3w")
+	f.TestTTY(t,
+		"~> echo a b ", Styles,
+		"   vvvv     ", term.DotHere, "c d\n",
+		" COMMAND ", Styles,
+		"*********",
+	)
+
+	// The count before the motion applies to bindings that are not part of the
+	// vi layer too, like edit:move-dot-left.
+	feedInput(f.TTYCtrl, "d2h")
+	f.TestTTY(t,
+		"~> echo a ", Styles,
+		"   vvvv   ", term.DotHere, "c d\n",
+		" COMMAND ", Styles,
+		"*********",
+	)
+
+	// Counts before the operator and the motion are multiplied.
+	feedInput(f.TTYCtrl, "0")
+	feedInput(f.TTYCtrl, "2d2w")
+	f.TestTTY(t,
+		"~> ", term.DotHere, "\n",
+		" COMMAND ", Styles,
+		"*********",
+	)
+}
+
+func TestVi_Find(t *testing.T) {
+	f := startViCommandMode(t, "echo foo bar")
+
+	feedInput(f.TTYCtrl, "fo;")
+	f.TestTTY(t,
+		"~> echo f", Styles,
+		"   vvvv  ", term.DotHere, "oo bar\n",
+		" COMMAND ", Styles,
+		"*********",
+	)
+
+	// f and t include the character they stop at in the range of operators.
+	feedInput(f.TTYCtrl, "dtr")
+	f.TestTTY(t,
+		"~> echo f", Styles,
+		"   vvvv  ", term.DotHere, "r\n",
+		" COMMAND ", Styles,
+		"*********",
+	)
+
+	// F and T don't.
+	feedInput(f.TTYCtrl, "dTc")
+	f.TestTTY(t,
+		"~> ec", Styles,
+		"   !!", term.DotHere, "r\n", Styles,
+		"!",
+		" COMMAND ", Styles,
+		"*********",
+	)
+}
+
+func TestVi_FindCanceledByEscape(t *testing.T) {
+	f := startViCommandMode(t, "echo foo")
+
+	f.TTYCtrl.Inject(term.K('f'), term.K('[', ui.Ctrl), term.K('w'))
+	f.TestTTY(t,
+		"~> echo ", Styles,
+		"   vvvv ", term.DotHere, "foo\n",
+		" COMMAND ", Styles,
+		"*********",
+	)
+}
+
+func TestVi_Change(t *testing.T) {
+	f := startViCommandMode(t, "echo foo")
+
+	feedInput(f.TTYCtrl, "cw")
+	f.TestTTY(t,
+		"~> ", term.DotHere, " foo", Styles,
+		" !!!",
+	)
+
+	feedInput(f.TTYCtrl, "put")
+	f.TestTTY(t,
+		"~> put", Styles,
+		"   vvv", term.DotHere, " foo",
+	)
+}
+
+func TestVi_Visual(t *testing.T) {
+	f := startViCommandMode(t, "echo foo bar")
+
+	feedInput(f.TTYCtrl, "wvw")
+	f.TestTTY(t,
+		"~> echo ", Styles,
+		"   vvvv ", "foo ", Styles,
+		"++++", term.DotHere, "bar\n",
+		" VISUAL ", Styles,
+		"********",
+	)
+
+	feedInput(f.TTYCtrl, "d")
+	f.TestTTY(t,
+		"~> echo ", Styles,
+		"   vvvv ", term.DotHere, "bar\n",
+		" COMMAND ", Styles,
+		"*********",
+	)
+
+	feedInput(f.TTYCtrl, "v$")
+	f.TestTTY(t,
+		"~> echo ", Styles,
+		"   vvvv ", "bar", Styles,
+		"+++", term.DotHere, "\n",
+		" VISUAL ", Styles,
+		"********",
+	)
+
+	feedInput(f.TTYCtrl, "y")
+	f.TestTTY(t,
+		"~> echo ", Styles,
+		"   vvvv ", term.DotHere, "bar\n",
+		" COMMAND ", Styles,
+		"*********",
+	)
+	if clipboard := f.TTYCtrl.Clipboard(); !reflect.DeepEqual(clipboard, []string{"bar"}) {
+		t.Errorf("got clipboard %q, want %q", clipboard, []string{"bar"})
+	}
+}
+
+func TestViLines(t *testing.T) {
+	tests := []struct {
+		buffer      string
+		dot, n      int
+		withNewline bool
+		from, to    int
+	}{
+		{"a\nb\nc", 0, 1, false, 0, 1},
+		{"a\nb\nc", 0, 1, true, 0, 2},
+		{"a\nb\nc", 2, 2, true, 1, 5},
+		{"a\nb\nc", 2, 2, false, 2, 5},
+		// More lines than there are.
+		{"a\nb\nc", 2, 5, true, 1, 5},
+		{"a", 0, 1, true, 0, 1},
+	}
+	for _, test := range tests {
+		from, to := viLines(test.buffer, test.dot, test.n, test.withNewline)
+		if from != test.from || to != test.to {
+			t.Errorf("viLines(%q, %v, %v, %v) -> (%v, %v), want (%v, %v)",
+				test.buffer, test.dot, test.n, test.withNewline,
+				from, to, test.from, test.to)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Package elvfmt implements a formatter for Elvish source code, and the -fmt
+// subprogram that uses it.
+package elvfmt
+
+import (
+	"strings"
+
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/parse/parseutil"
+)
+
+// The unit of indentation for each level of nesting.
+const indentUnit = "  "
+
+// Format formats Elvish source code, returning an error if it can't be parsed.
+//
+// Formatting only changes whitespace outside string literals and comments:
+//
+//   - Each line is indented by its level of nesting: lines inside brackets are
+//     one level deeper than the line of the opening bracket, lines that
+//     continue a pipeline or a command from a previous line are one more level
+//     deeper, and lines starting with a closing bracket are as deep as the line
+//     of the opening bracket.
+//
+//   - Runs of whitespace within a line are replaced by a single space, and
+//     whitespace at the end of lines is removed. As an exception, the values
+//     of map pairs on consecutive lines, each starting its own line and having
+//     its value on the same line, are aligned.
+//
+//   - Runs of blank lines are replaced by a single blank line, and blank lines
+//     at the start and end are removed. Non-empty output always ends with a
+//     newline.
+func Format(name, code string) (string, error) {
+	tree, err := parse.Parse(parse.Source{Name: name, Code: code}, parse.Config{})
+	if err != nil {
+		return "", err
+	}
+	f := &formatter{code, tree.Root, verbatimMask(tree.Root, len(code)),
+		alignments(tree.Root, code), map[int]string{}}
+	return f.format(), nil
+}
+
+type formatter struct {
+	code     string
+	root     parse.Node
+	verbatim []bool
+	// Numbers of spaces to write before aligned map pair values, keyed by
+	// their positions.
+	align map[int]int
+	// Indentations of lines, keyed by the position of their first
+	// non-whitespace character.
+	indents map[int]string
+}
+
+// Returns a mask of bytes that are part of string literals and comments.
+func verbatimMask(root parse.Node, n int) []bool {
+	mask := make([]bool, n)
+	var walk func(n parse.Node)
+	walk = func(n parse.Node) {
+		switch n := n.(type) {
+		case *parse.Primary:
+			if n.Type == parse.SingleQuoted || n.Type == parse.DoubleQuoted {
+				for i := n.Range().From; i < n.Range().To; i++ {
+					mask[i] = true
+				}
+				return
+			}
+		case *parse.Sep:
+			// Comments are part of separators.
+			rg := n.Range()
+			text := parse.SourceText(n)
+			for i := 0; i < len(text); i++ {
+				if text[i] == '#' {
+					end := i + strings.IndexAny(text[i:]+"\n", "\r\n")
+					// Trailing whitespace is not part of the comment.
+					end = i + len(strings.TrimRight(text[i:end], " \t"))
+					for ; i < end; i++ {
+						mask[rg.From+i] = true
+					}
+				}
+			}
+			return
+		}
+		for _, ch := range parse.Children(n) {
+			walk(ch)
+		}
+	}
+	walk(root)
+	return mask
+}
+
+// Returns the numbers of spaces to write before the values of map pairs that
+// are aligned, keyed by the positions of the values.
+func alignments(root parse.Node, code string) map[int]int {
+	align := map[int]int{}
+	// A block of map pairs on consecutive lines.
+	var block []*parse.MapPair
+	flush := func() {
+		width := 0
+		for _, pair := range block {
+			if w := keyWidth(pair); w > width {
+				width = w
+			}
+		}
+		for _, pair := range block {
+			align[pair.Value.Range().From] = width - keyWidth(pair) + 1
+		}
+		block = block[:0]
+	}
+	var walk func(n parse.Node)
+	walk = func(n parse.Node) {
+		if pn, ok := n.(*parse.Primary); ok && pn.Type == parse.Map {
+			for _, pair := range pn.MapPairs {
+				if !alignable(code, pair) {
+					flush()
+					continue
+				}
+				if len(block) > 0 &&
+					lineBreaks(code[block[len(block)-1].Range().From:pair.Range().From]) != 1 {
+					flush()
+				}
+				block = append(block, pair)
+			}
+			flush()
+		}
+		for _, ch := range parse.Children(n) {
+			walk(ch)
+		}
+	}
+	walk(root)
+	return align
+}
+
+// Returns whether a map pair starts its own line and has a value on the same
+// line.
+func alignable(code string, pair *parse.MapPair) bool {
+	from := pair.Range().From
+	if strings.Trim(code[lineStart(code, from):from], " \t") != "" ||
+		pair.Value == nil || pair.Value.Range().From == pair.Value.Range().To {
+		return false
+	}
+	sep := code[from+keyWidth(pair) : pair.Value.Range().From]
+	return strings.Trim(sep, " \t") == ""
+}
+
+// Returns the width of the key of a map pair, including the "&" and "=".
+func keyWidth(pair *parse.MapPair) int {
+	return pair.Key.Range().To + len("=") - pair.Range().From
+}
+
+func (f *formatter) format() string {
+	var sb strings.Builder
+	atLineStart, pendingSpace, blankLines := true, false, 0
+	for i := 0; i < len(f.code); i++ {
+		c := f.code[i]
+		switch {
+		case !f.verbatim[i] && c == '\r' && i+1 < len(f.code) && f.code[i+1] == '\n':
+			// Turn CRLF into LF.
+		case !f.verbatim[i] && (c == '\n' || c == '\r'):
+			if atLineStart {
+				blankLines++
+			} else {
+				sb.WriteByte('\n')
+			}
+			atLineStart, pendingSpace = true, false
+		case !f.verbatim[i] && (c == ' ' || c == '\t'):
+			pendingSpace = !atLineStart
+		default:
+			if atLineStart {
+				if blankLines > 0 && sb.Len() > 0 {
+					sb.WriteByte('\n')
+				}
+				sb.WriteString(f.indentAt(i))
+				atLineStart, blankLines = false, 0
+			} else if n, ok := f.align[i]; ok {
+				sb.WriteString(strings.Repeat(" ", n))
+			} else if pendingSpace {
+				sb.WriteByte(' ')
+			}
+			pendingSpace = false
+			sb.WriteByte(c)
+		}
+	}
+	if !atLineStart {
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// Returns the indentation of the line whose first non-whitespace character is
+// at pos.
+func (f *formatter) indentAt(pos int) string {
+	if indent, ok := f.indents[pos]; ok {
+		return indent
+	}
+	var indent string
+	if pn := parseutil.BracketClosedAt(f.root, pos); pn != nil {
+		indent = f.lineIndent(pn.Range().From)
+	} else {
+		bracket := parseutil.OpenBracketAt(f.root, pos)
+		if bracket != nil {
+			indent = f.lineIndent(bracket.Range().From) + indentUnit
+		}
+		// A pipeline within the innermost bracket that started on a previous
+		// line is being continued.
+		pn := parseutil.PipelineAt(f.root, pos)
+		if pn != nil && (bracket == nil || pn.Range().From > bracket.Range().From) &&
+			pn.Range().From < lineStart(f.code, pos) {
+			indent += indentUnit
+		}
+	}
+	f.indents[pos] = indent
+	return indent
+}
+
+// Returns the indentation of the line that contains pos. A line that starts
+// inside a string literal has the same indentation as the line where the
+// string literal starts.
+func (f *formatter) lineIndent(pos int) string {
+	start := lineStart(f.code, pos)
+	if start > 0 && f.verbatim[start-1] {
+		for start > 0 && f.verbatim[start-1] {
+			start--
+		}
+		return f.lineIndent(start)
+	}
+	first := start + len(f.code[start:]) - len(strings.TrimLeft(f.code[start:], " \t"))
+	return f.indentAt(first)
+}
+
+func lineBreaks(s string) int {
+	return strings.Count(s, "\n") + strings.Count(s, "\r") - strings.Count(s, "\r\n")
+}
+
+func lineStart(code string, pos int) int {
+	return strings.LastIndexAny(code[:pos], "\r\n") + 1
+}
@@ -0,0 +1,71 @@
+package elvfmt
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/tt"
+)
+
+var Args = tt.Args
+
+func TestFormat(t *testing.T) {
+	tt.Test(t, tt.Fn("Format", Format).ArgsFmt("(%q, %q)"), tt.Table{
+		Args("a.elv", "").Rets("", nil),
+		Args("a.elv", "echo foo").Rets("echo foo\n", nil),
+
+		// Whitespace within lines is collapsed, and trailing whitespace is
+		// removed, including before comments and CRs of CRLF.
+		Args("a.elv", "echo  foo\tbar  \r\n").Rets("echo foo bar\n", nil),
+		Args("a.elv", "echo foo   # comment  \n").Rets("echo foo # comment\n", nil),
+		// Whitespace in string literals and comments is kept.
+		Args("a.elv", "echo 'a  b' \"c\td\" # e  f\n").
+			Rets("echo 'a  b' \"c\td\" # e  f\n", nil),
+		// A lone CR separates pipelines like LF, so it becomes LF.
+		Args("a.elv", "echo a\recho b").Rets("echo a\necho b\n", nil),
+
+		// Blank lines are collapsed, and trimmed at the start and end.
+		Args("a.elv", "\n\necho a\n\n\n\necho b\n\n").Rets("echo a\n\necho b\n", nil),
+
+		// Indentation by nesting.
+		Args("a.elv", "fn f {|x|\nif $x {\n        put [\n a\n    ]\n}\n}\n").Rets(
+			"fn f {|x|\n"+
+				"  if $x {\n"+
+				"    put [\n"+
+				"      a\n"+
+				"    ]\n"+
+				"  }\n"+
+				"}\n", nil),
+		// Closing brackets are as deep as the line of the opening bracket,
+		// even when they follow other code.
+		Args("a.elv", "if $x {\necho a\n} else {\necho b }\n").Rets(
+			"if $x {\n  echo a\n} else {\n  echo b }\n", nil),
+		// Continued pipelines and commands.
+		Args("a.elv", "echo a |\neach {|x|\nput $x\n}\necho ^\nb\n").Rets(
+			"echo a |\n"+
+				"  each {|x|\n"+
+				"    put $x\n"+
+				"  }\n"+
+				"echo ^\n"+
+				"  b\n", nil),
+		// Lines inside multi-line strings are kept, and don't affect the
+		// indentation of lines after them.
+		Args("a.elv", "{\necho 'a\n  b'\necho c\n}\n").Rets(
+			"{\n  echo 'a\n  b'\n  echo c\n}\n", nil),
+		// Comments are indented like code.
+		Args("a.elv", "{\n    # comment\necho\n}\n").Rets("{\n  # comment\n  echo\n}\n", nil),
+
+		// Values of map pairs on their own consecutive lines are aligned.
+		Args("a.elv", "var m = [\n&a=  x\n&foo=y\n\n&bar= z\n&b=\n  w\n&c=[&k= v] &dd=v\n]\n").Rets(
+			"var m = [\n"+
+				"  &a=   x\n"+
+				"  &foo= y\n"+
+				"\n"+
+				"  &bar= z\n"+
+				"  &b=\n"+
+				"  w\n"+
+				"  &c= [&k= v] &dd=v\n"+
+				"]\n", nil),
+
+		Args("a.elv", "echo (").Rets("", tt.Any),
+	})
+}
@@ -0,0 +1,78 @@
+package elvfmt
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"src.elv.sh/pkg/diff"
+	"src.elv.sh/pkg/prog"
+)
+
+// Program is the -fmt subprogram.
+type Program struct {
+	run       bool
+	overwrite bool
+	showDiff  bool
+}
+
+func (p *Program) RegisterFlags(fs *prog.FlagSet) {
+	fs.BoolVar(&p.run, "fmt", false,
+		"Format Elvish sources in the given files, or stdin if none is given")
+	fs.BoolVar(&p.overwrite, "w", false,
+		"Write the result of -fmt to the source files instead of stdout")
+	fs.BoolVar(&p.showDiff, "d", false,
+		"Show the result of -fmt as diffs instead of the formatted sources")
+}
+
+func (p *Program) Run(fds [3]*os.File, files []string) error {
+	if !p.run {
+		if p.overwrite || p.showDiff {
+			return prog.BadUsage("-w and -d can only be used with -fmt")
+		}
+		return prog.NextProgram()
+	}
+	if len(files) == 0 {
+		if p.overwrite {
+			return prog.BadUsage("-w requires files to format")
+		}
+		code, err := io.ReadAll(fds[0])
+		if err != nil {
+			return fmt.Errorf("read stdin: %w", err)
+		}
+		return p.format(fds, "[stdin]", string(code))
+	}
+	var failed bool
+	for _, file := range files {
+		code, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(fds[2], err)
+			failed = true
+			continue
+		}
+		if err := p.format(fds, file, string(code)); err != nil {
+			fmt.Fprintln(fds[2], err)
+			failed = true
+		}
+	}
+	if failed {
+		return prog.Exit(2)
+	}
+	return nil
+}
+
+func (p *Program) format(fds [3]*os.File, name, code string) error {
+	formatted, err := Format(name, code)
+	if err != nil {
+		return err
+	}
+	if p.showDiff {
+		fds[1].Write(diff.Diff(name+".orig", []byte(code), name, []byte(formatted)))
+	} else if !p.overwrite {
+		fds[1].WriteString(formatted)
+	}
+	if p.overwrite && formatted != code {
+		return os.WriteFile(name, []byte(formatted), 0o644)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package elvfmt
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/prog/progtest"
+	"src.elv.sh/pkg/testutil"
+)
+
+var ThatElvish = progtest.ThatElvish
+
+func TestProgram(t *testing.T) {
+	testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{
+		"a.elv":   "echo  a\n",
+		"b.elv":   "echo b\n",
+		"bad.elv": "echo (",
+	})
+
+	progtest.Test(t, &Program{},
+		ThatElvish("-fmt").WithStdin("echo  foo").WritesStdout("echo foo\n"),
+		ThatElvish("-fmt", "a.elv", "b.elv").WritesStdout("echo a\necho b\n"),
+		ThatElvish("-fmt", "-d", "a.elv", "b.elv").WritesStdout(
+			"diff a.elv.orig a.elv\n"+
+				"--- a.elv.orig\n"+
+				"+++ a.elv\n"+
+				"@@ -1,1 +1,1 @@\n"+
+				"-echo  a\n"+
+				"+echo a\n"),
+
+		ThatElvish("-fmt", "bad.elv", "b.elv").
+			ExitsWith(2).
+			WritesStdout("echo b\n").
+			WritesStderrContaining("parse error"),
+		ThatElvish("-fmt", "nonexistent.elv").
+			ExitsWith(2).
+			WritesStderrContaining("nonexistent.elv"),
+		ThatElvish("-fmt", "-w").
+			ExitsWith(2).
+			WritesStderrContaining("-w requires files to format"),
+		ThatElvish("-w", "a.elv").
+			ExitsWith(2).
+			WritesStderrContaining("-w and -d can only be used with -fmt"),
+		ThatElvish("-d", "a.elv").
+			ExitsWith(2).
+			WritesStderrContaining("-w and -d can only be used with -fmt"),
+
+		ThatElvish().ExitsWith(2).WritesStderr("internal error: no suitable subprogram\n"),
+	)
+
+	progtest.Test(t, &Program{},
+		ThatElvish("-fmt", "-w", "a.elv", "b.elv").DoesNothing())
+	if got := must.ReadFileString("a.elv"); got != "echo a\n" {
+		t.Errorf("got a.elv %q after -w, want %q", got, "echo a\n")
+	}
+}
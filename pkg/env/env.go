@@ -9,6 +9,7 @@ const (
 	PATH      = "PATH"
 	PWD       = "PWD"
 	SHLVL     = "SHLVL"
+	TERM      = "TERM"
 	USERNAME  = "USERNAME"
 
 	// Only used on Unix
@@ -19,6 +20,7 @@ const (
 	XDG_STATE_HOME  = "XDG_STATE_HOME"
 
 	// Only used on Windows
+	COMSPEC = "COMSPEC"
 	PATHEXT = "PATHEXT"
 
 	// Only used in tests
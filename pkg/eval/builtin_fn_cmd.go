@@ -1,10 +1,16 @@
 package eval
 
 import (
+	"fmt"
+	"math/big"
 	"os"
 	"os/exec"
+	"strconv"
+	"strings"
 
 	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
 )
 
 // Command and process control.
@@ -14,14 +20,18 @@ import (
 func init() {
 	addBuiltinFns(map[string]any{
 		// Command resolution
-		"external":        external,
-		"has-external":    hasExternal,
-		"search-external": searchExternal,
+		"external":             external,
+		"has-external":         hasExternal,
+		"search-external":      searchExternal,
+		"clear-external-cache": clearExternalCache,
+		"argv":                 argv,
 
 		// Process control
-		"fg":   fg,
-		"exec": execFn,
-		"exit": exit,
+		"fg":       fg,
+		"jobs":     jobs,
+		"kill-job": killJob,
+		"exec":     execFn,
+		"exit":     exit,
 	})
 }
 
@@ -38,6 +48,187 @@ func searchExternal(cmd string) (string, error) {
 	return exec.LookPath(cmd)
 }
 
+type argvOpts struct {
+	Run    bool
+	DryRun bool
+}
+
+func (*argvOpts) SetDefaultOptions() {}
+
+func argv(fm *Frame, opts argvOpts, template, data any) error {
+	words, err := templateWords(template)
+	if err != nil {
+		return err
+	}
+	var args []string
+	for _, word := range words {
+		wordArgs, err := expandTemplateWord(word, data)
+		if err != nil {
+			return err
+		}
+		args = append(args, wordArgs...)
+	}
+
+	if opts.DryRun {
+		_, err := fmt.Fprintln(fm.ByteOutput(), quoteCommand(args))
+		return err
+	}
+	if !opts.Run {
+		list := vals.EmptyList
+		for _, arg := range args {
+			list = list.Conj(arg)
+		}
+		return fm.ValueOutput().Put(list)
+	}
+	if len(args) == 0 {
+		return errs.BadValue{What: "template", Valid: "non-empty", Actual: "empty"}
+	}
+	callArgs := make([]any, len(args)-1)
+	for i, arg := range args[1:] {
+		callArgs[i] = arg
+	}
+	return NewExternalCmd(args[0]).Call(fm.Fork("argv"), callArgs, NoOpts)
+}
+
+// Returns the words of an argv template, which is either a string to be split
+// at whitespaces, or a list of strings.
+func templateWords(template any) ([]string, error) {
+	if s, ok := template.(string); ok {
+		return strings.Fields(s), nil
+	}
+	if _, ok := template.(vals.List); !ok {
+		return nil, errs.BadValue{What: "template",
+			Valid: "string or list", Actual: vals.Kind(template)}
+	}
+	var words []string
+	var errWord error
+	errIterate := vals.Iterate(template, func(v any) bool {
+		s, ok := v.(string)
+		if !ok {
+			errWord = errs.BadValue{What: "word in template",
+				Valid: "string", Actual: vals.Kind(v)}
+			return false
+		}
+		words = append(words, s)
+		return true
+	})
+	if errIterate != nil {
+		return nil, errIterate
+	}
+	return words, errWord
+}
+
+// Expands one word of an argv template. A word that consists of just {@name}
+// expands to all the elements of the list at the key name of data; otherwise
+// the word expands to exactly one argument, with each {name} replaced by the
+// string or number at the key name of data. Literal braces are written as {{
+// and }}.
+func expandTemplateWord(word string, data any) ([]string, error) {
+	if strings.HasPrefix(word, "{@") && strings.HasSuffix(word, "}") &&
+		strings.IndexAny(word[2:len(word)-1], "{}") == -1 {
+		name := word[2 : len(word)-1]
+		value, err := vals.Index(data, name)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := value.(vals.List); !ok {
+			return nil, errs.BadValue{What: "value of {@" + name + "}",
+				Valid: "list", Actual: vals.Kind(value)}
+		}
+		var args []string
+		var errElem error
+		vals.Iterate(value, func(v any) bool {
+			var arg string
+			arg, errElem = templateArg("element of {@"+name+"}", v)
+			args = append(args, arg)
+			return errElem == nil
+		})
+		return args, errElem
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(word); i++ {
+		switch {
+		case strings.HasPrefix(word[i:], "{{"):
+			sb.WriteByte('{')
+			i++
+		case strings.HasPrefix(word[i:], "}}"):
+			sb.WriteByte('}')
+			i++
+		case word[i] == '{':
+			j := strings.IndexAny(word[i+1:], "{}")
+			if j == -1 || word[i+1+j] != '}' || j == 0 {
+				return nil, fmt.Errorf("bad placeholder in template word %s", parse.Quote(word))
+			}
+			name := word[i+1 : i+1+j]
+			if name[0] == '@' {
+				return nil, fmt.Errorf("{%s} must be a whole word in template word %s",
+					name, parse.Quote(word))
+			}
+			value, err := vals.Index(data, name)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := value.(vals.List); ok {
+				return nil, fmt.Errorf(
+					"value of {%s} is a list; use {@%s} to expand it into several arguments",
+					name, name)
+			}
+			arg, err := templateArg("value of {"+name+"}", value)
+			if err != nil {
+				return nil, err
+			}
+			sb.WriteString(arg)
+			i += 1 + j
+		case word[i] == '}':
+			return nil, fmt.Errorf("unmatched } in template word %s", parse.Quote(word))
+		default:
+			sb.WriteByte(word[i])
+		}
+	}
+	return []string{sb.String()}, nil
+}
+
+func templateArg(what string, v any) (string, error) {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case int, *big.Int, *big.Rat, float64:
+		s = vals.ToString(v)
+	default:
+		return "", errs.BadValue{What: what, Valid: "string or number", Actual: vals.Kind(v)}
+	}
+	if strings.ContainsRune(s, 0) {
+		return "", errs.BadValue{What: what, Valid: "string without NUL", Actual: parse.Quote(s)}
+	}
+	return s, nil
+}
+
+func jobs(fm *Frame) error {
+	out := fm.ValueOutput()
+	for _, j := range fm.Evaler.getBgJobs() {
+		err := out.Put(j.info())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type killJobOpts struct{ Tree bool }
+
+func (*killJobOpts) SetDefaultOptions() {}
+
+func killJob(fm *Frame, opts killJobOpts, id int) error {
+	j := fm.Evaler.getBgJob(id)
+	if j == nil {
+		return errs.BadValue{What: "job ID",
+			Valid: "ID of a background job", Actual: strconv.Itoa(id)}
+	}
+	return j.kill(opts.Tree)
+}
+
 // Can be overridden in tests.
 var osExit = os.Exit
 
@@ -7,9 +7,55 @@ import (
 	. "src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/eval/errs"
 	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/testutil"
 )
 
+func TestArgv(t *testing.T) {
+	Test(t,
+		That("argv 'cmd {a} -x={b} {{{a}}}' [&a='foo bar' &b=(num 10)]").
+			Puts(vals.MakeList("cmd", "foo bar", "-x=10", "{foo bar}")),
+		That("argv [cmd 'a b' '{a}'] [&a=x]").Puts(vals.MakeList("cmd", "a b", "x")),
+		That("argv 'cmd {@a} {@b}' [&a=[x 'y z'] &b=[]]").
+			Puts(vals.MakeList("cmd", "x", "y z")),
+		That("argv '' [&]").Puts(vals.EmptyList),
+		// Dry run.
+		That("argv &dry-run 'cmd {a} {@b}' [&a='foo bar' &b=['$x' y]]").
+			Prints("cmd 'foo bar' '$x' y\n"),
+
+		// Bad templates.
+		That("argv [cmd (num 1)] [&]").Throws(
+			errs.BadValue{What: "word in template", Valid: "string", Actual: "number"}),
+		That("argv [&] [&]").Throws(
+			errs.BadValue{What: "template", Valid: "string or list", Actual: "map"}),
+		That("argv 'cmd {a' [&a=x]").Throws(
+			ErrorWithMessage("bad placeholder in template word '{a'")),
+		That("argv 'cmd {}' [&]").Throws(
+			ErrorWithMessage("bad placeholder in template word '{}'")),
+		That("argv 'cmd a}' [&]").Throws(
+			ErrorWithMessage("unmatched } in template word 'a}'")),
+		That("argv 'cmd x{@a}' [&a=[]]").Throws(
+			ErrorWithMessage("{@a} must be a whole word in template word 'x{@a}'")),
+
+		// Bad data.
+		That("argv 'cmd {a}' [&]").Throws(vals.NoSuchKey("a")),
+		That("argv 'cmd {a}' [&a=[x y]]").Throws(ErrorWithMessage(
+			"value of {a} is a list; use {@a} to expand it into several arguments")),
+		That("argv 'cmd {a}' [&a=[&]]").Throws(
+			errs.BadValue{What: "value of {a}", Valid: "string or number", Actual: "map"}),
+		That("argv 'cmd {@a}' [&a=x]").Throws(
+			errs.BadValue{What: "value of {@a}", Valid: "list", Actual: "string"}),
+		That("argv 'cmd {@a}' [&a=[[]]]").Throws(
+			errs.BadValue{What: "element of {@a}", Valid: "string or number", Actual: "list"}),
+		That("argv 'cmd {a}' [&a=\"a\\x00b\"]").Throws(
+			errs.BadValue{What: "value of {a}", Valid: "string without NUL", Actual: `"a\x00b"`}),
+
+		// Running needs a command.
+		That("argv &run '' [&]").Throws(
+			errs.BadValue{What: "template", Valid: "non-empty", Actual: "empty"}),
+	)
+}
+
 func TestExit(t *testing.T) {
 	var exitCodes []int
 	testutil.Set(t, OSExit, func(i int) { exitCodes = append(exitCodes, i) })
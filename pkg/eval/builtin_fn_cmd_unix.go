@@ -33,6 +33,11 @@ func execFn(fm *Frame, args ...any) error {
 		}
 	}
 
+	if fm.dryRun {
+		printDryRun(fm, argstrings)
+		return nil
+	}
+
 	var err error
 	argstrings[0], err = exec.LookPath(argstrings[0])
 	if err != nil {
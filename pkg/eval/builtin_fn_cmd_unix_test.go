@@ -4,9 +4,16 @@ package eval_test
 
 import (
 	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"src.elv.sh/pkg/eval/errs"
 	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/testutil"
 )
 
 func TestHasExternal(t *testing.T) {
@@ -41,3 +48,75 @@ func TestExternal(t *testing.T) {
 		That(`(external sh) -c 'echo external-sh'`).Prints("external-sh\n"),
 	)
 }
+
+func TestArgv_Run(t *testing.T) {
+	Test(t,
+		That(`argv &run 'sh -c {script} {arg}' [&script='echo $0' &arg='a b']`).
+			Prints("a b\n"),
+	)
+}
+
+func TestJobs_KillJob(t *testing.T) {
+	Test(t,
+		That("e:sleep 10 &", "put (jobs)[id source]", "kill-job 1").
+			Puts(1, "e:sleep 10 &"),
+		That(
+			"e:sleep 10 | e:sleep 10 &",
+			"while (!= (count (jobs)[pids]) 2) { sleep 0.01 }", "kill-job 1",
+		).DoesNothing(),
+		// IDs of finished jobs are reused.
+		That(
+			"e:sleep 10 &", "e:sleep 10 &", "kill-job 1",
+			"while (== (count [(jobs)]) 2) { sleep 0.01 }",
+			"e:sleep 10 &", "jobs | each {|j| put $j[id] }", "kill-job 1", "kill-job 2",
+		).Puts(1, 2),
+		// All the processes of the job are terminated.
+		That(
+			"e:sleep 10 | e:sleep 10 &", "kill-job 1",
+			"while (> $num-bg-jobs 0) { sleep 0.01 }", "jobs",
+		).DoesNothing(),
+		That("kill-job 1").Throws(errs.BadValue{
+			What: "job ID", Valid: "ID of a background job", Actual: "1"}),
+	)
+}
+
+func TestKillJob_Tree(t *testing.T) {
+	if _, err := exec.LookPath("setsid"); err != nil {
+		t.Skip("setsid not found")
+	}
+	testutil.InTempDir(t)
+
+	Test(t,
+		// The grandchild started by setsid is in a process group of its own,
+		// and only gets terminated with &tree.
+		That(
+			"sh -c 'setsid sleep 100 & echo $! > pid; wait' &",
+			"while (not ?(test -s pid)) { sleep 0.01 }",
+			"kill-job &tree 1",
+			"while (> $num-bg-jobs 0) { sleep 0.01 }",
+		).DoesNothing(),
+	)
+
+	pid, err := strconv.Atoi(strings.TrimSpace(must.ReadFileString("pid")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(testutil.Scaled(5 * time.Second))
+	for isRunning(pid) {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("grandchild %d still running after kill-job &tree", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Reports whether a process exists and has not become a zombie, which can
+// happen when no process reaps orphans, like in some containers.
+func isRunning(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	out, err := exec.Command("ps", "-o", "stat=", "-p", strconv.Itoa(pid)).Output()
+	return err == nil && !strings.HasPrefix(strings.TrimSpace(string(out)), "Z")
+}
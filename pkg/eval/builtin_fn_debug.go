@@ -2,17 +2,23 @@ package eval
 
 import (
 	"runtime"
+	"sort"
+	"strconv"
 
+	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/logutil"
 	"src.elv.sh/pkg/parse"
 )
 
 func init() {
 	addBuiltinFns(map[string]any{
-		"src":    src,
-		"-gc":    _gc,
-		"-stack": _stack,
-		"-log":   _log,
+		"src":          src,
+		"-gc":          _gc,
+		"-stack":       _stack,
+		"-log":         _log,
+		"-log-options": _logOptions,
+
+		"-pipe-buffers": _pipeBuffers,
 	})
 }
 
@@ -37,3 +43,71 @@ func _stack(fm *Frame) error {
 func _log(fname string) error {
 	return logutil.SetOutputFile(fname)
 }
+
+type logOptions struct {
+	Level      string
+	Format     string
+	MaxSize    int
+	MaxBackups int
+}
+
+func (opts *logOptions) SetDefaultOptions() {
+	c := logutil.GetConfig()
+	*opts = logOptions{c.Level.String(), c.Format.String(), int(c.MaxSize), c.MaxBackups}
+}
+
+func _logOptions(opts logOptions) error {
+	level, err := logutil.ParseLevel(opts.Level)
+	if err != nil {
+		return errs.BadValue{What: "&level option",
+			Valid: "debug, info, warn or error", Actual: parse.Quote(opts.Level)}
+	}
+	format, err := logutil.ParseFormat(opts.Format)
+	if err != nil {
+		return errs.BadValue{What: "&format option",
+			Valid: "text or json", Actual: parse.Quote(opts.Format)}
+	}
+	if opts.MaxSize < 0 {
+		return errs.BadValue{What: "&max-size option",
+			Valid: "non-negative integer", Actual: strconv.Itoa(opts.MaxSize)}
+	}
+	if opts.MaxBackups < 0 {
+		return errs.BadValue{What: "&max-backups option",
+			Valid: "non-negative integer", Actual: strconv.Itoa(opts.MaxBackups)}
+	}
+	logutil.SetConfig(logutil.Config{
+		Level: level, Format: format,
+		MaxSize: int64(opts.MaxSize), MaxBackups: opts.MaxBackups})
+	return nil
+}
+
+type pipeBuffer struct {
+	Pipeline string
+	Index    int
+	Buffered int
+	Capacity int
+}
+
+func (pipeBuffer) IsStructMap() {}
+
+func _pipeBuffers(fm *Frame) error {
+	ev := fm.Evaler
+	ev.mu.RLock()
+	buffers := make([]pipeBuffer, 0, len(ev.pipes))
+	for p := range ev.pipes {
+		buffers = append(buffers, pipeBuffer{p.pipeline, p.index, len(p.ch), cap(p.ch)})
+	}
+	ev.mu.RUnlock()
+	sort.Slice(buffers, func(i, j int) bool {
+		a, b := buffers[i], buffers[j]
+		return a.Pipeline < b.Pipeline || (a.Pipeline == b.Pipeline && a.Index < b.Index)
+	})
+	out := fm.ValueOutput()
+	for _, b := range buffers {
+		err := out.Put(b)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
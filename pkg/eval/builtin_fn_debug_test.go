@@ -0,0 +1,41 @@
+package eval_test
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/eval/errs"
+	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/logutil"
+)
+
+func TestLogOptions(t *testing.T) {
+	t.Cleanup(func() { logutil.SetConfig(logutil.DefaultConfig) })
+	Test(t,
+		That("-log-options &level=debug &format=json &max-size=100").DoesNothing(),
+		That("-log-options &max-backups=3").DoesNothing(),
+
+		That("-log-options &level=bad").Throws(errs.BadValue{What: "&level option",
+			Valid: "debug, info, warn or error", Actual: "bad"}),
+		That("-log-options &format=bad").Throws(errs.BadValue{What: "&format option",
+			Valid: "text or json", Actual: "bad"}),
+		That("-log-options &max-size=-1").Throws(errs.BadValue{What: "&max-size option",
+			Valid: "non-negative integer", Actual: "-1"}),
+		That("-log-options &max-backups=-1").Throws(errs.BadValue{What: "&max-backups option",
+			Valid: "non-negative integer", Actual: "-1"}),
+	)
+	want := logutil.Config{Level: logutil.Debug, Format: logutil.JSON, MaxSize: 100, MaxBackups: 3}
+	if got := logutil.GetConfig(); got != want {
+		t.Errorf("got config %v, want %v", got, want)
+	}
+}
+
+func TestPipeBuffers(t *testing.T) {
+	Test(t,
+		// The reader waits for the writer to finish with a byte output.
+		That(`{ put a b; echo } | { var _ = (read-line); put (-pipe-buffers)[pipeline index buffered capacity] }`).
+			Puts("{ put a b; echo } | { var _ = (read-line); put (-pipe-buffers)[pipeline index buffered capacity] }",
+				0, 2, 32),
+		// Pipes are removed when the pipeline finishes.
+		That(`put a | nop; -pipe-buffers`).DoesNothing(),
+	)
+}
@@ -2,7 +2,10 @@ package eval
 
 import (
 	"errors"
+	"fmt"
 	"os"
+
+	"src.elv.sh/pkg/parse"
 )
 
 // ErrNonExistentEnvVar is raised by the get-env command when the environment
@@ -15,6 +18,9 @@ func init() {
 		"get-env":   getEnv,
 		"set-env":   os.Setenv,
 		"unset-env": os.Unsetenv,
+
+		"load-dotenv": loadDotenv,
+		"with-dotenv": withDotenv,
 	})
 }
 
@@ -30,3 +36,60 @@ func getEnv(key string) (string, error) {
 	}
 	return value, nil
 }
+
+type loadDotenvOpts struct {
+	Override bool
+	DryRun   bool
+}
+
+func (*loadDotenvOpts) SetDefaultOptions() {}
+
+func loadDotenv(fm *Frame, opts loadDotenvOpts, path string) error {
+	changes, err := readDotenv(path, opts.Override)
+	if err != nil {
+		return err
+	}
+	if opts.DryRun {
+		out := fm.ByteOutput()
+		for _, c := range changes {
+			op := "+"
+			if c.existed {
+				op = "~"
+			}
+			_, err := fmt.Fprintf(out, "%s %s=%s\n", op, c.name, parse.Quote(c.value))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, c := range changes {
+		os.Setenv(c.name, c.value)
+	}
+	return nil
+}
+
+type withDotenvOpts struct{ Override bool }
+
+func (*withDotenvOpts) SetDefaultOptions() {}
+
+func withDotenv(fm *Frame, opts withDotenvOpts, path string, f Callable) error {
+	changes, err := readDotenv(path, opts.Override)
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		os.Setenv(c.name, c.value)
+	}
+	// Use a Go defer so that the environment is restored even if f panics.
+	defer func() {
+		for _, c := range changes {
+			if c.existed {
+				os.Setenv(c.name, c.oldValue)
+			} else {
+				os.Unsetenv(c.name)
+			}
+		}
+	}()
+	return f.Call(fm, NoArgs, NoOpts)
+}
@@ -9,6 +9,7 @@ import (
 	. "src.elv.sh/pkg/eval/evaltest"
 	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/testutil"
 )
 
 func TestGetEnv(t *testing.T) {
@@ -70,6 +71,56 @@ func TestSetEnv_PATH(t *testing.T) {
 	)
 }
 
+var dotenvContent = "# comment\n" +
+	"export DOTENV_NEW='new value'\n" +
+	"DOTENV_OLD=changed\n" +
+	"DOTENV_SAME=same\n" +
+	"DOTENV_MULTI=\"a\nb\"\n"
+
+func setupDotenv(t *testing.T) {
+	testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{".env": dotenvContent, "bad.env": "x\n"})
+	testutil.Unsetenv(t, "DOTENV_NEW")
+	testutil.Setenv(t, "DOTENV_OLD", "old")
+	testutil.Setenv(t, "DOTENV_SAME", "same")
+	testutil.Unsetenv(t, "DOTENV_MULTI")
+}
+
+func TestLoadDotenv(t *testing.T) {
+	setupDotenv(t)
+	Test(t,
+		That("load-dotenv &dry-run &override .env").Prints(
+			"+ DOTENV_NEW='new value'\n~ DOTENV_OLD=changed\n+ DOTENV_MULTI=\"a\\nb\"\n"),
+		That("load-dotenv &dry-run .env").Prints(
+			"+ DOTENV_NEW='new value'\n+ DOTENV_MULTI=\"a\\nb\"\n"),
+		That("has-env DOTENV_NEW").Puts(false),
+
+		That("load-dotenv .env").DoesNothing(),
+		That("put $E:DOTENV_NEW $E:DOTENV_OLD $E:DOTENV_MULTI").
+			Puts("new value", "old", "a\nb"),
+		That("load-dotenv &override .env").DoesNothing(),
+		That("put $E:DOTENV_OLD").Puts("changed"),
+
+		That("load-dotenv bad.env").Throws(ErrorWithMessage("bad.env:1: missing = after x")),
+		That("load-dotenv nonexistent.env").Throws(ErrorWithType(&os.PathError{})),
+	)
+}
+
+func TestWithDotenv(t *testing.T) {
+	setupDotenv(t)
+	Test(t,
+		That("with-dotenv .env { put $E:DOTENV_NEW $E:DOTENV_OLD }").
+			Puts("new value", "old"),
+		That("with-dotenv &override .env { put $E:DOTENV_OLD }").Puts("changed"),
+		That("has-env DOTENV_NEW").Puts(false),
+		That("put $E:DOTENV_OLD").Puts("old"),
+
+		That("with-dotenv &override .env { fail foo }").Throws(eval.FailError{Content: "foo"}),
+		That("has-env DOTENV_NEW").Puts(false),
+		That("put $E:DOTENV_OLD").Puts("old"),
+	)
+}
+
 func saveEnv(name string) func() {
 	oldValue, ok := os.LookupEnv(name)
 	return func() {
@@ -2,10 +2,14 @@ package eval
 
 import (
 	"errors"
+	"math/rand"
+	"strconv"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"src.elv.sh/pkg/errutil"
+	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
 )
 
@@ -23,6 +27,7 @@ func init() {
 		"break":       breakFn,
 		"continue":    continueFn,
 		"defer":       deferFn,
+		"retry":       retry,
 		// Iterations.
 		"each":  each,
 		"peach": peach,
@@ -47,15 +52,25 @@ func runParallel(fm *Frame, functions ...Callable) error {
 	return MakePipelineError(exceptions)
 }
 
-func each(fm *Frame, f Callable, inputs Inputs) error {
+type eachOpts struct{ Index bool }
+
+func (*eachOpts) SetDefaultOptions() {}
+
+func each(fm *Frame, opts eachOpts, f Callable, inputs Inputs) error {
 	broken := false
 	var err error
+	i := 0
 	inputs(func(v any) {
 		if broken {
 			return
 		}
+		args := []any{v}
+		if opts.Index {
+			args = []any{i, v}
+			i++
+		}
 		newFm := fm.Fork("closure of each")
-		ex := f.Call(newFm, []any{v}, NoOpts)
+		ex := f.Call(newFm, args, NoOpts)
 		newFm.Close()
 
 		if ex != nil {
@@ -160,6 +175,9 @@ func deferFn(fm *Frame, fn Callable) error {
 	deferTraceback := fm.traceback
 	fm.addDefer(func(fm *Frame) Exception {
 		err := fn.Call(fm, NoArgs, NoOpts)
+		if err == nil {
+			return nil
+		}
 		if exc, ok := err.(Exception); ok {
 			return exc
 		}
@@ -167,3 +185,105 @@ func deferFn(fm *Frame, fn Callable) error {
 	})
 	return nil
 }
+
+type retryOpts struct {
+	Times   int
+	Backoff string
+	Delay   any
+	Jitter  float64
+	RetryIf Callable
+	delay   time.Duration
+}
+
+func (o *retryOpts) SetDefaultOptions() {
+	o.Times = 3
+	o.Backoff = "exponential"
+	o.delay = 100 * time.Millisecond
+}
+
+func (opts *retryOpts) parse() error {
+	if opts.Times < 1 {
+		return errs.BadValue{What: "times option",
+			Valid: "positive integer", Actual: strconv.Itoa(opts.Times)}
+	}
+	if opts.Backoff != "constant" && opts.Backoff != "exponential" {
+		return errs.BadValue{What: "backoff option",
+			Valid: "constant or exponential", Actual: opts.Backoff}
+	}
+	if opts.Delay != nil {
+		d, ok := toDuration(opts.Delay)
+		if !ok || d < 0 {
+			return errs.BadValue{What: "delay option",
+				Valid:  "non-negative number or duration string",
+				Actual: vals.ReprPlain(opts.Delay)}
+		}
+		opts.delay = d
+	}
+	if opts.Jitter < 0 || opts.Jitter > 1 {
+		return errs.BadValue{What: "jitter option",
+			Valid: "number between 0 and 1", Actual: vals.ToString(opts.Jitter)}
+	}
+	return nil
+}
+
+// Reference to [rand.Float64] that can be overridden in tests.
+var randFloat64 = rand.Float64
+
+func retry(fm *Frame, opts retryOpts, f Callable) error {
+	if err := opts.parse(); err != nil {
+		return err
+	}
+	delay := opts.delay
+	for attempt := 1; ; attempt++ {
+		err := f.Call(fm.Fork("retry"), NoArgs, NoOpts)
+		if err == nil || attempt == opts.Times {
+			return err
+		}
+		if _, isFlow := Reason(err).(Flow); isFlow {
+			return err
+		}
+		if opts.RetryIf != nil {
+			retryable, errPred := callRetryIf(fm, opts.RetryIf, err)
+			if errPred != nil {
+				return errPred
+			}
+			if !retryable {
+				return err
+			}
+		}
+
+		d := delay
+		if opts.Jitter > 0 {
+			d -= time.Duration(float64(d) * opts.Jitter * randFloat64())
+		}
+		select {
+		case <-fm.Interrupts():
+			return ErrInterrupted
+		case <-timeAfter(fm, d):
+		}
+		if opts.Backoff == "exponential" {
+			delay *= 2
+		}
+	}
+}
+
+// Calls the predicate of retry with an exception, and returns whether the
+// exception is retryable.
+func callRetryIf(fm *Frame, pred Callable, err error) (bool, error) {
+	outputs, errPred := fm.CaptureOutput(func(fm *Frame) error {
+		return pred.Call(fm, []any{err}, NoOpts)
+	})
+	if errPred != nil {
+		return false, errPred
+	}
+	if len(outputs) != 1 {
+		return false, errs.ArityMismatch{What: "retry-if output",
+			ValidLow: 1, ValidHigh: 1, Actual: len(outputs)}
+	}
+	b, ok := outputs[0].(bool)
+	if !ok {
+		return false, errs.BadValue{What: "retry-if output",
+			Valid: "boolean", Actual: vals.Kind(outputs[0])}
+	}
+	return b, nil
+}
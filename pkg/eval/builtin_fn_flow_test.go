@@ -2,12 +2,14 @@ package eval_test
 
 import (
 	"testing"
+	"time"
 
 	. "src.elv.sh/pkg/eval"
 
 	"src.elv.sh/pkg/eval/errs"
 	. "src.elv.sh/pkg/eval/evaltest"
 	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/testutil"
 )
 
 func TestRunParallel(t *testing.T) {
@@ -30,6 +32,12 @@ func TestEach(t *testing.T) {
 			Puts(0, 1, 2, 3, 5, 6, 7, 8, 9),
 		That(`range 10 | each {|x| if (== $x 4) { fail haha }; put $x }`).
 			Puts(0, 1, 2, 3).Throws(FailError{"haha"}),
+		That(`each &index {|i x| put $i $x } [a b]`).Puts(0, "a", 1, "b"),
+		That(`range 5 10 | each &index {|i x| if (== $i 2) { break }; put $x }`).
+			Puts(5, 6),
+		That(`echo "a
+b" | each &index {|i x| put $i$x }`).Puts("0a", "1b"),
+		That(`each &index {|x| put $x } [a]`).Throws(ErrorWithType(errs.ArityMismatch{})),
 		// TODO(xiaq): Test that "each" does not close the stdin.
 	)
 }
@@ -104,9 +112,69 @@ func TestDefer(t *testing.T) {
 			Throws(ErrorWithMessage("defer must be called from within a closure")),
 		That("{ defer { fail foo } }").
 			Throws(FailError{"foo"}, "fail foo ", "{ defer { fail foo } }"),
+		// Deferred functions are called in reverse order
+		That("{ defer { put a }; defer { put b }; put c }").Puts("c", "b", "a"),
+		// Deferred functions are called when the closure throws an exception
+		That("{ defer { put a }; fail foo }").
+			Puts("a").Throws(FailError{"foo"}),
+		That("fn f { defer { put a }; return; put b }", "f").Puts("a"),
+		// An exception from a deferred function is not masked by other
+		// deferred functions that succeed
+		That("{ defer { fail foo }; defer { put a } }").
+			Puts("a").Throws(FailError{"foo"}),
 		That("{ defer {|x| } }").Throws(
 			errs.ArityMismatch{What: "arguments",
 				ValidLow: 1, ValidHigh: 1, Actual: 0},
 			"defer {|x| } ", "{ defer {|x| } }"),
 	)
 }
+
+func TestRetry(t *testing.T) {
+	testutil.Set(t, TimeAfter,
+		func(fm *Frame, d time.Duration) <-chan time.Time {
+			fm.ValueOutput().Put(d)
+			return time.After(0)
+		})
+	testutil.Set(t, RandFloat, func() float64 { return 0.5 })
+
+	Test(t,
+		That("retry { put foo }").Puts("foo"),
+		// Exceptions are retried, with exponential backoff by default
+		That("var n = 0",
+			"retry &times=4 { set n = (+ $n 1); if (< $n 4) { fail flaky } }",
+			"put $n").
+			Puts(100*time.Millisecond, 200*time.Millisecond, 400*time.Millisecond, 4),
+		// The last exception is propagated
+		That("retry &times=2 &delay=0 { fail foo }").
+			Puts(time.Duration(0)).Throws(FailError{"foo"}),
+		That("retry &times=1 { fail foo }").Throws(FailError{"foo"}),
+		// Constant backoff, with a duration string
+		That("retry &times=3 &delay=1s &backoff=constant { fail foo }").
+			Puts(time.Second, time.Second).Throws(FailError{"foo"}),
+		// Jitter
+		That("retry &times=2 &delay=1s &jitter=0.5 { fail foo }").
+			Puts(750*time.Millisecond).Throws(FailError{"foo"}),
+		// Predicate for retryable exceptions
+		That("retry &retry-if={|e| ==s $e[reason][content] retry } { fail foo }").
+			Throws(FailError{"foo"}),
+		That("retry &times=2 &retry-if={|e| ==s $e[reason][content] retry } { fail retry }").
+			Puts(100*time.Millisecond).Throws(FailError{"retry"}),
+		That("retry &retry-if={|e| } { fail foo }").
+			Throws(errs.ArityMismatch{What: "retry-if output",
+				ValidLow: 1, ValidHigh: 1, Actual: 0}),
+		That("retry &retry-if={|e| put foo } { fail foo }").
+			Throws(errs.BadValue{What: "retry-if output",
+				Valid: "boolean", Actual: "string"}),
+		// Flow control exceptions are not retried
+		That("for x [a b] { retry { break }; put $x }").DoesNothing(),
+		// Bad options
+		That("retry &times=0 { }").Throws(errs.BadValue{What: "times option",
+			Valid: "positive integer", Actual: "0"}),
+		That("retry &backoff=linear { }").Throws(errs.BadValue{
+			What: "backoff option", Valid: "constant or exponential", Actual: "linear"}),
+		That("retry &delay=-1 { }").Throws(errs.BadValue{What: "delay option",
+			Valid: "non-negative number or duration string", Actual: "-1"}),
+		That("retry &jitter=2 { }").Throws(errs.BadValue{What: "jitter option",
+			Valid: "number between 0 and 1", Actual: "2.0"}),
+	)
+}
@@ -1,6 +1,8 @@
 package eval
 
 import (
+	"os"
+
 	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/fsutil"
 )
@@ -10,7 +12,8 @@ import (
 func init() {
 	addBuiltinFns(map[string]any{
 		// Directory
-		"cd": cd,
+		"cd":      cd,
+		"with-cd": withCd,
 
 		// Path
 		"tilde-abbr": tildeAbbr,
@@ -35,6 +38,25 @@ func cd(fm *Frame, args ...string) error {
 	return fm.Evaler.Chdir(dir)
 }
 
+func withCd(fm *Frame, dir string, f Callable) (err error) {
+	oldDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	err = fm.Evaler.Chdir(dir)
+	if err != nil {
+		return err
+	}
+	// Use a Go defer so that the old directory is restored even if f panics.
+	defer func() {
+		errRestore := fm.Evaler.Chdir(oldDir)
+		if err == nil {
+			err = errRestore
+		}
+	}()
+	return f.Call(fm, NoArgs, NoOpts)
+}
+
 func tildeAbbr(path string) string {
 	return fsutil.TildeAbbr(path)
 }
@@ -2,6 +2,7 @@ package eval_test
 
 import (
 	"errors"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -41,6 +42,21 @@ func TestCd(t *testing.T) {
 	)
 }
 
+func TestWithCd(t *testing.T) {
+	tmpHome := testutil.InTempHome(t)
+
+	must.MkdirAll("d1")
+	d1Path := filepath.Join(tmpHome, "d1")
+
+	Test(t,
+		That("with-cd d1 { put $pwd }", "put $pwd").Puts(d1Path, tmpHome),
+		// The old directory is restored when the function throws
+		That("with-cd d1 { fail foo }").Throws(FailError{"foo"}),
+		That("try { with-cd d1 { fail foo } } catch { }", "put $pwd").Puts(tmpHome),
+		That("with-cd bad { }").Throws(ErrorWithType(&os.PathError{})),
+	)
+}
+
 func TestCd_GetHomeError(t *testing.T) {
 	err := errors.New("fake error")
 	testutil.Set(t, GetHome, func(name string) (string, error) { return "", err })
@@ -1,7 +1,6 @@
 package eval
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -312,23 +311,15 @@ func slurp(fm *Frame) (string, error) {
 }
 
 func fromLines(fm *Frame) error {
-	filein := bufio.NewReader(fm.InputFile())
 	out := fm.ValueOutput()
-	for {
-		line, err := filein.ReadString('\n')
-		if line != "" {
-			err := out.Put(strutil.ChopLineEnding(line))
-			if err != nil {
-				return err
-			}
-		}
+	mode := fm.Evaler.getCaptureInvalidUTF8()
+	return eachLine(fm.InputFile(), '\n', func(line string) error {
+		v, err := lineToValue(strutil.ChopLineEnding(line), mode)
 		if err != nil {
-			if err != io.EOF {
-				return err
-			}
-			return nil
+			return err
 		}
-	}
+		return out.Put(v)
+	})
 }
 
 func fromJSON(fm *Frame) error {
@@ -345,7 +336,7 @@ func fromJSON(fm *Frame) error {
 			}
 			return err
 		}
-		converted, err := fromJSONInterface(v)
+		converted, err := FromJSONInterface(v)
 		if err != nil {
 			return err
 		}
@@ -356,17 +347,21 @@ func fromJSON(fm *Frame) error {
 	}
 }
 
-// Converts a interface{} that results from json.Unmarshal to an Elvish value.
-func fromJSONInterface(v any) (any, error) {
+// FromJSONInterface converts a value that results from json.Unmarshal to an
+// Elvish value. Numbers decoded as json.Number, as done by a json.Decoder after
+// UseNumber is called, are converted with vals.ParseNum.
+func FromJSONInterface(v any) (any, error) {
 	switch v := v.(type) {
 	case nil, bool, string:
 		return v, nil
 	case float64:
 		return v, nil
+	case json.Number:
+		return vals.ParseNum(string(v)), nil
 	case []any:
 		vec := vals.EmptyList
 		for _, elem := range v {
-			converted, err := fromJSONInterface(elem)
+			converted, err := FromJSONInterface(elem)
 			if err != nil {
 				return nil, err
 			}
@@ -376,7 +371,7 @@ func fromJSONInterface(v any) (any, error) {
 	case map[string]any:
 		m := vals.EmptyMap
 		for key, val := range v {
-			convertedVal, err := fromJSONInterface(val)
+			convertedVal, err := FromJSONInterface(val)
 			if err != nil {
 				return nil, err
 			}
@@ -393,24 +388,14 @@ func fromTerminated(fm *Frame, terminator string) error {
 		return err
 	}
 
-	filein := bufio.NewReader(fm.InputFile())
 	out := fm.ValueOutput()
-	for {
-		line, err := filein.ReadString(terminator[0])
-		if line != "" {
-			err := out.Put(strutil.ChopTerminator(line, terminator[0]))
-			if err != nil {
-				return err
-			}
-		}
-		if err != nil {
-			if err != io.EOF {
-				logger.Println("error on reading:", err)
-				return err
-			}
-			return nil
-		}
+	err := eachLine(fm.InputFile(), terminator[0], func(line string) error {
+		return out.Put(strutil.ChopTerminator(line, terminator[0]))
+	})
+	if err != nil {
+		logger.Warnf("error on reading: %v", err)
 	}
+	return err
 }
 
 func toLines(fm *Frame, inputs Inputs) error {
@@ -118,6 +118,13 @@ func TestFromLines(t *testing.T) {
 		That(`print "a\nb" | from-lines`).Puts("a", "b"),
 		That(`print "a\nb\n" | from-lines`).Puts("a", "b"),
 		thatOutputErrorIsBubbled(`print "a\nb\n" | from-lines`),
+
+		That(`print "a\xffb" | from-lines`).Puts("a\xffb"),
+		That(`{ tmp capture-invalid-utf8 = replace; print "a\xffb" | from-lines }`).
+			Puts("a\uFFFDb"),
+		That(`{ tmp capture-invalid-utf8 = error; print "a\n\xff\nb" | from-lines }`).
+			Puts("a").
+			Throws(errs.BadValue{What: "output line", Valid: "valid UTF-8", Actual: `"\xff"`}),
 	)
 }
 
@@ -170,6 +177,8 @@ func TestToJson(t *testing.T) {
 "foo"
 `),
 		That(`put [$nil foo] | to-json`).Prints("[null,\"foo\"]\n"),
+		That(`put 1..3 0..=1..0.5 | to-json`).Prints("[1,2]\n[0,0.5,1]\n"),
+		That(`put 1..inf | to-json`).Throws(ErrorWithType(&json.MarshalerError{})),
 		thatOutputErrorIsBubbled("to-json [foo]"),
 	)
 }
@@ -3,10 +3,13 @@ package eval
 // Misc builtin functions.
 
 import (
+	"container/list"
 	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
+	"time"
 
 	"src.elv.sh/pkg/diag"
 	"src.elv.sh/pkg/eval/errs"
@@ -25,6 +28,7 @@ func init() {
 	addBuiltinFns(map[string]any{
 		"kind-of":    kindOf,
 		"constantly": constantly,
+		"memoize":    memoize,
 
 		// Introspection
 		"call":    call,
@@ -73,6 +77,166 @@ func constantly(args ...any) Callable {
 	)
 }
 
+type memoizeOpts struct {
+	MaxEntries int
+	TTL        any
+	Key        Callable
+}
+
+func (*memoizeOpts) SetDefaultOptions() {}
+
+func memoize(opts memoizeOpts, fn Callable) (Callable, error) {
+	if opts.MaxEntries < 0 {
+		return nil, errs.BadValue{What: "max-entries option",
+			Valid: "non-negative integer", Actual: strconv.Itoa(opts.MaxEntries)}
+	}
+	var ttl time.Duration
+	if opts.TTL != nil {
+		d, ok := toDuration(opts.TTL)
+		if !ok || d < 0 {
+			return nil, errs.BadValue{What: "ttl option",
+				Valid:  "non-negative number or duration string",
+				Actual: vals.ReprPlain(opts.TTL)}
+		}
+		ttl = d
+	}
+	m := &memoized{fn: fn, keyFn: opts.Key, maxEntries: opts.MaxEntries,
+		ttl: ttl, entries: make(map[string]*list.Element), lru: list.New()}
+	// TODO: Repr of this function is not right, like the one created by
+	// constantly.
+	return NewGoFn("created by memoize", m.call), nil
+}
+
+// State of a function created by memoize.
+type memoized struct {
+	fn         Callable
+	keyFn      Callable
+	maxEntries int
+	ttl        time.Duration
+
+	mutex sync.Mutex
+	// Maps keys to elements of lru, whose values are *memoEntry.
+	entries map[string]*list.Element
+	// Entries ordered from the most recently used to the least recently used.
+	lru *list.List
+}
+
+type memoEntry struct {
+	key string
+	// Closed when the outputs are available. Until then, callers with the
+	// same key wait for the first caller instead of calling the function
+	// again.
+	done    chan struct{}
+	outputs []any
+	err     error
+	expires time.Time
+}
+
+func (m *memoized) call(fm *Frame, opts RawOptions, args ...any) error {
+	key, err := m.key(fm, opts, args)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	var entry *memoEntry
+	if elem, ok := m.entries[key]; ok {
+		entry = elem.Value.(*memoEntry)
+		if isDone(entry.done) && m.ttl > 0 && !timeNow().Before(entry.expires) {
+			m.remove(elem)
+			entry = nil
+		} else {
+			m.lru.MoveToFront(elem)
+		}
+	}
+	if entry == nil {
+		entry = &memoEntry{key: key, done: make(chan struct{})}
+		elem := m.lru.PushFront(entry)
+		m.entries[key] = elem
+		if m.maxEntries > 0 && m.lru.Len() > m.maxEntries {
+			m.remove(m.lru.Back())
+		}
+		m.mutex.Unlock()
+
+		entry.outputs, entry.err = fm.CaptureOutput(func(fm *Frame) error {
+			return m.fn.Call(fm, args, opts)
+		})
+
+		m.mutex.Lock()
+		if entry.err != nil {
+			// Don't cache exceptions.
+			if m.entries[key] == elem {
+				m.remove(elem)
+			}
+		} else {
+			entry.expires = timeNow().Add(m.ttl)
+		}
+		close(entry.done)
+		m.mutex.Unlock()
+	} else {
+		m.mutex.Unlock()
+		select {
+		case <-entry.done:
+		case <-fm.Interrupts():
+			return ErrInterrupted
+		}
+	}
+
+	if entry.err != nil {
+		return entry.err
+	}
+	out := fm.ValueOutput()
+	for _, v := range entry.outputs {
+		err := out.Put(v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the cache key for the arguments and options. Without &key, it is
+// derived from the repr of the arguments and options.
+func (m *memoized) key(fm *Frame, opts RawOptions, args []any) (string, error) {
+	if m.keyFn == nil {
+		key := vals.ReprPlain(vals.MakeList(args...))
+		if len(opts) > 0 {
+			optsMap := vals.EmptyMap
+			for k, v := range opts {
+				optsMap = optsMap.Assoc(k, v)
+			}
+			key += " " + vals.ReprPlain(optsMap)
+		}
+		return key, nil
+	}
+	outputs, err := fm.CaptureOutput(func(fm *Frame) error {
+		return m.keyFn.Call(fm, args, opts)
+	})
+	if err != nil {
+		return "", err
+	} else if len(outputs) != 1 {
+		return "", errs.ArityMismatch{
+			What:     "number of outputs of the &key callback",
+			ValidLow: 1, ValidHigh: 1, Actual: len(outputs)}
+	}
+	return vals.ReprPlain(outputs[0]), nil
+}
+
+// Must be called with the mutex held.
+func (m *memoized) remove(elem *list.Element) {
+	m.lru.Remove(elem)
+	delete(m.entries, elem.Value.(*memoEntry).key)
+}
+
+func isDone(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
 func call(fm *Frame, fn Callable, argsVal vals.List, optsVal vals.Map) error {
 	args := make([]any, 0, argsVal.Len())
 	for it := argsVal.Iterator(); it.HasElem(); it.Next() {
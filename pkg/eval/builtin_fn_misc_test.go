@@ -3,6 +3,7 @@ package eval_test
 import (
 	"os"
 	"testing"
+	"time"
 
 	"src.elv.sh/pkg/diag"
 	. "src.elv.sh/pkg/eval"
@@ -28,6 +29,57 @@ func TestConstantly(t *testing.T) {
 	)
 }
 
+func TestMemoize(t *testing.T) {
+	Test(t,
+		That(`var n = 0; var f = (memoize {|x| set n = (+ $n 1); put $x$x })`,
+			`$f a; $f a; $f b; put $n`).Puts("aa", "aa", "bb", 2),
+		// Options are part of the key
+		That(`var n = 0; var f = (memoize {|&o=x| set n = (+ $n 1); put $o })`,
+			`$f; $f &o=y; $f &o=y; put $n`).Puts("x", "y", "y", 2),
+		// Byte output is cached as strings
+		That(`var f = (memoize { echo foo }); $f; $f`).Puts("foo", "foo"),
+		// Exceptions are not cached
+		That(`var n = 0; var f = (memoize { set n = (+ $n 1); fail bad })`,
+			`try { $f } catch { }; try { $f } catch { }; put $n`).Puts(2),
+		// &key
+		That(`var n = 0; var f = (memoize &key={|x y| put $x} {|x y| set n = (+ $n 1); put $y })`,
+			`$f a 1; $f a 2; $f b 3; put $n`).Puts("1", "1", "3", 2),
+		That(`var f = (memoize &key={|x| } {|x| }); $f a`).Throws(
+			errs.ArityMismatch{What: "number of outputs of the &key callback",
+				ValidLow: 1, ValidHigh: 1, Actual: 0}),
+		// &max-entries
+		That(`var n = 0; var f = (memoize &max-entries=2 {|x| set n = (+ $n 1); put $x })`,
+			`$f a; $f b; $f a; $f c; $f a; $f b; put $n`).
+			Puts("a", "b", "a", "c", "a", "b", 4),
+		That(`memoize &max-entries=-1 { }`).Throws(
+			errs.BadValue{What: "max-entries option",
+				Valid: "non-negative integer", Actual: "-1"}),
+		That(`memoize &ttl=foo { }`).Throws(
+			errs.BadValue{What: "ttl option",
+				Valid: "non-negative number or duration string", Actual: "foo"}),
+		// Concurrent calls
+		That(`var n = 0; var f = (memoize {|x| set n = (+ $n 1); put $x })`,
+			`range 100 | peach {|_| $f a } | count; put $n`).Puts(100, 1),
+	)
+}
+
+func TestMemoize_TTL(t *testing.T) {
+	now := time.Unix(0, 0)
+	testutil.Set(t, TimeNow, func() time.Time { return now })
+	TestWithSetup(t,
+		func(ev *Evaler) {
+			ev.ExtendGlobal(BuildNs().AddGoFn("advance", func(s float64) {
+				now = now.Add(time.Duration(s * float64(time.Second)))
+			}))
+		},
+		That(`var n = 0; var f = (memoize &ttl=10 { set n = (+ $n 1); put $n })`,
+			`$f; advance 5; $f; advance 5; $f; advance 1; $f`).
+			Puts(1, 1, 2, 2),
+		That(`var n = 0; var f = (memoize &ttl=1m { set n = (+ $n 1); put $n })`,
+			`$f; advance 59; $f; advance 1; $f`).Puts(1, 1, 2),
+	)
+}
+
 func TestCallCommand(t *testing.T) {
 	Test(t,
 		That(`call {|arg &opt=v| put $arg $opt } [foo] [&opt=bar]`).
@@ -38,6 +38,14 @@ func init() {
 		"/": slash,
 		"%": rem,
 
+		// Bitwise
+		"bit-and":         bitAnd,
+		"bit-or":          bitOr,
+		"bit-xor":         bitXor,
+		"bit-not":         bitNot,
+		"bit-shift-left":  bitShiftLeft,
+		"bit-shift-right": bitShiftRight,
+
 		// Random
 		"rand":      rand.Float64,
 		"randint":   randint,
@@ -319,6 +327,70 @@ func rem(a, b int) (int, error) {
 	return a % b, nil
 }
 
+func bitAnd(rawNums ...vals.Num) (vals.Num, error) {
+	return bitOp("bit-and", big.NewInt(-1), (*big.Int).And, rawNums)
+}
+
+func bitOr(rawNums ...vals.Num) (vals.Num, error) {
+	return bitOp("bit-or", big.NewInt(0), (*big.Int).Or, rawNums)
+}
+
+func bitXor(rawNums ...vals.Num) (vals.Num, error) {
+	return bitOp("bit-xor", big.NewInt(0), (*big.Int).Xor, rawNums)
+}
+
+func bitOp(name string, acc *big.Int, op func(z, x, y *big.Int) *big.Int, rawNums []vals.Num) (vals.Num, error) {
+	for _, rawNum := range rawNums {
+		num, err := exactInt("argument to "+name, rawNum)
+		if err != nil {
+			return nil, err
+		}
+		op(acc, acc, num)
+	}
+	return vals.NormalizeBigInt(acc), nil
+}
+
+func bitNot(rawNum vals.Num) (vals.Num, error) {
+	num, err := exactInt("argument to bit-not", rawNum)
+	if err != nil {
+		return nil, err
+	}
+	return vals.NormalizeBigInt(new(big.Int).Not(num)), nil
+}
+
+func bitShiftLeft(rawNum vals.Num, count int) (vals.Num, error) {
+	return bitShift("bit-shift-left", (*big.Int).Lsh, rawNum, count)
+}
+
+func bitShiftRight(rawNum vals.Num, count int) (vals.Num, error) {
+	return bitShift("bit-shift-right", (*big.Int).Rsh, rawNum, count)
+}
+
+func bitShift(name string, op func(z, x *big.Int, n uint) *big.Int, rawNum vals.Num, count int) (vals.Num, error) {
+	num, err := exactInt("argument to "+name, rawNum)
+	if err != nil {
+		return nil, err
+	}
+	if count < 0 {
+		return nil, errs.BadValue{What: "shift count",
+			Valid: "non-negative integer", Actual: strconv.Itoa(count)}
+	}
+	return vals.NormalizeBigInt(op(new(big.Int), num, uint(count))), nil
+}
+
+// Converts a number that must be an exact integer to a *big.Int.
+func exactInt(what string, n vals.Num) (*big.Int, error) {
+	switch n := n.(type) {
+	case int:
+		return big.NewInt(int64(n)), nil
+	case *big.Int:
+		return n, nil
+	default:
+		return nil, errs.BadValue{What: what,
+			Valid: "exact integer", Actual: vals.ReprPlain(n)}
+	}
+}
+
 func randint(args ...int) (int, error) {
 	var low, high int
 	switch len(args) {
@@ -358,138 +430,15 @@ func rangeFn(fm *Frame, opts rangeOpts, args ...vals.Num) error {
 	if opts.Step != nil {
 		rawNums = append(rawNums, opts.Step)
 	}
-	nums := vals.UnifyNums(rawNums, vals.Int)
-
-	out := fm.ValueOutput()
-
-	switch nums := nums.(type) {
-	case []int:
-		return rangeBuiltinNum(nums, out)
-	case []*big.Int:
-		return rangeBigNum(nums, out, bigIntDesc)
-	case []*big.Rat:
-		return rangeBigNum(nums, out, bigRatDesc)
-	case []float64:
-		return rangeBuiltinNum(nums, out)
-	default:
-		panic("unreachable")
-	}
-}
-
-type builtinNum interface{ int | float64 }
-
-func rangeBuiltinNum[T builtinNum](nums []T, out ValueOutput) error {
-	start, end := nums[0], nums[1]
-	var step T
-	if start <= end {
-		if len(nums) == 3 {
-			step = nums[2]
-			if step <= 0 {
-				return errs.BadValue{
-					What: "step", Valid: "positive", Actual: vals.ToString(step)}
-			}
-		} else {
-			step = 1
-		}
-		for cur := start; cur < end; cur += step {
-			err := out.Put(vals.FromGo(cur))
-			if err != nil {
-				return err
-			}
-			if cur+step <= cur {
-				break
-			}
-		}
-	} else {
-		if len(nums) == 3 {
-			step = nums[2]
-			if step >= 0 {
-				return errs.BadValue{
-					What: "step", Valid: "negative", Actual: vals.ToString(step)}
-			}
-		} else {
-			step = -1
-		}
-		for cur := start; cur > end; cur += step {
-			err := out.Put(vals.FromGo(cur))
-			if err != nil {
-				return err
-			}
-			if cur+step >= cur {
-				break
-			}
-		}
+	r, err := newNumRange(rawNums, false)
+	if err != nil {
+		return err
 	}
-	return nil
-}
 
-type bigNum[T any] interface {
-	Cmp(T) int
-	Sign() int
-	Add(T, T) T
-}
-
-type bigNumDesc[T any] struct {
-	one     T
-	negOne  T
-	newZero func() T
-}
-
-var bigIntDesc = bigNumDesc[*big.Int]{
-	one:     big.NewInt(1),
-	negOne:  big.NewInt(-1),
-	newZero: func() *big.Int { return &big.Int{} },
-}
-
-var bigRatDesc = bigNumDesc[*big.Rat]{
-	one:     big.NewRat(1, 1),
-	negOne:  big.NewRat(-1, 1),
-	newZero: func() *big.Rat { return &big.Rat{} },
-}
-
-func rangeBigNum[T bigNum[T]](nums []T, out ValueOutput, d bigNumDesc[T]) error {
-	start, end := nums[0], nums[1]
-	var step T
-	if start.Cmp(end) <= 0 {
-		if len(nums) == 3 {
-			step = nums[2]
-			if step.Sign() <= 0 {
-				return errs.BadValue{
-					What: "step", Valid: "positive", Actual: vals.ToString(step)}
-			}
-		} else {
-			step = d.one
-		}
-		var cur, next T
-		for cur = start; cur.Cmp(end) < 0; cur = next {
-			err := out.Put(vals.FromGo(cur))
-			if err != nil {
-				return err
-			}
-			next = d.newZero()
-			next.Add(cur, step)
-			cur = next
-		}
-	} else {
-		if len(nums) == 3 {
-			step = nums[2]
-			if step.Sign() >= 0 {
-				return errs.BadValue{
-					What: "step", Valid: "negative", Actual: vals.ToString(step)}
-			}
-		} else {
-			step = d.negOne
-		}
-		var cur, next T
-		for cur = start; cur.Cmp(end) > 0; cur = next {
-			err := out.Put(vals.FromGo(cur))
-			if err != nil {
-				return err
-			}
-			next = d.newZero()
-			next.Add(cur, step)
-			cur = next
-		}
-	}
-	return nil
+	out := fm.ValueOutput()
+	r.Iterate(func(v any) bool {
+		err = out.Put(v)
+		return err == nil
+	})
+	return err
 }
@@ -234,6 +234,50 @@ func TestArithmeticCommands(t *testing.T) {
 	)
 }
 
+func TestBitwise(t *testing.T) {
+	Test(t,
+		That("bit-and 12 10").Puts(8),
+		That("bit-and 0o755 0o022").Puts(0o022&0o755),
+		That("bit-and").Puts(-1),
+		That("bit-and -1 "+z).Puts(bigInt(z)),
+		That("bit-and "+z+" 0xff").Puts(0),
+		That("bit-or 12 10").Puts(14),
+		That("bit-or").Puts(0),
+		That("bit-or "+z+" 1").Puts(bigInt(z1)),
+		That("bit-xor 12 10").Puts(6),
+		That("bit-xor").Puts(0),
+		That("bit-xor "+z+" "+z1).Puts(1),
+		That("bit-and 1.0 1").Throws(
+			errs.BadValue{What: "argument to bit-and",
+				Valid: "exact integer", Actual: "(num 1.0)"},
+			"bit-and 1.0 1"),
+		That("bit-or 1/2").Throws(
+			errs.BadValue{What: "argument to bit-or",
+				Valid: "exact integer", Actual: "(num 1/2)"},
+			"bit-or 1/2"),
+
+		That("bit-not 0").Puts(-1),
+		That("bit-not 5").Puts(-6),
+		That("bit-not -"+z1).Puts(bigInt(z)),
+
+		That("bit-shift-left 1 4").Puts(16),
+		That("bit-shift-left -3 1").Puts(-6),
+		That("bit-shift-left 1 70").Puts(new(big.Int).Lsh(big.NewInt(1), 70)),
+		That("bit-shift-right 16 4").Puts(1),
+		That("bit-shift-right -8 1").Puts(-4),
+		That("bit-shift-right -1 10").Puts(-1),
+		That("bit-shift-right "+z+" 1").Puts(bigInt("5"+zeros)),
+		That("bit-shift-left 1 -1").Throws(
+			errs.BadValue{What: "shift count",
+				Valid: "non-negative integer", Actual: "-1"},
+			"bit-shift-left 1 -1"),
+		That("bit-shift-right 1.5 1").Throws(
+			errs.BadValue{What: "argument to bit-shift-right",
+				Valid: "exact integer", Actual: "(num 1.5)"},
+			"bit-shift-right 1.5 1"),
+	)
+}
+
 func TestRandint(t *testing.T) {
 	Test(t,
 		That("randint 1 2").Puts(1),
@@ -3,6 +3,7 @@ package eval
 import (
 	"math"
 	"math/big"
+	"strings"
 
 	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
@@ -110,6 +111,10 @@ func cmp(a, b any) ordering {
 				return more
 			}
 		}
+	case vals.Bytes:
+		if b, ok := b.(vals.Bytes); ok {
+			return compareInt(strings.Compare(string(a), string(b)), 0)
+		}
 	case vals.List:
 		if b, ok := b.(vals.List); ok {
 			aIt := a.Iterator()
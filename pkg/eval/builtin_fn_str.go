@@ -2,10 +2,12 @@ package eval
 
 import (
 	"errors"
+	"math/big"
 	"regexp"
 	"strconv"
 	"strings"
 
+	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/wcwidth"
 )
@@ -52,14 +54,39 @@ func toString(fm *Frame, args ...any) error {
 // greater than 36.
 var ErrBadBase = errors.New("bad base")
 
-func base(fm *Frame, b int, nums ...int) error {
+type baseOpts struct{ Prefix bool }
+
+func (*baseOpts) SetDefaultOptions() {}
+
+var basePrefixes = map[int]string{2: "0b", 8: "0o", 16: "0x"}
+
+func base(fm *Frame, opts baseOpts, b int, rawNums ...vals.Num) error {
 	if b < 2 || b > 36 {
 		return ErrBadBase
 	}
+	prefix := ""
+	if opts.Prefix {
+		var ok bool
+		prefix, ok = basePrefixes[b]
+		if !ok {
+			return errs.BadValue{What: "base with &prefix",
+				Valid: "2, 8 or 16", Actual: strconv.Itoa(b)}
+		}
+	}
 
 	out := fm.ValueOutput()
-	for _, num := range nums {
-		err := out.Put(strconv.FormatInt(int64(num), b))
+	for _, rawNum := range rawNums {
+		num, err := exactInt("argument to base", rawNum)
+		if err != nil {
+			return err
+		}
+		var s string
+		if num.Sign() < 0 {
+			s = "-" + prefix + new(big.Int).Neg(num).Text(b)
+		} else {
+			s = prefix + num.Text(b)
+		}
+		err = out.Put(s)
 		if err != nil {
 			return err
 		}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	. "src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
 	. "src.elv.sh/pkg/eval/evaltest"
 )
 
@@ -39,6 +40,14 @@ func TestBase(t *testing.T) {
 		That(`base 16 42 233`).Puts("2a", "e9"),
 		That(`base 1 1`).Throws(ErrBadBase),
 		That(`base 37 10`).Throws(ErrBadBase),
+		That(`base 16 -255 `+z).Puts("-ff", "56bc75e2d63100000"),
+		That(`base &prefix 2 5 -5`).Puts("0b101", "-0b101"),
+		That(`base &prefix 8 8`).Puts("0o10"),
+		That(`base &prefix 16 255`).Puts("0xff"),
+		That(`base &prefix 10 10`).Throws(
+			errs.BadValue{What: "base with &prefix", Valid: "2, 8 or 16", Actual: "10"}),
+		That(`base 2 1/2`).Throws(
+			errs.BadValue{What: "argument to base", Valid: "exact integer", Actual: "(num 1/2)"}),
 		thatOutputErrorIsBubbled("base 2 1"),
 	)
 }
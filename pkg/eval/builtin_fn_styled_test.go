@@ -21,11 +21,17 @@ func TestStyledSegment(t *testing.T) {
 			Prints("\033[mabc"),
 		That("print (styled (styled-segment abc &inverse=$true) toggle-inverse)").
 			Prints("\033[mabc"),
+		That("print (styled-segment abc &link=https://elv.sh)").
+			Prints("\033[m\033]8;;https://elv.sh\aabc\033]8;;\a"),
+		That("put (styled (styled-segment abc &link=https://elv.sh) red)[0][link]").
+			Puts("https://elv.sh"),
 
 		That("styled-segment []").Throws(ErrorWithMessage(
 			"argument to styled-segment must be a string or a styled segment")),
 		That("styled-segment text &foo=bar").
 			Throws(ErrorWithMessage("unrecognized option 'foo'")),
+		That("styled-segment text &link=[]").
+			Throws(ErrorWithMessage("value for option 'link' must be a string")),
 	)
 }
 
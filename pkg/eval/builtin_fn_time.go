@@ -29,24 +29,10 @@ var (
 )
 
 func sleep(fm *Frame, duration any) error {
-	var f float64
-	var d time.Duration
-
-	if err := vals.ScanToGo(duration, &f); err == nil {
-		d = time.Duration(f * float64(time.Second))
-	} else {
-		// See if it is a duration string rather than a simple number.
-		switch duration := duration.(type) {
-		case string:
-			d, err = time.ParseDuration(duration)
-			if err != nil {
-				return ErrInvalidSleepDuration
-			}
-		default:
-			return ErrInvalidSleepDuration
-		}
+	d, ok := toDuration(duration)
+	if !ok {
+		return ErrInvalidSleepDuration
 	}
-
 	if d < 0 {
 		return ErrNegativeSleepDuration
 	}
@@ -59,6 +45,21 @@ func sleep(fm *Frame, duration any) error {
 	}
 }
 
+// Converts a number of seconds or a duration string like "1.5s" to a
+// time.Duration.
+func toDuration(v any) (time.Duration, bool) {
+	var f float64
+	if err := vals.ScanToGo(v, &f); err == nil {
+		return time.Duration(f * float64(time.Second)), true
+	}
+	// See if it is a duration string rather than a simple number.
+	if s, ok := v.(string); ok {
+		d, err := time.ParseDuration(s)
+		return d, err == nil
+	}
+	return 0, false
+}
+
 type timeOpt struct{ OnEnd Callable }
 
 func (o *timeOpt) SetDefaultOptions() {}
@@ -17,7 +17,9 @@ package eval
 // closures functioning as code blocks.
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -44,6 +46,11 @@ type NoSuchModule struct{ spec string }
 // Error implements the error interface.
 func (err NoSuchModule) Error() string { return "no such module: " + err.spec }
 
+var (
+	errRemoteModuleNotHTTPS     = errors.New("remote modules must be loaded over HTTPS")
+	errRemoteModuleNotSupported = errors.New("remote modules are not supported")
+)
+
 func init() {
 	// Needed to avoid initialization loop
 	builtinSpecials = map[string]compileBuiltin{
@@ -63,6 +70,7 @@ func init() {
 		"while": compileWhile,
 		"for":   compileFor,
 		"try":   compileTry,
+		"match": compileMatch,
 
 		"pragma": compilePragma,
 	}
@@ -74,7 +82,7 @@ func init() {
 // VarForm = 'var' { VariablePrimary } [ '=' { Compound } ]
 func compileVar(cp *compiler, fn *parse.Form) effectOp {
 	lhsArgs, rhs := compileLHSRHS(cp, fn)
-	lhs := cp.parseCompoundLValues(lhsArgs, newLValue)
+	lhs := cp.parseCompoundLValues(lhsArgs, newLValue|patternLValue)
 	if rhs == nil {
 		// Just create new variables, nothing extra to do at runtime.
 		return nopOp{}
@@ -102,7 +110,7 @@ func compileSetArgs(cp *compiler, fn *parse.Form) (lvaluesGroup, valuesOp) {
 	if rhs == nil {
 		cp.errorpf(diag.PointRanging(fn.Range().To), "need = and right-hand-side")
 	}
-	lhs := cp.parseCompoundLValues(lhsArgs, setLValue)
+	lhs := cp.parseCompoundLValues(lhsArgs, setLValue|patternLValue)
 	return lhs, rhs
 }
 
@@ -237,6 +245,7 @@ func compileFn(cp *compiler, fn *parse.Form) effectOp {
 	// Define the variable before compiling the body, so that the body may refer
 	// to the function itself.
 	index := cp.thisScope().add(name + FnSuffix)
+	cp.lintDecl(index, name+FnSuffix, fn.Args[0])
 	op := cp.lambda(bodyNode)
 
 	return fnOp{fn.Args[0].Range(), index, op}
@@ -284,12 +293,18 @@ func compileUse(cp *compiler, fn *parse.Form) effectOp {
 		name = args.get(1, "module name").stringLiteral()
 	} else {
 		name = spec[strings.LastIndexByte(spec, '/')+1:]
+		if isRemoteSpec(spec) {
+			name = strings.TrimSuffix(name, ".elv")
+		}
 	}
 	if !args.finish() {
 		return nil
 	}
 
-	return useOp{fn.Range(), cp.thisScope().add(name + NsSuffix), spec}
+	cp.lintUseSpec(spec, fn.Args[0])
+	index := cp.thisScope().add(name + NsSuffix)
+	cp.lintDecl(index, name+NsSuffix, fn)
+	return useOp{fn.Range(), index, spec}
 }
 
 type useOp struct {
@@ -314,6 +329,18 @@ func use(fm *Frame, spec string, r diag.Ranger) (*Ns, error) {
 	// path separator because module specs are meant to be platform independent. If necessary, we
 	// translate a module spec to an appropriate path for the platform.
 	if strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../") {
+		if !fm.srcMeta.IsFile && isRemoteSpec(fm.srcMeta.Name) {
+			// Relative to the URL of the remote module.
+			base, err := url.Parse(fm.srcMeta.Name)
+			if err != nil {
+				return nil, err
+			}
+			ref, err := url.Parse(spec + ".elv")
+			if err != nil {
+				return nil, err
+			}
+			return useRemote(fm, base.ResolveReference(ref).String(), r)
+		}
 		var dir string
 		if fm.srcMeta.IsFile {
 			dir = filepath.Dir(fm.srcMeta.Name)
@@ -328,8 +355,12 @@ func use(fm *Frame, spec string, r diag.Ranger) (*Ns, error) {
 		return useFromFile(fm, spec, path, r)
 	}
 
+	if isRemoteSpec(spec) {
+		return useRemote(fm, spec, r)
+	}
+
 	// Handle imports of pre-defined modules like `builtin` and `str`.
-	if ns, ok := fm.Evaler.modules[spec]; ok {
+	if ns, ok := fm.Evaler.internalModule(spec); ok {
 		return ns, nil
 	}
 	if code, ok := fm.Evaler.BundledModules[spec]; ok {
@@ -353,6 +384,28 @@ func use(fm *Frame, spec string, r diag.Ranger) (*Ns, error) {
 	return nil, NoSuchModule{spec}
 }
 
+func isRemoteSpec(spec string) bool {
+	return strings.HasPrefix(spec, "https://") || strings.HasPrefix(spec, "http://")
+}
+
+// TODO: Make access to fm.Evaler.modules concurrency-safe.
+func useRemote(fm *Frame, url string, r diag.Ranger) (*Ns, error) {
+	if !strings.HasPrefix(url, "https://") {
+		return nil, errRemoteModuleNotHTTPS
+	}
+	if fm.Evaler.LoadRemoteModule == nil {
+		return nil, errRemoteModuleNotSupported
+	}
+	if ns, ok := fm.Evaler.modules[url]; ok {
+		return ns, nil
+	}
+	code, err := fm.Evaler.LoadRemoteModule(url)
+	if err != nil {
+		return nil, err
+	}
+	return evalModule(fm, url, parse.Source{Name: url, Code: code}, r)
+}
+
 // TODO: Make access to fm.Evaler.modules concurrency-safe.
 func useFromFile(fm *Frame, spec, path string, r diag.Ranger) (*Ns, error) {
 	if ns, ok := fm.Evaler.modules[path]; ok {
@@ -379,12 +432,27 @@ func useFromFile(fm *Frame, spec, path string, r diag.Ranger) (*Ns, error) {
 	if err != nil {
 		return nil, err
 	}
-	ns, ok := sym.(**Ns)
+	ns, ok := nsFromPluginSymbol(fm.Evaler, sym)
 	if !ok {
 		return nil, NoSuchModule{spec}
 	}
-	fm.Evaler.modules[path] = *ns
-	return *ns, nil
+	fm.Evaler.modules[path] = ns
+	return ns, nil
+}
+
+// Converts the symbol named Ns in a plugin to a namespace. The symbol may be
+// either a variable of type *Ns, or a function of type func(*Evaler) *Ns,
+// which is useful when the namespace needs access to the Evaler.
+func nsFromPluginSymbol(ev *Evaler, sym any) (*Ns, bool) {
+	switch sym := sym.(type) {
+	case **Ns:
+		return *sym, *sym != nil
+	case func(*Evaler) *Ns:
+		ns := sym(ev)
+		return ns, ns != nil
+	default:
+		return nil, false
+	}
 }
 
 func readFileUTF8(fname string) (string, error) {
@@ -509,6 +577,9 @@ func compileIf(cp *compiler, fn *parse.Form) effectOp {
 		return nil
 	}
 
+	for _, condNode := range condNodes {
+		cp.lintCondition(condNode)
+	}
 	condOps := cp.compoundOps(condNodes)
 	bodyOps := cp.primaryOps(bodyNodes)
 	var elseOp valuesOp
@@ -556,6 +627,7 @@ func compileWhile(cp *compiler, fn *parse.Form) effectOp {
 		return nil
 	}
 
+	cp.lintCondition(condNode)
 	condOp := cp.compoundOp(condNode)
 	bodyOp := cp.primaryOp(bodyNode)
 	var elseOp valuesOp
@@ -786,7 +858,234 @@ func (op *tryOp) exec(fm *Frame) Exception {
 	return fm.errorp(op, err)
 }
 
-// PragmaForm = 'pragma' 'fallback-resolver' '=' { Compound }
+// MatchForm = 'match' Compound { MatchArm } [ 'else' Lambda ]
+// MatchArm = ( 'is' | 'kind' | 'list' | 'map' ) { Compound } Lambda
+func compileMatch(cp *compiler, fn *parse.Form) effectOp {
+	args := getArgs(cp, fn)
+	valueNode := args.get(0, "value").any()
+	var arms []matchArm
+	var elseNode *parse.Primary
+	i := 1
+	for args.has(i) {
+		kwNode := fn.Args[i]
+		kw := args.get(i, "match arm keyword").stringLiteral()
+		if kw == "else" {
+			elseNode = args.get(i+1, "else body").thunk()
+			break
+		}
+		// The patterns extend until the body, which is the first lambda.
+		i++
+		begin := i
+		for args.has(i) {
+			if _, ok := cmpd.Lambda(fn.Args[i]); ok {
+				break
+			}
+			i++
+		}
+		patternNodes := fn.Args[begin:i]
+		bodyNode := args.get(i, kw+" body").thunk()
+		i++
+
+		var pattern matchPattern
+		switch kw {
+		case "is":
+			if len(patternNodes) == 0 {
+				cp.errorpf(kwNode, "is must be followed by at least one value")
+			}
+			pattern = isPattern{cp.compoundOps(patternNodes)}
+		case "kind":
+			if len(patternNodes) == 0 {
+				cp.errorpf(kwNode, "kind must be followed by at least one kind name")
+			}
+			kinds := make([]string, len(patternNodes))
+			for j, n := range patternNodes {
+				kinds[j] = stringLiteralOrError(cp, n, "kind name")
+			}
+			pattern = kindPattern{kinds}
+		case "list":
+			pattern = listPattern{kwNode.Range(),
+				cp.parseCompoundLValues(patternNodes, setLValue|newLValue)}
+		case "map":
+			keys := make([]string, len(patternNodes))
+			lvalues := make([]lvalue, len(patternNodes))
+			for j, n := range patternNodes {
+				lvalues[j] = cp.compileOneLValue(n, setLValue|newLValue)
+				if len(n.Indexings) == 1 {
+					_, keys[j] = SplitSigil(n.Indexings[0].Head.Value)
+				}
+			}
+			pattern = mapPattern{keys, lvalues}
+		default:
+			args.errorpf(kwNode,
+				"unknown match arm keyword %s, must be one of is, kind, list, map and else",
+				parse.Quote(kw))
+			return nil
+		}
+		if bodyNode == nil {
+			break
+		}
+		// The body is compiled after the pattern, so that it can use the
+		// variables declared by the pattern.
+		arms = append(arms, matchArm{pattern, cp.primaryOp(bodyNode)})
+	}
+	if !args.finish() {
+		return nil
+	}
+
+	valueOp := cp.compoundOp(valueNode)
+	var elseOp valuesOp
+	if elseNode != nil {
+		elseOp = cp.primaryOp(elseNode)
+	}
+	return &matchOp{fn.Range(), valueOp, arms, elseOp}
+}
+
+type matchOp struct {
+	diag.Ranging
+	valueOp valuesOp
+	arms    []matchArm
+	elseOp  valuesOp
+}
+
+type matchArm struct {
+	pattern matchPattern
+	bodyOp  valuesOp
+}
+
+// A pattern in a match arm. The match method returns whether the value
+// matches, and if it does, binds the variables in the pattern.
+type matchPattern interface {
+	match(fm *Frame, v any) (bool, Exception)
+}
+
+func (op *matchOp) exec(fm *Frame) Exception {
+	v, err := evalForValue(fm, op.valueOp, "value being matched")
+	if err != nil {
+		return fm.errorp(op, err)
+	}
+	for _, arm := range op.arms {
+		matched, exc := arm.pattern.match(fm, v)
+		if exc != nil {
+			return exc
+		}
+		if matched {
+			body := execLambdaOp(fm, arm.bodyOp)
+			return fm.errorp(op, body.Call(fm.Fork("match body"), NoArgs, NoOpts))
+		}
+	}
+	if op.elseOp != nil {
+		elseBody := execLambdaOp(fm, op.elseOp)
+		return fm.errorp(op, elseBody.Call(fm.Fork("match else"), NoArgs, NoOpts))
+	}
+	return nil
+}
+
+// Matches values equal to any of the values.
+type isPattern struct{ valueOps []valuesOp }
+
+func (p isPattern) match(fm *Frame, v any) (bool, Exception) {
+	for _, valueOp := range p.valueOps {
+		values, exc := valueOp.exec(fm.Fork("match is"))
+		if exc != nil {
+			return false, exc
+		}
+		for _, value := range values {
+			if vals.Equal(v, value) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// Matches values of any of the kinds.
+type kindPattern struct{ kinds []string }
+
+func (p kindPattern) match(fm *Frame, v any) (bool, Exception) {
+	kind := vals.Kind(v)
+	for _, k := range p.kinds {
+		if k == kind {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Matches lists whose elements can be assigned to the lvalues, with the same
+// rules as the var special command.
+type listPattern struct {
+	diag.Ranging
+	lhs lvaluesGroup
+}
+
+func (p listPattern) match(fm *Frame, v any) (bool, Exception) {
+	if vals.Kind(v) != "list" {
+		return false, nil
+	}
+	elems, err := vals.Collect(v)
+	if err != nil {
+		return false, fm.errorp(p, err)
+	}
+	n, rest := len(p.lhs.lvalues), p.lhs.rest
+	if rest == -1 && len(elems) != n || rest != -1 && len(elems) < n-1 {
+		return false, nil
+	}
+	for i, lv := range p.lhs.lvalues {
+		var value any
+		switch {
+		case rest == -1 || i < rest:
+			value = elems[i]
+		case i == rest:
+			value = vals.MakeList(elems[rest : len(elems)-(n-1-rest)]...)
+		default:
+			value = elems[len(elems)-(n-i)]
+		}
+		exc := bind(fm, lv, value)
+		if exc != nil {
+			return false, exc
+		}
+	}
+	return true, nil
+}
+
+// Matches maps that have all the keys, binding each of them to the variable of
+// the same name.
+type mapPattern struct {
+	keys    []string
+	lvalues []lvalue
+}
+
+func (p mapPattern) match(fm *Frame, v any) (bool, Exception) {
+	if vals.Kind(v) != "map" {
+		return false, nil
+	}
+	for _, key := range p.keys {
+		if !vals.HasKey(v, key) {
+			return false, nil
+		}
+	}
+	for i, key := range p.keys {
+		value, err := vals.Index(v, key)
+		if err != nil {
+			return false, fm.errorp(p.lvalues[i], err)
+		}
+		exc := bind(fm, p.lvalues[i], value)
+		if exc != nil {
+			return false, exc
+		}
+	}
+	return true, nil
+}
+
+func bind(fm *Frame, lv lvalue, value any) Exception {
+	variable, err := derefLValue(fm, lv)
+	if err != nil {
+		return fm.errorp(lv, err)
+	}
+	return set(fm, lv, false, variable, value)
+}
+
+// PragmaForm = 'pragma' Name '=' { Compound }
 func compilePragma(cp *compiler, fn *parse.Form) effectOp {
 	args := getArgs(cp, fn)
 	name := args.get(0, "pragma name").stringLiteral()
@@ -811,6 +1110,16 @@ func compilePragma(cp *compiler, fn *parse.Form) effectOp {
 			cp.errorpf(valueNode,
 				"invalid value for unknown-command: %s", parse.Quote(value))
 		}
+	case "strict":
+		value := stringLiteralOrError(cp, valueNode, "value for strict")
+		switch value {
+		case "on":
+			cp.currentPragma().strict = true
+		case "off":
+			cp.currentPragma().strict = false
+		default:
+			cp.errorpf(valueNode, "invalid value for strict: %s", parse.Quote(value))
+		}
 	default:
 		cp.errorpf(fn.Args[0], "unknown pragma %s", parse.Quote(name))
 	}
@@ -29,6 +29,36 @@ func TestPragma(t *testing.T) {
 	// Actual effect of the unknown-command pragma is tested in TestCommand_External
 }
 
+func TestPragma_Strict(t *testing.T) {
+	testutil.Unsetenv(t, "STRICT_UNSET")
+	testutil.Unsetenv(t, "STRICT_NEW")
+	testutil.Setenv(t, "STRICT_SET", "foo")
+	Test(t,
+		That("pragma strict = bad").DoesNotCompile("invalid value for strict: bad"),
+
+		// Unset environment variables
+		That("put $E:STRICT_UNSET").Puts(""),
+		That("pragma strict = on", "put x$E:STRICT_UNSET").
+			Throws(ErrorWithMessage("environment variable $E:STRICT_UNSET is not set")),
+		That("pragma strict = on", "put $E:STRICT_SET").Puts("foo"),
+		That("pragma strict = on", "has-env STRICT_UNSET").Puts(false),
+		That("pragma strict = on", "set E:STRICT_NEW = bar; put $E:STRICT_NEW").
+			Puts("bar"),
+
+		// Command heads containing whitespace
+		That("var cmd = 'ls -l'; $cmd").
+			Throws(ErrorWithType(errs.BadValue{})),
+		That("pragma strict = on", "var cmd = 'ls -l'; $cmd").
+			Throws(ErrorWithMessage("command 'ls -l' contains whitespace; strings are not split into words, use a list and $@ instead")),
+
+		// Scoping
+		That("{ pragma strict = on }", "put $E:STRICT_UNSET").Puts(""),
+		That("pragma strict = on", "{ put $E:STRICT_UNSET }").
+			Throws(ErrorWithMessage("environment variable $E:STRICT_UNSET is not set")),
+		That("pragma strict = on", "{ pragma strict = off; put $E:STRICT_UNSET }").Puts(""),
+	)
+}
+
 func TestVar(t *testing.T) {
 	// NOTE: TestClosure has more tests for the interaction between assignment
 	// and variable scoping.
@@ -95,6 +125,53 @@ func TestVar(t *testing.T) {
 		That("var a'b'").DoesNotCompile("lvalue may not be composite expressions"),
 		// Braced lists must not have any indices when used as a lvalue.
 		That("var {a b}[0] = x y").DoesNotCompile("braced list may not have indices when used as lvalue"),
+
+		// List patterns
+		That("var [x y] = [a b]", "put $x $y").Puts("a", "b"),
+		That("var [x @y] = [a b c]", "put $x $y").Puts("a", vals.MakeList("b", "c")),
+		That("var [x @y z] = [a b]", "put $x $y $z").Puts("a", vals.EmptyList, "b"),
+		That("var [] = []").DoesNothing(),
+		That("var [x] y = [a] b", "put $x $y").Puts("a", "b"),
+		// Declaring without assigning
+		That("var [x y]", "put $x $y").Puts(nil, nil),
+		// Map patterns
+		That("var [&name=n &age=a] = [&name=elf &age=3 &other=x]", "put $n $a").
+			Puts("elf", "3"),
+		That("var [&] = [&k=v]").DoesNothing(),
+		// Nested patterns
+		That("var [x [y @z]] [&k=[&l=w]] = [a [b c d]] [&k=[&l=e]]", "put $x $y $z $w").
+			Puts("a", "b", vals.MakeList("c", "d"), "e"),
+		// Shape mismatch
+		That("var [x y] = [a]").Throws(
+			errs.ArityMismatch{What: "list assigned to pattern",
+				ValidLow: 2, ValidHigh: 2, Actual: 1},
+			"[x y]"),
+		That("var [x y @z] = [a]").Throws(
+			errs.ArityMismatch{What: "list assigned to pattern",
+				ValidLow: 2, ValidHigh: -1, Actual: 1},
+			"[x y @z]"),
+		That("var [x] = foo").Throws(
+			errs.BadValue{What: "value assigned to list pattern",
+				Valid: "list", Actual: "string"},
+			"[x]"),
+		That("var [x [y]] = [a b]").Throws(
+			errs.BadValue{What: "value assigned to list pattern",
+				Valid: "list", Actual: "string"},
+			"[y]"),
+		That("var [&k=x] = [a]").Throws(
+			errs.BadValue{What: "value assigned to map pattern",
+				Valid: "map", Actual: "list"},
+			"[&k=x]"),
+		That("var [&k=x] = [&l=v]").Throws(ErrorWithMessage("no such key: k"), "[&k=x]"),
+		// Pattern syntax errors
+		That("var [x @y @z]").DoesNotCompile("at most one rest variable is allowed"),
+		That("var [x][0]").DoesNotCompile("list or map pattern may not have indices"),
+		That("var [&(put k)=x]").DoesNotCompile(
+			"key in map pattern must be string literal, found primary expression of type OutputCapture"),
+		That("var [&k]").DoesNotCompile("key in map pattern must be followed by an lvalue"),
+		That("var [&k=@x]").DoesNotCompile("rest variable not allowed"),
+		// Patterns are only allowed in assignments
+		That("for [x y] [] { }").DoesNotCompile("lvalue must be valid literal variable names"),
 	)
 }
 
@@ -135,6 +212,12 @@ func TestSet(t *testing.T) {
 		// = is required.
 		That("var x; set x").DoesNotCompile("need = and right-hand-side"),
 
+		// Patterns
+		That("var x y = a b; set [x y] = [$y $x]; put $x $y").Puts("b", "a"),
+		That("var li = [a b]; var x; set [li[1] [&k=x]] = [c [&k=d]]; put $li $x").
+			Puts(vals.MakeList("a", "c"), "d"),
+		That("set [x] = [a]").DoesNotCompile("cannot find variable $x"),
+
 		// set a non-exist environment
 		That("has-env X; set E:X = x; get-env X; unset-env X").
 			Puts(false, "x"),
@@ -157,6 +240,8 @@ func TestTmp(t *testing.T) {
 		That("var x = foo; put $x; { tmp x = bar; put $x }; put $x").
 			Puts("foo", "bar", "foo"),
 
+		That("var x y = a b; { tmp [x [&k=y]] = [c [&k=d]]; put $x $y }; put $x $y").
+			Puts("c", "d", "a", "b"),
 		That("var x; tmp x = y").DoesNotCompile("tmp may only be used inside a function"),
 		That("{ tmp x = y }").DoesNotCompile("cannot find variable $x"),
 
@@ -370,6 +455,73 @@ func TestTry_ExceptIsDeprecated(t *testing.T) {
 		`"except" is deprecated; use "catch" instead`, 18)
 }
 
+func TestMatch(t *testing.T) {
+	Test(t,
+		// is
+		That("match foo is foo { put good } else { put bad }").Puts("good"),
+		That("match bar is foo bar { put good }").Puts("good"),
+		That("match (num 1) is 1 { put bad } is (num 1) { put good }").Puts("good"),
+		That("match [a b] is [a b] { put good }").Puts("good"),
+		That("match foo is (put bar baz) { put bad } else { put good }").Puts("good"),
+		That("match foo is (fail bad) { }").Throws(FailError{"bad"}, "fail bad"),
+		// Only the values of arms up to the matching one are evaluated
+		That("match foo is foo { put good } is (fail bad) { }").Puts("good"),
+
+		// kind
+		That("match (num 1) kind string { put bad } kind number { put good }").
+			Puts("good"),
+		That("match $nil kind string nil { put good }").Puts("good"),
+
+		// list
+		That("match [] list { put empty }").Puts("empty"),
+		That("match [a b] list x { put bad } list x y { put $y $x }").
+			Puts("b", "a"),
+		That("match [a b c d] list x @y z { put $x $y $z }").
+			Puts("a", vals.MakeList("b", "c"), "d"),
+		That("match [a] list x y @z { put bad } else { put good }").Puts("good"),
+		That("match foo list @x { put bad } else { put good }").Puts("good"),
+		// Pattern variables are declared in the enclosing scope, like the
+		// variable of for.
+		That("match [a] list x { }; put $x").Puts("a"),
+
+		// map
+		That("match [&name=elf &age=3] map name age { put $name $age }").
+			Puts("elf", "3"),
+		That("match [&name=elf] map name age { put bad } map name { put $name }").
+			Puts("elf"),
+		That("match [a] map name { put bad } else { put good }").Puts("good"),
+		That("match [&] map { put good }").Puts("good"),
+
+		// else and no match
+		That("match foo is bar { put bad } else { put good }").Puts("good"),
+		That("match foo is bar { put bad }").DoesNothing(),
+		That("match foo else { put good }").Puts("good"),
+		// Exception in body
+		That("match foo is foo { fail body }").Throws(FailError{"body"}),
+		// The value must be a single value
+		That("match (put a b) else { }").Throws(
+			errs.ArityMismatch{What: "value being matched",
+				ValidLow: 1, ValidHigh: 1, Actual: 2}),
+
+		// Compilation errors
+		That("match").DoesNotCompile("need value"),
+		That("match foo bad { }").DoesNotCompile(
+			"unknown match arm keyword bad, must be one of is, kind, list, map and else"),
+		That("match foo is { }").DoesNotCompile("is must be followed by at least one value"),
+		That("match foo kind { }").DoesNotCompile("kind must be followed by at least one kind name"),
+		That("match foo kind (put x) { }").DoesNotCompile(
+			"kind name must be string literal, found primary expression of type OutputCapture"),
+		That("match foo is foo").DoesNotCompile("need is body"),
+		That("match foo is foo {|x| }").DoesNotCompile("is body must not have arguments"),
+		That("match foo else").DoesNotCompile("need else body"),
+		That("match foo else { } is foo { }").DoesNotCompile("superfluous arguments"),
+		That("match foo list @a @b { }").DoesNotCompile("at most one rest variable is allowed"),
+		That("match foo list nil { }").DoesNotCompile("variable $nil is read-only"),
+		That("match foo map @a { }").DoesNotCompile("rest variable not allowed"),
+		That("match foo map a[0] { }").DoesNotCompile("new variable $a must not have indices"),
+	)
+}
+
 func TestWhile(t *testing.T) {
 	Test(t,
 		That("var x = (num 0)", "while (< $x 4) { put $x; set x = (+ $x 1) }").
@@ -569,6 +721,46 @@ func TestUse(t *testing.T) {
 	)
 }
 
+func TestUse_Remote(t *testing.T) {
+	setup := func(ev *Evaler) {
+		ev.LoadRemoteModule = func(url string) (string, error) {
+			switch url {
+			case "https://example.com/lorem.elv":
+				return "var name = lorem", nil
+			case "https://example.com/has-init.elv":
+				return "put has-init", nil
+			case "https://example.com/lib/uses-relative.elv":
+				return "use ./sibling; use ../lorem; var name = $sibling:name' '$lorem:name", nil
+			case "https://example.com/lib/sibling.elv":
+				return "var name = sibling", nil
+			}
+			return "", errors.New("not found: " + url)
+		}
+	}
+
+	TestWithSetup(t, setup,
+		That("use https://example.com/lorem.elv; put $lorem:name").Puts("lorem"),
+		// Renaming module
+		That("use https://example.com/lorem.elv l; put $l:name").Puts("lorem"),
+		// module is cached after first use
+		That("use https://example.com/has-init.elv; use https://example.com/has-init.elv").
+			Puts("has-init"),
+		// relative uses in remote modules are resolved against their URLs
+		That("use https://example.com/lib/uses-relative.elv u; put $u:name").
+			Puts("sibling lorem"),
+		// errors from the loader are propagated
+		That("use https://example.com/unknown").
+			Throws(ErrorWithMessage("not found: https://example.com/unknown")),
+		That("use http://example.com/lorem.elv").
+			Throws(ErrorWithMessage("remote modules must be loaded over HTTPS")),
+	)
+
+	Test(t,
+		That("use https://example.com/lorem.elv").
+			Throws(ErrorWithMessage("remote modules are not supported")),
+	)
+}
+
 // Regression test for #1072
 func TestUse_WarnsAboutDeprecatedFeatures(t *testing.T) {
 	testutil.Set(t, &prog.DeprecationLevel, 18)
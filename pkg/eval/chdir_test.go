@@ -2,6 +2,7 @@ package eval_test
 
 import (
 	"os"
+	"reflect"
 	"testing"
 
 	"src.elv.sh/pkg/env"
@@ -17,9 +18,10 @@ func TestChdir(t *testing.T) {
 
 	ev := NewEvaler()
 
-	argDirInBefore, argDirInAfter := "", ""
+	argDirInBefore := ""
+	var eventInAfter ChdirEvent
 	ev.BeforeChdir = append(ev.BeforeChdir, func(dir string) { argDirInBefore = dir })
-	ev.AfterChdir = append(ev.AfterChdir, func(dir string) { argDirInAfter = dir })
+	ev.AfterChdir = append(ev.AfterChdir, func(e ChdirEvent) { eventInAfter = e })
 
 	back := saveWd()
 	defer back()
@@ -37,9 +39,9 @@ func TestChdir(t *testing.T) {
 		t.Errorf("Chdir called before-hook with %q, want %q",
 			argDirInBefore, dst)
 	}
-	if argDirInAfter != dst {
-		t.Errorf("Chdir called before-hook with %q, want %q",
-			argDirInAfter, dst)
+	if want := (ChdirEvent{Path: dst, Dir: dst}); eventInAfter != want {
+		t.Errorf("Chdir called after-hook with %v, want %v",
+			eventInAfter, want)
 	}
 }
 
@@ -60,6 +62,28 @@ func TestChdirElvishHooks(t *testing.T) {
 	)
 }
 
+func TestChdir_PublishesEventsForAllChanges(t *testing.T) {
+	dst := testutil.TempDir(t)
+	back := saveWd()
+	defer back()
+	orig := must.OK1(os.Getwd())
+
+	ev := NewEvaler()
+	var dirs []string
+	ev.AfterChdir = append(ev.AfterChdir, func(e ChdirEvent) { dirs = append(dirs, e.Dir) })
+
+	code := "with-cd " + parse.Quote(dst) + " { }; { tmp pwd = " + parse.Quote(dst) + " }"
+	err := ev.Eval(parse.Source{Name: "[test]", Code: code}, EvalCfg{})
+	if err != nil {
+		t.Fatalf("Eval => error %v", err)
+	}
+
+	wantDirs := []string{dst, orig, dst, orig}
+	if !reflect.DeepEqual(dirs, wantDirs) {
+		t.Errorf("got events with dirs %v, want %v", dirs, wantDirs)
+	}
+}
+
 func TestChdirError(t *testing.T) {
 	testutil.InTempDir(t)
 
@@ -3,6 +3,7 @@ package eval
 import (
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"sync/atomic"
 
@@ -45,8 +46,43 @@ func (op chunkOp) exec(fm *Frame) Exception {
 
 func (cp *compiler) pipelineOp(n *parse.Pipeline) effectOp {
 	formOps := cp.formOps(n.Forms)
+	valueInputs := cp.pipeOpts(n)
 
-	return &pipelineOp{n.Range(), n.Background, parse.SourceText(n), formOps}
+	return &pipelineOp{n.Range(), n.Background, parse.SourceText(n), formOps, valueInputs}
+}
+
+// Returns how value inputs are converted when fed to external commands, for
+// each form of the pipeline, as specified by the &to option of the pipe before
+// it.
+func (cp *compiler) pipeOpts(n *parse.Pipeline) []string {
+	if len(n.PipeOpts) == 0 {
+		return nil
+	}
+	valueInputs := make([]string, len(n.Forms))
+	i := 0
+	for _, opt := range n.PipeOpts {
+		for i < len(n.Forms) && n.Forms[i].Range().From < opt.Range().To {
+			i++
+		}
+		if i == len(n.Forms) {
+			// Only possible when compiling an incomplete pipeline.
+			break
+		}
+		if key, _ := cmpd.StringLiteral(opt.Key); key != "to" {
+			cp.errorpf(opt.Key, "unknown pipe option")
+			continue
+		}
+		mode, ok := "", false
+		if opt.Value != nil {
+			mode, ok = cmpd.StringLiteral(opt.Value)
+		}
+		if !ok || !isExternalValueInputMode(mode) {
+			cp.errorpf(opt, "value of &to must be one of %s", externalValueInputModes)
+			continue
+		}
+		valueInputs[i] = mode
+	}
+	return valueInputs
 }
 
 func (cp *compiler) pipelineOps(ns []*parse.Pipeline) []effectOp {
@@ -62,20 +98,38 @@ type pipelineOp struct {
 	bg     bool
 	source string
 	subops []effectOp
+	// How value inputs fed to external commands are converted in each form,
+	// or nil if all of them use the default.
+	valueInputs []string
 }
 
-const pipelineChanBufferSize = 32
+// A value pipe between two forms of a pipeline.
+type valuePipe struct {
+	// The source code of the pipeline.
+	pipeline string
+	// The index of the form writing to the pipe.
+	index int
+	ch    chan any
+}
 
 func (op *pipelineOp) exec(fm *Frame) Exception {
 	if fm.IsInterrupted() {
 		return fm.errorp(op, ErrInterrupted)
 	}
 
+	// The job started by this pipeline, if it is not part of another
+	// pipeline.
+	var fgJob *job
 	if op.bg {
 		fm = fm.Fork("background job" + op.source)
 		fm.intCh = nil
 		fm.background = true
-		fm.Evaler.addNumBgJobs(1)
+		fm.job = newJob(op.source, true, false)
+		fm.Evaler.addBgJob(fm.job)
+	} else if fm.job == nil {
+		fgJob = newJob(op.source, false, fm.jobControl)
+		fm = fm.Fork("job " + op.source)
+		fm.job = fgJob
 	}
 
 	nforms := len(op.subops)
@@ -85,6 +139,8 @@ func (op *pipelineOp) exec(fm *Frame) Exception {
 	excs := make([]Exception, nforms)
 
 	var nextIn *Port
+	bufferSize := fm.Evaler.getPipeBufferSize()
+	var pipes []*valuePipe
 
 	// For each form, create a dedicated evalCtx and run asynchronously
 	for i, formOp := range op.subops {
@@ -92,6 +148,9 @@ func (op *pipelineOp) exec(fm *Frame) Exception {
 		inputIsPipe := i > 0
 		outputIsPipe := i < nforms-1
 		if inputIsPipe {
+			if op.valueInputs != nil {
+				nextIn.valueInput = op.valueInputs[i]
+			}
 			newFm.ports[0] = nextIn
 		}
 		if outputIsPipe {
@@ -100,9 +159,13 @@ func (op *pipelineOp) exec(fm *Frame) Exception {
 			// os.Pipe sets O_CLOEXEC, which is what we want.
 			reader, writer, e := os.Pipe()
 			if e != nil {
+				fm.Evaler.removePipes(pipes)
 				return fm.errorpf(op, "failed to create pipe: %s", e)
 			}
-			ch := make(chan any, pipelineChanBufferSize)
+			ch := make(chan any, bufferSize)
+			pipe := &valuePipe{op.source, i, ch}
+			fm.Evaler.addPipe(pipe)
+			pipes = append(pipes, pipe)
 			sendStop := make(chan struct{})
 			sendError := new(error)
 			readerGone := new(int32)
@@ -141,7 +204,8 @@ func (op *pipelineOp) exec(fm *Frame) Exception {
 		// Background job, wait for form termination asynchronously.
 		go func() {
 			wg.Wait()
-			fm.Evaler.addNumBgJobs(-1)
+			fm.Evaler.removePipes(pipes)
+			fm.Evaler.removeBgJob(fm.job)
 			if notify := fm.Evaler.BgJobNotify; notify != nil {
 				msg := "job " + op.source + " finished"
 				err := MakePipelineError(excs)
@@ -156,6 +220,10 @@ func (op *pipelineOp) exec(fm *Frame) Exception {
 		return nil
 	}
 	wg.Wait()
+	fm.Evaler.removePipes(pipes)
+	if fgJob != nil {
+		fgJob.finish()
+	}
 	return fm.errorp(op, MakePipelineError(excs))
 }
 
@@ -181,7 +249,7 @@ func (cp *compiler) formOp(n *parse.Form) effectOp {
 			lvalues := cp.parseIndexingLValue(a.Left, setLValue|newLValue)
 			tempLValues = append(tempLValues, lvalues.lvalues...)
 		}
-		logger.Println("temporary assignment of", len(n.Assignments), "pairs")
+		logger.Debugf("temporary assignment of %d pairs", len(n.Assignments))
 	}
 
 	redirOps := cp.redirOps(n.Redirs)
@@ -210,7 +278,7 @@ func (cp *compiler) formBody(n *parse.Form) formBody {
 		// Head is a literal string: resolve to function or external (special
 		// commands are already handled above).
 		if _, fnRef := resolveCmdHeadInternally(cp, head, n.Head); fnRef != nil {
-			headOp = variableOp{n.Head.Range(), false, head + FnSuffix, fnRef}
+			headOp = variableOp{n.Head.Range(), false, head + FnSuffix, fnRef, false}
 		} else {
 			cp.autofixUnresolvedVar(head + FnSuffix)
 			if cp.currentPragma().unknownCommandIsExternal || fsutil.DontSearch(head) {
@@ -224,9 +292,10 @@ func (cp *compiler) formBody(n *parse.Form) formBody {
 		headOp = cp.compoundOp(n.Head)
 	}
 
+	cp.lintForm(n)
 	argOps := cp.compoundOps(n.Args)
 	optsOp := cp.mapPairs(n.Opts)
-	return formBody{ordinaryCmd: ordinaryCmd{headOp, argOps, optsOp}}
+	return formBody{ordinaryCmd: ordinaryCmd{headOp, argOps, optsOp, cp.currentPragma().strict}}
 }
 
 func (cp *compiler) formOps(ns []*parse.Form) []effectOp {
@@ -256,6 +325,8 @@ type ordinaryCmd struct {
 	headOp valuesOp
 	argOps []valuesOp
 	optsOp *mapPairsOp
+	// Whether the command is in strict mode.
+	strict bool
 }
 
 func (op *formOp) exec(fm *Frame) (errRet Exception) {
@@ -284,7 +355,7 @@ func (op *formOp) exec(fm *Frame) (errRet Exception) {
 			}
 			val := v.Get()
 			saveVals = append(saveVals, val)
-			logger.Printf("saved %s = %s", v, val)
+			logger.Debugf("saved %s = %s", v, val)
 		}
 		// Do assignment.
 		for _, subop := range op.tempAssignOps {
@@ -308,7 +379,7 @@ func (op *formOp) exec(fm *Frame) (errRet Exception) {
 				if err != nil {
 					errRet = fm.errorp(op, err)
 				}
-				logger.Printf("restored %s = %s", v, val)
+				logger.Debugf("restored %s = %s", v, val)
 			}
 		}()
 	}
@@ -336,7 +407,7 @@ func (op *formOp) exec(fm *Frame) (errRet Exception) {
 		return nil
 	}
 
-	headFn, err := evalForCommand(fm, cmd.headOp, "command")
+	headFn, err := evalForCommand(fm, cmd.headOp, "command", cmd.strict)
 	if err != nil {
 		return fm.errorp(cmd.headOp, err)
 	}
@@ -373,7 +444,7 @@ func (op *formOp) exec(fm *Frame) (errRet Exception) {
 	return &exception{err, fm.traceback}
 }
 
-func evalForCommand(fm *Frame, op valuesOp, what string) (Callable, error) {
+func evalForCommand(fm *Frame, op valuesOp, what string, strict bool) (Callable, error) {
 	value, err := evalForValue(fm, op, what)
 	if err != nil {
 		return nil, err
@@ -385,6 +456,12 @@ func evalForCommand(fm *Frame, op valuesOp, what string) (Callable, error) {
 		if fsutil.DontSearch(value) {
 			return NewExternalCmd(value), nil
 		}
+		if strict && strings.ContainsAny(value, " \t\n") {
+			// Most likely a command line stored in a string, expecting it
+			// to be split into words like in POSIX shells. Paths are not
+			// checked, since they may well contain whitespace.
+			return nil, fm.errorpf(op, "%s %s contains whitespace; strings are not split into words, use a list and $@ instead", what, parse.Quote(value))
+		}
 	}
 	return nil, fm.errorp(op, errs.BadValue{
 		What:   what,
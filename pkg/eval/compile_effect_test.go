@@ -35,6 +35,23 @@ func TestPipeline(t *testing.T) {
 	)
 }
 
+func TestPipeline_BufferSize(t *testing.T) {
+	Test(t,
+		That(`put $pipe-buffer-size`).Puts(32),
+		// Values are still passed through with an unbuffered pipe.
+		That(`{ tmp pipe-buffer-size = 0; range 100 | count }`).Puts(100),
+		That(`{ tmp pipe-buffer-size = 5; put a | put (-pipe-buffers)[capacity] }`).
+			Puts(5),
+
+		That(`set pipe-buffer-size = -1`).Throws(errs.BadValue{
+			What: "pipe buffer size", Valid: "integer from 0 to 1048576", Actual: "-1"}),
+		That(`set pipe-buffer-size = (num 2000000)`).Throws(errs.BadValue{
+			What: "pipe buffer size", Valid: "integer from 0 to 1048576", Actual: "(num 2000000)"}),
+		That(`set pipe-buffer-size = foo`).Throws(errs.BadValue{
+			What: "pipe buffer size", Valid: "integer from 0 to 1048576", Actual: "foo"}),
+	)
+}
+
 func TestPipeline_BgJob(t *testing.T) {
 	setup := func(ev *Evaler) {
 		ev.ExtendGlobal(BuildNs().AddNs("file", file.Ns))
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"testing"
 
+	. "src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/eval/errs"
 	. "src.elv.sh/pkg/eval/evaltest"
 	"src.elv.sh/pkg/must"
@@ -26,11 +27,42 @@ func TestPipeline_ReaderGone_Unix(t *testing.T) {
 	)
 }
 
+func TestPipeline_ExternalValueInput(t *testing.T) {
+	Test(t,
+		// Value inputs are dropped by default.
+		That("put foo | cat").DoesNothing(),
+		That("{ put foo; echo bar } | cat").Prints("bar\n"),
+		That("put foo | &to=drop cat").DoesNothing(),
+		That("put foo [a] | &to=string cat").Prints("foo\n[a]\n"),
+		That("put foo [&k=v] | &to=json cat").Prints("\"foo\"\n{\"k\":\"v\"}\n"),
+		That("put 1..=3 | &to=json cat").Prints("[1,2,3]\n"),
+		That("put foo | &to=error cat").Throws(ErrValueInputToExternal),
+		That("echo foo | &to=error cat").Prints("foo\n"),
+		// The option only applies to the pipe it is on.
+		That("put foo | &to=string cat | cat").Prints("foo\n"),
+		That("put foo | &to=string { put bar | cat }").DoesNothing(),
+		// Each external command gets its own bridge, which is stopped when the
+		// command exits.
+		That("put foo | &to=string { cat; echo bar | cat }").Prints("foo\nbar\n"),
+		// The writer can still detect that the reader has gone.
+		That("yes | &to=string true").DoesNothing(),
+		That("try { while $true { put y } | &to=string true } catch { }").
+			DoesNothing(),
+		// Bad options.
+		That("put foo | &bad=json cat").DoesNotCompile("unknown pipe option"),
+		That("put foo | &to=bad cat").DoesNotCompile(
+			"value of &to must be one of drop, json, string, error"),
+		That("var m = json; put foo | &to=$m cat").DoesNotCompile(
+			"value of &to must be one of drop, json, string, error"),
+	)
+}
+
 func TestCommand_External(t *testing.T) {
 	d := testutil.InTempDir(t)
 
 	mustWriteScript("foo", "#!/bin/sh", "echo foo")
 	mustWriteScript("lorem/ipsum", "#!/bin/sh", "echo lorem ipsum")
+	mustWriteScript("my app/tool", "#!/bin/sh", "echo tool")
 
 	testutil.Setenv(t, "PATH", d+"/bin")
 	mustWriteScript("bin/hello", "#!/bin/sh", "echo hello")
@@ -45,6 +77,8 @@ func TestCommand_External(t *testing.T) {
 		That("e:./foo").Prints("foo\n"),
 		// Relative external commands may be a dynamic string.
 		That("var x = ipsum", "lorem/$x").Prints("lorem ipsum\n"),
+		// Paths containing whitespace are allowed even in strict mode.
+		That("pragma strict = on", "var x = './my app/tool'; $x").Prints("tool\n"),
 		// Searched external commands may not be a dynamic string.
 		That("var x = hello; $x").Throws(
 			errs.BadValue{What: "command",
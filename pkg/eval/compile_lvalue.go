@@ -24,6 +24,20 @@ type lvalue struct {
 	ref      *varRef
 	indexOps []valuesOp
 	ends     []int
+	// If non-nil, the lvalue is a list or map pattern, and the other fields
+	// except the range are unused.
+	pattern *lvaluePattern
+}
+
+// A list or map pattern, which assigns the elements of the value assigned to
+// it to the lvalues within.
+type lvaluePattern struct {
+	isMap bool
+	// Used for list patterns.
+	elems lvaluesGroup
+	// Used for map patterns.
+	keys   []string
+	values []lvalue
 }
 
 type lvalueFlag uint
@@ -31,6 +45,8 @@ type lvalueFlag uint
 const (
 	setLValue lvalueFlag = 1 << iota
 	newLValue
+	// Allows list and map patterns.
+	patternLValue
 )
 
 func (cp *compiler) parseCompoundLValues(ns []*parse.Compound, f lvalueFlag) lvaluesGroup {
@@ -64,6 +80,13 @@ func (cp *compiler) parseIndexingLValue(n *parse.Indexing, f lvalueFlag) lvalues
 		}
 		return cp.parseCompoundLValues(n.Head.Braced, f)
 	}
+	if f&patternLValue != 0 && (n.Head.Type == parse.List || n.Head.Type == parse.Map) {
+		if len(n.Indices) > 0 {
+			cp.errorpf(n, "list or map pattern may not have indices")
+			return dummyLValuesGroup
+		}
+		return lvaluesGroup{[]lvalue{cp.parsePatternLValue(n.Head, f)}, -1}
+	}
 	// A basic lvalue.
 	if !parse.ValidLHSVariable(n.Head, true) {
 		cp.errorpf(n.Head, "lvalue must be valid literal variable names")
@@ -100,6 +123,7 @@ func (cp *compiler) parseIndexingLValue(n *parse.Indexing, f lvalueFlag) lvalues
 			name := segs[0]
 			ref = &varRef{localScope,
 				staticVarInfo{name, false, false}, cp.thisScope().add(name), nil}
+			cp.lintDecl(ref.index, name, n)
 		} else {
 			cp.errorpf(n, "cannot create variable $%s; "+
 				"new variables can only be created in the current scope",
@@ -113,7 +137,7 @@ func (cp *compiler) parseIndexingLValue(n *parse.Indexing, f lvalueFlag) lvalues
 	for i, idx := range n.Indices {
 		ends[i+1] = idx.Range().To
 	}
-	lv := lvalue{n.Range(), ref, cp.arrayOps(n.Indices), ends}
+	lv := lvalue{n.Range(), ref, cp.arrayOps(n.Indices), ends, nil}
 	restIndex := -1
 	if sigil == "@" {
 		restIndex = 0
@@ -122,6 +146,26 @@ func (cp *compiler) parseIndexingLValue(n *parse.Indexing, f lvalueFlag) lvalues
 	return lvaluesGroup{[]lvalue{lv}, restIndex}
 }
 
+func (cp *compiler) parsePatternLValue(n *parse.Primary, f lvalueFlag) lvalue {
+	p := &lvaluePattern{}
+	if n.Type == parse.List {
+		p.elems = cp.parseCompoundLValues(n.Elements, f)
+	} else {
+		p.isMap = true
+		p.keys = make([]string, len(n.MapPairs))
+		p.values = make([]lvalue, len(n.MapPairs))
+		for i, pair := range n.MapPairs {
+			p.keys[i] = stringLiteralOrError(cp, pair.Key, "key in map pattern")
+			if pair.Value == nil {
+				cp.errorpf(pair, "key in map pattern must be followed by an lvalue")
+				continue
+			}
+			p.values[i] = cp.compileOneLValue(pair.Value, f)
+		}
+	}
+	return lvalue{Ranging: n.Range(), pattern: p}
+}
+
 type assignOp struct {
 	diag.Ranging
 	lhs  lvaluesGroup
@@ -130,13 +174,9 @@ type assignOp struct {
 }
 
 func (op *assignOp) exec(fm *Frame) Exception {
-	variables := make([]vars.Var, len(op.lhs.lvalues))
-	for i, lvalue := range op.lhs.lvalues {
-		variable, err := derefLValue(fm, lvalue)
-		if err != nil {
-			return fm.errorp(op.lhs.lvalues[i], err)
-		}
-		variables[i] = variable
+	variables, exc := derefLValues(fm, op.lhs)
+	if exc != nil {
+		return exc
 	}
 
 	values, exc := op.rhs.exec(fm)
@@ -144,37 +184,61 @@ func (op *assignOp) exec(fm *Frame) Exception {
 		return exc
 	}
 
-	rest, temp := op.lhs.rest, op.temp
+	return assignGroup(fm, op, "assignment right-hand-side", op.lhs, variables, op.temp, values)
+}
+
+// Dereferences all the lvalues in the group, except for patterns, whose
+// corresponding elements in the result are nil.
+func derefLValues(fm *Frame, g lvaluesGroup) ([]vars.Var, Exception) {
+	variables := make([]vars.Var, len(g.lvalues))
+	for i, lvalue := range g.lvalues {
+		if lvalue.pattern != nil {
+			continue
+		}
+		variable, err := derefLValue(fm, lvalue)
+		if err != nil {
+			return nil, fm.errorp(lvalue, err)
+		}
+		variables[i] = variable
+	}
+	return variables, nil
+}
+
+// Assigns values to a group of lvalues that have been dereferenced to
+// variables by derefLValues. The what and r arguments are used in the error
+// when the number of values doesn't match.
+func assignGroup(fm *Frame, r diag.Ranger, what string, g lvaluesGroup, variables []vars.Var, temp bool, values []any) Exception {
+	rest := g.rest
 	if rest == -1 {
 		if len(variables) != len(values) {
-			return fm.errorp(op, errs.ArityMismatch{What: "assignment right-hand-side",
+			return fm.errorp(r, errs.ArityMismatch{What: what,
 				ValidLow: len(variables), ValidHigh: len(variables), Actual: len(values)})
 		}
 		for i, variable := range variables {
-			exc := set(fm, op.lhs.lvalues[i], temp, variable, values[i])
+			exc := assign(fm, g.lvalues[i], temp, variable, values[i])
 			if exc != nil {
 				return exc
 			}
 		}
 	} else {
 		if len(values) < len(variables)-1 {
-			return fm.errorp(op, errs.ArityMismatch{What: "assignment right-hand-side",
+			return fm.errorp(r, errs.ArityMismatch{What: what,
 				ValidLow: len(variables) - 1, ValidHigh: -1, Actual: len(values)})
 		}
 		for i := 0; i < rest; i++ {
-			exc := set(fm, op.lhs.lvalues[i], temp, variables[i], values[i])
+			exc := assign(fm, g.lvalues[i], temp, variables[i], values[i])
 			if exc != nil {
 				return exc
 			}
 		}
 		restOff := len(values) - len(variables)
-		exc := set(fm, op.lhs.lvalues[rest], temp,
+		exc := set(fm, g.lvalues[rest], temp,
 			variables[rest], vals.MakeList(values[rest:rest+restOff+1]...))
 		if exc != nil {
 			return exc
 		}
 		for i := rest + 1; i < len(variables); i++ {
-			exc := set(fm, op.lhs.lvalues[i], temp, variables[i], values[i+restOff])
+			exc := assign(fm, g.lvalues[i], temp, variables[i], values[i+restOff])
 			if exc != nil {
 				return exc
 			}
@@ -183,6 +247,52 @@ func (op *assignOp) exec(fm *Frame) Exception {
 	return nil
 }
 
+// Assigns a value to an lvalue, which has been dereferenced to variable unless
+// it is a pattern.
+func assign(fm *Frame, lv lvalue, temp bool, variable vars.Var, value any) Exception {
+	if lv.pattern == nil {
+		return set(fm, lv, temp, variable, value)
+	}
+	p := lv.pattern
+	if p.isMap {
+		if kind := vals.Kind(value); kind != "map" {
+			return fm.errorp(lv, errs.BadValue{
+				What: "value assigned to map pattern", Valid: "map", Actual: kind})
+		}
+		for i, key := range p.keys {
+			elem, err := vals.Index(value, key)
+			if err != nil {
+				return fm.errorp(lv, err)
+			}
+			var variable vars.Var
+			if p.values[i].pattern == nil {
+				variable, err = derefLValue(fm, p.values[i])
+				if err != nil {
+					return fm.errorp(p.values[i], err)
+				}
+			}
+			exc := assign(fm, p.values[i], temp, variable, elem)
+			if exc != nil {
+				return exc
+			}
+		}
+		return nil
+	}
+	if kind := vals.Kind(value); kind != "list" {
+		return fm.errorp(lv, errs.BadValue{
+			What: "value assigned to list pattern", Valid: "list", Actual: kind})
+	}
+	elems, err := vals.Collect(value)
+	if err != nil {
+		return fm.errorp(lv, err)
+	}
+	variables, exc := derefLValues(fm, p.elems)
+	if exc != nil {
+		return exc
+	}
+	return assignGroup(fm, lv, "list assigned to pattern", p.elems, variables, temp, elems)
+}
+
 func set(fm *Frame, r diag.Ranger, temp bool, variable vars.Var, value any) Exception {
 	if temp {
 		saved := variable.Get()
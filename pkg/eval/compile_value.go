@@ -3,6 +3,7 @@ package eval
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"src.elv.sh/pkg/diag"
@@ -26,6 +27,13 @@ var outputCaptureBufferSize = 16
 var getHome = fsutil.GetHome
 
 func (cp *compiler) compoundOp(n *parse.Compound) valuesOp {
+	if op := cp.rangeOp(n); op != nil {
+		return op
+	}
+	return cp.nonRangeCompoundOp(n)
+}
+
+func (cp *compiler) nonRangeCompoundOp(n *parse.Compound) valuesOp {
 	if len(n.Indexings) == 0 {
 		return literalValues(n, "")
 	}
@@ -138,6 +146,128 @@ func outerProduct(vs []any, us []any, f func(any, any) (any, error)) ([]any, err
 	return ws, nil
 }
 
+var rangeSep = regexp.MustCompile(`\.\.=?`)
+
+// A piece of a range expression: a separator, a number literal, or an indexing
+// expression whose value is used as a number.
+type rangePiece struct {
+	sep      string
+	literal  *parse.Primary
+	text     string
+	indexing *parse.Indexing
+}
+
+// Returns an op for a range expression like 0..10, 0..=10, 0..10..2 or
+// $a..$b, or nil if n is not a range expression.
+//
+// A range expression is made up of the start, a separator (either .. or ..=),
+// the end, and optionally another .. followed by the step. Each operand is
+// either a number literal, or a variable or output capture, optionally
+// indexed.
+func (cp *compiler) rangeOp(n *parse.Compound) valuesOp {
+	var pieces []rangePiece
+	for _, in := range n.Indexings {
+		switch {
+		case in.Head.Type == parse.Bareword && len(in.Indices) == 0:
+			text := in.Head.Value
+			last := 0
+			for _, loc := range rangeSep.FindAllStringIndex(text, -1) {
+				if loc[0] > last {
+					pieces = append(pieces, rangePiece{literal: in.Head, text: text[last:loc[0]]})
+				}
+				pieces = append(pieces, rangePiece{sep: text[loc[0]:loc[1]]})
+				last = loc[1]
+			}
+			if last < len(text) {
+				pieces = append(pieces, rangePiece{literal: in.Head, text: text[last:]})
+			}
+		case in.Head.Type == parse.Variable || in.Head.Type == parse.OutputCapture:
+			pieces = append(pieces, rangePiece{indexing: in})
+		default:
+			return nil
+		}
+	}
+	if len(pieces) != 3 && len(pieces) != 5 {
+		return nil
+	}
+	for i, piece := range pieces {
+		if i%2 == 1 {
+			if piece.sep == "" || (i == 3 && piece.sep != "..") {
+				return nil
+			}
+		} else if piece.sep != "" ||
+			(piece.literal != nil && vals.ParseNum(piece.text) == nil) {
+			return nil
+		}
+	}
+
+	var operandOps []valuesOp
+	for i := 0; i < len(pieces); i += 2 {
+		if piece := pieces[i]; piece.literal != nil {
+			operandOps = append(operandOps, literalValues(piece.literal, piece.text))
+		} else {
+			operandOps = append(operandOps, cp.indexingOp(piece.indexing))
+		}
+	}
+	return rangeOp{n.Range(), operandOps, pieces[1].sep == "..="}
+}
+
+type rangeOp struct {
+	diag.Ranging
+	operandOps []valuesOp
+	inclusive  bool
+}
+
+func (op rangeOp) exec(fm *Frame) ([]any, Exception) {
+	operands := make([][]any, len(op.operandOps))
+	for i, operandOp := range op.operandOps {
+		vs, exc := operandOp.exec(fm)
+		if exc != nil {
+			return nil, exc
+		}
+		operands[i] = vs
+	}
+	seps := []any{"..", ".."}
+	if op.inclusive {
+		seps[0] = "..="
+	}
+
+	nums := make([]vals.Num, len(operands))
+	var sb strings.Builder
+	for i, vs := range operands {
+		if len(vs) != 1 || vals.ScanToGo(vs[0], &nums[i]) != nil {
+			// Not a range of numbers; evaluate like an ordinary compound
+			// expression instead.
+			return op.concat(fm, operands, seps)
+		}
+		if i > 0 {
+			sb.WriteString(seps[i-1].(string))
+		}
+		sb.WriteString(vals.ToString(vs[0]))
+	}
+	r, err := newNumRange(nums, op.inclusive)
+	if err != nil {
+		return nil, fm.errorp(op, err)
+	}
+	r.str = sb.String()
+	return []any{r}, nil
+}
+
+func (op rangeOp) concat(fm *Frame, operands [][]any, seps []any) ([]any, Exception) {
+	vs := operands[0]
+	for i, us := range operands[1:] {
+		var err error
+		vs, err = outerProduct(vs, seps[i:i+1], vals.Concat)
+		if err == nil {
+			vs, err = outerProduct(vs, us, vals.Concat)
+		}
+		if err != nil {
+			return nil, fm.errorp(op, err)
+		}
+	}
+	return vs, nil
+}
+
 // Errors thrown when globbing.
 var (
 	ErrBadglobPattern          = errors.New("bad globPattern; elvish bug")
@@ -196,8 +326,14 @@ func doTilde(v any) (any, error) {
 	}
 }
 
+// Arrays only appear as indices, which have their own syntax for slices, so
+// range expressions are not recognized in them.
 func (cp *compiler) arrayOp(n *parse.Array) valuesOp {
-	return seqValuesOp{n.Range(), cp.compoundOps(n.Compounds)}
+	ops := make([]valuesOp, len(n.Compounds))
+	for i, cn := range n.Compounds {
+		ops[i] = cp.nonRangeCompoundOp(cn)
+	}
+	return seqValuesOp{n.Range(), ops}
 }
 
 func (cp *compiler) arrayOps(ns []*parse.Array) []valuesOp {
@@ -265,7 +401,8 @@ func (cp *compiler) primaryOp(n *parse.Primary) valuesOp {
 			cp.autofixUnresolvedVar(qname)
 			cp.errorpf(n, "variable $%s not found", parse.Quote(qname))
 		}
-		return &variableOp{n.Range(), sigil != "", qname, ref}
+		mustBeSet := cp.currentPragma().strict && ref.scope == envScope
+		return &variableOp{n.Range(), sigil != "", qname, ref, mustBeSet}
 	case parse.Wildcard:
 		seg, err := wildcardToSegment(parse.SourceText(n))
 		if err != nil {
@@ -309,6 +446,9 @@ type variableOp struct {
 	explode bool
 	qname   string
 	ref     *varRef
+	// Whether it is an error if the variable is not set. Only used for
+	// environment variables in strict mode.
+	mustBeSet bool
 }
 
 func (op variableOp) exec(fm *Frame) ([]any, Exception) {
@@ -316,6 +456,11 @@ func (op variableOp) exec(fm *Frame) ([]any, Exception) {
 	if variable == nil {
 		return nil, fm.errorpf(op, "variable $%s not found", parse.Quote(op.qname))
 	}
+	if op.mustBeSet {
+		if u, ok := variable.(vars.UnsettableVar); ok && !u.IsSet() {
+			return nil, fm.errorpf(op, "environment variable $%s is not set", parse.Quote(op.qname))
+		}
+	}
 	value := variable.Get()
 	if op.explode {
 		vs, err := vals.Collect(value)
@@ -362,12 +507,16 @@ type outputCaptureOp struct {
 }
 
 func (op outputCaptureOp) exec(fm *Frame) ([]any, Exception) {
-	outPort, collect, err := ValueCapturePort()
+	outPort, collect, err := valueCapturePort(fm.Evaler.getCaptureInvalidUTF8())
 	if err != nil {
 		return nil, fm.errorp(op, err)
 	}
 	exc := op.subop.exec(fm.forkWithOutput("[output capture]", outPort))
-	return collect(), exc
+	vs, errLine := collect()
+	if exc == nil && errLine != nil {
+		exc = fm.errorp(op, errLine)
+	}
+	return vs, exc
 }
 
 func (cp *compiler) lambda(n *parse.Primary) valuesOp {
@@ -490,12 +639,14 @@ func (op mapOp) exec(fm *Frame) ([]any, Exception) {
 }
 
 func (cp *compiler) mapPairs(pairs []*parse.MapPair) *mapPairsOp {
+	cp.lintMapPairs(pairs)
 	npairs := len(pairs)
 	keysOps := make([]valuesOp, npairs)
 	valuesOps := make([]valuesOp, npairs)
 	begins, ends := make([]int, npairs), make([]int, npairs)
 	for i, pair := range pairs {
-		keysOps[i] = cp.compoundOp(pair.Key)
+		// Like indices, keys are not range expressions.
+		keysOps[i] = cp.nonRangeCompoundOp(pair.Key)
 		if pair.Value == nil {
 			p := pair.Range().To
 			valuesOps[i] = literalValues(diag.PointRanging(p), true)
@@ -47,6 +47,51 @@ func TestIndexing(t *testing.T) {
 	)
 }
 
+func TestRangeExpression(t *testing.T) {
+	Test(t,
+		That("all 0..3").Puts(0, 1, 2),
+		That("all 0..=3").Puts(0, 1, 2, 3),
+		That("all 0..10..3").Puts(0, 3, 6, 9),
+		That("all 3..0").Puts(3, 2, 1),
+		That("all 6..=0..-3").Puts(6, 3, 0),
+		That("all 0.5..2").Puts(0.5, 1.5),
+		That("all 3..3").DoesNothing(),
+		That("for x 1..3 { put $x }").Puts(1, 2),
+		That("var a b = 1 (num 3); all $a..$b").Puts(1, 2),
+		That("all (put 1)..=(put 2)").Puts(1, 2),
+		That("var l = [1 3]; all $l[0]..$l[1]").Puts(1, 2),
+
+		// Ranges are values of their own, and are only expanded when iterated.
+		That("kind-of 0..10").Puts("range"),
+		That("count 0..100").Puts(100),
+		That("eq 0..3 0..3").Puts(true),
+		That("eq 0..3 0..=3").Puts(false),
+		// Their string form is the source form.
+		That("echo 0..10..2 1..=2 (num 1)..(num 3)").Prints("0..10..2 1..=2 1..3\n"),
+		That("repr 0..=3").Prints("0..=3\n"),
+		// They can be used as indices.
+		That("has-key [a b c] 0..2").Puts(true),
+
+		// Errors in the operands are propagated.
+		That("put (fail x)..3").Throws(FailError{"x"}, "fail x"),
+		// The step must go in the direction from the start to the end.
+		That("put 0..3..-1").Throws(
+			errs.BadValue{What: "step", Valid: "positive", Actual: "-1"}, "0..3..-1"),
+		That("put 3..0..1").Throws(
+			errs.BadValue{What: "step", Valid: "negative", Actual: "1"}, "3..0..1"),
+
+		// Expressions that look like ranges but have non-numerical operands
+		// are ordinary compound expressions.
+		That("put a..b ../foo 1..2..=3").Puts("a..b", "../foo", "1..2..=3"),
+		That("var a = foo; put $a..3").Puts("foo..3"),
+		That("var a = [x y]; put $@a..3").Puts("x..3", "y..3"),
+		That("put []..3").Throws(ErrorWithMessage("cannot concatenate list and string")),
+		// Indices are not range expressions.
+		That("put [a b c][0..2]").Puts(vals.MakeList("a", "b")),
+		That("put [&0..2=x][0..2]").Puts("x"),
+	)
+}
+
 func TestListLiteral(t *testing.T) {
 	Test(t,
 		That("put [a b c]").Puts(vals.MakeList("a", "b", "c")),
@@ -144,6 +189,26 @@ func TestOutputCapture(t *testing.T) {
 	)
 }
 
+func TestOutputCapture_InvalidUTF8(t *testing.T) {
+	Test(t,
+		That(`put (print "a\xffb\nc")`).Puts("a\xffb", "c"),
+		That(`{ tmp capture-invalid-utf8 = replace; put (print "a\xff\xfeb\nc") }`).
+			Puts("a\uFFFDb", "c"),
+		// Values and valid lines are not affected.
+		That(`{ tmp capture-invalid-utf8 = error; put (put "\xff") (print "c") }`).
+			Puts("\xff", "c"),
+		That(`{ tmp capture-invalid-utf8 = error; put (print "a\xffb\nc") }`).Throws(
+			errs.BadValue{What: "output line", Valid: "valid UTF-8", Actual: `"a\xffb"`}),
+		// An exception thrown by the captured code takes precedence.
+		That(`{ tmp capture-invalid-utf8 = error; put (print "\xff"; fail foo) }`).
+			Throws(FailError{"foo"}),
+		That("set capture-invalid-utf8 = bad").Throws(errs.BadValue{
+			What:   "capture invalid UTF-8 mode",
+			Valid:  "keep, replace, error",
+			Actual: "bad"}),
+	)
+}
+
 func TestExceptionCapture(t *testing.T) {
 	Test(t,
 		// Exception capture
@@ -35,21 +35,27 @@ type compiler struct {
 	errors []*diag.Error
 	// Suggested code to fix potential issues found during compilation.
 	autofixes []string
+	// State for finding lint warnings; nil when not linting.
+	lint *linter
 }
 
 type scopePragma struct {
 	unknownCommandIsExternal bool
+	strict                   bool
 }
 
 func compile(b, g *staticNs, modules []string, tree parse.Tree, w io.Writer) (nsOp, []string, error) {
-	g = g.clone()
-	cp := &compiler{
-		b, []*staticNs{g}, []*staticUpNs{new(staticUpNs)},
+	cp := newCompiler(b, g, modules, tree.Source, w)
+	chunkOp := cp.chunkOp(tree.Root)
+	return nsOp{chunkOp, cp.scopes[0]}, cp.autofixes, diag.PackCognateErrors(cp.errors)
+}
+
+func newCompiler(b, g *staticNs, modules []string, src parse.Source, w io.Writer) *compiler {
+	return &compiler{
+		b, []*staticNs{g.clone()}, []*staticUpNs{new(staticUpNs)},
 		[]*scopePragma{{unknownCommandIsExternal: true}},
 		modules,
-		w, newDeprecationRegistry(), tree.Source, nil, nil}
-	chunkOp := cp.chunkOp(tree.Root)
-	return nsOp{chunkOp, g}, cp.autofixes, diag.PackCognateErrors(cp.errors)
+		w, newDeprecationRegistry(), src, nil, nil, nil}
 }
 
 type nsOp struct {
@@ -136,11 +142,16 @@ func (cp *compiler) checkDeprecatedBuiltin(name string, r diag.Ranger) {
 }
 
 func (cp *compiler) deprecate(r diag.Ranger, msg string, minLevel int) {
-	if cp.warn == nil || r == nil {
+	if (cp.warn == nil && cp.lint == nil) || r == nil {
 		return
 	}
 	dep := deprecation{cp.srcMeta.Name, r.Range(), msg}
-	if prog.DeprecationLevel >= minLevel && cp.deprecations.register(dep) {
+	if prog.DeprecationLevel < minLevel || !cp.deprecations.register(dep) {
+		return
+	}
+	if cp.lint != nil {
+		cp.lintf(r, deprecationWarning, "%s", msg)
+	} else {
 		err := diag.Error{
 			Type: "deprecation", Message: msg,
 			Context: *diag.NewContext(cp.srcMeta.Name, cp.srcMeta.Code, r.Range())}
@@ -0,0 +1,217 @@
+package eval
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A variable assignment in a .env file.
+type dotenvVar struct {
+	name, value string
+}
+
+// Parses the content of a .env file. The name is used in error messages.
+//
+// Each line is either empty, a comment starting with #, or an assignment
+// NAME=VALUE, optionally preceded by "export". The value may be:
+//
+//   - Unquoted, extending to the end of the line or an inline comment (a #
+//     preceded by whitespace), with surrounding whitespace removed.
+//
+//   - Single-quoted, taken literally.
+//
+//   - Double-quoted, with the escape sequences \n, \r, \t, \", \\ and \$.
+//
+// Quoted values may span multiple lines.
+func parseDotenv(name, src string) ([]dotenvVar, error) {
+	p := &dotenvParser{name: name, src: src, line: 1}
+	var vars []dotenvVar
+	for {
+		p.skipBlanks()
+		if p.eof() {
+			return vars, nil
+		}
+		switch p.peek() {
+		case '\n':
+			p.next()
+			continue
+		case '#':
+			p.skipToEOL()
+			continue
+		}
+		v, err := p.parseAssignment()
+		if err != nil {
+			return nil, err
+		}
+		vars = append(vars, v)
+	}
+}
+
+type dotenvParser struct {
+	name string
+	src  string
+	pos  int
+	line int
+}
+
+func (p *dotenvParser) eof() bool  { return p.pos >= len(p.src) }
+func (p *dotenvParser) peek() byte { return p.src[p.pos] }
+
+func (p *dotenvParser) next() byte {
+	b := p.src[p.pos]
+	p.pos++
+	if b == '\n' {
+		p.line++
+	}
+	return b
+}
+
+// Skips spaces and tabs, and carriage returns from CRLF line endings.
+func (p *dotenvParser) skipBlanks() {
+	for !p.eof() && strings.IndexByte(" \t\r", p.peek()) >= 0 {
+		p.pos++
+	}
+}
+
+// Skips to the newline at the end of the line.
+func (p *dotenvParser) skipToEOL() {
+	for !p.eof() && p.peek() != '\n' {
+		p.pos++
+	}
+}
+
+func (p *dotenvParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%s:%d: "+format, append([]any{p.name, p.line}, args...)...)
+}
+
+func (p *dotenvParser) parseAssignment() (dotenvVar, error) {
+	if rest := p.src[p.pos:]; strings.HasPrefix(rest, "export") &&
+		len(rest) > len("export") && (rest[6] == ' ' || rest[6] == '\t') {
+		p.pos += len("export")
+		p.skipBlanks()
+	}
+	start := p.pos
+	for !p.eof() && isDotenvNameByte(p.peek(), p.pos == start) {
+		p.pos++
+	}
+	name := p.src[start:p.pos]
+	if name == "" {
+		return dotenvVar{}, p.errorf("invalid variable name")
+	}
+	p.skipBlanks()
+	if p.eof() || p.peek() != '=' {
+		return dotenvVar{}, p.errorf("missing = after %s", name)
+	}
+	p.next()
+	p.skipBlanks()
+
+	var value string
+	if !p.eof() && (p.peek() == '\'' || p.peek() == '"') {
+		var err error
+		value, err = p.parseQuoted(name)
+		if err != nil {
+			return dotenvVar{}, err
+		}
+		p.skipBlanks()
+		if !p.eof() && p.peek() == '#' {
+			p.skipToEOL()
+		}
+		if !p.eof() && p.peek() != '\n' {
+			return dotenvVar{}, p.errorf("unexpected text after quoted value of %s", name)
+		}
+	} else {
+		start := p.pos
+		p.skipToEOL()
+		value = p.src[start:p.pos]
+		for i := 1; i < len(value); i++ {
+			if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+				value = value[:i]
+				break
+			}
+		}
+		value = strings.TrimRight(value, " \t\r")
+	}
+	return dotenvVar{name, value}, nil
+}
+
+func (p *dotenvParser) parseQuoted(name string) (string, error) {
+	startLine := p.line
+	quote := p.next()
+	var sb strings.Builder
+	for !p.eof() {
+		b := p.next()
+		switch {
+		case b == quote:
+			return sb.String(), nil
+		case b == '\\' && quote == '"' && !p.eof():
+			switch e := p.next(); e {
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case '"', '\\', '$':
+				sb.WriteByte(e)
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(e)
+			}
+		default:
+			sb.WriteByte(b)
+		}
+	}
+	p.line = startLine
+	return "", p.errorf("unterminated quoted value of %s", name)
+}
+
+func isDotenvNameByte(b byte, first bool) bool {
+	return b == '_' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' ||
+		!first && '0' <= b && b <= '9'
+}
+
+// A change to the environment from applying a .env file.
+type dotenvChange struct {
+	name, value string
+	// The old value, if the variable existed.
+	oldValue string
+	existed  bool
+}
+
+// Reads the .env file at path, and returns the changes that applying it would
+// make to the environment, in the order the variables first appear in the
+// file. Later assignments to the same variable take precedence. Variables that
+// already exist are only changed if override is true.
+func readDotenv(path string, override bool) ([]dotenvChange, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := parseDotenv(path, string(src))
+	if err != nil {
+		return nil, err
+	}
+	var changes []dotenvChange
+	index := make(map[string]int)
+	for _, v := range vars {
+		if i, ok := index[v.name]; ok {
+			changes[i].value = v.value
+			continue
+		}
+		oldValue, existed := os.LookupEnv(v.name)
+		if existed && !override {
+			continue
+		}
+		index[v.name] = len(changes)
+		changes = append(changes, dotenvChange{v.name, v.value, oldValue, existed})
+	}
+	// Drop the variables that wouldn't change.
+	filtered := changes[:0]
+	for _, c := range changes {
+		if !c.existed || c.value != c.oldValue {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,77 @@
+package eval
+
+import (
+	"reflect"
+	"testing"
+)
+
+var parseDotenvTests = []struct {
+	name    string
+	src     string
+	want    []dotenvVar
+	wantErr string
+}{
+	{name: "empty", src: "", want: nil},
+	{
+		name: "comments and blank lines",
+		src:  "# comment\n\n  # indented comment\nA=1\n",
+		want: []dotenvVar{{"A", "1"}},
+	},
+	{
+		name: "unquoted values",
+		src:  "A = foo bar  \nexport B=x#y\nC=x # comment\nD=\n",
+		want: []dotenvVar{{"A", "foo bar"}, {"B", "x#y"}, {"C", "x"}, {"D", ""}},
+	},
+	{
+		name: "single-quoted values",
+		src:  "A='a \\n $b # c'\nB='multi\nline' # comment\n",
+		want: []dotenvVar{{"A", `a \n $b # c`}, {"B", "multi\nline"}},
+	},
+	{
+		name: "double-quoted values",
+		src:  `A="a\nb\t\"c\"\\\$d\x"` + "\nB=\"multi\nline\"\n",
+		want: []dotenvVar{{"A", "a\nb\t\"c\"\\$d\\x"}, {"B", "multi\nline"}},
+	},
+	{
+		name: "CRLF line endings",
+		src:  "A=1\r\nB='2'\r\n",
+		want: []dotenvVar{{"A", "1"}, {"B", "2"}},
+	},
+	{
+		name: "export as variable name",
+		src:  "export=1\n",
+		want: []dotenvVar{{"export", "1"}},
+	},
+	{name: "invalid name", src: "A=1\n1A=2\n", wantErr: ".env:2: invalid variable name"},
+	{name: "missing =", src: "A\n", wantErr: ".env:1: missing = after A"},
+	{
+		name:    "unterminated quote",
+		src:     "A=1\nB=\"foo\nbar\n",
+		wantErr: ".env:2: unterminated quoted value of B",
+	},
+	{
+		name:    "text after quoted value",
+		src:     "A='foo' bar\n",
+		wantErr: ".env:1: unexpected text after quoted value of A",
+	},
+}
+
+func TestParseDotenv(t *testing.T) {
+	for _, tc := range parseDotenvTests {
+		t.Run(tc.name, func(t *testing.T) {
+			vars, err := parseDotenv(".env", tc.src)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Errorf("got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("got error %v", err)
+			}
+			if !reflect.DeepEqual(vars, tc.want) {
+				t.Errorf("got %q, want %q", vars, tc.want)
+			}
+		})
+	}
+}
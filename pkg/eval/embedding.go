@@ -0,0 +1,52 @@
+package eval
+
+import (
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/parse"
+)
+
+// EvalCapture is like Eval, but captures the output of the code in the same
+// way as the output capture syntax (...): value outputs are kept as is, and
+// byte outputs are split into lines. The output port in cfg.Ports, if any, is
+// ignored.
+func (ev *Evaler) EvalCapture(src parse.Source, cfg EvalCfg) ([]any, error) {
+	port, collect, err := valueCapturePort(ev.getCaptureInvalidUTF8())
+	if err != nil {
+		return nil, err
+	}
+	cfg.fillDefaults()
+	cfg.Ports = append([]*Port{cfg.Ports[0], port}, cfg.Ports[2:]...)
+	err = ev.Eval(src, cfg)
+	vs, errLine := collect()
+	if err == nil {
+		err = errLine
+	}
+	return vs, err
+}
+
+// AddGlobalGoFns adds Go functions to the global namespace, converting them
+// with NewGoFn. It is a shorthand for calling ExtendGlobal with a namespace
+// built with AddGoFns.
+func (ev *Evaler) AddGlobalGoFns(fns map[string]any) {
+	ev.ExtendGlobal(BuildNs().AddGoFns(fns))
+}
+
+// SetGlobalVar sets the value of a variable in the global namespace,
+// converting the Go value with vals.FromGoDeep. If the variable doesn't exist
+// yet, it is created. It returns an error if the variable exists but can't
+// be set, or if the value isn't valid for the variable.
+func (ev *Evaler) SetGlobalVar(name string, v any) error {
+	v = vals.FromGoDeep(v)
+	if variable := ev.Global().IndexString(name); variable != nil {
+		return variable.Set(v)
+	}
+	ev.ExtendGlobal(BuildNs().AddVar(name, vars.FromInit(v)))
+	return nil
+}
+
+// GlobalVar returns the value of a variable in the global namespace, and
+// whether the variable exists.
+func (ev *Evaler) GlobalVar(name string) (any, bool) {
+	return ev.Global().Index(name)
+}
@@ -0,0 +1,115 @@
+package eval_test
+
+import (
+	"reflect"
+	"testing"
+
+	. "src.elv.sh/pkg/eval"
+
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/parse"
+)
+
+func TestEmbedding(t *testing.T) {
+	ev := NewEvaler()
+	ev.AddGlobalGoFns(map[string]any{
+		"greet": func(name string) string { return "Hello, " + name },
+	})
+	err := ev.SetGlobalVar("names", []string{"foo", "bar"})
+	if err != nil {
+		t.Fatalf("SetGlobalVar: %v", err)
+	}
+
+	values, err := ev.EvalCapture(
+		parse.Source{Name: "[test]", Code: "each $greet~ $names"},
+		EvalCfg{})
+	if err != nil {
+		t.Fatalf("EvalCapture: %v", err)
+	}
+	want := []any{"Hello, foo", "Hello, bar"}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("got values %v, want %v", values, want)
+	}
+
+	err = ev.Eval(parse.Source{Name: "[test]", Code: "var x = [&n=(num 42)]"}, EvalCfg{})
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	x, ok := ev.GlobalVar("x")
+	if !ok {
+		t.Fatalf("GlobalVar returned false for variable x")
+	}
+	var goX struct{ N int }
+	if err := vals.ScanMapToGo(x.(vals.Map), &goX); err != nil || goX.N != 42 {
+		t.Errorf("got %v, %v from scanning $x, want N=42", goX, err)
+	}
+	if _, ok := ev.GlobalVar("nonexistent"); ok {
+		t.Errorf("GlobalVar returned true for nonexistent variable")
+	}
+}
+
+func TestEvalCapture_SplitsBytesIntoLines(t *testing.T) {
+	ev := NewEvaler()
+	values, err := ev.EvalCapture(
+		parse.Source{Name: "[test]", Code: "echo foo; echo bar"}, EvalCfg{})
+	if err != nil || !reflect.DeepEqual(values, []any{"foo", "bar"}) {
+		t.Errorf("got (%v, %v), want ([foo bar], nil)", values, err)
+	}
+}
+
+func TestEvalCapture_ReturnsOutputBeforeException(t *testing.T) {
+	ev := NewEvaler()
+	values, err := ev.EvalCapture(
+		parse.Source{Name: "[test]", Code: "put foo; fail bad"}, EvalCfg{})
+	if !reflect.DeepEqual(values, []any{"foo"}) {
+		t.Errorf("got values %v, want [foo]", values)
+	}
+	if err == nil {
+		t.Errorf("got nil error, want exception")
+	}
+}
+
+func TestSetGlobalVar_ExistingVariable(t *testing.T) {
+	ev := NewEvaler()
+	ev.Eval(parse.Source{Name: "[test]", Code: "var x = old; fn get { put $x }"}, EvalCfg{})
+
+	err := ev.SetGlobalVar("x", int64(10))
+	if err != nil {
+		t.Fatalf("SetGlobalVar: %v", err)
+	}
+	// The existing variable is set, so closures capturing it see the new
+	// value.
+	values, _ := ev.EvalCapture(parse.Source{Name: "[test]", Code: "get"}, EvalCfg{})
+	if !reflect.DeepEqual(values, []any{10}) {
+		t.Errorf("got %v, want [10]", values)
+	}
+
+	ev.ExtendGlobal(BuildNs().AddVar("ro", vars.NewReadOnly("foo")))
+	err = ev.SetGlobalVar("ro", "bar")
+	if err == nil {
+		t.Errorf("SetGlobalVar on read-only variable returned nil error")
+	}
+}
+
+func TestEval_DryRun(t *testing.T) {
+	r, w := must.Pipe()
+	ev := NewEvaler()
+	values, err := ev.EvalCapture(parse.Source{
+		Name: "[test]",
+		Code: "put builtin; nonexistent-command 'a b' (num 1); put done",
+	}, EvalCfg{Ports: []*Port{nil, nil, {File: w, Chan: BlackholeChan}}, DryRun: true})
+	w.Close()
+	stderr := string(must.ReadAllAndClose(r))
+
+	if err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+	if want := []any{"builtin", "done"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("got values %v, want %v", values, want)
+	}
+	if want := "nonexistent-command 'a b' 1\n"; stderr != want {
+		t.Errorf("got stderr %q, want %q", stderr, want)
+	}
+}
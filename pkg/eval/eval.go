@@ -1,15 +1,37 @@
 // Package eval handles evaluation of parsed Elvish code and provides runtime
 // facilities.
+//
+// # Embedding Elvish
+//
+// Go programs can use Elvish as an extension language with an [Evaler]. A
+// typical use looks like:
+//
+//	ev := eval.NewEvaler()
+//	ev.AddGlobalGoFns(map[string]any{
+//		"greet": func(name string) string { return "Hello, " + name },
+//	})
+//	ev.SetGlobalVar("names", []string{"foo", "bar"})
+//	values, err := ev.EvalCapture(
+//		parse.Source{Name: "[config]", Code: "each $greet~ $names"},
+//		eval.EvalCfg{})
+//
+// Go functions are converted to Elvish functions following the rules of
+// [NewGoFn], and Go values are converted to Elvish values with
+// [vals.FromGoDeep]. Values returned by [Evaler.EvalCapture] and
+// [Evaler.GlobalVar] can be converted back to Go values with [vals.ScanToGo],
+// [vals.ScanListToGo] and [vals.ScanMapToGo].
 package eval
 
 import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strconv"
 	"sync"
 
 	"src.elv.sh/pkg/env"
+	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/eval/vars"
 	"src.elv.sh/pkg/logutil"
@@ -32,6 +54,11 @@ const (
 const (
 	defaultValuePrefix        = "▶ "
 	defaultNotifyBgJobSuccess = true
+	defaultCaptureInvalidUTF8 = captureInvalidUTF8Keep
+	defaultPipeBufferSize     = 32
+	// Large buffers are allocated upfront, so the size is limited to avoid
+	// running out of memory by mistake.
+	maxPipeBufferSize = 1 << 20
 )
 
 // Evaler provides methods for evaluating code, and maintains state that is
@@ -45,12 +72,22 @@ type Evaler struct {
 	Args vals.List
 	// Hooks to run before exit or exec.
 	PreExitHooks []func()
-	// Chdir hooks, exposed indirectly as $before-chdir and $after-chdir.
-	BeforeChdir, AfterChdir []func(string)
+	// Functions to call immediately before changing the working directory,
+	// with the argument to Chdir. Exposed indirectly as $before-chdir.
+	BeforeChdir []func(string)
+	// Functions to call after the working directory has changed. All changes
+	// to the working directory, including those made by cd, with-cd, assigning
+	// to $pwd and the location and navigation modes, are published here.
+	// Exposed indirectly as $after-chdir.
+	AfterChdir []func(ChdirEvent)
 	// Directories to search libraries.
 	LibDirs []string
 	// Source code of internal bundled modules indexed by use specs.
 	BundledModules map[string]string
+	// Function to get the source code of a remote module from its URL, used
+	// for use specs starting with "https://". If nil, such use specs are not
+	// supported.
+	LoadRemoteModule func(url string) (string, error)
 	// Callback to notify the success or failure of background jobs. Must not be
 	// mutated once the Evaler is used to evaluate any code.
 	BgJobNotify func(string)
@@ -74,6 +111,8 @@ type Evaler struct {
 	// Internal modules are indexed by use specs. External modules are indexed by
 	// absolute paths.
 	modules map[string]*Ns
+	// Internal modules added with AddLazyModule that haven't been built yet.
+	lazyModules map[string]func() *Ns
 
 	// Various states and configs exposed to Elvish code.
 	//
@@ -83,8 +122,17 @@ type Evaler struct {
 	// Whether to notify the success of background jobs, exposed as
 	// $notify-bg-job-sucess.
 	notifyBgJobSuccess bool
-	// The current number of background jobs, exposed as $num-bg-jobs.
-	numBgJobs int
+	// The running background jobs indexed by their IDs, exposed by jobs; the
+	// number of them is exposed as $num-bg-jobs.
+	bgJobs map[int]*job
+	// How lines of byte output that are not valid UTF-8 are converted to
+	// values, exposed as $capture-invalid-utf8.
+	captureInvalidUTF8 string
+	// The number of values that can be buffered in each value pipe of a
+	// pipeline, exposed as $pipe-buffer-size.
+	pipeBufferSize int
+	// The value pipes of running pipelines, exposed by -pipe-buffers.
+	pipes map[*valuePipe]struct{}
 }
 
 // NewEvaler creates a new Evaler.
@@ -99,18 +147,23 @@ func NewEvaler() *Evaler {
 		deprecations: newDeprecationRegistry(),
 
 		modules:        make(map[string]*Ns),
+		lazyModules:    make(map[string]func() *Ns),
 		BundledModules: make(map[string]string),
 
 		valuePrefix:        defaultValuePrefix,
 		notifyBgJobSuccess: defaultNotifyBgJobSuccess,
-		numBgJobs:          0,
+		bgJobs:             make(map[int]*job),
+		captureInvalidUTF8: defaultCaptureInvalidUTF8,
+		pipeBufferSize:     defaultPipeBufferSize,
+		pipes:              make(map[*valuePipe]struct{}),
 		Args:               vals.EmptyList,
 	}
 
 	ev.BeforeChdir = []func(string){
 		adaptChdirHook("before-chdir", ev, &beforeChdirElvish)}
-	ev.AfterChdir = []func(string){
-		adaptChdirHook("after-chdir", ev, &afterChdirElvish)}
+	afterChdirHook := adaptChdirHook("after-chdir", ev, &afterChdirElvish)
+	ev.AfterChdir = []func(ChdirEvent){
+		func(e ChdirEvent) { afterChdirHook(e.Path) }}
 
 	ev.ExtendBuiltin(BuildNs().
 		AddVar("pwd", NewPwdVar(ev)).
@@ -120,6 +173,10 @@ func NewEvaler() *Evaler {
 			vars.FromPtrWithMutex(&ev.valuePrefix, &ev.mu)).
 		AddVar("notify-bg-job-success",
 			vars.FromPtrWithMutex(&ev.notifyBgJobSuccess, &ev.mu)).
+		AddVar("capture-invalid-utf8", vars.FromSetGet(ev.setCaptureInvalidUTF8,
+			func() any { return ev.getCaptureInvalidUTF8() })).
+		AddVar("pipe-buffer-size", vars.FromSetGet(ev.setPipeBufferSize,
+			func() any { return ev.getPipeBufferSize() })).
 		AddVar("num-bg-jobs",
 			vars.FromGet(func() any { return strconv.Itoa(ev.getNumBgJobs()) })).
 		AddVar("args", vars.FromGet(func() any { return ev.Args })))
@@ -221,6 +278,40 @@ func (ev *Evaler) AddModule(name string, mod *Ns) {
 	ev.mu.Lock()
 	defer ev.mu.Unlock()
 	ev.modules[name] = mod
+	delete(ev.lazyModules, name)
+}
+
+// AddLazyModule is like AddModule, but the namespace is only built by calling
+// f when the module is imported for the first time. This is useful for
+// modules that are costly to build and not always used.
+func (ev *Evaler) AddLazyModule(name string, f func() *Ns) {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	delete(ev.modules, name)
+	ev.lazyModules[name] = f
+}
+
+// Returns the internal module with the given name, building it first if it
+// was added with AddLazyModule.
+func (ev *Evaler) internalModule(name string) (*Ns, bool) {
+	ev.mu.RLock()
+	mod, ok := ev.modules[name]
+	f, lazy := ev.lazyModules[name]
+	ev.mu.RUnlock()
+	if ok || !lazy {
+		return mod, ok
+	}
+	// Build the module without holding the mutex, since f may use the Evaler.
+	mod = f()
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	if built, ok := ev.modules[name]; ok {
+		// Built concurrently by another goroutine.
+		return built, true
+	}
+	ev.modules[name] = mod
+	delete(ev.lazyModules, name)
+	return mod, true
 }
 
 // ValuePrefix returns the prefix to prepend to value outputs when writing them
@@ -237,23 +328,116 @@ func (ev *Evaler) getNotifyBgJobSuccess() bool {
 	return ev.notifyBgJobSuccess
 }
 
+func (ev *Evaler) getCaptureInvalidUTF8() string {
+	ev.mu.RLock()
+	defer ev.mu.RUnlock()
+	return ev.captureInvalidUTF8
+}
+
+func (ev *Evaler) setCaptureInvalidUTF8(v any) error {
+	mode, ok := v.(string)
+	if !ok || !isCaptureInvalidUTF8Mode(mode) {
+		return errs.BadValue{What: "capture invalid UTF-8 mode",
+			Valid: captureInvalidUTF8Modes, Actual: vals.ReprPlain(v)}
+	}
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	ev.captureInvalidUTF8 = mode
+	return nil
+}
+
+func (ev *Evaler) getPipeBufferSize() int {
+	ev.mu.RLock()
+	defer ev.mu.RUnlock()
+	return ev.pipeBufferSize
+}
+
+func (ev *Evaler) setPipeBufferSize(v any) error {
+	var size int
+	err := vals.ScanToGo(v, &size)
+	if err != nil || size < 0 || size > maxPipeBufferSize {
+		return errs.BadValue{What: "pipe buffer size",
+			Valid:  "integer from 0 to " + strconv.Itoa(maxPipeBufferSize),
+			Actual: vals.ReprPlain(v)}
+	}
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	ev.pipeBufferSize = size
+	return nil
+}
+
+func (ev *Evaler) addPipe(p *valuePipe) {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	ev.pipes[p] = struct{}{}
+}
+
+func (ev *Evaler) removePipes(pipes []*valuePipe) {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	for _, p := range pipes {
+		delete(ev.pipes, p)
+	}
+}
+
 func (ev *Evaler) getNumBgJobs() int {
 	ev.mu.RLock()
 	defer ev.mu.RUnlock()
-	return ev.numBgJobs
+	return len(ev.bgJobs)
 }
 
-func (ev *Evaler) addNumBgJobs(delta int) {
+// Adds a background job, giving it the smallest ID not used by other
+// background jobs.
+func (ev *Evaler) addBgJob(j *job) {
 	ev.mu.Lock()
 	defer ev.mu.Unlock()
-	ev.numBgJobs += delta
+	id := 1
+	for ev.bgJobs[id] != nil {
+		id++
+	}
+	j.id = id
+	ev.bgJobs[id] = j
+}
+
+func (ev *Evaler) removeBgJob(j *job) {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	delete(ev.bgJobs, j.id)
+}
+
+func (ev *Evaler) getBgJob(id int) *job {
+	ev.mu.RLock()
+	defer ev.mu.RUnlock()
+	return ev.bgJobs[id]
+}
+
+// Returns the background jobs, sorted by their IDs.
+func (ev *Evaler) getBgJobs() []*job {
+	ev.mu.RLock()
+	defer ev.mu.RUnlock()
+	jobs := make([]*job, 0, len(ev.bgJobs))
+	for _, j := range ev.bgJobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].id < jobs[k].id })
+	return jobs
+}
+
+// ChdirEvent describes a change of the working directory.
+type ChdirEvent struct {
+	// The argument passed to Chdir.
+	Path string
+	// The new working directory as an absolute path. If it can't be
+	// determined, this is the same as Path.
+	Dir string
 }
 
 // Chdir changes the current directory, and updates $E:PWD on success
 //
-// It runs the functions in beforeChdir immediately before changing the
-// directory, and the functions in afterChdir immediately after (if chdir was
-// successful). It returns nil as long as the directory changing part succeeds.
+// It runs the functions in BeforeChdir immediately before changing the
+// directory, and publishes a ChdirEvent to the functions in AfterChdir
+// immediately after (if chdir was successful). It returns nil as long as the
+// directory changing part succeeds.
 func (ev *Evaler) Chdir(path string) error {
 	for _, hook := range ev.BeforeChdir {
 		hook(path)
@@ -264,16 +448,18 @@ func (ev *Evaler) Chdir(path string) error {
 		return err
 	}
 
-	for _, hook := range ev.AfterChdir {
-		hook(path)
-	}
-
 	pwd, err := os.Getwd()
 	if err != nil {
-		logger.Println("getwd after cd:", err)
-		return nil
+		logger.Warnf("getwd after cd: %v", err)
+		pwd = path
+	} else {
+		os.Setenv(env.PWD, pwd)
+	}
+
+	e := ChdirEvent{Path: path, Dir: pwd}
+	for _, hook := range ev.AfterChdir {
+		hook(e)
 	}
-	os.Setenv(env.PWD, pwd)
 
 	return nil
 }
@@ -291,8 +477,16 @@ type EvalCfg struct {
 	// Whether the Eval method should try to put the Elvish in the foreground
 	// after the code is executed.
 	PutInFg bool
+	// Whether to do job control, which puts each pipeline in a process group
+	// of its own and in the foreground of the terminal while it runs external
+	// commands. This only takes effect if Elvish is in the foreground of the
+	// terminal on its stdin, and is not supported on Windows.
+	JobControl bool
 	// If not nil, used the given global namespace, instead of Evaler's own.
 	Global *Ns
+	// Whether to print external commands to stderr instead of executing them.
+	// Builtin commands and functions still run as usual.
+	DryRun bool
 }
 
 func (cfg *EvalCfg) fillDefaults() {
@@ -314,12 +508,18 @@ func (cfg *EvalCfg) fillDefaults() {
 // returned error may be a parse error, compilation error or exception.
 func (ev *Evaler) Eval(src parse.Source, cfg EvalCfg) error {
 	cfg.fillDefaults()
-	errFile := cfg.Ports[2].File
-
-	tree, err := parse.Parse(src, parse.Config{WarningWriter: errFile})
+	tree, err := parse.Parse(src, parse.Config{WarningWriter: cfg.Ports[2].File})
 	if err != nil {
 		return err
 	}
+	return ev.EvalTree(tree, cfg)
+}
+
+// EvalTree is like Eval, but evaluates a parsed source tree. The returned error
+// may be a compilation error or exception.
+func (ev *Evaler) EvalTree(tree parse.Tree, cfg EvalCfg) error {
+	cfg.fillDefaults()
+	errFile := cfg.Ports[2].File
 
 	ev.mu.Lock()
 	b := ev.builtin
@@ -342,7 +542,7 @@ func (ev *Evaler) Eval(src parse.Source, cfg EvalCfg) error {
 		return err
 	}
 
-	fm, cleanup := ev.prepareFrame(src, cfg)
+	fm, cleanup := ev.prepareFrame(tree.Source, cfg)
 	defer cleanup()
 
 	newLocal, exec := op.prepare(fm)
@@ -394,7 +594,8 @@ func (ev *Evaler) prepareFrame(src parse.Source, cfg EvalCfg) (*Frame, func()) {
 
 	ports := fillDefaultDummyPorts(cfg.Ports)
 
-	fm := &Frame{ev, src, cfg.Global, new(Ns), nil, intCh, ports, nil, false}
+	fm := &Frame{ev, src, cfg.Global, new(Ns), nil, intCh, ports, nil, false, cfg.DryRun,
+		nil, cfg.JobControl}
 	return fm, func() {
 		if intChCleanup != nil {
 			intChCleanup()
@@ -436,8 +637,9 @@ func (ev *Evaler) Check(src parse.Source, w io.Writer) (error, []string, error)
 // errors. If w is not nil, deprecation messages are written to it.
 func (ev *Evaler) CheckTree(tree parse.Tree, w io.Writer) ([]string, error) {
 	ev.mu.RLock()
-	b, g, m := ev.builtin, ev.global, ev.modules
+	b, g := ev.builtin, ev.global
+	modules := append(mapKeys(ev.modules), mapKeys(ev.lazyModules)...)
 	ev.mu.RUnlock()
-	_, autofixes, compileErr := compile(b.static(), g.static(), mapKeys(m), tree, w)
+	_, autofixes, compileErr := compile(b.static(), g.static(), modules, tree, w)
 	return autofixes, compileErr
 }
@@ -1,6 +1,7 @@
 package eval_test
 
 import (
+	"reflect"
 	"strconv"
 	"sync"
 	"syscall"
@@ -161,3 +162,26 @@ func TestCheck(t *testing.T) {
 		})
 	}
 }
+
+func TestAddLazyModule(t *testing.T) {
+	builds := 0
+	ev := NewEvaler()
+	ev.AddLazyModule("lazy", func() *Ns {
+		builds++
+		return BuildNs().AddVar("x", vars.NewReadOnly("foo")).Ns()
+	})
+
+	ev.Eval(parse.Source{Name: "[test]", Code: "nop"}, EvalCfg{})
+	if builds != 0 {
+		t.Errorf("module built %d times before use, want 0", builds)
+	}
+
+	code := "use lazy; put $lazy:x; use lazy; put $lazy:x"
+	values, err := ev.EvalCapture(parse.Source{Name: "[test]", Code: code}, EvalCfg{})
+	if !reflect.DeepEqual(values, []any{"foo", "foo"}) || err != nil {
+		t.Errorf("got (%v, %v), want ([foo foo], nil)", values, err)
+	}
+	if builds != 1 {
+		t.Errorf("module built %d times, want 1", builds)
+	}
+}
@@ -2,8 +2,9 @@ package eval
 
 import (
 	"errors"
+	"fmt"
 	"os"
-	"os/exec"
+	"strings"
 	"sync/atomic"
 	"syscall"
 
@@ -54,7 +55,7 @@ func (e externalCmd) Repr(int) string {
 }
 
 // Call calls an external command.
-func (e externalCmd) Call(fm *Frame, argVals []any, opts map[string]any) error {
+func (e externalCmd) Call(fm *Frame, argVals []any, opts map[string]any) (err error) {
 	if len(opts) > 0 {
 		return ErrExternalCmdOpts
 	}
@@ -69,6 +70,16 @@ func (e externalCmd) Call(fm *Frame, argVals []any, opts map[string]any) error {
 		}
 	}
 
+	if fm.dryRun {
+		args := make([]string, len(argVals)+1)
+		args[0] = e.Name
+		for i, a := range argVals {
+			args[i+1] = vals.ToString(a)
+		}
+		printDryRun(fm, args)
+		return nil
+	}
+
 	files := make([]*os.File, len(fm.ports))
 	for i, port := range fm.ports {
 		if port != nil {
@@ -76,6 +87,19 @@ func (e externalCmd) Call(fm *Frame, argVals []any, opts map[string]any) error {
 		}
 	}
 
+	if in := fm.ports[0]; needsValueInputBridge(in) {
+		r, finish, errBridge := bridgeValueInput(in)
+		if errBridge != nil {
+			return errBridge
+		}
+		files[0] = r
+		defer func() {
+			if errValue := finish(); errValue != nil && err == nil {
+				err = errValue
+			}
+		}()
+	}
+
 	args := make([]string, len(argVals)+1)
 	for i, a := range argVals {
 		// TODO: Maybe we should enforce string arguments instead of coercing
@@ -83,20 +107,36 @@ func (e externalCmd) Call(fm *Frame, argVals []any, opts map[string]any) error {
 		args[i+1] = vals.ToString(a)
 	}
 
-	path, err := exec.LookPath(e.Name)
+	path, err := lookPathCached(e.Name)
 	if err != nil {
 		return err
 	}
 
 	args[0] = path
 
-	sys := makeSysProcAttr(fm.background)
-	proc, err := os.StartProcess(path, args, &os.ProcAttr{Files: files, Sys: sys})
+	var proc *os.Process
+	if fm.job != nil {
+		proc, err = fm.job.start(path, args, files)
+	} else {
+		sys := makeSysProcAttr(fm.background)
+		proc, err = startExternal(path, args, &os.ProcAttr{Files: files, Sys: sys})
+	}
 	if err != nil {
 		return err
 	}
 
-	state, err := proc.Wait()
+	// Release may reset the PID.
+	pid := proc.Pid
+	var ws syscall.WaitStatus
+	if fm.job != nil {
+		ws, err = fm.job.wait(proc)
+	} else {
+		var state *os.ProcessState
+		state, err = proc.Wait()
+		if err == nil {
+			ws = state.Sys().(syscall.WaitStatus)
+		}
+	}
 	if err != nil {
 		// This should be a can't happen situation. Nonetheless, treat it as a
 		// soft error rather than panicking since the Go documentation is not
@@ -104,12 +144,26 @@ func (e externalCmd) Call(fm *Frame, argVals []any, opts map[string]any) error {
 		// calling `Wait` twice on a particular process object.
 		return err
 	}
-	ws := state.Sys().(syscall.WaitStatus)
 	if ws.Signaled() && isSIGPIPE(ws.Signal()) {
 		readerGone := fm.ports[1].readerGone
 		if readerGone != nil && atomic.LoadInt32(readerGone) == 1 {
 			return errs.ReaderGone{}
 		}
 	}
-	return NewExternalCmdExit(e.Name, state.Sys().(syscall.WaitStatus), proc.Pid)
+	return NewExternalCmdExit(e.Name, ws, pid)
+}
+
+// Prints the command line of an external command that would have run if not
+// for dry-run mode.
+func printDryRun(fm *Frame, args []string) {
+	fmt.Fprintln(fm.ErrorFile(), quoteCommand(args))
+}
+
+// Quotes each word of a command line, so that it can be run as Elvish code.
+func quoteCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = parse.Quote(arg)
+	}
+	return strings.Join(quoted, " ")
 }
@@ -0,0 +1,72 @@
+package eval
+
+import (
+	"errors"
+	"strings"
+)
+
+// Running batch files on Windows.
+//
+// Windows passes a command line rather than a list of arguments to new
+// processes. Most programs split it following the rules of the C runtime,
+// which os.StartProcess already quotes for. Batch files are however run by
+// cmd.exe, which expands variables like %PATH% and interprets metacharacters
+// like & and | in the command line before the batch file gets its arguments,
+// so arguments need to be quoted for cmd.exe instead.
+
+var errBatchArgNewline = errors.New("arguments to batch files can't contain newlines")
+
+// Characters that cmd.exe treats specially outside quotes, or that separate
+// arguments of batch files.
+const batchSpecialChars = " \t!\"#$%&'()*+,;<=>?@[]^`{|}~"
+
+// Returns the command line that runs a batch file with cmd.exe at cmdPath.
+//
+// The options of cmd.exe skip AutoRun commands (/d), enable the extensions
+// that the quoting of % relies on (/e:on), disable the expansion of !var!
+// (/v:off), and make cmd.exe only remove the outermost quotes around the rest
+// of the command line (/s), which are added here.
+func batchCmdLine(cmdPath, script string, args []string) (string, error) {
+	var sb strings.Builder
+	sb.WriteString(`"` + cmdPath + `" /d /e:on /v:off /s /c "`)
+	writeBatchArg(&sb, script, true)
+	for _, arg := range args {
+		if strings.ContainsAny(arg, "\r\n") {
+			// cmd.exe stops reading the command line at a newline.
+			return "", errBatchArgNewline
+		}
+		sb.WriteByte(' ')
+		writeBatchArg(&sb, arg, arg == "" || strings.ContainsAny(arg, batchSpecialChars))
+	}
+	sb.WriteByte('"')
+	return sb.String(), nil
+}
+
+// Writes an argument of a batch file. Inside quotes, cmd.exe only treats " and
+// % specially: " is doubled, which keeps cmd.exe inside quotes, and % is
+// followed by %cd:~,%, which expands to nothing and prevents % from starting
+// a variable.
+//
+// Batch files see their arguments with the quotes, as cmd.exe doesn't remove
+// them; %~1 removes the outer ones. Backslashes at the end are doubled, so
+// that the closing quote is not escaped when the argument is passed on to
+// another program.
+func writeBatchArg(sb *strings.Builder, arg string, quote bool) {
+	if !quote {
+		sb.WriteString(arg)
+		return
+	}
+	sb.WriteByte('"')
+	for _, r := range arg {
+		switch r {
+		case '"':
+			sb.WriteString(`""`)
+		case '%':
+			sb.WriteString(`%%cd:~,%`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteString(strings.Repeat(`\`, len(arg)-len(strings.TrimRight(arg, `\`))))
+	sb.WriteByte('"')
+}
@@ -0,0 +1,32 @@
+package eval
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/tt"
+)
+
+func TestBatchCmdLine(t *testing.T) {
+	const prefix = `"C:\cmd.exe" /d /e:on /v:off /s /c `
+	batchCmdLine := func(script string, args ...string) (string, error) {
+		return batchCmdLine(`C:\cmd.exe`, script, args)
+	}
+	tt.Test(t, tt.Fn("batchCmdLine", batchCmdLine), tt.Table{
+		tt.Args(`C:\a b\x.bat`).Rets(prefix+`""C:\a b\x.bat""`, nil),
+		// Arguments without special characters are not quoted.
+		tt.Args("x.bat", "foo", `C:\dir`).Rets(prefix+`""x.bat" foo C:\dir"`, nil),
+		tt.Args("x.bat", "", "a b").Rets(prefix+`""x.bat" "" "a b""`, nil),
+		// Metacharacters are quoted.
+		tt.Args("x.bat", "a&b", "a|b", "(a)", "^").
+			Rets(prefix+`""x.bat" "a&b" "a|b" "(a)" "^""`, nil),
+		// Quotes are doubled.
+		tt.Args("x.bat", `say "hi"`).Rets(prefix+`""x.bat" "say ""hi""""`, nil),
+		// Variables are not expanded.
+		tt.Args("x.bat", "%PATH%", "!x!").
+			Rets(prefix+`""x.bat" "%%cd:~,%PATH%%cd:~,%" "!x!""`, nil),
+		// Trailing backslashes are doubled when quoted.
+		tt.Args("x.bat", `C:\a b\`).Rets(prefix+`""x.bat" "C:\a b\\""`, nil),
+
+		tt.Args("x.bat", "a\nb").Rets("", errBatchArgNewline),
+	})
+}
@@ -0,0 +1,159 @@
+package eval
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+
+	"src.elv.sh/pkg/eval/vals"
+)
+
+// Modes of converting value inputs fed to external commands, as specified by
+// the &to option of a pipe.
+const (
+	// Value inputs are silently discarded. This is the default.
+	externalValueInputDrop = "drop"
+	// Each value is written as a line of JSON.
+	externalValueInputJSON = "json"
+	// Each value is stringified and written as a line.
+	externalValueInputString = "string"
+	// Any value input causes the external command to fail.
+	externalValueInputError = "error"
+)
+
+var externalValueInputModes = strings.Join([]string{
+	externalValueInputDrop, externalValueInputJSON,
+	externalValueInputString, externalValueInputError}, ", ")
+
+func isExternalValueInputMode(s string) bool {
+	switch s {
+	case externalValueInputDrop, externalValueInputJSON,
+		externalValueInputString, externalValueInputError:
+		return true
+	}
+	return false
+}
+
+var (
+	// ErrValueInputToExternal is thrown when an external command is fed value
+	// inputs through a pipe with &to=error.
+	ErrValueInputToExternal = errors.New("external command received value input")
+	// ErrValueInputNotConsumed is thrown when an external command exits before
+	// a value input taken from its pipe could be written to it.
+	ErrValueInputNotConsumed = errors.New("external command exited before reading all value input")
+)
+
+// Returns whether the input port needs to be bridged to be fed to external
+// commands, which is the case when the pipe has the &to option and can carry
+// values.
+func needsValueInputBridge(in *Port) bool {
+	return in != nil && in.valueInput != "" &&
+		in.valueInput != externalValueInputDrop && in.Chan != ClosedChan
+}
+
+// Returns the read end of a pipe that relays both the byte input and the value
+// input of the given port, the latter converted according to the valueInput
+// field of the port. The returned function must be called after the external
+// command has exited. It stops the relay and waits for it to finish, so that
+// the rest of the input is left to later readers of the port, and returns
+// ErrValueInputToExternal if the mode is "error" and any value input was seen,
+// or ErrValueInputNotConsumed if a value could not be written because the
+// external command had stopped reading.
+//
+// The relay deliberately does not wait for the input port to be exhausted, so
+// that the writer of the input port can still detect that its reader is gone.
+func bridgeValueInput(in *Port) (*os.File, func() error, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	rStop, wStop, err := os.Pipe()
+	if err != nil {
+		r.Close()
+		w.Close()
+		return nil, nil, err
+	}
+
+	var (
+		writeMu sync.Mutex
+		wg      sync.WaitGroup
+		stop    = make(chan struct{})
+		// Written by the value relay, and read after it has finished.
+		sawValue, dropped bool
+	)
+	// Write errors mean that the external command has stopped reading, in
+	// which case the relay goroutines stop too.
+	write := func(p []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		_, err := w.Write(p)
+		return err
+	}
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		relayBytes(in.File, rStop, write)
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case v, ok := <-in.Chan:
+				if !ok {
+					return
+				}
+				var line []byte
+				switch in.valueInput {
+				case externalValueInputJSON:
+					bs, err := json.Marshal(v)
+					if err != nil {
+						logger.Warnf("cannot encode value input as JSON: %v", err)
+						continue
+					}
+					line = append(bs, '\n')
+				case externalValueInputString:
+					line = []byte(vals.ToString(v) + "\n")
+				case externalValueInputError:
+					sawValue = true
+					return
+				}
+				if write(line) != nil {
+					dropped = true
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	// Close the write end once both inputs are exhausted, so that the
+	// external command sees EOF.
+	//
+	// TODO: In the "error" mode, the external command keeps running until its
+	// byte input is exhausted; it should ideally be terminated early.
+	relayDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		w.Close()
+		close(relayDone)
+	}()
+
+	return r, func() error {
+		close(stop)
+		wStop.Close()
+		// Closing the read end makes pending writes fail.
+		r.Close()
+		<-relayDone
+		rStop.Close()
+		if sawValue {
+			return ErrValueInputToExternal
+		}
+		if dropped {
+			return ErrValueInputNotConsumed
+		}
+		return nil
+	}, nil
+}
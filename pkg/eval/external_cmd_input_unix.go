@@ -0,0 +1,36 @@
+//go:build !windows && !plan9 && !js
+
+package eval
+
+import (
+	"os"
+	"syscall"
+
+	"src.elv.sh/pkg/sys/eunix"
+)
+
+// Relays the content of src with write until src is exhausted, write fails, or
+// stop becomes ready to read. Data is only read from src when it is available,
+// so that the relay can be stopped without taking any input after that.
+func relayBytes(src, stop *os.File, write func([]byte) error) {
+	buf := make([]byte, 4096)
+	for {
+		ready, err := eunix.WaitForRead(-1, src, stop)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			return
+		}
+		if ready[1] {
+			return
+		}
+		n, err := src.Read(buf)
+		if n > 0 && write(buf[:n]) != nil {
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package eval
+
+import "os"
+
+// Relays the content of src with write until src is exhausted, write fails, or
+// stop becomes ready to read.
+//
+// Reads from pipes can't be interrupted on Windows, so src is read in another
+// goroutine, which keeps running after the relay is stopped until its pending
+// read finishes. Input that it reads after that can't be written and is lost.
+//
+// TODO: Use overlapped I/O to leave the rest of the input to later readers,
+// like on Unix.
+func relayBytes(src, stop *os.File, write func([]byte) error) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 4096)
+		for {
+			n, err := src.Read(buf)
+			if n > 0 && write(buf[:n]) != nil {
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	stopped := make(chan struct{})
+	go func() {
+		// Returns when the write end of stop is closed.
+		stop.Read(make([]byte, 1))
+		close(stopped)
+	}()
+	select {
+	case <-done:
+	case <-stopped:
+	}
+}
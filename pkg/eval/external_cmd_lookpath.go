@@ -0,0 +1,129 @@
+package eval
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"src.elv.sh/pkg/env"
+	"src.elv.sh/pkg/fsutil"
+)
+
+// Caching of executable lookups.
+//
+// Resolving the name of an external command by searching every directory in
+// $E:PATH takes one stat call per directory, which adds up when running
+// external commands in a tight loop with a long $E:PATH. Like most other
+// shells, Elvish remembers the result of each search. How processes are
+// spawned is not changed by this; that is left to the os/exec package.
+//
+// The remembered results are discarded whenever $E:PATH changes, when the
+// modification time of any directory in $E:PATH changes, which is checked at
+// most once every lookPathRecheckInterval, and when clear-external-cache is
+// called. A remembered path is also only used if it is still an executable
+// file. Results found in relative directories of $E:PATH, which depend on the
+// working directory, are never remembered.
+
+// Hook for tests.
+var lookPath = exec.LookPath
+
+// How often the directories in $E:PATH are checked for changes.
+var lookPathRecheckInterval = time.Second
+
+var lookPathCache = struct {
+	sync.Mutex
+	pathEnv string
+	paths   map[string]string
+	// Modification times of the directories in pathEnv when they were last
+	// checked, and when that happened.
+	dirMtimes []time.Time
+	checkedAt time.Time
+}{paths: map[string]string{}}
+
+// Like exec.LookPath, but remembers the results of searching $E:PATH.
+func lookPathCached(name string) (string, error) {
+	if fsutil.DontSearch(name) {
+		return lookPath(name)
+	}
+	pathEnv := os.Getenv(env.PATH)
+
+	lookPathCache.Lock()
+	if lookPathCache.pathEnv != pathEnv {
+		lookPathCache.pathEnv = pathEnv
+		resetLookPathCache()
+	} else if time.Since(lookPathCache.checkedAt) >= lookPathRecheckInterval {
+		if !mtimesEqual(lookPathCache.dirMtimes, dirMtimes(pathEnv)) {
+			resetLookPathCache()
+		}
+		lookPathCache.checkedAt = time.Now()
+	}
+	path, ok := lookPathCache.paths[name]
+	lookPathCache.Unlock()
+	if ok && isExecutableFile(path) {
+		return path, nil
+	}
+
+	path, err := lookPath(name)
+	if err != nil {
+		return "", err
+	}
+	if filepath.IsAbs(path) {
+		lookPathCache.Lock()
+		if lookPathCache.pathEnv == pathEnv {
+			lookPathCache.paths[name] = path
+		}
+		lookPathCache.Unlock()
+	}
+	return path, nil
+}
+
+// Discards all the remembered results, and takes a new snapshot of the
+// modification times of the directories in $E:PATH. Must be called with
+// lookPathCache locked.
+func resetLookPathCache() {
+	lookPathCache.paths = map[string]string{}
+	lookPathCache.dirMtimes = dirMtimes(lookPathCache.pathEnv)
+	lookPathCache.checkedAt = time.Now()
+}
+
+func clearExternalCache() {
+	lookPathCache.Lock()
+	defer lookPathCache.Unlock()
+	resetLookPathCache()
+}
+
+// Returns the modification times of the directories in pathEnv, with the zero
+// time for directories that can't be accessed.
+func dirMtimes(pathEnv string) []time.Time {
+	dirs := filepath.SplitList(pathEnv)
+	mtimes := make([]time.Time, len(dirs))
+	for i, dir := range dirs {
+		if info, err := os.Stat(dir); err == nil {
+			mtimes[i] = info.ModTime()
+		}
+	}
+	return mtimes
+}
+
+func mtimesEqual(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isExecutableFile(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode()&0o111 != 0
+}
@@ -0,0 +1,134 @@
+//go:build !windows && !plan9 && !js
+
+package eval
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestLookPathCached(t *testing.T) {
+	dir := testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{
+		"bin1": testutil.Dir{"foo": testutil.File{Perm: 0755}},
+		"bin2": testutil.Dir{"foo": testutil.File{Perm: 0755}},
+	})
+	calls := 0
+	testutil.Set(t, &lookPath, func(name string) (string, error) {
+		calls++
+		return exec.LookPath(name)
+	})
+	lookup := func(wantPath string, wantCalls int) {
+		t.Helper()
+		path, err := lookPathCached("foo")
+		if path != wantPath || err != nil {
+			t.Errorf("got (%q, %v), want (%q, nil)", path, err, wantPath)
+		}
+		if calls != wantCalls {
+			t.Errorf("got %d searches, want %d", calls, wantCalls)
+		}
+	}
+
+	testutil.Setenv(t, "PATH", dir+"/bin1:"+dir+"/bin2")
+	lookup(dir+"/bin1/foo", 1)
+	// The result is remembered.
+	lookup(dir+"/bin1/foo", 1)
+	// The remembered result is not used when it is no longer executable.
+	os.Chmod(dir+"/bin1/foo", 0644)
+	lookup(dir+"/bin2/foo", 2)
+	// The remembered results are discarded when $E:PATH changes.
+	os.Chmod(dir+"/bin1/foo", 0755)
+	testutil.Setenv(t, "PATH", dir+"/bin1")
+	lookup(dir+"/bin1/foo", 3)
+
+	// The remembered results are discarded by clear-external-cache.
+	clearExternalCache()
+	lookup(dir+"/bin1/foo", 4)
+
+	// Names that are not searched for are not remembered.
+	lookPathCached("./bin1/foo")
+	lookPathCached("./bin1/foo")
+	if calls != 6 {
+		t.Errorf("got %d searches, want 6", calls)
+	}
+}
+
+func TestLookPathCached_DirectoryChanges(t *testing.T) {
+	dir := testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{
+		"bin1": testutil.Dir{},
+		"bin2": testutil.Dir{"foo": testutil.File{Perm: 0755}},
+	})
+	testutil.Setenv(t, "PATH", dir+"/bin1:"+dir+"/bin2")
+	testutil.Set(t, &lookPathRecheckInterval, 0)
+
+	if path, _ := lookPathCached("foo"); path != dir+"/bin2/foo" {
+		t.Errorf("got %q, want %q", path, dir+"/bin2/foo")
+	}
+	// A command added to an earlier directory of $E:PATH takes precedence.
+	// Make sure that the modification time changes even on file systems with
+	// a coarse resolution.
+	testutil.ApplyDir(testutil.Dir{"bin1": testutil.Dir{"foo": testutil.File{Perm: 0755}}})
+	os.Chtimes(dir+"/bin1", time.Now(), time.Now().Add(time.Hour))
+	if path, _ := lookPathCached("foo"); path != dir+"/bin1/foo" {
+		t.Errorf("got %q, want %q", path, dir+"/bin1/foo")
+	}
+}
+
+func TestLookPathCached_RelativeDirectory(t *testing.T) {
+	testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{"bin": testutil.Dir{"foo": testutil.File{Perm: 0755}}})
+	testutil.Setenv(t, "PATH", "bin")
+	calls := 0
+	testutil.Set(t, &lookPath, func(name string) (string, error) {
+		calls++
+		return exec.LookPath(name)
+	})
+
+	lookPathCached("foo")
+	lookPathCached("foo")
+	if calls != 2 {
+		t.Errorf("got %d searches, want 2", calls)
+	}
+}
+
+func BenchmarkExternalCmd(b *testing.B) {
+	path, err := exec.LookPath("true")
+	if err != nil {
+		b.Skip("true not found")
+	}
+	// A long $E:PATH, with the command in the last directory.
+	dirs := make([]string, 50)
+	for i := range dirs {
+		dirs[i] = b.TempDir()
+	}
+	dirs = append(dirs, filepath.Dir(path))
+	testutil.Setenv(b, "PATH", strings.Join(dirs, string(filepath.ListSeparator)))
+
+	b.Run("LookPath", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			exec.LookPath("true")
+		}
+	})
+	b.Run("lookPathCached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			lookPathCached("true")
+		}
+	})
+	b.Run("Spawn", func(b *testing.B) {
+		ev := NewEvaler()
+		src := parse.Source{Name: "[bench]", Code: "true"}
+		for i := 0; i < b.N; i++ {
+			if err := ev.Eval(src, EvalCfg{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
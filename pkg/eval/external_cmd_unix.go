@@ -2,8 +2,16 @@
 
 package eval
 
-import "syscall"
+import (
+	"os"
+	"syscall"
+)
 
 func isSIGPIPE(s syscall.Signal) bool {
 	return s == syscall.SIGPIPE
 }
+
+// Starts an external command.
+func startExternal(path string, args []string, attr *os.ProcAttr) (*os.Process, error) {
+	return os.StartProcess(path, args, attr)
+}
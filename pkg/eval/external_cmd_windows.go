@@ -1,8 +1,51 @@
 package eval
 
-import "syscall"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"src.elv.sh/pkg/env"
+)
 
 func isSIGPIPE(s syscall.Signal) bool {
 	// Windows doesn't have SIGPIPE.
 	return false
 }
+
+// Starts an external command. Batch files are run with cmd.exe, with the
+// arguments quoted for it, and PowerShell scripts with powershell.exe, since
+// Windows can't run them directly.
+func startExternal(path string, args []string, attr *os.ProcAttr) (*os.Process, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".bat", ".cmd":
+		cmdPath := os.Getenv(env.COMSPEC)
+		if cmdPath == "" {
+			var err error
+			if cmdPath, err = lookPath("cmd.exe"); err != nil {
+				return nil, err
+			}
+		}
+		cmdLine, err := batchCmdLine(cmdPath, path, args[1:])
+		if err != nil {
+			return nil, err
+		}
+		sys := &syscall.SysProcAttr{}
+		if attr.Sys != nil {
+			*sys = *attr.Sys
+		}
+		sys.CmdLine = cmdLine
+		attr2 := *attr
+		attr2.Sys = sys
+		return os.StartProcess(cmdPath, []string{cmdPath}, &attr2)
+	case ".ps1":
+		psPath, err := lookPath("powershell.exe")
+		if err != nil {
+			return nil, err
+		}
+		psArgs := append([]string{psPath, "-NoProfile", "-File", path}, args[1:]...)
+		return os.StartProcess(psPath, psArgs, attr)
+	}
+	return os.StartProcess(path, args, attr)
+}
@@ -4,8 +4,34 @@ package eval_test
 
 import (
 	"syscall"
+	"testing"
+
+	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/testutil"
 )
 
 func exitWaitStatus(exit uint32) syscall.WaitStatus {
 	return syscall.WaitStatus{ExitCode: exit}
 }
+
+func TestExternalCmd_BatchFile(t *testing.T) {
+	testutil.InTempDir(t)
+	must.WriteFile("args.bat", "@echo off\r\necho(%*\r\n")
+
+	Test(t,
+		That(`./args.bat foo 'a b' 'x&y' '%PATH%' '"hi"' | slurp`).
+			Puts(`foo "a b" "x&y" "%PATH%" """hi"""`+"\r\n"),
+		That("./args.bat \"a\\nb\"").Throws(ErrorWithMessage(
+			"arguments to batch files can't contain newlines")),
+	)
+}
+
+func TestExternalCmd_PowerShellScript(t *testing.T) {
+	testutil.InTempDir(t)
+	must.WriteFile("args.ps1", "$args | ForEach-Object { Write-Output $_ }\r\n")
+
+	Test(t,
+		That(`./args.ps1 foo 'a b' | slurp`).Puts("foo\r\na b\r\n"),
+	)
+}
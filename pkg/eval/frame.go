@@ -1,7 +1,6 @@
 package eval
 
 import (
-	"bufio"
 	"fmt"
 	"io"
 	"os"
@@ -31,6 +30,13 @@ type Frame struct {
 	traceback *StackTrace
 
 	background bool
+	// Whether external commands are printed instead of executed.
+	dryRun bool
+
+	// The job of the running pipeline, nil outside pipelines.
+	job *job
+	// Whether pipelines that are not part of other pipelines do job control.
+	jobControl bool
 }
 
 // PrepareEval prepares a piece of code for evaluation in a copy of the current
@@ -56,7 +62,8 @@ func (fm *Frame) PrepareEval(src parse.Source, r diag.Ranger, ns *Ns) (*Ns, func
 		traceback = fm.addTraceback(r)
 	}
 	newFm := &Frame{
-		fm.Evaler, src, local, new(Ns), nil, fm.intCh, fm.ports, traceback, fm.background}
+		fm.Evaler, src, local, new(Ns), nil, fm.intCh, fm.ports, traceback, fm.background,
+		fm.dryRun, fm.job, fm.jobControl}
 	op, _, err := compile(fm.Evaler.Builtin().static(), local.static(), nil, tree, fm.ErrorFile())
 	if err != nil {
 		return nil, nil, err
@@ -123,6 +130,13 @@ func (fm *Frame) Port(i int) *Port {
 	return fm.ports[i]
 }
 
+// DryRun returns whether external commands are to be printed instead of
+// executed, as requested with [EvalCfg.DryRun]. Modules that run external
+// commands without going through Elvish should respect it.
+func (fm *Frame) DryRun() bool {
+	return fm.dryRun
+}
+
 // IterateInputs calls the passed function for each input element.
 func (fm *Frame) IterateInputs(f func(any)) {
 	var wg sync.WaitGroup
@@ -150,18 +164,12 @@ func (fm *Frame) IterateInputs(f func(any)) {
 }
 
 func linesToChan(r io.Reader, ch chan<- any) {
-	filein := bufio.NewReader(r)
-	for {
-		line, err := filein.ReadString('\n')
-		if line != "" {
-			ch <- strutil.ChopLineEnding(line)
-		}
-		if err != nil {
-			if err != io.EOF {
-				logger.Println("error on reading:", err)
-			}
-			break
-		}
+	err := eachLine(r, '\n', func(line string) error {
+		ch <- strutil.ChopLineEnding(line)
+		return nil
+	})
+	if err != nil {
+		logger.Warnf("error on reading: %v", err)
 	}
 }
 
@@ -178,7 +186,8 @@ func (fm *Frame) Fork(name string) *Frame {
 		fm.Evaler, fm.srcMeta,
 		fm.local, fm.up, fm.defers,
 		fm.intCh, newPorts,
-		fm.traceback, fm.background,
+		fm.traceback, fm.background, fm.dryRun,
+		fm.job, fm.jobControl,
 	}
 }
 
@@ -191,12 +200,16 @@ func (fm *Frame) forkWithOutput(name string, p *Port) *Frame {
 
 // CaptureOutput captures the output of a given callback that operates on a Frame.
 func (fm *Frame) CaptureOutput(f func(*Frame) error) ([]any, error) {
-	outPort, collect, err := ValueCapturePort()
+	outPort, collect, err := valueCapturePort(fm.Evaler.getCaptureInvalidUTF8())
 	if err != nil {
 		return nil, err
 	}
 	err = f(fm.forkWithOutput("[output capture]", outPort))
-	return collect(), err
+	vs, errLine := collect()
+	if err == nil {
+		err = errLine
+	}
+	return vs, err
 }
 
 // PipeOutput calls a callback with output piped to the given output handlers.
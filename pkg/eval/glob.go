@@ -238,7 +238,7 @@ func doGlob(gp globPattern, abort <-chan struct{}) ([]any, error) {
 	if !gp.Glob(func(pathInfo glob.PathInfo) bool {
 		select {
 		case <-abort:
-			logger.Println("glob aborted")
+			logger.Debugf("glob aborted")
 			return false
 		default:
 		}
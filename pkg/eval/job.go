@@ -0,0 +1,156 @@
+package eval
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+
+	"src.elv.sh/pkg/eval/vals"
+)
+
+// A job is a pipeline that is not part of another pipeline, together with all
+// the pipelines it runs. Where supported, the external commands of a
+// background job, and of a foreground job when Elvish does job control, are
+// put in a process group of the job, so that they and the processes they
+// start can be signaled together.
+type job struct {
+	// The ID of a background job, used by kill-job; 0 for foreground jobs.
+	id     int
+	source string
+	bg     bool
+	// Whether the external commands are put in a process group of the job.
+	pgroup bool
+	// Whether the process group is put in the foreground of the terminal
+	// while any external command is running.
+	fg bool
+
+	mu sync.Mutex
+	// Mutations to fields below must be guarded by mutex.
+	//
+	// The ID of the process group, 0 if no process has been started yet.
+	pgid int
+	// The processes that have not been waited for, including stopped ones.
+	procs map[*os.Process]struct{}
+	// Whether any process has been killed by SIGINT or SIGQUIT, which
+	// usually means that it was interrupted from the terminal.
+	interrupted bool
+	// Whether the job has been killed with kill-job.
+	killed bool
+}
+
+// Returned when a job that has been killed tries to start a process.
+var errJobKilled = errors.New("job has been killed")
+
+// Returns a new job. When jobControl is true, a foreground job has its own
+// process group if Elvish can put it in the foreground of the terminal.
+func newJob(source string, bg, jobControl bool) *job {
+	j := &job{source: source, bg: bg, procs: make(map[*os.Process]struct{})}
+	if bg {
+		j.pgroup = supportsProcessGroups
+	} else if jobControl && ownsTerminal() {
+		j.pgroup, j.fg = true, true
+	}
+	return j
+}
+
+// Starts a process in the job.
+func (j *job) start(path string, args []string, files []*os.File) (*os.Process, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.killed {
+		// Processes started after kill-job would otherwise escape it, which
+		// is likely when it is called right after the job is started.
+		return nil, errJobKilled
+	}
+	if j.pgid != 0 && !j.ownsProcessGroup() {
+		// The ID of the process group may have been reused.
+		j.pgid = 0
+	}
+	proc, err := j.startProcess(path, args, files)
+	if err != nil && j.pgid != 0 && errors.Is(err, syscall.EPERM) {
+		// The remaining processes in the process group have exited since it
+		// was checked, so the group no longer exists; start a new one.
+		j.pgid = 0
+		proc, err = j.startProcess(path, args, files)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if j.pgroup && j.pgid == 0 {
+		j.pgid = proc.Pid
+	}
+	j.procs[proc] = struct{}{}
+	return proc, nil
+}
+
+// Records that a process started with start has been stopped. The process
+// remains in the job and can be resumed with fg, but a foreground job gives
+// the terminal back to Elvish.
+func (j *job) stopped() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.fg {
+		if err := putSelfInFg(); err != nil {
+			logger.Warnf("failed to put myself in foreground: %v", err)
+		}
+	}
+}
+
+// Records the exit of a process started with start. It puts Elvish back in
+// the foreground of the terminal when no process of a foreground job is left.
+func (j *job) exited(proc *os.Process, ws syscall.WaitStatus) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.procs, proc)
+	if ws.Signaled() && isInterruptSignal(ws.Signal()) {
+		j.interrupted = true
+	}
+	if j.fg && len(j.procs) == 0 {
+		if err := putSelfInFg(); err != nil {
+			logger.Warnf("failed to put myself in foreground: %v", err)
+		}
+	}
+}
+
+// Called when all the pipelines of a foreground job have finished. If the job
+// has been interrupted, it terminates the remaining processes in the process
+// group, like the background processes of a wrapper script, which usually
+// ignore SIGINT and would otherwise keep running.
+func (j *job) finish() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.interrupted && j.ownsProcessGroup() {
+		terminateProcessGroup(j.pgid)
+	}
+}
+
+// Returns the IDs of the running processes, sorted.
+func (j *job) pids() []int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	pids := make([]int, 0, len(j.procs))
+	for proc := range j.procs {
+		pids = append(pids, proc.Pid)
+	}
+	sort.Ints(pids)
+	return pids
+}
+
+// Information about a background job, output by the jobs builtin.
+type jobInfo struct {
+	ID     int
+	Source string
+	Pids   vals.List
+}
+
+func (jobInfo) IsStructMap() {}
+
+func (j *job) info() jobInfo {
+	pids := vals.EmptyList
+	for _, pid := range j.pids() {
+		pids = pids.Conj(pid)
+	}
+	return jobInfo{j.id, j.source, pids}
+}
@@ -0,0 +1,186 @@
+//go:build !windows && !plan9
+
+package eval
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const supportsProcessGroups = true
+
+func (j *job) startProcess(path string, args []string, files []*os.File) (*os.Process, error) {
+	if !j.pgroup {
+		return os.StartProcess(path, args, &os.ProcAttr{Files: files, Sys: makeSysProcAttr(false)})
+	}
+	sys := &syscall.SysProcAttr{Setpgid: true, Pgid: j.pgid}
+	if !j.fg || len(j.procs) > 0 {
+		return os.StartProcess(path, args, &os.ProcAttr{Files: files, Sys: sys})
+	}
+	// The first process started when no other process of a foreground job is
+	// running puts the process group in the foreground, before it runs the
+	// command. The tcsetpgrp call is made from the new process group, so it
+	// only succeeds with SIGTTOU ignored.
+	sys.Foreground, sys.Ctty = true, 0
+	var proc *os.Process
+	err := withSIGTTOUIgnored(func() error {
+		var err error
+		proc, err = os.StartProcess(path, args, &os.ProcAttr{Files: files, Sys: sys})
+		return err
+	})
+	return proc, err
+}
+
+// Waits for a process started with start to exit, or in a foreground job, to
+// exit or stop, and records either in the job. A stopped process of a
+// background job is waited for until it is resumed and exits, since there is
+// no terminal to take back from it.
+func (j *job) wait(proc *os.Process) (syscall.WaitStatus, error) {
+	options := 0
+	if j.fg {
+		options = syscall.WUNTRACED
+	}
+	var ws syscall.WaitStatus
+	_, err := syscall.Wait4(proc.Pid, &ws, options, nil)
+	for err == syscall.EINTR {
+		_, err = syscall.Wait4(proc.Pid, &ws, options, nil)
+	}
+	if err == nil && ws.Stopped() {
+		j.stopped()
+		return ws, nil
+	}
+	j.exited(proc, ws)
+	// The process has been reaped by Wait4, which os.Process doesn't know
+	// about; release its resources, like the pidfd on Linux.
+	proc.Release()
+	return ws, err
+}
+
+// Reports whether the process group of the job can be signaled or joined.
+// Must be called with j.mu held.
+//
+// The group certainly exists while any of its processes has not been waited
+// for. After that, its ID may have been reused; since a PID is not reused
+// while there is a process group with the same ID, the group can only be
+// known to still be that of the job when no process has its ID as the PID.
+func (j *job) ownsProcessGroup() bool {
+	if j.pgid == 0 {
+		return false
+	}
+	if len(j.procs) > 0 {
+		return true
+	}
+	_, err := syscall.Getpgid(j.pgid)
+	return err == syscall.ESRCH
+}
+
+func isInterruptSignal(s syscall.Signal) bool {
+	return s == syscall.SIGINT || s == syscall.SIGQUIT
+}
+
+func terminateProcessGroup(pgid int) {
+	syscall.Kill(-pgid, syscall.SIGTERM)
+}
+
+// Sends SIGTERM to the process group of the job, or the processes of the job
+// if it doesn't have one. If tree is true, it also sends SIGTERM to all the
+// descendants of those processes, including those that have moved to other
+// process groups.
+func (j *job) kill(tree bool) error {
+	j.mu.Lock()
+	j.killed = true
+	pgid := 0
+	if j.ownsProcessGroup() {
+		pgid = j.pgid
+	}
+	j.mu.Unlock()
+	pids := j.pids()
+	if tree {
+		// Look up the descendants before signaling any process, since
+		// processes whose parents have exited are adopted by init.
+		table, err := processTable()
+		if err != nil {
+			return err
+		}
+		pids = descendants(table, pids, pgid)
+	}
+	if pgid != 0 {
+		if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil && err != syscall.ESRCH {
+			return err
+		}
+	}
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, syscall.SIGTERM); err != nil && err != syscall.ESRCH {
+			return err
+		}
+	}
+	return nil
+}
+
+type processEntry struct{ pid, ppid, pgid int }
+
+// Returns all the processes on the system, by running ps. Can be overridden
+// in tests.
+var processTable = func() ([]processEntry, error) {
+	out, err := exec.Command("ps", "-A", "-o", "pid=", "-o", "ppid=", "-o", "pgid=").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseProcessTable(string(out)), nil
+}
+
+func parseProcessTable(s string) []processEntry {
+	var table []processEntry
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		var nums [3]int
+		ok := true
+		for i, field := range fields {
+			n, err := strconv.Atoi(field)
+			if err != nil {
+				ok = false
+				break
+			}
+			nums[i] = n
+		}
+		if ok {
+			table = append(table, processEntry{nums[0], nums[1], nums[2]})
+		}
+	}
+	return table
+}
+
+// Returns the processes in pids and the process group pgid (unless it is 0),
+// together with all their descendants, sorted.
+func descendants(table []processEntry, pids []int, pgid int) []int {
+	children := make(map[int][]int)
+	for _, p := range table {
+		children[p.ppid] = append(children[p.ppid], p.pid)
+		if pgid != 0 && p.pgid == pgid {
+			pids = append(pids, p.pid)
+		}
+	}
+	seen := make(map[int]bool)
+	var result []int
+	for len(pids) > 0 {
+		pid := pids[len(pids)-1]
+		pids = pids[:len(pids)-1]
+		if seen[pid] {
+			continue
+		}
+		seen[pid] = true
+		result = append(result, pid)
+		pids = append(pids, children[pid]...)
+	}
+	sort.Ints(result)
+	return result
+}
@@ -0,0 +1,72 @@
+//go:build !windows && !plan9
+
+package eval
+
+import (
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestParseProcessTable(t *testing.T) {
+	got := parseProcessTable("    1     0     1\n  100     1   100\nbad line\n  101   100   100\n")
+	want := []processEntry{{1, 0, 1}, {100, 1, 100}, {101, 100, 100}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDescendants(t *testing.T) {
+	table := []processEntry{
+		{1, 0, 1},
+		// A job with process group 10, whose leader has started 12 in another
+		// process group and 13 that has been adopted by init.
+		{10, 1, 10}, {11, 10, 10}, {12, 10, 12}, {13, 1, 10}, {14, 12, 12},
+		// An unrelated process.
+		{20, 1, 20},
+	}
+	if got, want := descendants(table, []int{10}, 10), []int{10, 11, 12, 13, 14}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := descendants(table, []int{12}, 0), []int{12, 14}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestJob_WaitReturnsWhenForegroundProcessStops(t *testing.T) {
+	j := &job{fg: true, procs: make(map[*os.Process]struct{})}
+	proc, err := j.start("/bin/sh", []string{"sh", "-c", "kill -STOP $$"}, []*os.File{nil, nil, os.Stderr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := j.wait(proc)
+	if err != nil || !ws.Stopped() {
+		t.Fatalf("got (%v, %v), want stopped", ws, err)
+	}
+	if len(j.procs) != 1 {
+		t.Errorf("stopped process not left in job")
+	}
+
+	syscall.Kill(proc.Pid, syscall.SIGCONT)
+	ws, err = j.wait(proc)
+	if err != nil || !ws.Exited() {
+		t.Fatalf("got (%v, %v), want exited", ws, err)
+	}
+	if len(j.procs) != 0 {
+		t.Errorf("exited process left in job")
+	}
+}
+
+func TestJob_DoesNotSignalReusedProcessGroup(t *testing.T) {
+	// A process with the ID of the process group as its PID exists, so the
+	// ID may have been reused.
+	j := &job{pgid: os.Getpid(), procs: make(map[*os.Process]struct{})}
+	if j.ownsProcessGroup() {
+		t.Errorf("ownsProcessGroup -> true for group with a live leader")
+	}
+	j.procs[&os.Process{}] = struct{}{}
+	if !j.ownsProcessGroup() {
+		t.Errorf("ownsProcessGroup -> false with processes not waited for")
+	}
+}
@@ -0,0 +1,56 @@
+package eval
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+const supportsProcessGroups = false
+
+func (j *job) startProcess(path string, args []string, files []*os.File) (*os.Process, error) {
+	return startExternal(path, args, &os.ProcAttr{Files: files, Sys: makeSysProcAttr(j.bg)})
+}
+
+// Waits for a process started with start to exit, and records it in the job.
+func (j *job) wait(proc *os.Process) (syscall.WaitStatus, error) {
+	state, err := proc.Wait()
+	var ws syscall.WaitStatus
+	if err == nil {
+		ws = state.Sys().(syscall.WaitStatus)
+	}
+	j.exited(proc, ws)
+	return ws, err
+}
+
+func (j *job) ownsProcessGroup() bool { return false }
+
+func isInterruptSignal(syscall.Signal) bool { return false }
+
+func terminateProcessGroup(int) {}
+
+// Terminates the processes of the job. If tree is true, it also terminates all
+// their descendants, using taskkill.
+func (j *job) kill(tree bool) error {
+	j.mu.Lock()
+	j.killed = true
+	procs := make([]*os.Process, 0, len(j.procs))
+	for proc := range j.procs {
+		procs = append(procs, proc)
+	}
+	j.mu.Unlock()
+	for _, proc := range procs {
+		var err error
+		if tree {
+			err = exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(proc.Pid)).Run()
+		} else {
+			err = proc.Kill()
+		}
+		if err != nil && !errors.Is(err, os.ErrProcessDone) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package eval
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+)
+
+// The size of the chunks read by eachLine.
+const lineChunkSize = 32 * 1024
+
+// Pool of *[]byte, each pointing to a chunk of lineChunkSize bytes.
+var lineChunkPool = sync.Pool{New: func() any {
+	chunk := make([]byte, lineChunkSize)
+	return &chunk
+}}
+
+// Calls f with each line of r, which includes the terminator unless it is the
+// last line and r doesn't end with a terminator. It stops when f returns an
+// error, or reading from r fails with an error other than io.EOF, returning
+// the error.
+//
+// Instead of allocating a string for each line, it reads r in large chunks and
+// allocates a single string for all the lines that are complete in a chunk,
+// which the lines passed to f are slices of. A line that is kept alive thus
+// keeps the memory of the lines around it alive too, but this costs much less
+// than allocating each line separately in the common case of most lines being
+// discarded soon.
+func eachLine(r io.Reader, terminator byte, f func(string) error) error {
+	chunkp := lineChunkPool.Get().(*[]byte)
+	defer lineChunkPool.Put(chunkp)
+	chunk := *chunkp
+	// A line that is not complete yet, spanning one or more chunks.
+	var partial []byte
+	for {
+		n, err := r.Read(chunk)
+		if n > 0 {
+			data := chunk[:n]
+			last := bytes.LastIndexByte(data, terminator)
+			if last == -1 {
+				partial = append(partial, data...)
+			} else {
+				if len(partial) > 0 {
+					// Complete the partial line with the start of this chunk.
+					first := bytes.IndexByte(data, terminator)
+					partial = append(partial, data[:first+1]...)
+					if err := f(string(partial)); err != nil {
+						return err
+					}
+					partial = partial[:0]
+					data = data[first+1:]
+					last -= first + 1
+				}
+				if err := eachLineInString(string(data[:last+1]), terminator, f); err != nil {
+					return err
+				}
+				partial = append(partial, data[last+1:]...)
+			}
+		}
+		if err != nil {
+			if len(partial) > 0 {
+				if err := f(string(partial)); err != nil {
+					return err
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// Calls f with each line in s, which must end with the terminator.
+func eachLineInString(s string, terminator byte, f func(string) error) error {
+	for s != "" {
+		i := strings.IndexByte(s, terminator)
+		if err := f(s[:i+1]); err != nil {
+			return err
+		}
+		s = s[i+1:]
+	}
+	return nil
+}
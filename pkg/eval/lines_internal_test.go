@@ -0,0 +1,105 @@
+package eval
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var eachLineTests = []struct {
+	name  string
+	input string
+	want  []string
+}{
+	{"empty", "", nil},
+	{"one terminated line", "foo\n", []string{"foo\n"}},
+	{"one unterminated line", "foo", []string{"foo"}},
+	{"empty lines", "\n\n", []string{"\n", "\n"}},
+	{"mixed", "foo\nbar\n\nlorem", []string{"foo\n", "bar\n", "\n", "lorem"}},
+	{"line longer than chunk",
+		strings.Repeat("x", 2*lineChunkSize+1) + "\nfoo\n",
+		[]string{strings.Repeat("x", 2*lineChunkSize+1) + "\n", "foo\n"}},
+}
+
+func TestEachLine(t *testing.T) {
+	readers := []struct {
+		name string
+		wrap func(io.Reader) io.Reader
+	}{
+		{"plain", func(r io.Reader) io.Reader { return r }},
+		{"one byte", iotest.OneByteReader},
+		{"half", iotest.HalfReader},
+		{"data with EOF", iotest.DataErrReader},
+	}
+	for _, test := range eachLineTests {
+		for _, reader := range readers {
+			t.Run(test.name+"/"+reader.name, func(t *testing.T) {
+				r := reader.wrap(strings.NewReader(test.input))
+				lines, err := collectLines(r, '\n')
+				if !reflect.DeepEqual(lines, test.want) || err != nil {
+					t.Errorf("got (%q, %v), want (%q, nil)", lines, err, test.want)
+				}
+			})
+		}
+	}
+}
+
+func TestEachLine_Terminator(t *testing.T) {
+	lines, err := collectLines(strings.NewReader("a\x00b\nc\x00"), 0)
+	want := []string{"a\x00", "b\nc\x00"}
+	if !reflect.DeepEqual(lines, want) || err != nil {
+		t.Errorf("got (%q, %v), want (%q, nil)", lines, err, want)
+	}
+}
+
+func TestEachLine_ReadError(t *testing.T) {
+	errRead := errors.New("read error")
+	r := io.MultiReader(strings.NewReader("foo\nbar"), iotest.ErrReader(errRead))
+	lines, err := collectLines(r, '\n')
+	want := []string{"foo\n", "bar"}
+	if !reflect.DeepEqual(lines, want) || err != errRead {
+		t.Errorf("got (%q, %v), want (%q, %v)", lines, err, want, errRead)
+	}
+}
+
+func TestEachLine_CallbackError(t *testing.T) {
+	errStop := errors.New("stop")
+	var lines []string
+	err := eachLine(strings.NewReader("foo\nbar\nlorem\n"), '\n', func(line string) error {
+		lines = append(lines, line)
+		if len(lines) == 2 {
+			return errStop
+		}
+		return nil
+	})
+	want := []string{"foo\n", "bar\n"}
+	if !reflect.DeepEqual(lines, want) || err != errStop {
+		t.Errorf("got (%q, %v), want (%q, %v)", lines, err, want, errStop)
+	}
+}
+
+func collectLines(r io.Reader, terminator byte) ([]string, error) {
+	var lines []string
+	err := eachLine(r, terminator, func(line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	return lines, err
+}
+
+func BenchmarkEachLine(b *testing.B) {
+	for _, lineLen := range []int{8, 80, 800} {
+		input := strings.Repeat(strings.Repeat("x", lineLen-1)+"\n", 64*1024/lineLen)
+		b.Run("line-"+strconv.Itoa(lineLen), func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				eachLine(strings.NewReader(input), '\n', func(string) error { return nil })
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package eval
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/parse/cmpd"
+)
+
+// Types of the warnings returned by Evaler.Lint.
+const (
+	unusedWarning       = "unused"
+	noSuchModuleWarning = "no such module"
+	deprecationWarning  = "deprecation"
+	suspiciousWarning   = "suspicious"
+)
+
+// Lint checks the given source code like Check, and also looks for code that
+// compiles but is likely to be a mistake. It returns the parse error, the
+// compilation error, and the warnings found, sorted by their positions.
+//
+// Warnings are found for:
+//
+//   - Variables, functions and imported modules that are defined in a function
+//     body or another lambda and never used. Those defined at the top level
+//     are not checked, since they are exported if the code is used as a
+//     module. Names starting with "_" are not checked either.
+//
+//   - Module specs in use forms that can't be resolved. Remote modules are
+//     assumed to exist.
+//
+//   - Uses of deprecated features, subject to prog.DeprecationLevel.
+//
+//   - Suspicious constructs: conditions of if and while that are string
+//     literals, duplicate keys in map literals and options, and commands like
+//     "x = foo" that look like assignments in other shells.
+func (ev *Evaler) Lint(src parse.Source) (parseErr, compileErr error, warnings []*diag.Error) {
+	tree, parseErr := parse.Parse(src, parse.Config{})
+	ev.mu.RLock()
+	b, g := ev.builtin, ev.global
+	modules := append(mapKeys(ev.modules), mapKeys(ev.lazyModules)...)
+	ev.mu.RUnlock()
+	cp := newCompiler(b.static(), g.static(), modules, src, nil)
+	cp.lint = &linter{decls: map[staticSlot]*localDecl{},
+		hasModule: func(spec string) bool { return ev.hasModule(src, spec) }}
+	cp.chunkOp(tree.Root)
+	for _, decl := range cp.lint.declOrder {
+		if !decl.used {
+			cp.lintf(decl.r, unusedWarning, "%s is never used", decl.desc)
+		}
+	}
+	sortWarnings(cp.lint.warnings)
+	return parseErr, diag.PackCognateErrors(cp.errors), cp.lint.warnings
+}
+
+type linter struct {
+	// Local declarations, keyed by their slots and in the order they appear.
+	decls     map[staticSlot]*localDecl
+	declOrder []*localDecl
+	hasModule func(spec string) bool
+	warnings  []*diag.Error
+}
+
+type staticSlot struct {
+	ns    *staticNs
+	index int
+}
+
+type localDecl struct {
+	desc string
+	r    diag.Ranging
+	used bool
+}
+
+func (cp *compiler) lintf(r diag.Ranger, typ, format string, args ...any) {
+	cp.lint.warnings = append(cp.lint.warnings, &diag.Error{
+		Type:    typ,
+		Message: fmt.Sprintf(format, args...),
+		Context: *diag.NewContext(cp.srcMeta.Name, cp.srcMeta.Code, r)})
+}
+
+// Records the declaration of a variable in the current scope with the given
+// index and name, which may have the function or namespace suffix.
+func (cp *compiler) lintDecl(index int, name string, r diag.Ranger) {
+	if cp.lint == nil || len(cp.scopes) == 1 || strings.HasPrefix(name, "_") {
+		return
+	}
+	var desc string
+	switch {
+	case strings.HasSuffix(name, FnSuffix):
+		desc = "function " + strings.TrimSuffix(name, FnSuffix)
+	case strings.HasSuffix(name, NsSuffix):
+		desc = "module " + strings.TrimSuffix(name, NsSuffix)
+	default:
+		desc = "variable $" + name
+	}
+	decl := &localDecl{desc, r.Range(), false}
+	cp.lint.decls[staticSlot{cp.thisScope(), index}] = decl
+	cp.lint.declOrder = append(cp.lint.declOrder, decl)
+}
+
+// Records a use of the variable in the given static namespace and index.
+func (cp *compiler) lintUse(ns *staticNs, index int) {
+	if cp.lint == nil {
+		return
+	}
+	if decl, ok := cp.lint.decls[staticSlot{ns, index}]; ok {
+		decl.used = true
+	}
+}
+
+func (cp *compiler) lintUseSpec(spec string, r diag.Ranger) {
+	if cp.lint != nil && !cp.lint.hasModule(spec) {
+		cp.lintf(r, noSuchModuleWarning, "cannot find module %s", parse.Quote(spec))
+	}
+}
+
+func (cp *compiler) lintCondition(n *parse.Compound) {
+	if cp.lint == nil {
+		return
+	}
+	s, ok := cmpd.StringLiteral(n)
+	switch {
+	case !ok:
+	case s == "":
+		cp.lintf(n, suspiciousWarning, "condition is an empty string, which is always false")
+	case s == "true" || s == "false":
+		cp.lintf(n, suspiciousWarning,
+			"condition is the string %s, which is always true; use $%s instead", s, s)
+	default:
+		cp.lintf(n, suspiciousWarning,
+			"condition is a string, which is always true; use an output capture like (%s) to run a command",
+			s)
+	}
+}
+
+func (cp *compiler) lintMapPairs(pairs []*parse.MapPair) {
+	if cp.lint == nil {
+		return
+	}
+	seen := make(map[string]bool)
+	for _, pair := range pairs {
+		if key, ok := cmpd.StringLiteral(pair.Key); ok {
+			if seen[key] {
+				cp.lintf(pair.Key, suspiciousWarning, "duplicate key %s", parse.Quote(key))
+			}
+			seen[key] = true
+		}
+	}
+}
+
+func (cp *compiler) lintForm(n *parse.Form) {
+	if cp.lint == nil || len(n.Args) == 0 {
+		return
+	}
+	head, ok1 := cmpd.StringLiteral(n.Head)
+	arg, ok2 := cmpd.StringLiteral(n.Args[0])
+	if ok1 && ok2 && arg == "=" {
+		cp.lintf(n, suspiciousWarning,
+			`this runs the command %s; use "var %s = ..." or "set %s = ..." for assignment`,
+			parse.Quote(head), head, head)
+	}
+}
+
+// Returns whether the module spec can be resolved when used in src, without
+// loading the module. Remote modules are assumed to exist.
+func (ev *Evaler) hasModule(src parse.Source, spec string) bool {
+	exists := func(path string) bool {
+		for _, ext := range []string{".elv", ".so"} {
+			if _, err := os.Stat(path + ext); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+	if strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../") {
+		var dir string
+		if src.IsFile {
+			dir = filepath.Dir(src.Name)
+		} else {
+			var err error
+			dir, err = os.Getwd()
+			if err != nil {
+				return true
+			}
+		}
+		return exists(filepath.Clean(dir + "/" + spec))
+	}
+	if isRemoteSpec(spec) {
+		return true
+	}
+	ev.mu.RLock()
+	_, ok := ev.modules[spec]
+	_, lazy := ev.lazyModules[spec]
+	_, bundled := ev.BundledModules[spec]
+	libDirs := ev.LibDirs
+	ev.mu.RUnlock()
+	if ok || lazy || bundled {
+		return true
+	}
+	for _, dir := range libDirs {
+		if exists(filepath.Join(dir, spec)) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortWarnings(warnings []*diag.Error) {
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return warnings[i].Range().From < warnings[j].Range().From
+	})
+}
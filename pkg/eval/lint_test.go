@@ -0,0 +1,135 @@
+package eval
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/prog"
+	"src.elv.sh/pkg/testutil"
+)
+
+var lintTests = []struct {
+	name string
+	code string
+	want []string
+}{
+	{name: "no warnings", code: "fn f {|x| var y = $x; put $y }; f 1"},
+
+	{
+		name: "unused local variable",
+		code: "fn f { var x y = 1 2; put $y }",
+		want: []string{"unused: [test]:1:12: variable $x is never used"},
+	},
+	{
+		name: "shadowed local variable",
+		code: "fn f { var x = 1; var x = 2; put $x }",
+		want: []string{"unused: [test]:1:12: variable $x is never used"},
+	},
+	{
+		name: "variable used in a closure",
+		code: "fn f { var x = 1; put { put $x } }",
+	},
+	{
+		name: "variable only set",
+		code: "fn f { var x; set x = 1 }",
+	},
+	{
+		name: "unused local function and module",
+		code: "{ fn g { }; use str }",
+		want: []string{
+			"unused: [test]:1:6: function g is never used",
+			"unused: [test]:1:13: module str is never used",
+		},
+	},
+	{
+		name: "top-level and underscore variables are not checked",
+		code: "var x = 1; fn f { }; { var _x = 1 }",
+	},
+
+	{
+		name: "module not found",
+		code: "use str; use lib; use ./rel; use nonexistent; use https://example.com/m.elv",
+		want: []string{"no such module: [test]:1:34: cannot find module nonexistent"},
+	},
+
+	{
+		name: "deprecated builtin",
+		code: "float64 1",
+		want: []string{`deprecation: [test]:1:1: the "float64" command is deprecated; use "num" or "inexact-num" instead`},
+	},
+
+	{
+		name: "string literal conditions",
+		code: "if false { } elif '' { }; while foo { }; if (foo) { }",
+		want: []string{
+			"suspicious: [test]:1:4: condition is the string false, which is always true; use $false instead",
+			"suspicious: [test]:1:19: condition is an empty string, which is always false",
+			"suspicious: [test]:1:33: condition is a string, which is always true; use an output capture like (foo) to run a command",
+		},
+	},
+	{
+		name: "duplicate keys",
+		code: "put [&a=1 &b=2 &a=3]; echo &sep=, &sep=,",
+		want: []string{
+			`suspicious: [test]:1:17: duplicate key a`,
+			`suspicious: [test]:1:36: duplicate key sep`,
+		},
+	},
+	{
+		name: "assignment syntax of other shells",
+		code: "x = foo",
+		want: []string{`suspicious: [test]:1:1: this runs the command x; use "var x = ..." or "set x = ..." for assignment`},
+	},
+
+	{
+		name: "warnings are found despite errors",
+		code: "{ var x }; echo $nonexistent; echo (",
+		want: []string{"unused: [test]:1:7: variable $x is never used"},
+	},
+}
+
+func TestLint(t *testing.T) {
+	testutil.Set(t, &prog.DeprecationLevel, 19)
+	testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{
+		"rel.elv": "",
+		"lib":     testutil.Dir{"lib.elv": ""},
+	})
+	ev := NewEvaler()
+	ev.LibDirs = []string{"lib"}
+	ev.AddModule("str", &Ns{})
+
+	for _, test := range lintTests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, warnings := ev.Lint(parse.Source{Name: "[test]", Code: test.code})
+			var got []string
+			for _, w := range warnings {
+				got = append(got, w.Error())
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got warnings:\n%q\nwant:\n%q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLint_Errors(t *testing.T) {
+	ev := NewEvaler()
+	parseErr, compileErr, _ := ev.Lint(parse.Source{Name: "[test]", Code: "echo $x ("})
+	if parseErr == nil || compileErr == nil {
+		t.Errorf("got (%v, %v), want both non-nil", parseErr, compileErr)
+	}
+}
+
+func TestLint_RelativeToFile(t *testing.T) {
+	dir := testutil.TempDir(t)
+	testutil.ApplyDirIn(testutil.Dir{"a.elv": "", "b.elv": ""}, dir)
+	ev := NewEvaler()
+
+	src := parse.Source{Name: filepath.Join(dir, "b.elv"), Code: "use ./a", IsFile: true}
+	if _, _, warnings := ev.Lint(src); len(warnings) != 0 {
+		t.Errorf("got warnings %v, want none", warnings)
+	}
+}
@@ -0,0 +1,44 @@
+package eval
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/parse"
+)
+
+// Modes of handling lines of byte output that are not valid UTF-8 when they
+// are converted to string values, as seen in $capture-invalid-utf8.
+const (
+	// Invalid bytes are kept in the string as they are. This is the default.
+	captureInvalidUTF8Keep = "keep"
+	// Each run of invalid bytes is replaced with U+FFFD.
+	captureInvalidUTF8Replace = "replace"
+	// The line is dropped, and the capture throws an exception.
+	captureInvalidUTF8Error = "error"
+)
+
+var captureInvalidUTF8Modes = strings.Join([]string{
+	captureInvalidUTF8Keep, captureInvalidUTF8Replace, captureInvalidUTF8Error}, ", ")
+
+func isCaptureInvalidUTF8Mode(s string) bool {
+	switch s {
+	case captureInvalidUTF8Keep, captureInvalidUTF8Replace, captureInvalidUTF8Error:
+		return true
+	}
+	return false
+}
+
+// Converts a line of byte output, without the line ending, to a string value
+// according to mode.
+func lineToValue(line, mode string) (string, error) {
+	if mode == captureInvalidUTF8Keep || utf8.ValidString(line) {
+		return line, nil
+	}
+	if mode == captureInvalidUTF8Replace {
+		return strings.ToValidUTF8(line, "\uFFFD"), nil
+	}
+	return "", errs.BadValue{What: "output line",
+		Valid: "valid UTF-8", Actual: parse.Quote(line)}
+}
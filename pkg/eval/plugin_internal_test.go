@@ -0,0 +1,28 @@
+package eval
+
+import "testing"
+
+func TestNsFromPluginSymbol(t *testing.T) {
+	ev := NewEvaler()
+	ns := BuildNs().AddVar("x", nil).Ns()
+
+	if got, ok := nsFromPluginSymbol(ev, &ns); got != ns || !ok {
+		t.Errorf("got (%v, %v) for **Ns, want (ns, true)", got, ok)
+	}
+
+	var evPassed *Evaler
+	f := func(ev *Evaler) *Ns { evPassed = ev; return ns }
+	if got, ok := nsFromPluginSymbol(ev, f); got != ns || !ok {
+		t.Errorf("got (%v, %v) for func, want (ns, true)", got, ok)
+	}
+	if evPassed != ev {
+		t.Errorf("func not called with the Evaler")
+	}
+
+	var nilNs *Ns
+	for _, sym := range []any{&nilNs, func(*Evaler) *Ns { return nil }, "foo"} {
+		if got, ok := nsFromPluginSymbol(ev, sym); got != nil || ok {
+			t.Errorf("got (%v, %v) for %T, want (nil, false)", got, ok, sym)
+		}
+	}
+}
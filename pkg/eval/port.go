@@ -33,6 +33,11 @@ type Port struct {
 	// is used to check if an external command killed by SIGPIPE is caused by
 	// the termination of the reader of the pipe.
 	readerGone *int32
+
+	// Only populated in input ports reading from another command in a
+	// pipeline, when the pipe has the &to option. It determines how value
+	// inputs are converted when fed to external commands.
+	valueInput string
 }
 
 // ErrPortDoesNotSupportValueOutput is thrown when writing to a port that does
@@ -47,7 +52,7 @@ func init() { close(closedSendStop) }
 
 // Returns a copy of the Port with the Close* flags unset.
 func (p *Port) fork() *Port {
-	return &Port{p.File, p.Chan, false, false, p.sendStop, p.sendError, p.readerGone}
+	return &Port{p.File, p.Chan, false, false, p.sendStop, p.sendError, p.readerGone, p.valueInput}
 }
 
 // Closes a Port.
@@ -155,7 +160,7 @@ func CapturePort() (*Port, func() ([]any, []byte), error) {
 			var err error
 			bytes, err = io.ReadAll(r)
 			if err != nil && err != io.EOF {
-				logger.Println("error on reading:", err)
+				logger.Warnf("error on reading: %v", err)
 			}
 		},
 	)
@@ -172,7 +177,23 @@ func CapturePort() (*Port, func() ([]any, []byte), error) {
 // are saved, with bytes saved one string value per line. It also returns a
 // function to call to obtain the captured output.
 func ValueCapturePort() (*Port, func() []any, error) {
+	port, collect, err := valueCapturePort(captureInvalidUTF8Keep)
+	if err != nil {
+		return nil, nil, err
+	}
+	return port, func() []any {
+		vs, _ := collect()
+		return vs
+	}, nil
+}
+
+// Like ValueCapturePort, but lines that are not valid UTF-8 are converted
+// according to mode, one of the modes of $capture-invalid-utf8. The function
+// to obtain the captured output also returns the error for the first line that
+// could not be converted.
+func valueCapturePort(mode string) (*Port, func() ([]any, error), error) {
 	vs := []any{}
+	var errLine error
 	var m sync.Mutex
 	port, done, err := PipePort(
 		func(ch <-chan any) {
@@ -187,14 +208,18 @@ func ValueCapturePort() (*Port, func() []any, error) {
 			for {
 				line, err := buffered.ReadString('\n')
 				if line != "" {
-					v := strutil.ChopLineEnding(line)
+					v, err := lineToValue(strutil.ChopLineEnding(line), mode)
 					m.Lock()
-					vs = append(vs, v)
+					if err == nil {
+						vs = append(vs, v)
+					} else if errLine == nil {
+						errLine = err
+					}
 					m.Unlock()
 				}
 				if err != nil {
 					if err != io.EOF {
-						logger.Println("error on reading:", err)
+						logger.Warnf("error on reading: %v", err)
 					}
 					break
 				}
@@ -203,9 +228,9 @@ func ValueCapturePort() (*Port, func() []any, error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	return port, func() []any {
+	return port, func() ([]any, error) {
 		done()
-		return vs
+		return vs, errLine
 	}, nil
 }
 
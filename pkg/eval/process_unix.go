@@ -20,9 +20,29 @@ func putSelfInFg() error {
 	// If Elvish is in the background, the tcsetpgrp call below will either fail
 	// (if the process is in an orphaned process group) or stop the process.
 	// Ignoring TTOU fixes that.
-	signal.Ignore(syscall.SIGTTOU)
-	defer signal.Reset(syscall.SIGTTOU)
-	return eunix.Tcsetpgrp(0, syscall.Getpgrp())
+	return withSIGTTOUIgnored(func() error {
+		return eunix.Tcsetpgrp(0, syscall.Getpgrp())
+	})
+}
+
+// Calls f with SIGTTOU ignored, restoring its disposition afterwards unless it
+// was already ignored.
+func withSIGTTOUIgnored(f func() error) error {
+	if !signal.Ignored(syscall.SIGTTOU) {
+		signal.Ignore(syscall.SIGTTOU)
+		defer signal.Reset(syscall.SIGTTOU)
+	}
+	return f()
+}
+
+// Reports whether Elvish is in the foreground process group of the terminal
+// on its stdin.
+func ownsTerminal() bool {
+	if !sys.IsATTY(os.Stdin.Fd()) {
+		return false
+	}
+	pgid, err := eunix.Tcgetpgrp(0)
+	return err == nil && pgid == syscall.Getpgrp()
 }
 
 func makeSysProcAttr(bg bool) *syscall.SysProcAttr {
@@ -15,3 +15,6 @@ func makeSysProcAttr(bg bool) *syscall.SysProcAttr {
 	}
 	return &syscall.SysProcAttr{CreationFlags: flags}
 }
+
+// Job control is not supported on Windows.
+func ownsTerminal() bool { return false }
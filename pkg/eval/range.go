@@ -0,0 +1,205 @@
+package eval
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+)
+
+// A range of numbers, produced by the range builtin and range expressions like
+// 0..10. The numbers are only generated when the range is iterated.
+type numRange struct {
+	// The start, end and step of the range, unified to the same type.
+	nums any
+	// Whether the end is part of the range.
+	inclusive bool
+	// The string form of the range; only set for ranges created from range
+	// expressions.
+	str string
+}
+
+// Creates a range from the start, the end and an optional step. Returns an
+// error if the step goes in the wrong direction.
+func newNumRange(rawNums []vals.Num, inclusive bool) (numRange, error) {
+	var err error
+	nums := vals.UnifyNums(rawNums, vals.Int)
+	switch ns := nums.(type) {
+	case []int:
+		nums, err = builtinNumRange(ns)
+	case []*big.Int:
+		nums, err = bigNumRange(ns, bigIntDesc)
+	case []*big.Rat:
+		nums, err = bigNumRange(ns, bigRatDesc)
+	case []float64:
+		nums, err = builtinNumRange(ns)
+	default:
+		panic("unreachable")
+	}
+	if err != nil {
+		return numRange{}, err
+	}
+	return numRange{nums: nums, inclusive: inclusive}, nil
+}
+
+func (r numRange) Kind() string { return "range" }
+
+func (r numRange) String() string { return r.str }
+
+func (r numRange) Repr(int) string { return r.str }
+
+func (r numRange) IndexString() (string, bool) { return r.str, r.str != "" }
+
+func (r numRange) Equal(other any) bool {
+	r2, ok := other.(numRange)
+	if !ok || r.inclusive != r2.inclusive {
+		return false
+	}
+	v, v2 := reflect.ValueOf(r.nums), reflect.ValueOf(r2.nums)
+	if v.Type() != v2.Type() {
+		return false
+	}
+	for i := 0; i < v.Len(); i++ {
+		if !vals.Equal(v.Index(i).Interface(), v2.Index(i).Interface()) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r numRange) Iterate(f func(any) bool) {
+	switch nums := r.nums.(type) {
+	case []int:
+		iterateBuiltinNums(nums, r.inclusive, f)
+	case []*big.Int:
+		iterateBigNums(nums, r.inclusive, f, bigIntDesc)
+	case []*big.Rat:
+		iterateBigNums(nums, r.inclusive, f, bigRatDesc)
+	case []float64:
+		iterateBuiltinNums(nums, r.inclusive, f)
+	}
+}
+
+var errInfiniteRangeJSON = errors.New("cannot encode an infinite range as JSON")
+
+// MarshalJSON encodes the range as an array of its numbers.
+func (r numRange) MarshalJSON() ([]byte, error) {
+	if nums, ok := r.nums.([]float64); ok && math.IsInf(nums[1], 0) {
+		return nil, errInfiniteRangeJSON
+	}
+	list := []any{}
+	r.Iterate(func(v any) bool {
+		list = append(list, v)
+		return true
+	})
+	return json.Marshal(list)
+}
+
+type builtinNum interface{ int | float64 }
+
+// Returns the start, end and step of a range, filling in the default step.
+func builtinNumRange[T builtinNum](nums []T) ([]T, error) {
+	start, end := nums[0], nums[1]
+	if len(nums) == 3 {
+		step := nums[2]
+		if start <= end && step <= 0 {
+			return nil, errs.BadValue{
+				What: "step", Valid: "positive", Actual: vals.ToString(step)}
+		} else if start > end && step >= 0 {
+			return nil, errs.BadValue{
+				What: "step", Valid: "negative", Actual: vals.ToString(step)}
+		}
+		return nums, nil
+	}
+	if start <= end {
+		return []T{start, end, 1}, nil
+	}
+	return []T{start, end, -1}, nil
+}
+
+func iterateBuiltinNums[T builtinNum](nums []T, inclusive bool, f func(any) bool) {
+	start, end, step := nums[0], nums[1], nums[2]
+	if step > 0 {
+		for cur := start; cur < end || inclusive && cur == end; cur += step {
+			if !f(vals.FromGo(cur)) || cur+step <= cur {
+				break
+			}
+		}
+	} else {
+		for cur := start; cur > end || inclusive && cur == end; cur += step {
+			if !f(vals.FromGo(cur)) || cur+step >= cur {
+				break
+			}
+		}
+	}
+}
+
+type bigNum[T any] interface {
+	Cmp(T) int
+	Sign() int
+	Add(T, T) T
+}
+
+type bigNumDesc[T any] struct {
+	one     T
+	negOne  T
+	newZero func() T
+}
+
+var bigIntDesc = bigNumDesc[*big.Int]{
+	one:     big.NewInt(1),
+	negOne:  big.NewInt(-1),
+	newZero: func() *big.Int { return &big.Int{} },
+}
+
+var bigRatDesc = bigNumDesc[*big.Rat]{
+	one:     big.NewRat(1, 1),
+	negOne:  big.NewRat(-1, 1),
+	newZero: func() *big.Rat { return &big.Rat{} },
+}
+
+// Like builtinNumRange, but for big numbers.
+func bigNumRange[T bigNum[T]](nums []T, d bigNumDesc[T]) ([]T, error) {
+	start, end := nums[0], nums[1]
+	if len(nums) == 3 {
+		step := nums[2]
+		if start.Cmp(end) <= 0 && step.Sign() <= 0 {
+			return nil, errs.BadValue{
+				What: "step", Valid: "positive", Actual: vals.ToString(step)}
+		} else if start.Cmp(end) > 0 && step.Sign() >= 0 {
+			return nil, errs.BadValue{
+				What: "step", Valid: "negative", Actual: vals.ToString(step)}
+		}
+		return nums, nil
+	}
+	if start.Cmp(end) <= 0 {
+		return []T{start, end, d.one}, nil
+	}
+	return []T{start, end, d.negOne}, nil
+}
+
+func iterateBigNums[T bigNum[T]](nums []T, inclusive bool, f func(any) bool, d bigNumDesc[T]) {
+	start, end, step := nums[0], nums[1], nums[2]
+	// The sign of the comparison between the current value and the end that
+	// continues the iteration.
+	sign := -1
+	if step.Sign() < 0 {
+		sign = 1
+	}
+	for cur := start; ; {
+		c := cur.Cmp(end)
+		if c != sign && !(inclusive && c == 0) {
+			break
+		}
+		if !f(vals.FromGo(cur)) {
+			break
+		}
+		next := d.newZero()
+		next.Add(cur, step)
+		cur = next
+	}
+}
@@ -5,6 +5,7 @@ var (
 	GetHome   = &getHome
 	Getwd     = &getwd
 	OSExit    = &osExit
+	RandFloat = &randFloat64
 	TimeAfter = &timeAfter
 	TimeNow   = &timeNow
 )
@@ -0,0 +1,83 @@
+package vals
+
+import (
+	"encoding/json"
+
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/persistent/hash"
+)
+
+// Bytes is a sequence of bytes, for binary data that is not necessarily text.
+//
+// Unlike strings, Bytes are indexed and sliced by bytes regardless of UTF-8
+// boundaries, and indexing with a single index gives the byte as a number.
+type Bytes string
+
+var (
+	_ Lener          = Bytes("")
+	_ ErrIndexer     = Bytes("")
+	_ Concatter      = Bytes("")
+	_ Iterator       = Bytes("")
+	_ json.Marshaler = Bytes("")
+)
+
+// Kind returns "bytes".
+func (Bytes) Kind() string { return "bytes" }
+
+// Equal compares the bytes with another Bytes value. Bytes are never equal to
+// strings.
+func (b Bytes) Equal(rhs any) bool {
+	c, ok := rhs.(Bytes)
+	return ok && b == c
+}
+
+// Hash calculates the hash of the bytes.
+func (b Bytes) Hash() uint32 { return hash.String(string(b)) }
+
+// Repr returns an expression that evaluates to the bytes when the str module
+// is imported.
+func (b Bytes) Repr(int) string {
+	return "(str:to-bytes " + parse.Quote(string(b)) + ")"
+}
+
+// String returns the bytes unchanged, so that they are written as is when
+// output as bytes.
+func (b Bytes) String() string { return string(b) }
+
+// Len returns the number of bytes.
+func (b Bytes) Len() int { return len(b) }
+
+// Index returns the byte at an index as a number, or the Bytes in a slice.
+func (b Bytes) Index(k any) (any, error) {
+	index, err := ConvertListIndex(k, len(b))
+	if err != nil {
+		return nil, err
+	}
+	if index.Slice {
+		return b[index.Lower:index.Upper], nil
+	}
+	return int(b[index.Lower]), nil
+}
+
+// Concat concatenates with another Bytes value.
+func (b Bytes) Concat(rhs any) (any, error) {
+	if c, ok := rhs.(Bytes); ok {
+		return b + c, nil
+	}
+	return nil, ErrConcatNotImplemented
+}
+
+// Iterate calls f with each byte as a number.
+func (b Bytes) Iterate(f func(any) bool) {
+	for i := 0; i < len(b); i++ {
+		if !f(int(b[i])) {
+			return
+		}
+	}
+}
+
+// MarshalJSON encodes the bytes as a string of their base64 encoding, like
+// byte slices are encoded by the encoding/json package.
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]byte(b))
+}
@@ -0,0 +1,39 @@
+package vals
+
+import (
+	"encoding/json"
+	"testing"
+
+	"src.elv.sh/pkg/persistent/hash"
+	"src.elv.sh/pkg/tt"
+)
+
+func TestBytes(t *testing.T) {
+	TestValue(t, Bytes("a\xffb")).
+		Kind("bytes").
+		Bool(true).
+		Hash(hash.String("a\xffb")).
+		Len(3).
+		Repr(`(str:to-bytes "a\xffb")`).
+		Equal(Bytes("a\xffb")).
+		NotEqual("a\xffb", Bytes("ab")).
+		Index("0", int('a')).
+		Index(1, 0xff).
+		Index("1..", Bytes("\xffb")).
+		Index("1..2", Bytes("\xff"))
+
+	tt.Test(t, tt.Fn("ToString", ToString), tt.Table{
+		tt.Args(Bytes("a\xffb")).Rets("a\xffb"),
+	})
+	tt.Test(t, tt.Fn("Concat", Concat), tt.Table{
+		tt.Args(Bytes("a"), Bytes("\xff")).Rets(Bytes("a\xff"), nil),
+		tt.Args(Bytes("a"), "b").Rets(nil, cannotConcat{"bytes", "string"}),
+		tt.Args("a", Bytes("b")).Rets(nil, cannotConcat{"string", "bytes"}),
+	})
+	tt.Test(t, tt.Fn("Collect", Collect), tt.Table{
+		tt.Args(Bytes("a\xff")).Rets([]any{int('a'), 0xff}, nil),
+	})
+	tt.Test(t, tt.Fn("json.Marshal", json.Marshal), tt.Table{
+		tt.Args(Bytes("a\xff")).Rets([]byte(`"Yf8="`), nil),
+	})
+}
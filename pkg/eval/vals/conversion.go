@@ -305,3 +305,57 @@ func FromGo(a any) any {
 		return a
 	}
 }
+
+// FromGoDeep is like FromGo, but also converts Go values of types that Elvish
+// doesn't use natively, which is useful when passing arbitrary Go values to
+// Elvish code:
+//
+//   - Integers of other sizes are converted to int, or *big.Int if they don't
+//     fit in an int. The exception is int32, which is the same type as rune:
+//     like in FromGo, int32 values are converted to strings of one character,
+//     including in slices like []int32.
+//
+//   - float32 values are converted to float64.
+//
+//   - Slices and arrays are converted to lists, and maps are converted to
+//     maps, with their elements converted recursively.
+//
+// Values of named types defined in packages, including Elvish's own types,
+// only undergo the conversion of FromGo.
+func FromGoDeep(a any) any {
+	switch a.(type) {
+	case nil, bool, string, int, float64:
+		return a
+	}
+	v := reflect.ValueOf(a)
+	if v.Type().PkgPath() != "" {
+		return FromGo(a)
+	}
+	switch v.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == reflect.TypeOf(rune(0)) {
+			// rune is an alias of int32; keep the behavior of FromGo.
+			break
+		}
+		return NormalizeBigInt(big.NewInt(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return NormalizeBigInt(new(big.Int).SetUint64(v.Uint()))
+	case reflect.Float32:
+		return v.Float()
+	case reflect.Slice, reflect.Array:
+		l := EmptyList
+		for i := 0; i < v.Len(); i++ {
+			l = l.Conj(FromGoDeep(v.Index(i).Interface()))
+		}
+		return l
+	case reflect.Map:
+		m := EmptyMap
+		for it := v.MapRange(); it.Next(); {
+			m = m.Assoc(FromGoDeep(it.Key().Interface()),
+				FromGoDeep(it.Value().Interface()))
+		}
+		return m
+	}
+	return FromGo(a)
+}
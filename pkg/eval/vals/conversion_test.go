@@ -225,3 +225,33 @@ func TestFromGo(t *testing.T) {
 		Args(someType{"foo"}).Rets(someType{"foo"}),
 	})
 }
+
+func TestFromGoDeep(t *testing.T) {
+	tt.Test(t, tt.Fn("FromGoDeep", FromGoDeep), tt.Table{
+		// Conversions done by FromGo
+		Args(big.NewInt(100)).Rets(100),
+		Args('x').Rets("x"),
+		// Other integer and float types
+		Args(int64(100)).Rets(100),
+		Args(uint8(100)).Rets(100),
+		Args(uint64(1 << 63)).Rets(bigInt("9223372036854775808")),
+		Args(float32(0.5)).Rets(0.5),
+		// Slices, arrays and maps
+		Args([]string{"foo", "bar"}).Rets(MakeList("foo", "bar")),
+		Args([2]int64{1, 2}).Rets(MakeList(1, 2)),
+		// int32 is the same as rune, so it is converted to a string
+		Args([]int32{'a', 'b'}).Rets(MakeList("a", "b")),
+		// Values of named types are not converted
+		Args(someType{"foo"}).Rets(someType{"foo"}),
+		Args(MakeList("foo")).Rets(MakeList("foo")),
+		Args(nil).Rets(nil),
+	})
+}
+
+func TestFromGoDeep_Map(t *testing.T) {
+	got := FromGoDeep(map[string][]int8{"foo": {1}})
+	want := MakeMap("foo", MakeList(1))
+	if !Equal(got, want) {
+		t.Errorf("got %s, want %s", ReprPlain(got), ReprPlain(want))
+	}
+}
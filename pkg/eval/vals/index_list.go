@@ -25,6 +25,14 @@ func indexList(l List, rawIndex any) (any, error) {
 	return value, nil
 }
 
+// RangeIndex is implemented by the ranges produced by range expressions, which
+// are used as list indices like their string forms, so that
+// has-key [a b c] 0..2 works like $li[0..2].
+type RangeIndex interface {
+	// Returns the string form of the range, or false if it doesn't have one.
+	IndexString() (string, bool)
+}
+
 // ListIndex represents a (converted) list index.
 type ListIndex struct {
 	Slice bool
@@ -95,6 +103,11 @@ func ConvertListIndex(rawIndex any, n int) (*ListIndex, error) {
 			}
 		}
 		return &ListIndex{slice, i, j}, nil
+	case RangeIndex:
+		if s, ok := rawIndex.IndexString(); ok {
+			return ConvertListIndex(s, n)
+		}
+		return nil, errIndexMustBeInteger
 	default:
 		return nil, errIndexMustBeInteger
 	}
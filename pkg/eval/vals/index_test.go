@@ -114,6 +114,12 @@ func TestIndex(t *testing.T) {
 		// TODO(xiaq): Make the error more accurate.
 		Args(li4, "1:3:2").Rets(tt.Any, errIndexMustBeInteger),
 
+		// Ranges are used as indices by their string forms, but not other
+		// values that have one.
+		Args(li4, testRange("1..3")).Rets(eq(MakeList("bar", "lorem")), nil),
+		Args(li4, testRange("")).Rets(tt.Any, errIndexMustBeInteger),
+		Args(li4, testStringer{}).Rets(tt.Any, errIndexMustBeInteger),
+
 		// Map indices
 		// ============
 
@@ -125,6 +131,14 @@ func TestIndex(t *testing.T) {
 	})
 }
 
+type testRange string
+
+func (r testRange) IndexString() (string, bool) { return string(r), r != "" }
+
+type testStringer struct{}
+
+func (testStringer) String() string { return "1..3" }
+
 func TestIndex_File(t *testing.T) {
 	testutil.InTempDir(t)
 	f, err := os.Create("f")
@@ -0,0 +1,115 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var (
+	caseMutex            sync.RWMutex
+	caseInsensitivePaths map[string]bool
+)
+
+// CaseInsensitivePaths returns the paths configured with
+// SetCaseInsensitivePaths.
+func CaseInsensitivePaths() map[string]bool {
+	caseMutex.RLock()
+	defer caseMutex.RUnlock()
+	m := make(map[string]bool, len(caseInsensitivePaths))
+	for path, ci := range caseInsensitivePaths {
+		m[path] = ci
+	}
+	return m
+}
+
+// SetCaseInsensitivePaths sets whether file names are matched
+// case-insensitively under each of the given absolute paths, overriding the
+// detection of IsCaseInsensitive. When the paths are nested, the longest one
+// applies.
+func SetCaseInsensitivePaths(m map[string]bool) {
+	paths := make(map[string]bool, len(m))
+	for path, ci := range m {
+		paths[filepath.Clean(path)] = ci
+	}
+	caseMutex.Lock()
+	defer caseMutex.Unlock()
+	caseInsensitivePaths = paths
+}
+
+// IsCaseInsensitive returns whether file names in dir are matched
+// case-insensitively, like on the default file systems of macOS and Windows.
+//
+// Paths configured with SetCaseInsensitivePaths take precedence. Otherwise,
+// this is detected by looking up the name of dir, or of the nearest parent
+// that exists, with the case of a letter changed. If there are no letters to
+// change, file names are assumed to be case-insensitive on macOS and Windows.
+func IsCaseInsensitive(dir string) bool {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return caseInsensitiveByDefault()
+	}
+	if ci, ok := configuredCaseInsensitive(abs); ok {
+		return ci
+	}
+	return detectCaseInsensitive(abs)
+}
+
+func configuredCaseInsensitive(path string) (ci, ok bool) {
+	caseMutex.RLock()
+	defer caseMutex.RUnlock()
+	longest := -1
+	for p, v := range caseInsensitivePaths {
+		if len(p) > longest && isUnder(path, p) {
+			ci, ok, longest = v, true, len(p)
+		}
+	}
+	return ci, ok
+}
+
+// Returns whether path is dir or inside it. Both must be clean and absolute.
+func isUnder(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." &&
+		!strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func detectCaseInsensitive(path string) bool {
+	for {
+		base := filepath.Base(path)
+		if swapped := swapCase(base); swapped != base {
+			if info, err := os.Lstat(path); err == nil {
+				swappedInfo, err := os.Lstat(filepath.Join(filepath.Dir(path), swapped))
+				// If both names exist but are different files, file names are
+				// case-sensitive.
+				return err == nil && os.SameFile(info, swappedInfo)
+			}
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return caseInsensitiveByDefault()
+		}
+		path = parent
+	}
+}
+
+// Changes the case of the first ASCII letter in s. Other letters are left
+// alone, since file systems don't agree on how to fold them.
+func swapCase(s string) string {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z':
+			return s[:i] + string(c-'a'+'A') + s[i+1:]
+		case 'A' <= c && c <= 'Z':
+			return s[:i] + string(c-'A'+'a') + s[i+1:]
+		}
+	}
+	return s
+}
+
+func caseInsensitiveByDefault() bool {
+	return runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+}
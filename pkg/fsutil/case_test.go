@@ -0,0 +1,60 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestIsCaseInsensitive_Detected(t *testing.T) {
+	testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{"d": testutil.Dir{}})
+
+	if err := os.Mkdir("D", 0700); err != nil {
+		// "D" is the same directory as "d".
+		if !IsCaseInsensitive("d") {
+			t.Errorf("IsCaseInsensitive -> false, want true when d and D are the same")
+		}
+		return
+	}
+	if IsCaseInsensitive("d") {
+		t.Errorf("IsCaseInsensitive -> true, want false when d and D are different")
+	}
+	// A name that doesn't exist is looked up in the nearest parent.
+	if IsCaseInsensitive(filepath.Join("d", "x", "y")) {
+		t.Errorf("IsCaseInsensitive -> true, want false for a path that doesn't exist")
+	}
+}
+
+func TestIsCaseInsensitive_Configured(t *testing.T) {
+	dir := testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{"a": testutil.Dir{"b": testutil.Dir{}}})
+	t.Cleanup(func() { SetCaseInsensitivePaths(nil) })
+
+	SetCaseInsensitivePaths(map[string]bool{
+		dir:                          true,
+		filepath.Join(dir, "a", "b"): false,
+	})
+	for _, tc := range []struct {
+		path string
+		want bool
+	}{
+		{".", true},
+		{"a", true},
+		{filepath.Join("a", "b"), false},
+		{filepath.Join("a", "b", "c"), false},
+		// Not under a configured path.
+		{filepath.Join("a", "bc"), true},
+	} {
+		if got := IsCaseInsensitive(tc.path); got != tc.want {
+			t.Errorf("IsCaseInsensitive(%q) -> %v, want %v", tc.path, got, tc.want)
+		}
+	}
+
+	paths := CaseInsensitivePaths()
+	if len(paths) != 2 || !paths[dir] || paths[filepath.Join(dir, "a", "b")] {
+		t.Errorf("CaseInsensitivePaths -> %v", paths)
+	}
+}
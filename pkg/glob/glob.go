@@ -4,7 +4,11 @@ package glob
 import (
 	"os"
 	"runtime"
+	"strings"
+	"unicode"
 	"unicode/utf8"
+
+	"src.elv.sh/pkg/fsutil"
 )
 
 // TODO: On Windows, preserve the original path separator (/ or \) specified in
@@ -48,7 +52,7 @@ func (p Pattern) Glob(cb func(PathInfo) bool) bool {
 		}
 	}
 
-	return glob(segs, dir, cb)
+	return glob(segs, dir, nil, cb)
 }
 
 // isLetter returns true if the byte is an ASCII letter.
@@ -64,7 +68,12 @@ func isDrive(s string) bool {
 // glob finds all filenames matching the given Segments in the given dir, and
 // calls the callback on all of them. If the callback returns false, globbing is
 // interrupted, and glob returns false. Otherwise it returns true.
-func glob(segs []Segment, dir string, cb func(PathInfo) bool) bool {
+//
+// The fold argument is whether names are matched case-insensitively. It is nil
+// until the first directory to be searched is reached, and then set according
+// to the file system of that directory, which is assumed to hold for its
+// subdirectories too.
+func glob(segs []Segment, dir string, fold *bool, cb func(PathInfo) bool) bool {
 	// Consume non-wildcard path elements simply by following the path. This may
 	// seem like an optimization, but is actually required for "." and ".." to
 	// be used as path elements, as they do not appear in the result of ReadDir.
@@ -88,7 +97,17 @@ func glob(segs []Segment, dir string, cb func(PathInfo) bool) bool {
 			return cb(PathInfo{dir, info})
 		}
 		return true
-	} else if len(segs) == 1 && IsLiteral(segs[0]) {
+	}
+
+	if fold == nil {
+		ci := fsutil.IsCaseInsensitive(dirOrDot(dir))
+		fold = &ci
+	}
+
+	// On case-insensitive file systems, a literal name is matched against the
+	// directory entries like wildcards, so that the result has the case of the
+	// file rather than the pattern.
+	if len(segs) == 1 && IsLiteral(segs[0]) && (!*fold || isDotOrDotDot(segs[0])) {
 		path := dir + segs[0].(Literal).Data
 		if info, err := os.Lstat(path); err == nil {
 			return cb(PathInfo{path, info})
@@ -96,7 +115,7 @@ func glob(segs []Segment, dir string, cb func(PathInfo) bool) bool {
 		return true
 	}
 
-	infos, err := readDir(dir)
+	infos, err := os.ReadDir(dirOrDot(dir))
 	if err != nil {
 		// TODO(xiaq): Silently drop the error.
 		return true
@@ -147,8 +166,8 @@ func glob(segs []Segment, dir string, cb func(PathInfo) bool) bool {
 
 		for _, info := range infos {
 			name := info.Name()
-			if matchElement(first, name) && info.IsDir() {
-				if !glob(rest, dir+name+"/", cb) {
+			if matchElement(first, name, *fold) && info.IsDir() {
+				if !glob(rest, dir+name+"/", fold, cb) {
 					return false
 				}
 			}
@@ -165,7 +184,7 @@ func glob(segs []Segment, dir string, cb func(PathInfo) bool) bool {
 	// the entire pattern with all files.
 	for _, info := range infos {
 		name := info.Name()
-		if matchElement(segs, name) {
+		if matchElement(segs, name, *fold) {
 			dirname := dir + name
 			info, err := os.Lstat(dirname)
 			if err != nil {
@@ -179,17 +198,23 @@ func glob(segs []Segment, dir string, cb func(PathInfo) bool) bool {
 	return true
 }
 
-// readDir is just like os.ReadDir except that it treats an argument of "" as ".".
-func readDir(dir string) ([]os.DirEntry, error) {
+// dirOrDot returns dir, or "." if dir is "".
+func dirOrDot(dir string) string {
 	if dir == "" {
-		dir = "."
+		return "."
 	}
-	return os.ReadDir(dir)
+	return dir
+}
+
+func isDotOrDotDot(seg Segment) bool {
+	data := seg.(Literal).Data
+	return data == "." || data == ".."
 }
 
 // matchElement matches a path element against segments, which may not contain
 // any Slash segments. It treats StarStar segments as they are Star segments.
-func matchElement(segs []Segment, name string) bool {
+// When fold is true, literal segments are matched case-insensitively.
+func matchElement(segs []Segment, name string, fold bool) bool {
 	if len(segs) == 0 {
 		return name == ""
 	}
@@ -223,7 +248,7 @@ segs:
 
 		// Match at the current position. If this is the last chunk, we need to
 		// make sure name is exhausted by the matching.
-		ok, rest := matchFixedLength(chunk, name)
+		ok, rest := matchFixedLength(chunk, name, fold)
 		if ok && (rest == "" || len(segs) > 0) {
 			name = rest
 			continue
@@ -239,7 +264,7 @@ segs:
 				if !startingStar.Match(r) {
 					break
 				}
-				ok, rest := matchFixedLength(chunk, name[j:])
+				ok, rest := matchFixedLength(chunk, name[j:], fold)
 				if ok && (rest == "" || len(segs) > 0) {
 					name = rest
 					continue segs
@@ -255,18 +280,18 @@ segs:
 // matchFixedLength returns whether a run of fixed-length segments (Literal and
 // Question) matches a prefix of name. It returns whether the match is
 // successful and if it is, the remaining part of name.
-func matchFixedLength(segs []Segment, name string) (bool, string) {
+func matchFixedLength(segs []Segment, name string, fold bool) (bool, string) {
 	for _, seg := range segs {
 		if name == "" {
 			return false, ""
 		}
 		switch seg := seg.(type) {
 		case Literal:
-			n := len(seg.Data)
-			if len(name) < n || name[:n] != seg.Data {
+			rest, ok := cutPrefix(name, seg.Data, fold)
+			if !ok {
 				return false, ""
 			}
-			name = name[n:]
+			name = rest
 		case Wild:
 			if seg.Type == Question {
 				r, n := utf8.DecodeRuneInString(name)
@@ -283,3 +308,41 @@ func matchFixedLength(segs []Segment, name string) (bool, string) {
 	}
 	return true, name
 }
+
+// cutPrefix returns name without prefix and true if name starts with prefix,
+// or "" and false otherwise. When fold is true, letters are compared
+// case-insensitively.
+func cutPrefix(name, prefix string, fold bool) (string, bool) {
+	if !fold {
+		if strings.HasPrefix(name, prefix) {
+			return name[len(prefix):], true
+		}
+		return "", false
+	}
+	for prefix != "" {
+		if name == "" {
+			return "", false
+		}
+		r1, n1 := utf8.DecodeRuneInString(prefix)
+		r2, n2 := utf8.DecodeRuneInString(name)
+		if !equalFold(r1, r2) {
+			return "", false
+		}
+		prefix, name = prefix[n1:], name[n2:]
+	}
+	return name, true
+}
+
+// equalFold returns whether two runes are equal under simple Unicode case
+// folding, like strings.EqualFold.
+func equalFold(r1, r2 rune) bool {
+	if r1 == r2 {
+		return true
+	}
+	for r := unicode.SimpleFold(r1); r != r1; r = unicode.SimpleFold(r) {
+		if r == r2 {
+			return true
+		}
+	}
+	return false
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"testing"
 
+	"src.elv.sh/pkg/fsutil"
 	"src.elv.sh/pkg/testutil"
 )
 
@@ -125,6 +126,33 @@ func testGlob(t *testing.T, abs bool) {
 	}
 }
 
+func TestGlob_CaseInsensitive(t *testing.T) {
+	dir := testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{
+		"README.md": "",
+		"src":       testutil.Dir{"Main.go": ""},
+	})
+	t.Cleanup(func() { fsutil.SetCaseInsensitivePaths(nil) })
+
+	fsutil.SetCaseInsensitivePaths(map[string]bool{dir: true})
+	for _, tc := range []globCase{
+		{"readme*", []string{"README.md"}},
+		{"*.MD", []string{"README.md"}},
+		{"src/*.GO", []string{"src/Main.go"}},
+		// Literal names get the case of the file too.
+		{"*/main.go", []string{"src/Main.go"}},
+	} {
+		if paths := globPaths(tc.pattern); !reflect.DeepEqual(paths, tc.want) {
+			t.Errorf("Glob(%q) => %v, want %v", tc.pattern, paths, tc.want)
+		}
+	}
+
+	fsutil.SetCaseInsensitivePaths(map[string]bool{dir: false})
+	if paths := globPaths("readme*"); len(paths) != 0 {
+		t.Errorf("Glob(%q) => %v, want no match", "readme*", paths)
+	}
+}
+
 // Regression test for b.elv.sh/1220
 func TestGlob_InvalidUTF8InFilename(t *testing.T) {
 	if runtime.GOOS == "windows" {
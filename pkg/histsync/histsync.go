@@ -0,0 +1,294 @@
+// Package histsync syncs the command history of stores on different hosts.
+//
+// The stores share a sync file, which holds the union of their command
+// histories as JSON, and is kept on a remote that all of them can reach: an
+// HTTP server that supports GET and PUT, like a WebDAV server or another
+// Elvish (see Handler), or a file in a directory that is synced by other
+// means. Syncing merges the sync file into the store and then writes the
+// commands that the sync file lacks back to it.
+//
+// Since each command is identified by the store it was first added to and
+// its sequence number there, merging is free of conflicts: syncing in any
+// order, or concurrently, makes all the stores end up with the same commands.
+// Deleting a command from a store doesn't delete it from the sync file or
+// other stores.
+package histsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+// ErrConflict is returned by Remote.Put when the sync file has been changed
+// since it was read.
+var ErrConflict = errors.New("sync file changed while syncing")
+
+// Remote is where the sync file is kept.
+type Remote interface {
+	// Get returns the content of the sync file, or nil if it doesn't exist
+	// yet, and a tag identifying its version, which may be empty if unknown.
+	Get() (data []byte, tag string, err error)
+	// Put replaces the content of the sync file. If tag is not empty, it
+	// returns ErrConflict if the version of the sync file is no longer tag.
+	Put(data []byte, tag string) error
+}
+
+// Version of the format of the sync file.
+const formatVersion = 1
+
+// Sync files larger than this are rejected.
+const maxFileSize = 64 << 20
+
+// How many times Sync tries to write the sync file when it is changed
+// concurrently.
+const maxAttempts = 3
+
+type syncFile struct {
+	Version int                 `json:"version"`
+	Cmds    []storedefs.SyncCmd `json:"cmds"`
+}
+
+// Result contains the number of commands transferred by Sync.
+type Result struct {
+	// Commands added to the store from the sync file.
+	Pulled int
+	// Commands added to the sync file from the store.
+	Pushed int
+}
+
+func (Result) IsStructMap() {}
+
+// Sync syncs the command history of st with the sync file on r.
+func Sync(st storedefs.Store, r Remote) (Result, error) {
+	var res Result
+	for attempt := 1; ; attempt++ {
+		data, tag, err := r.Get()
+		if err != nil {
+			return res, err
+		}
+		remote, err := decode(data)
+		if err != nil {
+			return res, err
+		}
+		pulled, err := st.MergeCmds(remote)
+		res.Pulled += pulled
+		if err != nil {
+			return res, err
+		}
+		local, err := st.SyncCmds()
+		if err != nil {
+			return res, err
+		}
+		missing := missingCmds(remote, local)
+		if len(missing) == 0 {
+			return res, nil
+		}
+		data, err = encode(append(remote, missing...))
+		if err != nil {
+			return res, err
+		}
+		err = r.Put(data, tag)
+		if err == ErrConflict && attempt < maxAttempts {
+			continue
+		}
+		if err == nil {
+			res.Pushed = len(missing)
+		}
+		return res, err
+	}
+}
+
+type cmdKey struct {
+	origin string
+	seq    int
+}
+
+// Returns the commands in local that are not in remote.
+func missingCmds(remote, local []storedefs.SyncCmd) []storedefs.SyncCmd {
+	known := make(map[cmdKey]bool, len(remote))
+	for _, cmd := range remote {
+		known[cmdKey{cmd.Origin, cmd.Seq}] = true
+	}
+	var missing []storedefs.SyncCmd
+	for _, cmd := range local {
+		if !known[cmdKey{cmd.Origin, cmd.Seq}] {
+			missing = append(missing, cmd)
+		}
+	}
+	return missing
+}
+
+func decode(data []byte) ([]storedefs.SyncCmd, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var f syncFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse sync file: %w", err)
+	}
+	if f.Version != formatVersion {
+		return nil, fmt.Errorf("sync file has unsupported version %d", f.Version)
+	}
+	return f.Cmds, nil
+}
+
+func encode(cmds []storedefs.SyncCmd) ([]byte, error) {
+	return json.Marshal(syncFile{formatVersion, cmds})
+}
+
+// ErrInsecureToken is returned by NewRemote when the token would be sent over
+// plain HTTP to a host other than the local one.
+var ErrInsecureToken = errors.New("refusing to send token over plain HTTP to a non-loopback host")
+
+// NewRemote returns the Remote for the sync file at target, which is either
+// an http:// or https:// URL, or a path. If token is not empty, it is sent in
+// HTTP requests as a bearer token; with an http:// URL, this is only allowed
+// if the host is a loopback address or "localhost", like when the remote is
+// reached through an SSH tunnel.
+func NewRemote(target, token string) (Remote, error) {
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		if token != "" && strings.HasPrefix(target, "http://") {
+			u, err := url.Parse(target)
+			if err != nil {
+				return nil, err
+			}
+			if !isLoopbackHost(u.Hostname()) {
+				return nil, ErrInsecureToken
+			}
+		}
+		return &httpRemote{target, token, &http.Client{Timeout: 30 * time.Second}}, nil
+	}
+	return fileRemote{target}, nil
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+type httpRemote struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (r *httpRemote) Get() ([]byte, string, error) {
+	resp, err := r.do(http.MethodGet, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, "", nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, "", fmt.Errorf("get %s: %s", r.url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFileSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("get %s: %w", r.url, err)
+	}
+	if len(data) > maxFileSize {
+		return nil, "", fmt.Errorf("get %s: sync file larger than %d bytes", r.url, maxFileSize)
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+func (r *httpRemote) Put(data []byte, tag string) error {
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	if tag != "" {
+		header.Set("If-Match", tag)
+	}
+	resp, err := r.do(http.MethodPut, header, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return ErrConflict
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("put %s: %s", r.url, resp.Status)
+	}
+	return nil
+}
+
+func (r *httpRemote) do(method string, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, r.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	return r.client.Do(req)
+}
+
+type fileRemote struct{ path string }
+
+func (r fileRemote) Get() ([]byte, string, error) {
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	tag, err := r.tag()
+	return data, tag, err
+}
+
+func (r fileRemote) Put(data []byte, tag string) error {
+	// Write to a temporary file next to the sync file and rename it, so that
+	// readers never see a partially written sync file.
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err2 := tmp.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		return err
+	}
+	if tag != "" {
+		// This leaves a small window for a concurrent change to be lost.
+		// Since the change is still in the store that made it, it will be
+		// written again when that store syncs next time.
+		if current, err := r.tag(); err != nil || current != tag {
+			return ErrConflict
+		}
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
+
+// Returns a tag for the current version of the sync file, made from its size
+// and modification time.
+func (r fileRemote) tag() (string, error) {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano()), nil
+}
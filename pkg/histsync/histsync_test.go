@@ -0,0 +1,211 @@
+package histsync
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestSync_File(t *testing.T) {
+	r := must.OK1(NewRemote(filepath.Join(testutil.TempDir(t), "history.json"), ""))
+	a, b := newStore(t, "a1", "a2"), newStore(t, "b1")
+
+	testSync(t, a, r, Result{Pulled: 0, Pushed: 2})
+	testSync(t, b, r, Result{Pulled: 2, Pushed: 1})
+	testSync(t, a, r, Result{Pulled: 1, Pushed: 0})
+	testSync(t, b, r, Result{Pulled: 0, Pushed: 0})
+
+	testCmds(t, a, "a1", "a2", "b1")
+	testCmds(t, b, "b1", "a1", "a2")
+}
+
+func TestSync_Handler(t *testing.T) {
+	server := newStore(t, "s1")
+	ts := httptest.NewServer(Handler(server, "secret"))
+	defer ts.Close()
+	client := newStore(t, "c1")
+
+	testSync(t, client, must.OK1(NewRemote(ts.URL, "secret")), Result{Pulled: 1, Pushed: 1})
+	testCmds(t, server, "s1", "c1")
+	testCmds(t, client, "c1", "s1")
+
+	_, err := Sync(client, must.OK1(NewRemote(ts.URL, "wrong")))
+	if err == nil {
+		t.Errorf("Sync with wrong token -> nil error, want non-nil")
+	}
+}
+
+func TestHandler_RequiresToken(t *testing.T) {
+	ts := httptest.NewServer(Handler(newStore(t, "s1"), ""))
+	defer ts.Close()
+	client := newStore(t, "c1")
+
+	_, err := Sync(client, must.OK1(NewRemote(ts.URL, "")))
+	if err == nil {
+		t.Errorf("Sync with handler without token -> nil error, want non-nil")
+	}
+	testCmds(t, client, "c1")
+}
+
+func TestNewToken(t *testing.T) {
+	a, b := must.OK1(NewToken()), must.OK1(NewToken())
+	if a == "" || a == b {
+		t.Errorf("NewToken -> %q, %q, want distinct non-empty tokens", a, b)
+	}
+}
+
+func TestNewRemote_InsecureToken(t *testing.T) {
+	for _, target := range []string{"http://localhost:8080/", "http://127.0.0.1/", "http://[::1]/",
+		"https://example.com/", "path"} {
+		if _, err := NewRemote(target, "secret"); err != nil {
+			t.Errorf("NewRemote(%q) -> error %v, want nil", target, err)
+		}
+	}
+	if _, err := NewRemote("http://example.com/", ""); err != nil {
+		t.Errorf("NewRemote without token -> error %v, want nil", err)
+	}
+	if _, err := NewRemote("http://example.com/", "secret"); err != ErrInsecureToken {
+		t.Errorf("NewRemote -> error %v, want %v", err, ErrInsecureToken)
+	}
+}
+
+func TestListen(t *testing.T) {
+	l, err := Listen("127.0.0.1:0", "")
+	if err != nil {
+		t.Errorf("Listen on loopback address -> error %v, want nil", err)
+	} else {
+		l.Close()
+	}
+	if l, err := Listen(":0", "secret"); err != nil {
+		t.Errorf("Listen with token -> error %v, want nil", err)
+	} else {
+		l.Close()
+	}
+	if _, err := Listen(":0", ""); err != ErrTokenRequired {
+		t.Errorf("Listen -> error %v, want %v", err, ErrTokenRequired)
+	}
+}
+
+func TestSync_RetriesOnConflict(t *testing.T) {
+	r := &conflictingRemote{conflicts: 1}
+	testSync(t, newStore(t, "a1"), r, Result{Pulled: 0, Pushed: 1})
+
+	r = &conflictingRemote{conflicts: maxAttempts}
+	if _, err := Sync(newStore(t, "a1"), r); err != ErrConflict {
+		t.Errorf("Sync -> error %v, want %v", err, ErrConflict)
+	}
+}
+
+func TestSync_BadSyncFile(t *testing.T) {
+	path := filepath.Join(testutil.TempDir(t), "history.json")
+	for _, content := range []string{"not json", `{"version": 2, "cmds": []}`} {
+		os.WriteFile(path, []byte(content), 0o600)
+		if _, err := Sync(newStore(t), must.OK1(NewRemote(path, ""))); err == nil {
+			t.Errorf("Sync with sync file %q -> nil error, want non-nil", content)
+		}
+	}
+}
+
+func TestHTTPRemote_ChecksVersion(t *testing.T) {
+	var mutex sync.Mutex
+	data, version := []byte(nil), 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		etag := fmt.Sprintf(`"%d"`, version)
+		switch r.Method {
+		case http.MethodGet:
+			if data == nil {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			w.Write(data)
+		case http.MethodPut:
+			if m := r.Header.Get("If-Match"); m != "" && m != etag {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			data, _ = io.ReadAll(r.Body)
+			version++
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer ts.Close()
+	r := must.OK1(NewRemote(ts.URL, ""))
+
+	if got, tag, err := r.Get(); got != nil || tag != "" || err != nil {
+		t.Errorf("Get -> (%q, %q, %v), want (nil, \"\", nil)", got, tag, err)
+	}
+	if err := r.Put([]byte("foo"), ""); err != nil {
+		t.Errorf("Put -> %v, want nil", err)
+	}
+	got, tag, err := r.Get()
+	if string(got) != "foo" || tag != `"1"` || err != nil {
+		t.Errorf("Get -> (%q, %q, %v), want (%q, %q, nil)", got, tag, err, "foo", `"1"`)
+	}
+	if err := r.Put([]byte("bar"), `"0"`); err != ErrConflict {
+		t.Errorf("Put with old tag -> %v, want %v", err, ErrConflict)
+	}
+	if err := r.Put([]byte("bar"), tag); err != nil {
+		t.Errorf("Put with current tag -> %v, want nil", err)
+	}
+}
+
+func newStore(t *testing.T, cmds ...string) storedefs.Store {
+	st := store.MustTempStore(t)
+	for _, cmd := range cmds {
+		st.AddCmd(cmd)
+	}
+	return st
+}
+
+func testSync(t *testing.T, st storedefs.Store, r Remote, want Result) {
+	t.Helper()
+	if res, err := Sync(st, r); res != want || err != nil {
+		t.Errorf("Sync -> (%v, %v), want (%v, nil)", res, err, want)
+	}
+}
+
+func testCmds(t *testing.T, st storedefs.Store, want ...string) {
+	t.Helper()
+	cmds, err := st.CmdsWithSeq(0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, cmd := range cmds {
+		got = append(got, cmd.Text)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q, want %q", got, want)
+	}
+}
+
+// A remote whose Put fails with ErrConflict the given number of times.
+type conflictingRemote struct {
+	data      []byte
+	conflicts int
+}
+
+func (r *conflictingRemote) Get() ([]byte, string, error) { return r.data, "tag", nil }
+
+func (r *conflictingRemote) Put(data []byte, tag string) error {
+	if r.conflicts > 0 {
+		r.conflicts--
+		return ErrConflict
+	}
+	r.data = data
+	return nil
+}
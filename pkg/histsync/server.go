@@ -0,0 +1,102 @@
+package histsync
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+// Handler returns an HTTP handler that serves the command history of st as a
+// sync file, so that the stores on other hosts can sync with st by using its
+// URL as the remote.
+//
+// GET requests get the sync file made from all the commands in st, and PUT
+// requests merge the sync file in the request body into st. Requests must
+// carry token as a bearer token; if token is empty, all requests are rejected.
+// A token is required even on a loopback address, since other local users and
+// web pages (through DNS rebinding) can reach it too; use NewToken to make one.
+func Handler(st storedefs.Store, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" || !validToken(r, token) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			cmds, err := st.SyncCmds()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			data, err := encode(cmds)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(data)
+		case http.MethodPut:
+			// Merging is free of conflicts, so If-Match is not checked.
+			data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxFileSize))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			cmds, err := decode(data)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if _, err := st.MergeCmds(cmds); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// ErrTokenRequired is returned by Listen when the token is empty and the
+// address is not a loopback address.
+var ErrTokenRequired = errors.New("a token is required to serve history on a non-loopback address")
+
+// Listen listens on the TCP address addr, for serving requests with Handler.
+// Unless token is not empty, addr must be a loopback address, so that a token
+// that is not chosen by the user (like one from NewToken) is never needed on
+// other hosts, where it would be sent over plain HTTP.
+func Listen(addr, token string) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if tcpAddr, ok := l.Addr().(*net.TCPAddr); token == "" && !(ok && tcpAddr.IP.IsLoopback()) {
+		l.Close()
+		return nil, ErrTokenRequired
+	}
+	return l, nil
+}
+
+// NewToken returns a random token for use with Handler.
+func NewToken() (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf[:]), nil
+}
+
+func validToken(r *http.Request, token string) bool {
+	got := r.Header.Get("Authorization")
+	want := "Bearer " + token
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
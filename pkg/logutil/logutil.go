@@ -1,55 +1,256 @@
 // Package logutil provides logging utilities.
+//
+// Log messages have levels, and are only written when their level is at least
+// the minimum level in the configuration. They can be written as text or as
+// JSON, one message per line. When logging to a file set by SetOutputFile, the
+// file is rotated when it grows beyond the configured size.
 package logutil
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
-	"log"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
+// Level is the level of log messages.
+type Level int
+
+// Possible values of Level.
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if 0 <= l && int(l) < len(levelNames) {
+		return levelNames[l]
+	}
+	return "level " + strconv.Itoa(int(l))
+}
+
+// ParseLevel parses the name of a level, one of "debug", "info", "warn" and
+// "error".
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if s == name {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("bad log level %q, must be one of debug, info, warn and error", s)
+}
+
+// Format is the format of log messages.
+type Format int
+
+// Possible values of Format.
+const (
+	// Lines like "2006/01/02 15:04:05 INFO [daemon] message".
+	Text Format = iota
+	// JSON objects with the fields "time", "level", "logger" and "msg".
+	JSON
+)
+
+func (f Format) String() string {
+	if f == JSON {
+		return "json"
+	}
+	return "text"
+}
+
+// ParseFormat parses the name of a format, either "text" or "json".
+func ParseFormat(s string) (Format, error) {
+	switch s {
+	case "text":
+		return Text, nil
+	case "json":
+		return JSON, nil
+	}
+	return 0, fmt.Errorf("bad log format %q, must be text or json", s)
+}
+
+// Config keeps the configuration of logging.
+type Config struct {
+	// The minimum level of messages to write.
+	Level Level
+	// The format of messages.
+	Format Format
+	// The maximum size of a log file set by SetOutputFile in bytes. When a
+	// message would make the file grow beyond it, the file is renamed by
+	// appending ".1" to its name, and a new file is started. If 0, log files
+	// are never rotated.
+	MaxSize int64
+	// The number of rotated log files to keep, named with the suffixes ".1",
+	// ".2" and so on, from the newest to the oldest. If 0, rotated files are
+	// deleted.
+	MaxBackups int
+}
+
+// DefaultConfig is the initial configuration.
+var DefaultConfig = Config{Level: Info, Format: Text, MaxSize: 10 << 20, MaxBackups: 1}
+
 var (
-	out = io.Discard
+	// Protects all the variables below.
+	mutex  sync.Mutex
+	config = DefaultConfig
+	out    = io.Discard
 	// If out is set by SetOutputFile, outFile is set and keeps the same value
 	// as out. Otherwise, outFile is nil.
 	outFile *os.File
-	loggers []*log.Logger
+	// The size of outFile.
+	outSize int64
 )
 
-// GetLogger gets a logger with a prefix.
-func GetLogger(prefix string) *log.Logger {
-	logger := log.New(out, prefix, log.LstdFlags)
-	loggers = append(loggers, logger)
-	return logger
+// Can be overridden in tests.
+var timeNow = time.Now
+
+// Logger writes log messages tagged with a name.
+type Logger struct {
+	prefix string
+	name   string
+}
+
+// GetLogger gets a logger with a prefix like "[daemon] ". The name of the
+// logger in the JSON format is the prefix without the brackets and spaces.
+func GetLogger(prefix string) *Logger {
+	return &Logger{prefix, strings.Trim(prefix, "[] ")}
+}
+
+// Debugf writes a message at the Debug level.
+func (l *Logger) Debugf(format string, args ...any) { l.logf(Debug, format, args...) }
+
+// Infof writes a message at the Info level.
+func (l *Logger) Infof(format string, args ...any) { l.logf(Info, format, args...) }
+
+// Warnf writes a message at the Warn level.
+func (l *Logger) Warnf(format string, args ...any) { l.logf(Warn, format, args...) }
+
+// Errorf writes a message at the Error level.
+func (l *Logger) Errorf(format string, args ...any) { l.logf(Error, format, args...) }
+
+func (l *Logger) logf(level Level, format string, args ...any) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if level < config.Level || out == io.Discard {
+		return
+	}
+	t := timeNow()
+	msg := fmt.Sprintf(format, args...)
+	var line []byte
+	if config.Format == JSON {
+		line, _ = json.Marshal(struct {
+			Time   string `json:"time"`
+			Level  string `json:"level"`
+			Logger string `json:"logger"`
+			Msg    string `json:"msg"`
+		}{t.Format(time.RFC3339Nano), level.String(), l.name, msg})
+		line = append(line, '\n')
+	} else {
+		line = []byte(fmt.Sprintf("%s %s %s%s\n", t.Format("2006/01/02 15:04:05"),
+			strings.ToUpper(level.String()), l.prefix, strings.TrimSuffix(msg, "\n")))
+	}
+	if outFile != nil && config.MaxSize > 0 && outSize > 0 &&
+		outSize+int64(len(line)) > config.MaxSize {
+		rotate()
+	}
+	n, _ := out.Write(line)
+	outSize += int64(n)
+}
+
+// Rotates outFile. If anything goes wrong, keeps logging to the current file
+// if possible, or stops logging otherwise.
+func rotate() {
+	name := outFile.Name()
+	outFile.Close()
+	if config.MaxBackups == 0 {
+		os.Remove(name)
+	} else {
+		for i := config.MaxBackups - 1; i >= 1; i-- {
+			os.Rename(backupName(name, i), backupName(name, i+1))
+		}
+		os.Rename(name, backupName(name, 1))
+	}
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		out, outFile = io.Discard, nil
+		return
+	}
+	out, outFile, outSize = file, file, 0
+}
+
+func backupName(name string, i int) string { return name + "." + strconv.Itoa(i) }
+
+// SetConfig changes the configuration of logging.
+func SetConfig(c Config) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	config = c
+}
+
+// GetConfig returns the current configuration of logging.
+func GetConfig() Config {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return config
 }
 
 // SetOutput redirects the output of all loggers obtained with GetLogger to the
 // new io.Writer. If the old output was a file opened by SetOutputFile, it is
 // closed.
 func SetOutput(newout io.Writer) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	setOutput(newout)
+}
+
+func setOutput(newout io.Writer) {
 	if outFile != nil {
 		outFile.Close()
 		outFile = nil
 	}
 	out = newout
-	for _, logger := range loggers {
-		logger.SetOutput(out)
+}
+
+// OutputFile returns the name of the file set by SetOutputFile, or "" if the
+// output is not such a file.
+func OutputFile() string {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if outFile == nil {
+		return ""
 	}
+	return outFile.Name()
 }
 
 // SetOutputFile redirects the output of all loggers obtained with GetLogger to
-// the named file. If the old output was a file opened by SetOutputFile, it is
-// closed. The new file is truncated. SetOutFile("") is equivalent to
-// SetOutput(io.Discard).
+// the named file, appending to it, and rotating it according to the
+// configuration. If the old output was a file opened by SetOutputFile, it is
+// closed. SetOutFile("") is equivalent to SetOutput(io.Discard).
 func SetOutputFile(fname string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
 	if fname == "" {
-		SetOutput(io.Discard)
+		setOutput(io.Discard)
 		return nil
 	}
 	file, err := os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
 	if err != nil {
 		return err
 	}
-	SetOutput(file)
-	outFile = file
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	setOutput(file)
+	outFile, outSize = file, info.Size()
 	return nil
 }
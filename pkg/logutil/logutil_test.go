@@ -1,39 +1,166 @@
 package logutil
 
 import (
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
 	"testing"
+	"time"
 
 	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/testutil"
 )
 
 func TestLogger(t *testing.T) {
-	logger := GetLogger("foo ")
+	logger := GetLogger("[foo] ")
 
 	r, w := must.Pipe()
 	SetOutput(w)
-	logger.Println("out 1")
+	logger.Infof("out %d", 1)
 	w.Close()
-	wantOut1 := must.OK1(regexp.Compile("^foo .*out 1\n$"))
+	wantOut1 := must.OK1(regexp.Compile(`^\S+ \S+ INFO \[foo\] out 1\n$`))
 	if out := must.ReadAllAndClose(r); !wantOut1.Match(out) {
 		t.Errorf("got out %q, want one matching %q", out, wantOut1)
 	}
 
 	outPath := filepath.Join(t.TempDir(), "out")
 	must.OK(SetOutputFile(outPath))
-	logger.Println("out 2")
+	if name := OutputFile(); name != outPath {
+		t.Errorf("OutputFile() -> %q, want %q", name, outPath)
+	}
+	logger.Warnf("out 2")
 	must.OK(SetOutputFile(""))
-	wantOut2 := must.OK1(regexp.Compile("^foo .*out 2\n$"))
+	if name := OutputFile(); name != "" {
+		t.Errorf("OutputFile() -> %q, want empty", name)
+	}
+	wantOut2 := must.OK1(regexp.Compile(`^\S+ \S+ WARN \[foo\] out 2\n$`))
 	if out := must.ReadAllAndClose(must.OK1(os.Open(outPath))); !wantOut2.Match(out) {
 		t.Errorf("got out %q, want one matching %q", out, wantOut2)
 	}
 }
 
+func TestLogger_Level(t *testing.T) {
+	setConfig(t, Config{Level: Warn})
+	logger := GetLogger("[foo] ")
+
+	out := capture(func() {
+		logger.Debugf("debug")
+		logger.Infof("info")
+		logger.Warnf("warn")
+		logger.Errorf("error")
+	})
+	want := "2000/01/02 03:04:05 WARN [foo] warn\n" +
+		"2000/01/02 03:04:05 ERROR [foo] error\n"
+	if out != want {
+		t.Errorf("got out %q, want %q", out, want)
+	}
+}
+
+func TestLogger_JSON(t *testing.T) {
+	setConfig(t, Config{Level: Debug, Format: JSON})
+	logger := GetLogger("[foo] ")
+
+	out := capture(func() { logger.Debugf("a \"quoted\" message") })
+	want := `{"time":"2000-01-02T03:04:05Z","level":"debug","logger":"foo",` +
+		`"msg":"a \"quoted\" message"}` + "\n"
+	if out != want {
+		t.Errorf("got out %q, want %q", out, want)
+	}
+}
+
+func TestLogger_Rotation(t *testing.T) {
+	testutil.InTempDir(t)
+	// Each message is 41 bytes, so each file only fits 2 messages.
+	setConfig(t, Config{Level: Info, MaxSize: 90, MaxBackups: 2})
+	logger := GetLogger("[foo] ")
+
+	must.OK(SetOutputFile("log"))
+	for i := 1; i <= 7; i++ {
+		logger.Infof("message %d", i)
+	}
+	must.OK(SetOutputFile(""))
+
+	wantFiles := map[string]string{
+		"log":   line(7),
+		"log.1": line(5) + line(6),
+		"log.2": line(3) + line(4),
+	}
+	for name, want := range wantFiles {
+		if got := string(must.ReadFile(name)); got != want {
+			t.Errorf("got %s %q, want %q", name, got, want)
+		}
+	}
+	if _, err := os.Stat("log.3"); err == nil {
+		t.Errorf("log.3 exists, want it not to exist")
+	}
+}
+
+func TestLogger_Rotation_NoBackups(t *testing.T) {
+	testutil.InTempDir(t)
+	setConfig(t, Config{Level: Info, MaxSize: 90, MaxBackups: 0})
+	logger := GetLogger("[foo] ")
+
+	must.OK(SetOutputFile("log"))
+	for i := 1; i <= 3; i++ {
+		logger.Infof("message %d", i)
+	}
+	must.OK(SetOutputFile(""))
+
+	if got, want := string(must.ReadFile("log")), line(3); got != want {
+		t.Errorf("got log %q, want %q", got, want)
+	}
+	if _, err := os.Stat("log.1"); err == nil {
+		t.Errorf("log.1 exists, want it not to exist")
+	}
+}
+
+func line(i int) string {
+	return "2000/01/02 03:04:05 INFO [foo] message " + string(rune('0'+i)) + "\n"
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, level := range []Level{Debug, Info, Warn, Error} {
+		if got, err := ParseLevel(level.String()); got != level || err != nil {
+			t.Errorf("ParseLevel(%q) -> (%v, %v), want (%v, nil)", level, got, err, level)
+		}
+	}
+	if _, err := ParseLevel("bad"); err == nil {
+		t.Errorf("ParseLevel(\"bad\") returns nil error, want non-nil")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, format := range []Format{Text, JSON} {
+		if got, err := ParseFormat(format.String()); got != format || err != nil {
+			t.Errorf("ParseFormat(%q) -> (%v, %v), want (%v, nil)", format, got, err, format)
+		}
+	}
+	if _, err := ParseFormat("bad"); err == nil {
+		t.Errorf("ParseFormat(\"bad\") returns nil error, want non-nil")
+	}
+}
+
 func TestSetOutput_Error(t *testing.T) {
 	err := SetOutputFile("/bad/file/path")
 	if err == nil {
 		t.Errorf("want non-nil error, got nil")
 	}
 }
+
+func setConfig(t *testing.T, c Config) {
+	SetConfig(c)
+	t.Cleanup(func() { SetConfig(DefaultConfig) })
+	testutil.Set(t, &timeNow, func() time.Time {
+		return time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC)
+	})
+}
+
+func capture(f func()) string {
+	r, w := must.Pipe()
+	SetOutput(w)
+	f()
+	SetOutput(io.Discard)
+	w.Close()
+	return string(must.ReadAllAndClose(r))
+}
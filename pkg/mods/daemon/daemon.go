@@ -2,10 +2,13 @@
 package daemon
 
 import (
+	_ "embed"
 	"strconv"
+	"time"
 
 	"src.elv.sh/pkg/daemon/daemondefs"
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/eval/vars"
 )
 
@@ -31,6 +34,39 @@ func Ns(d daemondefs.Client) *eval.Ns {
 			"sock": vars.NewReadOnly(string(d.SockPath())),
 		}).
 		AddGoFns(map[string]any{
-			"pid": getPid,
+			"pid":    getPid,
+			"status": func() (vals.Map, error) { return status(d) },
 		}).Ns()
 }
+
+// Can be overridden in tests.
+var timeNow = time.Now
+
+func status(d daemondefs.Client) (vals.Map, error) {
+	st, err := d.Status()
+	if err != nil {
+		return nil, err
+	}
+	calls := vals.EmptyMap
+	for name, c := range st.Calls {
+		calls = calls.Assoc(name, vals.MakeMap(
+			"count", c.Count,
+			"total", c.Total.Seconds(),
+			"max", c.Max.Seconds(),
+			"last", c.Last.Seconds()))
+	}
+	return vals.MakeMap(
+		"pid", st.Pid,
+		"version", st.Version,
+		"uptime", timeNow().Sub(st.StartTime).Seconds(),
+		"clients", st.Clients,
+		"db-path", st.DBPath,
+		"db-size", int(st.DBSize),
+		"db-error", st.DBError,
+		"calls", calls), nil
+}
+
+// DElvCode contains the content of the .d.elv file for this module.
+//
+//go:embed *.d.elv
+var DElvCode string
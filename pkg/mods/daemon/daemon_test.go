@@ -1,7 +1,56 @@
 package daemon
 
-import "testing"
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/daemon/daemondefs"
+	"src.elv.sh/pkg/eval"
+	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/testutil"
+)
+
+type fakeClient struct {
+	daemondefs.Client
+	status daemondefs.Status
+	err    error
+}
+
+func (c fakeClient) Pid() (int, error)                  { return c.status.Pid, c.err }
+func (c fakeClient) SockPath() string                   { return "/tmp/sock" }
+func (c fakeClient) Status() (daemondefs.Status, error) { return c.status, c.err }
 
 func TestDaemon(t *testing.T) {
-	// TODO
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	testutil.Set(t, &timeNow, func() time.Time { return now })
+	status := daemondefs.Status{
+		Pid: 42, Version: -92, StartTime: now.Add(-time.Minute), Clients: 2,
+		DBPath: "/tmp/db", DBSize: 4096,
+		Calls: map[string]daemondefs.CallStats{
+			"AddCmd": {Count: 2, Total: 3 * time.Second, Max: 2 * time.Second,
+				Last: time.Second},
+		},
+	}
+	setup := func(c fakeClient) func(*eval.Evaler) {
+		return func(ev *eval.Evaler) {
+			ev.ExtendGlobal(eval.BuildNs().AddNs("daemon", Ns(c)))
+		}
+	}
+
+	TestWithSetup(t, setup(fakeClient{status: status}),
+		That("put $daemon:pid (daemon:pid) $daemon:sock").Puts("42", "42", "/tmp/sock"),
+		That("daemon:status").Puts(vals.MakeMap(
+			"pid", 42, "version", -92, "uptime", 60.0, "clients", 2,
+			"db-path", "/tmp/db", "db-size", 4096, "db-error", "",
+			"calls", vals.MakeMap("AddCmd", vals.MakeMap(
+				"count", 2, "total", 3.0, "max", 2.0, "last", 1.0)))),
+	)
+
+	errDaemon := errors.New("daemon offline")
+	TestWithSetup(t, setup(fakeClient{err: errDaemon}),
+		That("put $daemon:pid").Puts("-1"),
+		That("daemon:status").Throws(errDaemon),
+	)
 }
@@ -13,15 +13,19 @@ import (
 	"src.elv.sh/pkg/elvdoc"
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/md"
+	"src.elv.sh/pkg/mods/daemon"
 	"src.elv.sh/pkg/mods/epm"
 	"src.elv.sh/pkg/mods/file"
 	"src.elv.sh/pkg/mods/flag"
+	"src.elv.sh/pkg/mods/keyring"
 	"src.elv.sh/pkg/mods/math"
+	"src.elv.sh/pkg/mods/osutil"
 	"src.elv.sh/pkg/mods/path"
 	"src.elv.sh/pkg/mods/platform"
 	"src.elv.sh/pkg/mods/re"
 	"src.elv.sh/pkg/mods/readlinebinding"
 	"src.elv.sh/pkg/mods/runtime"
+	"src.elv.sh/pkg/mods/sh"
 	"src.elv.sh/pkg/mods/store"
 	"src.elv.sh/pkg/mods/str"
 	"src.elv.sh/pkg/mods/unix"
@@ -155,17 +159,21 @@ func symbols(fm *eval.Frame) error {
 
 var modToCode = map[string]io.Reader{
 	"":                 readAll(eval.BuiltinDElvFiles),
+	"daemon:":          read(daemon.DElvCode),
 	"doc:":             read(DElvCode),
 	"edit:":            readAll(edit.DElvFiles),
 	"epm:":             read(epm.Code),
 	"file:":            read(file.DElvCode),
 	"flag:":            read(flag.DElvCode),
+	"keyring:":         read(keyring.DElvCode),
 	"math:":            read(math.DElvCode),
+	"osutil:":          read(osutil.DElvCode),
 	"path:":            read(path.DElvCode),
 	"platform:":        read(platform.DElvCode),
 	"re:":              read(re.DElvCode),
 	"readlinebinding:": read(readlinebinding.Code),
 	"runtime:":         read(runtime.DElvCode),
+	"sh:":              read(sh.DElvCode),
 	"store:":           read(store.DElvCode),
 	"str:":             read(str.DElvCode),
 	"unix:":            readAll(unix.DElvFiles),
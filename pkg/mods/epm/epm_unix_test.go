@@ -0,0 +1,109 @@
+//go:build !windows && !plan9
+
+package epm_test
+
+import (
+	"testing"
+
+	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/mods"
+	"src.elv.sh/pkg/testutil"
+)
+
+// Sets up epm to manage ./lib, where packages in the example.com domain are
+// installed and upgraded with a fake method. Installing example.com/bad and
+// upgrading example.com/bad-upgrade fail.
+const epmSetup = `
+use epm
+set epm:managed-dir = (pwd)/lib
+set epm:-method-handler[fake] = [
+  &src= {|pkg cfg| put fake }
+  &install= {|pkg cfg|
+    if (eq $pkg example.com/bad) {
+      echo 'bad output'
+      fail bad
+    }
+    mkdir -p (epm:dest $pkg)
+  }
+  &upgrade= {|pkg cfg|
+    if (eq $pkg example.com/bad-upgrade) {
+      fail bad-upgrade
+    }
+  }
+]
+`
+
+func infoLine(s string) string  { return "\033[;32m=> \033[m" + s + "\n" }
+func errorLine(s string) string { return "\033[;31m=> \033[m" + s + "\n" }
+
+func TestInstallAndUpgrade(t *testing.T) {
+	testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{
+		"lib": testutil.Dir{
+			"example.com": testutil.Dir{
+				"epm-domain.cfg": `{"method": "fake", "levels": "1"}`,
+			},
+		},
+	})
+
+	TestWithSetup(t, mods.AddTo,
+		// The order of progress messages is only deterministic when packages
+		// are installed one at a time.
+		That(epmSetup+`
+			set epm:parallelism = 1
+			epm:install example.com/a example.com/bad example.com/b
+			`).
+			Prints(infoLine("[1/3] Installed example.com/a")+
+				errorLine("[2/3] Failed: example.com/bad")+
+				"bad output\n"+
+				"Exception: \033[31;1mbad\033[m\n"+
+				infoLine("[3/3] Installed example.com/b")).
+			Throws(ErrorWithMessage(
+				"1 of 3 packages failed to install: example.com/bad")),
+		That(epmSetup+"put [(epm:list)]").Puts(vals.MakeList("example.com/a", "example.com/b")),
+
+		That(epmSetup+"epm:install example.com/a").
+			Prints(infoLine("Package example.com/a is already installed.")),
+		That(epmSetup+"epm:install &silent-if-installed example.com/a").
+			DoesNothing(),
+
+		That(epmSetup+`
+			set epm:parallelism = 1
+			epm:upgrade example.com/a example.com/c
+			`).
+			Prints(errorLine("Package example.com/c is not installed.")+
+				infoLine("[1/1] Updated example.com/a")),
+	)
+}
+
+func TestInstall_Concurrent(t *testing.T) {
+	testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{
+		"lib": testutil.Dir{
+			"example.com": testutil.Dir{
+				"epm-domain.cfg": `{"method": "fake", "levels": "1"}`,
+			},
+		},
+	})
+
+	// Installing each package waits for the installation of the other one to
+	// start, which only succeeds when they are installed concurrently.
+	TestWithSetup(t, mods.AddTo,
+		That(epmSetup+`
+			set epm:-method-handler[fake][install] = {|pkg cfg|
+				mkdir -p (epm:dest $pkg)
+				for _ [(range 500)] {
+					if (and ?(test -d lib/example.com/a) ?(test -d lib/example.com/b)) {
+						return
+					}
+					sleep 0.01
+				}
+				fail timeout
+			}
+			epm:install example.com/a example.com/b | only-values
+			put [(epm:list)]
+			`).
+			Puts(vals.MakeList("example.com/a", "example.com/b")),
+	)
+}
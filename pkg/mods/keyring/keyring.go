@@ -0,0 +1,88 @@
+// Package keyring exposes functions for storing secrets in the keychain of the
+// operating system.
+package keyring
+
+import (
+	_ "embed"
+	"errors"
+	"os/exec"
+	"strings"
+
+	"src.elv.sh/pkg/eval"
+)
+
+// Ns is the namespace for the keyring: module.
+var Ns = eval.BuildNsNamed("keyring").
+	AddGoFns(map[string]any{
+		"get":    get,
+		"set":    set,
+		"delete": del,
+	}).Ns()
+
+// DElvCode contains the content of the .d.elv file for this module.
+//
+//go:embed *.d.elv
+var DElvCode string
+
+// Operations on the keychain. Implemented differently on each platform, and
+// can be mutated in tests.
+type backend interface {
+	// Returns errNotFound if there is no secret for the service and account.
+	get(service, account string) (string, error)
+	set(service, account, secret string) error
+	// Returns nil if there is no secret for the service and account.
+	delete(service, account string) error
+}
+
+var keychain backend = platformBackend{}
+
+var errNotFound = errors.New("secret not found in keyring")
+
+func get(service, account string) (string, error) {
+	return keychain.get(service, account)
+}
+
+func set(service, account, secret string) error {
+	return keychain.set(service, account, secret)
+}
+
+func del(service, account string) error {
+	return keychain.delete(service, account)
+}
+
+// Runs an external command with the given standard input, and returns its
+// standard output. If the command fails, the error includes its standard error
+// output. Can be mutated in tests.
+var runCmd = func(stdin string, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return out, &cmdError{err, msg}
+		}
+	}
+	return out, err
+}
+
+// An error from running a command, with its standard error output.
+type cmdError struct {
+	err    error
+	stderr string
+}
+
+func (e *cmdError) Error() string { return e.err.Error() + ": " + e.stderr }
+
+func (e *cmdError) Unwrap() error { return e.err }
+
+// Returns the exit status of the command that resulted in err, or -1 if err
+// isn't from a command that exited.
+func exitStatus(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
@@ -0,0 +1,50 @@
+package keyring
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Stores secrets as generic passwords in the login keychain, using the
+// security command.
+type platformBackend struct{}
+
+// Exit status of security when the item is not found.
+const securityNotFound = 44
+
+func (platformBackend) get(service, account string) (string, error) {
+	out, err := runCmd("", "security", "find-generic-password",
+		"-s", service, "-a", account, "-w")
+	if exitStatus(err) == securityNotFound {
+		return "", errNotFound
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (platformBackend) set(service, account, secret string) error {
+	// Pass the command on stdin instead of as arguments, so that the secret
+	// doesn't show up in the process list. Encoding it in hex avoids the need
+	// to quote it.
+	_, err := runCmd(fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n",
+		securityQuote(service), securityQuote(account),
+		hex.EncodeToString([]byte(secret))), "security", "-i")
+	return err
+}
+
+func (platformBackend) delete(service, account string) error {
+	_, err := runCmd("", "security", "delete-generic-password",
+		"-s", service, "-a", account)
+	if exitStatus(err) == securityNotFound {
+		return nil
+	}
+	return err
+}
+
+// Quotes a string for the interactive mode of security, which splits commands
+// into words like a POSIX shell.
+func securityQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,21 @@
+//go:build plan9 || js
+
+package keyring
+
+import "errors"
+
+type platformBackend struct{}
+
+var errNotSupported = errors.New("keyring not supported on this platform")
+
+func (platformBackend) get(string, string) (string, error) {
+	return "", errNotSupported
+}
+
+func (platformBackend) set(string, string, string) error {
+	return errNotSupported
+}
+
+func (platformBackend) delete(string, string) error {
+	return errNotSupported
+}
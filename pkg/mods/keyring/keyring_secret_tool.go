@@ -0,0 +1,42 @@
+//go:build !windows && !plan9 && !js && !darwin
+
+package keyring
+
+// Stores secrets in the Secret Service (like GNOME Keyring or KWallet), using
+// the secret-tool command from libsecret.
+type platformBackend struct{}
+
+func (platformBackend) get(service, account string) (string, error) {
+	out, err := runCmd("", "secret-tool", "lookup",
+		"service", service, "account", account)
+	if isNotFound(err) {
+		return "", errNotFound
+	} else if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func (platformBackend) set(service, account, secret string) error {
+	// secret-tool reads the secret from stdin, so that it doesn't show up in
+	// the process list.
+	_, err := runCmd(secret, "secret-tool", "store",
+		"--label", service+" ("+account+")",
+		"service", service, "account", account)
+	return err
+}
+
+func (platformBackend) delete(service, account string) error {
+	_, err := runCmd("", "secret-tool", "clear",
+		"service", service, "account", account)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// secret-tool exits with 1 without any output when the secret is not found.
+func isNotFound(err error) bool {
+	_, hasStderr := err.(*cmdError)
+	return !hasStderr && exitStatus(err) == 1
+}
@@ -0,0 +1,74 @@
+//go:build !windows && !plan9 && !js && !darwin
+
+package keyring
+
+import (
+	"errors"
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"src.elv.sh/pkg/testutil"
+)
+
+type cmdCall struct {
+	stdin string
+	args  []string
+}
+
+// Replaces runCmd with a function that records the calls and returns the
+// given output and error.
+func fakeCmd(t *testing.T, out string, err error) *[]cmdCall {
+	var calls []cmdCall
+	testutil.Set(t, &runCmd, func(stdin, name string, args ...string) ([]byte, error) {
+		calls = append(calls, cmdCall{stdin, append([]string{name}, args...)})
+		return []byte(out), err
+	})
+	return &calls
+}
+
+func TestSecretTool(t *testing.T) {
+	calls := fakeCmd(t, "secret", nil)
+	b := platformBackend{}
+
+	secret, err := b.get("svc", "elf")
+	if secret != "secret" || err != nil {
+		t.Errorf("get returned (%q, %v), want (%q, nil)", secret, err, "secret")
+	}
+	b.set("svc", "elf", "new-secret")
+	b.delete("svc", "elf")
+
+	wantCalls := []cmdCall{
+		{"", []string{"secret-tool", "lookup", "service", "svc", "account", "elf"}},
+		{"new-secret", []string{"secret-tool", "store", "--label", "svc (elf)",
+			"service", "svc", "account", "elf"}},
+		{"", []string{"secret-tool", "clear", "service", "svc", "account", "elf"}},
+	}
+	if !reflect.DeepEqual(*calls, wantCalls) {
+		t.Errorf("got calls %v, want %v", *calls, wantCalls)
+	}
+}
+
+func TestSecretTool_NotFound(t *testing.T) {
+	// Get a real *exec.ExitError with exit status 1.
+	exitErr := exec.Command("sh", "-c", "exit 1").Run()
+	fakeCmd(t, "", exitErr)
+	b := platformBackend{}
+
+	if _, err := b.get("svc", "elf"); err != errNotFound {
+		t.Errorf("get returned error %v, want errNotFound", err)
+	}
+	if err := b.delete("svc", "elf"); err != nil {
+		t.Errorf("delete returned error %v, want nil", err)
+	}
+}
+
+func TestSecretTool_Error(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 1").Run()
+	fakeCmd(t, "", &cmdError{exitErr, "no Secret Service"})
+	b := platformBackend{}
+
+	if _, err := b.get("svc", "elf"); !errors.Is(err, exitErr) {
+		t.Errorf("get returned error %v, want one wrapping %v", err, exitErr)
+	}
+}
@@ -0,0 +1,50 @@
+package keyring
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/eval"
+	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/testutil"
+)
+
+// A backend that stores secrets in a map.
+type fakeBackend map[[2]string]string
+
+func (b fakeBackend) get(service, account string) (string, error) {
+	secret, ok := b[[2]string{service, account}]
+	if !ok {
+		return "", errNotFound
+	}
+	return secret, nil
+}
+
+func (b fakeBackend) set(service, account, secret string) error {
+	b[[2]string{service, account}] = secret
+	return nil
+}
+
+func (b fakeBackend) delete(service, account string) error {
+	delete(b, [2]string{service, account})
+	return nil
+}
+
+func TestKeyring(t *testing.T) {
+	testutil.Set[backend](t, &keychain, fakeBackend{})
+
+	TestWithSetup(t, setup,
+		That("keyring:get svc elf").Throws(errNotFound),
+
+		That("keyring:set svc elf secret; keyring:get svc elf").Puts("secret"),
+		That("keyring:set svc elf new-secret; keyring:get svc elf").Puts("new-secret"),
+		That("keyring:get svc other").Throws(errNotFound),
+		That("keyring:get other elf").Throws(errNotFound),
+
+		That("keyring:delete svc elf; keyring:get svc elf").Throws(errNotFound),
+		That("keyring:delete svc elf").DoesNothing(),
+	)
+}
+
+func setup(ev *eval.Evaler) {
+	ev.ExtendGlobal(eval.BuildNs().AddNs("keyring", Ns))
+}
@@ -0,0 +1,36 @@
+package keyring
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+	"src.elv.sh/pkg/sys/ewindows"
+)
+
+// Stores secrets as generic credentials in the Windows Credential Manager.
+type platformBackend struct{}
+
+// Returns the target name of the credential for the service and account.
+func target(service, account string) string { return service + ":" + account }
+
+func (platformBackend) get(service, account string) (string, error) {
+	blob, err := ewindows.CredRead(target(service, account))
+	if errors.Is(err, windows.ERROR_NOT_FOUND) {
+		return "", errNotFound
+	} else if err != nil {
+		return "", err
+	}
+	return string(blob), nil
+}
+
+func (platformBackend) set(service, account, secret string) error {
+	return ewindows.CredWrite(target(service, account), account, []byte(secret))
+}
+
+func (platformBackend) delete(service, account string) error {
+	err := ewindows.CredDelete(target(service, account))
+	if errors.Is(err, windows.ERROR_NOT_FOUND) {
+		return nil
+	}
+	return err
+}
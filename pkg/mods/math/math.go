@@ -6,6 +6,7 @@ import (
 	_ "embed"
 	"math"
 	"math/big"
+	"strconv"
 
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/eval/errs"
@@ -28,15 +29,22 @@ var Ns = eval.BuildNsNamed("math").
 		"atan":          math.Atan,
 		"atanh":         math.Atanh,
 		"ceil":          ceil,
+		"clamp":         clamp,
 		"cos":           math.Cos,
 		"cosh":          math.Cosh,
 		"floor":         floor,
+		"from-base":     fromBase,
+		"gcd":           gcd,
 		"is-inf":        isInf,
 		"is-nan":        isNaN,
+		"isqrt":         isqrt,
+		"lcm":           lcm,
 		"log":           math.Log,
 		"log10":         math.Log10,
 		"log2":          math.Log2,
 		"max":           max,
+		"mean":          mean,
+		"median":        median,
 		"min":           min,
 		"pow":           pow,
 		"round":         round,
@@ -44,6 +52,8 @@ var Ns = eval.BuildNsNamed("math").
 		"sin":           math.Sin,
 		"sinh":          math.Sinh,
 		"sqrt":          math.Sqrt,
+		"stddev":        stddev,
+		"sum":           sum,
 		"tan":           math.Tan,
 		"tanh":          math.Tanh,
 		"trunc":         trunc,
@@ -102,6 +112,21 @@ func ceil(n vals.Num) vals.Num {
 		})
 }
 
+func clamp(n, lower, upper vals.Num) (vals.Num, error) {
+	if cmpNum(lower, upper) > 0 {
+		return nil, errs.BadValue{What: "bounds to math:clamp",
+			Valid:  "lower bound <= upper bound",
+			Actual: vals.ReprPlain(lower) + " > " + vals.ReprPlain(upper)}
+	}
+	if cmpNum(n, lower) < 0 {
+		return lower, nil
+	}
+	if cmpNum(n, upper) > 0 {
+		return upper, nil
+	}
+	return n, nil
+}
+
 func floor(n vals.Num) vals.Num {
 	return integerize(n,
 		math.Floor,
@@ -110,6 +135,71 @@ func floor(n vals.Num) vals.Num {
 		})
 }
 
+func fromBase(fm *eval.Frame, b int, strs ...string) error {
+	if b < 2 || b > 36 {
+		return eval.ErrBadBase
+	}
+	out := fm.ValueOutput()
+	for _, s := range strs {
+		z, ok := new(big.Int).SetString(s, b)
+		if !ok {
+			return errs.BadValue{What: "argument to math:from-base",
+				Valid: "integer in base " + strconv.Itoa(b), Actual: vals.ReprPlain(s)}
+		}
+		err := out.Put(vals.NormalizeBigInt(z))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func gcd(rawNums ...vals.Num) (vals.Num, error) {
+	nums, err := exactInts("math:gcd", rawNums)
+	if err != nil {
+		return nil, err
+	}
+	z := new(big.Int)
+	for _, n := range nums {
+		z.GCD(nil, nil, z, new(big.Int).Abs(n))
+	}
+	return vals.NormalizeBigInt(z), nil
+}
+
+func lcm(rawNums ...vals.Num) (vals.Num, error) {
+	nums, err := exactInts("math:lcm", rawNums)
+	if err != nil {
+		return nil, err
+	}
+	z := big.NewInt(1)
+	for _, n := range nums {
+		if n.Sign() == 0 {
+			return 0, nil
+		}
+		n = new(big.Int).Abs(n)
+		d := new(big.Int).GCD(nil, nil, z, n)
+		z.Mul(z, new(big.Int).Quo(n, d))
+	}
+	return vals.NormalizeBigInt(z), nil
+}
+
+// Converts numbers that must be exact integers to *big.Int. There must be at
+// least one number.
+func exactInts(fnName string, rawNums []vals.Num) ([]*big.Int, error) {
+	if len(rawNums) == 0 {
+		return nil, errs.ArityMismatch{What: "arguments", ValidLow: 1, ValidHigh: -1, Actual: 0}
+	}
+	nums := make([]*big.Int, len(rawNums))
+	for i, n := range rawNums {
+		if !isExactInt(n) {
+			return nil, errs.BadValue{What: "argument to " + fnName,
+				Valid: "exact integer", Actual: vals.ReprPlain(n)}
+		}
+		nums[i] = vals.PromoteToBigInt(n)
+	}
+	return nums, nil
+}
+
 type isInfOpts struct{ Sign int }
 
 func (opts *isInfOpts) SetDefaultOptions() { opts.Sign = 0 }
@@ -128,6 +218,14 @@ func isNaN(n vals.Num) bool {
 	return false
 }
 
+func isqrt(n vals.Num) (vals.Num, error) {
+	if !isExactInt(n) || vals.PromoteToBigInt(n).Sign() < 0 {
+		return nil, errs.BadValue{What: "argument to math:isqrt",
+			Valid: "non-negative exact integer", Actual: vals.ReprPlain(n)}
+	}
+	return vals.NormalizeBigInt(new(big.Int).Sqrt(vals.PromoteToBigInt(n))), nil
+}
+
 func max(rawNums ...vals.Num) (vals.Num, error) {
 	if len(rawNums) == 0 {
 		return nil, errs.ArityMismatch{What: "arguments", ValidLow: 1, ValidHigh: -1, Actual: 0}
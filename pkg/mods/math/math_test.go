@@ -4,6 +4,7 @@ import (
 	"math"
 	"math/big"
 	"strconv"
+	"strings"
 	"testing"
 
 	"src.elv.sh/pkg/eval"
@@ -241,6 +242,105 @@ func TestMath(t *testing.T) {
 	)
 }
 
+func TestMath_StatsAndIntegers(t *testing.T) {
+	setup := func(ev *eval.Evaler) {
+		ev.ExtendGlobal(eval.BuildNs().AddNs("math", Ns))
+	}
+	TestWithSetup(t, setup,
+		That("math:sum [1 2 3]").Puts(6),
+		That("range 1 101 | math:sum").Puts(5050),
+		That("math:sum []").Puts(0),
+		That("math:sum ["+z+" "+z+"]").Puts(bigInt("2"+zeros+"0")),
+		That("math:sum ["+strconv.Itoa(maxInt)+" 1]").
+			Puts(new(big.Int).Add(big.NewInt(int64(maxInt)), big1)),
+		That("math:sum [1/2 1/3 1/6]").Puts(1),
+		That("math:sum [1 2.5]").Puts(3.5),
+		That("math:sum [(num 1) 2 '3']").Puts(6),
+		That("math:sum [1 foo]").Throws(
+			errs.BadValue{What: "input", Valid: "number", Actual: "foo"},
+			"math:sum [1 foo]"),
+
+		That("math:mean [1 2 3 4]").Puts(big.NewRat(5, 2)),
+		That("math:mean [2 4]").Puts(3),
+		That("math:mean [1.0 2]").Puts(1.5),
+		That("math:mean []").Throws(
+			errs.ArityMismatch{What: "inputs", ValidLow: 1, ValidHigh: -1, Actual: 0},
+			"math:mean []"),
+
+		That("math:median [3 1 2]").Puts(2),
+		That("math:median [4 1 3 2]").Puts(big.NewRat(5, 2)),
+		That("math:median [1 3 2 "+z+"]").Puts(big.NewRat(5, 2)),
+		That("math:median [1 3.0 2]").Puts(2.0),
+		That("math:median [1 NaN 2]").Puts(math.NaN()),
+		That("math:median []").Throws(
+			errs.ArityMismatch{What: "inputs", ValidLow: 1, ValidHigh: -1, Actual: 0},
+			"math:median []"),
+
+		That("math:stddev [2 4 4 4 5 5 7 9]").Puts(2.0),
+		That("math:stddev &sample [2 4 4 4 5 5 7 9]").Puts(math.Sqrt(32.0/7)),
+		That("math:stddev [5]").Puts(0.0),
+		That("math:stddev &sample [5]").Throws(
+			errs.ArityMismatch{What: "inputs", ValidLow: 2, ValidHigh: -1, Actual: 1},
+			"math:stddev &sample [5]"),
+
+		That("math:gcd 12 18").Puts(6),
+		That("math:gcd -12 18 27").Puts(3),
+		That("math:gcd 0 0").Puts(0),
+		That("math:gcd "+z+" 5").Puts(5),
+		That("math:gcd").Throws(
+			errs.ArityMismatch{What: "arguments", ValidLow: 1, ValidHigh: -1, Actual: 0},
+			"math:gcd"),
+		That("math:gcd 1.5 3").Throws(
+			errs.BadValue{What: "argument to math:gcd",
+				Valid: "exact integer", Actual: "(num 1.5)"},
+			"math:gcd 1.5 3"),
+
+		That("math:lcm 4 6").Puts(12),
+		That("math:lcm -4 6 10").Puts(60),
+		That("math:lcm 4 0").Puts(0),
+		That("math:lcm "+z+" 3").Puts(bigInt("3"+zeros+"0")),
+		That("math:lcm 1/2").Throws(
+			errs.BadValue{What: "argument to math:lcm",
+				Valid: "exact integer", Actual: "(num 1/2)"},
+			"math:lcm 1/2"),
+
+		That("math:isqrt 0").Puts(0),
+		That("math:isqrt 15").Puts(3),
+		That("math:isqrt 16").Puts(4),
+		That("math:isqrt 1"+zeros+zeros+"00").Puts(bigInt(z)),
+		That("math:isqrt -1").Throws(
+			errs.BadValue{What: "argument to math:isqrt",
+				Valid: "non-negative exact integer", Actual: "(num -1)"},
+			"math:isqrt -1"),
+		That("math:isqrt 4.0").Throws(
+			errs.BadValue{What: "argument to math:isqrt",
+				Valid: "non-negative exact integer", Actual: "(num 4.0)"},
+			"math:isqrt 4.0"),
+
+		That("math:clamp 5 1 10").Puts(5),
+		That("math:clamp -5 1 10").Puts(1),
+		That("math:clamp 15 1 10").Puts(10),
+		That("math:clamp 1/2 0 1").Puts(big.NewRat(1, 2)),
+		That("math:clamp 1.5 1 2").Puts(1.5),
+		That("math:clamp 5 10 1").Throws(
+			errs.BadValue{What: "bounds to math:clamp",
+				Valid: "lower bound <= upper bound", Actual: "(num 10) > (num 1)"},
+			"math:clamp 5 10 1"),
+
+		That("math:from-base 16 ff -10").Puts(255, -16),
+		That("math:from-base 2 101").Puts(5),
+		That("math:from-base 36 zz").Puts(1295),
+		That("math:from-base 16 "+strings.Repeat("f", 20)).
+			Puts(bigInt("0x"+strings.Repeat("f", 20))),
+		That("math:from-base 1 1").Throws(eval.ErrBadBase, "math:from-base 1 1"),
+		That("math:from-base 37 1").Throws(eval.ErrBadBase, "math:from-base 37 1"),
+		That("math:from-base 2 12").Throws(
+			errs.BadValue{What: "argument to math:from-base",
+				Valid: "integer in base 2", Actual: "12"},
+			"math:from-base 2 12"),
+	)
+}
+
 func bigInt(s string) *big.Int {
 	z, ok := new(big.Int).SetString(s, 0)
 	if !ok {
@@ -0,0 +1,178 @@
+package math
+
+import (
+	"math"
+	"math/big"
+	"sort"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+)
+
+func sum(inputs eval.Inputs) (vals.Num, error) {
+	nums, err := readNums(inputs)
+	if err != nil {
+		return nil, err
+	}
+	return sumNums(nums), nil
+}
+
+func mean(inputs eval.Inputs) (vals.Num, error) {
+	nums, err := readNonEmptyNums(inputs, 1)
+	if err != nil {
+		return nil, err
+	}
+	return divNum(sumNums(nums), len(nums)), nil
+}
+
+func median(inputs eval.Inputs) (vals.Num, error) {
+	nums, err := readNonEmptyNums(inputs, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nums {
+		if f, ok := n.(float64); ok && math.IsNaN(f) {
+			return f, nil
+		}
+	}
+	sort.Slice(nums, func(i, j int) bool { return cmpNum(nums[i], nums[j]) < 0 })
+	mid := len(nums) / 2
+	if len(nums)%2 == 1 {
+		return nums[mid], nil
+	}
+	return divNum(addNum(nums[mid-1], nums[mid]), 2), nil
+}
+
+type stddevOpts struct{ Sample bool }
+
+func (*stddevOpts) SetDefaultOptions() {}
+
+func stddev(opts stddevOpts, inputs eval.Inputs) (float64, error) {
+	minInputs := 1
+	if opts.Sample {
+		minInputs = 2
+	}
+	nums, err := readNonEmptyNums(inputs, minInputs)
+	if err != nil {
+		return 0, err
+	}
+	m := vals.ConvertToFloat64(divNum(sumNums(nums), len(nums)))
+	var sq float64
+	for _, n := range nums {
+		d := vals.ConvertToFloat64(n) - m
+		sq += d * d
+	}
+	if opts.Sample {
+		return math.Sqrt(sq / float64(len(nums)-1)), nil
+	}
+	return math.Sqrt(sq / float64(len(nums))), nil
+}
+
+// Reads numbers from inputs. If any of them is inexact, all of them are
+// converted to float64, so that the result of computing on them is inexact.
+func readNums(inputs eval.Inputs) ([]vals.Num, error) {
+	var nums []vals.Num
+	var err error
+	hasFloat := false
+	inputs(func(v any) {
+		if err != nil {
+			return
+		}
+		var n vals.Num
+		if vals.ScanToGo(v, &n) != nil {
+			err = errs.BadValue{What: "input", Valid: "number", Actual: vals.ReprPlain(v)}
+			return
+		}
+		if _, ok := n.(float64); ok {
+			hasFloat = true
+		}
+		nums = append(nums, n)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if hasFloat {
+		for i, n := range nums {
+			nums[i] = vals.ConvertToFloat64(n)
+		}
+	}
+	return nums, nil
+}
+
+// Like readNums, but requires at least min numbers.
+func readNonEmptyNums(inputs eval.Inputs, min int) ([]vals.Num, error) {
+	nums, err := readNums(inputs)
+	if err != nil {
+		return nil, err
+	}
+	if len(nums) < min {
+		return nil, errs.ArityMismatch{What: "inputs", ValidLow: min, ValidHigh: -1, Actual: len(nums)}
+	}
+	return nums, nil
+}
+
+func sumNums(nums []vals.Num) vals.Num {
+	var s vals.Num = 0
+	for _, n := range nums {
+		s = addNum(s, n)
+	}
+	return s
+}
+
+func addNum(a, b vals.Num) vals.Num {
+	a, b = vals.UnifyNums2(a, b, 0)
+	switch a := a.(type) {
+	case int:
+		b := b.(int)
+		if s := a + b; (s > a) == (b > 0) {
+			return s
+		}
+		return vals.NormalizeBigInt(
+			new(big.Int).Add(big.NewInt(int64(a)), big.NewInt(int64(b))))
+	case *big.Int:
+		return vals.NormalizeBigInt(new(big.Int).Add(a, b.(*big.Int)))
+	case *big.Rat:
+		return vals.NormalizeBigRat(new(big.Rat).Add(a, b.(*big.Rat)))
+	case float64:
+		return a + b.(float64)
+	default:
+		panic("unreachable")
+	}
+}
+
+func divNum(a vals.Num, n int) vals.Num {
+	if f, ok := a.(float64); ok {
+		return f / float64(n)
+	}
+	return vals.NormalizeBigRat(
+		new(big.Rat).Quo(vals.PromoteToBigRat(a), big.NewRat(int64(n), 1)))
+}
+
+func cmpNum(a, b vals.Num) int {
+	a, b = vals.UnifyNums2(a, b, 0)
+	switch a := a.(type) {
+	case int:
+		b := b.(int)
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+		return 0
+	case *big.Int:
+		return a.Cmp(b.(*big.Int))
+	case *big.Rat:
+		return a.Cmp(b.(*big.Rat))
+	case float64:
+		b := b.(float64)
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+		return 0
+	default:
+		panic("unreachable")
+	}
+}
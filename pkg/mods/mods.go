@@ -2,38 +2,99 @@
 package mods
 
 import (
+	"fmt"
+	"sync"
+
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/mods/doc"
 	"src.elv.sh/pkg/mods/epm"
 	"src.elv.sh/pkg/mods/file"
 	"src.elv.sh/pkg/mods/flag"
+	"src.elv.sh/pkg/mods/keyring"
 	"src.elv.sh/pkg/mods/math"
+	"src.elv.sh/pkg/mods/osutil"
 	"src.elv.sh/pkg/mods/path"
 	"src.elv.sh/pkg/mods/platform"
 	"src.elv.sh/pkg/mods/re"
 	"src.elv.sh/pkg/mods/readlinebinding"
 	"src.elv.sh/pkg/mods/runtime"
+	"src.elv.sh/pkg/mods/sh"
 	"src.elv.sh/pkg/mods/str"
 	"src.elv.sh/pkg/mods/unix"
 )
 
 // AddTo adds all standard library modules to the Evaler.
 //
-// All the public properties of the Evaler should be set before this function is
-// called.
+// The runtime: module and registered modules are only built when they are
+// first imported. All the public properties of the Evaler should be set before
+// this function is called.
 func AddTo(ev *eval.Evaler) {
-	ev.AddModule("runtime", runtime.Ns(ev))
+	ev.AddLazyModule("runtime", func() *eval.Ns { return runtime.Ns(ev) })
 	ev.AddModule("math", math.Ns)
+	ev.AddModule("osutil", osutil.Ns)
 	ev.AddModule("path", path.Ns)
 	ev.AddModule("platform", platform.Ns)
 	ev.AddModule("re", re.Ns)
+	ev.AddModule("sh", sh.Ns)
 	ev.AddModule("str", str.Ns)
 	ev.AddModule("file", file.Ns)
 	ev.AddModule("flag", flag.Ns)
+	ev.AddModule("keyring", keyring.Ns)
 	ev.AddModule("doc", doc.Ns)
 	if unix.ExposeUnixNs {
 		ev.AddModule("unix", unix.Ns)
 	}
 	ev.BundledModules["epm"] = epm.Code
 	ev.BundledModules["readline-binding"] = readlinebinding.Code
+
+	registeredMutex.RLock()
+	defer registeredMutex.RUnlock()
+	for name, ns := range registered {
+		ns := ns
+		ev.AddLazyModule(name, func() *eval.Ns { return ns(ev) })
+	}
+}
+
+var (
+	registeredMutex sync.RWMutex
+	registered      = map[string]func(*eval.Evaler) *eval.Ns{}
+)
+
+// Register registers a module written in Go, so that it gets added by AddTo
+// and can be imported with "use $name" from Elvish code. The function is
+// called with the Evaler as argument when an Evaler imports the module for the
+// first time.
+//
+// This is intended for custom builds of Elvish that include additional
+// modules, for example in-house API clients. The package implementing the
+// module typically calls Register in an init function, and the main package of
+// the custom build imports it:
+//
+//	package mymod
+//
+//	func init() {
+//		mods.Register("mymod", func(ev *eval.Evaler) *eval.Ns {
+//			return eval.BuildNsNamed("mymod").
+//				AddGoFns(map[string]any{"hello": hello}).Ns()
+//		})
+//	}
+//
+// Register panics if a module with the same name has already been registered.
+// Registered modules take precedence over standard library modules with the
+// same name.
+func Register(name string, ns func(*eval.Evaler) *eval.Ns) {
+	registeredMutex.Lock()
+	defer registeredMutex.Unlock()
+	if _, ok := registered[name]; ok {
+		panic(fmt.Sprintf("module %q already registered", name))
+	}
+	registered[name] = ns
+}
+
+// Unregister removes a module registered with Register. It is a no-op if no
+// module with the name has been registered.
+func Unregister(name string) {
+	registeredMutex.Lock()
+	defer registeredMutex.Unlock()
+	delete(registered, name)
 }
@@ -0,0 +1,34 @@
+package mods
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/eval"
+	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestRegister(t *testing.T) {
+	Register("test-mod", func(ev *eval.Evaler) *eval.Ns {
+		return eval.BuildNsNamed("test-mod").
+			AddGoFn("hello", func() string { return "hello" }).Ns()
+	})
+	t.Cleanup(func() { Unregister("test-mod") })
+
+	TestWithSetup(t, AddTo,
+		That("use test-mod; test-mod:hello").Puts("hello"),
+		// Standard library modules are still available.
+		That("use str; str:to-upper foo").Puts("FOO"),
+	)
+}
+
+func TestRegister_PanicsOnDuplicate(t *testing.T) {
+	ns := func(*eval.Evaler) *eval.Ns { return eval.BuildNs().Ns() }
+	Register("test-dup", ns)
+	t.Cleanup(func() { Unregister("test-dup") })
+
+	x := testutil.Recover(func() { Register("test-dup", ns) })
+	if x == nil {
+		t.Errorf("Register did not panic on duplicate registration")
+	}
+}
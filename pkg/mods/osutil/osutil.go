@@ -0,0 +1,259 @@
+// Package osutil exposes adapters that run common system tools and parse their
+// output into Elvish values.
+package osutil
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+)
+
+var Ns = eval.BuildNsNamed("osutil").
+	AddGoFns(map[string]any{
+		"ps":        ps,
+		"df":        df,
+		"ls-l":      lsL,
+		"ip-addr":   ipAddr,
+		"docker-ps": dockerPs,
+	}).Ns()
+
+// DElvCode contains the content of the .d.elv file for this module.
+//
+//go:embed *.d.elv
+var DElvCode string
+
+// Runs an external command and returns its standard output. The command is
+// run in the C locale, since the output of some commands, like the dates
+// printed by ls, is parsed. Can be mutated in tests.
+var runCmd = func(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	return cmd.Output()
+}
+
+var psFields = []string{"pid", "ppid", "user", "cpu", "mem", "command"}
+
+func ps(fm *eval.Frame) error {
+	out, err := runCmd("ps", "-A",
+		"-o", "pid=", "-o", "ppid=", "-o", "user=",
+		"-o", "pcpu=", "-o", "pmem=", "-o", "comm=")
+	if err != nil {
+		return err
+	}
+	return putTable(fm, out, psFields, false)
+}
+
+var dfFields = []string{
+	"filesystem", "blocks", "used", "available", "capacity", "mounted-on"}
+
+func df(fm *eval.Frame) error {
+	out, err := runCmd("df", "-P", "-k")
+	if err != nil {
+		return err
+	}
+	return putTable(fm, out, dfFields, true)
+}
+
+// Writes each line of a whitespace-separated table as a map keyed by the given
+// field names. The last field takes the rest of the line, which may contain
+// spaces. Lines with too few fields are skipped.
+func putTable(fm *eval.Frame, text []byte, fields []string, hasHeader bool) error {
+	out := fm.ValueOutput()
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+	if hasHeader {
+		scanner.Scan()
+	}
+	for scanner.Scan() {
+		values, ok := splitFields(scanner.Text(), len(fields))
+		if !ok {
+			continue
+		}
+		err := out.Put(makeMap(fields, values))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Splits s into n whitespace-separated fields, with the last field containing
+// the rest of s.
+func splitFields(s string, n int) ([]string, bool) {
+	values := make([]string, 0, n)
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	for len(values) < n-1 {
+		i := strings.IndexFunc(s, unicode.IsSpace)
+		if i == -1 {
+			return nil, false
+		}
+		values = append(values, s[:i])
+		s = strings.TrimLeftFunc(s[i:], unicode.IsSpace)
+	}
+	s = strings.TrimRightFunc(s, unicode.IsSpace)
+	if s == "" {
+		return nil, false
+	}
+	return append(values, s), true
+}
+
+func makeMap(keys, values []string) vals.Map {
+	m := vals.EmptyMap
+	for i, key := range keys {
+		m = m.Assoc(key, values[i])
+	}
+	return m
+}
+
+var lsLFields = []string{
+	"mode", "links", "owner", "group", "size", "mtime", "name"}
+
+func lsL(fm *eval.Frame, paths ...string) error {
+	out, err := runCmd("ls", append([]string{"-l", "--"}, paths...)...)
+	if err != nil {
+		return err
+	}
+	output := fm.ValueOutput()
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		m, ok := parseLsLLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		err := output.Put(m)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parses one line of the output of "ls -l". Lines that don't describe a file,
+// like "total 42" and the headers of directories, are rejected.
+func parseLsLLine(line string) (vals.Map, bool) {
+	// mode links owner group size month day time-or-year name
+	values, ok := splitFields(line, 9)
+	if !ok {
+		return nil, false
+	}
+	if strings.HasSuffix(values[4], ",") {
+		// Device files show "major, minor" in place of the size.
+		values, ok = splitFields(line, 10)
+		if !ok {
+			return nil, false
+		}
+		values = append(values[:4:4],
+			append([]string{values[4] + " " + values[5]}, values[6:]...)...)
+	}
+	mtime := values[5] + " " + values[6] + " " + values[7]
+	name := values[8]
+	m := makeMap(lsLFields, append(values[:5:5], mtime, name))
+	if values[0][0] == 'l' {
+		if i := strings.Index(name, " -> "); i != -1 {
+			m = m.Assoc("name", name[:i]).Assoc("target", name[i+len(" -> "):])
+		}
+	}
+	return m, true
+}
+
+type ipAddrInfo struct {
+	Ifname    string
+	Operstate string
+	Mtu       int
+	Address   string
+	AddrInfo  []struct {
+		Family    string
+		Local     string
+		Prefixlen int
+	} `json:"addr_info"`
+}
+
+func ipAddr(fm *eval.Frame) error {
+	out, err := runCmd("ip", "-j", "addr")
+	if err != nil {
+		return err
+	}
+	var infos []ipAddrInfo
+	err = json.Unmarshal(out, &infos)
+	if err != nil {
+		return err
+	}
+	output := fm.ValueOutput()
+	for _, info := range infos {
+		addrs := vals.EmptyList
+		for _, addr := range info.AddrInfo {
+			addrs = addrs.Conj(vals.MakeMap(
+				"family", addr.Family, "address", addr.Local,
+				"prefix-len", addr.Prefixlen))
+		}
+		err := output.Put(vals.MakeMap(
+			"name", info.Ifname, "state", info.Operstate, "mtu", info.Mtu,
+			"mac", info.Address, "addrs", addrs))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type dockerPsOpts struct{ All bool }
+
+func (*dockerPsOpts) SetDefaultOptions() {}
+
+func dockerPs(fm *eval.Frame, opts dockerPsOpts) error {
+	args := []string{"ps", "--no-trunc", "--format", "{{json .}}"}
+	if opts.All {
+		args = append(args, "-a")
+	}
+	out, err := runCmd("docker", args...)
+	if err != nil {
+		return err
+	}
+	output := fm.ValueOutput()
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for dec.More() {
+		var container map[string]string
+		err := dec.Decode(&container)
+		if err != nil {
+			return err
+		}
+		m := vals.EmptyMap
+		for key, value := range container {
+			m = m.Assoc(kebabCase(key), value)
+		}
+		err = output.Put(m)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Converts a CamelCase or all-caps identifier like "CreatedAt" or "ID" to
+// kebab-case like "created-at" or "id".
+func kebabCase(s string) string {
+	var sb strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Start a new word at an upper-case letter that follows a
+			// lower-case letter, or that begins a new capitalized word after
+			// an acronym.
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) &&
+					unicode.IsUpper(runes[i-1]))) {
+				sb.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,156 @@
+package osutil
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/eval"
+	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestPs(t *testing.T) {
+	fakeCmd(t, "ps", ""+
+		"    1     0 root      0.0  0.1 init\n"+
+		" 4242     1 elf      12.5  3.0 Web Content\n")
+
+	TestWithSetup(t, setup,
+		That("osutil:ps").Puts(
+			vals.MakeMap("pid", "1", "ppid", "0", "user", "root",
+				"cpu", "0.0", "mem", "0.1", "command", "init"),
+			vals.MakeMap("pid", "4242", "ppid", "1", "user", "elf",
+				"cpu", "12.5", "mem", "3.0", "command", "Web Content")),
+	)
+}
+
+func TestDf(t *testing.T) {
+	fakeCmd(t, "df", ""+
+		"Filesystem     1024-blocks      Used Available Capacity Mounted on\n"+
+		"/dev/sda1        100000000  60000000  40000000      60% /\n"+
+		"tmpfs                 1024         0      1024       0% /run/user 1000\n")
+
+	TestWithSetup(t, setup,
+		That("osutil:df").Puts(
+			vals.MakeMap("filesystem", "/dev/sda1", "blocks", "100000000",
+				"used", "60000000", "available", "40000000",
+				"capacity", "60%", "mounted-on", "/"),
+			vals.MakeMap("filesystem", "tmpfs", "blocks", "1024",
+				"used", "0", "available", "1024",
+				"capacity", "0%", "mounted-on", "/run/user 1000")),
+	)
+}
+
+func TestLsL(t *testing.T) {
+	fakeCmd(t, "ls", ""+
+		"total 8\n"+
+		"-rw-r--r--  1 elf  staff  4096 Jan  2 15:04 a file\n"+
+		"lrwxrwxrwx  1 root root      7 Mar 10  2020 bin -> usr/bin\n"+
+		"brw-rw----  1 root disk   8, 1 Oct 14 09:00 sda1\n")
+
+	TestWithSetup(t, setup,
+		That("osutil:ls-l").Puts(
+			vals.MakeMap("mode", "-rw-r--r--", "links", "1", "owner", "elf",
+				"group", "staff", "size", "4096", "mtime", "Jan 2 15:04",
+				"name", "a file"),
+			vals.MakeMap("mode", "lrwxrwxrwx", "links", "1", "owner", "root",
+				"group", "root", "size", "7", "mtime", "Mar 10 2020",
+				"name", "bin", "target", "usr/bin"),
+			vals.MakeMap("mode", "brw-rw----", "links", "1", "owner", "root",
+				"group", "disk", "size", "8, 1", "mtime", "Oct 14 09:00",
+				"name", "sda1")),
+	)
+}
+
+func TestIPAddr(t *testing.T) {
+	fakeCmd(t, "ip", `[{"ifindex":1,"ifname":"lo","mtu":65536,"operstate":"UNKNOWN",`+
+		`"address":"00:00:00:00:00:00","addr_info":[`+
+		`{"family":"inet","local":"127.0.0.1","prefixlen":8},`+
+		`{"family":"inet6","local":"::1","prefixlen":128}]}]`)
+
+	TestWithSetup(t, setup,
+		That("osutil:ip-addr").Puts(
+			vals.MakeMap("name", "lo", "state", "UNKNOWN", "mtu", 65536,
+				"mac", "00:00:00:00:00:00", "addrs", vals.MakeList(
+					vals.MakeMap("family", "inet", "address", "127.0.0.1",
+						"prefix-len", 8),
+					vals.MakeMap("family", "inet6", "address", "::1",
+						"prefix-len", 128)))),
+	)
+}
+
+func TestDockerPs(t *testing.T) {
+	fakeCmd(t, "docker", ""+
+		`{"ID":"abc","Image":"nginx","RunningFor":"2 hours ago"}`+"\n"+
+		`{"ID":"def","Image":"redis","RunningFor":"3 days ago"}`+"\n")
+
+	TestWithSetup(t, setup,
+		That("osutil:docker-ps").Puts(
+			vals.MakeMap("id", "abc", "image", "nginx", "running-for", "2 hours ago"),
+			vals.MakeMap("id", "def", "image", "redis", "running-for", "3 days ago")),
+		That("osutil:docker-ps &all").Puts(
+			vals.MakeMap("id", "abc", "image", "nginx", "running-for", "2 hours ago"),
+			vals.MakeMap("id", "def", "image", "redis", "running-for", "3 days ago")),
+	)
+}
+
+func TestLsL_PathsAreNotOptions(t *testing.T) {
+	var gotArgs []string
+	testutil.Set(t, &runCmd, func(_ string, args ...string) ([]byte, error) {
+		gotArgs = args
+		return nil, nil
+	})
+
+	TestWithSetup(t, setup, That("osutil:ls-l -a").DoesNothing())
+	if want := []string{"-l", "--", "-a"}; !reflect.DeepEqual(gotArgs, want) {
+		t.Errorf("got args %q, want %q", gotArgs, want)
+	}
+}
+
+func TestCommandError(t *testing.T) {
+	errCmd := errors.New("command failed")
+	testutil.Set(t, &runCmd, func(string, ...string) ([]byte, error) {
+		return nil, errCmd
+	})
+
+	TestWithSetup(t, setup,
+		That("osutil:ps").Throws(errCmd),
+		That("osutil:df").Throws(errCmd),
+		That("osutil:ls-l").Throws(errCmd),
+		That("osutil:ip-addr").Throws(errCmd),
+		That("osutil:docker-ps").Throws(errCmd),
+	)
+}
+
+var kebabCaseTests = []struct {
+	in, out string
+}{
+	{"ID", "id"},
+	{"Image", "image"},
+	{"CreatedAt", "created-at"},
+	{"LocalVolumes", "local-volumes"},
+	{"HTTPServer", "http-server"},
+}
+
+func TestKebabCase(t *testing.T) {
+	for _, test := range kebabCaseTests {
+		if out := kebabCase(test.in); out != test.out {
+			t.Errorf("kebabCase(%q) = %q, want %q", test.in, out, test.out)
+		}
+	}
+}
+
+func fakeCmd(t *testing.T, wantName, output string) {
+	testutil.Set(t, &runCmd, func(name string, args ...string) ([]byte, error) {
+		if name != wantName {
+			t.Errorf("got command %s %s, want %s", name, strings.Join(args, " "), wantName)
+		}
+		return []byte(output), nil
+	})
+}
+
+func setup(ev *eval.Evaler) {
+	ev.ExtendGlobal(eval.BuildNs().AddNs("osutil", Ns))
+}
@@ -8,16 +8,19 @@ import (
 
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/fsutil"
 )
 
 // Ns is the namespace for the path: module.
 var Ns = eval.BuildNsNamed("path").
 	AddVars(map[string]vars.Var{
-		"dev-null":       vars.NewReadOnly(os.DevNull),
-		"dev-tty":        vars.NewReadOnly(devTty),
-		"list-separator": vars.NewReadOnly(string(filepath.ListSeparator)),
-		"separator":      vars.NewReadOnly(string(filepath.Separator)),
+		"case-insensitive": vars.FromSetGet(setCaseInsensitive, getCaseInsensitive),
+		"dev-null":         vars.NewReadOnly(os.DevNull),
+		"dev-tty":          vars.NewReadOnly(devTty),
+		"list-separator":   vars.NewReadOnly(string(filepath.ListSeparator)),
+		"separator":        vars.NewReadOnly(string(filepath.Separator)),
 	}).
 	AddGoFns(map[string]any{
 		"abs":           filepath.Abs,
@@ -32,6 +35,7 @@ var Ns = eval.BuildNsNamed("path").
 		"join":          filepath.Join,
 		"temp-dir":      tempDir,
 		"temp-file":     tempFile,
+		"with-temp-dir": withTempDir,
 	}).Ns()
 
 // DElvCode contains the content of the .d.elv file for this module.
@@ -39,6 +43,39 @@ var Ns = eval.BuildNsNamed("path").
 //go:embed *.d.elv
 var DElvCode string
 
+func getCaseInsensitive() any {
+	m := vals.EmptyMap
+	for path, ci := range fsutil.CaseInsensitivePaths() {
+		m = m.Assoc(path, ci)
+	}
+	return m
+}
+
+func setCaseInsensitive(v any) error {
+	m, ok := v.(vals.Map)
+	if !ok {
+		return errs.BadValue{What: "$path:case-insensitive",
+			Valid: "map", Actual: vals.Kind(v)}
+	}
+	paths := make(map[string]bool, m.Len())
+	for it := m.Iterator(); it.HasElem(); it.Next() {
+		k, v := it.Elem()
+		path, ok := k.(string)
+		if !ok || !filepath.IsAbs(path) {
+			return errs.BadValue{What: "key of $path:case-insensitive",
+				Valid: "absolute path", Actual: vals.ReprPlain(k)}
+		}
+		ci, ok := v.(bool)
+		if !ok {
+			return errs.BadValue{What: "value of $path:case-insensitive",
+				Valid: "bool", Actual: vals.ReprPlain(v)}
+		}
+		paths[path] = ci
+	}
+	fsutil.SetCaseInsensitivePaths(paths)
+	return nil
+}
+
 type isOpts struct{ FollowSymlink bool }
 
 func (opts *isOpts) SetDefaultOptions() {}
@@ -84,6 +121,27 @@ func tempDir(opts mktempOpt, args ...string) (string, error) {
 	return os.MkdirTemp(opts.Dir, pattern)
 }
 
+type withTempDirOpt struct {
+	Dir     string
+	Pattern string
+}
+
+func (o *withTempDirOpt) SetDefaultOptions() { o.Pattern = "elvish-*" }
+
+func withTempDir(fm *eval.Frame, opts withTempDirOpt, f eval.Callable) (err error) {
+	dir, err := os.MkdirTemp(opts.Dir, opts.Pattern)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		errRemove := os.RemoveAll(dir)
+		if err == nil {
+			err = errRemove
+		}
+	}()
+	return f.Call(fm, []any{dir}, eval.NoOpts)
+}
+
 func tempFile(opts mktempOpt, args ...string) (*os.File, error) {
 	var pattern string
 	switch len(args) {
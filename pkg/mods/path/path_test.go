@@ -9,7 +9,10 @@ import (
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/eval/errs"
 	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/fsutil"
 	"src.elv.sh/pkg/mods/file"
+	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/testutil"
 )
 
@@ -74,6 +77,17 @@ func TestPath(t *testing.T) {
 			errs.ArityMismatch{What: "arguments", ValidLow: 0, ValidHigh: 1, Actual: 2},
 			"path:temp-dir a b"),
 
+		// The temporary directory is removed after the function returns, even
+		// if it throws an exception.
+		That("var x", "path:with-temp-dir {|d| set x = $d; echo > $d/f }",
+			"path:is-dir $x", "put $x").Puts(
+			false, MatchingRegexp{Pattern: anyDir + `elvish-.*$`}),
+		That("var x", "try { path:with-temp-dir {|d| set x = $d; fail foo } } catch { }",
+			"path:is-dir $x").Puts(false),
+		That("path:with-temp-dir {|d| fail foo }").Throws(eval.FailError{Content: "foo"}),
+		That("path:with-temp-dir &dir=. &pattern='x-*.y' {|d| put $d }").Puts(
+			MatchingRegexp{Pattern: `^(\.[/\\])?x-.*\.y$`}),
+
 		That("var f = (path:temp-file)", "file:close $f", "put $f[fd]", "rm $f[name]").
 			Puts(-1),
 		That("var f = (path:temp-file)", "put $f[name]", "file:close $f", "rm $f[name]").
@@ -90,6 +104,31 @@ func TestPath(t *testing.T) {
 	)
 }
 
+func TestPath_CaseInsensitive(t *testing.T) {
+	tmpdir := testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{"README": ""})
+	t.Cleanup(func() { fsutil.SetCaseInsensitivePaths(nil) })
+	quoted := parse.Quote(tmpdir)
+
+	TestWithSetup(t, importModules,
+		That("put $path:case-insensitive").Puts(vals.EmptyMap),
+		That("set path:case-insensitive["+quoted+"] = $true",
+			"put $path:case-insensitive["+quoted+"]", "put read*").
+			Puts(true, "README"),
+		That("set path:case-insensitive["+quoted+"] = $false", "put read*[nomatch-ok]").
+			DoesNothing(),
+
+		That("set path:case-insensitive = foo").Throws(
+			errs.BadValue{What: "$path:case-insensitive", Valid: "map", Actual: "string"}),
+		That("set path:case-insensitive = [&d=$true]").Throws(
+			errs.BadValue{What: "key of $path:case-insensitive",
+				Valid: "absolute path", Actual: "d"}),
+		That("set path:case-insensitive = [&"+quoted+"=yes]").Throws(
+			errs.BadValue{What: "value of $path:case-insensitive",
+				Valid: "bool", Actual: "yes"}),
+	)
+}
+
 var symlinks = []struct {
 	path   string
 	target string
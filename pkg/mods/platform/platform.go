@@ -8,7 +8,9 @@ import (
 	"runtime"
 	"strings"
 
+	"src.elv.sh/pkg/cli/term"
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/eval/vars"
 )
 
@@ -30,12 +32,30 @@ func hostname(opts hostnameOpt) (string, error) {
 	return parts[0], nil
 }
 
+var getTerminalCapabilities = term.GetCapabilities // to allow mocking in unit tests
+
+func terminal() any {
+	c := getTerminalCapabilities()
+	colors := c.Colors
+	if colors == 0 {
+		colors = 256
+	}
+	return vals.MakeMap(
+		"probed", c.Probed,
+		"truecolor", c.TrueColor,
+		"colors", colors,
+		"bracketed-paste", c.BracketedPaste,
+		"mouse", c.Mouse,
+		"synchronized-output", c.SynchronizedOutput)
+}
+
 var Ns = eval.BuildNsNamed("platform").
 	AddVars(map[string]vars.Var{
 		"arch":       vars.NewReadOnly(runtime.GOARCH),
 		"os":         vars.NewReadOnly(runtime.GOOS),
 		"is-unix":    vars.NewReadOnly(isUnix),
 		"is-windows": vars.NewReadOnly(isWindows),
+		"terminal":   vars.FromGet(terminal),
 	}).
 	AddGoFns(map[string]any{
 		"hostname": hostname,
@@ -5,8 +5,11 @@ import (
 	"runtime"
 	"testing"
 
+	"src.elv.sh/pkg/cli/term"
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
 	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/testutil"
 )
 
@@ -29,6 +32,19 @@ func TestPlatform(t *testing.T) {
 	)
 }
 
+func TestPlatform_Terminal(t *testing.T) {
+	testutil.Set(t, &getTerminalCapabilities, func() term.Capabilities {
+		return term.Capabilities{Probed: true, TrueColor: true, SynchronizedOutput: true}
+	})
+	TestWithSetup(t, setup,
+		That(`put $platform:terminal`).Puts(vals.MakeMap(
+			"probed", true, "truecolor", true, "colors", 256,
+			"bracketed-paste", false, "mouse", false, "synchronized-output", true)),
+		That(`set platform:terminal = [&]`).Throws(
+			errs.SetReadOnlyVar{VarName: "platform:terminal"}),
+	)
+}
+
 func TestPlatform_HostNameError(t *testing.T) {
 	errNoHostname := errors.New("hostname cannot be determined")
 
@@ -0,0 +1,148 @@
+package sh
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Builtin commands of the interpreter. They take the arguments after the
+// command name and return the exit status.
+var builtins map[string]func(*interp, []string, stdio) int
+
+func init() {
+	// Initialized here because eval and . refer to builtins indirectly.
+	builtins = map[string]func(*interp, []string, stdio) int{
+		":":      func(*interp, []string, stdio) int { return 0 },
+		"true":   func(*interp, []string, stdio) int { return 0 },
+		"false":  func(*interp, []string, stdio) int { return 1 },
+		".":      sourceBuiltin,
+		"cd":     cd,
+		"eval":   evalBuiltin,
+		"exit":   exit,
+		"export": export,
+		"unset":  unset,
+	}
+}
+
+// Builtin commands that are run by sh:import-env.
+var envBuiltins = map[string]bool{
+	":": true, "true": true, "false": true, "export": true, "unset": true,
+}
+
+func cd(in *interp, args []string, files stdio) int {
+	var dir string
+	switch len(args) {
+	case 0:
+		dir, _ = in.getVar("HOME")
+	case 1:
+		dir = args[0]
+		if dir == "-" {
+			dir, _ = in.getVar("OLDPWD")
+			fmt.Fprintln(files[1], dir)
+		}
+	default:
+		fmt.Fprintln(files[2], "sh: cd: too many arguments")
+		return 2
+	}
+	dir = in.path(dir)
+	if info, err := os.Stat(dir); err != nil {
+		fmt.Fprintf(files[2], "sh: cd: %v\n", err)
+		return 1
+	} else if !info.IsDir() {
+		fmt.Fprintf(files[2], "sh: cd: %s: not a directory\n", dir)
+		return 1
+	}
+	in.setVar("OLDPWD", in.dir)
+	in.setVar("PWD", dir)
+	in.dir = dir
+	return 0
+}
+
+func evalBuiltin(in *interp, args []string, files stdio) int {
+	return in.runCode(strings.Join(args, " "), files)
+}
+
+func sourceBuiltin(in *interp, args []string, files stdio) int {
+	if len(args) != 1 {
+		fmt.Fprintln(files[2], "sh: .: need exactly one argument")
+		return 2
+	}
+	code, err := os.ReadFile(in.path(args[0]))
+	if err != nil {
+		fmt.Fprintf(files[2], "sh: .: %v\n", err)
+		return 1
+	}
+	return in.runCode(string(code), files)
+}
+
+// Runs code in the current interpreter for eval and ., and returns the exit
+// status.
+func (in *interp) runCode(code string, files stdio) int {
+	l, err := parse(code)
+	if err != nil {
+		fmt.Fprintf(files[2], "sh: %v\n", err)
+		return 2
+	}
+	in.status = 0
+	if err := in.runList(l, files); err != nil {
+		fmt.Fprintf(files[2], "sh: %v\n", err)
+		return 1
+	}
+	return in.status
+}
+
+func exit(in *interp, args []string, files stdio) int {
+	status := in.status
+	if len(args) > 0 {
+		var err error
+		status, err = strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Fprintf(files[2], "sh: exit: bad status %s\n", args[0])
+			status = 2
+		}
+	}
+	in.exited = true
+	return status
+}
+
+func export(in *interp, args []string, files stdio) int {
+	return declare(in, args, files, "export", func(name string, v *shVar) {
+		v.exported = true
+	})
+}
+
+func unset(in *interp, args []string, files stdio) int {
+	if len(args) > 0 && args[0] == "-v" {
+		args = args[1:]
+	}
+	return declare(in, args, files, "unset", func(name string, v *shVar) {
+		*v = shVar{}
+	})
+}
+
+// Implements export and unset, which take names or assignments, rejecting
+// options.
+func declare(in *interp, args []string, files stdio, cmd string, f func(string, *shVar)) int {
+	status := 0
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if strings.HasPrefix(arg, "-") {
+			fmt.Fprintf(files[2], "sh: %s: unsupported option %s\n", cmd, arg)
+			return 2
+		}
+		if !isName(name) || (hasValue && cmd == "unset") {
+			fmt.Fprintf(files[2], "sh: %s: bad variable name %s\n", cmd, arg)
+			status = 1
+			continue
+		}
+		if hasValue {
+			in.setVar(name, value)
+		}
+		v := in.vars[name]
+		f(name, &v)
+		in.vars[name] = v
+	}
+	return status
+}
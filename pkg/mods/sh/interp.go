@@ -0,0 +1,613 @@
+package sh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	elvparse "src.elv.sh/pkg/parse"
+)
+
+// The standard input, output and error of a command.
+type stdio [3]*os.File
+
+type shVar struct {
+	value    string
+	set      bool
+	exported bool
+}
+
+// The state of the interpreter. The interpreter never changes the environment
+// or the working directory of the process while running; the changes are
+// tracked in the state and applied when the snippet finishes.
+type interp struct {
+	vars   map[string]shVar
+	dir    string
+	status int
+	exited bool
+	// If true, only commands that change variables are run, and command
+	// substitutions are not allowed. Used by sh:import-env.
+	envOnly bool
+	// If true, external commands are printed instead of being run, like in
+	// the dry-run mode of Elvish.
+	dryRun bool
+}
+
+func newInterp() (*interp, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]shVar)
+	for name, value := range environ() {
+		vars[name] = shVar{value, true, true}
+	}
+	return &interp{vars: vars, dir: dir}, nil
+}
+
+// Returns the environment of the process as a map.
+func environ() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		// On Windows, the environment contains entries like "=C:=C:\", which
+		// are not variables.
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	return env
+}
+
+// Returns a copy of the interpreter, for running a subshell.
+func (in *interp) clone() *interp {
+	vars := make(map[string]shVar, len(in.vars))
+	for name, v := range in.vars {
+		vars[name] = v
+	}
+	return &interp{vars: vars, dir: in.dir, status: in.status,
+		envOnly: in.envOnly, dryRun: in.dryRun}
+}
+
+func (in *interp) getVar(name string) (string, bool) {
+	if name == "?" {
+		return strconv.Itoa(in.status), true
+	}
+	v := in.vars[name]
+	return v.value, v.set
+}
+
+func (in *interp) setVar(name, value string) {
+	v := in.vars[name]
+	v.value, v.set = value, true
+	in.vars[name] = v
+}
+
+var errCmdSubstInEnv = errors.New("command substitution is not allowed in environment settings")
+
+// Returns the environment for running external commands, with extra
+// variables from assignments before the command.
+func (in *interp) environ(extra map[string]string) []string {
+	var env []string
+	for name, v := range in.vars {
+		if _, ok := extra[name]; !ok && v.set && v.exported {
+			env = append(env, name+"="+v.value)
+		}
+	}
+	for name, value := range extra {
+		env = append(env, name+"="+value)
+	}
+	return env
+}
+
+func (in *interp) runList(l list, files stdio) error {
+	for _, ao := range l {
+		if err := in.runAndOr(ao, files); err != nil {
+			return err
+		}
+		if in.exited {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (in *interp) runAndOr(ao andOr, files stdio) error {
+	if err := in.runPipeline(ao.first, files); err != nil {
+		return err
+	}
+	for _, item := range ao.rest {
+		if in.exited {
+			return nil
+		}
+		if (item.op == "&&") != (in.status == 0) {
+			continue
+		}
+		if err := in.runPipeline(item.p, files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (in *interp) runPipeline(pl pipeline, files stdio) error {
+	var err error
+	if len(pl.cmds) == 1 {
+		err = in.runCmd(pl.cmds[0], files)
+	} else {
+		err = in.runPipe(pl.cmds, files)
+	}
+	if pl.negate {
+		if in.status == 0 {
+			in.status = 1
+		} else {
+			in.status = 0
+		}
+	}
+	return err
+}
+
+// Runs commands connected with pipes. Like in other shells, each command runs
+// in a subshell.
+func (in *interp) runPipe(cmds []simpleCmd, files stdio) error {
+	n := len(cmds)
+	errs := make([]error, n)
+	statuses := make([]int, n)
+	var wg sync.WaitGroup
+	input := files[0]
+	for i, cmd := range cmds {
+		cmdFiles := files
+		cmdFiles[0] = input
+		var r, w *os.File
+		if i < n-1 {
+			var err error
+			r, w, err = os.Pipe()
+			if err != nil {
+				errs[i] = err
+				if i > 0 {
+					input.Close()
+				}
+				break
+			}
+			cmdFiles[1] = w
+		}
+		wg.Add(1)
+		go func(i int, cmd simpleCmd, sub *interp) {
+			defer wg.Done()
+			errs[i] = sub.runCmd(cmd, cmdFiles)
+			statuses[i] = sub.status
+			if i < n-1 {
+				cmdFiles[1].Close()
+			}
+			if i > 0 {
+				cmdFiles[0].Close()
+			}
+		}(i, cmd, in.clone())
+		input = r
+	}
+	wg.Wait()
+	in.status = statuses[n-1]
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (in *interp) runCmd(c simpleCmd, files stdio) error {
+	args, err := in.expandWords(c.words, files)
+	if err != nil {
+		return err
+	}
+	values := make(map[string]string, len(c.assigns))
+	for _, a := range c.assigns {
+		value, err := in.expandString(a.value, files)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			in.setVar(a.name, value)
+		} else {
+			values[a.name] = value
+		}
+	}
+
+	if in.envOnly && len(args) > 0 && !envBuiltins[args[0]] {
+		// Other commands, like the echo commands output by ssh-agent, are
+		// skipped.
+		in.status = 0
+		return nil
+	}
+
+	cmdFiles, closeFiles, err := in.redirect(c.redirs, files)
+	defer closeFiles()
+	if err != nil {
+		fmt.Fprintf(files[2], "sh: %v\n", err)
+		in.status = 1
+		return nil
+	}
+	if len(args) == 0 {
+		in.status = 0
+		return nil
+	}
+	if builtin, ok := builtins[args[0]]; ok {
+		in.status = builtin(in, args[1:], cmdFiles)
+		return nil
+	}
+	in.status = in.runExternal(args, values, cmdFiles)
+	return nil
+}
+
+// Applies redirections, and returns the resulting files and a function to close
+// the files that were opened.
+func (in *interp) redirect(redirs []redir, files stdio) (stdio, func(), error) {
+	var opened []*os.File
+	closeFiles := func() {
+		for _, f := range opened {
+			f.Close()
+		}
+	}
+	for _, r := range redirs {
+		if r.fd > 2 {
+			return files, closeFiles, fmt.Errorf("unsupported file descriptor %d", r.fd)
+		}
+		target, err := in.expandString(r.target, files)
+		if err != nil {
+			return files, closeFiles, err
+		}
+		if r.op == ">&" || r.op == "<&" {
+			src, err := strconv.Atoi(target)
+			if err != nil || src < 0 || src > 2 {
+				return files, closeFiles, fmt.Errorf("unsupported file descriptor %s", target)
+			}
+			files[r.fd] = files[src]
+			continue
+		}
+		var flag int
+		switch r.op {
+		case ">":
+			flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		case ">>":
+			flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		case "<":
+			flag = os.O_RDONLY
+		}
+		f, err := os.OpenFile(in.path(target), flag, 0666)
+		if err != nil {
+			return files, closeFiles, err
+		}
+		opened = append(opened, f)
+		files[r.fd] = f
+	}
+	return files, closeFiles, nil
+}
+
+// Resolves a path relative to the working directory of the interpreter.
+func (in *interp) path(p string) string {
+	if filepath.IsAbs(p) || p == os.DevNull {
+		return p
+	}
+	return filepath.Join(in.dir, p)
+}
+
+func (in *interp) runExternal(args []string, values map[string]string, files stdio) int {
+	if in.dryRun {
+		quoted := make([]string, len(args))
+		for i, arg := range args {
+			quoted[i] = elvparse.Quote(arg)
+		}
+		fmt.Fprintln(files[2], strings.Join(quoted, " "))
+		return 0
+	}
+	path, err := in.lookPath(args[0])
+	if err != nil {
+		fmt.Fprintf(files[2], "sh: %s: not found\n", args[0])
+		return 127
+	}
+	cmd := &exec.Cmd{
+		Path: path, Args: args, Env: in.environ(values), Dir: in.dir,
+		Stdin: files[0], Stdout: files[1], Stderr: files[2]}
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		if code := exitErr.ExitCode(); code >= 0 {
+			return code
+		}
+		// Killed by a signal.
+		return 128
+	default:
+		fmt.Fprintf(files[2], "sh: %s: %v\n", args[0], err)
+		return 126
+	}
+}
+
+// Searches an external command using the PATH of the interpreter.
+func (in *interp) lookPath(name string) (string, error) {
+	if strings.ContainsRune(name, '/') || strings.ContainsRune(name, filepath.Separator) {
+		return exec.LookPath(in.path(name))
+	}
+	pathVar, _ := in.getVar("PATH")
+	for _, dir := range filepath.SplitList(pathVar) {
+		if dir == "" {
+			dir = "."
+		}
+		if path, err := exec.LookPath(in.path(filepath.Join(dir, name))); err == nil {
+			return path, nil
+		}
+	}
+	return "", exec.ErrNotFound
+}
+
+// Words expansion.
+
+type segment struct {
+	text   string
+	quoted bool
+}
+
+// A field is the result of expanding a word, before pathname expansion.
+type field []segment
+
+// Expands words into arguments, performing field splitting and pathname
+// expansion.
+func (in *interp) expandWords(ws []word, files stdio) ([]string, error) {
+	var args []string
+	for _, w := range ws {
+		fields, err := in.fields(w, files)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			args = append(args, in.glob(f)...)
+		}
+	}
+	return args, nil
+}
+
+func (in *interp) fields(w word, files stdio) ([]field, error) {
+	ifs, ok := in.getVar("IFS")
+	if !ok {
+		ifs = " \t\n"
+	}
+	var fields []field
+	var cur field
+	started := false
+	add := func(text string, quoted bool) {
+		cur = append(cur, segment{text, quoted})
+		started = true
+	}
+	// Adds the parts of a word. If split is true, unquoted literals are also
+	// subject to field splitting, as is the case for the word in
+	// ${name:-word}.
+	var addParts func(w word, split bool) error
+	addParts = func(w word, split bool) error {
+		for _, part := range w {
+			if p, ok := part.(param); ok && !p.quoted {
+				arg, useArg, err := in.paramArg(p, files)
+				if err != nil {
+					return err
+				}
+				if useArg {
+					if err := addParts(arg, true); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			value, quoted, err := in.expandPart(part, files)
+			if err != nil {
+				return err
+			}
+			if quoted {
+				add(value, true)
+				continue
+			}
+			if _, isLiteral := part.(literal); (isLiteral && !split) || ifs == "" {
+				add(value, false)
+				continue
+			}
+			// Split the result of an unquoted expansion.
+			for value != "" {
+				i := strings.IndexAny(value, ifs)
+				if i == -1 {
+					add(value, false)
+					break
+				}
+				if i > 0 {
+					add(value[:i], false)
+				}
+				if started {
+					fields = append(fields, cur)
+					cur, started = nil, false
+				}
+				value = value[i+1:]
+			}
+		}
+		return nil
+	}
+	if err := addParts(w, false); err != nil {
+		return nil, err
+	}
+	if started {
+		fields = append(fields, cur)
+	}
+	return fields, nil
+}
+
+// Expands a word into a single string, without field splitting or pathname
+// expansion.
+func (in *interp) expandString(w word, files stdio) (string, error) {
+	var sb strings.Builder
+	for _, part := range w {
+		value, _, err := in.expandPart(part, files)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(value)
+	}
+	return sb.String(), nil
+}
+
+// Expands one part of a word, and returns whether the result is quoted.
+func (in *interp) expandPart(part any, files stdio) (string, bool, error) {
+	switch part := part.(type) {
+	case literal:
+		return part.text, part.quoted, nil
+	case tilde:
+		home, _ := in.getVar("HOME")
+		return home, true, nil
+	case param:
+		value, err := in.expandParam(part, files)
+		return value, part.quoted, err
+	case cmdSubst:
+		value, err := in.expandCmdSubst(part, files)
+		return value, part.quoted, err
+	default:
+		panic("unreachable")
+	}
+}
+
+func (in *interp) expandParam(p param, files stdio) (string, error) {
+	arg, useArg, err := in.paramArg(p, files)
+	if err != nil || !useArg {
+		value, _ := in.getVar(p.name)
+		if p.op == "#" {
+			value = strconv.Itoa(utf8.RuneCountInString(value))
+		} else if strings.HasSuffix(p.op, "+") {
+			value = ""
+		}
+		return value, err
+	}
+	return in.expandString(arg, files)
+}
+
+// Returns whether the word in a parameter expansion like ${name:-word} should
+// be used instead of the value of the parameter, and the word. Assigns the
+// word to the parameter for operators like :=, and returns an error for
+// operators like :?.
+func (in *interp) paramArg(p param, files stdio) (word, bool, error) {
+	value, set := in.getVar(p.name)
+	// Whether the parameter is considered missing by the operator.
+	missing := !set || (strings.HasPrefix(p.op, ":") && value == "")
+	switch p.op {
+	case "-", ":-":
+		return p.arg, missing, nil
+	case "=", ":=":
+		if missing {
+			arg, err := in.expandString(p.arg, files)
+			if err != nil {
+				return nil, false, err
+			}
+			in.setVar(p.name, arg)
+		}
+		return nil, false, nil
+	case "+", ":+":
+		return p.arg, !missing, nil
+	case "?", ":?":
+		if missing {
+			msg, err := in.expandString(p.arg, files)
+			if err != nil {
+				return nil, false, err
+			}
+			if msg == "" {
+				msg = "parameter not set"
+				if set {
+					msg = "parameter null or not set"
+				}
+			}
+			return nil, false, fmt.Errorf("%s: %s", p.name, msg)
+		}
+	}
+	return nil, false, nil
+}
+
+func (in *interp) expandCmdSubst(c cmdSubst, files stdio) (string, error) {
+	if in.envOnly {
+		return "", errCmdSubstInEnv
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(&buf, r)
+		r.Close()
+		close(copied)
+	}()
+	sub := in.clone()
+	err = sub.runList(c.body, stdio{files[0], w, files[2]})
+	w.Close()
+	<-copied
+	in.status = sub.status
+	return strings.TrimRight(buf.String(), "\n"), err
+}
+
+// Performs pathname expansion on a field. Like in POSIX sh, a pattern that
+// matches nothing is kept as is.
+func (in *interp) glob(f field) []string {
+	var text, pattern strings.Builder
+	hasMeta := false
+	for _, seg := range f {
+		text.WriteString(seg.text)
+		if seg.quoted {
+			pattern.WriteString(escapeGlob(seg.text))
+		} else {
+			pattern.WriteString(seg.text)
+			hasMeta = hasMeta || strings.ContainsAny(seg.text, "*?[")
+		}
+	}
+	if !hasMeta {
+		return []string{text.String()}
+	}
+	pat := pattern.String()
+	prefix := ""
+	if !filepath.IsAbs(pat) {
+		prefix = in.dir + string(filepath.Separator)
+	}
+	matches, err := filepath.Glob(escapeGlob(prefix) + pat)
+	if err != nil || len(matches) == 0 {
+		return []string{text.String()}
+	}
+	// Unlike in filepath.Glob, * and ? don't match a leading dot.
+	hidden := strings.HasPrefix(filepath.Base(pat), ".")
+	var results []string
+	for _, match := range matches {
+		if hidden || !strings.HasPrefix(filepath.Base(match), ".") {
+			results = append(results, strings.TrimPrefix(match, prefix))
+		}
+	}
+	if len(results) == 0 {
+		return []string{text.String()}
+	}
+	return results
+}
+
+func escapeGlob(s string) string {
+	if runtime.GOOS == "windows" {
+		// Backslashes are path separators on Windows, and can't be used for
+		// escaping.
+		return s
+	}
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[\`, r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,615 @@
+package sh
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The syntax tree of the supported subset of POSIX sh.
+
+// A list of and-or lists, separated by ";" or newlines.
+type list []andOr
+
+// Pipelines joined by "&&" and "||".
+type andOr struct {
+	first pipeline
+	rest  []andOrItem
+}
+
+type andOrItem struct {
+	op string // "&&" or "||"
+	p  pipeline
+}
+
+// Commands joined by "|", optionally preceded by "!".
+type pipeline struct {
+	negate bool
+	cmds   []simpleCmd
+}
+
+type simpleCmd struct {
+	assigns []assign
+	words   []word
+	redirs  []redir
+}
+
+type assign struct {
+	name  string
+	value word
+}
+
+type redir struct {
+	fd int
+	// One of ">", ">>", "<", ">&" and "<&".
+	op     string
+	target word
+}
+
+// A word is a sequence of parts, which are one of literal, param, cmdSubst and
+// tilde.
+type word []any
+
+type literal struct {
+	text   string
+	quoted bool
+}
+
+// A parameter expansion like $name or ${name:-default}.
+type param struct {
+	name string
+	// One of "", "#" (length), "-", ":-", "=", ":=", "+", ":+", "?" and ":?".
+	op     string
+	arg    word
+	quoted bool
+}
+
+// A command substitution $(...).
+type cmdSubst struct {
+	body   list
+	quoted bool
+}
+
+// An unquoted ~ at the start of a word.
+type tilde struct{}
+
+// Error returned for syntax that is not supported.
+type unsupportedError struct{ what string }
+
+func (e unsupportedError) Error() string {
+	return "unsupported sh syntax: " + e.what
+}
+
+// Syntax error.
+type syntaxError struct {
+	pos int
+	msg string
+}
+
+func (e syntaxError) Error() string {
+	return fmt.Sprintf("sh syntax error at offset %d: %s", e.pos, e.msg)
+}
+
+// Words that start compound commands and other unsupported constructs when
+// they appear as the command name.
+var reservedWords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "fi": true,
+	"for": true, "while": true, "until": true, "do": true, "done": true,
+	"case": true, "esac": true, "in": true, "function": true,
+	"{": true, "}": true,
+}
+
+type parser struct {
+	src string
+	pos int
+}
+
+func parse(src string) (list list, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+				return
+			}
+			panic(r)
+		}
+	}()
+	p := &parser{src: src}
+	list = p.list(false)
+	if p.pos < len(p.src) {
+		p.fail("unexpected %q", p.src[p.pos])
+	}
+	return list, nil
+}
+
+func (p *parser) fail(format string, args ...any) {
+	panic(syntaxError{p.pos, fmt.Sprintf(format, args...)})
+}
+
+func (p *parser) unsupported(what string) {
+	panic(unsupportedError{what})
+}
+
+func (p *parser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) hasPrefix(s string) bool { return strings.HasPrefix(p.src[p.pos:], s) }
+
+// Skips spaces, tabs, line continuations and comments, but not newlines.
+func (p *parser) skipBlanks() {
+	for !p.eof() {
+		switch {
+		case p.peek() == ' ' || p.peek() == '\t':
+			p.pos++
+		case p.hasPrefix("\\\n"):
+			p.pos += 2
+		case p.peek() == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// Parses a list. If nested is true, the list is the body of a command
+// substitution and ends before a ")".
+func (p *parser) list(nested bool) list {
+	var l list
+	for {
+		p.skipBlanks()
+		if p.eof() || (nested && p.peek() == ')') {
+			return l
+		}
+		if p.peek() == ';' || p.peek() == '\n' {
+			if p.peek() == ';' && len(l) == 0 {
+				p.fail("unexpected ;")
+			}
+			p.pos++
+			continue
+		}
+		l = append(l, p.andOr())
+		p.skipBlanks()
+		switch {
+		case p.eof(), p.peek() == ';', p.peek() == '\n':
+		case nested && p.peek() == ')':
+		case p.peek() == '&':
+			p.unsupported("background job &")
+		default:
+			p.fail("unexpected %q", p.peek())
+		}
+	}
+}
+
+func (p *parser) andOr() andOr {
+	ao := andOr{first: p.pipeline()}
+	for {
+		p.skipBlanks()
+		var op string
+		switch {
+		case p.hasPrefix("&&"):
+			op = "&&"
+		case p.hasPrefix("||"):
+			op = "||"
+		default:
+			return ao
+		}
+		p.pos += 2
+		p.skipNewlines()
+		ao.rest = append(ao.rest, andOrItem{op, p.pipeline()})
+	}
+}
+
+func (p *parser) skipNewlines() {
+	for {
+		p.skipBlanks()
+		if p.peek() != '\n' {
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) pipeline() pipeline {
+	var pl pipeline
+	p.skipBlanks()
+	if p.hasPrefix("!") && (p.hasPrefix("! ") || p.hasPrefix("!\t")) {
+		pl.negate = true
+		p.pos++
+	}
+	pl.cmds = append(pl.cmds, p.simpleCmd())
+	for {
+		p.skipBlanks()
+		if !p.hasPrefix("|") || p.hasPrefix("||") {
+			return pl
+		}
+		p.pos++
+		p.skipNewlines()
+		pl.cmds = append(pl.cmds, p.simpleCmd())
+	}
+}
+
+func (p *parser) simpleCmd() simpleCmd {
+	var c simpleCmd
+	for {
+		p.skipBlanks()
+		if p.eof() {
+			break
+		}
+		switch p.peek() {
+		case '\n', ';', '&', '|', ')':
+			goto done
+		case '(':
+			p.unsupported("subshell (...)")
+		}
+		if r, ok := p.redir(); ok {
+			c.redirs = append(c.redirs, r)
+			continue
+		}
+		w := p.word()
+		if len(c.words) == 0 {
+			if name, value, ok := assignment(w); ok {
+				c.assigns = append(c.assigns, assign{name, value})
+				continue
+			}
+			if lit, ok := literalText(w); ok && reservedWords[lit] {
+				p.unsupported(lit)
+			}
+		}
+		c.words = append(c.words, w)
+	}
+done:
+	if len(c.assigns) == 0 && len(c.words) == 0 && len(c.redirs) == 0 {
+		if p.eof() {
+			p.fail("missing command")
+		}
+		p.fail("unexpected %q", p.peek())
+	}
+	return c
+}
+
+// Returns the text of a word if it consists of only unquoted literals.
+func literalText(w word) (string, bool) {
+	var sb strings.Builder
+	for _, part := range w {
+		lit, ok := part.(literal)
+		if !ok || lit.quoted {
+			return "", false
+		}
+		sb.WriteString(lit.text)
+	}
+	return sb.String(), true
+}
+
+// Checks whether a word is an assignment like name=value, and if so, returns
+// the name and the value.
+func assignment(w word) (string, word, bool) {
+	if len(w) == 0 {
+		return "", nil, false
+	}
+	lit, ok := w[0].(literal)
+	if !ok || lit.quoted {
+		return "", nil, false
+	}
+	i := strings.IndexByte(lit.text, '=')
+	if i <= 0 || !isName(lit.text[:i]) {
+		return "", nil, false
+	}
+	value := word{}
+	if rest := lit.text[i+1:]; rest != "" {
+		value = append(value, literal{rest, false})
+	}
+	value = append(value, w[1:]...)
+	return lit.text[:i], value, true
+}
+
+func isName(s string) bool {
+	if s == "" || isDigit(s[0]) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isNameByte(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isNameByte(b byte) bool {
+	return b == '_' || isDigit(b) || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+func isDigit(b byte) bool { return '0' <= b && b <= '9' }
+
+// Parses a redirection if there is one at the current position.
+func (p *parser) redir() (redir, bool) {
+	i := p.pos
+	for i < len(p.src) && isDigit(p.src[i]) {
+		i++
+	}
+	if i == len(p.src) || (p.src[i] != '<' && p.src[i] != '>') {
+		return redir{}, false
+	}
+	fd := -1
+	if i > p.pos {
+		var err error
+		fd, err = strconv.Atoi(p.src[p.pos:i])
+		if err != nil {
+			p.fail("bad file descriptor %s", p.src[p.pos:i])
+		}
+	}
+	p.pos = i
+	var op string
+	for _, candidate := range []string{">>", ">&", "<&", "<<", "<>", ">|", ">", "<"} {
+		if p.hasPrefix(candidate) {
+			op = candidate
+			break
+		}
+	}
+	switch op {
+	case "<<":
+		p.unsupported("here-document <<")
+	case "<>":
+		p.unsupported("redirection <>")
+	case ">|":
+		op = ">"
+	}
+	p.pos += len(op)
+	if fd == -1 {
+		fd = 1
+		if op[0] == '<' {
+			fd = 0
+		}
+	}
+	p.skipBlanks()
+	if p.eof() || isMeta(p.peek()) {
+		p.fail("missing target of redirection %s", op)
+	}
+	return redir{fd, op, p.word()}, true
+}
+
+func isMeta(b byte) bool {
+	switch b {
+	case ' ', '\t', '\n', ';', '&', '|', '<', '>', '(', ')':
+		return true
+	}
+	return false
+}
+
+// Parses a word, which ends at an unquoted metacharacter.
+func (p *parser) word() word {
+	w := word{}
+	var lit strings.Builder
+	flush := func() {
+		if lit.Len() > 0 {
+			w = append(w, literal{lit.String(), false})
+			lit.Reset()
+		}
+	}
+	if p.peek() == '~' {
+		if next := p.pos + 1; next == len(p.src) || p.src[next] == '/' || isMeta(p.src[next]) {
+			w = append(w, tilde{})
+			p.pos++
+		}
+	}
+	for !p.eof() && !isMeta(p.peek()) {
+		switch b := p.peek(); b {
+		case '\'':
+			flush()
+			p.pos++
+			end := strings.IndexByte(p.src[p.pos:], '\'')
+			if end == -1 {
+				p.fail("unterminated single-quoted string")
+			}
+			w = append(w, literal{p.src[p.pos : p.pos+end], true})
+			p.pos += end + 1
+		case '"':
+			flush()
+			w = append(w, p.doubleQuoted()...)
+		case '\\':
+			p.pos++
+			if p.eof() {
+				lit.WriteByte('\\')
+			} else if p.peek() == '\n' {
+				p.pos++
+			} else {
+				flush()
+				w = append(w, literal{p.src[p.pos : p.pos+1], true})
+				p.pos++
+			}
+		case '$':
+			flush()
+			w = append(w, p.dollar(false))
+		case '`':
+			p.unsupported("command substitution with backquotes")
+		default:
+			lit.WriteByte(b)
+			p.pos++
+		}
+	}
+	flush()
+	return w
+}
+
+func (p *parser) doubleQuoted() word {
+	p.pos++ // Skip the opening quote
+	w := word{literal{"", true}}
+	var lit strings.Builder
+	flush := func() {
+		if lit.Len() > 0 {
+			w = append(w, literal{lit.String(), true})
+			lit.Reset()
+		}
+	}
+	for {
+		if p.eof() {
+			p.fail("unterminated double-quoted string")
+		}
+		switch b := p.peek(); b {
+		case '"':
+			p.pos++
+			flush()
+			return w
+		case '\\':
+			p.pos++
+			next := p.peek()
+			switch next {
+			case '$', '`', '"', '\\':
+				lit.WriteByte(next)
+				p.pos++
+			case '\n':
+				p.pos++
+			default:
+				lit.WriteByte('\\')
+			}
+		case '$':
+			flush()
+			w = append(w, p.dollar(true))
+		case '`':
+			p.unsupported("command substitution with backquotes")
+		default:
+			lit.WriteByte(b)
+			p.pos++
+		}
+	}
+}
+
+// Parses an expansion starting with $.
+func (p *parser) dollar(quoted bool) any {
+	p.pos++ // Skip $
+	switch {
+	case p.hasPrefix("(("):
+		p.unsupported("arithmetic expansion $((...))")
+	case p.hasPrefix("("):
+		p.pos++
+		body := p.list(true)
+		if p.peek() != ')' {
+			p.fail("unterminated command substitution")
+		}
+		p.pos++
+		return cmdSubst{body, quoted}
+	case p.hasPrefix("{"):
+		p.pos++
+		return p.bracedParam(quoted)
+	case p.peek() == '?':
+		p.pos++
+		return param{name: "?", quoted: quoted}
+	case isDigit(p.peek()) || strings.IndexByte("@*#$!-", p.peek()) != -1:
+		p.unsupported("special parameter $" + p.src[p.pos:p.pos+1])
+	case isNameByte(p.peek()):
+		start := p.pos
+		for !p.eof() && isNameByte(p.peek()) {
+			p.pos++
+		}
+		return param{name: p.src[start:p.pos], quoted: quoted}
+	}
+	return literal{"$", quoted}
+}
+
+func (p *parser) bracedParam(quoted bool) param {
+	var pm param
+	if p.hasPrefix("#") && !p.hasPrefix("#}") {
+		pm.op = "#"
+		p.pos++
+	}
+	start := p.pos
+	if p.peek() == '?' {
+		p.pos++
+	} else {
+		for !p.eof() && isNameByte(p.peek()) {
+			p.pos++
+		}
+	}
+	pm.name = p.src[start:p.pos]
+	if pm.name != "?" && !isName(pm.name) {
+		if pm.name != "" && isDigit(pm.name[0]) {
+			p.unsupported("positional parameter ${" + pm.name + "}")
+		}
+		p.fail("bad parameter name in ${...}")
+	}
+	pm.quoted = quoted
+	if p.peek() == '}' {
+		p.pos++
+		return pm
+	}
+	if pm.op == "#" {
+		p.fail("bad ${#...} expansion")
+	}
+	for _, op := range []string{":-", ":=", ":+", ":?", "-", "=", "+", "?"} {
+		if p.hasPrefix(op) {
+			pm.op = op
+			break
+		}
+	}
+	if pm.op == "" {
+		if p.eof() {
+			p.fail("unterminated ${...}")
+		}
+		p.unsupported("parameter expansion operator " + p.src[p.pos:p.pos+1])
+	}
+	p.pos += len(pm.op)
+	pm.arg = p.paramArg(quoted)
+	return pm
+}
+
+// Parses the word in ${name op word}, up to the closing brace.
+func (p *parser) paramArg(quoted bool) word {
+	w := word{}
+	var lit strings.Builder
+	flush := func() {
+		if lit.Len() > 0 {
+			w = append(w, literal{lit.String(), quoted})
+			lit.Reset()
+		}
+	}
+	for {
+		if p.eof() {
+			p.fail("unterminated ${...}")
+		}
+		switch b := p.peek(); b {
+		case '}':
+			p.pos++
+			flush()
+			return w
+		case '\'':
+			if quoted {
+				lit.WriteByte(b)
+				p.pos++
+				continue
+			}
+			flush()
+			p.pos++
+			end := strings.IndexByte(p.src[p.pos:], '\'')
+			if end == -1 {
+				p.fail("unterminated single-quoted string")
+			}
+			w = append(w, literal{p.src[p.pos : p.pos+end], true})
+			p.pos += end + 1
+		case '"':
+			flush()
+			w = append(w, p.doubleQuoted()...)
+		case '\\':
+			p.pos++
+			if !p.eof() {
+				flush()
+				w = append(w, literal{p.src[p.pos : p.pos+1], true})
+				p.pos++
+			}
+		case '$':
+			flush()
+			w = append(w, p.dollar(quoted))
+		case '`':
+			p.unsupported("command substitution with backquotes")
+		default:
+			lit.WriteByte(b)
+			p.pos++
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Package sh implements the sh: module, which runs snippets of POSIX sh.
+package sh
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+)
+
+// Ns is the namespace for the sh: module.
+var Ns = eval.BuildNsNamed("sh").
+	AddGoFns(map[string]any{
+		"eval":       evalSh,
+		"import-env": importEnv,
+	}).Ns()
+
+// DElvCode contains the content of the .d.elv file for this module.
+//
+//go:embed *.d.elv
+var DElvCode string
+
+// ExitError is thrown by sh:eval when the snippet exits with a non-zero
+// status.
+type ExitError struct{ Status int }
+
+func (e ExitError) Error() string {
+	return fmt.Sprintf("sh snippet exited with status %d", e.Status)
+}
+
+func evalSh(fm *eval.Frame, code string) error {
+	l, err := parse(code)
+	if err != nil {
+		return err
+	}
+	in, err := newInterp()
+	if err != nil {
+		return err
+	}
+	in.dryRun = fm.DryRun()
+	oldDir := in.dir
+	errRun := in.runList(l, stdio{fm.InputFile(), fm.Port(1).File, fm.ErrorFile()})
+	// Apply the changes even if the snippet failed halfway.
+	if err := in.apply(fm, oldDir); err != nil && errRun == nil {
+		errRun = err
+	}
+	if errRun != nil {
+		return errRun
+	}
+	if in.status != 0 {
+		return ExitError{in.status}
+	}
+	return nil
+}
+
+func importEnv(fm *eval.Frame, cmd any, args ...any) error {
+	var callable eval.Callable
+	switch cmd := cmd.(type) {
+	case string:
+		callable = eval.NewExternalCmd(cmd)
+	case eval.Callable:
+		callable = cmd
+	default:
+		return errs.BadValue{What: "command",
+			Valid: "string or callable", Actual: vals.Kind(cmd)}
+	}
+	outputs, err := fm.CaptureOutput(func(fm *eval.Frame) error {
+		return callable.Call(fm, args, eval.NoOpts)
+	})
+	if err != nil {
+		return err
+	}
+	lines := make([]string, len(outputs))
+	for i, output := range outputs {
+		line, ok := output.(string)
+		if !ok {
+			return errs.BadValue{What: "output of command",
+				Valid: "string", Actual: vals.Kind(output)}
+		}
+		lines[i] = line
+	}
+
+	l, err := parse(strings.Join(lines, "\n"))
+	if err != nil {
+		return err
+	}
+	in, err := newInterp()
+	if err != nil {
+		return err
+	}
+	in.envOnly = true
+	if err := in.runList(l, stdio{fm.InputFile(), fm.Port(1).File, fm.ErrorFile()}); err != nil {
+		return err
+	}
+	return in.apply(fm, in.dir)
+}
+
+// Applies the changes to the environment and the working directory to the
+// process.
+func (in *interp) apply(fm *eval.Frame, oldDir string) error {
+	env := environ()
+	for name, v := range in.vars {
+		if name == "PWD" {
+			// Updated by Chdir.
+			continue
+		}
+		if v.set && v.exported {
+			if value, ok := env[name]; !ok || value != v.value {
+				os.Setenv(name, v.value)
+			}
+		} else if _, ok := env[name]; ok {
+			os.Unsetenv(name)
+		}
+	}
+	if in.dir != oldDir {
+		return fm.Evaler.Chdir(in.dir)
+	}
+	return nil
+}
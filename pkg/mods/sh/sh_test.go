@@ -0,0 +1,118 @@
+package sh
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestEval_Builtins(t *testing.T) {
+	testutil.Unsetenv(t, "SH_X")
+	testutil.Unsetenv(t, "SH_Y")
+	testutil.Setenv(t, "SH_Z", "z")
+
+	TestWithSetup(t, setup,
+		// Exported variables are applied to the environment; unexported ones
+		// are not.
+		That("sh:eval 'SH_X=foo; export SH_X; SH_Y=bar'; put $E:SH_X; has-env SH_Y").
+			Puts("foo", false),
+		That("sh:eval 'export SH_X=\"a b\"'; put $E:SH_X").Puts("a b"),
+		// Assigning to a variable from the environment changes it.
+		That("sh:eval 'SH_Z=new'; put $E:SH_Z").Puts("new"),
+		That("sh:eval 'unset SH_Z'; has-env SH_Z").Puts(false),
+		// Expansions.
+		That("sh:eval 'export SH_X=${SH_UNSET:-\"default\"}${#SH_UNSET}'; put $E:SH_X").
+			Puts("default0"),
+		That("sh:eval 'A=a; export SH_X=${A:+set}${B+set}'; put $E:SH_X").Puts("set"),
+		That("sh:eval ': ${A:=x}; export SH_X=$A'; put $E:SH_X").Puts("x"),
+		// eval runs code in the same interpreter.
+		That("sh:eval 'eval \"SH_X=evaled; export SH_X\"'; put $E:SH_X").Puts("evaled"),
+
+		// Exit status.
+		That("sh:eval 'false || true'").DoesNothing(),
+		That("sh:eval 'true && false'").Throws(ExitError{1}),
+		That("sh:eval '! true'").Throws(ExitError{1}),
+		That("sh:eval 'false && true; exit'").Throws(ExitError{1}),
+		That("sh:eval 'exit 3; export SH_Y=1'; has-env SH_Y").Throws(ExitError{3}),
+		That("sh:eval 'false; true'").DoesNothing(),
+		That("sh:eval 'echo ${A:?need A}'").Throws(ErrorWithMessage("A: need A")),
+
+		// Unsupported syntax.
+		That("sh:eval 'for x in a; do :; done'").
+			Throws(unsupportedError{"for"}),
+		That("sh:eval 'true &'").Throws(unsupportedError{"background job &"}),
+		That("sh:eval 'echo `pwd`'").
+			Throws(unsupportedError{"command substitution with backquotes"}),
+		That("sh:eval 'echo $((1+2))'").
+			Throws(unsupportedError{"arithmetic expansion $((...))"}),
+		That("sh:eval 'cat <<EOF'").Throws(unsupportedError{"here-document <<"}),
+		That("sh:eval '(true)'").Throws(unsupportedError{"subshell (...)"}),
+		That("sh:eval 'echo $1'").Throws(unsupportedError{"special parameter $1"}),
+		That("sh:eval 'echo ${A%b}'").
+			Throws(unsupportedError{"parameter expansion operator %"}),
+		// Unsupported syntax is rejected before anything runs.
+		That("sh:eval 'export SH_Y=1; if true; then :; fi'; has-env SH_Y").
+			Throws(unsupportedError{"if"}),
+
+		// Syntax errors.
+		That("sh:eval 'echo \"foo'").Throws(
+			syntaxError{9, "unterminated double-quoted string"}),
+		That("sh:eval 'true &&'").Throws(syntaxError{7, "missing command"}),
+		That("sh:eval 'echo >'").Throws(syntaxError{6, "missing target of redirection >"}),
+	)
+}
+
+func TestImportEnv(t *testing.T) {
+	testutil.Unsetenv(t, "SH_X")
+	testutil.Unsetenv(t, "SH_Y")
+	testutil.Setenv(t, "SH_Z", "z")
+
+	TestWithSetup(t, setup,
+		That(`sh:import-env { echo 'SH_X=x; export SH_X;'; echo 'echo Agent pid 1;' }`+
+			`; put $E:SH_X`).Puts("x"),
+		That(`sh:import-env { put 'export SH_X="$SH_Z y"' 'unset SH_Z' }`+
+			`; put $E:SH_X; has-env SH_Z`).Puts("z y", false),
+		// Commands other than the builtins that change variables are not run.
+		That(`sh:import-env { echo 'echo hi; cd /; export SH_Y=1' }; put $E:SH_Y`).
+			Puts("1"),
+		That(`sh:import-env { echo 'export SH_X=$(echo y)' }`).
+			Throws(errCmdSubstInEnv),
+		That(`sh:import-env { put [] }`).Throws(
+			errs.BadValue{What: "output of command", Valid: "string", Actual: "list"}),
+		That(`sh:import-env [] `).Throws(
+			errs.BadValue{What: "command", Valid: "string or callable", Actual: "list"}),
+		// Errors from the command are propagated.
+		That(`sh:import-env { fail foo }`).Throws(eval.FailError{Content: "foo"}),
+	)
+}
+
+func TestEval_Cd(t *testing.T) {
+	testutil.Unsetenv(t, "SH_X")
+	dir := testutil.InTempDir(t)
+	os.Mkdir("d", 0700)
+	var chdirs []string
+	TestWithSetup(t, func(ev *eval.Evaler) {
+		setup(ev)
+		ev.AfterChdir = append(ev.AfterChdir, func(e eval.ChdirEvent) {
+			chdirs = append(chdirs, e.Path)
+		})
+	},
+		That("sh:eval 'cd d && export SH_X=$PWD'").DoesNothing(),
+	)
+	wantDir := filepath.Join(dir, "d")
+	if len(chdirs) != 1 || chdirs[0] != wantDir {
+		t.Errorf("got chdirs %v, want [%v]", chdirs, wantDir)
+	}
+	if got := os.Getenv("SH_X"); got != wantDir {
+		t.Errorf("got $SH_X %q, want %q", got, wantDir)
+	}
+}
+
+func setup(ev *eval.Evaler) {
+	ev.ExtendGlobal(eval.BuildNs().AddNs("sh", Ns))
+}
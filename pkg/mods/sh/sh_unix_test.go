@@ -0,0 +1,73 @@
+//go:build !windows && !plan9 && !js
+
+package sh
+
+import (
+	"os"
+	"testing"
+
+	"src.elv.sh/pkg/eval"
+	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/must"
+	elvparse "src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestEval_ExternalCommands(t *testing.T) {
+	testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{"a.txt": "", "b.txt": "", ".c.txt": "", "d": ""})
+
+	TestWithSetup(t, setup,
+		That("sh:eval 'echo hello world'").Prints("hello world\n"),
+		// Quoting and field splitting.
+		That(`sh:eval 'A="x  y"; printf "[%s]" $A "$A" ${B:-"q r"} "" \$A; echo'`).
+			Prints("[x][y][x  y][q r][][$A]\n"),
+		That(`sh:eval 'IFS=:; A=x:y; printf "[%s]" $A; echo'`).Prints("[x][y]\n"),
+		// Pathname expansion.
+		That("sh:eval 'echo *.txt \"*\".txt *.none'").Prints("a.txt b.txt *.txt *.none\n"),
+		// Tilde expansion.
+		That("sh:eval 'HOME=/home/elf; echo ~ ~/x \"~\"'").Prints("/home/elf /home/elf/x ~\n"),
+		// Assignments before a command only apply to the command.
+		That("sh:eval 'A=1 sh -c \"echo \\$A\"; echo \"[$A]\"'").Prints("1\n[]\n"),
+		// Pipelines and command substitution.
+		That("sh:eval 'echo foo | tr a-z A-Z'").Prints("FOO\n"),
+		That("sh:eval 'false | true'").DoesNothing(),
+		That("sh:eval 'true | false'").Throws(ExitError{1}),
+		That(`sh:eval 'A=$(echo foo; echo bar); echo "$A"; echo $(echo x)y'`).
+			Prints("foo\nbar\nxy\n"),
+		That(`sh:eval 'echo $?; false; echo $?'`).Prints("0\n1\n"),
+		// Redirections.
+		That("sh:eval 'echo foo > out; echo bar >> out; cat < out'; slurp < out").
+			Prints("foo\nbar\n").Puts("foo\nbar\n"),
+		That("sh:eval 'echo err >&2 2>/dev/null'").PrintsStderrWith("err"),
+		That("sh:eval 'echo err 2>/dev/null >&2'").DoesNothing(),
+		That("sh:eval 'cat < nonexistent'").Throws(ExitError{1}).
+			PrintsStderrWith("nonexistent"),
+		// Command not found.
+		That("sh:eval 'nonexistent-command'").Throws(ExitError{127}).
+			PrintsStderrWith("sh: nonexistent-command: not found"),
+	)
+}
+
+func TestEval_DryRun(t *testing.T) {
+	testutil.InTempDir(t)
+	r, w := must.Pipe()
+	ev := eval.NewEvaler()
+	setup(ev)
+	err := ev.Eval(elvparse.Source{
+		Name: "[test]",
+		Code: "sh:eval 'A=\"a b\"; touch \"$A\" $(echo c); echo foo >&2'",
+	}, eval.EvalCfg{Ports: []*eval.Port{nil, nil, {File: w, Chan: eval.BlackholeChan}}, DryRun: true})
+	w.Close()
+	stderr := string(must.ReadAllAndClose(r))
+
+	if err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+	if want := "echo c\ntouch 'a b'\necho foo\n"; stderr != want {
+		t.Errorf("got stderr %q, want %q", stderr, want)
+	}
+	if _, err := os.Stat("a b"); err == nil {
+		t.Errorf("file created in dry-run mode")
+	}
+}
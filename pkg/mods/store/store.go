@@ -2,8 +2,17 @@ package store
 
 import (
 	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"strings"
 
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/histsync"
 	"src.elv.sh/pkg/store/storedefs"
 )
 
@@ -21,9 +30,202 @@ func Ns(s storedefs.Store) *eval.Ns {
 			"add-dir": func(dir string) error { return s.AddDir(dir, 1) },
 			"del-dir": s.DelDir,
 			"dirs":    func() ([]storedefs.Dir, error) { return s.Dirs(storedefs.NoBlacklist) },
+
+			"get":       func(ns, key string) (any, error) { return get(s, ns, key) },
+			"get-entry": func(ns, key string) (entry, error) { return getEntry(s, ns, key) },
+			"set": func(opts versionOpts, ns, key string, value any) error {
+				return set(s, opts, ns, key, value)
+			},
+			"del": func(opts versionOpts, ns, key string) error {
+				return del(s, opts, ns, key)
+			},
+			"keys": func(fm *eval.Frame, ns string) error { return keys(fm, s, ns) },
+
+			"sync-history": func(opts tokenOpts, target string) (histsync.Result, error) {
+				r, err := histsync.NewRemote(target, opts.Token)
+				if err != nil {
+					return histsync.Result{}, err
+				}
+				return histsync.Sync(s, r)
+			},
+			"serve-history": func(fm *eval.Frame, opts tokenOpts, addr string) error {
+				return serveHistory(fm, s, opts.Token, addr)
+			},
 		}).Ns()
 }
 
+// An entry in the key-value store, as output by store:get-entry.
+type entry struct {
+	Value   any
+	Version int
+}
+
+func (entry) IsStructMap() {}
+
+type versionOpts struct{ Version int }
+
+func (opts *versionOpts) SetDefaultOptions() { opts.Version = storedefs.AnyVersion }
+
+func get(s storedefs.Store, ns, key string) (any, error) {
+	e, err := getEntry(s, ns, key)
+	if err != nil {
+		return nil, err
+	}
+	if e.Version == 0 {
+		return nil, vals.NoSuchKey(key)
+	}
+	return e.Value, nil
+}
+
+func getEntry(s storedefs.Store, ns, key string) (entry, error) {
+	if err := checkNsAndKey(ns, key); err != nil {
+		return entry{}, err
+	}
+	v, err := s.Value(ns, key)
+	if err != nil || v.Version == 0 {
+		return entry{}, err
+	}
+	var data any
+	decoder := json.NewDecoder(strings.NewReader(v.Data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return entry{}, err
+	}
+	value, err := eval.FromJSONInterface(data)
+	return entry{value, v.Version}, err
+}
+
+func set(s storedefs.Store, opts versionOpts, ns, key string, value any) error {
+	if err := checkNsAndKey(ns, key); err != nil {
+		return err
+	}
+	jsonValue, err := toJSONValue(value)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(jsonValue)
+	if err != nil {
+		return err
+	}
+	return s.SetValue(ns, key, string(data), opts.Version)
+}
+
+func del(s storedefs.Store, opts versionOpts, ns, key string) error {
+	if err := checkNsAndKey(ns, key); err != nil {
+		return err
+	}
+	return s.DelValue(ns, key, opts.Version)
+}
+
+func keys(fm *eval.Frame, s storedefs.Store, ns string) error {
+	if ns == "" {
+		return errEmptyNs
+	}
+	keys, err := s.ValueKeys(ns)
+	if err != nil {
+		return err
+	}
+	out := fm.ValueOutput()
+	for _, key := range keys {
+		if err := out.Put(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type tokenOpts struct{ Token string }
+
+func (*tokenOpts) SetDefaultOptions() {}
+
+// Serves the command history for syncing on addr until interrupted.
+func serveHistory(fm *eval.Frame, s storedefs.Store, token, addr string) error {
+	l, err := histsync.Listen(addr, token)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		token, err = histsync.NewToken()
+		if err != nil {
+			l.Close()
+			return err
+		}
+		fmt.Fprintf(fm.ErrorFile(), "Serving history on %s with token %s\n", l.Addr(), token)
+	}
+	server := &http.Server{Handler: histsync.Handler(s, token)}
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.Serve(l) }()
+	select {
+	case <-fm.Interrupts():
+		server.Close()
+		<-errCh
+		return eval.ErrInterrupted
+	case err := <-errCh:
+		return err
+	}
+}
+
+var (
+	errEmptyNs  = errs.BadValue{What: "namespace", Valid: "non-empty string", Actual: "empty"}
+	errEmptyKey = errs.BadValue{What: "key", Valid: "non-empty string", Actual: "empty"}
+)
+
+func checkNsAndKey(ns, key string) error {
+	if ns == "" {
+		return errEmptyNs
+	}
+	if key == "" {
+		return errEmptyKey
+	}
+	return nil
+}
+
+// Converts v to a value that survives a round trip through JSON, or returns an
+// error if v contains values that don't. Floating-point numbers are encoded
+// the same way Elvish prints them, like 1.0, so that they are not read back as
+// integers.
+func toJSONValue(v any) (any, error) {
+	switch v := v.(type) {
+	case nil, bool, string, int, *big.Int:
+		return v, nil
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, errs.BadValue{What: "number to store",
+				Valid: "finite number", Actual: vals.ReprPlain(v)}
+		}
+		return json.Number(vals.ToString(v)), nil
+	case vals.List:
+		list := make([]any, 0, v.Len())
+		for it := v.Iterator(); it.HasElem(); it.Next() {
+			elem, err := toJSONValue(it.Elem())
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, elem)
+		}
+		return list, nil
+	case vals.Map:
+		m := make(map[string]any, v.Len())
+		for it := v.Iterator(); it.HasElem(); it.Next() {
+			k, elem := it.Elem()
+			ks, ok := k.(string)
+			if !ok {
+				return nil, errs.BadValue{What: "map key to store",
+					Valid: "string", Actual: vals.ReprPlain(k)}
+			}
+			elem, err := toJSONValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			m[ks] = elem
+		}
+		return m, nil
+	default:
+		return nil, errs.BadValue{What: "value to store",
+			Valid: "nil, bool, string, number, list or map", Actual: vals.Kind(v)}
+	}
+}
+
 // DElvCode contains the content of the .d.elv file for this module.
 //
 //go:embed *.d.elv
@@ -1,10 +1,14 @@
 package store
 
 import (
+	"net"
 	"testing"
 
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
 	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/histsync"
 	"src.elv.sh/pkg/store"
 	"src.elv.sh/pkg/store/storedefs"
 	"src.elv.sh/pkg/testutil"
@@ -49,8 +53,80 @@ func TestStore(t *testing.T) {
 		That("store:del-dir /foo").DoesNothing(),
 		That("store:dirs").Puts(
 			dir("/bar", store.DirScoreIncrement)),
+
+		// Set values
+		That("store:set ns foo [a [&k=(num 1)] $true $nil]").DoesNothing(),
+		That("store:set ns bar (num 10)").DoesNothing(),
+		That("store:set other foo text").DoesNothing(),
+		// Query values
+		That("store:get ns foo").Puts(vals.MakeList("a", vals.MakeMap("k", 1), true, nil)),
+		That("store:get ns bar").Puts(10),
+		That("store:get other foo").Puts("text"),
+		That("store:get ns lorem").Throws(vals.NoSuchKey("lorem")),
+		That("store:get-entry ns lorem").Puts(entry{nil, 0}),
+		That("store:keys ns").Puts("bar", "foo"),
+		// Numbers keep their types
+		That("store:set nums big (num 100000000000000000000)", "store:get nums big").
+			Puts(vals.ParseNum("100000000000000000000")),
+		That("store:set nums floats [(num 1.0) (num 1e300)]", "store:get nums floats").
+			Puts(vals.MakeList(1.0, 1e300)),
+		// Delete values
+		That("store:del ns foo").DoesNothing(),
+		That("store:del ns foo").DoesNothing(),
+		That("store:keys ns").Puts("bar"),
+
+		// Compare-and-swap
+		That(
+			"var e = (store:get-entry ns bar)",
+			"store:set &version=$e[version] ns bar (+ $e[value] 1)",
+			"store:get ns bar",
+			"store:set &version=$e[version] ns bar (num 0)",
+		).Puts(11).Throws(ErrorWithMessage(storedefs.ErrVersionMismatch.Error())),
+		That("store:get ns bar").Puts(11),
+		That("store:set &version=(num 0) ns new value", "store:get ns new").Puts("value"),
+		That("store:set &version=(num 0) ns new value").
+			Throws(ErrorWithMessage(storedefs.ErrVersionMismatch.Error())),
+		That("store:del &version=(num 12345) ns new").
+			Throws(ErrorWithMessage(storedefs.ErrVersionMismatch.Error())),
+
+		// Bad values
+		That("store:set ns foo { }").Throws(errs.BadValue{What: "value to store",
+			Valid: "nil, bool, string, number, list or map", Actual: "fn"}),
+		That("store:set ns foo [&[]=x]").Throws(errs.BadValue{What: "map key to store",
+			Valid: "string", Actual: "[]"}),
+		That("store:set ns foo (num inf)").Throws(errs.BadValue{What: "number to store",
+			Valid: "finite number", Actual: "(num +Inf)"}),
+		That("store:get '' foo").Throws(errs.BadValue{What: "namespace",
+			Valid: "non-empty string", Actual: "empty"}),
+		That("store:set ns '' x").Throws(errs.BadValue{What: "key",
+			Valid: "non-empty string", Actual: "empty"}),
+		That("store:keys ''").Throws(errs.BadValue{What: "namespace",
+			Valid: "non-empty string", Actual: "empty"}),
 	)
 }
 
 func cmd(s string, i int) storedefs.Cmd     { return storedefs.Cmd{Text: s, Seq: i} }
 func dir(s string, f float64) storedefs.Dir { return storedefs.Dir{Path: s, Score: f} }
+
+func TestSyncHistory(t *testing.T) {
+	testutil.InTempDir(t)
+	a, b := store.MustTempStore(t), store.MustTempStore(t)
+
+	setup := func(ev *eval.Evaler) {
+		ev.ExtendGlobal(eval.BuildNs().AddNs("a", Ns(a)).AddNs("b", Ns(b)))
+	}
+	TestWithSetup(t, setup,
+		That("a:add-cmd foo", "b:add-cmd bar").Puts(1, 1),
+		That("a:sync-history hist.json").Puts(histsync.Result{Pulled: 0, Pushed: 1}),
+		That("b:sync-history hist.json").Puts(histsync.Result{Pulled: 1, Pushed: 1}),
+		That("a:sync-history hist.json").Puts(histsync.Result{Pulled: 1, Pushed: 0}),
+		That("a:cmds 1 -1").Puts(cmd("foo", 1), cmd("bar", 2)),
+		That("b:cmds 1 -1").Puts(cmd("bar", 1), cmd("foo", 2)),
+
+		That("a:sync-history &token=secret http://example.com/hist.json").
+			Throws(histsync.ErrInsecureToken),
+
+		That("a:serve-history no-port").Throws(ErrorWithType(&net.OpError{})),
+		That("a:serve-history :0").Throws(histsync.ErrTokenRequired),
+	)
+}
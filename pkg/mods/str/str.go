@@ -5,6 +5,8 @@ package str
 import (
 	"bytes"
 	_ "embed"
+	"encoding/base64"
+	enchex "encoding/hex"
 	"fmt"
 	"strconv"
 	"strings"
@@ -14,6 +16,7 @@ import (
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
 )
 
 var Ns = eval.BuildNsNamed("str").
@@ -24,6 +27,7 @@ var Ns = eval.BuildNsNamed("str").
 		"count":        strings.Count,
 		"equal-fold":   strings.EqualFold,
 		// TODO: Fields, FieldsFunc
+		"from-bytes":      fromBytes,
 		"from-codepoints": fromCodepoints,
 		"from-utf8-bytes": fromUtf8Bytes,
 		"has-prefix":      strings.HasPrefix,
@@ -40,6 +44,7 @@ var Ns = eval.BuildNsNamed("str").
 		//lint:ignore SA1019 Elvish builtins need to be formally deprecated
 		// before removal
 		"title":         strings.Title,
+		"to-bytes":      toBytes,
 		"to-codepoints": toCodepoints,
 		"to-lower":      strings.ToLower,
 		"to-title":      strings.ToTitle,
@@ -173,3 +178,68 @@ func toUtf8Bytes(fm *eval.Frame, s string) error {
 	}
 	return nil
 }
+
+type encodingOpt struct{ Encoding string }
+
+func (o *encodingOpt) SetDefaultOptions() { o.Encoding = "utf-8" }
+
+const validEncodings = "utf-8, latin-1, hex, base64"
+
+func badEncoding(enc string) error {
+	return errs.BadValue{What: "encoding", Valid: validEncodings, Actual: parse.Quote(enc)}
+}
+
+func toBytes(opts encodingOpt, s string) (vals.Bytes, error) {
+	bad := errs.BadValue{What: "argument to str:to-bytes",
+		Valid: opts.Encoding + " string", Actual: parse.Quote(s)}
+	switch opts.Encoding {
+	case "utf-8":
+		return vals.Bytes(s), nil
+	case "latin-1":
+		b := make([]byte, 0, len(s))
+		for _, r := range s {
+			if r > 0xff {
+				return "", bad
+			}
+			b = append(b, byte(r))
+		}
+		return vals.Bytes(b), nil
+	case "hex":
+		b, err := enchex.DecodeString(s)
+		if err != nil {
+			return "", bad
+		}
+		return vals.Bytes(b), nil
+	case "base64":
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return "", bad
+		}
+		return vals.Bytes(b), nil
+	default:
+		return "", badEncoding(opts.Encoding)
+	}
+}
+
+func fromBytes(opts encodingOpt, b vals.Bytes) (string, error) {
+	switch opts.Encoding {
+	case "utf-8":
+		if !utf8.ValidString(string(b)) {
+			return "", errs.BadValue{What: "argument to str:from-bytes",
+				Valid: "valid UTF-8 sequence", Actual: vals.ReprPlain(b)}
+		}
+		return string(b), nil
+	case "latin-1":
+		var sb strings.Builder
+		for i := 0; i < len(b); i++ {
+			sb.WriteRune(rune(b[i]))
+		}
+		return sb.String(), nil
+	case "hex":
+		return enchex.EncodeToString([]byte(b)), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString([]byte(b)), nil
+	default:
+		return "", badEncoding(opts.Encoding)
+	}
+}
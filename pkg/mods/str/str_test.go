@@ -9,6 +9,7 @@ import (
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/eval/errs"
 	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/eval/vals"
 )
 
 func TestStr(t *testing.T) {
@@ -35,6 +36,19 @@ func TestStr(t *testing.T) {
 		That(`str:equal-fold abc ABC`).Puts(true),
 		That(`str:equal-fold abc A`).Puts(false),
 
+		That(`str:from-bytes (str:to-bytes abc)`).Puts("abc"),
+		That(`str:from-bytes (str:to-bytes "\xff")`).Throws(errs.BadValue{
+			What:   "argument to str:from-bytes",
+			Valid:  "valid UTF-8 sequence",
+			Actual: `(str:to-bytes "\xff")`}),
+		That(`str:from-bytes &encoding=latin-1 (str:to-bytes "\xe9")`).Puts("é"),
+		That(`str:from-bytes &encoding=hex (str:to-bytes "\x01\xff")`).Puts("01ff"),
+		That(`str:from-bytes &encoding=base64 (str:to-bytes "a\xff")`).Puts("Yf8="),
+		That(`str:from-bytes abc`).Throws(
+			ErrorWithMessage("wrong type for arg #0: wrong type: need bytes, got string")),
+		That(`str:from-bytes &encoding=utf-16 (str:to-bytes abc)`).Throws(errs.BadValue{
+			What: "encoding", Valid: "utf-8, latin-1, hex, base64", Actual: "utf-16"}),
+
 		That(`str:from-codepoints 0x61`).Puts("a"),
 		That(`str:from-codepoints 0x4f60 0x597d`).Puts("你好"),
 		That(`str:from-codepoints -0x1`).Throws(errs.OutOfRange{
@@ -104,6 +118,32 @@ func TestStr(t *testing.T) {
 		That(`str:to-codepoints 你好 | str:from-codepoints (all)`).Puts("你好"),
 		That(`str:to-codepoints a >&-`).Throws(eval.ErrPortDoesNotSupportValueOutput),
 
+		That(`str:to-bytes "a\xff"`).Puts(vals.Bytes("a\xff")),
+		That(`str:to-bytes &encoding=latin-1 é`).Puts(vals.Bytes("\xe9")),
+		That(`str:to-bytes &encoding=latin-1 你`).Throws(errs.BadValue{
+			What:   "argument to str:to-bytes",
+			Valid:  "latin-1 string",
+			Actual: "你"}),
+		That(`str:to-bytes &encoding=hex 01ff`).Puts(vals.Bytes("\x01\xff")),
+		That(`str:to-bytes &encoding=hex 0g`).Throws(ErrorWithType(errs.BadValue{})),
+		That(`str:to-bytes &encoding=base64 Yf8=`).Puts(vals.Bytes("a\xff")),
+		That(`str:to-bytes &encoding=base64 Yf8`).Throws(ErrorWithType(errs.BadValue{})),
+		That(`str:to-bytes &encoding=utf-16 abc`).Throws(errs.BadValue{
+			What: "encoding", Valid: "utf-8, latin-1, hex, base64", Actual: "utf-16"}),
+
+		// Operations on bytes.
+		That(`var b = (str:to-bytes "\xe4\xbd\xa0x")`,
+			`put (count $b) $b[0] $b[1..3] (kind-of $b)`).
+			Puts(4, 0xe4, vals.Bytes("\xbd\xa0"), "bytes"),
+		That(`put (str:to-bytes a)(str:to-bytes b)`).Puts(vals.Bytes("ab")),
+		That(`put (str:to-bytes a)b`).Throws(
+			ErrorWithMessage("cannot concatenate bytes and string")),
+		That(`put [&(str:to-bytes a)=x][(str:to-bytes a)]`).Puts("x"),
+		That(`eq (str:to-bytes a) a`).Puts(false),
+		That(`compare (str:to-bytes a) (str:to-bytes b)`).Puts(-1),
+		That(`to-string (str:to-bytes "a\xff")`).Puts("a\xff"),
+		That(`put (str:to-bytes "a\xff") | to-json`).Prints(`"Yf8="`+"\n"),
+
 		That(`str:to-utf8-bytes a`).Puts("0x61"),
 		That(`str:to-utf8-bytes 你好`).Puts("0xe4", "0xbd", "0xa0", "0xe5", "0xa5", "0xbd"),
 		That(`str:to-utf8-bytes 你好 | str:from-utf8-bytes (all)`).Puts("你好"),
@@ -78,7 +78,7 @@ func initRlimits() {
 			// Since getrlimit should only ever return an error when the
 			// resource is not supported, this should normally never happen. But
 			// be defensive nonetheless.
-			logger.Println("initialize rlimits", res, rlimitKeys[res], err)
+			logger.Warnf("initialize rlimits %v %v: %v", res, rlimitKeys[res], err)
 			// Remove this key, so that rlimitKeys is always consistent with the
 			// value of rlimits (and thus $unix:rlimits).
 			delete(rlimitKeys, res)
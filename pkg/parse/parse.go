@@ -117,10 +117,13 @@ func (bn *Chunk) parseSeps(ps *parser) int {
 	return nseps
 }
 
-// Pipeline = Form { '|' Form }
+// Pipeline = Form { '|' { MapPair { Space } } Form }
 type Pipeline struct {
 	node
-	Forms      []*Form
+	Forms []*Form
+	// Options of the pipes, like &to=json in "put foo | &to=json cat". Each of
+	// them applies to the pipe before the first form after it.
+	PipeOpts   []*MapPair
 	Background bool
 }
 
@@ -128,6 +131,10 @@ func (pn *Pipeline) parse(ps *parser) {
 	ps.parse(&Form{}).addTo(&pn.Forms, pn)
 	for parseSep(pn, ps, '|') {
 		parseSpacesAndNewlines(pn, ps)
+		for ps.peek() == '&' {
+			ps.parse(&MapPair{}).addTo(&pn.PipeOpts, pn)
+			parseSpacesAndNewlines(pn, ps)
+		}
 		if !startsForm(ps.peek()) {
 			ps.error(errShouldBeForm)
 			return
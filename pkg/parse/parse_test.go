@@ -54,6 +54,14 @@ var testCases = []struct {
 		node: &Pipeline{},
 		want: ast{"Pipeline", fs{"Forms": []string{"a", "b"}}},
 	},
+	{
+		name: "pipe options",
+		code: "a | &to=json &x=y b | c",
+		node: &Pipeline{},
+		want: ast{"Pipeline", fs{
+			"Forms":    []string{"a ", "b ", "c"},
+			"PipeOpts": []string{"&to=json", "&x=y"}}},
+	},
 
 	{
 		name:         "no form after pipe",
@@ -62,6 +70,13 @@ var testCases = []struct {
 		wantErrAtEnd: true,
 		wantErrMsg:   "should be form",
 	},
+	{
+		name:         "no form after pipe options",
+		code:         "a | &to=json",
+		node:         &Chunk{},
+		wantErrAtEnd: true,
+		wantErrMsg:   "should be form",
+	},
 
 	// Form
 	{
@@ -31,3 +31,84 @@ func isCompound(n parse.Node) bool {
 	_, ok := n.(*parse.Compound)
 	return ok
 }
+
+// PipelineAt returns the innermost pipeline in the tree rooted at n that
+// contains the byte at pos, or nil if there is none.
+func PipelineAt(n parse.Node, pos int) *parse.Pipeline {
+	for _, ch := range parse.Children(n) {
+		if rg := ch.Range(); rg.From <= pos && pos < rg.To {
+			if pn := PipelineAt(ch, pos); pn != nil {
+				return pn
+			}
+		}
+	}
+	pn, _ := n.(*parse.Pipeline)
+	return pn
+}
+
+// OpenBracketAt returns the innermost bracketed primary in the tree rooted at
+// n whose opening bracket is before pos and which is not closed before pos, or
+// nil if there is none.
+//
+// Bracketed primaries are output and exception captures, lists, lambdas, maps
+// and braced lists.
+func OpenBracketAt(n parse.Node, pos int) *parse.Primary {
+	for _, ch := range parse.Children(n) {
+		if rg := ch.Range(); rg.From <= pos && pos <= rg.To {
+			if pn := OpenBracketAt(ch, pos); pn != nil {
+				return pn
+			}
+		}
+	}
+	if pn, ok := n.(*parse.Primary); ok && isBracketed(pn) && pn.Range().From < pos {
+		if closer := closingBracket(pn); closer == nil || pos <= closer.Range().From {
+			return pn
+		}
+	}
+	return nil
+}
+
+// BracketClosedAt returns the bracketed primary in the tree rooted at n whose
+// closing bracket is at pos, or nil if there is none.
+func BracketClosedAt(n parse.Node, pos int) *parse.Primary {
+	if pn, ok := n.(*parse.Primary); ok && isBracketed(pn) {
+		if closer := closingBracket(pn); closer != nil && closer.Range().From == pos {
+			return pn
+		}
+	}
+	for _, ch := range parse.Children(n) {
+		if rg := ch.Range(); rg.From <= pos && pos < rg.To {
+			if pn := BracketClosedAt(ch, pos); pn != nil {
+				return pn
+			}
+		}
+	}
+	return nil
+}
+
+func isBracketed(pn *parse.Primary) bool {
+	switch pn.Type {
+	case parse.ExceptionCapture, parse.OutputCapture, parse.List, parse.Lambda,
+		parse.Map, parse.Braced:
+		return true
+	}
+	return false
+}
+
+// Returns the closing bracket of a bracketed primary, or nil if it is not
+// closed.
+func closingBracket(pn *parse.Primary) parse.Node {
+	children := parse.Children(pn)
+	if len(children) < 2 {
+		return nil
+	}
+	last := children[len(children)-1]
+	if _, ok := last.(*parse.Sep); !ok {
+		return nil
+	}
+	switch parse.SourceText(last) {
+	case ")", "]", "}":
+		return last
+	}
+	return nil
+}
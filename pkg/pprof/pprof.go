@@ -4,46 +4,89 @@ package pprof
 import (
 	"fmt"
 	"os"
+	"runtime"
 	"runtime/pprof"
+	"runtime/trace"
 
 	"src.elv.sh/pkg/prog"
 )
 
-// Program adds support for the -cpuprofile flag.
+// Program adds support for the -cpuprofile, -allocsprofile, -memprofile,
+// -blockprofile, -mutexprofile and -trace flags.
 type Program struct {
 	cpuProfile    string
 	allocsProfile string
+	memProfile    string
+	blockProfile  string
+	mutexProfile  string
+	trace         string
 }
 
 func (p *Program) RegisterFlags(f *prog.FlagSet) {
 	f.StringVar(&p.cpuProfile, "cpuprofile", "", "write CPU profile to file")
 	f.StringVar(&p.allocsProfile, "allocsprofile", "", "write memory allocation profile to file")
+	f.StringVar(&p.memProfile, "memprofile", "", "write heap profile to file")
+	f.StringVar(&p.blockProfile, "blockprofile", "", "write goroutine blocking profile to file")
+	f.StringVar(&p.mutexProfile, "mutexprofile", "", "write mutex contention profile to file")
+	f.StringVar(&p.trace, "trace", "", "write execution trace to file")
 }
 
 func (p *Program) Run(fds [3]*os.File, _ []string) error {
 	var cleanups []func([3]*os.File)
-	if p.cpuProfile != "" {
-		f, err := os.Create(p.cpuProfile)
-		if err != nil {
-			fmt.Fprintln(fds[2], "Warning: cannot create CPU profile:", err)
-			fmt.Fprintln(fds[2], "Continuing without CPU profiling.")
-		} else {
-			pprof.StartCPUProfile(f)
-			cleanups = append(cleanups, func([3]*os.File) { pprof.StopCPUProfile() })
+	// Creates the file at path if it is not empty, calls start with it, and
+	// arranges for stop to be called with it when the program finishes.
+	profile := func(path, name, doing string, start func(*os.File) error, stop func(*os.File)) {
+		if path == "" {
+			return
 		}
-	}
-	if p.allocsProfile != "" {
-		f, err := os.Create(p.allocsProfile)
-		if err != nil {
-			fmt.Fprintln(fds[2], "Warning: cannot create memory allocation profile:", err)
-			fmt.Fprintln(fds[2], "Continuing without memory allocation profiling.")
-		} else {
-			cleanups = append(cleanups, func([3]*os.File) {
-				pprof.Lookup("allocs").WriteTo(f, 0)
+		f, err := os.Create(path)
+		if err == nil && start != nil {
+			if err = start(f); err != nil {
 				f.Close()
-			})
+			}
 		}
+		if err != nil {
+			fmt.Fprintln(fds[2], "Warning: cannot create "+name+":", err)
+			fmt.Fprintln(fds[2], "Continuing without "+doing+".")
+			return
+		}
+		cleanups = append(cleanups, func([3]*os.File) {
+			stop(f)
+			f.Close()
+		})
 	}
+	// Returns a stop function that writes the named profile.
+	writeProfile := func(name string) func(*os.File) {
+		return func(f *os.File) { pprof.Lookup(name).WriteTo(f, 0) }
+	}
+
+	profile(p.cpuProfile, "CPU profile", "CPU profiling",
+		func(f *os.File) error { return pprof.StartCPUProfile(f) },
+		func(*os.File) { pprof.StopCPUProfile() })
+	profile(p.allocsProfile, "memory allocation profile", "memory allocation profiling",
+		nil, writeProfile("allocs"))
+	profile(p.memProfile, "heap profile", "heap profiling",
+		nil, func(f *os.File) {
+			// Make the profile reflect the live objects when the program
+			// finishes.
+			runtime.GC()
+			writeProfile("heap")(f)
+		})
+	profile(p.blockProfile, "blocking profile", "blocking profiling",
+		func(*os.File) error { runtime.SetBlockProfileRate(1); return nil },
+		func(f *os.File) {
+			writeProfile("block")(f)
+			runtime.SetBlockProfileRate(0)
+		})
+	profile(p.mutexProfile, "mutex profile", "mutex profiling",
+		func(*os.File) error { runtime.SetMutexProfileFraction(1); return nil },
+		func(f *os.File) {
+			writeProfile("mutex")(f)
+			runtime.SetMutexProfileFraction(0)
+		})
+	profile(p.trace, "execution trace", "tracing",
+		func(f *os.File) error { return trace.Start(f) },
+		func(*os.File) { trace.Stop() })
 	return prog.NextProgram(cleanups...)
 }
 
@@ -26,22 +26,39 @@ func TestProgram(t *testing.T) {
 		ThatElvish("-allocsprofile", "allocs").DoesNothing(),
 		ThatElvish("-allocsprofile", "bad/path").
 			WritesStderrContaining("Warning: cannot create memory allocation profile:"),
+
+		ThatElvish("-memprofile", "mem").DoesNothing(),
+		ThatElvish("-memprofile", "bad/path").
+			WritesStderrContaining("Warning: cannot create heap profile:"),
+
+		ThatElvish("-blockprofile", "block").DoesNothing(),
+		ThatElvish("-blockprofile", "bad/path").
+			WritesStderrContaining("Warning: cannot create blocking profile:"),
+
+		ThatElvish("-mutexprofile", "mutex").DoesNothing(),
+		ThatElvish("-mutexprofile", "bad/path").
+			WritesStderrContaining("Warning: cannot create mutex profile:"),
+
+		ThatElvish("-trace", "trace").DoesNothing(),
+		ThatElvish("-trace", "bad/path").
+			WritesStderrContaining("Warning: cannot create execution trace:"),
 	)
 
-	// Check for the effects of -cpuprofile and -allocsprofile. There isn't much
-	// that can be checked easily, so we only do a sanity check that the profile
-	// files exist and are non-empty.
-	checkFileIsNonEmpty(t, "cpu")
-	checkFileIsNonEmpty(t, "allocs")
+	// Check for the effects of the flags. There isn't much that can be checked
+	// easily, so we only do a sanity check that the profile files exist and
+	// are non-empty.
+	for _, name := range []string{"cpu", "allocs", "mem", "block", "mutex", "trace"} {
+		checkFileIsNonEmpty(t, name)
+	}
 }
 
 func checkFileIsNonEmpty(t *testing.T, name string) {
 	t.Helper()
 	stat, err := os.Stat(name)
 	if err != nil {
-		t.Errorf("CPU profile file does not exist: %v", err)
+		t.Errorf("profile file %s does not exist: %v", name, err)
 	} else if stat.Size() == 0 {
-		t.Errorf("CPU profile exists but is empty")
+		t.Errorf("profile file %s exists but is empty", name)
 	}
 }
 
@@ -6,6 +6,7 @@ type FlagSet struct {
 	*flag.FlagSet
 	daemonPaths *DaemonPaths
 	json        *bool
+	login       *bool
 }
 
 type DaemonPaths struct {
@@ -28,8 +29,22 @@ func (fs *FlagSet) JSON() *bool {
 	if fs.json == nil {
 		var json bool
 		fs.BoolVar(&json, "json", false,
-			"Show the output from -buildinfo, -compileonly or -version in JSON")
+			"Show the output from -buildinfo, -compileonly, -daemon-ctl status,\n-dump-config, -dump-default-bindings, -lint or -version in JSON")
 		fs.json = &json
 	}
 	return fs.json
 }
+
+// Login returns a pointer to the value of the -l and -login flags, which is
+// also set to true when Elvish is started as a login shell, with a name that
+// starts with "-".
+func (fs *FlagSet) Login() *bool {
+	if fs.login == nil {
+		var login bool
+		fs.BoolVar(&login, "l", false, "Same as -login")
+		fs.BoolVar(&login, "login", false,
+			"Run as a login shell, executing the login script before anything else")
+		fs.login = &login
+	}
+	return fs.login
+}
@@ -11,6 +11,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"sync"
 
 	"src.elv.sh/pkg/logutil"
 )
@@ -27,6 +29,33 @@ type Program interface {
 	Run(fds [3]*os.File, args []string) error
 }
 
+var (
+	registeredMutex sync.RWMutex
+	registered      []Program
+)
+
+// Register registers subprograms that Run tries before the program passed to
+// it, in the order they are registered.
+//
+// This is intended for custom builds of Elvish that include additional
+// subprograms. The package implementing the subprogram typically calls
+// Register in an init function, and the main package of the custom build
+// imports it:
+//
+//	package mysubprog
+//
+//	func init() {
+//		prog.Register(&Program{})
+//	}
+//
+// Like the subprograms in a Composite, a registered subprogram should return
+// NextProgram() when the flags that it handles are not given.
+func Register(programs ...Program) {
+	registeredMutex.Lock()
+	defer registeredMutex.Unlock()
+	registered = append(registered, programs...)
+}
+
 func usage(out io.Writer, fs *flag.FlagSet) {
 	fmt.Fprintln(out, "Usage: elvish [flags] [script] [args]")
 	fmt.Fprintln(out, "Supported flags:")
@@ -34,23 +63,40 @@ func usage(out io.Writer, fs *flag.FlagSet) {
 	fs.PrintDefaults()
 }
 
-// Run parses command-line flags and runs the first applicable subprogram. It
-// returns the exit status of the program.
+// Run parses command-line flags and runs the first applicable subprogram,
+// trying those added with Register before p. It returns the exit status of
+// the program.
 func Run(fds [3]*os.File, args []string, p Program) int {
+	registeredMutex.RLock()
+	if len(registered) > 0 {
+		p = Composite(append(append([]Program(nil), registered...), p)...)
+	}
+	registeredMutex.RUnlock()
+
 	fs := flag.NewFlagSet("elvish", flag.ContinueOnError)
 	// Error and usage will be printed explicitly.
 	fs.SetOutput(io.Discard)
 
-	var log string
+	var log, logLevel, logFormat string
 	var help bool
+	logConfig := logutil.DefaultConfig
 	fs.StringVar(&log, "log", "",
 		"Path to a file to write debug logs")
+	fs.StringVar(&logLevel, "log-level", logConfig.Level.String(),
+		"Minimum level of log messages to write: debug, info, warn or error")
+	fs.StringVar(&logFormat, "log-format", logConfig.Format.String(),
+		"Format of log messages: text or json")
+	fs.Int64Var(&logConfig.MaxSize, "log-max-size", logConfig.MaxSize,
+		"Size in bytes beyond which the log file is rotated; 0 to never rotate")
+	fs.IntVar(&logConfig.MaxBackups, "log-max-backups", logConfig.MaxBackups,
+		"Number of rotated log files to keep")
 	fs.BoolVar(&help, "help", false,
 		"Show usage help and quit")
 	fs.IntVar(&DeprecationLevel, "deprecation-level", DeprecationLevel,
 		"Show warnings for all features deprecated as of version 0.X")
 
-	p.RegisterFlags(&FlagSet{FlagSet: fs})
+	pfs := &FlagSet{FlagSet: fs}
+	p.RegisterFlags(pfs)
 
 	err := fs.Parse(args[1:])
 	if err != nil {
@@ -67,6 +113,23 @@ func Run(fds [3]*os.File, args []string, p Program) int {
 		return 2
 	}
 
+	// Programs like login(1) start login shells with a name starting with "-".
+	if pfs.login != nil && strings.HasPrefix(args[0], "-") {
+		*pfs.login = true
+	}
+
+	logConfig.Level, err = logutil.ParseLevel(logLevel)
+	if err == nil {
+		logConfig.Format, err = logutil.ParseFormat(logFormat)
+	}
+	if err != nil {
+		fmt.Fprintln(fds[2], err)
+		usage(fds[2], fs)
+		return 2
+	}
+	logutil.SetConfig(logConfig)
+	defer logutil.SetConfig(logutil.DefaultConfig)
+
 	if log != "" {
 		err = logutil.SetOutputFile(log)
 		if err == nil {
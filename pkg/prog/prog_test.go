@@ -36,6 +36,14 @@ func TestLogFlag(t *testing.T) {
 	Test(t, &testProgram{},
 		ThatElvish("-log", "log").DoesNothing(),
 		ThatElvish("-log", "bad/log").WritesStderrContaining("open bad/log:"),
+		ThatElvish("-log", "log", "-log-level", "debug", "-log-format", "json").
+			DoesNothing(),
+		ThatElvish("-log-level", "bad").
+			ExitsWith(2).
+			WritesStderrContaining(`bad log level "bad"`),
+		ThatElvish("-log-format", "bad").
+			ExitsWith(2).
+			WritesStderrContaining(`bad log format "bad"`),
 	)
 
 	_, err := os.Stat("log")
@@ -54,7 +62,7 @@ func TestCustomFlag(t *testing.T) {
 func TestSharedFlags(t *testing.T) {
 	Test(t, &testProgram{sharedFlags: true},
 		ThatElvish("-sock", "sock", "-db", "db", "-json").
-			WritesStdout("-sock sock -db db -json true\n"),
+			WritesStdout("-sock sock -db db -json true -login false\n"),
 	)
 }
 
@@ -64,10 +72,25 @@ func TestSharedFlags_MultiplePrograms(t *testing.T) {
 			&testProgram{sharedFlags: true, returnErr: NextProgram()},
 			&testProgram{sharedFlags: true}),
 		ThatElvish("-sock", "sock", "-db", "db", "-json").
-			WritesStdout("-sock sock -db db -json true\n"),
+			WritesStdout("-sock sock -db db -json true -login false\n"),
 	)
 }
 
+func TestSharedFlags_Login(t *testing.T) {
+	Test(t, &testProgram{sharedFlags: true},
+		ThatElvish("-l").
+			WritesStdout("-sock  -db  -json false -login true\n"),
+		ThatElvish("-login").
+			WritesStdout("-sock  -db  -json false -login true\n"),
+	)
+
+	// Started as a login shell
+	exit, stdout, _ := progtest.Run(&testProgram{sharedFlags: true}, "-elvish")
+	if want := "-sock  -db  -json false -login true\n"; exit != 0 || stdout != want {
+		t.Errorf("got (%v, %q), want (0, %q)", exit, stdout, want)
+	}
+}
+
 func TestShowDeprecations(t *testing.T) {
 	testutil.Set(t, &DeprecationLevel, 0)
 
@@ -151,6 +174,30 @@ func TestComposite_PreferEarlierSubprogram(t *testing.T) {
 	)
 }
 
+func TestRegister(t *testing.T) {
+	testutil.Set(t, Registered, nil)
+	Register(&testProgram{customFlag: true})
+
+	Test(t, &testProgram{writeOut: "main program"},
+		ThatElvish("-flag", "foo").WritesStdout("-flag foo\n"),
+		ThatElvish("-help").WritesStdoutContaining("-flag"),
+	)
+}
+
+func TestRegister_TriesProgramPassedToRunLast(t *testing.T) {
+	testutil.Set(t, Registered, nil)
+	Register(
+		&testProgram{returnErr: NextProgram(func(fds [3]*os.File) {
+			fds[1].WriteString("registered program cleanup\n")
+		})},
+		&testProgram{returnErr: NextProgram()})
+
+	Test(t, &testProgram{writeOut: "main program\n"},
+		ThatElvish().
+			WritesStdout("main program\nregistered program cleanup\n"),
+	)
+}
+
 func TestBadUsageError(t *testing.T) {
 	Test(t,
 		&testProgram{returnErr: BadUsage("lorem ipsum")},
@@ -179,6 +226,7 @@ type testProgram struct {
 	flag        string
 	daemonPaths *DaemonPaths
 	json        *bool
+	login       *bool
 }
 
 func (p *testProgram) RegisterFlags(f *FlagSet) {
@@ -188,6 +236,7 @@ func (p *testProgram) RegisterFlags(f *FlagSet) {
 	if p.sharedFlags {
 		p.daemonPaths = f.DaemonPaths()
 		p.json = f.JSON()
+		p.login = f.Login()
 	}
 }
 
@@ -200,8 +249,8 @@ func (p *testProgram) Run(fds [3]*os.File, args []string) error {
 		fmt.Fprintf(fds[1], "-flag %s\n", p.flag)
 	}
 	if p.sharedFlags {
-		fmt.Fprintf(fds[1], "-sock %s -db %s -json %v\n",
-			p.daemonPaths.Sock, p.daemonPaths.DB, *p.json)
+		fmt.Fprintf(fds[1], "-sock %s -db %s -json %v -login %v\n",
+			p.daemonPaths.Sock, p.daemonPaths.DB, *p.json, *p.login)
 	}
 	return nil
 }
@@ -0,0 +1,4 @@
+package prog
+
+// Pointers to variables that can be mutated for testing.
+var Registered = &registered
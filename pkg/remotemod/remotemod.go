@@ -0,0 +1,233 @@
+// Package remotemod implements loading of remote modules, which are Elvish
+// modules fetched over HTTPS, with use specs like https://example.com/mod.elv.
+//
+// A remote module must be trusted before it is loaded for the first time. The
+// SHA-256 checksums of trusted modules are recorded in a sums file, one
+// "<url> <checksum>" pair per line, and their source code is cached, so that
+// they are only fetched again when the cache is missing or corrupted. A module
+// whose content no longer matches the recorded checksum is never loaded; to
+// update a module, remove its line from the sums file.
+package remotemod
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Config keeps configurations for a Loader.
+type Config struct {
+	// The directory to store the sums file and the cached modules in. It is
+	// created when needed.
+	Dir string
+	// The HTTP client to fetch modules with. Default is a client with a
+	// timeout of 30 seconds. In either case, redirects to URLs that don't use
+	// HTTPS are rejected.
+	Client *http.Client
+	// The function to ask whether to trust a module that is not trusted yet,
+	// given its URL and SHA-256 checksum. Default always returns false.
+	Trust func(url, sum string) (bool, error)
+}
+
+// Remote modules larger than this are rejected.
+const maxModuleSize = 1 << 20
+
+// SumsFile is the name of the sums file in the directory of a Loader.
+const SumsFile = "sums"
+
+// Loader loads remote modules. It is safe for concurrent use.
+type Loader struct {
+	cfg   Config
+	mutex sync.Mutex
+}
+
+// New makes a new Loader.
+func New(cfg Config) *Loader {
+	client := &http.Client{Timeout: 30 * time.Second}
+	if cfg.Client != nil {
+		c := *cfg.Client
+		client = &c
+	}
+	checkRedirect := client.CheckRedirect
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if req.URL.Scheme != "https" {
+			return fmt.Errorf("redirect to non-HTTPS URL %s", req.URL)
+		}
+		if checkRedirect != nil {
+			return checkRedirect(req, via)
+		}
+		// The default policy of http.Client.
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	cfg.Client = client
+	if cfg.Trust == nil {
+		cfg.Trust = func(string, string) (bool, error) { return false, nil }
+	}
+	return &Loader{cfg: cfg}
+}
+
+// NotTrusted is returned by Loader.Load when a module is not trusted.
+type NotTrusted struct {
+	URL      string
+	Sum      string
+	SumsPath string
+}
+
+// Error implements the error interface.
+func (err NotTrusted) Error() string {
+	return fmt.Sprintf("remote module %s is not trusted; to trust it, add %q to %s",
+		err.URL, err.URL+" "+err.Sum, err.SumsPath)
+}
+
+// ChecksumMismatch is returned by Loader.Load when the content of a module
+// doesn't match its recorded checksum.
+type ChecksumMismatch struct {
+	URL      string
+	Want     string
+	Got      string
+	SumsPath string
+}
+
+// Error implements the error interface.
+func (err ChecksumMismatch) Error() string {
+	return fmt.Sprintf("remote module %s has changed: checksum is %s, %s has %s",
+		err.URL, err.Got, err.SumsPath, err.Want)
+}
+
+// Load returns the source code of the module at the given URL, which must use
+// HTTPS.
+//
+// If the module is trusted and cached, the cached code is returned without
+// fetching it. Otherwise the module is fetched: if it is trusted, its checksum
+// must match the recorded one; if not, Config.Trust is asked to trust it and
+// its checksum is recorded.
+func (l *Loader) Load(url string) (string, error) {
+	if !strings.HasPrefix(url, "https://") {
+		return "", errors.New("remote modules must be loaded over HTTPS")
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	sums, err := l.readSums()
+	if err != nil {
+		return "", err
+	}
+	sum, trusted := sums[url]
+	if trusted {
+		code, err := os.ReadFile(l.cachePath(sum))
+		if err == nil && checksum(code) == sum {
+			return string(code), nil
+		}
+	}
+
+	code, err := l.fetch(url)
+	if err != nil {
+		return "", err
+	}
+	gotSum := checksum(code)
+	if trusted {
+		if gotSum != sum {
+			return "", ChecksumMismatch{url, sum, gotSum, l.sumsPath()}
+		}
+	} else {
+		ok, err := l.cfg.Trust(url, gotSum)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", NotTrusted{url, gotSum, l.sumsPath()}
+		}
+		if err := l.addSum(url, gotSum); err != nil {
+			return "", err
+		}
+	}
+	// Failing to cache the module is not fatal; it will be fetched again next
+	// time.
+	os.WriteFile(l.cachePath(gotSum), code, 0o644)
+	return string(code), nil
+}
+
+func (l *Loader) fetch(url string) ([]byte, error) {
+	resp, err := l.cfg.Client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: %s", url, resp.Status)
+	}
+	code, err := io.ReadAll(io.LimitReader(resp.Body, maxModuleSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", url, err)
+	}
+	if len(code) > maxModuleSize {
+		return nil, fmt.Errorf("fetch %s: module larger than %d bytes", url, maxModuleSize)
+	}
+	if !utf8.Valid(code) {
+		return nil, fmt.Errorf("%s: source is not valid UTF-8", url)
+	}
+	return code, nil
+}
+
+func (l *Loader) sumsPath() string { return filepath.Join(l.cfg.Dir, SumsFile) }
+
+func (l *Loader) cachePath(sum string) string {
+	return filepath.Join(l.cfg.Dir, sum+".elv")
+}
+
+func (l *Loader) readSums() (map[string]string, error) {
+	sums := map[string]string{}
+	file, err := os.Open(l.sumsPath())
+	if os.IsNotExist(err) {
+		return sums, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s: bad line: %q", l.sumsPath(), line)
+		}
+		sums[fields[0]] = fields[1]
+	}
+	return sums, scanner.Err()
+}
+
+func (l *Loader) addSum(url, sum string) error {
+	if err := os.MkdirAll(l.cfg.Dir, 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.sumsPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(file, url, sum)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func checksum(code []byte) string {
+	sum := sha256.Sum256(code)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,181 @@
+package remotemod
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/testutil"
+)
+
+// Serves modules from the map, counting the number of requests.
+type fakeServer struct {
+	*httptest.Server
+	modules  map[string]string
+	requests int
+}
+
+func newFakeServer(t *testing.T, modules map[string]string) *fakeServer {
+	s := &fakeServer{modules: modules}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			s.requests++
+			code, ok := s.modules[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			if strings.HasPrefix(code, "redirect ") {
+				http.Redirect(w, r, strings.TrimPrefix(code, "redirect "), http.StatusFound)
+				return
+			}
+			w.Write([]byte(code))
+		}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Returns a Loader using the server, trusting all modules and recording the
+// URLs it is asked to trust.
+func newTrustingLoader(s *fakeServer, dir string, asked *[]string) *Loader {
+	return New(Config{
+		Dir:    dir,
+		Client: s.Client(),
+		Trust: func(url, sum string) (bool, error) {
+			*asked = append(*asked, url)
+			return true, nil
+		},
+	})
+}
+
+func TestLoad_TrustsAndCaches(t *testing.T) {
+	s := newFakeServer(t, map[string]string{"/mod.elv": "echo mod"})
+	dir := testutil.TempDir(t)
+	var asked []string
+	l := newTrustingLoader(s, dir, &asked)
+	url := s.URL + "/mod.elv"
+
+	for i := 0; i < 2; i++ {
+		code, err := l.Load(url)
+		if code != "echo mod" || err != nil {
+			t.Errorf("got (%q, %v), want (%q, nil)", code, err, "echo mod")
+		}
+	}
+	if len(asked) != 1 || asked[0] != url {
+		t.Errorf("asked to trust %q, want only %q", asked, url)
+	}
+	if s.requests != 1 {
+		t.Errorf("module fetched %d times, want 1", s.requests)
+	}
+	sums := must.ReadFileString(filepath.Join(dir, SumsFile))
+	if want := url + " " + checksum([]byte("echo mod")) + "\n"; sums != want {
+		t.Errorf("got sums file %q, want %q", sums, want)
+	}
+
+	// A new Loader uses the same sums file and cache.
+	newTrustingLoader(s, dir, &asked).Load(url)
+	if len(asked) != 1 || s.requests != 1 {
+		t.Errorf("new Loader asked to trust or fetched cached module")
+	}
+}
+
+func TestLoad_RefetchesCorruptedCache(t *testing.T) {
+	s := newFakeServer(t, map[string]string{"/mod.elv": "echo mod"})
+	dir := testutil.TempDir(t)
+	var asked []string
+	l := newTrustingLoader(s, dir, &asked)
+	url := s.URL + "/mod.elv"
+
+	l.Load(url)
+	must.WriteFile(filepath.Join(dir, checksum([]byte("echo mod"))+".elv"), "echo bad")
+	code, err := l.Load(url)
+	if code != "echo mod" || err != nil {
+		t.Errorf("got (%q, %v), want (%q, nil)", code, err, "echo mod")
+	}
+	if s.requests != 2 || len(asked) != 1 {
+		t.Errorf("got %d requests and %d trust prompts, want 2 and 1",
+			s.requests, len(asked))
+	}
+}
+
+func TestLoad_ChecksumMismatch(t *testing.T) {
+	s := newFakeServer(t, map[string]string{"/mod.elv": "echo mod"})
+	dir := testutil.TempDir(t)
+	var asked []string
+	l := newTrustingLoader(s, dir, &asked)
+	url := s.URL + "/mod.elv"
+
+	l.Load(url)
+	os.Remove(filepath.Join(dir, checksum([]byte("echo mod"))+".elv"))
+	s.modules["/mod.elv"] = "echo changed"
+	_, err := l.Load(url)
+	if !errors.As(err, &ChecksumMismatch{}) {
+		t.Errorf("got error %v, want ChecksumMismatch", err)
+	}
+}
+
+func TestLoad_NotTrusted(t *testing.T) {
+	s := newFakeServer(t, map[string]string{"/mod.elv": "echo mod"})
+	dir := testutil.TempDir(t)
+	l := New(Config{Dir: dir, Client: s.Client()})
+	url := s.URL + "/mod.elv"
+
+	_, err := l.Load(url)
+	var notTrusted NotTrusted
+	if !errors.As(err, &notTrusted) {
+		t.Fatalf("got error %v, want NotTrusted", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, SumsFile)); err == nil {
+		t.Errorf("sums file written for untrusted module")
+	}
+
+	// Adding the line suggested by the error trusts the module.
+	line := notTrusted.URL + " " + notTrusted.Sum + "\n"
+	testutil.ApplyDirIn(testutil.Dir{SumsFile: "# comment\n" + line}, dir)
+	code, err := l.Load(url)
+	if code != "echo mod" || err != nil {
+		t.Errorf("got (%q, %v), want (%q, nil)", code, err, "echo mod")
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	s := newFakeServer(t, map[string]string{
+		"/invalid-utf8.elv": "\xff",
+		"/large.elv":        strings.Repeat("#", maxModuleSize+1),
+		"/redirect.elv":     "redirect http://example.com/mod.elv",
+	})
+	dir := testutil.TempDir(t)
+	var asked []string
+	l := newTrustingLoader(s, dir, &asked)
+
+	tests := []struct {
+		url     string
+		wantErr string
+	}{
+		{"http://example.com/mod.elv", "must be loaded over HTTPS"},
+		{s.URL + "/nonexistent.elv", "404 Not Found"},
+		{s.URL + "/invalid-utf8.elv", "not valid UTF-8"},
+		{s.URL + "/large.elv", "larger than"},
+		{s.URL + "/redirect.elv", "redirect to non-HTTPS URL"},
+	}
+	for _, test := range tests {
+		_, err := l.Load(test.url)
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("Load(%q) -> error %v, want containing %q",
+				test.url, err, test.wantErr)
+		}
+	}
+	if len(asked) != 0 {
+		t.Errorf("asked to trust %q, want none", asked)
+	}
+
+	testutil.ApplyDirIn(testutil.Dir{SumsFile: "bad line here\n"}, dir)
+	if _, err := l.Load(s.URL + "/mod.elv"); err == nil {
+		t.Errorf("got nil error with bad sums file")
+	}
+}
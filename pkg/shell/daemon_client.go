@@ -0,0 +1,274 @@
+package shell
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"src.elv.sh/pkg/daemon/daemondefs"
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+// A daemon client that activates the daemon, possibly spawning it, when it is
+// first used, instead of when the shell starts.
+//
+// Since the activation can happen while the editor is active, messages written
+// during the activation are buffered until flushMessages is called.
+type lazyDaemonClient struct {
+	activate daemondefs.ActivateFunc
+	spawnCfg *daemondefs.SpawnConfig
+	// Called with the duration of the activation. May be nil.
+	onActivated func(time.Duration)
+
+	once sync.Once
+	cl   daemondefs.Client
+	err  error
+
+	msgMutex sync.Mutex
+	msgs     bytes.Buffer
+}
+
+var errNoDaemonClient = errors.New("daemon client not available")
+
+func newLazyDaemonClient(activate daemondefs.ActivateFunc, spawnCfg *daemondefs.SpawnConfig, onActivated func(time.Duration)) *lazyDaemonClient {
+	return &lazyDaemonClient{
+		activate: activate, spawnCfg: spawnCfg, onActivated: onActivated}
+}
+
+// Returns the underlying client, activating it if this is the first call.
+func (c *lazyDaemonClient) client() (daemondefs.Client, error) {
+	c.once.Do(func() {
+		start := time.Now()
+		w := lockedWriter{&c.msgMutex, &c.msgs}
+		cl, err := c.activate(w, c.spawnCfg)
+		if err != nil {
+			fmt.Fprintln(w, "Cannot connect to daemon:", err)
+			fmt.Fprintln(w, "Daemon-related functions will likely not work.")
+		}
+		if cl == nil {
+			if err == nil {
+				err = errNoDaemonClient
+			}
+		} else {
+			// Even if error is not nil, we use the client anyway. Daemon may
+			// eventually come online and become functional.
+			err = nil
+		}
+		c.cl, c.err = cl, err
+		if c.onActivated != nil {
+			c.onActivated(time.Since(start))
+		}
+	})
+	return c.cl, c.err
+}
+
+// Writes out messages written during activation, if any.
+func (c *lazyDaemonClient) flushMessages(w io.Writer) {
+	c.msgMutex.Lock()
+	defer c.msgMutex.Unlock()
+	c.msgs.WriteTo(w)
+}
+
+type lockedWriter struct {
+	m *sync.Mutex
+	w io.Writer
+}
+
+func (w lockedWriter) Write(p []byte) (int, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+	return w.w.Write(p)
+}
+
+func (c *lazyDaemonClient) NextCmdSeq() (int, error) {
+	cl, err := c.client()
+	if err != nil {
+		return -1, err
+	}
+	return cl.NextCmdSeq()
+}
+
+func (c *lazyDaemonClient) AddCmd(text string) (int, error) {
+	cl, err := c.client()
+	if err != nil {
+		return -1, err
+	}
+	return cl.AddCmd(text)
+}
+
+func (c *lazyDaemonClient) DelCmd(seq int) error {
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+	return cl.DelCmd(seq)
+}
+
+func (c *lazyDaemonClient) Cmd(seq int) (string, error) {
+	cl, err := c.client()
+	if err != nil {
+		return "", err
+	}
+	return cl.Cmd(seq)
+}
+
+func (c *lazyDaemonClient) CmdsWithSeq(from, upto int) ([]storedefs.Cmd, error) {
+	cl, err := c.client()
+	if err != nil {
+		return nil, err
+	}
+	return cl.CmdsWithSeq(from, upto)
+}
+
+func (c *lazyDaemonClient) NextCmd(from int, prefix string) (storedefs.Cmd, error) {
+	cl, err := c.client()
+	if err != nil {
+		return storedefs.Cmd{}, err
+	}
+	return cl.NextCmd(from, prefix)
+}
+
+func (c *lazyDaemonClient) PrevCmd(upto int, prefix string) (storedefs.Cmd, error) {
+	cl, err := c.client()
+	if err != nil {
+		return storedefs.Cmd{}, err
+	}
+	return cl.PrevCmd(upto, prefix)
+}
+
+func (c *lazyDaemonClient) SyncCmds() ([]storedefs.SyncCmd, error) {
+	cl, err := c.client()
+	if err != nil {
+		return nil, err
+	}
+	return cl.SyncCmds()
+}
+
+func (c *lazyDaemonClient) MergeCmds(cmds []storedefs.SyncCmd) (int, error) {
+	cl, err := c.client()
+	if err != nil {
+		return 0, err
+	}
+	return cl.MergeCmds(cmds)
+}
+
+func (c *lazyDaemonClient) AddDir(dir string, incFactor float64) error {
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+	return cl.AddDir(dir, incFactor)
+}
+
+func (c *lazyDaemonClient) DelDir(dir string) error {
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+	return cl.DelDir(dir)
+}
+
+func (c *lazyDaemonClient) Dirs(blacklist map[string]struct{}) ([]storedefs.Dir, error) {
+	cl, err := c.client()
+	if err != nil {
+		return nil, err
+	}
+	return cl.Dirs(blacklist)
+}
+
+func (c *lazyDaemonClient) Value(ns, key string) (storedefs.Value, error) {
+	cl, err := c.client()
+	if err != nil {
+		return storedefs.Value{}, err
+	}
+	return cl.Value(ns, key)
+}
+
+func (c *lazyDaemonClient) SetValue(ns, key, data string, version int) error {
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+	return cl.SetValue(ns, key, data, version)
+}
+
+func (c *lazyDaemonClient) DelValue(ns, key string, version int) error {
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+	return cl.DelValue(ns, key, version)
+}
+
+func (c *lazyDaemonClient) ValueKeys(ns string) ([]string, error) {
+	cl, err := c.client()
+	if err != nil {
+		return nil, err
+	}
+	return cl.ValueKeys(ns)
+}
+
+func (c *lazyDaemonClient) ResetConn() error {
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+	return cl.ResetConn()
+}
+
+// Close closes the underlying client if it has been activated, and does
+// nothing otherwise.
+func (c *lazyDaemonClient) Close() error {
+	activated := true
+	c.once.Do(func() {
+		activated = false
+		c.err = errNoDaemonClient
+	})
+	if !activated || c.cl == nil {
+		return nil
+	}
+	return c.cl.Close()
+}
+
+func (c *lazyDaemonClient) Pid() (int, error) {
+	cl, err := c.client()
+	if err != nil {
+		return -1, err
+	}
+	return cl.Pid()
+}
+
+func (c *lazyDaemonClient) Compact() (before, after int64, err error) {
+	cl, err := c.client()
+	if err != nil {
+		return 0, 0, err
+	}
+	return cl.Compact()
+}
+
+func (c *lazyDaemonClient) SockPath() string {
+	cl, err := c.client()
+	if err != nil {
+		return c.spawnCfg.SockPath
+	}
+	return cl.SockPath()
+}
+
+func (c *lazyDaemonClient) Status() (daemondefs.Status, error) {
+	cl, err := c.client()
+	if err != nil {
+		return daemondefs.Status{}, err
+	}
+	return cl.Status()
+}
+
+func (c *lazyDaemonClient) Version() (int, error) {
+	cl, err := c.client()
+	if err != nil {
+		return -1, err
+	}
+	return cl.Version()
+}
@@ -0,0 +1,104 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/edit"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/must"
+)
+
+// Configuration for dumping the configuration of the editor.
+type dumpCfg struct {
+	// Dump the default binding tables instead of the configuration.
+	Bindings bool
+	// The rc file to evaluate before dumping the configuration.
+	RC   string
+	JSON bool
+}
+
+// The value and the source of an editor variable, as dumped by -dump-config.
+type configEntry struct {
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+// Dumps the default binding tables or the effective configuration of the
+// editor, without starting an interactive session.
+func dump(ev *eval.Evaler, fds [3]*os.File, cfg *dumpCfg) int {
+	ed := edit.NewEditor(cli.NewTTY(fds[0], fds[2]), ev, nil)
+	ev.ExtendBuiltin(eval.BuildNs().AddNs("edit", ed))
+
+	if cfg.Bindings {
+		tables := ed.BindingTables()
+		if cfg.JSON {
+			writeJSON(fds[1], tables)
+			return 0
+		}
+		for _, name := range sortedKeys(tables) {
+			writeBindings(fds[1], name, tables[name], "")
+		}
+		return 0
+	}
+
+	exit := 0
+	if cfg.RC != "" {
+		// Output from the rc file goes to stderr, so that it doesn't get
+		// mixed with the dump.
+		err := sourceRC([3]*os.File{fds[0], fds[2], fds[2]}, ev, nil, cfg.RC, "rc", nil)
+		if err != nil {
+			diag.ShowError(fds[2], err)
+			exit = 2
+		}
+	}
+	entries := make(map[string]configEntry)
+	for _, cv := range ed.ConfigVars() {
+		source := cfg.RC
+		if cv.IsDefault {
+			source = "default"
+		}
+		entries[cv.Name] = configEntry{cv.Value, source}
+	}
+	if cfg.JSON {
+		writeJSON(fds[1], entries)
+		return exit
+	}
+	for _, name := range sortedKeys(entries) {
+		e := entries[name]
+		if bindings, ok := e.Value.(map[string]string); ok {
+			writeBindings(fds[1], name, bindings, " ("+e.Source+")")
+		} else {
+			fmt.Fprintf(fds[1], "%s = %s (%s)\n", name, e.Value, e.Source)
+		}
+	}
+	return exit
+}
+
+func writeJSON(w io.Writer, v any) {
+	enc := json.NewEncoder(w)
+	// Function descriptions like "<builtin edit:move-dot-left>" are more
+	// readable without escaping.
+	enc.SetEscapeHTML(false)
+	must.OK(enc.Encode(v))
+}
+
+func writeBindings(w io.Writer, name string, bindings map[string]string, suffix string) {
+	for _, key := range sortedKeys(bindings) {
+		fmt.Fprintf(w, "%s[%s] = %s%s\n", name, key, bindings[key], suffix)
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,56 @@
+package shell
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/must"
+	. "src.elv.sh/pkg/prog/progtest"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestDump(t *testing.T) {
+	setupCleanHomePaths(t)
+	testutil.InTempDir(t)
+	must.WriteFile("rc.elv", `
+		echo output of rc.elv
+		set edit:max-height = 10
+		set edit:insert:binding[Ctrl-X] = { edit:insert-at-dot x }`)
+	must.WriteFile("rc-fail.elv", "set edit:tab-width = 4; fail bad")
+
+	Test(t, &Program{},
+		ThatElvish("-dump-default-bindings").
+			WritesStdoutContaining(
+				"edit:insert:binding[Left] = <builtin <edit>:move-dot-left>\n"),
+		ThatElvish("-dump-default-bindings", "-json").
+			WritesStdoutContaining(
+				`"Left":"<builtin <edit>:move-dot-left>"`),
+		// The rc file is not read.
+		ThatElvish("-rc", "rc.elv", "-dump-default-bindings").
+			WritesStdoutContaining("edit:insert:binding[Left]"),
+
+		// Output of the rc file goes to stderr.
+		ThatElvish("-rc", "rc.elv", "-dump-config").
+			WritesStdoutContaining("edit:max-height = (num 10) (").
+			WritesStderr("output of rc.elv\n"),
+		ThatElvish("-rc", "rc.elv", "-dump-config").
+			WritesStdoutContaining("edit:tab-width = (num -1) (default)\n").
+			WritesStderr("output of rc.elv\n"),
+		ThatElvish("-rc", "rc.elv", "-dump-config").
+			WritesStdoutContaining(
+				"edit:insert:binding[Ctrl-X] = { edit:insert-at-dot x } (").
+			WritesStderr("output of rc.elv\n"),
+		ThatElvish("-norc", "-dump-config", "-json").
+			WritesStdoutContaining(
+				`"edit:max-height":{"value":"(num -1)","source":"default"}`),
+		// Configuration is still dumped if the rc file fails.
+		ThatElvish("-rc", "rc-fail.elv", "-dump-config").
+			ExitsWith(2).
+			WritesStdoutContaining("edit:tab-width = (num 4) (").
+			WritesStderrContaining("bad"),
+
+		ThatElvish("-dump-config", "foo.elv").
+			ExitsWith(2).
+			WritesStderrContaining(
+				"arguments are not allowed with -dump-default-bindings or -dump-config"),
+	)
+}
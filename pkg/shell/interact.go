@@ -11,9 +11,11 @@ import (
 	"time"
 
 	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/term"
 	"src.elv.sh/pkg/daemon/daemondefs"
 	"src.elv.sh/pkg/diag"
 	"src.elv.sh/pkg/edit"
+	"src.elv.sh/pkg/env"
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/mods/daemon"
 	"src.elv.sh/pkg/mods/store"
@@ -27,12 +29,26 @@ import (
 // being launched. It should be set to false by interactive mode unit tests.
 var interactiveRescueShell bool = true
 
+// How long to wait for the terminal to respond to the capability probe. Most
+// terminals respond within a few milliseconds.
+const terminalProbeTimeout = 100 * time.Millisecond
+
 // Configuration for the interactive mode.
 type interactCfg struct {
-	RC string
+	// Path to the login script, sourced before the RC file when running as a
+	// login shell.
+	Login string
+	RC    string
 
 	ActivateDaemon daemondefs.ActivateFunc
 	SpawnConfig    *daemondefs.SpawnConfig
+
+	// Don't connect to the daemon, and start the editor with $edit:incognito
+	// set, so that nothing is saved.
+	Private bool
+
+	// If not nil, used to record and print the duration of startup phases.
+	Timing *timing
 }
 
 // Interface satisfied by the line editor. Used for swapping out the editor with
@@ -49,50 +65,95 @@ func interact(ev *eval.Evaler, fds [3]*os.File, cfg *interactCfg) {
 	}
 
 	var daemonClient daemondefs.Client
-	if cfg.ActivateDaemon != nil && cfg.SpawnConfig != nil {
-		// TODO(xiaq): Connect to daemon and install daemon module
-		// asynchronously.
-		cl, err := cfg.ActivateDaemon(fds[2], cfg.SpawnConfig)
-		if err != nil {
-			fmt.Fprintln(fds[2], "Cannot connect to daemon:", err)
-			fmt.Fprintln(fds[2], "Daemon-related functions will likely not work.")
-		}
-		if cl != nil {
-			// Even if error is not nil, we install daemon-related
-			// functionalities anyway. Daemon may eventually come online and
-			// become functional.
-			daemonClient = cl
-			ev.PreExitHooks = append(ev.PreExitHooks, func() { cl.Close() })
-			ev.AddModule("store", store.Ns(cl))
-			ev.AddModule("daemon", daemon.Ns(cl))
-		}
+	var lazyClient *lazyDaemonClient
+	if cfg.ActivateDaemon != nil && cfg.SpawnConfig != nil && !cfg.Private {
+		// The daemon is only activated when it is first used, which often
+		// happens after the first prompt.
+		lazyClient = newLazyDaemonClient(cfg.ActivateDaemon, cfg.SpawnConfig,
+			func(d time.Duration) {
+				cfg.Timing.add(timingPhase{name: "daemon dial", duration: d})
+			})
+		daemonClient = lazyClient
+		ev.PreExitHooks = append(ev.PreExitHooks, func() {
+			lazyClient.flushMessages(fds[2])
+			lazyClient.Close()
+		})
+		ev.AddLazyModule("store", func() *eval.Ns { return store.Ns(lazyClient) })
+		ev.AddLazyModule("daemon", func() *eval.Ns { return daemon.Ns(lazyClient) })
 	}
 
-	// Build Editor.
-	var ed editor
-	if sys.IsATTY(fds[0].Fd()) {
-		newed := edit.NewEditor(cli.NewTTY(fds[0], fds[2]), ev, daemonClient)
-		ev.ExtendBuiltin(eval.BuildNs().AddNs("edit", newed))
-		ev.BgJobNotify = func(s string) { newed.Notify(ui.T(s)) }
-		ed = newed
+	// Probe the terminal while the editor is being built. The probe must be
+	// finished before the RC files are sourced, since they may use the
+	// terminal.
+	probeDone := make(chan struct{})
+	if sys.IsATTY(fds[0].Fd()) && sys.IsATTY(fds[2].Fd()) && os.Getenv(env.TERM) != "dumb" {
+		go func() {
+			cfg.Timing.measure("terminal probe", func() {
+				term.SetCapabilities(term.Probe(fds[0], fds[2], terminalProbeTimeout))
+			})
+			close(probeDone)
+		}()
 	} else {
-		ed = newMinEditor(fds[0], fds[2])
+		close(probeDone)
 	}
 
-	// Source rc.elv.
+	// Build Editor.
+	var ed editor
+	cfg.Timing.measure("editor init", func() {
+		if sys.IsATTY(fds[0].Fd()) {
+			newed := edit.NewEditor(cli.NewTTY(fds[0], fds[2]), ev, daemonClient)
+			ev.ExtendBuiltin(eval.BuildNs().AddNs("edit", newed))
+			ev.BgJobNotify = func(s string) { newed.Notify(ui.T(s)) }
+			if cfg.Private {
+				newed.SetIncognito(true)
+			}
+			if cfg.Timing != nil {
+				newed.AfterRedraw = append(newed.AfterRedraw, func() {
+					cfg.Timing.firstPrompt(func(s string) { newed.Notify(ui.T(s)) })
+				})
+			}
+			ed = newed
+		} else {
+			minEd := newMinEditor(fds[0], fds[2])
+			if cfg.Timing != nil {
+				minEd.afterPrompt = func() {
+					fmt.Fprintln(fds[2])
+					cfg.Timing.firstPrompt(func(s string) { fmt.Fprintln(fds[2], s) })
+				}
+			}
+			ed = minEd
+		}
+	})
+
+	<-probeDone
+
+	// Source login.elv and rc.elv.
+	if cfg.Login != "" {
+		err := sourceRC(fds, ev, ed, cfg.Login, "login", cfg.Timing)
+		if err != nil {
+			diag.ShowError(fds[2], err)
+		}
+	}
 	if cfg.RC != "" {
-		err := sourceRC(fds, ev, ed, cfg.RC)
+		err := sourceRC(fds, ev, ed, cfg.RC, "rc", cfg.Timing)
 		if err != nil {
 			diag.ShowError(fds[2], err)
 		}
 	}
 
+	if lazyClient != nil {
+		cfg.Timing.add(timingPhase{name: "daemon dial", note: "deferred until first use"})
+	}
+
 	cooldown := time.Second
 	cmdNum := 0
 
 	for {
 		cmdNum++
 
+		if lazyClient != nil {
+			lazyClient.flushMessages(fds[2])
+		}
 		line, err := ed.ReadCode()
 		if err == io.EOF {
 			break
@@ -122,7 +183,7 @@ func interact(ev *eval.Evaler, fds [3]*os.File, cfg *interactCfg) {
 			continue
 		}
 		err = evalInTTY(fds, ev, ed,
-			parse.Source{Name: fmt.Sprintf("[tty %v]", cmdNum), Code: line})
+			parse.Source{Name: fmt.Sprintf("[tty %v]", cmdNum), Code: line}, false)
 		if err != nil {
 			diag.ShowError(fds[2], err)
 		}
@@ -142,10 +203,13 @@ func handlePanic() {
 	}
 }
 
-func sourceRC(fds [3]*os.File, ev *eval.Evaler, ed editor, rcPath string) error {
+// Sources the RC file or the login script, recording the time it takes as the
+// "<phase> parse" and "<phase> eval" phases. It does nothing if the file
+// doesn't exist.
+func sourceRC(fds [3]*os.File, ev *eval.Evaler, ed editor, rcPath, phase string, t *timing) error {
 	absPath, err := filepath.Abs(rcPath)
 	if err != nil {
-		return fmt.Errorf("cannot get full path of rc.elv: %v", err)
+		return fmt.Errorf("cannot get full path of %s: %v", filepath.Base(rcPath), err)
 	}
 	code, err := readFileUTF8(absPath)
 	if err != nil {
@@ -154,16 +218,27 @@ func sourceRC(fds [3]*os.File, ev *eval.Evaler, ed editor, rcPath string) error
 		}
 		return err
 	}
-	return evalInTTY(fds, ev, ed, parse.Source{Name: absPath, Code: code, IsFile: true})
+	src := parse.Source{Name: absPath, Code: code, IsFile: true}
+	var tree parse.Tree
+	t.measure(phase+" parse", func() {
+		tree, err = parse.Parse(src, parse.Config{WarningWriter: fds[2]})
+	})
+	if err != nil {
+		return err
+	}
+	t.measure(phase+" eval", func() { err = evalTreeInTTY(fds, ev, ed, tree, false) })
+	return err
 }
 
 type minEditor struct {
 	in  *bufio.Reader
 	out io.Writer
+	// If not nil, called after the next prompt is written.
+	afterPrompt func()
 }
 
 func newMinEditor(in, out *os.File) *minEditor {
-	return &minEditor{bufio.NewReader(in), out}
+	return &minEditor{in: bufio.NewReader(in), out: out}
 }
 
 func (ed *minEditor) RunAfterCommandHooks(src parse.Source, duration float64, err error) {
@@ -176,6 +251,10 @@ func (ed *minEditor) ReadCode() (string, error) {
 		wd = "?"
 	}
 	fmt.Fprintf(ed.out, "%s> ", wd)
+	if f := ed.afterPrompt; f != nil {
+		ed.afterPrompt = nil
+		f()
+	}
 	line, err := ed.in.ReadString('\n')
 	return strutil.ChopLineEnding(line), err
 }
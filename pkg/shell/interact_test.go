@@ -6,13 +6,16 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"src.elv.sh/pkg/daemon"
 	"src.elv.sh/pkg/daemon/daemondefs"
 	"src.elv.sh/pkg/env"
+	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/parse"
 	. "src.elv.sh/pkg/prog/progtest"
 	"src.elv.sh/pkg/testutil"
 )
@@ -63,6 +66,24 @@ func TestInteract_RCPath_XDG_CONFIG_HOME(t *testing.T) {
 	)
 }
 
+func TestInteract_Login(t *testing.T) {
+	setupCleanHomePaths(t)
+	xdgConfigHome := testutil.Setenv(t, env.XDG_CONFIG_HOME, testutil.TempDir(t))
+	testutil.ApplyDirIn(testutil.Dir{
+		"elvish": testutil.Dir{
+			"login.elv": "echo hello login.elv",
+			"rc.elv":    "echo hello rc.elv",
+		},
+	}, xdgConfigHome)
+
+	Test(t, &Program{},
+		thatElvishInteract("-login").
+			WritesStdout("hello login.elv\nhello rc.elv\n"),
+		thatElvishInteract("-l", "-norc").WritesStdout("hello login.elv\n"),
+		thatElvishInteract().WritesStdout("hello rc.elv\n"),
+	)
+}
+
 func TestInteract_ConnectsToDaemon(t *testing.T) {
 	sockPath := startDaemon(t)
 
@@ -87,11 +108,65 @@ func TestInteract_ErrorInActivateDaemon(t *testing.T) {
 		return nil, errors.New("fake error")
 	}
 	Test(t, &Program{ActivateDaemon: activate},
-		thatElvishInteract().
+		thatElvishInteract().WithStdin("use daemon; nop $daemon:pid\n").
 			WritesStderrContaining("Cannot connect to daemon: fake error"),
 	)
 }
 
+func TestInteract_PrivateDoesNotConnectToDaemon(t *testing.T) {
+	activated := false
+	activate := func(io.Writer, *daemondefs.SpawnConfig) (daemondefs.Client, error) {
+		activated = true
+		return nil, errors.New("fake error")
+	}
+	Test(t, &Program{ActivateDaemon: activate},
+		thatElvishInteract("-private").
+			WithStdin("use daemon; nop $daemon:pid\n").
+			WritesStderrContaining("no such module: daemon"),
+	)
+	if activated {
+		t.Errorf("daemon activated in private mode")
+	}
+}
+
+func TestInteract_DaemonActivatedOnFirstUse(t *testing.T) {
+	activated := false
+	activate := func(io.Writer, *daemondefs.SpawnConfig) (daemondefs.Client, error) {
+		activated = true
+		return nil, errors.New("fake error")
+	}
+	Test(t, &Program{ActivateDaemon: activate},
+		thatElvishInteract().WithStdin("echo hello\n").WritesStdout("hello\n"),
+	)
+	if activated {
+		t.Errorf("daemon activated without being used")
+	}
+}
+
+func TestInteract_Timing(t *testing.T) {
+	setupCleanHomePaths(t)
+	testutil.InTempDir(t)
+	must.WriteFile("rc.elv", "nop")
+
+	Test(t, &Program{ActivateDaemon: fakeActivate("")},
+		thatElvishInteract("-timing").WritesStderrContaining("Startup timing:\n"),
+		thatElvishInteract("-timing").
+			WritesStderrContaining("daemon dial    deferred until first use\n"),
+		thatElvishInteract("-timing", "-rc", "rc.elv").
+			WritesStderrContaining("rc eval"),
+	)
+}
+
+func TestInteract_Timing_DaemonDialAfterFirstPrompt(t *testing.T) {
+	sockPath := startDaemon(t)
+	setupCleanHomePaths(t)
+
+	Test(t, &Program{ActivateDaemon: fakeActivate(sockPath)},
+		thatElvishInteract("-timing").WithStdin("use daemon; nop $daemon:pid\n").
+			WritesStderrContaining("Startup timing: daemon dial "),
+	)
+}
+
 func TestInteract_DBPath_Legacy(t *testing.T) {
 	sockPath := startDaemon(t)
 	home := setupCleanHomePaths(t)
@@ -99,7 +174,7 @@ func TestInteract_DBPath_Legacy(t *testing.T) {
 	must.WriteFile(legacyDBPath, "")
 
 	Test(t, &Program{ActivateDaemon: fakeActivate(sockPath)},
-		thatElvishInteract().
+		thatElvishInteract().WithStdin("use daemon; nop $daemon:pid\n").
 			WritesStderrContaining("db requested: "+legacyDBPath),
 	)
 }
@@ -110,7 +185,7 @@ func TestInteract_DBPath_XDG_STATE_HOME(t *testing.T) {
 	xdgStateHome := testutil.Setenv(t, env.XDG_STATE_HOME, t.TempDir())
 
 	Test(t, &Program{ActivateDaemon: fakeActivate(sockPath)},
-		thatElvishInteract().
+		thatElvishInteract().WithStdin("use daemon; nop $daemon:pid\n").
 			WritesStderrContaining("db requested: "+
 				filepath.Join(xdgStateHome, "elvish", "db.bolt")),
 	)
@@ -159,3 +234,44 @@ func fakeActivate(sockPath string) daemondefs.ActivateFunc {
 		return daemon.NewClient(sockPath), nil
 	}
 }
+
+func TestEvalInTTY_RecordsOutput(t *testing.T) {
+	r, w := must.Pipe()
+	ed := &recordingEditor{}
+	fds := [3]*os.File{eval.DevNull, w, w}
+
+	err := evalInTTY(fds, eval.NewEvaler(), ed,
+		parse.Source{Name: "[test]", Code: "echo foo; put bar"}, false)
+	w.Close()
+
+	if err != nil {
+		t.Errorf("got error %v", err)
+	}
+	wantOut := "foo\n▶ bar\n"
+	if out := string(must.ReadAllAndClose(r)); out != wantOut {
+		t.Errorf("got output %q, want %q", out, wantOut)
+	}
+	if !ed.rec.closed {
+		t.Errorf("recorder not closed")
+	}
+	if recorded := ed.rec.String(); recorded != wantOut {
+		t.Errorf("got recorded output %q, want %q", recorded, wantOut)
+	}
+}
+
+type recordingEditor struct {
+	minEditor
+	rec recorder
+}
+
+func (ed *recordingEditor) RecordOutput() io.WriteCloser { return &ed.rec }
+
+type recorder struct {
+	strings.Builder
+	closed bool
+}
+
+func (r *recorder) Close() error {
+	r.closed = true
+	return nil
+}
@@ -29,7 +29,7 @@ func TestInteract_DBPath_Default(t *testing.T) {
 	home := setupCleanHomePaths(t)
 
 	Test(t, &Program{ActivateDaemon: fakeActivate(sockPath)},
-		thatElvishInteract().
+		thatElvishInteract().WithStdin("use daemon; nop $daemon:pid\n").
 			WritesStderrContaining("db requested: "+
 				filepath.Join(home, ".local", "state", "elvish", "db.bolt")),
 	)
@@ -0,0 +1,88 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/edit"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/parse"
+)
+
+// Configuration for the lint mode.
+type lintCfg struct {
+	JSON bool
+}
+
+// A problem found by -lint, as output with -json.
+type lintProblemInJSON struct {
+	FileName string `json:"fileName"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Type     string `json:"type"`
+	Message  string `json:"message"`
+}
+
+// Checks the given files, or stdin if there are none, without executing them.
+// It returns 2 if any file can't be read, parsed or compiled, 1 if there are
+// only warnings, and 0 otherwise.
+func lint(ev *eval.Evaler, fds [3]*os.File, files []string, cfg *lintCfg) int {
+	// Make the edit: namespace available, so that rc files can be checked.
+	ed := edit.NewEditor(cli.NewTTY(fds[0], fds[2]), ev, nil)
+	ev.ExtendBuiltin(eval.BuildNs().AddNs("edit", ed))
+
+	var srcs []parse.Source
+	exit := 0
+	if len(files) == 0 {
+		code, err := io.ReadAll(fds[0])
+		if err != nil {
+			fmt.Fprintln(fds[2], "cannot read stdin:", err)
+			return 2
+		}
+		srcs = append(srcs, parse.Source{Name: "[stdin]", Code: string(code)})
+	}
+	for _, file := range files {
+		code, err := readFileUTF8(file)
+		if err != nil {
+			fmt.Fprintf(fds[2], "cannot read %q: %v\n", file, err)
+			exit = 2
+			continue
+		}
+		srcs = append(srcs, parse.Source{Name: file, Code: code, IsFile: true})
+	}
+
+	var problems []*diag.Error
+	for _, src := range srcs {
+		parseErr, compileErr, warnings := ev.Lint(src)
+		errs := append(parse.UnpackErrors(parseErr),
+			eval.UnpackCompilationErrors(compileErr)...)
+		if len(errs) > 0 {
+			exit = 2
+		} else if len(warnings) > 0 && exit == 0 {
+			exit = 1
+		}
+		fileProblems := append(errs, warnings...)
+		sort.SliceStable(fileProblems, func(i, j int) bool {
+			return fileProblems[i].Range().From < fileProblems[j].Range().From
+		})
+		problems = append(problems, fileProblems...)
+	}
+
+	if cfg.JSON {
+		converted := make([]lintProblemInJSON, len(problems))
+		for i, e := range problems {
+			converted[i] = lintProblemInJSON{
+				e.Context.Name, e.Context.From, e.Context.To, e.Type, e.Message}
+		}
+		writeJSON(fds[1], converted)
+	} else {
+		for _, e := range problems {
+			fmt.Fprintf(fds[1], "%s: %s: %s\n", e.Context.Position(), e.Type, e.Message)
+		}
+	}
+	return exit
+}
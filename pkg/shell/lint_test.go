@@ -0,0 +1,50 @@
+package shell
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/must"
+	. "src.elv.sh/pkg/prog/progtest"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestLint(t *testing.T) {
+	setupCleanHomePaths(t)
+	testutil.InTempDir(t)
+	must.WriteFile("good.elv", "use str; use ./warn; fn f {|x| put $x }")
+	must.WriteFile("warn.elv", "fn f {\n  var x = 1\n  if false { }\n}")
+	must.WriteFile("bad.elv", "echo $x")
+	must.WriteFile("rc.elv", "set edit:max-height = 10")
+
+	Test(t, &Program{},
+		ThatElvish("-lint", "good.elv", "rc.elv").DoesNothing(),
+		ThatElvish("-lint").WithStdin("echo foo").DoesNothing(),
+		ThatElvish("-lint").
+			WithStdin("use nonexistent").
+			ExitsWith(1).
+			WritesStdout("[stdin]:1:5: no such module: cannot find module nonexistent\n"),
+
+		ThatElvish("-lint", "good.elv", "warn.elv").
+			ExitsWith(1).
+			WritesStdout(
+				"warn.elv:2:7: unused: variable $x is never used\n"+
+					"warn.elv:3:6: suspicious: condition is the string false, which is always true; use $false instead\n"),
+		ThatElvish("-lint", "warn.elv", "bad.elv").
+			ExitsWith(2).
+			WritesStdoutContaining("bad.elv:1:6: compilation error: variable $x not found\n"),
+		ThatElvish("-lint", "-json", "bad.elv").
+			ExitsWith(2).
+			WritesStdout(`[{"fileName":"bad.elv","start":5,"end":7,"type":"compilation error","message":"variable $x not found"}]`+"\n"),
+		ThatElvish("-lint").
+			WithStdin("echo (").
+			ExitsWith(2).
+			WritesStdout("[stdin]:1:7: parse error: should be ')'\n"),
+		ThatElvish("-lint", "nonexistent.elv").
+			ExitsWith(2).
+			WritesStderrContaining(`cannot read "nonexistent.elv"`),
+
+		ThatElvish("-lint", "-c", "echo").
+			ExitsWith(2).
+			WritesStderrContaining("-lint cannot be used with -c, -e or -compileonly"),
+	)
+}
@@ -27,6 +27,16 @@ func rcPath(w io.Writer) (string, error) {
 	}
 }
 
+func loginPath() (string, error) {
+	if configHome := os.Getenv(env.XDG_CONFIG_HOME); configHome != "" {
+		return filepath.Join(configHome, "elvish", "login.elv"), nil
+	} else if configHome, err := defaultConfigHome(); err == nil {
+		return filepath.Join(configHome, "elvish", "login.elv"), nil
+	} else {
+		return "", fmt.Errorf("find login.elv: %w", err)
+	}
+}
+
 const legacyLibPathWarning = `Warning: ~/.elvish/lib will be ignored from Elvish 0.20.0. Move libraries to one of the new module search directories, as documented in https://elv.sh/ref/command.html#module-search-directories.`
 
 func libPaths(w io.Writer) ([]string, error) {
@@ -66,9 +76,19 @@ func libPaths(w io.Writer) ([]string, error) {
 	return paths, nil
 }
 
-// Returns a SpawnConfig containing all the paths needed by the daemon. It
-// respects overrides of sock and db from CLI flags.
-func daemonPaths(p *prog.DaemonPaths, w io.Writer) (*daemondefs.SpawnConfig, error) {
+func remoteModulesPath() (string, error) {
+	if dataHome := os.Getenv(env.XDG_DATA_HOME); dataHome != "" {
+		return filepath.Join(dataHome, "elvish", "remote-modules"), nil
+	} else if dataHome, err := defaultDataHome(); err == nil {
+		return filepath.Join(dataHome, "elvish", "remote-modules"), nil
+	} else {
+		return "", fmt.Errorf("find remote module directory: %w", err)
+	}
+}
+
+// DaemonSpawnConfig returns a SpawnConfig containing all the paths needed by
+// the daemon. It respects overrides of sock and db from CLI flags.
+func DaemonSpawnConfig(p *prog.DaemonPaths, w io.Writer) (*daemondefs.SpawnConfig, error) {
 	runDir, err := secureRunDir()
 	if err != nil {
 		return nil, err
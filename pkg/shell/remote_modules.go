@@ -0,0 +1,49 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"src.elv.sh/pkg/sys"
+)
+
+// Returns a function that asks the user whether to trust a remote module on
+// the terminal. When stdin or stderr is not a terminal, the function doesn't
+// trust any module.
+func trustOnTerminal(fds [3]*os.File) func(url, sum string) (bool, error) {
+	return func(url, sum string) (bool, error) {
+		if !sys.IsATTY(fds[0].Fd()) || !sys.IsATTY(fds[2].Fd()) {
+			return false, nil
+		}
+		fmt.Fprintf(fds[2], "Trust remote module %s (SHA-256 %s)? [y/N] ", url, sum)
+		answer, err := readLine(fds[0])
+		if err != nil {
+			return false, err
+		}
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes", nil
+	}
+}
+
+// Reads a line from the file, one byte at a time so that nothing after the
+// line is consumed.
+func readLine(f *os.File) (string, error) {
+	var sb strings.Builder
+	var buf [1]byte
+	for {
+		n, err := f.Read(buf[:])
+		if n == 1 {
+			if buf[0] == '\n' {
+				return sb.String(), nil
+			}
+			sb.WriteByte(buf[0])
+		}
+		if err != nil {
+			if sb.Len() > 0 {
+				return sb.String(), nil
+			}
+			return "", err
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package shell
 
 import (
+	"bufio"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"unicode/utf8"
@@ -11,13 +13,16 @@ import (
 	"src.elv.sh/pkg/diag"
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/must"
 	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/strutil"
 )
 
 // Configuration for the script mode.
 type scriptCfg struct {
 	Cmd         bool
 	CompileOnly bool
+	DryRun      bool
 	JSON        bool
 }
 
@@ -47,10 +52,16 @@ func script(ev *eval.Evaler, fds [3]*os.File, args []string, cfg *scriptCfg) int
 
 	src := parse.Source{Name: name, Code: code, IsFile: true}
 	if cfg.CompileOnly {
-		parseErr, _, compileErr := ev.Check(src, fds[2])
+		var parseErr, compileErr error
 		if cfg.JSON {
-			fmt.Fprintf(fds[1], "%s\n", errorsToJSON(parseErr, compileErr))
+			// Lint finds the same errors as Check, and returns deprecation
+			// warnings instead of writing them.
+			var warnings []*diag.Error
+			parseErr, compileErr, warnings = ev.Lint(src)
+			fmt.Fprintf(fds[1], "%s\n",
+				errorsToJSON(parseErr, compileErr, deprecations(warnings)))
 		} else {
+			parseErr, _, compileErr = ev.Check(src, fds[2])
 			if parseErr != nil {
 				diag.ShowError(fds[2], parseErr)
 			}
@@ -62,7 +73,7 @@ func script(ev *eval.Evaler, fds [3]*os.File, args []string, cfg *scriptCfg) int
 			return 2
 		}
 	} else {
-		err := evalInTTY(fds, ev, nil, src)
+		err := evalInTTY(fds, ev, nil, src, cfg.DryRun)
 		if err != nil {
 			diag.ShowError(fds[2], err)
 			return 2
@@ -72,6 +83,81 @@ func script(ev *eval.Evaler, fds [3]*os.File, args []string, cfg *scriptCfg) int
 	return 0
 }
 
+// Configuration for running code from -e.
+type exprsCfg struct {
+	Exprs []string
+	// Run the last piece of code once for each line of stdin, after running
+	// the others once.
+	EachLine bool
+	// Print $line after running the code for each line. Implies EachLine.
+	PrintLine bool
+	// Print external commands instead of executing them.
+	DryRun bool
+}
+
+// Executes the code from -e flags in order, stopping at the first error.
+func exprs(ev *eval.Evaler, fds [3]*os.File, args []string, cfg *exprsCfg) int {
+	ev.Args = vals.MakeListSlice(args)
+	srcs := make([]parse.Source, len(cfg.Exprs))
+	for i, expr := range cfg.Exprs {
+		name := "code from -e"
+		if len(cfg.Exprs) > 1 {
+			name = fmt.Sprintf("code from -e #%d", i+1)
+		}
+		srcs[i] = parse.Source{Name: name, Code: expr, IsFile: true}
+	}
+	evalAll := func(fds [3]*os.File, srcs []parse.Source) bool {
+		for _, src := range srcs {
+			if err := evalInTTY(fds, ev, nil, src, cfg.DryRun); err != nil {
+				diag.ShowError(fds[2], err)
+				return false
+			}
+		}
+		return true
+	}
+
+	if !cfg.EachLine {
+		if !evalAll(fds, srcs) {
+			return 2
+		}
+		return 0
+	}
+
+	// The code can't read stdin, since the lines are read from it.
+	codeFds := [3]*os.File{eval.DevNull, fds[1], fds[2]}
+	// Only the last piece of code is run for each line. The ones before it
+	// are run once, so that they can set things up.
+	must.OK(ev.SetGlobalVar("line", ""))
+	if !evalAll(codeFds, srcs[:len(srcs)-1]) {
+		return 2
+	}
+	lineSrcs := srcs[len(srcs)-1:]
+	in := bufio.NewReader(fds[0])
+	for {
+		line, err := in.ReadString('\n')
+		if line != "" {
+			if err := ev.SetGlobalVar("line", strutil.ChopLineEnding(line)); err != nil {
+				fmt.Fprintln(fds[2], "cannot set $line:", err)
+				return 2
+			}
+			if !evalAll(codeFds, lineSrcs) {
+				return 2
+			}
+			if cfg.PrintLine {
+				v, _ := ev.GlobalVar("line")
+				fmt.Fprintln(fds[1], vals.ToString(v))
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintln(fds[2], "cannot read stdin:", err)
+				return 2
+			}
+			return 0
+		}
+	}
+}
+
 var errSourceNotUTF8 = errors.New("source is not UTF-8")
 
 func readFileUTF8(fname string) (string, error) {
@@ -91,19 +177,33 @@ type errorInJSON struct {
 	Start    int    `json:"start"`
 	End      int    `json:"end"`
 	Message  string `json:"message"`
+	// Either "error" or "warning".
+	Severity string `json:"severity"`
 }
 
-// Converts parse and compilation errors into JSON.
-func errorsToJSON(parseErr, compileErr error) []byte {
-	var converted []errorInJSON
-	for _, e := range parse.UnpackErrors(parseErr) {
-		converted = append(converted,
-			errorInJSON{e.Context.Name, e.Context.From, e.Context.To, e.Message})
+// Returns the deprecation warnings among the warnings from Evaler.Lint.
+func deprecations(warnings []*diag.Error) []*diag.Error {
+	var deps []*diag.Error
+	for _, w := range warnings {
+		if w.Type == "deprecation" {
+			deps = append(deps, w)
+		}
 	}
-	for _, e := range eval.UnpackCompilationErrors(compileErr) {
-		converted = append(converted,
-			errorInJSON{e.Context.Name, e.Context.From, e.Context.To, e.Message})
+	return deps
+}
+
+// Converts parse and compilation errors and warnings into JSON.
+func errorsToJSON(parseErr, compileErr error, warnings []*diag.Error) []byte {
+	converted := []errorInJSON{}
+	add := func(es []*diag.Error, severity string) {
+		for _, e := range es {
+			converted = append(converted, errorInJSON{
+				e.Context.Name, e.Context.From, e.Context.To, e.Message, severity})
+		}
 	}
+	add(parse.UnpackErrors(parseErr), "error")
+	add(eval.UnpackCompilationErrors(compileErr), "error")
+	add(warnings, "warning")
 
 	jsonError, errMarshal := json.Marshal(converted)
 	if errMarshal != nil {
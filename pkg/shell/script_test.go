@@ -3,11 +3,58 @@ package shell
 import (
 	"testing"
 
+	"src.elv.sh/pkg/env"
 	"src.elv.sh/pkg/must"
 	. "src.elv.sh/pkg/prog/progtest"
 	"src.elv.sh/pkg/testutil"
 )
 
+func TestExprs(t *testing.T) {
+	setupCleanHomePaths(t)
+
+	Test(t, &Program{},
+		ThatElvish("-e", "var x = foo", "-e", "echo $x $@args", "a", "b").
+			WritesStdout("foo a b\n"),
+		ThatElvish("-e", "fail bad", "-e", "echo unreachable").
+			ExitsWith(2).
+			WritesStderrContaining("code from -e #1"),
+		ThatElvish("-e", "echo [").
+			ExitsWith(2).
+			WritesStderrContaining("code from -e"),
+
+		ThatElvish("-n", "-e", "var n = 0", "-e", "set n = (+ $n 1); echo $n $line").
+			WithStdin("foo\nbar\n").
+			WritesStdout("1 foo\n2 bar\n"),
+		ThatElvish("-n", "-e", "echo $line").
+			WithStdin("").
+			DoesNothing(),
+		ThatElvish("-p", "-e", "use str", "-e", "set line = (str:to-upper $line)").
+			WithStdin("foo\r\nbar").
+			WritesStdout("FOO\nBAR\n"),
+		ThatElvish("-n", "-e", "if (==s $line b) { fail bad }; echo $line").
+			WithStdin("a\nb\nc\n").
+			ExitsWith(2).
+			WritesStdout("a\n").
+			WritesStderrContaining("bad"),
+
+		ThatElvish("-c", "-e", "echo").
+			ExitsWith(2).
+			WritesStderrContaining("-c and -e cannot be used together"),
+		ThatElvish("-compileonly", "-e", "echo").
+			ExitsWith(2).
+			WritesStderrContaining("-compileonly cannot be used with -e"),
+		ThatElvish("-dry-run").
+			ExitsWith(2).
+			WritesStderrContaining("-dry-run can only be used with a script, -c or -e"),
+		ThatElvish("-norc", "-rc", "rc.elv").
+			ExitsWith(2).
+			WritesStderrContaining("-norc and -rc cannot be used together"),
+		ThatElvish("-n", "foo.elv").
+			ExitsWith(2).
+			WritesStderrContaining("-n and -p can only be used with -e"),
+	)
+}
+
 func TestScript(t *testing.T) {
 	setupCleanHomePaths(t)
 	testutil.InTempDir(t)
@@ -18,6 +65,13 @@ func TestScript(t *testing.T) {
 		ThatElvish("hello.elv").WritesStdout("hello\n"),
 		ThatElvish("-c", "echo hello").WritesStdout("hello\n"),
 
+		ThatElvish("-dry-run", "-c", "echo builtin; e:echo 'a b'").
+			WritesStdout("builtin\n").
+			WritesStderr("echo 'a b'\n"),
+		ThatElvish("-dry-run", "-e", "echo builtin; e:echo 'a b'").
+			WritesStdout("builtin\n").
+			WritesStderr("echo 'a b'\n"),
+
 		ThatElvish("invalid-utf8.elv").
 			ExitsWith(2).
 			WritesStderrContaining("cannot read script"),
@@ -36,11 +90,11 @@ func TestScript(t *testing.T) {
 		// parse error with -compileonly -json
 		ThatElvish("-compileonly", "-json", "-c", "echo [").
 			ExitsWith(2).
-			WritesStdout(`[{"fileName":"code from -c","start":6,"end":6,"message":"should be ']'"}]`+"\n"),
+			WritesStdout(`[{"fileName":"code from -c","start":6,"end":6,"message":"should be ']'","severity":"error"}]`+"\n"),
 		// multiple parse errors with -compileonly -json
 		ThatElvish("-compileonly", "-json", "-c", "echo [{").
 			ExitsWith(2).
-			WritesStdout(`[{"fileName":"code from -c","start":7,"end":7,"message":"should be ',' or '}'"},{"fileName":"code from -c","start":7,"end":7,"message":"should be ']'"}]`+"\n"),
+			WritesStdout(`[{"fileName":"code from -c","start":7,"end":7,"message":"should be ',' or '}'","severity":"error"},{"fileName":"code from -c","start":7,"end":7,"message":"should be ']'","severity":"error"}]`+"\n"),
 
 		// compilation error
 		ThatElvish("-c", "echo $a").
@@ -53,11 +107,11 @@ func TestScript(t *testing.T) {
 		// compilation error with -compileonly -json
 		ThatElvish("-compileonly", "-json", "-c", "echo $a").
 			ExitsWith(2).
-			WritesStdout(`[{"fileName":"code from -c","start":5,"end":7,"message":"variable $a not found"}]`+"\n"),
+			WritesStdout(`[{"fileName":"code from -c","start":5,"end":7,"message":"variable $a not found","severity":"error"}]`+"\n"),
 		// parse error and compilation error with -compileonly
 		ThatElvish("-compileonly", "-json", "-c", "echo [$a").
 			ExitsWith(2).
-			WritesStdout(`[{"fileName":"code from -c","start":8,"end":8,"message":"should be ']'"},{"fileName":"code from -c","start":6,"end":8,"message":"variable $a not found"}]`+"\n"),
+			WritesStdout(`[{"fileName":"code from -c","start":8,"end":8,"message":"should be ']'","severity":"error"},{"fileName":"code from -c","start":6,"end":8,"message":"variable $a not found","severity":"error"}]`+"\n"),
 
 		// exception
 		ThatElvish("-c", "fail failure").
@@ -67,5 +121,40 @@ func TestScript(t *testing.T) {
 		// exception with -compileonly
 		ThatElvish("-compileonly", "-c", "fail failure").
 			ExitsWith(0),
+		// no errors with -compileonly -json
+		ThatElvish("-compileonly", "-json", "-c", "echo").
+			WritesStdout("[]\n"),
+		// deprecation warning with -compileonly -json
+		ThatElvish("-deprecation-level", "19", "-compileonly", "-json", "-c", "float64 1").
+			WritesStdout(`[{"fileName":"code from -c","start":0,"end":7,"message":"the \"float64\" command is deprecated; use \"num\" or \"inexact-num\" instead","severity":"warning"}]`+"\n"),
+	)
+}
+
+func TestScript_InteractiveAndLogin(t *testing.T) {
+	setupCleanHomePaths(t)
+	xdgConfigHome := testutil.Setenv(t, env.XDG_CONFIG_HOME, testutil.TempDir(t))
+	testutil.ApplyDirIn(testutil.Dir{
+		"elvish": testutil.Dir{
+			"login.elv": "var from-login = login",
+			"rc.elv":    "var from-rc = rc",
+		},
+	}, xdgConfigHome)
+	testutil.InTempDir(t)
+	must.WriteFile("a.elv", "echo $from-rc")
+
+	Test(t, &Program{},
+		ThatElvish("-i", "-c", "echo $from-rc").WritesStdout("rc\n"),
+		ThatElvish("-i", "-e", "echo $from-rc").WritesStdout("rc\n"),
+		ThatElvish("-i", "a.elv").WritesStdout("rc\n"),
+		ThatElvish("-i", "-norc", "-c", "echo $from-rc").
+			ExitsWith(2).
+			WritesStderrContaining("variable $from-rc not found"),
+		ThatElvish("-c", "echo $from-rc").
+			ExitsWith(2).
+			WritesStderrContaining("variable $from-rc not found"),
+
+		ThatElvish("-l", "-c", "echo $from-login").WritesStdout("login\n"),
+		ThatElvish("-l", "-i", "-c", "echo $from-login $from-rc").
+			WritesStdout("login rc\n"),
 	)
 }
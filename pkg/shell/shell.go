@@ -8,16 +8,19 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"src.elv.sh/pkg/cli/term"
 	"src.elv.sh/pkg/daemon/daemondefs"
+	"src.elv.sh/pkg/diag"
 	"src.elv.sh/pkg/env"
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/logutil"
 	"src.elv.sh/pkg/mods"
 	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/prog"
+	"src.elv.sh/pkg/remotemod"
 	"src.elv.sh/pkg/sys"
 )
 
@@ -27,27 +30,56 @@ var logger = logutil.GetLogger("[shell] ")
 type Program struct {
 	ActivateDaemon daemondefs.ActivateFunc
 
-	codeInArg   bool
-	compileOnly bool
-	noRC        bool
-	rc          string
-	json        *bool
-	daemonPaths *prog.DaemonPaths
+	forceInteractive bool
+	codeInArg        bool
+	exprs            stringsFlag
+	eachLine         bool
+	printLine        bool
+	compileOnly      bool
+	dryRun           bool
+	lint             bool
+	dumpBindings     bool
+	dumpConfig       bool
+	noRC             bool
+	private          bool
+	rc               string
+	timing           bool
+	json             *bool
+	login            *bool
+	daemonPaths      *prog.DaemonPaths
 }
 
 func (p *Program) RegisterFlags(fs *prog.FlagSet) {
-	// Support -i so that programs that expect shells to support it (like
-	// "script") don't error when they invoke Elvish.
-	fs.Bool("i", false,
-		"A no-op flag, introduced for POSIX compatibility")
+	fs.BoolVar(&p.forceInteractive, "i", false,
+		"Read the RC file like in interactive mode, even when running a script or code\nfrom -c or -e")
+	p.login = fs.Login()
 	fs.BoolVar(&p.codeInArg, "c", false,
 		"Treat the first argument as code to execute")
+	p.exprs = nil
+	fs.Var(&p.exprs, "e",
+		"Execute `code`; can be specified multiple times, in which case the code is\nexecuted in order. All arguments are put in $args")
+	fs.BoolVar(&p.eachLine, "n", false,
+		"Execute the code from the last -e once for each line of stdin, with the line in\n$line, after executing the code from the other -e flags once")
+	fs.BoolVar(&p.printLine, "p", false,
+		"Like -n, but also print $line after executing the code for each line")
 	fs.BoolVar(&p.compileOnly, "compileonly", false,
 		"Parse and compile Elvish code without executing it")
+	fs.BoolVar(&p.dryRun, "dry-run", false,
+		"Print external commands to stderr instead of executing them when running a script\nor code from -c or -e; builtin commands still run")
+	fs.BoolVar(&p.lint, "lint", false,
+		"Check Elvish code in the given files, or stdin if none is given, for errors and\nlikely mistakes without executing it")
+	fs.BoolVar(&p.dumpBindings, "dump-default-bindings", false,
+		"Output the default key bindings of the editor and quit")
+	fs.BoolVar(&p.dumpConfig, "dump-config", false,
+		"Output the editor variables after reading the RC file, and where their values come from, and quit")
 	fs.BoolVar(&p.noRC, "norc", false,
 		"Don't read the RC file when running interactively")
+	fs.BoolVar(&p.private, "private", false,
+		"Start an interactive session that doesn't save anything, without connecting to\nthe storage daemon")
 	fs.StringVar(&p.rc, "rc", "",
 		"Path to the RC file when running interactively")
+	fs.BoolVar(&p.timing, "timing", false,
+		"Print how long startup phases take when running interactively")
 
 	p.json = fs.JSON()
 	if p.ActivateDaemon != nil {
@@ -56,26 +88,99 @@ func (p *Program) RegisterFlags(fs *prog.FlagSet) {
 }
 
 func (p *Program) Run(fds [3]*os.File, args []string) error {
+	if p.noRC && p.rc != "" {
+		return prog.BadUsage("-norc and -rc cannot be used together")
+	}
+
+	if p.dumpBindings || p.dumpConfig {
+		if len(args) > 0 {
+			return prog.BadUsage("arguments are not allowed with -dump-default-bindings or -dump-config")
+		}
+		ev := p.makeEvaler(fds, p.dumpConfig)
+		defer ev.PreExit()
+		return prog.Exit(dump(ev, fds, &dumpCfg{
+			Bindings: p.dumpBindings, RC: ev.EffectiveRcPath, JSON: *p.json}))
+	}
+
+	if p.lint {
+		if p.codeInArg || len(p.exprs) > 0 || p.compileOnly {
+			return prog.BadUsage("-lint cannot be used with -c, -e or -compileonly")
+		}
+		ev := p.makeEvaler(fds, false)
+		defer ev.PreExit()
+		return prog.Exit(lint(ev, fds, args, &lintCfg{JSON: *p.json}))
+	}
+
+	if len(p.exprs) > 0 {
+		if p.codeInArg {
+			return prog.BadUsage("-c and -e cannot be used together")
+		}
+		if p.compileOnly {
+			return prog.BadUsage("-compileonly cannot be used with -e")
+		}
+	} else if p.eachLine || p.printLine {
+		return prog.BadUsage("-n and -p can only be used with -e")
+	}
+
+	var t *timing
+	interactive := len(args) == 0 && len(p.exprs) == 0
+	if interactive && p.dryRun {
+		return prog.BadUsage("-dry-run can only be used with a script, -c or -e")
+	}
+	if interactive && p.timing {
+		t = newTiming()
+	}
+
 	cleanup1 := incSHLVL()
 	defer cleanup1()
 	cleanup2 := initSignal(fds)
 	defer cleanup2()
 
-	interactive := len(args) == 0
-	ev := p.makeEvaler(fds[2], interactive)
+	// With -i, the RC file is also read when running code, unless it is only
+	// compiled.
+	readRC := interactive || (p.forceInteractive && !p.compileOnly)
+
+	var ev *eval.Evaler
+	t.measure("evaler init", func() { ev = p.makeEvaler(fds, readRC) })
 	defer ev.PreExit()
 
+	var login string
+	if *p.login && !p.compileOnly {
+		var err error
+		login, err = loginPath()
+		if err != nil {
+			fmt.Fprintln(fds[2], "Warning:", err)
+		}
+	}
+
 	if !interactive {
-		exit := script(
-			ev, fds, args, &scriptCfg{
-				Cmd: p.codeInArg, CompileOnly: p.compileOnly, JSON: *p.json})
+		for _, f := range []struct{ path, phase string }{
+			{login, "login"}, {ev.EffectiveRcPath, "rc"}} {
+			if f.path == "" {
+				continue
+			}
+			if err := sourceRC(fds, ev, nil, f.path, f.phase, nil); err != nil {
+				diag.ShowError(fds[2], err)
+			}
+		}
+		var exit int
+		if len(p.exprs) > 0 {
+			exit = exprs(ev, fds, args, &exprsCfg{
+				Exprs: p.exprs, EachLine: p.eachLine || p.printLine,
+				PrintLine: p.printLine, DryRun: p.dryRun})
+		} else {
+			exit = script(
+				ev, fds, args, &scriptCfg{
+					Cmd: p.codeInArg, CompileOnly: p.compileOnly, DryRun: p.dryRun,
+					JSON: *p.json})
+		}
 		return prog.Exit(exit)
 	}
 
 	var spawnCfg *daemondefs.SpawnConfig
-	if p.ActivateDaemon != nil {
+	if p.ActivateDaemon != nil && !p.private {
 		var err error
-		spawnCfg, err = daemonPaths(p.daemonPaths, fds[2])
+		spawnCfg, err = DaemonSpawnConfig(p.daemonPaths, fds[2])
 		if err != nil {
 			fmt.Fprintln(fds[2], "Warning:", err)
 			fmt.Fprintln(fds[2], "Storage daemon may not function.")
@@ -83,17 +188,19 @@ func (p *Program) Run(fds [3]*os.File, args []string) error {
 	}
 
 	interact(ev, fds, &interactCfg{
-		RC:             ev.EffectiveRcPath,
-		ActivateDaemon: p.ActivateDaemon, SpawnConfig: spawnCfg})
+		Login: login, RC: ev.EffectiveRcPath,
+		ActivateDaemon: p.ActivateDaemon, SpawnConfig: spawnCfg,
+		Private: p.private, Timing: t})
 	return nil
 }
 
-// Creates an Evaler, sets the module search directories and installs all the
-// standard builtin modules.
+// Creates an Evaler, sets the module search directories, sets up loading of
+// remote modules and installs all the standard builtin modules.
 //
-// It writes a warning message to the supplied Writer if it could not initialize
-// module search directories.
-func (p *Program) makeEvaler(stderr io.Writer, interactive bool) *eval.Evaler {
+// It writes a warning message to stderr if it could not initialize module
+// search directories.
+func (p *Program) makeEvaler(fds [3]*os.File, interactive bool) *eval.Evaler {
+	stderr := fds[2]
 	ev := eval.NewEvaler()
 
 	var errRc error
@@ -124,6 +231,14 @@ func (p *Program) makeEvaler(stderr io.Writer, interactive bool) *eval.Evaler {
 		ev.LibDirs = libs
 	}
 
+	remoteDir, err := remoteModulesPath()
+	if err != nil {
+		fmt.Fprintln(stderr, "Warning:", err)
+	} else {
+		ev.LoadRemoteModule = remotemod.New(remotemod.Config{
+			Dir: remoteDir, Trust: trustOnTerminal(fds)}).Load
+	}
+
 	mods.AddTo(ev)
 	return ev
 }
@@ -149,7 +264,7 @@ func initSignal(fds [3]*os.File) func() {
 	sigCh := sys.NotifySignals()
 	go func() {
 		for sig := range sigCh {
-			logger.Println("signal", sig)
+			logger.Infof("signal %v", sig)
 			handleSignal(sig, fds[2])
 		}
 	}()
@@ -160,16 +275,85 @@ func initSignal(fds [3]*os.File) func() {
 	}
 }
 
-func evalInTTY(fds [3]*os.File, ev *eval.Evaler, ed editor, src parse.Source) error {
+func evalInTTY(fds [3]*os.File, ev *eval.Evaler, ed editor, src parse.Source, dryRun bool) error {
+	tree, err := parse.Parse(src, parse.Config{WarningWriter: fds[2]})
+	if err != nil {
+		if ed != nil {
+			ed.RunAfterCommandHooks(src, 0, err)
+		}
+		return err
+	}
+	return evalTreeInTTY(fds, ev, ed, tree, dryRun)
+}
+
+// Like evalInTTY, but evaluates code that has already been parsed.
+func evalTreeInTTY(fds [3]*os.File, ev *eval.Evaler, ed editor, tree parse.Tree, dryRun bool) error {
 	start := time.Now()
-	ports, cleanup := eval.PortsFromFiles(fds, ev.ValuePrefix())
+	portFiles := fds
+	if r, ok := ed.(outputRecorder); ok {
+		if w := r.RecordOutput(); w != nil {
+			var finish func()
+			portFiles[1], finish = teeOutput(fds[1], w)
+			defer finish()
+		}
+	}
+	ports, cleanup := eval.PortsFromFiles(portFiles, ev.ValuePrefix())
 	defer cleanup()
 	restore := term.SetupForEval(fds[0], fds[1])
 	defer restore()
-	err := ev.Eval(src, eval.EvalCfg{
-		Ports: ports, Interrupt: eval.ListenInterrupts, PutInFg: true})
+	// Like POSIX shells, only interactive shells, which have an editor, do job
+	// control.
+	err := ev.EvalTree(tree, eval.EvalCfg{
+		Ports: ports, Interrupt: eval.ListenInterrupts, PutInFg: true,
+		JobControl: ed != nil, DryRun: dryRun})
 	if ed != nil {
-		ed.RunAfterCommandHooks(src, time.Since(start).Seconds(), err)
+		ed.RunAfterCommandHooks(tree.Source, time.Since(start).Seconds(), err)
 	}
 	return err
 }
+
+// A flag that can be specified multiple times, collecting all the values.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, " ") }
+
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// Implemented by *edit.Editor.
+type outputRecorder interface {
+	RecordOutput() io.WriteCloser
+}
+
+// How long to wait for the output of a command to be copied after the command
+// finishes. The wait can only time out when background jobs started by the
+// command keep the output open.
+var teeOutputWait = 100 * time.Millisecond
+
+// Returns a pipe to use as the output of a command in place of out, with
+// everything written to it copied to both out and w, and a function to call
+// when the command finishes, which waits for the output to be copied and
+// closes w. If the pipe can't be created, returns out itself.
+func teeOutput(out *os.File, w io.WriteCloser) (*os.File, func()) {
+	r, pw, err := os.Pipe()
+	if err != nil {
+		logger.Warnf("cannot create pipe to record output: %v", err)
+		return out, func() { w.Close() }
+	}
+	done := make(chan struct{})
+	go func() {
+		io.Copy(io.MultiWriter(out, w), r)
+		r.Close()
+		close(done)
+	}()
+	return pw, func() {
+		pw.Close()
+		select {
+		case <-done:
+		case <-time.After(teeOutputWait):
+		}
+		w.Close()
+	}
+}
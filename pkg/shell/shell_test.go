@@ -1,6 +1,8 @@
 package shell
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
 	"path/filepath"
 	"testing"
@@ -76,6 +78,29 @@ func TestShell_LibPath_Legacy(t *testing.T) {
 	)
 }
 
+func TestShell_RemoteModules(t *testing.T) {
+	url := "https://example.com/mod.elv"
+	code := "echo remote mod"
+	sum := sha256.Sum256([]byte(code))
+	hexSum := hex.EncodeToString(sum[:])
+
+	xdgDataHome := testutil.TempDir(t)
+	testutil.ApplyDirIn(testutil.Dir{
+		"elvish": testutil.Dir{
+			"remote-modules": testutil.Dir{
+				"sums":          url + " " + hexSum + "\n",
+				hexSum + ".elv": code,
+			},
+		},
+	}, xdgDataHome)
+	testutil.Setenv(t, env.XDG_DATA_HOME, xdgDataHome)
+
+	// Trusted and cached modules are loaded without being fetched.
+	Test(t, &Program{},
+		ThatElvish("-c", "use "+url).WritesStdout("remote mod\n"),
+	)
+}
+
 // Most high-level tests against Program are specific to either script mode or
 // interactive mode, and are found in script_test.go and interact_test.go.
 
@@ -0,0 +1,99 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Records how long the phases of startup take, for the -timing flag. Methods
+// on a nil *timing do nothing except running the functions passed to them.
+type timing struct {
+	start  time.Time
+	mutex  sync.Mutex
+	phases []timingPhase
+	// Set when the report is written at the first prompt. Phases recorded
+	// after that are written with it as they are recorded.
+	write func(string)
+}
+
+type timingPhase struct {
+	name     string
+	duration time.Duration
+	// If not empty, shown instead of the duration, until the phase is
+	// recorded with a duration.
+	note string
+}
+
+func (p timingPhase) value() string {
+	if p.note != "" {
+		return p.note
+	}
+	return p.duration.String()
+}
+
+func newTiming() *timing { return &timing{start: time.Now()} }
+
+// Runs f and records how long it takes as a phase.
+func (t *timing) measure(name string, f func()) {
+	if t == nil {
+		f()
+		return
+	}
+	start := time.Now()
+	f()
+	t.add(timingPhase{name: name, duration: time.Since(start)})
+}
+
+// Records a phase, unless it has already been recorded with a duration.
+func (t *timing) add(phase timingPhase) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for i, p := range t.phases {
+		if p.name == phase.name {
+			if p.note == "" || phase.note != "" {
+				return
+			}
+			t.phases[i] = phase
+			t.writeLate(phase)
+			return
+		}
+	}
+	t.phases = append(t.phases, phase)
+	t.writeLate(phase)
+}
+
+// Writes a phase recorded after the report has been written. Must be called
+// with t.mutex held.
+func (t *timing) writeLate(phase timingPhase) {
+	if t.write != nil && phase.note == "" {
+		t.write(fmt.Sprintf("Startup timing: %s %s", phase.name, phase.value()))
+	}
+}
+
+// Records the time elapsed since the start as the time of the first prompt,
+// which must have just been shown, and writes the report of the phases
+// recorded so far with write. Only the first call has any effect.
+func (t *timing) firstPrompt(write func(string)) {
+	if t == nil {
+		return
+	}
+	total := time.Since(t.start)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.write != nil {
+		return
+	}
+	t.write = write
+	var sb strings.Builder
+	sb.WriteString("Startup timing:")
+	for _, phase := range t.phases {
+		fmt.Fprintf(&sb, "\n  %-14s %s", phase.name, phase.value())
+	}
+	fmt.Fprintf(&sb, "\n  %-14s %v", "first prompt", total)
+	write(sb.String())
+}
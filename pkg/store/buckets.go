@@ -3,6 +3,13 @@ package store
 const (
 	bucketCmd = "cmd"
 	bucketDir = "dir"
+	bucketKV  = "kv"
+
+	bucketSync      = "sync"
+	bucketCmdSync   = "cmd-sync"
+	bucketCmdOrigin = "cmd-origin"
+
+	bucketMeta = "meta"
 )
 
 // The following buckets were used before and are thus reserved:
@@ -3,22 +3,21 @@ package store
 import (
 	"bytes"
 	"encoding/binary"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 	. "src.elv.sh/pkg/store/storedefs"
 )
 
-func init() {
-	initDB["initialize command history table"] = func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(bucketCmd))
-		return err
-	}
+func initCmd(tx *bolt.Tx) error {
+	_, err := tx.CreateBucketIfNotExists([]byte(bucketCmd))
+	return err
 }
 
 // NextCmdSeq returns the next sequence number of the command history.
 func (s *dbStore) NextCmdSeq() (int, error) {
 	var seq uint64
-	err := s.db.View(func(tx *bolt.Tx) error {
+	err := s.view(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketCmd))
 		seq = b.Sequence() + 1
 		return nil
@@ -32,29 +31,37 @@ func (s *dbStore) AddCmd(cmd string) (int, error) {
 		seq uint64
 		err error
 	)
-	err = s.db.Update(func(tx *bolt.Tx) error {
+	err = s.update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketCmd))
 		seq, err = b.NextSequence()
 		if err != nil {
 			return err
 		}
-		return b.Put(marshalSeq(seq), []byte(cmd))
+		if err := b.Put(marshalSeq(seq), []byte(cmd)); err != nil {
+			return err
+		}
+		return putCmdMeta(tx, seq, cmdMeta{time: time.Now().UnixMilli()})
 	})
 	return int(seq), err
 }
 
 // DelCmd deletes a command history item with the given sequence number.
 func (s *dbStore) DelCmd(seq int) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	return s.update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketCmd))
-		return b.Delete(marshalSeq(uint64(seq)))
+		if err := b.Delete(marshalSeq(uint64(seq))); err != nil {
+			return err
+		}
+		// The origin of the command, if it came from another store, is kept
+		// in bucketCmdOrigin, so that syncing doesn't add it again.
+		return tx.Bucket([]byte(bucketCmdSync)).Delete(marshalSeq(uint64(seq)))
 	})
 }
 
 // Cmd queries the command history item with the specified sequence number.
 func (s *dbStore) Cmd(seq int) (string, error) {
 	var cmd string
-	err := s.db.View(func(tx *bolt.Tx) error {
+	err := s.view(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketCmd))
 		v := b.Get(marshalSeq(uint64(seq)))
 		if v == nil {
@@ -69,7 +76,7 @@ func (s *dbStore) Cmd(seq int) (string, error) {
 // IterateCmds iterates all the commands in the specified range, and calls the
 // callback with the content of each command sequentially.
 func (s *dbStore) IterateCmds(from, upto int, f func(Cmd)) error {
-	return s.db.View(func(tx *bolt.Tx) error {
+	return s.view(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketCmd))
 		c := b.Cursor()
 		for k, v := c.Seek(marshalSeq(uint64(from))); k != nil && unmarshalSeq(k) < uint64(upto); k, v = c.Next() {
@@ -92,7 +99,7 @@ func (s *dbStore) CmdsWithSeq(from, upto int) ([]Cmd, error) {
 // with the given prefix.
 func (s *dbStore) NextCmd(from int, prefix string) (Cmd, error) {
 	var cmd Cmd
-	err := s.db.View(func(tx *bolt.Tx) error {
+	err := s.view(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketCmd))
 		c := b.Cursor()
 		p := []byte(prefix)
@@ -111,7 +118,7 @@ func (s *dbStore) NextCmd(from int, prefix string) (Cmd, error) {
 // with the given prefix.
 func (s *dbStore) PrevCmd(upto int, prefix string) (Cmd, error) {
 	var cmd Cmd
-	err := s.db.View(func(tx *bolt.Tx) error {
+	err := s.view(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketCmd))
 		c := b.Cursor()
 		p := []byte(prefix)
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+	. "src.elv.sh/pkg/store/storedefs"
+)
+
+// To sync the command history with other stores, each store has a random ID,
+// kept in bucketSync. For each command, bucketCmdSync keeps when it was added
+// and, if it came from another store, the ID of that store and its sequence
+// number there. For each command that came from another store, bucketCmdOrigin
+// maps its origin to its sequence number in this store.
+//
+// Commands added before these buckets existed have no entries in them, and are
+// synced as if they were added to this store at an unknown time.
+
+var errBadOrigin = errors.New("origin of command must be non-empty and contain no NUL")
+
+func initCmdSync(tx *bolt.Tx) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(bucketSync))
+	if err != nil {
+		return err
+	}
+	if b.Get([]byte("id")) == nil {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return err
+		}
+		err := b.Put([]byte("id"), []byte(hex.EncodeToString(id)))
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := tx.CreateBucketIfNotExists([]byte(bucketCmdSync)); err != nil {
+		return err
+	}
+	_, err = tx.CreateBucketIfNotExists([]byte(bucketCmdOrigin))
+	return err
+}
+
+// Metadata of a command kept in bucketCmdSync. The origin is empty for
+// commands added to this store.
+type cmdMeta struct {
+	time   int64
+	origin string
+	seq    uint64
+}
+
+func putCmdMeta(tx *bolt.Tx, seq uint64, m cmdMeta) error {
+	v := marshalSeq(uint64(m.time))
+	if m.origin != "" {
+		v = append(append(v, marshalSeq(m.seq)...), m.origin...)
+	}
+	return tx.Bucket([]byte(bucketCmdSync)).Put(marshalSeq(seq), v)
+}
+
+func getCmdMeta(tx *bolt.Tx, seq uint64) cmdMeta {
+	v := tx.Bucket([]byte(bucketCmdSync)).Get(marshalSeq(seq))
+	if len(v) < 8 {
+		return cmdMeta{}
+	}
+	m := cmdMeta{time: int64(unmarshalSeq(v))}
+	if len(v) > 16 {
+		m.seq, m.origin = unmarshalSeq(v[8:]), string(v[16:])
+	}
+	return m
+}
+
+func originKey(origin string, seq uint64) []byte {
+	return append(append([]byte(origin), 0), marshalSeq(seq)...)
+}
+
+func storeID(tx *bolt.Tx) string {
+	return string(tx.Bucket([]byte(bucketSync)).Get([]byte("id")))
+}
+
+// SyncCmds returns all the commands in the command history, with the
+// metadata needed for syncing them with other stores.
+func (s *dbStore) SyncCmds() ([]SyncCmd, error) {
+	var cmds []SyncCmd
+	err := s.view(func(tx *bolt.Tx) error {
+		id := storeID(tx)
+		c := tx.Bucket([]byte(bucketCmd)).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			seq := unmarshalSeq(k)
+			m := getCmdMeta(tx, seq)
+			if m.origin == "" {
+				m.origin, m.seq = id, seq
+			}
+			cmds = append(cmds, SyncCmd{
+				Text: string(v), Time: m.time, Origin: m.origin, Seq: int(m.seq)})
+		}
+		return nil
+	})
+	return cmds, err
+}
+
+// MergeCmds adds the commands that came from other stores and are not known to
+// this store yet to the end of the command history, in the order they were
+// first added, and returns how many were added. Commands that have been
+// deleted from this store are not added again.
+func (s *dbStore) MergeCmds(cmds []SyncCmd) (int, error) {
+	for _, cmd := range cmds {
+		if cmd.Origin == "" || strings.ContainsRune(cmd.Origin, 0) || cmd.Seq < 0 {
+			return 0, errBadOrigin
+		}
+	}
+	// Sort the commands the same way on all stores, so that they end up in
+	// the same relative order.
+	cmds = append([]SyncCmd(nil), cmds...)
+	sort.SliceStable(cmds, func(i, j int) bool {
+		a, b := cmds[i], cmds[j]
+		if a.Time != b.Time {
+			return a.Time < b.Time
+		}
+		if a.Origin != b.Origin {
+			return a.Origin < b.Origin
+		}
+		return a.Seq < b.Seq
+	})
+	added := 0
+	err := s.update(func(tx *bolt.Tx) error {
+		added = 0
+		id := storeID(tx)
+		b := tx.Bucket([]byte(bucketCmd))
+		origins := tx.Bucket([]byte(bucketCmdOrigin))
+		for _, cmd := range cmds {
+			key := originKey(cmd.Origin, uint64(cmd.Seq))
+			if cmd.Origin == id || origins.Get(key) != nil {
+				continue
+			}
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			if err := b.Put(marshalSeq(seq), []byte(cmd.Text)); err != nil {
+				return err
+			}
+			m := cmdMeta{time: cmd.Time, origin: cmd.Origin, seq: uint64(cmd.Seq)}
+			if err := putCmdMeta(tx, seq, m); err != nil {
+				return err
+			}
+			if err := origins.Put(key, marshalSeq(seq)); err != nil {
+				return err
+			}
+			added++
+		}
+		return nil
+	})
+	return added, err
+}
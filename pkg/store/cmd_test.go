@@ -5,8 +5,30 @@ import (
 
 	"src.elv.sh/pkg/store"
 	"src.elv.sh/pkg/store/storetest"
+	"src.elv.sh/pkg/testutil"
 )
 
 func TestCmd(t *testing.T) {
 	storetest.TestCmd(t, store.MustTempStore(t))
 }
+
+func TestCmdSync(t *testing.T) {
+	storetest.TestCmdSync(t, store.MustTempStore(t))
+}
+
+func TestCmdSync_IDIsPersistent(t *testing.T) {
+	testutil.InTempDir(t)
+	origin := func() string {
+		st, err := store.NewStore("db")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer st.Close()
+		st.AddCmd("echo")
+		cmds, _ := st.SyncCmds()
+		return cmds[len(cmds)-1].Origin
+	}
+	if first, second := origin(), origin(); first != second {
+		t.Errorf("got origins %q and %q after reopening the store, want the same", first, second)
+	}
+}
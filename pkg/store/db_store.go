@@ -1,7 +1,7 @@
 package store
 
 import (
-	"fmt"
+	"os"
 	"sync"
 	"time"
 
@@ -11,7 +11,6 @@ import (
 )
 
 var logger = logutil.GetLogger("[store] ")
-var initDB = map[string](func(*bolt.Tx) error){}
 
 // DBStore is the permanent storage backend for elvish. It is not thread-safe.
 // In particular, the store may be closed while another goroutine is still
@@ -21,12 +20,15 @@ var initDB = map[string](func(*bolt.Tx) error){}
 // call wg.Done() in the spawned goroutine after the operation is finished.
 type DBStore interface {
 	Store
+	Compact() (before, after int64, err error)
 	Close() error
 }
 
 type dbStore struct {
-	db *bolt.DB
-	wg sync.WaitGroup // used for registering outstanding operations on the store
+	// Held for writing while the database is being replaced by Compact.
+	mutex sync.RWMutex
+	db    *bolt.DB
+	wg    sync.WaitGroup // used for registering outstanding operations on the store
 }
 
 func dbWithDefaultOptions(dbname string) (*bolt.DB, error) {
@@ -46,25 +48,18 @@ func NewStore(dbname string) (DBStore, error) {
 	return NewStoreFromDB(db)
 }
 
-// NewStoreFromDB creates a new Store from a bolt DB.
+// NewStoreFromDB creates a new Store from a bolt DB, migrating it to the
+// latest schema version first. If the database can't be migrated, for example
+// because it was created by a newer version of Elvish, it is closed and an
+// error is returned.
 func NewStoreFromDB(db *bolt.DB) (DBStore, error) {
-	logger.Println("initializing store")
-	defer logger.Println("initialized store")
-	st := &dbStore{
-		db: db,
-		wg: sync.WaitGroup{},
+	logger.Debugf("initializing store")
+	defer logger.Debugf("initialized store")
+	if _, _, err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
 	}
-
-	err := db.Update(func(tx *bolt.Tx) error {
-		for name, fn := range initDB {
-			err := fn(tx)
-			if err != nil {
-				return fmt.Errorf("failed to %s: %v", name, err)
-			}
-		}
-		return nil
-	})
-	return st, err
+	return &dbStore{db: db}, nil
 }
 
 // Close waits for all outstanding operations to finish, and closes the
@@ -74,5 +69,72 @@ func (s *dbStore) Close() error {
 		return nil
 	}
 	s.wg.Wait()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	return s.db.Close()
 }
+
+// Compact rewrites the database file to reclaim the space left unused by
+// deleted entries, and returns the sizes of the file before and after.
+// Operations on the store wait until it finishes.
+func (s *dbStore) Compact() (before, after int64, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	dbname := s.db.Path()
+	info, err := os.Stat(dbname)
+	if err != nil {
+		return 0, 0, err
+	}
+	// Write to a new file next to the database, keeping its permission, and
+	// replace the database with it when done.
+	tmpname := dbname + ".compact"
+	os.Remove(tmpname)
+	dst, err := bolt.Open(tmpname, info.Mode().Perm(), &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.Remove(tmpname)
+	err = bolt.Compact(dst, s.db, 0)
+	if err2 := dst.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	tmpInfo, err := os.Stat(tmpname)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// The store keeps the database open, so no other process can have opened
+	// it in the meantime.
+	if err := s.db.Close(); err != nil {
+		return 0, 0, err
+	}
+	errRename := os.Rename(tmpname, dbname)
+	db, err := dbWithDefaultOptions(dbname)
+	if err != nil {
+		// Keep the closed database, so that operations fail instead of
+		// panicking.
+		return 0, 0, err
+	}
+	s.db = db
+	if errRename != nil {
+		return 0, 0, errRename
+	}
+	return info.Size(), tmpInfo.Size(), nil
+}
+
+// Runs f in a read-only transaction.
+func (s *dbStore) view(f func(*bolt.Tx) error) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.db.View(f)
+}
+
+// Runs f in a read-write transaction.
+func (s *dbStore) update(f func(*bolt.Tx) error) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.db.Update(f)
+}
@@ -0,0 +1,45 @@
+package store_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestCompact(t *testing.T) {
+	testutil.InTempDir(t)
+	st, err := store.NewStore("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	for i := 0; i < 100; i++ {
+		st.AddCmd(strings.Repeat("x", 1000))
+	}
+	for i := 0; i < 99; i++ {
+		st.DelCmd(i + 1)
+	}
+
+	before, after, err := st.Compact()
+	if err != nil {
+		t.Fatalf("Compact -> error %v", err)
+	}
+	if after >= before {
+		t.Errorf("Compact -> sizes %v, %v, want smaller size after", before, after)
+	}
+
+	// The store keeps working with the compacted database.
+	cmds, err := st.CmdsWithSeq(0, -1)
+	if err != nil || len(cmds) != 1 || cmds[0].Seq != 100 {
+		t.Errorf("CmdsWithSeq -> (%v, %v), want the only command left", cmds, err)
+	}
+	if seq, _ := st.NextCmdSeq(); seq != 101 {
+		t.Errorf("NextCmdSeq -> %v, want 101", seq)
+	}
+	if _, err := os.Stat("db.compact"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left after Compact")
+	}
+}
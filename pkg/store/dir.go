@@ -15,11 +15,9 @@ const (
 	DirScorePrecision = 6
 )
 
-func init() {
-	initDB["initialize directory history table"] = func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(bucketDir))
-		return err
-	}
+func initDir(tx *bolt.Tx) error {
+	_, err := tx.CreateBucketIfNotExists([]byte(bucketDir))
+	return err
 }
 
 func marshalScore(score float64) []byte {
@@ -33,7 +31,7 @@ func unmarshalScore(data []byte) float64 {
 
 // AddDir adds a directory to the directory history.
 func (s *dbStore) AddDir(d string, incFactor float64) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	return s.update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketDir))
 
 		c := b.Cursor()
@@ -54,7 +52,7 @@ func (s *dbStore) AddDir(d string, incFactor float64) error {
 
 // AddDir adds a directory and its score to history.
 func (s *dbStore) AddDirRaw(d string, score float64) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	return s.update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketDir))
 		return b.Put([]byte(d), marshalScore(score))
 	})
@@ -62,7 +60,7 @@ func (s *dbStore) AddDirRaw(d string, score float64) error {
 
 // DelDir deletes a directory record from history.
 func (s *dbStore) DelDir(d string) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	return s.update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketDir))
 		return b.Delete([]byte(d))
 	})
@@ -73,7 +71,7 @@ func (s *dbStore) DelDir(d string) error {
 func (s *dbStore) Dirs(blacklist map[string]struct{}) ([]Dir, error) {
 	var dirs []Dir
 
-	err := s.db.View(func(tx *bolt.Tx) error {
+	err := s.view(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketDir))
 		c := b.Cursor()
 		for k, v := c.First(); k != nil; k, v = c.Next() {
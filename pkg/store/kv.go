@@ -0,0 +1,104 @@
+package store
+
+import (
+	bolt "go.etcd.io/bbolt"
+	. "src.elv.sh/pkg/store/storedefs"
+)
+
+// The key-value store keeps a nested bucket for each namespace in bucketKV.
+// Each value is stored as its version, encoded like the command sequence
+// numbers, followed by its data. Versions are taken from the sequence of
+// bucketKV, so that a key that is deleted and set again never gets a version
+// it had before.
+
+func initKV(tx *bolt.Tx) error {
+	_, err := tx.CreateBucketIfNotExists([]byte(bucketKV))
+	return err
+}
+
+// Value queries the value of a key in a namespace.
+func (s *dbStore) Value(ns, key string) (Value, error) {
+	var value Value
+	err := s.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketKV)).Bucket([]byte(ns))
+		if b == nil {
+			return nil
+		}
+		value = unmarshalValue(b.Get([]byte(key)))
+		return nil
+	})
+	return value, err
+}
+
+// SetValue sets the value of a key in a namespace. Unless version is
+// AnyVersion, it must be the current version of the value, or
+// ErrVersionMismatch is returned.
+func (s *dbStore) SetValue(ns, key, data string, version int) error {
+	return s.update(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte(bucketKV))
+		b, err := root.CreateBucketIfNotExists([]byte(ns))
+		if err != nil {
+			return err
+		}
+		if !versionMatches(b, key, version) {
+			return ErrVersionMismatch
+		}
+		seq, err := root.NextSequence()
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), append(marshalSeq(seq), data...))
+	})
+}
+
+// DelValue deletes a key in a namespace. Unless version is AnyVersion, it must
+// be the current version of the value, or ErrVersionMismatch is returned.
+func (s *dbStore) DelValue(ns, key string, version int) error {
+	return s.update(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte(bucketKV))
+		b := root.Bucket([]byte(ns))
+		if b == nil {
+			if version == AnyVersion || version == 0 {
+				return nil
+			}
+			return ErrVersionMismatch
+		}
+		if !versionMatches(b, key, version) {
+			return ErrVersionMismatch
+		}
+		if err := b.Delete([]byte(key)); err != nil {
+			return err
+		}
+		if k, _ := b.Cursor().First(); k == nil {
+			return root.DeleteBucket([]byte(ns))
+		}
+		return nil
+	})
+}
+
+// ValueKeys lists all the keys in a namespace, in lexicographical order.
+func (s *dbStore) ValueKeys(ns string) ([]string, error) {
+	var keys []string
+	err := s.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketKV)).Bucket([]byte(ns))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, _ []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	return keys, err
+}
+
+func versionMatches(b *bolt.Bucket, key string, version int) bool {
+	return version == AnyVersion || unmarshalValue(b.Get([]byte(key))).Version == version
+}
+
+func unmarshalValue(v []byte) Value {
+	if len(v) < 8 {
+		return Value{}
+	}
+	return Value{Data: string(v[8:]), Version: int(unmarshalSeq(v[:8]))}
+}
@@ -0,0 +1,12 @@
+package store_test
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/store/storetest"
+)
+
+func TestKV(t *testing.T) {
+	storetest.TestKV(t, store.MustTempStore(t))
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// A migration changes the schema of the database from one version to the next.
+type migration struct {
+	desc string
+	fn   func(*bolt.Tx) error
+}
+
+// All the migrations, in the order they are applied. The schema version of a
+// database is the number of migrations that have been applied to it, and is
+// kept in bucketMeta.
+//
+// Released migrations must never be changed or removed, since databases may
+// have been migrated with them; change the schema with a new migration
+// instead. The migrations up to initCmdSync predate schema versions, and are
+// also applied to databases created before them, which already have some of
+// the buckets; they are idempotent for this reason.
+var migrations = []migration{
+	{"initialize command history table", initCmd},
+	{"initialize directory history table", initDir},
+	{"initialize key-value table", initKV},
+	{"initialize command history sync tables", initCmdSync},
+}
+
+const keySchemaVersion = "schema-version"
+
+// SchemaVersion returns the schema version that this version of Elvish
+// migrates databases to.
+func SchemaVersion() int { return len(migrations) }
+
+// SchemaTooNewError is returned when opening a database with a schema version
+// newer than SchemaVersion, usually because it has been used by a newer
+// version of Elvish. Such databases are not opened, since older versions of
+// Elvish may not be able to use them correctly.
+type SchemaTooNewError struct {
+	Path    string
+	Version int
+}
+
+func (e *SchemaTooNewError) Error() string {
+	return fmt.Sprintf("database %s has schema version %d, but this version "+
+		"of Elvish only supports up to %d; use a newer version of Elvish",
+		e.Path, e.Version, SchemaVersion())
+}
+
+// Migrate opens the database file and migrates it to the latest schema
+// version, returning the schema versions before and after. The database must
+// not be open by any process.
+func Migrate(dbname string) (from, to int, err error) {
+	db, err := dbWithDefaultOptions(dbname)
+	if err != nil {
+		return 0, 0, err
+	}
+	from, to, err = migrate(db)
+	if err2 := db.Close(); err == nil {
+		err = err2
+	}
+	return from, to, err
+}
+
+// Applies the migrations that haven't been applied to db yet, each in its own
+// transaction together with the update of the schema version, so that an
+// error leaves the database at the version of the last successful migration.
+func migrate(db *bolt.DB) (from, to int, err error) {
+	err = db.View(func(tx *bolt.Tx) error {
+		from, err = getSchemaVersion(tx)
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	if from > len(migrations) {
+		return from, from, &SchemaTooNewError{db.Path(), from}
+	}
+	for to = from; to < len(migrations); to++ {
+		m, version := migrations[to], to+1
+		err := db.Update(func(tx *bolt.Tx) error {
+			if err := m.fn(tx); err != nil {
+				return err
+			}
+			return setSchemaVersion(tx, version)
+		})
+		if err != nil {
+			return from, to, fmt.Errorf("migrate to schema version %d (%s): %w",
+				version, m.desc, err)
+		}
+		logger.Infof("migrated to schema version %d: %s", version, m.desc)
+	}
+	return from, to, nil
+}
+
+// Returns the schema version of the database, or 0 if it was created before
+// schema versions.
+func getSchemaVersion(tx *bolt.Tx) (int, error) {
+	b := tx.Bucket([]byte(bucketMeta))
+	if b == nil {
+		return 0, nil
+	}
+	data := b.Get([]byte(keySchemaVersion))
+	if data == nil {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(string(data))
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid schema version %q", data)
+	}
+	return version, nil
+}
+
+func setSchemaVersion(tx *bolt.Tx, version int) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(bucketMeta))
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(keySchemaVersion), []byte(strconv.Itoa(version)))
+}
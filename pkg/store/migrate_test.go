@@ -0,0 +1,110 @@
+package store_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestMigrate(t *testing.T) {
+	testutil.InTempDir(t)
+	latest := store.SchemaVersion()
+
+	from, to, err := store.Migrate("db")
+	if from != 0 || to != latest || err != nil {
+		t.Errorf("Migrate on new db -> (%v, %v, %v), want (0, %v, nil)",
+			from, to, err, latest)
+	}
+	from, to, err = store.Migrate("db")
+	if from != latest || to != latest || err != nil {
+		t.Errorf("Migrate on migrated db -> (%v, %v, %v), want (%v, %v, nil)",
+			from, to, err, latest, latest)
+	}
+}
+
+func TestMigrate_DBWithoutSchemaVersion(t *testing.T) {
+	testutil.InTempDir(t)
+	// Databases created before schema versions have some of the buckets.
+	updateDB(t, "db", func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("cmd"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("\x00\x00\x00\x00\x00\x00\x00\x01"), []byte("echo"))
+	})
+
+	from, to, err := store.Migrate("db")
+	if from != 0 || to != store.SchemaVersion() || err != nil {
+		t.Errorf("Migrate -> (%v, %v, %v), want (0, %v, nil)",
+			from, to, err, store.SchemaVersion())
+	}
+	st, err := store.NewStore("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if cmd, err := st.Cmd(1); cmd != "echo" || err != nil {
+		t.Errorf("Cmd(1) -> (%q, %v), want (%q, nil)", cmd, err, "echo")
+	}
+}
+
+func TestNewStore_SchemaTooNew(t *testing.T) {
+	testutil.InTempDir(t)
+	setSchemaVersion(t, "db", strconv.Itoa(store.SchemaVersion()+1))
+
+	st, err := store.NewStore("db")
+	var tooNew *store.SchemaTooNewError
+	if st != nil || !errors.As(err, &tooNew) ||
+		tooNew.Version != store.SchemaVersion()+1 || tooNew.Path != "db" {
+		t.Fatalf("NewStore -> (%v, %v), want SchemaTooNewError", st, err)
+	}
+	// The database is left alone and closed.
+	updateDB(t, "db", func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte("cmd")) != nil {
+			t.Errorf("database migrated despite newer schema version")
+		}
+		return nil
+	})
+
+	if _, _, err := store.Migrate("db"); !errors.As(err, &tooNew) {
+		t.Errorf("Migrate -> error %v, want SchemaTooNewError", err)
+	}
+}
+
+func TestNewStore_InvalidSchemaVersion(t *testing.T) {
+	testutil.InTempDir(t)
+	setSchemaVersion(t, "db", "bad")
+
+	_, err := store.NewStore("db")
+	if err == nil || err.Error() != `invalid schema version "bad"` {
+		t.Errorf("NewStore -> error %v, want invalid schema version", err)
+	}
+}
+
+func setSchemaVersion(t *testing.T, dbname, version string) {
+	t.Helper()
+	updateDB(t, dbname, func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("meta"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("schema-version"), []byte(version))
+	})
+}
+
+func updateDB(t *testing.T, dbname string, f func(*bolt.Tx) error) {
+	t.Helper()
+	db, err := bolt.Open(dbname, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Update(f); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -13,6 +13,14 @@ var NoBlacklist = map[string]struct{}{}
 // completes with no result.
 var ErrNoMatchingCmd = errors.New("no matching command line")
 
+// ErrVersionMismatch is the error returned when SetValue or DelValue is called
+// with a version that is not the current version of the value.
+var ErrVersionMismatch = errors.New("version of value does not match")
+
+// AnyVersion can be passed to SetValue and DelValue to change a value
+// regardless of its current version.
+const AnyVersion = -1
+
 // Store is an interface satisfied by the storage service.
 type Store interface {
 	NextCmdSeq() (int, error)
@@ -22,10 +30,17 @@ type Store interface {
 	CmdsWithSeq(from, upto int) ([]Cmd, error)
 	NextCmd(from int, prefix string) (Cmd, error)
 	PrevCmd(upto int, prefix string) (Cmd, error)
+	SyncCmds() ([]SyncCmd, error)
+	MergeCmds(cmds []SyncCmd) (int, error)
 
 	AddDir(dir string, incFactor float64) error
 	DelDir(dir string) error
 	Dirs(blacklist map[string]struct{}) ([]Dir, error)
+
+	Value(ns, key string) (Value, error)
+	SetValue(ns, key, data string, version int) error
+	DelValue(ns, key string, version int) error
+	ValueKeys(ns string) ([]string, error)
 }
 
 // Dir is an entry in the directory history.
@@ -43,3 +58,31 @@ type Cmd struct {
 }
 
 func (Cmd) IsStructMap() {}
+
+// SyncCmd is an entry in the command history, as exchanged with the stores on
+// other hosts when syncing the command history.
+//
+// An entry is identified by the ID of the store it was first added to and its
+// sequence number there, so merging the same entry more than once has no
+// effect.
+type SyncCmd struct {
+	Text string `json:"text"`
+	// When the entry was first added, in milliseconds since the Unix epoch, or
+	// 0 if unknown.
+	Time int64 `json:"time"`
+	// The ID of the store the entry was first added to, and its sequence
+	// number there.
+	Origin string `json:"origin"`
+	Seq    int    `json:"seq"`
+}
+
+// Value is an entry in the key-value store.
+type Value struct {
+	// The value serialized as JSON.
+	Data string
+	// The version of the value, which changes every time the value is set. It
+	// is 0 if the key does not exist.
+	Version int
+}
+
+func (Value) IsStructMap() {}
@@ -0,0 +1,78 @@
+package storetest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+// TestCmdSync tests the command history sync functionality of a Store.
+func TestCmdSync(t *testing.T, store storedefs.Store) {
+	startSeq, _ := store.NextCmdSeq()
+	before := time.Now().UnixMilli()
+	if _, err := store.AddCmd("local"); err != nil {
+		t.Fatalf("store.AddCmd(%q) => error %v", "local", err)
+	}
+	after := time.Now().UnixMilli()
+
+	// SyncCmds
+	cmds, err := store.SyncCmds()
+	if err != nil || len(cmds) == 0 {
+		t.Fatalf("store.SyncCmds() => (%v, %v), want non-empty and nil", cmds, err)
+	}
+	local := cmds[len(cmds)-1]
+	if local.Text != "local" || local.Seq != startSeq || local.Origin == "" ||
+		local.Time < before || local.Time > after {
+		t.Errorf("store.SyncCmds() has last entry %v, "+
+			"want text %q, seq %v, non-empty origin and time in [%v, %v]",
+			local, "local", startSeq, before, after)
+	}
+	id := local.Origin
+
+	// MergeCmds
+	remote := []storedefs.SyncCmd{
+		{Text: "remote 2", Time: 20, Origin: "other", Seq: 2},
+		{Text: "remote 1", Time: 10, Origin: "other", Seq: 1},
+		// Commands from the store itself are never added.
+		{Text: "local", Time: local.Time, Origin: id, Seq: startSeq},
+		{Text: "unknown", Time: 30, Origin: id, Seq: startSeq + 100},
+	}
+	if added, err := store.MergeCmds(remote); added != 2 || err != nil {
+		t.Errorf("store.MergeCmds(...) => (%v, %v), want (2, nil)", added, err)
+	}
+	// Commands from other stores are added in the order they were first added.
+	wantCmds := []storedefs.Cmd{
+		{Text: "local", Seq: startSeq},
+		{Text: "remote 1", Seq: startSeq + 1},
+		{Text: "remote 2", Seq: startSeq + 2},
+	}
+	if cmds, err := store.CmdsWithSeq(startSeq, -1); !reflect.DeepEqual(cmds, wantCmds) || err != nil {
+		t.Errorf("store.CmdsWithSeq(%v, -1) => (%v, %v), want (%v, nil)",
+			startSeq, cmds, err, wantCmds)
+	}
+	// The origins of the added commands are kept.
+	cmds, _ = store.SyncCmds()
+	wantSyncCmds := []storedefs.SyncCmd{local, remote[1], remote[0]}
+	if got := cmds[len(cmds)-3:]; !reflect.DeepEqual(got, wantSyncCmds) {
+		t.Errorf("store.SyncCmds() has last entries %v, want %v", got, wantSyncCmds)
+	}
+
+	// Merging the same commands again has no effect.
+	if added, err := store.MergeCmds(remote); added != 0 || err != nil {
+		t.Errorf("store.MergeCmds(...) again => (%v, %v), want (0, nil)", added, err)
+	}
+	// Deleted commands are not added again.
+	store.DelCmd(startSeq + 1)
+	if added, err := store.MergeCmds(remote[1:2]); added != 0 || err != nil {
+		t.Errorf("store.MergeCmds(...) after deleting => (%v, %v), want (0, nil)", added, err)
+	}
+
+	for _, origin := range []string{"", "a\x00b"} {
+		cmds := []storedefs.SyncCmd{{Text: "bad", Origin: origin, Seq: 1}}
+		if _, err := store.MergeCmds(cmds); err == nil {
+			t.Errorf("store.MergeCmds with origin %q => nil error, want non-nil", origin)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package storetest
+
+import (
+	"reflect"
+	"testing"
+
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+// TestKV tests the key-value store functionality of a Store.
+func TestKV(t *testing.T, store storedefs.Store) {
+	if v, err := store.Value("ns", "foo"); v != (storedefs.Value{}) || err != nil {
+		t.Errorf(`store.Value("ns", "foo") => (%v, %v), want zero Value and <nil>`, v, err)
+	}
+
+	mustSetValue(t, store, "ns", "foo", `"foo 1"`, 0)
+	mustSetValue(t, store, "ns", "bar", `"bar 1"`, storedefs.AnyVersion)
+	mustSetValue(t, store, "other", "foo", `"other foo"`, storedefs.AnyVersion)
+
+	foo := mustValue(t, store, "ns", "foo", `"foo 1"`)
+	bar := mustValue(t, store, "ns", "bar", `"bar 1"`)
+	if foo.Version == bar.Version {
+		t.Errorf("different values have the same version %v", foo.Version)
+	}
+
+	// Compare-and-swap.
+	mustSetValue(t, store, "ns", "foo", `"foo 2"`, foo.Version)
+	if err := store.SetValue("ns", "foo", `"foo 3"`, foo.Version); !matchErr(err, storedefs.ErrVersionMismatch) {
+		t.Errorf("setting with an old version => %v, want %v", err, storedefs.ErrVersionMismatch)
+	}
+	if err := store.SetValue("ns", "foo", `"foo 3"`, 0); !matchErr(err, storedefs.ErrVersionMismatch) {
+		t.Errorf("setting an existent key with version 0 => %v, want %v", err, storedefs.ErrVersionMismatch)
+	}
+	foo2 := mustValue(t, store, "ns", "foo", `"foo 2"`)
+	if err := store.DelValue("ns", "foo", foo.Version); !matchErr(err, storedefs.ErrVersionMismatch) {
+		t.Errorf("deleting with an old version => %v, want %v", err, storedefs.ErrVersionMismatch)
+	}
+
+	keys, err := store.ValueKeys("ns")
+	if wantKeys := []string{"bar", "foo"}; !reflect.DeepEqual(keys, wantKeys) || err != nil {
+		t.Errorf(`store.ValueKeys("ns") => (%v, %v), want (%v, <nil>)`, keys, err, wantKeys)
+	}
+
+	// Deletion.
+	if err := store.DelValue("ns", "foo", foo2.Version); err != nil {
+		t.Errorf("deleting with the current version => %v, want <nil>", err)
+	}
+	if err := store.DelValue("ns", "bar", storedefs.AnyVersion); err != nil {
+		t.Errorf("deleting with AnyVersion => %v, want <nil>", err)
+	}
+	if err := store.DelValue("ns", "bar", storedefs.AnyVersion); err != nil {
+		t.Errorf("deleting a nonexistent key => %v, want <nil>", err)
+	}
+	keys, err = store.ValueKeys("ns")
+	if len(keys) != 0 || err != nil {
+		t.Errorf(`store.ValueKeys("ns") => (%v, %v), want no keys`, keys, err)
+	}
+	mustValue(t, store, "other", "foo", `"other foo"`)
+
+	// A key that is set again after deletion gets a new version.
+	mustSetValue(t, store, "ns", "foo", `"foo 4"`, 0)
+	if foo4 := mustValue(t, store, "ns", "foo", `"foo 4"`); foo4.Version == foo2.Version {
+		t.Errorf("value set again after deletion has the old version %v", foo4.Version)
+	}
+}
+
+func mustSetValue(t *testing.T, store storedefs.Store, ns, key, data string, version int) {
+	t.Helper()
+	if err := store.SetValue(ns, key, data, version); err != nil {
+		t.Errorf("store.SetValue(%q, %q, %q, %v) => %v, want <nil>", ns, key, data, version, err)
+	}
+}
+
+func mustValue(t *testing.T, store storedefs.Store, ns, key, wantData string) storedefs.Value {
+	t.Helper()
+	v, err := store.Value(ns, key)
+	if v.Data != wantData || v.Version == 0 || err != nil {
+		t.Errorf("store.Value(%q, %q) => (%v, %v), want data %q with non-zero version",
+			ns, key, v, err, wantData)
+	}
+	return v
+}
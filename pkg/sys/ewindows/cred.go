@@ -0,0 +1,127 @@
+//go:build windows
+// +build windows
+
+package ewindows
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var advapi32 = windows.NewLazySystemDLL("advapi32.dll")
+
+var (
+	credReadW   = advapi32.NewProc("CredReadW")
+	credWriteW  = advapi32.NewProc("CredWriteW")
+	credDeleteW = advapi32.NewProc("CredDeleteW")
+	credFree    = advapi32.NewProc("CredFree")
+)
+
+const (
+	CRED_TYPE_GENERIC          = 1
+	CRED_PERSIST_LOCAL_MACHINE = 2
+)
+
+// Credential corresponds to the CREDENTIALW struct.
+//
+// https://docs.microsoft.com/en-us/windows/win32/api/wincred/ns-wincred-credentialw
+type Credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// CredRead reads the blob of the generic credential with the given target
+// name. It returns windows.ERROR_NOT_FOUND if there is no such credential.
+//
+// BOOL CredReadW(
+//
+//	[in]  LPCWSTR      TargetName,
+//	[in]  DWORD        Type,
+//	[in]  DWORD        Flags,
+//	[out] PCREDENTIALW *Credential
+//
+// );
+func CredRead(target string) ([]byte, error) {
+	targetPtr, err := windows.UTF16PtrFromString(target)
+	if err != nil {
+		return nil, err
+	}
+	var cred *Credential
+	r, _, err := credReadW.Call(uintptr(unsafe.Pointer(targetPtr)),
+		CRED_TYPE_GENERIC, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		return nil, err
+	}
+	defer credFree.Call(uintptr(unsafe.Pointer(cred)))
+	blob := unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)
+	return append([]byte(nil), blob...), nil
+}
+
+// CredWrite creates or replaces the generic credential with the given target
+// name, persisted for the current user on the local machine.
+//
+// BOOL CredWriteW(
+//
+//	[in] PCREDENTIALW Credential,
+//	[in] DWORD        Flags
+//
+// );
+func CredWrite(target, userName string, blob []byte) error {
+	targetPtr, err := windows.UTF16PtrFromString(target)
+	if err != nil {
+		return err
+	}
+	userNamePtr, err := windows.UTF16PtrFromString(userName)
+	if err != nil {
+		return err
+	}
+	cred := Credential{
+		Type:               CRED_TYPE_GENERIC,
+		TargetName:         targetPtr,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            CRED_PERSIST_LOCAL_MACHINE,
+		UserName:           userNamePtr,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	r, _, err := credWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+// CredDelete deletes the generic credential with the given target name. It
+// returns windows.ERROR_NOT_FOUND if there is no such credential.
+//
+// BOOL CredDeleteW(
+//
+//	[in] LPCWSTR TargetName,
+//	[in] DWORD   Type,
+//	[in] DWORD   Flags
+//
+// );
+func CredDelete(target string) error {
+	targetPtr, err := windows.UTF16PtrFromString(target)
+	if err != nil {
+		return err
+	}
+	r, _, err := credDeleteW.Call(uintptr(unsafe.Pointer(targetPtr)),
+		CRED_TYPE_GENERIC, 0)
+	if r == 0 {
+		return err
+	}
+	return nil
+}
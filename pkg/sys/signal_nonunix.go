@@ -3,8 +3,10 @@
 package sys
 
 import (
+	"errors"
 	"os"
 	"os/signal"
+	"syscall"
 )
 
 func notifySignals() chan os.Signal {
@@ -13,3 +15,14 @@ func notifySignals() chan os.Signal {
 	signal.Notify(sigCh)
 	return sigCh
 }
+
+const (
+	sigTSTP = syscall.Signal(-2)
+	sigCONT = syscall.Signal(-3)
+)
+
+var errSuspendNotSupported = errors.New("suspending is not supported on this platform")
+
+func canSuspend() error { return errSuspendNotSupported }
+
+func suspend() error { return errSuspendNotSupported }
@@ -3,9 +3,13 @@
 package sys
 
 import (
+	"errors"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+
+	"golang.org/x/sys/unix"
 )
 
 func notifySignals() chan os.Signal {
@@ -23,3 +27,65 @@ func notifySignals() chan os.Signal {
 	signal.Ignore(syscall.SIGTTIN, syscall.SIGTTOU, syscall.SIGTSTP)
 	return sigCh
 }
+
+const (
+	sigTSTP = syscall.SIGTSTP
+	sigCONT = syscall.SIGCONT
+)
+
+var (
+	errSuspendLoginShell    = errors.New("cannot suspend a login shell")
+	errSuspendSessionLeader = errors.New("cannot suspend a session leader")
+	errSuspendOrphaned      = errors.New("cannot suspend an orphaned process group")
+)
+
+// Like POSIX shells, this refuses to suspend when there may be nothing to
+// continue the process afterwards.
+func canSuspend() error {
+	if len(os.Args) > 0 && strings.HasPrefix(os.Args[0], "-") {
+		return errSuspendLoginShell
+	}
+	sid, err := unix.Getsid(0)
+	if err != nil {
+		return err
+	}
+	if sid == unix.Getpid() {
+		return errSuspendSessionLeader
+	}
+	if inOrphanedProcessGroup(sid) {
+		return errSuspendOrphaned
+	}
+	return nil
+}
+
+func suspend() error {
+	if err := canSuspend(); err != nil {
+		return err
+	}
+	// SIGSTOP can't be caught, unlike SIGTSTP, which the caller may be
+	// relaying.
+	return syscall.Kill(syscall.Getpid(), syscall.SIGSTOP)
+}
+
+// Reports whether the process group of the process is orphaned, which means
+// that no process in the same session but another process group can continue
+// it. Only the parent process is checked; if the parent is in the same process
+// group, only a job control shell above it could continue the group, which is
+// assumed to exist.
+func inOrphanedProcessGroup(sid int) bool {
+	ppid := unix.Getppid()
+	if ppid == 1 {
+		return true
+	}
+	ppgid, err := unix.Getpgid(ppid)
+	if err != nil {
+		return true
+	}
+	if ppgid == unix.Getpgrp() {
+		return false
+	}
+	// Some systems don't allow querying the session of a process in another
+	// session.
+	psid, err := unix.Getsid(ppid)
+	return err != nil || psid != sid
+}
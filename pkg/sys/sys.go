@@ -17,6 +17,26 @@ func NotifySignals() chan os.Signal { return notifySignals() }
 // SIGWINCH is the window size change signal.
 const SIGWINCH = sigWINCH
 
+// SIGTSTP is the terminal stop signal, and SIGCONT is the signal for continuing
+// a stopped process. They are never delivered on Windows.
+const (
+	SIGTSTP = sigTSTP
+	SIGCONT = sigCONT
+)
+
+// Suspend stops the current process until it gets SIGCONT, which is what
+// happens when a process that doesn't handle SIGTSTP gets it. It is not
+// supported on Windows.
+//
+// Like POSIX shells, it refuses to stop a login shell, a session leader or a
+// process in an orphaned process group, since nothing may be able to continue
+// it; see CanSuspend.
+func Suspend() error { return suspend() }
+
+// CanSuspend returns the error that Suspend would return without stopping the
+// process, or nil if Suspend would stop it.
+func CanSuspend() error { return canSuspend() }
+
 // Winsize queries the size of the terminal referenced by the given file.
 func WinSize(file *os.File) (row, col int) { return winSize(file) }
 
@@ -63,6 +63,18 @@ const (
 	F10
 	F11
 	F12
+	F13
+	F14
+	F15
+	F16
+	F17
+	F18
+	F19
+	F20
+	F21
+	F22
+	F23
+	F24
 
 	Up
 	Down
@@ -76,6 +88,15 @@ const (
 	PageUp
 	PageDown
 
+	// Keys on the numeric keypad. Most terminals send the same sequences for
+	// these keys as their counterparts on the main keyboard, so they are only
+	// seen in terminals that report them distinctly.
+	KeypadEnter
+	KeypadUp
+	KeypadDown
+	KeypadRight
+	KeypadLeft
+
 	// Function key names that are aliases for their ASCII representation.
 	Tab       = '\t'
 	Enter     = '\n'
@@ -97,6 +118,18 @@ var keyNames = map[rune]string{
 	F10:                "F10",
 	F11:                "F11",
 	F12:                "F12",
+	F13:                "F13",
+	F14:                "F14",
+	F15:                "F15",
+	F16:                "F16",
+	F17:                "F17",
+	F18:                "F18",
+	F19:                "F19",
+	F20:                "F20",
+	F21:                "F21",
+	F22:                "F22",
+	F23:                "F23",
+	F24:                "F24",
 	Up:                 "Up",
 	Down:               "Down",
 	Right:              "Right",
@@ -107,6 +140,11 @@ var keyNames = map[rune]string{
 	End:                "End",
 	PageUp:             "PageUp",
 	PageDown:           "PageDown",
+	KeypadEnter:        "KeypadEnter",
+	KeypadUp:           "KeypadUp",
+	KeypadDown:         "KeypadDown",
+	KeypadRight:        "KeypadRight",
+	KeypadLeft:         "KeypadLeft",
 	Tab:                "Tab",
 	Enter:              "Enter",
 	Backspace:          "Backspace",
@@ -49,6 +49,9 @@ var parseKeyTests = []struct {
 	{s: "x", wantKey: K('x')},
 	{s: "Tab", wantKey: K(Tab)},
 	{s: "F1", wantKey: K(F1)},
+	{s: "F24", wantKey: K(F24)},
+	{s: "KeypadEnter", wantKey: K(KeypadEnter)},
+	{s: "Shift-KeypadUp", wantKey: K(KeypadUp, Shift)},
 
 	// Alt- keys are case-sensitive.
 	{s: "A-x", wantKey: Key{'x', Alt}},
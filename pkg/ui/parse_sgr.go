@@ -12,10 +12,25 @@ type sgrTokenizer struct {
 	content string
 }
 
-const sgrPrefix = "\033["
+const (
+	sgrPrefix  = "\033["
+	osc8Prefix = "\033]8;"
+)
 
 func (st *sgrTokenizer) Next() bool {
-	for strings.HasPrefix(st.text, sgrPrefix) {
+	for strings.HasPrefix(st.text, sgrPrefix) || strings.HasPrefix(st.text, osc8Prefix) {
+		if strings.HasPrefix(st.text, osc8Prefix) {
+			styling, ok := st.parseOSC8()
+			if !ok {
+				return false
+			}
+			if styling != nil {
+				st.styling = styling
+				st.content = ""
+				return true
+			}
+			continue
+		}
 		trimmed := strings.TrimPrefix(st.text, sgrPrefix)
 		// Find the terminator of this sequence.
 		termIndex := strings.IndexFunc(trimmed, func(r rune) bool {
@@ -41,13 +56,13 @@ func (st *sgrTokenizer) Next() bool {
 	if st.text == "" {
 		return false
 	}
-	// Parse a content segment until the next SGR prefix.
-	content := ""
-	nextSGR := strings.Index(st.text, sgrPrefix)
-	if nextSGR == -1 {
-		content = st.text
-	} else {
-		content = st.text[:nextSGR]
+	// Parse a content segment until the next SGR or OSC 8 prefix.
+	content := st.text
+	if i := strings.Index(content, sgrPrefix); i != -1 {
+		content = content[:i]
+	}
+	if i := strings.Index(content, osc8Prefix); i != -1 {
+		content = content[:i]
 	}
 	st.text = st.text[len(content):]
 	st.styling = nil
@@ -55,12 +70,37 @@ func (st *sgrTokenizer) Next() bool {
 	return true
 }
 
+// Parses an OSC 8 sequence at the start of the text, which starts or ends a
+// hyperlink, and consumes it. It returns the Styling setting the link, or nil
+// if the sequence is malformed and should be ignored. The returned bool is
+// false if the text ends with an unterminated sequence.
+func (st *sgrTokenizer) parseOSC8() (Styling, bool) {
+	trimmed := st.text[len(osc8Prefix):]
+	// The sequence is terminated by either BEL or ST.
+	end, termLen := strings.IndexByte(trimmed, '\a'), 1
+	if i := strings.Index(trimmed, "\033\\"); i != -1 && (end == -1 || i < end) {
+		end, termLen = i, 2
+	}
+	if end == -1 {
+		st.text = ""
+		return nil, false
+	}
+	st.text = trimmed[end+termLen:]
+	// The parameters are followed by the URL.
+	_, url, ok := strings.Cut(trimmed[:end], ";")
+	if !ok {
+		return nil, true
+	}
+	return Link(url), true
+}
+
 func (st *sgrTokenizer) Token() (Styling, string) {
 	return st.styling, st.content
 }
 
-// ParseSGREscapedText parses SGR-escaped text into a Text. It also removes
-// non-SGR CSI sequences sequences in the text.
+// ParseSGREscapedText parses SGR-escaped text into a Text. OSC 8 sequences set
+// the links of the segments. It also removes non-SGR CSI sequences sequences in
+// the text.
 func ParseSGREscapedText(s string) Text {
 	var text Text
 	var style Style
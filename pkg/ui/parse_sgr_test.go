@@ -20,6 +20,15 @@ func TestParseSGREscapedText(t *testing.T) {
 		// Control characters not part of CSI escape sequences are left
 		// untouched.
 		Args("t\x01ext").Rets(T("t\x01ext")),
+		// OSC 8 sequences set links, and are terminated by either BEL or ST.
+		Args("\033]8;;https://elv.sh\alink\033]8;;\033\\text").Rets(
+			Concat(T("link", Link("https://elv.sh")), T("text"))),
+		// SGR resets don't remove links.
+		Args("\033]8;id=1;https://elv.sh\a\033[1mbold\033[mlink").Rets(
+			Concat(T("bold", Bold, Link("https://elv.sh")),
+				T("link", Link("https://elv.sh")))),
+		// Malformed and unterminated OSC 8 sequences are removed.
+		Args("\033]8;\atext\033]8;;https://elv.sh").Rets(T("text")),
 	})
 }
 
@@ -15,6 +15,9 @@ type Style struct {
 	Underlined bool
 	Blink      bool
 	Inverse    bool
+	// If not empty, the URL that the text links to. Terminals that support
+	// OSC 8 show the text as a hyperlink.
+	Link string
 }
 
 // SGRValues returns an array of the individual SGR values for the style.
@@ -91,6 +94,12 @@ func (s *Style) MergeFromOptions(options map[string]any) error {
 			need = assignBool(v, &s.Blink)
 		case "inverse":
 			need = assignBool(v, &s.Inverse)
+		case "link":
+			if link, ok := v.(string); ok {
+				s.Link = link
+			} else {
+				need = "string"
+			}
 
 		default:
 			return fmt.Errorf("unrecognized option '%s'", k)
@@ -20,6 +20,22 @@ func TestStyleSGR(t *testing.T) {
 	})
 }
 
+func TestStyleLink(t *testing.T) {
+	// Links are written as OSC 8 sequences, which are not part of the SGR.
+	testTextVTString(t, []textVTStringTest{
+		{T("foo", Link("https://elv.sh")),
+			"\033]8;;https://elv.sh\a\033[mfoo\033]8;;\a"},
+		{T("foo", Link("https://elv.sh"), Bold),
+			"\033]8;;https://elv.sh\a\033[;1mfoo\033]8;;\a\033[m"},
+		// Adjacent segments with the same link share the hyperlink.
+		{Concat(T("foo", Link("https://elv.sh"), Bold), T("bar", Link("https://elv.sh"))),
+			"\033]8;;https://elv.sh\a\033[;1mfoo\033[mbar\033]8;;\a"},
+		// Control characters are removed from links.
+		{T("foo", Link("https://elv.sh/\a\033")),
+			"\033]8;;https://elv.sh/\a\033[mfoo\033]8;;\a"},
+	})
+}
+
 type mergeFromOptionsTest struct {
 	style     Style
 	options   map[string]any
@@ -37,6 +53,7 @@ var mergeFromOptionsTests = []mergeFromOptionsTest{
 	kv("underlined", true, Style{Underlined: true}),
 	kv("blink", true, Style{Blink: true}),
 	kv("inverse", true, Style{Inverse: true}),
+	kv("link", "https://elv.sh", Style{Link: "https://elv.sh"}),
 	// Merging with existing options.
 	{
 		style: Style{Bold: true, Dim: true},
@@ -60,6 +77,11 @@ var mergeFromOptionsTests = []mergeFromOptionsTest{
 		options: map[string]any{"bold": ""},
 		wantErr: "value for option 'bold' must be a bool value",
 	},
+	// Bad type for link.
+	{
+		options: map[string]any{"link": true},
+		wantErr: "value for option 'link' must be a string",
+	},
 }
 
 // A helper for constructing a test case whose input is a single key-value pair.
@@ -39,6 +39,7 @@ func Stylings(ts ...Styling) Styling { return jointStyling(ts) }
 
 // Common stylings.
 var (
+	// Reset resets all the attributes except the link, like SGR 0.
 	Reset Styling = reset{}
 
 	FgDefault Styling = setForeground{nil}
@@ -109,16 +110,22 @@ func Fg(c Color) Styling { return setForeground{c} }
 // Bg returns a Styling that sets the background color.
 func Bg(c Color) Styling { return setBackground{c} }
 
+// Link returns a Styling that makes the text link to url. An empty url
+// removes the link.
+func Link(url string) Styling { return setLink{url} }
+
 type reset struct{}
 type setForeground struct{ c Color }
 type setBackground struct{ c Color }
+type setLink struct{ url string }
 type boolOn struct{ f boolField }
 type boolOff struct{ f boolField }
 type boolToggle struct{ f boolField }
 
-func (reset) transform(s *Style)           { *s = Style{} }
+func (reset) transform(s *Style)           { *s = Style{Link: s.Link} }
 func (t setForeground) transform(s *Style) { s.Fg = t.c }
 func (t setBackground) transform(s *Style) { s.Bg = t.c }
+func (t setLink) transform(s *Style)       { s.Link = t.url }
 func (t boolOn) transform(s *Style)        { *t.f.get(s) = true }
 func (t boolOff) transform(s *Style)       { *t.f.get(s) = false }
 func (t boolToggle) transform(s *Style)    { p := t.f.get(s); *p = !*p }
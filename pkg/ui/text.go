@@ -219,11 +219,17 @@ func (t Text) String() string {
 }
 
 // VTString renders the styled text using VT-style escape sequences. Any
-// existing SGR state will be cleared.
+// existing SGR state will be cleared. Segments with links are written as OSC 8
+// hyperlinks.
 func (t Text) VTString() string {
 	var sb strings.Builder
 	clean := false
+	link := ""
 	for _, seg := range t {
+		if seg.Link != link {
+			sb.WriteString(osc8(seg.Link))
+			link = seg.Link
+		}
 		sgr := seg.SGR()
 		if sgr == "" {
 			if !clean {
@@ -240,6 +246,9 @@ func (t Text) VTString() string {
 		}
 		sb.WriteString(seg.Text)
 	}
+	if link != "" {
+		sb.WriteString(osc8(""))
+	}
 	if !clean {
 		sb.WriteString("\033[m")
 	}
@@ -44,6 +44,7 @@ func (s *Segment) Repr(int) string {
 	addIfNotEqual("underlined", s.Underlined, false)
 	addIfNotEqual("blink", s.Blink, false)
 	addIfNotEqual("inverse", s.Inverse, false)
+	addIfNotEqual("link", s.Link, "")
 
 	if buf.Len() == 0 {
 		return parse.Quote(s.Text)
@@ -54,7 +55,7 @@ func (s *Segment) Repr(int) string {
 
 // IterateKeys feeds the function with all valid attributes of styled-segment.
 func (*Segment) IterateKeys(fn func(v any) bool) {
-	vals.Feed(fn, "text", "fg-color", "bg-color", "bold", "dim", "italic", "underlined", "blink", "inverse")
+	vals.Feed(fn, "text", "fg-color", "bg-color", "bold", "dim", "italic", "underlined", "blink", "inverse", "link")
 }
 
 // Index provides access to the attributes of a styled-segment.
@@ -84,6 +85,8 @@ func (s *Segment) Index(k any) (v any, ok bool) {
 		v = s.Blink
 	case "inverse":
 		v = s.Inverse
+	case "link":
+		v = s.Link
 	}
 
 	return v, v != nil
@@ -146,11 +149,29 @@ func (s *Segment) String() string {
 }
 
 // VTString renders the styled segment using VT-style escape sequences. Any
-// existing SGR state will be cleared.
+// existing SGR state will be cleared. If the segment has a link, it is written
+// as an OSC 8 hyperlink.
 func (s *Segment) VTString() string {
+	text := s.Text
+	if s.Link != "" {
+		text = osc8(s.Link) + text + osc8("")
+	}
 	sgr := s.SGR()
 	if sgr == "" {
-		return "\033[m" + s.Text
+		return "\033[m" + text
 	}
-	return fmt.Sprintf("\033[;%sm%s\033[m", sgr, s.Text)
+	return fmt.Sprintf("\033[;%sm%s\033[m", sgr, text)
+}
+
+// Returns the OSC 8 sequence that starts a hyperlink to url, or ends the
+// current one if url is empty. Control characters are removed from url, so
+// that it can't end the sequence early.
+func osc8(url string) string {
+	url = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, url)
+	return "\033]8;;" + url + "\a"
 }
@@ -11,7 +11,7 @@ func TestTextSegmentAsElvishValue(t *testing.T) {
 		Kind("ui:text-segment").
 		Repr("foo").
 		AllKeys("text", "fg-color", "bg-color",
-			"bold", "dim", "italic", "underlined", "blink", "inverse").
+			"bold", "dim", "italic", "underlined", "blink", "inverse", "link").
 		Index("text", "foo").
 		Index("fg-color", "default").
 		Index("bg-color", "default").
@@ -20,12 +20,17 @@ func TestTextSegmentAsElvishValue(t *testing.T) {
 		Index("italic", false).
 		Index("underlined", false).
 		Index("blink", false).
-		Index("inverse", false)
+		Index("inverse", false).
+		Index("link", "")
 
 	vals.TestValue(t, &Segment{Style{Fg: Red, Bg: Blue}, "foo"}).
 		Repr("(ui:text-segment foo &fg-color=red &bg-color=blue)").
 		Index("fg-color", "red").
 		Index("bg-color", "blue")
+
+	vals.TestValue(t, &Segment{Style{Link: "https://elv.sh"}, "foo"}).
+		Repr("(ui:text-segment foo &link=https://elv.sh)").
+		Index("link", "https://elv.sh")
 }
 
 var textSegmentVTStringTests = []struct {
@@ -43,6 +48,11 @@ var textSegmentVTStringTests = []struct {
 		seg:          &Segment{Style: Style{Bold: true}, Text: "foo"},
 		wantVTString: "\033[;1mfoo\033[m",
 	},
+	{
+		name:         "seg with link",
+		seg:          &Segment{Style: Style{Bold: true, Link: "https://elv.sh"}, Text: "foo"},
+		wantVTString: "\033[;1m\033]8;;https://elv.sh\afoo\033]8;;\a\033[m",
+	},
 }
 
 func TestTextSegmentVTString(t *testing.T) {
@@ -0,0 +1,110 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Role is a Styling that is looked up by name in the theme, so that the
+// styling of all the places that use the same role can be changed in one
+// place. When the theme doesn't have the role, the default styling is used.
+//
+// Since the lookup happens when the Styling is applied, Text that has already
+// been styled is not affected by changes to the theme.
+type Role struct {
+	Name    string
+	Default Styling
+}
+
+var (
+	themeMutex sync.RWMutex
+	roles      = map[string]Role{}
+	theme      = map[string]themeEntry{}
+)
+
+type themeEntry struct {
+	spec    string
+	styling Styling
+}
+
+// DefineRole defines a role with the given name and default styling. It is
+// meant to be called when initializing package-level variables, and panics if
+// a role with the same name has already been defined.
+func DefineRole(name string, def Styling) Role {
+	themeMutex.Lock()
+	defer themeMutex.Unlock()
+	if _, ok := roles[name]; ok {
+		panic("role " + name + " defined twice")
+	}
+	r := Role{name, def}
+	roles[name] = r
+	return r
+}
+
+// LookupRole returns the role with the given name and whether it exists.
+func LookupRole(name string) (Role, bool) {
+	themeMutex.RLock()
+	defer themeMutex.RUnlock()
+	r, ok := roles[name]
+	return r, ok
+}
+
+// Roles returns all the defined roles, sorted by name.
+func Roles() []Role {
+	themeMutex.RLock()
+	defer themeMutex.RUnlock()
+	rs := make([]Role, 0, len(roles))
+	for _, r := range roles {
+		rs = append(rs, r)
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].Name < rs[j].Name })
+	return rs
+}
+
+// Theme returns the stylings of the roles in the theme, in the format accepted
+// by ParseStyling.
+func Theme() map[string]string {
+	themeMutex.RLock()
+	defer themeMutex.RUnlock()
+	m := make(map[string]string, len(theme))
+	for name, e := range theme {
+		m[name] = e.spec
+	}
+	return m
+}
+
+// SetTheme replaces the theme. The keys of m must be names of defined roles,
+// and the values are parsed with ParseStyling; an empty string means no
+// styling. It returns an error without changing the theme if m is invalid.
+func SetTheme(m map[string]string) error {
+	newTheme := make(map[string]themeEntry, len(m))
+	for name, spec := range m {
+		if _, ok := LookupRole(name); !ok {
+			return fmt.Errorf("unknown role %q", name)
+		}
+		styling := Styling(jointStyling(nil))
+		if spec != "" {
+			styling = ParseStyling(spec)
+			if styling == nil {
+				return fmt.Errorf("invalid styling %q for role %s", spec, name)
+			}
+		}
+		newTheme[name] = themeEntry{spec, styling}
+	}
+	themeMutex.Lock()
+	defer themeMutex.Unlock()
+	theme = newTheme
+	return nil
+}
+
+func (r Role) transform(s *Style) {
+	themeMutex.RLock()
+	e, ok := theme[r.Name]
+	themeMutex.RUnlock()
+	if ok {
+		e.styling.transform(s)
+	} else if r.Default != nil {
+		r.Default.transform(s)
+	}
+}
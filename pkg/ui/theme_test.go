@@ -0,0 +1,74 @@
+package ui
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testRole = DefineRole("test-role", FgRed)
+
+func TestRole(t *testing.T) {
+	t.Cleanup(func() { SetTheme(nil) })
+
+	if s := ApplyStyling(Style{}, testRole); s != (Style{Fg: Red}) {
+		t.Errorf("got %v with default theme, want fg red", s)
+	}
+
+	err := SetTheme(map[string]string{"test-role": "bold fg-blue"})
+	if err != nil {
+		t.Fatalf("SetTheme returned error: %v", err)
+	}
+	if s := ApplyStyling(Style{}, testRole); s != (Style{Fg: Blue, Bold: true}) {
+		t.Errorf("got %v after SetTheme, want fg blue and bold", s)
+	}
+	if theme := Theme(); !reflect.DeepEqual(theme, map[string]string{"test-role": "bold fg-blue"}) {
+		t.Errorf("got theme %v", theme)
+	}
+
+	SetTheme(map[string]string{"test-role": ""})
+	if s := ApplyStyling(Style{}, testRole); s != (Style{}) {
+		t.Errorf("got %v after setting empty styling, want no styling", s)
+	}
+}
+
+func TestSetTheme_Errors(t *testing.T) {
+	t.Cleanup(func() { SetTheme(nil) })
+	SetTheme(map[string]string{"test-role": "blue"})
+
+	for _, m := range []map[string]string{
+		{"no-such-role": "red"},
+		{"test-role": "bad-styling"},
+	} {
+		if err := SetTheme(m); err == nil {
+			t.Errorf("SetTheme(%v) returned no error", m)
+		}
+	}
+	if theme := Theme(); !reflect.DeepEqual(theme, map[string]string{"test-role": "blue"}) {
+		t.Errorf("got theme %v after errors, want it unchanged", theme)
+	}
+}
+
+func TestLookupRole(t *testing.T) {
+	if r, ok := LookupRole("test-role"); !ok || r != testRole {
+		t.Errorf("LookupRole(test-role) returns %v, %v", r, ok)
+	}
+	if _, ok := LookupRole("no-such-role"); ok {
+		t.Errorf("LookupRole(no-such-role) returns true")
+	}
+	found := false
+	for _, r := range Roles() {
+		found = found || r == testRole
+	}
+	if !found {
+		t.Errorf("Roles() doesn't contain test-role")
+	}
+}
+
+func TestDefineRole_PanicsOnDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("DefineRole didn't panic")
+		}
+	}()
+	DefineRole("test-role", Bold)
+}
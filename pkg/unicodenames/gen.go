@@ -0,0 +1,66 @@
+//go:build ignore
+
+// Generates names.txt.gz from UnicodeData.txt, which can be downloaded from
+// https://www.unicode.org/Public/UCD/latest/ucd/UnicodeData.txt.
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var output = flag.String("o", "names.txt.gz", "output file")
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatal("usage: go run gen.go [-o output] UnicodeData.txt")
+	}
+	in, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer in.Close()
+	out, err := os.Create(*output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	gz, err := gzip.NewWriterLevel(out, gzip.BestCompression)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ";")
+		if len(fields) < 2 {
+			continue
+		}
+		hex, name := fields[0], fields[1]
+		r, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// Skip controls, ranges like "<CJK Ideograph, First>" and names
+		// derived from code points like "CJK COMPATIBILITY IDEOGRAPH-F900".
+		if strings.HasPrefix(name, "<") || strings.HasSuffix(name, fmt.Sprintf("-%04X", r)) {
+			continue
+		}
+		fmt.Fprintf(gz, "%X;%s\n", r, name)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,115 @@
+// Package unicodenames provides the names of Unicode characters.
+//
+// The names come from UnicodeData.txt of Unicode 14.0.0. Characters whose
+// names are derived from their code points, like CJK unified ideographs and
+// Hangul syllables, are not included.
+package unicodenames
+
+//go:generate go run ./gen.go -o names.txt.gz UnicodeData.txt
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Lines of the form "hex;NAME", sorted by code point.
+//
+//go:embed names.txt.gz
+var namesGz []byte
+
+type entry struct {
+	r    rune
+	name string
+}
+
+var (
+	loadOnce sync.Once
+	entries  []entry
+	byName   map[string]rune
+)
+
+// The table is only decompressed when it's first used, since it takes about
+// 1MB of memory.
+func load() {
+	loadOnce.Do(func() {
+		gz, err := gzip.NewReader(bytes.NewReader(namesGz))
+		if err != nil {
+			panic(err)
+		}
+		byName = make(map[string]rune)
+		scanner := bufio.NewScanner(gz)
+		for scanner.Scan() {
+			hex, name, _ := strings.Cut(scanner.Text(), ";")
+			r, err := strconv.ParseUint(hex, 16, 32)
+			if err != nil {
+				panic(err)
+			}
+			entries = append(entries, entry{rune(r), name})
+			byName[name] = rune(r)
+		}
+		if err := scanner.Err(); err != nil {
+			panic(err)
+		}
+	})
+}
+
+// Name returns the name of r, or "" if it has no name in the table.
+func Name(r rune) string {
+	load()
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].r >= r })
+	if i < len(entries) && entries[i].r == r {
+		return entries[i].name
+	}
+	return ""
+}
+
+// Lookup returns the character with the given name, compared
+// case-insensitively.
+func Lookup(name string) (rune, bool) {
+	load()
+	r, ok := byName[strings.ToUpper(name)]
+	return r, ok
+}
+
+// Search returns the characters whose names have words starting with each of
+// the words in query, compared case-insensitively, in the order of their code
+// points. Words in names are separated by spaces and hyphens. It returns nil if
+// query has no words.
+func Search(query string) []rune {
+	words := strings.Fields(strings.ToUpper(query))
+	if len(words) == 0 {
+		return nil
+	}
+	load()
+	var rs []rune
+entries:
+	for _, e := range entries {
+		for _, word := range words {
+			if !hasWordWithPrefix(e.name, word) {
+				continue entries
+			}
+		}
+		rs = append(rs, e.r)
+	}
+	return rs
+}
+
+func hasWordWithPrefix(name, prefix string) bool {
+	for i := 0; i+len(prefix) <= len(name); {
+		j := strings.Index(name[i:], prefix)
+		if j == -1 {
+			return false
+		}
+		if i+j == 0 || name[i+j-1] == ' ' || name[i+j-1] == '-' {
+			return true
+		}
+		i += j + 1
+	}
+	return false
+}
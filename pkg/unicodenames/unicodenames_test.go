@@ -0,0 +1,37 @@
+package unicodenames
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/tt"
+)
+
+var Args = tt.Args
+
+func TestName(t *testing.T) {
+	tt.Test(t, tt.Fn("Name", Name), tt.Table{
+		Args('a').Rets("LATIN SMALL LETTER A"),
+		Args('é').Rets("LATIN SMALL LETTER E WITH ACUTE"),
+		Args('\U0001F600').Rets("GRINNING FACE"),
+		Args('\n').Rets(""),
+		Args('中').Rets(""),
+		Args(rune(0x10FFFF)).Rets(""),
+	})
+}
+
+func TestLookup(t *testing.T) {
+	tt.Test(t, tt.Fn("Lookup", Lookup), tt.Table{
+		Args("GREEK SMALL LETTER ALPHA").Rets('α', true),
+		Args("greek small letter alpha").Rets('α', true),
+		Args("no such character").Rets(rune(0), false),
+	})
+}
+
+func TestSearch(t *testing.T) {
+	tt.Test(t, tt.Fn("Search", Search), tt.Table{
+		Args("e acute small").Rets([]rune{'é', 'ḗ', 'ế'}),
+		Args("snowman").Rets([]rune{'☃', '⛄', '⛇'}),
+		Args("  ").Rets([]rune(nil)),
+		Args("nonexistentword").Rets([]rune(nil)),
+	})
+}
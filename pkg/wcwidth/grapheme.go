@@ -0,0 +1,100 @@
+package wcwidth
+
+import "unicode/utf8"
+
+// A simplified implementation of extended grapheme clusters, as specified in
+// UAX #29 (https://unicode.org/reports/tr29/). It covers what matters for
+// determining column widths on the terminal: combining marks, variation
+// selectors, emoji modifiers, emoji ZWJ sequences, emoji tag sequences and
+// flags made of regional indicators.
+
+const (
+	zwj                   = 0x200D
+	textPresentation      = 0xFE0E
+	emojiPresentation     = 0xFE0F
+	regionalIndicatorLow  = 0x1F1E6
+	regionalIndicatorHigh = 0x1F1FF
+)
+
+func isRegionalIndicator(r rune) bool {
+	return regionalIndicatorLow <= r && r <= regionalIndicatorHigh
+}
+
+func isEmojiModifier(r rune) bool { return 0x1F3FB <= r && r <= 0x1F3FF }
+
+func isControl(r rune) bool { return r < 32 || (0x7f <= r && r < 0xa0) }
+
+// Reports whether r extends a grapheme cluster it follows.
+func isExtender(r rune) bool {
+	return r == zwj || isCombining(r) || isEmojiModifier(r)
+}
+
+// ClusterLen returns the length in bytes of the first grapheme cluster of s,
+// or 0 if s is empty.
+func ClusterLen(s string) int {
+	first, n := utf8.DecodeRuneInString(s)
+	if n == 0 || isControl(first) || (first == utf8.RuneError && n == 1) {
+		// Empty string, control character or invalid byte.
+		return n
+	}
+	prev := first
+	for n < len(s) {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		switch {
+		case isControl(r):
+			return n
+		case prev == zwj, isExtender(r):
+			// ZWJ joins whatever comes after it, like in emoji ZWJ sequences.
+		case isRegionalIndicator(r) && isRegionalIndicator(first) && n == utf8.RuneLen(first):
+			// The second regional indicator of a flag.
+		default:
+			return n
+		}
+		prev = r
+		n += size
+	}
+	return n
+}
+
+// LastClusterLen returns the length in bytes of the last grapheme cluster of
+// s, or 0 if s is empty. Since grapheme clusters can't always be determined
+// by scanning backwards, this scans s from the beginning.
+func LastClusterLen(s string) int {
+	last := 0
+	for i := 0; i < len(s); i += last {
+		last = ClusterLen(s[i:])
+	}
+	return last
+}
+
+// OfCluster returns the column width of a single grapheme cluster.
+//
+// The width is normally the width of the first rune, with the following
+// exceptions: a pair of regional indicators (a flag) and a cluster that
+// requests emoji presentation with U+FE0F are 2 columns wide, while one that
+// requests text presentation with U+FE0E is 1 column wide.
+func OfCluster(c string) int {
+	first, n := utf8.DecodeRuneInString(c)
+	w := OfRune(first)
+	if n == len(c) || w == 0 {
+		return w
+	}
+	if isRegionalIndicator(first) {
+		return 2
+	}
+	if r, _ := utf8.DecodeRuneInString(c[n:]); r == emojiPresentation {
+		return 2
+	} else if r == textPresentation {
+		return 1
+	}
+	return w
+}
+
+// Clusters calls f with each grapheme cluster of s.
+func Clusters(s string, f func(c string)) {
+	for len(s) > 0 {
+		n := ClusterLen(s)
+		f(s[:n])
+		s = s[n:]
+	}
+}
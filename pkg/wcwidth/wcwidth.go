@@ -95,7 +95,10 @@ func OfRune(r rune) int {
 			(r >= 0xffe0 && r <= 0xffe6) || /* Fullwidth Forms */
 			(r >= 0x20000 && r <= 0x2fffd) || /* CJK Extensions */
 			(r >= 0x30000 && r <= 0x3fffd) || /* Reserved for historical Chinese scripts */
-			(r >= 0x1f300 && r <= 0x1f6ff)) { // Miscellaneous Symbols and Pictographs ... Geometric Shapes Extended
+			(r >= 0x1f300 && r <= 0x1f64f) || /* Miscellaneous Symbols and Pictographs ... Emoticons */
+			(r >= 0x1f680 && r <= 0x1f6ff) || /* Transport and Map Symbols */
+			(r >= 0x1f900 && r <= 0x1f9ff) || /* Supplemental Symbols and Pictographs */
+			(r >= 0x1fa70 && r <= 0x1faff)) { // Symbols and Pictographs Extended-A
 		return 2
 	}
 	return 1
@@ -127,39 +130,38 @@ func Unoverride(r rune) {
 	delete(override, r)
 }
 
-// Of returns the column width of a string, assuming no soft line breaks.
+// Of returns the column width of a string, assuming no soft line breaks. The
+// string is split into grapheme clusters, and the width of each cluster is
+// determined with OfCluster.
 func Of(s string) (w int) {
-	for _, r := range s {
-		w += OfRune(r)
+	for len(s) > 0 {
+		n := ClusterLen(s)
+		w += OfCluster(s[:n])
+		s = s[n:]
 	}
 	return
 }
 
-// Trim trims the string s so that it has a column width of at most wmax.
+// Trim trims the string s so that it has a column width of at most wmax. It
+// never splits grapheme clusters.
 func Trim(s string, wmax int) string {
 	w := 0
-	for i, r := range s {
-		w += OfRune(r)
+	for i := 0; i < len(s); {
+		n := ClusterLen(s[i:])
+		w += OfCluster(s[i : i+n])
 		if w > wmax {
 			return s[:i]
 		}
+		i += n
 	}
 	return s
 }
 
 // Force forces the string s to the given column width by trimming and padding.
+// Like Trim, it never splits grapheme clusters.
 func Force(s string, width int) string {
-	w := 0
-	for i, r := range s {
-		w0 := OfRune(r)
-		w += w0
-		if w > width {
-			w -= w0
-			s = s[:i]
-			break
-		}
-	}
-	return s + strings.Repeat(" ", width-w)
+	s = Trim(s, width)
+	return s + strings.Repeat(" ", width-Of(s))
 }
 
 // TrimEachLine trims each line of s so that it is no wider than the specified
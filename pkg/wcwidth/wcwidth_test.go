@@ -18,6 +18,41 @@ func TestOf(t *testing.T) {
 
 		Args("abc").Rets(3),
 		Args("你好").Rets(4),
+
+		// Grapheme clusters
+		Args("e\u0301").Rets(1),                                  // e with combining acute accent
+		Args("\U0001F44B\U0001F3FD").Rets(2),                     // Waving hand with skin tone
+		Args("\U0001F469\u200D\U0001F4BB").Rets(2),               // Woman technologist (ZWJ sequence)
+		Args("\U0001F1EB\U0001F1F7").Rets(2),                     // Flag of France
+		Args("\U0001F1EB\U0001F1F7\U0001F1E9\U0001F1EA").Rets(4), // Two flags
+		Args("\u2764\uFE0F").Rets(2),                             // Heart with emoji presentation
+		Args("\U0001F600\uFE0E").Rets(1),                         // Emoji with text presentation
+		Args("\U0001F914").Rets(2),                               // Thinking face
+	})
+}
+
+func TestClusterLen(t *testing.T) {
+	tt.Test(t, tt.Fn("ClusterLen", ClusterLen), tt.Table{
+		Args("").Rets(0),
+		Args("ab").Rets(1),
+		Args("e\u0301x").Rets(3),
+		Args("\r\n").Rets(1),
+		Args("\u009b\u0301").Rets(2),
+		Args("\xff\u0301").Rets(1),
+		Args("\u0301").Rets(2),
+		Args("\U0001F469\u200D\U0001F4BBx").Rets(11),
+		Args("\U0001F1EB\U0001F1F7\U0001F1E9").Rets(8),
+	})
+}
+
+func TestLastClusterLen(t *testing.T) {
+	tt.Test(t, tt.Fn("LastClusterLen", LastClusterLen), tt.Table{
+		Args("").Rets(0),
+		Args("ab").Rets(1),
+		Args("xe\u0301").Rets(3),
+		Args("x\U0001F469\u200D\U0001F4BB").Rets(11),
+		// The last regional indicator is not paired with the one before it.
+		Args("\U0001F1EB\U0001F1F7\U0001F1E9").Rets(4),
 	})
 }
 
@@ -61,6 +96,9 @@ func TestTrim(t *testing.T) {
 		Args("你好", 3).Rets("你"),
 		Args("你好", 4).Rets("你好"),
 		Args("你好", 5).Rets("你好"),
+
+		Args("e\u0301e\u0301", 1).Rets("e\u0301"),
+		Args("\U0001F469\u200D\U0001F4BB", 1).Rets(""),
 	})
 }
 